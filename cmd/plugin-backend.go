@@ -1,34 +1,165 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"math"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/openshift/logging-view-plugin/pkg/server"
+	"github.com/openshift/logging-view-plugin/pkg/version"
+	"github.com/openshift/logging-view-plugin/web"
 	"github.com/sirupsen/logrus"
 )
 
 var (
-	portArg       = flag.Int("port", 0, "server port to listen on (default: 9002)")
-	certArg       = flag.String("cert", "", "cert file path to enable TLS (disabled by default)")
-	keyArg        = flag.String("key", "", "private key file path to enable TLS (disabled by default)")
-	featuresArg   = flag.String("features", "", "enabled features, comma separated")
-	staticPathArg = flag.String("static-path", "", "static files path to serve frontend (default: './web/dist')")
-	configPathArg = flag.String("config-path", "", "config files path (default: './config')")
-	log           = logrus.WithField("module", "main")
+	portArg           = flag.Int("port", 0, "server port to listen on (default: 9002)")
+	addressArg        = flag.String("address", "", "IP address to bind, IPv6 literals may be bracketed (default: all interfaces)")
+	ipFamilyArg       = flag.String("ip-family", "", "IP family to listen on: ipv4, ipv6 or dual (default: dual, or the family of -address)")
+	listenSocketArg   = flag.String("listen-socket", "", "unix socket path to listen on instead of the TCP port, for a reverse proxy in the pod (optional)")
+	listenAddressArg  = flag.String("listen-address", "", "<ip>:<port> to listen on, [<ipv6>%<zone>]:<port> for link-local addresses, overrides -address and -port (optional)")
+	certArg           = flag.String("cert", "", "cert file path to enable TLS (disabled by default)")
+	keyArg            = flag.String("key", "", "private key file path to enable TLS (disabled by default)")
+	certSecretArg     = flag.String("cert-secret", "", "<namespace>/<name> of a TLS secret to read the serving certificate from, alternative to -cert and -key")
+	tlsMinVersionArg  = flag.String("tls-min-version", "", "minimum TLS version of the served connections: 1.2 or 1.3 (default: 1.2)")
+	tlsMaxVersionArg  = flag.String("tls-max-version", "", "maximum TLS version of the served connections: 1.2 or 1.3 (default: 1.3)")
+	tlsCiphersArg     = flag.String("tls-cipher-suites", "", "TLS 1.2 cipher suites, comma separated crypto/tls names like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 (default: Go defaults)")
+	clientCAFileArg   = flag.String("client-ca-file", "", "CA bundle verifying the client certificates, required on the /api/ routes when set (disabled by default)")
+	redirectPortArg   = flag.Int("http-redirect-port", 0, "port of a plain HTTP listener redirecting to the HTTPS port when TLS is enabled (default: disabled)")
+	internalPortArg   = flag.Int("internal-port", 0, "port of a plain HTTP listener serving the probes and the metrics instead of the main port (default: disabled)")
+	certExpiryArg     = flag.Duration("cert-expiry-warning", 0, "time before the serving certificate expiry from which /readyz reports a warning (default: 720h)")
+	sniCertsArg       = flag.String("sni-certs", "", "additional certificates per SNI hostname, comma separated <hostname>=<cert-file>:<key-file> entries")
+	featuresArg       = flag.String("features", "", "enabled features, comma separated")
+	staticPathArg     = flag.String("static-path", "", "static files path to serve frontend, colon separated directories searched in order to override files (default: the embedded frontend, or './web/dist' when not embedded)")
+	staticRootsArg    = flag.String("static-roots", "", "additional static roots, comma separated <prefix>=<path>[:<max-age>] entries")
+	spaFallbackArg    = flag.String("spa-fallback", "", "path prefixes of the frontend routes served with the fallback file when no file matches, comma separated (default: disabled)")
+	spaFileArg        = flag.String("spa-fallback-file", "", "file of the static path served for the frontend routes (default: index.html)")
+	configPathArg     = flag.String("config-path", "", "config files path (default: './config')")
+	pluginConfigArg   = flag.String("plugin-config-path", "", "plugin config file path (optional)")
+	configMapArg      = flag.String("config-configmap", "", "<namespace>/<name> of a ConfigMap to read and watch the plugin config from, instead of -plugin-config-path (optional)")
+	faultInjectionArg = flag.Bool("fault-injection", false, "inject the faults defined in the plugin config, for testing only (default: false)")
+	shutdownArg       = flag.Duration("shutdown-timeout", 0, "time to wait for in-flight requests on SIGTERM, lower than the pod termination grace period (default: 25s)")
+	listenRetryArg    = flag.Duration("listen-retry-timeout", 0, "time to retry binding the port while it is in use, like during a container restart (default: 0, fail at once)")
+	readTimeoutArg    = flag.Duration("read-timeout", 0, "maximum duration to read a request, including its body (default: 30s)")
+	readHeaderArg     = flag.Duration("read-header-timeout", 0, "maximum duration to read the headers of a request (default: 10s)")
+	writeTimeoutArg   = flag.Duration("write-timeout", 0, "maximum duration of the responses other than the streams, probes and proxied queries (default: 30s)")
+	idleTimeoutArg    = flag.Duration("idle-timeout", 0, "maximum duration of an idle keep-alive connection (default: 2m)")
+	disableHTTP2Arg   = flag.Bool("disable-http2", false, "serve HTTP/1.1 only, for the proxies breaking with HTTP/2 (default: false)")
+	h2StreamsArg      = flag.Int("http2-max-streams", 0, "maximum number of concurrent streams of an HTTP/2 connection (default: 250)")
+	maxHeaderBytesArg = flag.Int("max-header-bytes", 0, "maximum size of the request headers in bytes (default: 1048576)")
+	maxBodySizeArg    = flag.Int("max-request-body-size", 0, "maximum size of the request bodies in bytes, -1 for unlimited (default: 1048576)")
+	bodyLimitsArg     = flag.String("request-body-limits", "", "request body limits of path prefixes, comma separated <prefix>=<bytes> entries (optional)")
+	middlewaresArg    = flag.String("middlewares", "", "middlewares wrapping the routes, the outermost first, comma separated (default: the built-in and registered ones)")
+	disableMwArg      = flag.String("disable-middlewares", "", "middlewares removed from -middlewares, comma separated (optional)")
+	noKeepAlivesArg   = flag.Bool("disable-keep-alives", false, "close the connections after each response (default: false)")
+	devArg            = flag.Bool("dev", false, "disable caching and watch the static path for changes, for frontend development only (default: false)")
+	devServerArg      = flag.String("dev-server-url", "", "webpack dev server URL the missing static files are proxied to in dev mode (optional)")
+	standaloneArg     = flag.Bool("standalone", false, "serve the frontend outside of the console with a token login form, requires -authentication (default: false)")
+	authenticationArg = flag.Bool("authentication", false, "require a bearer token validated with the TokenReview API on the config and proxy routes (default: false)")
+	authAllArg        = flag.Bool("authenticate-all", false, "require a bearer token on every path except -public-paths, requires -authentication (default: false)")
+	publicPathsArg    = flag.String("public-paths", "", "path.Match patterns served without a token with -authenticate-all, comma separated (default: /health,/health/*,/healthz,/readyz,/metrics)")
+	metricsTokenArg   = flag.String("metrics-token-file", "", "file holding the bearer token required on /metrics (default: disabled)")
+	logFormatArg      = flag.String("log-format", "", "log output format: text or json (default: text)")
+	validateConfigArg = flag.Bool("validate-config", false, "validate the plugin config file and exit with the result (default: false)")
+	tracingArg        = flag.String("tracing-endpoint", "", "OTLP/HTTP collector URL to export traces to (default: tracing disabled)")
+	auditArg          = flag.Bool("audit", false, "record the log queries of the users in an audit trail (default: false)")
+	auditLogPathArg   = flag.String("audit-log-path", "", "file the audit records are appended to in JSON, - for the standard output (default: with the plugin logs)")
+	auditRedactionArg = flag.String("audit-redaction", "", "redaction of the audited queries: none, filters or full (default: none)")
+	auditWebhookArg   = flag.String("audit-webhook-url", "", "URL the audit records are mirrored to in JSON arrays (default: none)")
+	log               = logrus.WithField("module", "main")
 )
 
 func main() {
 	flag.Parse()
 
+	logFormat := mergeEnvValue("LOGGING_VIEW_PLUGIN_LOG_FORMAT", *logFormatArg, server.LogFormatText)
+	switch logFormat {
+	case server.LogFormatJSON:
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	case server.LogFormatText:
+	default:
+		log.Fatalf("invalid log format %q, expected text or json", logFormat)
+	}
+
+	buildInfo := version.Get()
+	log.Infof("logging-view-plugin %s, commit %s, built %s with %s", buildInfo.Version, buildInfo.GitCommit, buildInfo.BuildDate, buildInfo.GoVersion)
+
 	port := mergeEnvValueInt("PORT", *portArg, 9002)
+	address := mergeEnvValue("LOGGING_VIEW_PLUGIN_ADDRESS", *addressArg, "")
+	ipFamily := mergeEnvValue("LOGGING_VIEW_PLUGIN_IP_FAMILY", *ipFamilyArg, "")
+	listenAddress := mergeEnvValue("LOGGING_VIEW_PLUGIN_LISTEN_ADDRESS", *listenAddressArg, "")
+	listenSocket := mergeEnvValue("LOGGING_VIEW_PLUGIN_LISTEN_SOCKET", *listenSocketArg, "")
 	cert := mergeEnvValue("CERT_FILE_PATH", *certArg, "")
 	key := mergeEnvValue("PRIVATE_KEY_FILE_PATH", *keyArg, "")
+	certSecret := mergeEnvValue("CERT_SECRET", *certSecretArg, "")
+	tlsMinVersion := mergeEnvValue("LOGGING_VIEW_PLUGIN_TLS_MIN_VERSION", *tlsMinVersionArg, "")
+	tlsMaxVersion := mergeEnvValue("LOGGING_VIEW_PLUGIN_TLS_MAX_VERSION", *tlsMaxVersionArg, "")
+	tlsCipherSuites := mergeEnvValue("LOGGING_VIEW_PLUGIN_TLS_CIPHER_SUITES", *tlsCiphersArg, "")
+	clientCAFile := mergeEnvValue("LOGGING_VIEW_PLUGIN_CLIENT_CA_FILE", *clientCAFileArg, "")
+	httpRedirectPort := mergeEnvValueInt("LOGGING_VIEW_PLUGIN_HTTP_REDIRECT_PORT", *redirectPortArg, 0)
+	internalPort := mergeEnvValueInt("LOGGING_VIEW_PLUGIN_INTERNAL_PORT", *internalPortArg, 0)
+	certExpiryWarning := mergeEnvValueDuration("LOGGING_VIEW_PLUGIN_CERT_EXPIRY_WARNING", *certExpiryArg, 720*time.Hour)
+	sniCerts := mergeEnvValue("SNI_CERTIFICATES", *sniCertsArg, "")
 	features := mergeEnvValue("LOGGING_VIEW_PLUGIN_FEATURES", *featuresArg, "")
-	staticPath := mergeEnvValue("LOGGING_VIEW_PLUGIN_STATIC_PATH", *staticPathArg, "./web/dist")
+	// the frontend embedded in the binary is served unless a static path is set
+	defaultStaticPath := "./web/dist"
+	if web.Dist != nil {
+		defaultStaticPath = ""
+	}
+	staticPath := mergeEnvValue("LOGGING_VIEW_PLUGIN_STATIC_PATH", *staticPathArg, defaultStaticPath)
+	staticRoots := mergeEnvValue("LOGGING_VIEW_PLUGIN_STATIC_ROOTS", *staticRootsArg, "")
+	spaFallback := mergeEnvValue("LOGGING_VIEW_PLUGIN_SPA_FALLBACK", *spaFallbackArg, "")
+	spaFallbackFile := mergeEnvValue("LOGGING_VIEW_PLUGIN_SPA_FALLBACK_FILE", *spaFileArg, "index.html")
 	configPath := mergeEnvValue("LOGGING_VIEW_PLUGIN_CONFIG_PATH", *configPathArg, "./config")
+	pluginConfigPath := mergeEnvValue("LOGGING_VIEW_PLUGIN_CONFIG_FILE", *pluginConfigArg, "")
+	pluginConfigMap := mergeEnvValue("LOGGING_VIEW_PLUGIN_CONFIG_CONFIGMAP", *configMapArg, "")
+
+	if *validateConfigArg {
+		os.Exit(validatePluginConfig(pluginConfigPath))
+	}
+	faultInjection := mergeEnvValueBool("LOGGING_VIEW_PLUGIN_FAULT_INJECTION", *faultInjectionArg)
+	authentication := mergeEnvValueBool("LOGGING_VIEW_PLUGIN_AUTHENTICATION", *authenticationArg)
+	authenticateAll := mergeEnvValueBool("LOGGING_VIEW_PLUGIN_AUTHENTICATE_ALL", *authAllArg)
+	publicPaths := mergeEnvValue("LOGGING_VIEW_PLUGIN_PUBLIC_PATHS", *publicPathsArg, strings.Join(server.DefaultPublicPaths, ","))
+	metricsTokenFile := mergeEnvValue("LOGGING_VIEW_PLUGIN_METRICS_TOKEN_FILE", *metricsTokenArg, "")
+	standalone := mergeEnvValueBool("LOGGING_VIEW_PLUGIN_STANDALONE", *standaloneArg)
+	dev := mergeEnvValueBool("LOGGING_VIEW_PLUGIN_DEV", *devArg)
+	devServerURL := mergeEnvValue("LOGGING_VIEW_PLUGIN_DEV_SERVER_URL", *devServerArg, "")
+	tracingEndpoint := mergeEnvValue("OTEL_EXPORTER_OTLP_ENDPOINT", *tracingArg, "")
+	audit := mergeEnvValueBool("LOGGING_VIEW_PLUGIN_AUDIT", *auditArg)
+	auditLogPath := mergeEnvValue("LOGGING_VIEW_PLUGIN_AUDIT_LOG_PATH", *auditLogPathArg, "")
+	auditRedaction := mergeEnvValue("LOGGING_VIEW_PLUGIN_AUDIT_REDACTION", *auditRedactionArg, server.AuditRedactionNone)
+	auditWebhookURL := mergeEnvValue("LOGGING_VIEW_PLUGIN_AUDIT_WEBHOOK_URL", *auditWebhookArg, "")
+	shutdownTimeout := mergeEnvValueDuration("LOGGING_VIEW_PLUGIN_SHUTDOWN_TIMEOUT", *shutdownArg, 25*time.Second)
+	listenRetryTimeout := mergeEnvValueDuration("LOGGING_VIEW_PLUGIN_LISTEN_RETRY_TIMEOUT", *listenRetryArg, 0)
+	readTimeout := mergeEnvValueDuration("LOGGING_VIEW_PLUGIN_READ_TIMEOUT", *readTimeoutArg, 30*time.Second)
+	readHeaderTimeout := mergeEnvValueDuration("LOGGING_VIEW_PLUGIN_READ_HEADER_TIMEOUT", *readHeaderArg, 10*time.Second)
+	writeTimeout := mergeEnvValueDuration("LOGGING_VIEW_PLUGIN_WRITE_TIMEOUT", *writeTimeoutArg, 30*time.Second)
+	idleTimeout := mergeEnvValueDuration("LOGGING_VIEW_PLUGIN_IDLE_TIMEOUT", *idleTimeoutArg, 2*time.Minute)
+	disableHTTP2 := mergeEnvValueBool("LOGGING_VIEW_PLUGIN_DISABLE_HTTP2", *disableHTTP2Arg)
+	http2MaxStreams := mergeEnvValueInt("LOGGING_VIEW_PLUGIN_HTTP2_MAX_STREAMS", *h2StreamsArg, 0)
+	maxHeaderBytes := mergeEnvValueInt("LOGGING_VIEW_PLUGIN_MAX_HEADER_BYTES", *maxHeaderBytesArg, 0)
+	maxRequestBodySize := mergeEnvValueInt("LOGGING_VIEW_PLUGIN_MAX_REQUEST_BODY_SIZE", *maxBodySizeArg, 1<<20)
+	requestBodyLimits := mergeEnvValue("LOGGING_VIEW_PLUGIN_REQUEST_BODY_LIMITS", *bodyLimitsArg, "")
+	middlewares := mergeEnvValue("LOGGING_VIEW_PLUGIN_MIDDLEWARES", *middlewaresArg, "")
+	disabledMiddlewares := mergeEnvValue("LOGGING_VIEW_PLUGIN_DISABLE_MIDDLEWARES", *disableMwArg, "")
+	disableKeepAlives := mergeEnvValueBool("LOGGING_VIEW_PLUGIN_DISABLE_KEEP_ALIVES", *noKeepAlivesArg)
+
+	if cert == "" && key == "" && certSecret == "" {
+		if detectedCert, detectedKey, found := server.DetectServingCertificate(server.ServiceCADirs...); found {
+			log.Infof("found serving certificate %s, enabling TLS", detectedCert)
+			cert, key = detectedCert, detectedKey
+		} else {
+			log.Info("no serving certificate configured or found, TLS disabled")
+		}
+	}
 
 	featuresList := strings.Fields(strings.Join(strings.Split(strings.ToLower(features), ","), " "))
 
@@ -39,14 +170,185 @@ func main() {
 
 	log.Infof("enabled features: %+q\n", featuresList)
 
-	server.Start(&server.Config{
-		Port:           port,
-		CertFile:       cert,
-		PrivateKeyFile: key,
-		Features:       featuresSet,
-		StaticPath:     staticPath,
-		ConfigPath:     configPath,
+	staticRootsList, err := server.ParseStaticRoots(staticRoots)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse static roots")
+	}
+
+	spaFallbackPrefixes, err := server.ParseSPAFallbackPrefixes(spaFallback)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse SPA fallback prefixes")
+	}
+
+	requestBodyLimitsList, err := server.ParseRequestBodyLimits(requestBodyLimits)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse request body limits")
+	}
+
+	middlewaresList, err := server.ParseMiddlewares(middlewares)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse middlewares")
+	}
+
+	disabledMiddlewaresList, err := server.ParseMiddlewares(disabledMiddlewares)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse disabled middlewares")
+	}
+
+	publicPathsList, err := server.ParsePublicPaths(publicPaths)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse public paths")
+	}
+
+	if listenSocket != "" && (*portArg != 0 || os.Getenv("PORT") != "" || listenAddress != "" || address != "") {
+		log.Fatal("-listen-socket cannot be used with -port, -address or -listen-address")
+	}
+
+	if listenAddress != "" {
+		address, port, err = server.ParseListenAddress(listenAddress)
+		if err != nil {
+			log.WithError(err).Fatal("cannot parse listen address")
+		}
+	}
+
+	tlsMinVersionID, err := server.ParseTLSVersion(tlsMinVersion)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse minimum TLS version")
+	}
+
+	tlsMaxVersionID, err := server.ParseTLSVersion(tlsMaxVersion)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse maximum TLS version")
+	}
+
+	tlsCipherSuiteIDs, err := server.ParseCipherSuites(tlsCipherSuites)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse TLS cipher suites")
+	}
+
+	if http2MaxStreams < 0 || http2MaxStreams > math.MaxUint32 {
+		log.Fatalf("invalid HTTP/2 max concurrent streams %d", http2MaxStreams)
+	}
+
+	sniCertificates, err := server.ParseSNICertificates(sniCerts)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse SNI certificates")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	srv, err := server.New(&server.Config{
+		Port:                  port,
+		Address:               address,
+		IPFamily:              ipFamily,
+		CertFile:              cert,
+		PrivateKeyFile:        key,
+		CertSecret:            certSecret,
+		SNICertificates:       sniCertificates,
+		CertExpiryWarning:     certExpiryWarning,
+		Features:              featuresSet,
+		StaticPath:            staticPath,
+		StaticFS:              web.Dist,
+		StaticRoots:           staticRootsList,
+		ConfigPath:            configPath,
+		PluginConfigPath:      pluginConfigPath,
+		PluginConfigMap:       pluginConfigMap,
+		FaultInjection:        faultInjection,
+		ShutdownTimeout:       shutdownTimeout,
+		ReadTimeout:           readTimeout,
+		ReadHeaderTimeout:     readHeaderTimeout,
+		WriteTimeout:          writeTimeout,
+		IdleTimeout:           idleTimeout,
+		LogFormat:             logFormat,
+		AuthenticationEnabled: authentication,
+		AuthenticateAll:       authenticateAll,
+		PublicPaths:           publicPathsList,
+		MetricsTokenFile:      metricsTokenFile,
+		InternalPort:          internalPort,
+		TracingEndpoint:       tracingEndpoint,
+		AuditEnabled:          audit,
+		AuditLogPath:          auditLogPath,
+		AuditRedaction:        auditRedaction,
+		AuditWebhookURL:       auditWebhookURL,
+		SPAFallbackPrefixes:   spaFallbackPrefixes,
+		SPAFallbackFile:       spaFallbackFile,
+		Standalone:            standalone,
+		Dev:                   dev,
+		DevServerURL:          devServerURL,
+		ListenSocket:          listenSocket,
+		TLSMinVersion:         tlsMinVersionID,
+		TLSMaxVersion:         tlsMaxVersionID,
+		TLSCipherSuites:       tlsCipherSuiteIDs,
+		ClientCAFile:          clientCAFile,
+		HTTPRedirectPort:      httpRedirectPort,
+		HTTP2Disabled:         disableHTTP2,
+		HTTP2MaxStreams:       uint32(http2MaxStreams),
+		MaxHeaderBytes:        maxHeaderBytes,
+		KeepAlivesDisabled:    disableKeepAlives,
+		MaxRequestBodySize:    int64(maxRequestBodySize),
+		RequestBodyLimits:     requestBodyLimitsList,
+		ListenRetryTimeout:    listenRetryTimeout,
+		Middlewares:           middlewaresList,
+		DisabledMiddlewares:   disabledMiddlewaresList,
 	})
+	if err != nil {
+		logValidationErrors(err)
+		log.WithError(err).Fatal("cannot start server")
+	}
+
+	if err := srv.Run(ctx); err != nil {
+		var listenErr *server.ListenError
+		if errors.As(err, &listenErr) {
+			log.WithError(listenErr.Err).WithField("attempts", listenErr.Attempts).Fatalf("cannot listen on %s", listenErr.Addr)
+		}
+		log.WithError(err).Fatal("server stopped")
+	}
+
+	log.Info("server stopped")
+}
+
+// validatePluginConfig prints the problems of the plugin config file and
+// returns the exit code of the -validate-config mode
+func validatePluginConfig(filePath string) int {
+	if filePath == "" {
+		fmt.Fprintln(os.Stderr, "no plugin config file, set -plugin-config-path")
+		return 2
+	}
+
+	err := server.ValidatePluginConfigFile(filePath)
+	if err == nil {
+		fmt.Printf("%s is valid\n", filePath)
+		return 0
+	}
+
+	var validationErrs server.ConfigValidationErrors
+	if !errors.As(err, &validationErrs) {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%s is invalid, %d problems found:\n", filePath, len(validationErrs))
+	for _, validationErr := range validationErrs {
+		if validationErr.Field == "" {
+			fmt.Fprintf(os.Stderr, "  - %s\n", validationErr.Message)
+		} else {
+			fmt.Fprintf(os.Stderr, "  - %s: %s\n", validationErr.Field, validationErr.Message)
+		}
+	}
+	return 1
+}
+
+// logValidationErrors logs every problem of an invalid plugin config on its
+// own line before the startup failure
+func logValidationErrors(err error) {
+	var validationErrs server.ConfigValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return
+	}
+	for _, validationErr := range validationErrs {
+		log.WithField("field", validationErr.Field).Error(validationErr.Message)
+	}
 }
 
 func mergeEnvValue(key string, arg string, defaultValue string) string {
@@ -71,9 +373,32 @@ func mergeEnvValueInt(key string, arg int, defaultValue int) int {
 	envValue := os.Getenv(key)
 
 	num, err := strconv.Atoi(envValue)
-	if err != nil && num != 0 {
+	if err == nil && num != 0 {
 		return num
 	}
 
 	return defaultValue
 }
+
+func mergeEnvValueBool(key string, arg bool) bool {
+	if arg {
+		return arg
+	}
+
+	envValue, err := strconv.ParseBool(os.Getenv(key))
+	return err == nil && envValue
+}
+
+func mergeEnvValueDuration(key string, arg time.Duration, defaultValue time.Duration) time.Duration {
+	if arg != 0 {
+		return arg
+	}
+
+	envValue := os.Getenv(key)
+
+	if d, err := time.ParseDuration(envValue); err == nil && d > 0 {
+		return d
+	}
+
+	return defaultValue
+}
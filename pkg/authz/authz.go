@@ -0,0 +1,117 @@
+package authz
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("module", "authz")
+
+const (
+	// allowedTTL is the time an allowed access is cached
+	allowedTTL = time.Minute
+	// deniedTTL is the time a denied access is cached
+	deniedTTL = 10 * time.Second
+	// maxCachedReviews bounds the access review cache
+	maxCachedReviews = 5000
+)
+
+// Reviewer performs SubjectAccessReviews, implemented by kube.Client
+type Reviewer interface {
+	ReviewAccess(ctx context.Context, spec kube.SubjectAccessReviewSpec) (*kube.SubjectAccessReviewStatus, error)
+}
+
+type reviewResult struct {
+	allowed bool
+	expires time.Time
+}
+
+// Authorizer checks that users are allowed an access to a resource in a
+// namespace, the review results are cached per user and namespace
+type Authorizer struct {
+	reviewer   Reviewer
+	attributes kube.ResourceAttributes
+	mu         sync.Mutex
+	cache      map[string]reviewResult
+}
+
+// New builds an Authorizer checking the access described by attributes, the
+// namespace of the attributes is ignored
+func New(reviewer Reviewer, attributes kube.ResourceAttributes) *Authorizer {
+	return &Authorizer{
+		reviewer:   reviewer,
+		attributes: attributes,
+		cache:      map[string]reviewResult{},
+	}
+}
+
+// Authorize returns true when user is allowed the access in namespace
+func (a *Authorizer) Authorize(ctx context.Context, user *kube.UserInfo, namespace string) (bool, error) {
+	return a.AuthorizeName(ctx, user, namespace, "")
+}
+
+// AuthorizeName returns true when user is allowed the access to the resource
+// named name in namespace, the cluster scoped resources have no namespace
+func (a *Authorizer) AuthorizeName(ctx context.Context, user *kube.UserInfo, namespace string, name string) (bool, error) {
+	key := cacheKey(user, namespace, name)
+
+	a.mu.Lock()
+	result, found := a.cache[key]
+	a.mu.Unlock()
+	if found && time.Now().Before(result.expires) {
+		return result.allowed, nil
+	}
+
+	attributes := a.attributes
+	attributes.Namespace = namespace
+	attributes.Name = name
+
+	status, err := a.reviewer.ReviewAccess(ctx, kube.SubjectAccessReviewSpec{
+		ResourceAttributes: &attributes,
+		User:               user.Username,
+		UID:                user.UID,
+		Groups:             user.Groups,
+		Extra:              user.Extra,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if status.EvaluationError != "" {
+		log.Warnf("access review of %s in %s: %s", user.Username, namespace, status.EvaluationError)
+	}
+
+	ttl := deniedTTL
+	if status.Allowed {
+		ttl = allowedTTL
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.cache) >= maxCachedReviews {
+		a.evictExpired()
+	}
+	if len(a.cache) < maxCachedReviews {
+		a.cache[key] = reviewResult{allowed: status.Allowed, expires: time.Now().Add(ttl)}
+	}
+
+	return status.Allowed, nil
+}
+
+func (a *Authorizer) evictExpired() {
+	now := time.Now()
+	for key, result := range a.cache {
+		if now.After(result.expires) {
+			delete(a.cache, key)
+		}
+	}
+}
+
+func cacheKey(user *kube.UserInfo, namespace string, name string) string {
+	return strings.Join([]string{user.Username, user.UID, strings.Join(user.Groups, ","), namespace, name}, "\x00")
+}
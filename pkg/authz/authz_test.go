@@ -0,0 +1,56 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReviewer struct {
+	reviews []kube.SubjectAccessReviewSpec
+}
+
+func (f *fakeReviewer) ReviewAccess(_ context.Context, spec kube.SubjectAccessReviewSpec) (*kube.SubjectAccessReviewStatus, error) {
+	f.reviews = append(f.reviews, spec)
+	return &kube.SubjectAccessReviewStatus{Allowed: spec.ResourceAttributes.Namespace == "my-app"}, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	reviewer := &fakeReviewer{}
+	authorizer := New(reviewer, kube.ResourceAttributes{Verb: "get", Resource: "pods", Subresource: "log"})
+	user := &kube.UserInfo{Username: "developer", Groups: []string{"system:authenticated"}}
+
+	allowed, err := authorizer.Authorize(context.Background(), user, "my-app")
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	allowed, err = authorizer.Authorize(context.Background(), user, "other-app")
+	require.NoError(t, err)
+	require.False(t, allowed)
+
+	// cached results
+	allowed, err = authorizer.Authorize(context.Background(), user, "my-app")
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	require.Len(t, reviewer.reviews, 2)
+	require.Equal(t, "developer", reviewer.reviews[0].User)
+	require.Equal(t, &kube.ResourceAttributes{Namespace: "my-app", Verb: "get", Resource: "pods", Subresource: "log"}, reviewer.reviews[0].ResourceAttributes)
+}
+
+func TestAuthorizeName(t *testing.T) {
+	reviewer := &fakeReviewer{}
+	authorizer := New(reviewer, kube.ResourceAttributes{Verb: "impersonate", Resource: "users"})
+	user := &kube.UserInfo{Username: "admin"}
+
+	_, err := authorizer.AuthorizeName(context.Background(), user, "", "developer")
+	require.NoError(t, err)
+	_, err = authorizer.AuthorizeName(context.Background(), user, "", "tester")
+	require.NoError(t, err)
+
+	require.Len(t, reviewer.reviews, 2)
+	require.Equal(t, &kube.ResourceAttributes{Verb: "impersonate", Resource: "users", Name: "developer"}, reviewer.reviews[0].ResourceAttributes)
+	require.Equal(t, "tester", reviewer.reviews[1].ResourceAttributes.Name)
+}
@@ -0,0 +1,162 @@
+// Package datasource defines the log store backends serving the Loki API of
+// the plugin. The backends are registered by type so that new log stores can
+// be added without changing the routes of the server
+package datasource
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+var (
+	// labelsEndpointRegexp matches the endpoints served by Labels
+	labelsEndpointRegexp = regexp.MustCompile(`^/loki/api/v1/(labels|label/[^/]+/values|series)$`)
+
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Datasource is a log store backend. The Query, QueryRange and Labels requests
+// have the /<tenant>/loki/api/v1/<endpoint> path and the Loki query
+// parameters, their responses are in the Loki format. The datasources also
+// implementing http.Handler serve the other Loki endpoints, like the index
+// stats
+type Datasource interface {
+	// Query serves the instant queries of /loki/api/v1/query
+	Query(w http.ResponseWriter, r *http.Request)
+	// QueryRange serves the log and metric queries of
+	// /loki/api/v1/query_range
+	QueryRange(w http.ResponseWriter, r *http.Request)
+	// Labels serves the label names of /loki/api/v1/labels, the label values
+	// of /loki/api/v1/label/<name>/values and the streams of
+	// /loki/api/v1/series
+	Labels(w http.ResponseWriter, r *http.Request)
+	// Tail serves the live tail WebSocket of the requests with the /<tenant>
+	// path, or the server-sent events of the requests marked with
+	// proxy.WithEventStream
+	Tail(w http.ResponseWriter, r *http.Request)
+}
+
+// Config is the configuration of a datasource passed to its factory
+type Config struct {
+	// Name identifies the datasource in the routes
+	Name string
+	// Proxy holds the upstream settings, like the URL, the transport and the
+	// timeouts
+	Proxy proxy.Config
+	// MetadataCache caches the Labels responses when enabled
+	MetadataCache proxy.CacheConfig
+	// Tail bounds the live tail streams
+	Tail proxy.TailLimits
+	// Options decodes the backend specific options of the datasource in v
+	Options func(v interface{}) error
+}
+
+// Factory builds the datasources of a type
+type Factory func(cfg Config) (Datasource, error)
+
+// Register makes the datasources of type typ available, it panics when the
+// type is already registered
+func Register(typ string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, found := factories[typ]; found {
+		panic(fmt.Sprintf("datasource type %s is already registered", typ))
+	}
+	factories[typ] = factory
+}
+
+// Registered returns true when typ is a registered type
+func Registered(typ string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	_, found := factories[typ]
+	return found
+}
+
+// Types returns the registered types in order
+func Types() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	types := make([]string, 0, len(factories))
+	for typ := range factories {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New builds a datasource of type typ
+func New(typ string, cfg Config) (Datasource, error) {
+	mu.RLock()
+	factory, found := factories[typ]
+	mu.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("unknown datasource type %q", typ)
+	}
+	if cfg.Options == nil {
+		cfg.Options = func(interface{}) error { return nil }
+	}
+	return factory(cfg)
+}
+
+// QueryHandler routes the /<tenant>/loki/api/v1/<endpoint> requests to the
+// methods of ds, the other endpoints are served by ds when it implements
+// http.Handler
+func QueryHandler(ds Datasource, errorHandler func(http.ResponseWriter, *http.Request, *proxy.Error)) http.Handler {
+	other, _ := ds.(http.Handler)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		endpoint = "/" + endpoint
+
+		switch {
+		case endpoint == "/loki/api/v1/query":
+			ds.Query(w, r)
+		case endpoint == "/loki/api/v1/query_range":
+			ds.QueryRange(w, r)
+		case labelsEndpointRegexp.MatchString(endpoint):
+			ds.Labels(w, r)
+		case other != nil:
+			other.ServeHTTP(w, r)
+		default:
+			errorHandler(w, r, &proxy.Error{Status: http.StatusNotFound, Code: "NotFound", Message: fmt.Sprintf("unsupported endpoint %s", endpoint)})
+		}
+	})
+}
+
+// MetadataHandler routes the label and series requests to the Labels method
+// of ds
+func MetadataHandler(ds Datasource, errorHandler func(http.ResponseWriter, *http.Request, *proxy.Error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if !labelsEndpointRegexp.MatchString("/" + endpoint) {
+			errorHandler(w, r, &proxy.Error{Status: http.StatusNotFound, Code: "NotFound", Message: fmt.Sprintf("unsupported metadata endpoint /%s", endpoint)})
+			return
+		}
+		ds.Labels(w, r)
+	})
+}
+
+// TailHandler serves the live tail streams of ds
+func TailHandler(ds Datasource) http.Handler {
+	return http.HandlerFunc(ds.Tail)
+}
+
+// EventStreamTailHandler serves the live tail streams of ds as server-sent
+// events
+func EventStreamTailHandler(ds Datasource) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ds.Tail(w, proxy.WithEventStream(r))
+	})
+}
@@ -0,0 +1,96 @@
+package datasource
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingDatasource writes the name of the method serving each request
+type recordingDatasource struct{}
+
+func (recordingDatasource) Query(w http.ResponseWriter, r *http.Request) { w.Write([]byte("query")) }
+
+func (recordingDatasource) QueryRange(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("query_range"))
+}
+
+func (recordingDatasource) Labels(w http.ResponseWriter, r *http.Request) { w.Write([]byte("labels")) }
+
+func (recordingDatasource) Tail(w http.ResponseWriter, r *http.Request) { w.Write([]byte("tail")) }
+
+type handlerDatasource struct{ recordingDatasource }
+
+func (handlerDatasource) ServeHTTP(w http.ResponseWriter, r *http.Request) { w.Write([]byte("other")) }
+
+func writeTestError(w http.ResponseWriter, r *http.Request, err *proxy.Error) {
+	w.WriteHeader(err.Status)
+	w.Write([]byte(err.Code))
+}
+
+func TestRegistry(t *testing.T) {
+	require.True(t, Registered(TypeLoki))
+	require.False(t, Registered("test"))
+
+	Register("test", func(cfg Config) (Datasource, error) {
+		var options struct{}
+		if err := cfg.Options(&options); err != nil {
+			return nil, err
+		}
+		return recordingDatasource{}, nil
+	})
+	require.True(t, Registered("test"))
+	require.Equal(t, []string{TypeLoki, "test"}, Types())
+
+	ds, err := New("test", Config{Name: "test"})
+	require.NoError(t, err)
+	require.Equal(t, recordingDatasource{}, ds)
+
+	require.Panics(t, func() { Register("test", nil) })
+
+	_, err = New("unknown", Config{})
+	require.EqualError(t, err, `unknown datasource type "unknown"`)
+}
+
+func TestQueryHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		ds           Datasource
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "query", ds: recordingDatasource{}, path: "/application/loki/api/v1/query", expectedCode: http.StatusOK, expectedBody: "query"},
+		{name: "query range", ds: recordingDatasource{}, path: "/application/loki/api/v1/query_range", expectedCode: http.StatusOK, expectedBody: "query_range"},
+		{name: "labels", ds: recordingDatasource{}, path: "/application/loki/api/v1/labels", expectedCode: http.StatusOK, expectedBody: "labels"},
+		{name: "label values", ds: recordingDatasource{}, path: "/application/loki/api/v1/label/namespace/values", expectedCode: http.StatusOK, expectedBody: "labels"},
+		{name: "series", ds: recordingDatasource{}, path: "/application/loki/api/v1/series", expectedCode: http.StatusOK, expectedBody: "labels"},
+		{name: "other endpoint", ds: handlerDatasource{}, path: "/application/loki/api/v1/index/stats", expectedCode: http.StatusOK, expectedBody: "other"},
+		{name: "unsupported endpoint", ds: recordingDatasource{}, path: "/application/loki/api/v1/index/stats", expectedCode: http.StatusNotFound, expectedBody: "NotFound"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			QueryHandler(tt.ds, writeTestError).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			require.Equal(t, tt.expectedCode, w.Code)
+			require.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestMetadataHandler(t *testing.T) {
+	handler := MetadataHandler(recordingDatasource{}, writeTestError)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/series", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "labels", w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
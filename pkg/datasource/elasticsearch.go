@@ -0,0 +1,39 @@
+package datasource
+
+import (
+	"net/http"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// Elasticsearch translates the Loki log queries to the searches of an
+// Elasticsearch log store, the instant and metric queries are not supported
+type Elasticsearch struct {
+	es   *proxy.Elasticsearch
+	tail *proxy.ElasticsearchTail
+}
+
+// NewElasticsearch builds an Elasticsearch datasource searching the indices
+// and the fields of mapping
+func NewElasticsearch(cfg Config, mapping proxy.ElasticsearchMapping) *Elasticsearch {
+	return &Elasticsearch{
+		es:   proxy.NewElasticsearch(cfg.Proxy, mapping),
+		tail: proxy.NewElasticsearchTail(cfg.Proxy, mapping, cfg.Tail),
+	}
+}
+
+func (e *Elasticsearch) Query(w http.ResponseWriter, r *http.Request) {
+	e.es.ServeHTTP(w, r)
+}
+
+func (e *Elasticsearch) QueryRange(w http.ResponseWriter, r *http.Request) {
+	e.es.ServeHTTP(w, r)
+}
+
+func (e *Elasticsearch) Labels(w http.ResponseWriter, r *http.Request) {
+	e.es.ServeHTTP(w, r)
+}
+
+func (e *Elasticsearch) Tail(w http.ResponseWriter, r *http.Request) {
+	e.tail.ServeHTTP(w, r)
+}
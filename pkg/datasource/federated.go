@@ -0,0 +1,42 @@
+package datasource
+
+import (
+	"net/http"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// Federated fans the queries out to the Loki datasources of several clusters
+// and merges their results, the live tail is not supported
+type Federated struct {
+	federation   *proxy.Federation
+	errorHandler func(w http.ResponseWriter, r *http.Request, err *proxy.Error)
+}
+
+// NewFederated builds a datasource federating the clusters of cfg
+func NewFederated(cfg proxy.FederationConfig) *Federated {
+	federation := proxy.NewFederation(cfg)
+	errorHandler := cfg.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = func(w http.ResponseWriter, r *http.Request, err *proxy.Error) {
+			http.Error(w, err.Message, err.Status)
+		}
+	}
+	return &Federated{federation: federation, errorHandler: errorHandler}
+}
+
+func (f *Federated) Query(w http.ResponseWriter, r *http.Request) {
+	f.federation.ServeHTTP(w, r)
+}
+
+func (f *Federated) QueryRange(w http.ResponseWriter, r *http.Request) {
+	f.federation.ServeHTTP(w, r)
+}
+
+func (f *Federated) Labels(w http.ResponseWriter, r *http.Request) {
+	f.federation.ServeHTTP(w, r)
+}
+
+func (f *Federated) Tail(w http.ResponseWriter, r *http.Request) {
+	f.errorHandler(w, r, &proxy.Error{Status: http.StatusNotImplemented, Code: "NotImplemented", Message: "the live tail is not supported by the federated datasources, tail the clusters separately"})
+}
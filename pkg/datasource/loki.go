@@ -0,0 +1,57 @@
+package datasource
+
+import (
+	"net/http"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// TypeLoki is the type of the Loki and LokiStack datasources
+const TypeLoki = "loki"
+
+func init() {
+	Register(TypeLoki, func(cfg Config) (Datasource, error) {
+		return NewLoki(cfg), nil
+	})
+}
+
+// Loki proxies the queries to Loki with the bearer token of the user, the
+// label and series queries have their own cache
+type Loki struct {
+	proxy    *proxy.Proxy
+	metadata *proxy.Proxy
+	tail     *proxy.Tail
+}
+
+// NewLoki builds a Loki datasource
+func NewLoki(cfg Config) *Loki {
+	metadataConfig := cfg.Proxy
+	metadataConfig.Cache = cfg.MetadataCache
+
+	return &Loki{
+		proxy:    proxy.New(cfg.Proxy),
+		metadata: proxy.NewMetadata(metadataConfig),
+		tail:     proxy.NewTail(cfg.Proxy, cfg.Tail),
+	}
+}
+
+func (l *Loki) Query(w http.ResponseWriter, r *http.Request) {
+	l.proxy.ServeHTTP(w, r)
+}
+
+func (l *Loki) QueryRange(w http.ResponseWriter, r *http.Request) {
+	l.proxy.ServeHTTP(w, r)
+}
+
+func (l *Loki) Labels(w http.ResponseWriter, r *http.Request) {
+	l.metadata.ServeHTTP(w, r)
+}
+
+func (l *Loki) Tail(w http.ResponseWriter, r *http.Request) {
+	l.tail.ServeHTTP(w, r)
+}
+
+// ServeHTTP proxies the other Loki endpoints, like the index stats and volumes
+func (l *Loki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.proxy.ServeHTTP(w, r)
+}
@@ -0,0 +1,52 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+)
+
+// UserInfo is the user identified by a token review
+type UserInfo struct {
+	Username string              `json:"username,omitempty"`
+	UID      string              `json:"uid,omitempty"`
+	Groups   []string            `json:"groups,omitempty"`
+	Extra    map[string][]string `json:"extra,omitempty"`
+}
+
+// TokenReviewSpec is the token sent for review
+type TokenReviewSpec struct {
+	Token     string   `json:"token"`
+	Audiences []string `json:"audiences,omitempty"`
+}
+
+// TokenReviewStatus is the result of a token review
+type TokenReviewStatus struct {
+	Authenticated bool     `json:"authenticated"`
+	User          UserInfo `json:"user,omitempty"`
+	Audiences     []string `json:"audiences,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// TokenReview is an authentication.k8s.io/v1 TokenReview
+type TokenReview struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Spec       TokenReviewSpec   `json:"spec"`
+	Status     TokenReviewStatus `json:"status,omitempty"`
+}
+
+// ReviewToken asks the API server to authenticate token
+func (c *Client) ReviewToken(ctx context.Context, token string, audiences []string) (*TokenReviewStatus, error) {
+	review := &TokenReview{
+		APIVersion: "authentication.k8s.io/v1",
+		Kind:       "TokenReview",
+		Spec:       TokenReviewSpec{Token: token, Audiences: audiences},
+	}
+
+	result := &TokenReview{}
+	if err := c.Do(ctx, http.MethodPost, "/apis/authentication.k8s.io/v1/tokenreviews", review, result); err != nil {
+		return nil, err
+	}
+
+	return &result.Status, nil
+}
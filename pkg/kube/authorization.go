@@ -0,0 +1,58 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+)
+
+// ResourceAttributes describes the access to a resource checked by a review
+type ResourceAttributes struct {
+	Namespace   string `json:"namespace,omitempty"`
+	Verb        string `json:"verb,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// SubjectAccessReviewSpec is the user and access sent for review
+type SubjectAccessReviewSpec struct {
+	ResourceAttributes *ResourceAttributes `json:"resourceAttributes,omitempty"`
+	User               string              `json:"user,omitempty"`
+	Groups             []string            `json:"groups,omitempty"`
+	Extra              map[string][]string `json:"extra,omitempty"`
+	UID                string              `json:"uid,omitempty"`
+}
+
+// SubjectAccessReviewStatus is the result of an access review
+type SubjectAccessReviewStatus struct {
+	Allowed         bool   `json:"allowed"`
+	Denied          bool   `json:"denied,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	EvaluationError string `json:"evaluationError,omitempty"`
+}
+
+// SubjectAccessReview is an authorization.k8s.io/v1 SubjectAccessReview
+type SubjectAccessReview struct {
+	APIVersion string                    `json:"apiVersion"`
+	Kind       string                    `json:"kind"`
+	Spec       SubjectAccessReviewSpec   `json:"spec"`
+	Status     SubjectAccessReviewStatus `json:"status,omitempty"`
+}
+
+// ReviewAccess asks the API server whether the user of spec is allowed the
+// access described by spec
+func (c *Client) ReviewAccess(ctx context.Context, spec SubjectAccessReviewSpec) (*SubjectAccessReviewStatus, error) {
+	review := &SubjectAccessReview{
+		APIVersion: "authorization.k8s.io/v1",
+		Kind:       "SubjectAccessReview",
+		Spec:       spec,
+	}
+
+	result := &SubjectAccessReview{}
+	if err := c.Do(ctx, http.MethodPost, "/apis/authorization.k8s.io/v1/subjectaccessreviews", review, result); err != nil {
+		return nil, err
+	}
+
+	return &result.Status, nil
+}
@@ -0,0 +1,215 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// ErrNotInCluster is returned when the in-cluster API server environment is
+// not available
+var ErrNotInCluster = errors.New("not running in a kubernetes cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
+
+// Client is a minimal Kubernetes API client authenticated with a bearer
+// token, the token file is read on every request to follow rotations
+type Client struct {
+	baseURL    string
+	tokenFile  string
+	httpClient *http.Client
+}
+
+// StatusError is returned when the API server replies with an error status
+type StatusError struct {
+	Code    int
+	Reason  string
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kubernetes API error %d %s: %s", e.Code, e.Reason, e.Message)
+}
+
+// IsNotFound returns true when err is a StatusError with a 404 code
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound
+}
+
+// IsConflict returns true when err is a StatusError with a 409 code, like
+// when an update is based on an outdated resource version
+func IsConflict(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusConflict
+}
+
+// IsGone returns true when err is a StatusError with a 410 code, like when a
+// watched resource version is too old
+func IsGone(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusGone
+}
+
+// InClusterNamespace returns the namespace of the pod service account
+func InClusterNamespace() (string, error) {
+	namespace, err := os.ReadFile(filepath.Join(serviceAccountPath, "namespace"))
+	if err != nil {
+		return "", fmt.Errorf("cannot read service account namespace: %w", err)
+	}
+	return strings.TrimSpace(string(namespace)), nil
+}
+
+// InClusterAPIServer returns the URL of the API server of the cluster and the
+// service account CA file verifying its certificate
+func InClusterAPIServer() (string, string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return "", "", ErrNotInCluster
+	}
+	return "https://" + net.JoinHostPort(host, port), filepath.Join(serviceAccountPath, "ca.crt"), nil
+}
+
+// NewInClusterClient builds a client using the pod service account
+func NewInClusterClient() (*Client, error) {
+	apiServerURL, caFile, err := InClusterAPIServer()
+	if err != nil {
+		return nil, err
+	}
+
+	caData, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read service account CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no certificates found in service account CA")
+	}
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	return NewClient(
+		apiServerURL,
+		ServiceAccountTokenFile,
+		&http.Client{Transport: transport, Timeout: 30 * time.Second},
+	), nil
+}
+
+// NewClient builds a client for the API server at baseURL, tokenFile is
+// optional
+func NewClient(baseURL string, tokenFile string, httpClient *http.Client) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		tokenFile:  tokenFile,
+		httpClient: httpClient,
+	}
+}
+
+// Get decodes the resource at path into out
+func (c *Client) Get(ctx context.Context, path string, out interface{}) error {
+	return c.Do(ctx, http.MethodGet, path, nil, out)
+}
+
+// Do sends a request with an optional JSON body and decodes the JSON response
+// into out when not nil
+func (c *Client) Do(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	resp, err := c.request(ctx, method, path, in, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) request(ctx context.Context, method string, path string, in interface{}, contentType string) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if contentType != "" {
+		req.Header.Set("Accept", contentType)
+	}
+
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, decodeStatusError(resp)
+	}
+
+	return resp, nil
+}
+
+// ResponseError returns the StatusError of an API server error response, like
+// the ones of the requests not sent with Client
+func ResponseError(resp *http.Response) error {
+	return decodeStatusError(resp)
+}
+
+func decodeStatusError(resp *http.Response) error {
+	statusErr := &StatusError{Code: resp.StatusCode, Reason: http.StatusText(resp.StatusCode)}
+
+	status := struct {
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	}{}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(data, &status); err == nil && status.Message != "" {
+		statusErr.Message = status.Message
+		if status.Reason != "" {
+			statusErr.Reason = status.Reason
+		}
+	} else {
+		statusErr.Message = strings.TrimSpace(string(data))
+	}
+
+	return statusErr
+}
@@ -0,0 +1,157 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSecret(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "kube-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	tokenFile := filepath.Join(tmpDir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("sa-token\n"), 0600))
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer sa-token", r.Header.Get("Authorization"))
+
+		switch r.URL.Path {
+		case "/api/v1/namespaces/openshift-logging/secrets/serving-cert":
+			w.Write([]byte(`{"metadata":{"name":"serving-cert","resourceVersion":"42"},"data":{"tls.crt":"Y2VydA=="}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","reason":"NotFound","message":"secrets \"missing\" not found"}`))
+		}
+	}))
+	defer apiServer.Close()
+
+	client := NewClient(apiServer.URL, tokenFile, apiServer.Client())
+
+	secret, err := client.GetSecret(context.Background(), "openshift-logging", "serving-cert")
+	require.NoError(t, err)
+	require.Equal(t, "42", secret.Metadata.ResourceVersion)
+	require.Equal(t, []byte("cert"), secret.Data["tls.crt"])
+
+	_, err = client.GetSecret(context.Background(), "openshift-logging", "missing")
+	require.True(t, IsNotFound(err))
+	require.EqualError(t, err, `kubernetes API error 404 NotFound: secrets "missing" not found`)
+}
+
+func TestParseNamespacedName(t *testing.T) {
+	namespace, name, err := ParseNamespacedName("openshift-logging/serving-cert")
+	require.NoError(t, err)
+	require.Equal(t, "openshift-logging", namespace)
+	require.Equal(t, "serving-cert", name)
+
+	for _, invalid := range []string{"", "serving-cert", "/serving-cert", "ns/", "ns/a/b"} {
+		_, _, err := ParseNamespacedName(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestReviewToken(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/apis/authentication.k8s.io/v1/tokenreviews", r.URL.Path)
+
+		review := TokenReview{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&review))
+		require.Equal(t, "TokenReview", review.Kind)
+
+		review.Status.Authenticated = review.Spec.Token == "valid"
+		if review.Status.Authenticated {
+			review.Status.User = UserInfo{Username: "developer", Groups: []string{"system:authenticated"}}
+		}
+		json.NewEncoder(w).Encode(review)
+	}))
+	defer apiServer.Close()
+
+	client := NewClient(apiServer.URL, "", apiServer.Client())
+
+	status, err := client.ReviewToken(context.Background(), "valid", nil)
+	require.NoError(t, err)
+	require.True(t, status.Authenticated)
+	require.Equal(t, "developer", status.User.Username)
+
+	status, err = client.ReviewToken(context.Background(), "invalid", nil)
+	require.NoError(t, err)
+	require.False(t, status.Authenticated)
+}
+
+func TestWatchConfigMap(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/namespaces/openshift-logging/configmaps", r.URL.Path)
+		require.Equal(t, "true", r.URL.Query().Get("watch"))
+		require.Equal(t, "metadata.name=plugin-config", r.URL.Query().Get("fieldSelector"))
+		require.Equal(t, "20", r.URL.Query().Get("timeoutSeconds"))
+
+		switch r.URL.Query().Get("resourceVersion") {
+		case "1":
+			w.Write([]byte(`{"type":"MODIFIED","object":{"metadata":{"name":"plugin-config","resourceVersion":"2"},"data":{"config.yaml":"logsLimit: 50"}}}
+{"type":"BOOKMARK","object":{"metadata":{"resourceVersion":"3"}}}
+`))
+		default:
+			w.Write([]byte(`{"type":"ERROR","object":{"kind":"Status","code":410,"reason":"Expired","message":"too old resource version"}}`))
+		}
+	}))
+	defer apiServer.Close()
+
+	client := NewClient(apiServer.URL, "", apiServer.Client())
+
+	events := []ConfigMapEvent{}
+	err := client.WatchConfigMap(context.Background(), "openshift-logging", "plugin-config", "1", 20*time.Second, func(event ConfigMapEvent) {
+		events = append(events, event)
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, EventModified, events[0].Type)
+	require.Equal(t, "logsLimit: 50", events[0].ConfigMap.Data["config.yaml"])
+	require.Equal(t, EventBookmark, events[1].Type)
+	require.Equal(t, "3", events[1].ConfigMap.Metadata.ResourceVersion)
+
+	err = client.WatchConfigMap(context.Background(), "openshift-logging", "plugin-config", "0", 20*time.Second, func(ConfigMapEvent) {})
+	require.True(t, IsGone(err))
+}
+
+func TestEvents(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/events", r.URL.Path)
+
+		if r.URL.Query().Get("watch") != "true" {
+			w.Write([]byte(`{"metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"api.1","namespace":"shop"},"involvedObject":{"kind":"Pod","namespace":"shop","name":"api"},"reason":"BackOff","type":"Warning","count":3,"lastTimestamp":"2024-05-01T10:00:00Z"}]}`))
+			return
+		}
+		require.Equal(t, "10", r.URL.Query().Get("resourceVersion"))
+		w.Write([]byte(`{"type":"ADDED","object":{"metadata":{"name":"api.2","namespace":"shop"},"involvedObject":{"kind":"Pod","namespace":"shop","name":"api"},"reason":"Pulled","eventTime":"2024-05-01T10:01:00Z"}}
+`))
+	}))
+	defer apiServer.Close()
+
+	client := NewClient(apiServer.URL, "", apiServer.Client())
+
+	list, err := client.ListEvents(context.Background(), "")
+	require.NoError(t, err)
+	require.Equal(t, "10", list.Metadata.ResourceVersion)
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "BackOff", list.Items[0].Reason)
+	require.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), list.Items[0].LastSeen())
+
+	events := []EventWatchEvent{}
+	err = client.WatchEvents(context.Background(), "", "10", 20*time.Second, func(event EventWatchEvent) {
+		events = append(events, event)
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, EventAdded, events[0].Type)
+	require.Equal(t, time.Date(2024, 5, 1, 10, 1, 0, 0, time.UTC), events[0].Event.LastSeen())
+	require.Equal(t, "/api/v1/namespaces/shop/events", eventsPath("shop", nil))
+}
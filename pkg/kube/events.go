@@ -0,0 +1,108 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ObjectReference is the object an event is about
+type ObjectReference struct {
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	UID       string `json:"uid,omitempty"`
+	FieldPath string `json:"fieldPath,omitempty"`
+}
+
+// EventSource is the component reporting an event
+type EventSource struct {
+	Component string `json:"component,omitempty"`
+	Host      string `json:"host,omitempty"`
+}
+
+// Event is a core/v1 Event
+type Event struct {
+	Metadata       ObjectMeta      `json:"metadata"`
+	InvolvedObject ObjectReference `json:"involvedObject"`
+	Reason         string          `json:"reason,omitempty"`
+	Message        string          `json:"message,omitempty"`
+	// Type is Normal or Warning
+	Type           string      `json:"type,omitempty"`
+	Count          int32       `json:"count,omitempty"`
+	FirstTimestamp time.Time   `json:"firstTimestamp,omitempty"`
+	LastTimestamp  time.Time   `json:"lastTimestamp,omitempty"`
+	EventTime      time.Time   `json:"eventTime,omitempty"`
+	Source         EventSource `json:"source,omitempty"`
+}
+
+// LastSeen returns the time the event last occurred, the events reported with
+// the events.k8s.io API only have an eventTime
+func (e *Event) LastSeen() time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime
+	}
+	return e.FirstTimestamp
+}
+
+// EventList is a list of core/v1 Events with the resource version to watch
+// their changes from
+type EventList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Items []Event `json:"items"`
+}
+
+// EventWatchEvent is a change of a watched event
+type EventWatchEvent struct {
+	Type  string
+	Event *Event
+}
+
+// ListEvents lists the events of namespace, of every namespace when empty
+func (c *Client) ListEvents(ctx context.Context, namespace string) (*EventList, error) {
+	list := &EventList{}
+	if err := c.Get(ctx, eventsPath(namespace, nil), list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// WatchEvents watches the events of namespace, of every namespace when
+// empty, from resourceVersion and calls onEvent with their changes until the
+// API server ends the watch after timeout, or ctx is done. An ERROR event is
+// returned as a StatusError, like the 410 Gone of an expired resource version
+func (c *Client) WatchEvents(ctx context.Context, namespace string, resourceVersion string, timeout time.Duration, onEvent func(EventWatchEvent)) error {
+	query := url.Values{
+		"watch":           {"true"},
+		"resourceVersion": {resourceVersion},
+		"timeoutSeconds":  {strconv.Itoa(int(timeout.Seconds()))},
+	}
+
+	return c.watch(ctx, eventsPath(namespace, query), func(eventType string, object json.RawMessage) error {
+		event := &Event{}
+		if err := json.Unmarshal(object, event); err != nil {
+			return err
+		}
+		onEvent(EventWatchEvent{Type: eventType, Event: event})
+		return nil
+	})
+}
+
+func eventsPath(namespace string, query url.Values) string {
+	path := "/api/v1/events"
+	if namespace != "" {
+		path = fmt.Sprintf("/api/v1/namespaces/%s/events", url.PathEscape(namespace))
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path
+}
@@ -0,0 +1,202 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectMeta holds the metadata fields used by the plugin
+type ObjectMeta struct {
+	Name            string            `json:"name,omitempty"`
+	Namespace       string            `json:"namespace,omitempty"`
+	UID             string            `json:"uid,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+}
+
+// Secret is a core/v1 Secret, data values are base64 decoded
+type Secret struct {
+	Metadata ObjectMeta        `json:"metadata"`
+	Type     string            `json:"type,omitempty"`
+	Data     map[string][]byte `json:"data,omitempty"`
+}
+
+// GetSecret fetches the secret name in namespace
+func (c *Client) GetSecret(ctx context.Context, namespace string, name string) (*Secret, error) {
+	secret := &Secret{}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/secrets/%s", url.PathEscape(namespace), url.PathEscape(name))
+	if err := c.Get(ctx, path, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// LokiStack is a loki.grafana.com/v1 LokiStack of the Loki operator, with
+// the retention limits used by the plugin
+type LokiStack struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Limits struct {
+			Global  LokiStackLimits            `json:"global,omitempty"`
+			Tenants map[string]LokiStackLimits `json:"tenants,omitempty"`
+		} `json:"limits,omitempty"`
+	} `json:"spec"`
+}
+
+// LokiStackLimits are the global or tenant limits of a LokiStack, a zero
+// retention keeps the logs forever
+type LokiStackLimits struct {
+	Retention struct {
+		Days int `json:"days,omitempty"`
+	} `json:"retention,omitempty"`
+}
+
+// GetLokiStack fetches the LokiStack name in namespace
+func (c *Client) GetLokiStack(ctx context.Context, namespace string, name string) (*LokiStack, error) {
+	lokiStack := &LokiStack{}
+	path := fmt.Sprintf("/apis/loki.grafana.com/v1/namespaces/%s/lokistacks/%s", url.PathEscape(namespace), url.PathEscape(name))
+	if err := c.Get(ctx, path, lokiStack); err != nil {
+		return nil, err
+	}
+	return lokiStack, nil
+}
+
+// ConfigMap is a core/v1 ConfigMap
+type ConfigMap struct {
+	APIVersion string            `json:"apiVersion,omitempty"`
+	Kind       string            `json:"kind,omitempty"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Data       map[string]string `json:"data,omitempty"`
+}
+
+// GetConfigMap fetches the config map name in namespace
+func (c *Client) GetConfigMap(ctx context.Context, namespace string, name string) (*ConfigMap, error) {
+	configMap := &ConfigMap{}
+	if err := c.Get(ctx, configMapPath(namespace, name), configMap); err != nil {
+		return nil, err
+	}
+	return configMap, nil
+}
+
+// CreateConfigMap creates configMap in the namespace of its metadata
+func (c *Client) CreateConfigMap(ctx context.Context, configMap *ConfigMap) (*ConfigMap, error) {
+	configMap.APIVersion, configMap.Kind = "v1", "ConfigMap"
+	created := &ConfigMap{}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/configmaps", url.PathEscape(configMap.Metadata.Namespace))
+	if err := c.Do(ctx, http.MethodPost, path, configMap, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateConfigMap replaces configMap, the update fails with a conflict when
+// its resource version is outdated
+func (c *Client) UpdateConfigMap(ctx context.Context, configMap *ConfigMap) (*ConfigMap, error) {
+	configMap.APIVersion, configMap.Kind = "v1", "ConfigMap"
+	updated := &ConfigMap{}
+	if err := c.Do(ctx, http.MethodPut, configMapPath(configMap.Metadata.Namespace, configMap.Metadata.Name), configMap, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// watch event types of the API server
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+	EventBookmark = "BOOKMARK"
+	EventError    = "ERROR"
+)
+
+// ConfigMapEvent is a change of a watched config map
+type ConfigMapEvent struct {
+	Type      string
+	ConfigMap *ConfigMap
+}
+
+// WatchConfigMap watches the config map name in namespace from
+// resourceVersion and calls onEvent with its changes until the API server
+// ends the watch after timeout, or ctx is done. An ERROR event is returned as
+// a StatusError, like the 410 Gone of an expired resource version
+func (c *Client) WatchConfigMap(ctx context.Context, namespace string, name string, resourceVersion string, timeout time.Duration, onEvent func(ConfigMapEvent)) error {
+	query := url.Values{
+		"watch":           {"true"},
+		"fieldSelector":   {"metadata.name=" + name},
+		"resourceVersion": {resourceVersion},
+		"timeoutSeconds":  {strconv.Itoa(int(timeout.Seconds()))},
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/configmaps?%s", url.PathEscape(namespace), query.Encode())
+
+	return c.watch(ctx, path, func(eventType string, object json.RawMessage) error {
+		configMap := &ConfigMap{}
+		if err := json.Unmarshal(object, configMap); err != nil {
+			return err
+		}
+		onEvent(ConfigMapEvent{Type: eventType, ConfigMap: configMap})
+		return nil
+	})
+}
+
+// watch sends the watch request of path and calls onObject with the type and
+// object of every event until the API server ends the watch or ctx is done.
+// An ERROR event is returned as a StatusError
+func (c *Client) watch(ctx context.Context, path string, onObject func(eventType string, object json.RawMessage) error) error {
+	resp, err := c.request(ctx, http.MethodGet, path, nil, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		event := struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}{}
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if event.Type == EventError {
+			status := struct {
+				Code    int    `json:"code"`
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			}{}
+			if err := json.Unmarshal(event.Object, &status); err != nil {
+				return err
+			}
+			return &StatusError{Code: status.Code, Reason: status.Reason, Message: status.Message}
+		}
+
+		if err := onObject(event.Type, event.Object); err != nil {
+			return err
+		}
+	}
+}
+
+func configMapPath(namespace string, name string) string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/configmaps/%s", url.PathEscape(namespace), url.PathEscape(name))
+}
+
+// ParseNamespacedName splits a `<namespace>/<name>` reference
+func ParseNamespacedName(value string) (string, string, error) {
+	namespace, name, found := strings.Cut(value, "/")
+	if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid reference %q, expected <namespace>/<name>", value)
+	}
+	return namespace, name, nil
+}
@@ -0,0 +1,97 @@
+package kube
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tokenExpiryMargin is the time before its expiry a token is read again
+	tokenExpiryMargin = 30 * time.Second
+	// tokenRefreshInterval is the time a token without expiry is used before
+	// it is read again
+	tokenRefreshInterval = time.Minute
+)
+
+// ServiceAccountTokenFile is the path of the projected token of the pod
+// service account
+var ServiceAccountTokenFile = filepath.Join(serviceAccountPath, "token")
+
+// TokenFile provides the bearer token of a file rotated by the kubelet, like
+// the projected service account token. The token is read again when it
+// expires
+type TokenFile struct {
+	path      string
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+	now       func() time.Time
+}
+
+// NewTokenFile returns the provider of the token of path, the file is read on
+// the first call to Token
+func NewTokenFile(path string) *TokenFile {
+	return &TokenFile{path: path, now: time.Now}
+}
+
+// Token returns the current token, the loaded token is kept when the file
+// cannot be read before its expiry
+func (f *TokenFile) Token() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	if f.token != "" && now.Before(f.refreshAt) {
+		return f.token, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		if f.token != "" && now.Before(f.refreshAt.Add(tokenExpiryMargin)) {
+			return f.token, nil
+		}
+		return "", fmt.Errorf("cannot read token: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", f.path)
+	}
+
+	f.token = token
+	f.refreshAt = now.Add(tokenRefreshInterval)
+	if expiry, ok := tokenExpiry(token); ok {
+		f.refreshAt = expiry.Add(-tokenExpiryMargin)
+	}
+
+	return f.token, nil
+}
+
+// tokenExpiry returns the exp claim of a JWT token, the signature is not
+// verified
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	claims := struct {
+		Expiry int64 `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Expiry == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Expiry, 0), true
+}
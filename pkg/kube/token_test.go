@@ -0,0 +1,81 @@
+package kube
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJWT(expiry time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"system:serviceaccount:openshift-logging:logging-view-plugin","exp":%d}`, expiry.Unix())))
+	return "eyJhbGciOiJSUzI1NiJ9." + payload + ".c2lnbmF0dXJl"
+}
+
+func TestTokenFile(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "token")
+
+	first := newTestJWT(now.Add(time.Hour))
+	require.NoError(t, os.WriteFile(path, []byte(first+"\n"), 0600))
+
+	tokenFile := NewTokenFile(path)
+	tokenFile.now = func() time.Time { return now }
+
+	token, err := tokenFile.Token()
+	require.NoError(t, err)
+	require.Equal(t, first, token)
+
+	// the rotated token is read when the loaded one expires
+	second := newTestJWT(now.Add(2 * time.Hour))
+	require.NoError(t, os.WriteFile(path, []byte(second), 0600))
+
+	token, err = tokenFile.Token()
+	require.NoError(t, err)
+	require.Equal(t, first, token)
+
+	now = now.Add(time.Hour - tokenExpiryMargin)
+	token, err = tokenFile.Token()
+	require.NoError(t, err)
+	require.Equal(t, second, token)
+
+	// the loaded token is kept until its expiry when the file cannot be read
+	require.NoError(t, os.Remove(path))
+	now = now.Add(time.Hour)
+	token, err = tokenFile.Token()
+	require.NoError(t, err)
+	require.Equal(t, second, token)
+
+	now = now.Add(time.Hour)
+	_, err = tokenFile.Token()
+	require.Error(t, err)
+}
+
+func TestTokenFileWithoutExpiry(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("opaque-token"), 0600))
+
+	tokenFile := NewTokenFile(path)
+	tokenFile.now = func() time.Time { return now }
+
+	token, err := tokenFile.Token()
+	require.NoError(t, err)
+	require.Equal(t, "opaque-token", token)
+
+	require.NoError(t, os.WriteFile(path, []byte("rotated-token"), 0600))
+	now = now.Add(tokenRefreshInterval)
+
+	token, err = tokenFile.Token()
+	require.NoError(t, err)
+	require.Equal(t, "rotated-token", token)
+}
+
+func TestTokenFileMissing(t *testing.T) {
+	_, err := NewTokenFile(filepath.Join(t.TempDir(), "token")).Token()
+	require.ErrorContains(t, err, "cannot read token")
+}
@@ -0,0 +1,207 @@
+package logql
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenNumber
+	tokenDuration
+	tokenOperator
+	tokenOpenBrace
+	tokenCloseBrace
+	tokenOpenParen
+	tokenCloseParen
+	tokenOpenBracket
+	tokenCloseBracket
+	tokenComma
+	tokenPipe
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of query"
+	case tokenIdentifier:
+		return "identifier"
+	case tokenString:
+		return "string"
+	case tokenNumber:
+		return "number"
+	case tokenDuration:
+		return "duration"
+	case tokenOperator:
+		return "operator"
+	case tokenOpenBrace:
+		return "{"
+	case tokenCloseBrace:
+		return "}"
+	case tokenOpenParen:
+		return "("
+	case tokenCloseParen:
+		return ")"
+	case tokenOpenBracket:
+		return "["
+	case tokenCloseBracket:
+		return "]"
+	case tokenComma:
+		return ","
+	case tokenPipe:
+		return "|"
+	}
+	return "unknown"
+}
+
+type token struct {
+	kind  tokenKind
+	value string
+	// pos is the byte offset of the token in the query
+	pos int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return t.kind.String()
+	case tokenString:
+		return fmt.Sprintf("string %s", t.value)
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// operators are sorted by decreasing length to match the longest one first
+var operators = []string{"|=", "|~", "|>", "!=", "!~", "!>", "=~", "==", ">=", "<=", "=", ">", "<", "+", "-", "*", "/", "%", "^"}
+
+// lex splits query into tokens, the comments starting with # are skipped
+func lex(query string) ([]token, error) {
+	tokens := []token{}
+
+	for pos := 0; pos < len(query); {
+		c := query[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+		case c == '#':
+			for pos < len(query) && query[pos] != '\n' {
+				pos++
+			}
+		case c == '"' || c == '`':
+			end, err := scanString(query, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: query[pos : end+1], pos: pos})
+			pos = end + 1
+		case isDigit(c) || (c == '.' && pos+1 < len(query) && isDigit(query[pos+1])):
+			end, kind := scanNumber(query, pos)
+			tokens = append(tokens, token{kind: kind, value: query[pos:end], pos: pos})
+			pos = end
+		case isIdentifierStart(c):
+			end := pos + 1
+			for end < len(query) && isIdentifierChar(query[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, value: query[pos:end], pos: pos})
+			pos = end
+		default:
+			if kind, ok := punctuation[c]; ok {
+				// | followed by an operator character is a line filter
+				if c != '|' || pos+1 >= len(query) || !strings.ContainsRune("=~>", rune(query[pos+1])) {
+					tokens = append(tokens, token{kind: kind, value: string(c), pos: pos})
+					pos++
+					continue
+				}
+			}
+
+			operator := ""
+			for _, op := range operators {
+				if strings.HasPrefix(query[pos:], op) {
+					operator = op
+					break
+				}
+			}
+			if operator == "" {
+				r, _ := utf8.DecodeRuneInString(query[pos:])
+				return nil, newSyntaxError(query, pos, fmt.Sprintf("unexpected character %q", r))
+			}
+			tokens = append(tokens, token{kind: tokenOperator, value: operator, pos: pos})
+			pos += len(operator)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(query)}), nil
+}
+
+var punctuation = map[byte]tokenKind{
+	'{': tokenOpenBrace,
+	'}': tokenCloseBrace,
+	'(': tokenOpenParen,
+	')': tokenCloseParen,
+	'[': tokenOpenBracket,
+	']': tokenCloseBracket,
+	',': tokenComma,
+	'|': tokenPipe,
+}
+
+func scanString(query string, pos int) (int, error) {
+	quote := query[pos]
+	for i := pos + 1; i < len(query); i++ {
+		switch {
+		case query[i] == '\\' && quote == '"':
+			i++
+		case query[i] == quote:
+			return i, nil
+		case query[i] == '\n' && quote == '"':
+			return pos, newSyntaxError(query, pos, "unterminated string")
+		}
+	}
+	return pos, newSyntaxError(query, pos, "unterminated string")
+}
+
+// scanNumber scans a number, a duration like 5m30s or a byte size like 10KB
+func scanNumber(query string, pos int) (int, tokenKind) {
+	end := pos
+	for end < len(query) && (isDigit(query[end]) || query[end] == '.') {
+		end++
+	}
+	if end < len(query) && (query[end] == 'e' || query[end] == 'E') && end+1 < len(query) && (isDigit(query[end+1]) || query[end+1] == '-' || query[end+1] == '+') {
+		end += 2
+		for end < len(query) && isDigit(query[end]) {
+			end++
+		}
+		return end, tokenNumber
+	}
+
+	if end < len(query) && isLetter(query[end]) {
+		// a unit suffix, possibly followed by other number and unit pairs
+		for end < len(query) && (isLetter(query[end]) || isDigit(query[end]) || query[end] == '.') {
+			end++
+		}
+		return end, tokenDuration
+	}
+
+	return end, tokenNumber
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierStart(c byte) bool {
+	return isLetter(c) || c == '_'
+}
+
+func isIdentifierChar(c byte) bool {
+	return isIdentifierStart(c) || isDigit(c)
+}
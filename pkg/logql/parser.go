@@ -0,0 +1,953 @@
+package logql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Position locates a token in a query, the line and column start at 1 and the
+// column counts bytes
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// SyntaxError is a LogQL syntax error located in the query
+type SyntaxError struct {
+	Message  string   `json:"message"`
+	Position Position `json:"position"`
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Position.Line, e.Position.Column, e.Message)
+}
+
+func newSyntaxError(query string, offset int, message string) *SyntaxError {
+	line, column := 1, 1
+	for i := 0; i < offset && i < len(query); i++ {
+		if query[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &SyntaxError{Message: message, Position: Position{Offset: offset, Line: line, Column: column}}
+}
+
+// Matcher is a stream selector label matcher
+type Matcher struct {
+	Label    string `json:"label"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+func (m Matcher) String() string {
+	return m.Label + m.Operator + strconv.Quote(m.Value)
+}
+
+// LineFilter is a line filter of the pipeline of a stream selector, like
+// |= "error" or "warning"
+type LineFilter struct {
+	Operator string
+	// Values are the strings of the filter, any of them matches
+	Values []string
+}
+
+// Selector is a stream selector of a query
+type Selector struct {
+	Matchers []Matcher
+	// LineFilters are the string line filters of the pipeline of the
+	// selector, Stages counts its other stages, like the parsers and the ip
+	// line filters
+	LineFilters []LineFilter
+	Stages      int
+	// start and end are the offsets of the braces in the query
+	start int
+	end   int
+}
+
+// Query is a parsed LogQL query
+type Query struct {
+	// Metric is true for the metric queries, false for the log queries
+	Metric    bool
+	Selectors []Selector
+	// Ranges are the log ranges of the range aggregations, like 5m in
+	// rate({app="foo"}[5m])
+	Ranges []time.Duration
+}
+
+type exprType int
+
+const (
+	exprLog exprType = iota
+	exprMetric
+	exprScalar
+)
+
+var (
+	matcherRegexp = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*("(?:[^"\\]|\\.)*"|` + "`[^`]*`" + `)\s*$`)
+
+	rangeAggregations = map[string]bool{
+		"count_over_time": true, "rate": true, "rate_counter": true, "bytes_rate": true, "bytes_over_time": true,
+		"absent_over_time": true, "sum_over_time": true, "avg_over_time": true, "max_over_time": true,
+		"min_over_time": true, "first_over_time": true, "last_over_time": true, "stdvar_over_time": true,
+		"stddev_over_time": true, "quantile_over_time": true,
+	}
+	// logRangeAggregations count the log lines and cannot unwrap a label
+	logRangeAggregations = map[string]bool{
+		"count_over_time": true, "bytes_rate": true, "bytes_over_time": true, "absent_over_time": true,
+	}
+	vectorAggregations = map[string]bool{
+		"sum": true, "avg": true, "min": true, "max": true, "stddev": true, "stdvar": true, "count": true,
+		"topk": true, "bottomk": true, "sort": true, "sort_desc": true,
+	}
+	binaryPrecedence = map[string]int{
+		"or": 1, "and": 2, "unless": 2,
+		"==": 3, "!=": 3, ">": 3, ">=": 3, "<": 3, "<=": 3,
+		"+": 4, "-": 4,
+		"*": 5, "/": 5, "%": 5,
+		"^": 6,
+	}
+	lineFilterOperators  = map[string]bool{"|=": true, "!=": true, "|~": true, "!~": true, "|>": true, "!>": true}
+	labelFilterOperators = map[string]bool{"=": true, "!=": true, "=~": true, "!~": true, "==": true, ">": true, ">=": true, "<": true, "<=": true}
+	unwrapConversions    = map[string]bool{"bytes": true, "duration": true, "duration_seconds": true}
+	logfmtFlags          = map[string]bool{"strict": true, "keep-empty": true}
+)
+
+// ParseMatcher parses a label matcher like namespace="default"
+func ParseMatcher(value string) (Matcher, error) {
+	match := matcherRegexp.FindStringSubmatch(value)
+	if match == nil {
+		return Matcher{}, fmt.Errorf("invalid matcher %q, expected <label><operator><quoted value>", value)
+	}
+
+	unquoted, err := unquote(match[3])
+	if err != nil {
+		return Matcher{}, fmt.Errorf("invalid matcher value %s: %w", match[3], err)
+	}
+
+	m := Matcher{Label: match[1], Operator: match[2], Value: unquoted}
+	if err := m.validate(); err != nil {
+		return Matcher{}, err
+	}
+	return m, nil
+}
+
+func (m Matcher) validate() error {
+	if m.Operator == "=~" || m.Operator == "!~" {
+		if _, err := regexp.Compile("^(?:" + m.Value + ")$"); err != nil {
+			return fmt.Errorf("invalid regular expression %q: %w", m.Value, err)
+		}
+	}
+	return nil
+}
+
+// matchesEmpty returns true when the matcher selects the streams without the
+// label
+func (m Matcher) matchesEmpty() bool {
+	switch m.Operator {
+	case "=":
+		return m.Value == ""
+	case "!=":
+		return m.Value != ""
+	case "=~":
+		return regexp.MustCompile("^(?:" + m.Value + ")$").MatchString("")
+	case "!~":
+		return !regexp.MustCompile("^(?:" + m.Value + ")$").MatchString("")
+	}
+	return false
+}
+
+// Parse parses a LogQL log or metric query
+func Parse(query string) (*Query, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{query: query, tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, newSyntaxError(query, 0, "empty query")
+	}
+
+	typ, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+
+	return &Query{Metric: typ != exprLog, Selectors: p.selectors, Ranges: p.ranges}, nil
+}
+
+type parser struct {
+	query     string
+	tokens    []token
+	pos       int
+	selectors []Selector
+	ranges    []time.Duration
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return newSyntaxError(p.query, t.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, p.errorf(t, "expected %s, found %s", kind, t)
+	}
+	return t, nil
+}
+
+func (p *parser) isIdentifier(values ...string) bool {
+	t := p.peek()
+	if t.kind != tokenIdentifier {
+		return false
+	}
+	for _, value := range values {
+		if t.value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) binaryOperator() (string, bool) {
+	t := p.peek()
+	if t.kind == tokenOperator || (t.kind == tokenIdentifier && (t.value == "and" || t.value == "or" || t.value == "unless")) {
+		if _, ok := binaryPrecedence[t.value]; ok {
+			return t.value, true
+		}
+	}
+	return "", false
+}
+
+// parseExpr parses the binary operations of operators with a precedence
+// higher than minPrecedence
+func (p *parser) parseExpr(minPrecedence int) (exprType, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return left, err
+	}
+
+	for {
+		operator, ok := p.binaryOperator()
+		if !ok || binaryPrecedence[operator] <= minPrecedence {
+			return left, nil
+		}
+		operatorToken := p.next()
+
+		if left == exprLog {
+			return left, p.errorf(operatorToken, "log queries cannot be used in binary operations, use a metric query")
+		}
+
+		if p.isIdentifier("bool") {
+			p.next()
+		}
+		if p.isIdentifier("on", "ignoring") {
+			p.next()
+			if err := p.parseLabelList(); err != nil {
+				return left, err
+			}
+			if p.isIdentifier("group_left", "group_right") {
+				p.next()
+				if p.peek().kind == tokenOpenParen {
+					if err := p.parseLabelList(); err != nil {
+						return left, err
+					}
+				}
+			}
+		}
+
+		// ^ is right associative
+		precedence := binaryPrecedence[operator]
+		if operator == "^" {
+			precedence--
+		}
+		rightToken := p.peek()
+		right, err := p.parseExpr(precedence)
+		if err != nil {
+			return right, err
+		}
+		if right == exprLog {
+			return right, p.errorf(rightToken, "log queries cannot be used in binary operations, use a metric query")
+		}
+
+		if left == exprScalar && right == exprScalar {
+			left = exprScalar
+		} else {
+			left = exprMetric
+		}
+	}
+}
+
+func (p *parser) parseUnary() (exprType, error) {
+	t := p.peek()
+
+	switch t.kind {
+	case tokenOperator:
+		if t.value == "-" || t.value == "+" {
+			p.next()
+			operandToken := p.peek()
+			typ, err := p.parseUnary()
+			if err == nil && typ == exprLog {
+				return typ, p.errorf(operandToken, "log queries cannot be negated")
+			}
+			return typ, err
+		}
+	case tokenNumber:
+		p.next()
+		return exprScalar, nil
+	case tokenOpenParen:
+		p.next()
+		typ, err := p.parseExpr(0)
+		if err != nil {
+			return typ, err
+		}
+		_, err = p.expect(tokenCloseParen)
+		return typ, err
+	case tokenOpenBrace:
+		return exprLog, p.parseLogExpr()
+	case tokenIdentifier:
+		switch {
+		case rangeAggregations[t.value]:
+			return exprMetric, p.parseRangeAggregation()
+		case vectorAggregations[t.value]:
+			return exprMetric, p.parseVectorAggregation()
+		case t.value == "vector":
+			p.next()
+			if _, err := p.expect(tokenOpenParen); err != nil {
+				return exprMetric, err
+			}
+			if _, err := p.expect(tokenNumber); err != nil {
+				return exprMetric, err
+			}
+			_, err := p.expect(tokenCloseParen)
+			return exprMetric, err
+		case t.value == "label_replace":
+			return exprMetric, p.parseLabelReplace()
+		}
+		return exprMetric, p.errorf(t, "unknown function %s", t.value)
+	}
+
+	return exprMetric, p.errorf(t, "unexpected %s, expected a stream selector, a function or a number", t)
+}
+
+// parseLogExpr parses a stream selector followed by its pipeline
+func (p *parser) parseLogExpr() error {
+	if err := p.parseSelector(); err != nil {
+		return err
+	}
+	return p.parsePipeline()
+}
+
+func (p *parser) parseSelector() error {
+	open, err := p.expect(tokenOpenBrace)
+	if err != nil {
+		return err
+	}
+
+	selector := Selector{start: open.pos}
+	for {
+		if t := p.peek(); t.kind == tokenCloseBrace {
+			selector.end = p.next().pos
+			break
+		}
+
+		labelToken, err := p.expect(tokenIdentifier)
+		if err != nil {
+			return p.errorf(labelToken, "expected label name, found %s", labelToken)
+		}
+		operatorToken := p.next()
+		if operatorToken.kind != tokenOperator || !(operatorToken.value == "=" || operatorToken.value == "!=" || operatorToken.value == "=~" || operatorToken.value == "!~") {
+			return p.errorf(operatorToken, "expected =, !=, =~ or !~ after label %s, found %s", labelToken.value, operatorToken)
+		}
+		valueToken, err := p.expect(tokenString)
+		if err != nil {
+			return err
+		}
+		value, err := unquote(valueToken.value)
+		if err != nil {
+			return p.errorf(valueToken, "invalid string %s", valueToken.value)
+		}
+
+		m := Matcher{Label: labelToken.value, Operator: operatorToken.value, Value: value}
+		if err := m.validate(); err != nil {
+			return p.errorf(valueToken, "%s", err)
+		}
+		selector.Matchers = append(selector.Matchers, m)
+
+		if t := p.peek(); t.kind == tokenComma {
+			p.next()
+		} else if t.kind != tokenCloseBrace {
+			return p.errorf(t, "expected , or }, found %s", t)
+		}
+	}
+
+	nonEmpty := false
+	for _, m := range selector.Matchers {
+		if !m.matchesEmpty() {
+			nonEmpty = true
+		}
+	}
+	if !nonEmpty {
+		return p.errorf(open, "the stream selector must have at least one matcher that does not match the empty value")
+	}
+
+	p.selectors = append(p.selectors, selector)
+	return nil
+}
+
+// parsePipeline parses the line filters and the stages following a stream
+// selector
+func (p *parser) parsePipeline() error {
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenOperator && lineFilterOperators[t.value]:
+			p.next()
+			if p.isIdentifier("ip") {
+				if err := p.parseIPFunction(); err != nil {
+					return err
+				}
+				p.currentSelector().Stages++
+				continue
+			}
+			filter := LineFilter{Operator: t.value}
+			value, err := p.parseLineFilterString()
+			if err != nil {
+				return err
+			}
+			filter.Values = append(filter.Values, value)
+			for p.isIdentifier("or") && p.peekAt(1).kind == tokenString {
+				p.next()
+				value, err := p.parseLineFilterString()
+				if err != nil {
+					return err
+				}
+				filter.Values = append(filter.Values, value)
+			}
+			selector := p.currentSelector()
+			selector.LineFilters = append(selector.LineFilters, filter)
+		case t.kind == tokenPipe:
+			p.next()
+			if err := p.parseStage(); err != nil {
+				return err
+			}
+			p.currentSelector().Stages++
+		default:
+			return nil
+		}
+	}
+}
+
+// currentSelector returns the selector whose pipeline is being parsed
+func (p *parser) currentSelector() *Selector {
+	return &p.selectors[len(p.selectors)-1]
+}
+
+func (p *parser) parseLineFilterString() (string, error) {
+	t, err := p.expect(tokenString)
+	if err != nil {
+		return "", p.errorf(t, "expected line filter string, found %s", t)
+	}
+	value, err := unquote(t.value)
+	if err != nil {
+		return "", p.errorf(t, "invalid string %s", t.value)
+	}
+	return value, nil
+}
+
+func (p *parser) parseIPFunction() error {
+	p.next()
+	if _, err := p.expect(tokenOpenParen); err != nil {
+		return err
+	}
+	if _, err := p.expect(tokenString); err != nil {
+		return err
+	}
+	_, err := p.expect(tokenCloseParen)
+	return err
+}
+
+func (p *parser) parseStage() error {
+	t := p.peek()
+	if t.kind != tokenIdentifier {
+		if t.kind == tokenOpenParen {
+			return p.parseLabelFilter()
+		}
+		return p.errorf(t, "expected a pipeline stage after |, found %s", t)
+	}
+
+	switch t.value {
+	case "json":
+		p.next()
+		return p.parseExtractionParams()
+	case "logfmt":
+		p.next()
+		if err := p.parseLogfmtFlags(); err != nil {
+			return err
+		}
+		return p.parseExtractionParams()
+	case "regexp", "pattern", "line_format":
+		p.next()
+		valueToken, err := p.expect(tokenString)
+		if err != nil {
+			return err
+		}
+		if t.value == "regexp" {
+			value, _ := unquote(valueToken.value)
+			if _, err := regexp.Compile(value); err != nil {
+				return p.errorf(valueToken, "invalid regular expression: %s", err)
+			}
+		}
+		return nil
+	case "unpack", "decolorize":
+		p.next()
+		return nil
+	case "label_format":
+		p.next()
+		return p.parseLabelFormat()
+	case "drop", "keep":
+		p.next()
+		return p.parseLabelsWithMatchers()
+	case "unwrap":
+		p.next()
+		return p.parseUnwrap()
+	}
+
+	// an identifier followed by a comparison is a label filter
+	if next := p.peekAt(1); next.kind == tokenOperator && labelFilterOperators[next.value] {
+		return p.parseLabelFilter()
+	}
+
+	return p.errorf(t, "unknown pipeline stage %s", t.value)
+}
+
+// parseExtractionParams parses the optional <label>[="<expression>"] list of
+// the json and logfmt parsers
+func (p *parser) parseExtractionParams() error {
+	if p.peek().kind != tokenIdentifier {
+		return nil
+	}
+
+	for {
+		if _, err := p.expect(tokenIdentifier); err != nil {
+			return err
+		}
+		if t := p.peek(); t.kind == tokenOperator && t.value == "=" {
+			p.next()
+			if _, err := p.expect(tokenString); err != nil {
+				return err
+			}
+		}
+		if p.peek().kind != tokenComma {
+			return nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseLogfmtFlags() error {
+	for {
+		t := p.peek()
+		if t.kind != tokenOperator || t.value != "-" || p.peekAt(1).value != "-" {
+			return nil
+		}
+		p.next()
+		p.next()
+
+		nameToken, err := p.expect(tokenIdentifier)
+		if err != nil {
+			return err
+		}
+		name := nameToken.value
+		for p.peek().value == "-" && p.peekAt(1).kind == tokenIdentifier && p.peek().pos == nameToken.pos+len(name) {
+			p.next()
+			name += "-" + p.next().value
+		}
+		if !logfmtFlags[name] {
+			return p.errorf(t, "unknown logfmt flag --%s", name)
+		}
+	}
+}
+
+func (p *parser) parseLabelFormat() error {
+	for {
+		if _, err := p.expect(tokenIdentifier); err != nil {
+			return err
+		}
+		if t := p.next(); t.kind != tokenOperator || t.value != "=" {
+			return p.errorf(t, "expected =, found %s", t)
+		}
+		if t := p.next(); t.kind != tokenString && t.kind != tokenIdentifier {
+			return p.errorf(t, "expected a template string or a label name, found %s", t)
+		}
+		if p.peek().kind != tokenComma {
+			return nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseLabelsWithMatchers() error {
+	for {
+		if _, err := p.expect(tokenIdentifier); err != nil {
+			return err
+		}
+		if t := p.peek(); t.kind == tokenOperator && labelFilterOperators[t.value] {
+			p.next()
+			if _, err := p.expect(tokenString); err != nil {
+				return err
+			}
+		}
+		if p.peek().kind != tokenComma {
+			return nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseUnwrap() error {
+	t, err := p.expect(tokenIdentifier)
+	if err != nil {
+		return err
+	}
+	if p.peek().kind == tokenOpenParen {
+		if !unwrapConversions[t.value] {
+			return p.errorf(t, "unknown unwrap conversion %s", t.value)
+		}
+		p.next()
+		if _, err := p.expect(tokenIdentifier); err != nil {
+			return err
+		}
+		if _, err := p.expect(tokenCloseParen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseLabelFilter parses label filters combined with and, or and commas
+func (p *parser) parseLabelFilter() error {
+	if err := p.parseLabelFilterAtom(); err != nil {
+		return err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenComma, t.kind == tokenIdentifier && (t.value == "and" || t.value == "or"):
+			p.next()
+		case t.kind == tokenIdentifier && p.peekAt(1).kind == tokenOperator && labelFilterOperators[p.peekAt(1).value]:
+			// consecutive filters are combined with and
+		default:
+			return nil
+		}
+		if err := p.parseLabelFilterAtom(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) parseLabelFilterAtom() error {
+	if p.peek().kind == tokenOpenParen {
+		p.next()
+		if err := p.parseLabelFilter(); err != nil {
+			return err
+		}
+		_, err := p.expect(tokenCloseParen)
+		return err
+	}
+
+	labelToken, err := p.expect(tokenIdentifier)
+	if err != nil {
+		return p.errorf(labelToken, "expected label filter, found %s", labelToken)
+	}
+	operatorToken := p.next()
+	if operatorToken.kind != tokenOperator || !labelFilterOperators[operatorToken.value] {
+		return p.errorf(operatorToken, "expected comparison operator after label %s, found %s", labelToken.value, operatorToken)
+	}
+
+	if (operatorToken.value == "=" || operatorToken.value == "!=") && p.isIdentifier("ip") {
+		return p.parseIPFunction()
+	}
+
+	valueToken := p.next()
+	switch valueToken.kind {
+	case tokenString:
+		if operatorToken.value != "=" && operatorToken.value != "!=" && operatorToken.value != "=~" && operatorToken.value != "!~" {
+			return p.errorf(valueToken, "operator %s cannot compare strings", operatorToken.value)
+		}
+		if operatorToken.value == "=~" || operatorToken.value == "!~" {
+			value, _ := unquote(valueToken.value)
+			if _, err := regexp.Compile(value); err != nil {
+				return p.errorf(valueToken, "invalid regular expression: %s", err)
+			}
+		}
+	case tokenNumber, tokenDuration:
+		if operatorToken.value == "=~" || operatorToken.value == "!~" {
+			return p.errorf(operatorToken, "operator %s requires a string", operatorToken.value)
+		}
+		if valueToken.kind == tokenDuration && !isDurationOrBytes(valueToken.value) {
+			return p.errorf(valueToken, "invalid duration or byte size %s", valueToken.value)
+		}
+	default:
+		return p.errorf(valueToken, "expected a string, a number or a duration, found %s", valueToken)
+	}
+
+	return nil
+}
+
+func (p *parser) parseRangeAggregation() error {
+	nameToken := p.next()
+	if _, err := p.expect(tokenOpenParen); err != nil {
+		return err
+	}
+
+	if nameToken.value == "quantile_over_time" {
+		if _, err := p.expect(tokenNumber); err != nil {
+			return err
+		}
+		if _, err := p.expect(tokenComma); err != nil {
+			return err
+		}
+	}
+
+	start := p.pos
+	if p.peek().kind == tokenOpenParen {
+		p.next()
+		if err := p.parseLogExpr(); err != nil {
+			return err
+		}
+		if _, err := p.expect(tokenCloseParen); err != nil {
+			return err
+		}
+	} else if p.peek().kind == tokenOpenBrace {
+		if err := p.parseLogExpr(); err != nil {
+			return err
+		}
+	} else {
+		t := p.peek()
+		return p.errorf(t, "%s expects a log range like {app=\"foo\"}[5m], found %s", nameToken.value, t)
+	}
+
+	if t, err := p.expect(tokenOpenBracket); err != nil {
+		return p.errorf(t, "%s expects a range like [5m] after the log query", nameToken.value)
+	}
+	logRange, err := p.parseDuration()
+	if err != nil {
+		return err
+	}
+	p.ranges = append(p.ranges, logRange)
+	if _, err := p.expect(tokenCloseBracket); err != nil {
+		return err
+	}
+
+	// the pipeline may also follow the range
+	if err := p.parsePipeline(); err != nil {
+		return err
+	}
+	if p.isIdentifier("offset") {
+		p.next()
+		if _, err := p.parseDuration(); err != nil {
+			return err
+		}
+	}
+
+	unwrapped := p.hasUnwrap(start, p.pos)
+	if logRangeAggregations[nameToken.value] && unwrapped {
+		return p.errorf(nameToken, "%s cannot be used with unwrap", nameToken.value)
+	}
+	if !logRangeAggregations[nameToken.value] && nameToken.value != "rate" && !unwrapped {
+		return p.errorf(nameToken, "%s requires an unwrap stage", nameToken.value)
+	}
+
+	if _, err := p.expect(tokenCloseParen); err != nil {
+		return err
+	}
+
+	if p.isIdentifier("by", "without") {
+		p.next()
+		return p.parseLabelList()
+	}
+	return nil
+}
+
+// hasUnwrap returns true when the tokens between start and end have an unwrap
+// stage
+func (p *parser) hasUnwrap(start int, end int) bool {
+	for i := start; i < end-1; i++ {
+		if p.tokens[i].kind == tokenPipe && p.tokens[i+1].kind == tokenIdentifier && p.tokens[i+1].value == "unwrap" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseVectorAggregation() error {
+	nameToken := p.next()
+
+	grouped := false
+	if p.isIdentifier("by", "without") {
+		p.next()
+		if err := p.parseLabelList(); err != nil {
+			return err
+		}
+		grouped = true
+	}
+
+	if _, err := p.expect(tokenOpenParen); err != nil {
+		return err
+	}
+	if nameToken.value == "topk" || nameToken.value == "bottomk" {
+		if _, err := p.expect(tokenNumber); err != nil {
+			return err
+		}
+		if _, err := p.expect(tokenComma); err != nil {
+			return err
+		}
+	}
+
+	operandToken := p.peek()
+	typ, err := p.parseExpr(0)
+	if err != nil {
+		return err
+	}
+	if typ == exprLog {
+		return p.errorf(operandToken, "%s expects a metric query, use a range aggregation like count_over_time on the log query", nameToken.value)
+	}
+
+	if _, err := p.expect(tokenCloseParen); err != nil {
+		return err
+	}
+
+	if !grouped && p.isIdentifier("by", "without") {
+		p.next()
+		return p.parseLabelList()
+	}
+	return nil
+}
+
+func (p *parser) parseLabelReplace() error {
+	p.next()
+	if _, err := p.expect(tokenOpenParen); err != nil {
+		return err
+	}
+
+	operandToken := p.peek()
+	typ, err := p.parseExpr(0)
+	if err != nil {
+		return err
+	}
+	if typ == exprLog {
+		return p.errorf(operandToken, "label_replace expects a metric query")
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := p.expect(tokenComma); err != nil {
+			return err
+		}
+		if _, err := p.expect(tokenString); err != nil {
+			return err
+		}
+	}
+
+	_, err = p.expect(tokenCloseParen)
+	return err
+}
+
+func (p *parser) parseLabelList() error {
+	if _, err := p.expect(tokenOpenParen); err != nil {
+		return err
+	}
+	for p.peek().kind != tokenCloseParen {
+		if _, err := p.expect(tokenIdentifier); err != nil {
+			return err
+		}
+		if p.peek().kind != tokenComma {
+			break
+		}
+		p.next()
+	}
+	_, err := p.expect(tokenCloseParen)
+	return err
+}
+
+func (p *parser) parseDuration() (time.Duration, error) {
+	t := p.next()
+	if t.kind != tokenDuration {
+		return 0, p.errorf(t, "expected a duration like 5m, found %s", t)
+	}
+	d, err := ParseDuration(t.value)
+	if err != nil {
+		return 0, p.errorf(t, "invalid duration %s", t.value)
+	}
+	return d, nil
+}
+
+var (
+	durationRegexp     = regexp.MustCompile(`^(\d+(ns|us|µs|ms|s|m|h|d|w|y))+$`)
+	durationPartRegexp = regexp.MustCompile(`(\d+)(ns|us|µs|ms|s|m|h|d|w|y)`)
+	durationUnits      = map[string]time.Duration{
+		"ns": time.Nanosecond, "us": time.Microsecond, "µs": time.Microsecond, "ms": time.Millisecond,
+		"s": time.Second, "m": time.Minute, "h": time.Hour,
+		"d": 24 * time.Hour, "w": 7 * 24 * time.Hour, "y": 365 * 24 * time.Hour,
+	}
+)
+
+// ParseDuration parses a LogQL duration like 5m or 1d12h, with the days,
+// weeks and years units of Prometheus
+func ParseDuration(value string) (time.Duration, error) {
+	if !durationRegexp.MatchString(value) {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	var d time.Duration
+	for _, part := range durationPartRegexp.FindAllStringSubmatch(value, -1) {
+		n, err := strconv.ParseInt(part[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		d += time.Duration(n) * durationUnits[part[2]]
+	}
+	return d, nil
+}
+
+var durationOrBytesRegexp = regexp.MustCompile(`^(\d+(\.\d+)?(ns|us|µs|ms|s|m|h|d|w|y|b|kb|kib|mb|mib|gb|gib|tb|tib|pb|pib|eb|eib))+$`)
+
+func isDurationOrBytes(value string) bool {
+	return durationOrBytesRegexp.MatchString(strings.ToLower(value))
+}
+
+func unquote(value string) (string, error) {
+	if strings.HasPrefix(value, "`") {
+		return strings.Trim(value, "`"), nil
+	}
+	return strconv.Unquote(value)
+}
@@ -0,0 +1,139 @@
+package logql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseValidQueries(t *testing.T) {
+	tests := []struct {
+		query             string
+		expectedMetric    bool
+		expectedSelectors int
+	}{
+		{query: `{kubernetes_namespace_name="default"}`, expectedSelectors: 1},
+		{query: `{app="foo", env=~"prod|dev"} |= "error" != "timeout" |~ "(?i)fail"`, expectedSelectors: 1},
+		{query: `{app="foo"} |= "a" or "b" | json | level="error" | line_format "{{.message}}"`, expectedSelectors: 1},
+		{query: `{app="foo"} | json first="servers[0]", ua | logfmt --strict --keep-empty | drop level, method="GET"`, expectedSelectors: 1},
+		{query: `{app="foo"} | pattern "<ip> - <_>" | status >= 500 and duration > 1.5s or (size > 10KB, method!="GET")`, expectedSelectors: 1},
+		{query: `{app="foo"} | regexp "(?P<method>\\w+)" | label_format dst=src, msg="{{.a}}" | decolorize | addr = ip("10.0.0.0/8")`, expectedSelectors: 1},
+		{query: "{app=`foo`} # the foo app\n|= `error`", expectedSelectors: 1},
+		{query: `rate({app="foo"}[5m])`, expectedMetric: true, expectedSelectors: 1},
+		{query: `count_over_time({app="foo"} |= "error" [1h] offset 1d)`, expectedMetric: true, expectedSelectors: 1},
+		{query: `sum by (level) (count_over_time({app="foo"}[5m] | json))`, expectedMetric: true, expectedSelectors: 1},
+		{query: `quantile_over_time(0.99, {app="foo"} | json | unwrap duration(latency) [5m]) by (path)`, expectedMetric: true, expectedSelectors: 1},
+		{query: `topk(10, sum(rate({app="foo"}[1m])) by (pod))`, expectedMetric: true, expectedSelectors: 1},
+		{query: `sum(rate({app="foo"}[5m])) / sum(rate({app="bar"}[5m])) * 100 > bool 5`, expectedMetric: true, expectedSelectors: 2},
+		{query: `sum(rate({app="foo"}[5m])) or vector(0)`, expectedMetric: true, expectedSelectors: 1},
+		{query: `label_replace(rate({app="foo"}[5m]), "dst", "$1", "src", "(.*)")`, expectedMetric: true, expectedSelectors: 1},
+		{query: `-sum(rate({app="foo"}[5m])) ^ 2 ^ 3`, expectedMetric: true, expectedSelectors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := Parse(tt.query)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedMetric, query.Metric)
+			require.Len(t, query.Selectors, tt.expectedSelectors)
+		})
+	}
+}
+
+func TestParseSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		query            string
+		expectedMessage  string
+		expectedPosition Position
+	}{
+		{query: ``, expectedMessage: "empty query", expectedPosition: Position{Offset: 0, Line: 1, Column: 1}},
+		{query: `{app="foo"`, expectedMessage: "expected , or }, found end of query", expectedPosition: Position{Offset: 10, Line: 1, Column: 11}},
+		{query: `{app="foo} |= "a"`, expectedMessage: "unterminated string", expectedPosition: Position{Offset: 16, Line: 1, Column: 17}},
+		{query: `{app="foo}`, expectedMessage: "unterminated string", expectedPosition: Position{Offset: 5, Line: 1, Column: 6}},
+		{query: `{app=""}`, expectedMessage: "the stream selector must have at least one matcher that does not match the empty value", expectedPosition: Position{Offset: 0, Line: 1, Column: 1}},
+		{query: `{app=~"(foo"}`, expectedMessage: "invalid regular expression \"(foo\": error parsing regexp: missing closing ): `^(?:(foo)$`", expectedPosition: Position{Offset: 6, Line: 1, Column: 7}},
+		{query: "{app=\"foo\"}\n  | jsn", expectedMessage: "unknown pipeline stage jsn", expectedPosition: Position{Offset: 16, Line: 2, Column: 5}},
+		{query: `{app="foo"} |= 5`, expectedMessage: `expected line filter string, found "5"`, expectedPosition: Position{Offset: 15, Line: 1, Column: 16}},
+		{query: `{app="foo"} | status > "500"`, expectedMessage: "operator > cannot compare strings", expectedPosition: Position{Offset: 23, Line: 1, Column: 24}},
+		{query: `rate({app="foo"})`, expectedMessage: "rate expects a range like [5m] after the log query", expectedPosition: Position{Offset: 16, Line: 1, Column: 17}},
+		{query: `rate({app="foo"}[5 m])`, expectedMessage: `expected a duration like 5m, found "5"`, expectedPosition: Position{Offset: 17, Line: 1, Column: 18}},
+		{query: `sum_over_time({app="foo"}[5m])`, expectedMessage: "sum_over_time requires an unwrap stage", expectedPosition: Position{Offset: 0, Line: 1, Column: 1}},
+		{query: `count_over_time({app="foo"} | unwrap size [5m])`, expectedMessage: "count_over_time cannot be used with unwrap", expectedPosition: Position{Offset: 0, Line: 1, Column: 1}},
+		{query: `sum({app="foo"})`, expectedMessage: "sum expects a metric query, use a range aggregation like count_over_time on the log query", expectedPosition: Position{Offset: 4, Line: 1, Column: 5}},
+		{query: `{app="foo"} / 2`, expectedMessage: "log queries cannot be used in binary operations, use a metric query", expectedPosition: Position{Offset: 12, Line: 1, Column: 13}},
+		{query: `rates({app="foo"}[5m])`, expectedMessage: "unknown function rates", expectedPosition: Position{Offset: 0, Line: 1, Column: 1}},
+		{query: `rate({app="foo"}[5m])) `, expectedMessage: `unexpected ")"`, expectedPosition: Position{Offset: 21, Line: 1, Column: 22}},
+		{query: `{app="foo"} | logfmt --lenient`, expectedMessage: "unknown logfmt flag --lenient", expectedPosition: Position{Offset: 21, Line: 1, Column: 22}},
+		{query: `{app="foo"} ; drop`, expectedMessage: `unexpected character ';'`, expectedPosition: Position{Offset: 12, Line: 1, Column: 13}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			require.Equal(t, &SyntaxError{Message: tt.expectedMessage, Position: tt.expectedPosition}, err)
+		})
+	}
+}
+
+func TestParseMatcher(t *testing.T) {
+	m, err := ParseMatcher(`kubernetes_namespace_name=~"ns-a|ns-b"`)
+	require.NoError(t, err)
+	require.Equal(t, Matcher{Label: "kubernetes_namespace_name", Operator: "=~", Value: "ns-a|ns-b"}, m)
+
+	_, err = ParseMatcher(`namespace:default`)
+	require.Error(t, err)
+
+	_, err = ParseMatcher(`namespace=~"(a"`)
+	require.Error(t, err)
+}
+
+func TestParseLineFilters(t *testing.T) {
+	query, err := Parse(`{app="foo"} |= "error" or "warning" != ` + "`timeout`" + ` |~ "fail.*" != ip("10.0.0.0/8") | json`)
+	require.NoError(t, err)
+	require.Equal(t, []LineFilter{
+		{Operator: "|=", Values: []string{"error", "warning"}},
+		{Operator: "!=", Values: []string{"timeout"}},
+		{Operator: "|~", Values: []string{"fail.*"}},
+	}, query.Selectors[0].LineFilters)
+	require.Equal(t, 2, query.Selectors[0].Stages)
+
+	query, err = Parse(`{app="foo"}`)
+	require.NoError(t, err)
+	require.Empty(t, query.Selectors[0].LineFilters)
+	require.Zero(t, query.Selectors[0].Stages)
+}
+
+func TestParseRanges(t *testing.T) {
+	query, err := Parse(`sum(rate({app="foo"}[5m] offset 1d)) / sum(count_over_time({app="bar"}[1d12h]))`)
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{5 * time.Minute, 36 * time.Hour}, query.Ranges)
+
+	query, err = Parse(`{app="foo"}`)
+	require.NoError(t, err)
+	require.Empty(t, query.Ranges)
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "5m", expected: 5 * time.Minute},
+		{value: "1m30s", expected: 90 * time.Second},
+		{value: "250ms", expected: 250 * time.Millisecond},
+		{value: "2w", expected: 14 * 24 * time.Hour},
+		{value: "1y", expected: 365 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			d, err := ParseDuration(tt.value)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, d)
+		})
+	}
+
+	_, err := ParseDuration("5")
+	require.Error(t, err)
+}
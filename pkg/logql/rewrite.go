@@ -0,0 +1,90 @@
+package logql
+
+import (
+	"sort"
+	"strings"
+)
+
+// InjectMatchers adds the matchers to every stream selector of query, the
+// matchers already present in a selector are not repeated. The rest of the
+// query is kept as is
+func InjectMatchers(query string, matchers ...Matcher) (string, error) {
+	parsed, err := Parse(query)
+	if err != nil {
+		return "", err
+	}
+
+	selectors := append([]Selector{}, parsed.Selectors...)
+	// rewrite from the end to keep the offsets of the previous selectors
+	sort.Slice(selectors, func(i, j int) bool { return selectors[i].start > selectors[j].start })
+
+	rewritten := query
+	for _, selector := range selectors {
+		added := []string{}
+		for _, m := range matchers {
+			if !selector.has(m) {
+				added = append(added, m.String())
+			}
+		}
+		if len(added) == 0 {
+			continue
+		}
+
+		insertion := strings.Join(added, ", ")
+		if len(selector.Matchers) > 0 {
+			insertion = ", " + insertion
+		}
+		before := strings.TrimRight(rewritten[:selector.end], " \t\n\r")
+		rewritten = before + insertion + rewritten[selector.end:]
+	}
+
+	return rewritten, nil
+}
+
+func (s Selector) has(m Matcher) bool {
+	for _, existing := range s.Matchers {
+		if existing == m {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedString replaces the string literals removed by RedactFilters
+const redactedString = `"<redacted>"`
+
+// RedactFilters replaces the string literals outside of the stream selectors
+// of query, like the line filter and label filter values, and drops the
+// comments. The stream selectors are kept to tell which streams were queried
+func RedactFilters(query string) (string, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	previous, depth := 0, 0
+	for _, t := range tokens {
+		gap := query[previous:t.pos]
+		if strings.Contains(gap, "#") {
+			gap = " "
+		}
+		b.WriteString(gap)
+
+		switch {
+		case t.kind == tokenOpenBrace:
+			depth++
+		case t.kind == tokenCloseBrace && depth > 0:
+			depth--
+		}
+
+		if t.kind == tokenString && depth == 0 {
+			b.WriteString(redactedString)
+		} else {
+			b.WriteString(t.value)
+		}
+		previous = t.pos + len(t.value)
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,96 @@
+package logql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectMatchers(t *testing.T) {
+	namespace := Matcher{Label: "kubernetes_namespace_name", Operator: "=", Value: "my-app"}
+
+	tests := []struct {
+		name          string
+		query         string
+		matchers      []Matcher
+		expectedQuery string
+	}{
+		{
+			name:          "log query",
+			query:         `{app="foo"} |= "error"`,
+			matchers:      []Matcher{namespace},
+			expectedQuery: `{app="foo", kubernetes_namespace_name="my-app"} |= "error"`,
+		},
+		{
+			name:          "every selector of a metric query",
+			query:         `sum(rate({app="foo"}[5m])) / sum(rate({app="bar" }[5m]))`,
+			matchers:      []Matcher{namespace, {Label: "log_type", Operator: "=", Value: "application"}},
+			expectedQuery: `sum(rate({app="foo", kubernetes_namespace_name="my-app", log_type="application"}[5m])) / sum(rate({app="bar", kubernetes_namespace_name="my-app", log_type="application"}[5m]))`,
+		},
+		{
+			name:          "matcher already present",
+			query:         `{kubernetes_namespace_name="my-app"} | json`,
+			matchers:      []Matcher{namespace},
+			expectedQuery: `{kubernetes_namespace_name="my-app"} | json`,
+		},
+		{
+			name:          "escaped value",
+			query:         `{app="foo"}`,
+			matchers:      []Matcher{{Label: "msg", Operator: "=~", Value: `a"b`}},
+			expectedQuery: `{app="foo", msg=~"a\"b"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := InjectMatchers(tt.query, tt.matchers...)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedQuery, query)
+
+			_, err = Parse(query)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestInjectMatchersInvalidQuery(t *testing.T) {
+	_, err := InjectMatchers(`{app="foo"`, Matcher{Label: "a", Operator: "=", Value: "b"})
+	require.Error(t, err)
+}
+
+func TestRedactFilters(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedQuery string
+	}{
+		{
+			name:          "stream selector only",
+			query:         `{app="foo", namespace=~"a|b"}`,
+			expectedQuery: `{app="foo", namespace=~"a|b"}`,
+		},
+		{
+			name:          "line and label filters",
+			query:         `{app="foo"} |= "password" != ` + "`secret`" + ` | json | user="alice"`,
+			expectedQuery: `{app="foo"} |= "<redacted>" != "<redacted>" | json | user="<redacted>"`,
+		},
+		{
+			name:          "metric query",
+			query:         `sum(count_over_time({app="foo"} |~ "token=.*" [5m]))`,
+			expectedQuery: `sum(count_over_time({app="foo"} |~ "<redacted>" [5m]))`,
+		},
+		{
+			name:          "comments",
+			query:         "{app=\"foo\"} # looking for alice\n|= \"alice\"",
+			expectedQuery: `{app="foo"} |= "<redacted>"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := RedactFilters(tt.query)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedQuery, query)
+		})
+	}
+}
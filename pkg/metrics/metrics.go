@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "logging_view_plugin"
+
+var (
+	// RequestsTotal counts the served requests by route, method and status code
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests served by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	// RequestDuration observes the request latency by route and method
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests by route and method.",
+		Buckets:   []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route", "method"})
+
+	// RequestsInFlight is the number of requests being served
+	RequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of HTTP requests being served.",
+	})
+
+	// UpstreamErrorsTotal counts the failed upstream requests by upstream and
+	// reason
+	UpstreamErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Number of failed upstream requests by upstream and reason.",
+	}, []string{"upstream", "reason"})
+
+	// TLSReloadsTotal counts the serving certificate and client CA reloads by
+	// source and result
+	TLSReloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tls_certificate_reloads_total",
+		Help:      "Number of serving certificate and client CA reloads by source and result.",
+	}, []string{"source", "result"})
+
+	// CacheRequestsTotal counts the cacheable proxied requests by result: hit,
+	// disk_hit, miss or bypass
+	CacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Number of cacheable proxied requests by cache result.",
+	}, []string{"result"})
+
+	// DatasourceClockSkew is the offset of the clock of a datasource from the
+	// clock of the plugin, measured by the self-test
+	DatasourceClockSkew = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "datasource_clock_skew_seconds",
+		Help:      "Offset in seconds of the clock of a datasource from the clock of the plugin.",
+	}, []string{"datasource"})
+
+	// AuditWebhookRecordsTotal counts the audit records mirrored to the
+	// webhook by result: sent, failed or dropped
+	AuditWebhookRecordsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_webhook_records_total",
+		Help:      "Number of audit records mirrored to the webhook by result.",
+	}, []string{"result"})
+
+	// DiskCacheBytes is the size of the query responses cached on disk
+	DiskCacheBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "disk_cache_bytes",
+		Help:      "Size in bytes of the query responses cached on disk.",
+	})
+
+	// UpstreamRejectedTotal counts the requests not sent upstream by upstream
+	// and reason: circuit_open or max_in_flight
+	UpstreamRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_rejected_requests_total",
+		Help:      "Number of requests not sent upstream by upstream and reason.",
+	}, []string{"upstream", "reason"})
+
+	// UpstreamRetriesTotal counts the upstream requests sent again by
+	// upstream and reason: error or the retried status code
+	UpstreamRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_retries_total",
+		Help:      "Number of upstream requests retried by upstream and reason.",
+	}, []string{"upstream", "reason"})
+
+	// UpstreamDialsTotal counts the connections dialed to the upstreams by
+	// upstream and result: success or error
+	UpstreamDialsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_dials_total",
+		Help:      "Number of connections dialed to the upstreams by upstream and result.",
+	}, []string{"upstream", "result"})
+
+	// UpstreamDialDuration observes the latency of the upstream dials by
+	// upstream
+	UpstreamDialDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_dial_duration_seconds",
+		Help:      "Latency of the connections dialed to the upstreams by upstream.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 5},
+	}, []string{"upstream"})
+
+	// UpstreamConnectionsReusedTotal counts the upstream requests sent on an
+	// idle connection of the pool by upstream
+	UpstreamConnectionsReusedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_connections_reused_total",
+		Help:      "Number of upstream requests sent on a reused connection by upstream.",
+	}, []string{"upstream"})
+
+	// ShadowQueriesTotal counts the queries mirrored to a shadow upstream by
+	// upstream, shadow and result: match, status_mismatch, results_mismatch,
+	// error or skipped
+	ShadowQueriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "shadow_queries_total",
+		Help:      "Number of queries mirrored to a shadow upstream by upstream, shadow and comparison result.",
+	}, []string{"upstream", "shadow", "result"})
+
+	// CircuitBreakerState is the state of the upstream circuit breakers: 0
+	// closed, 1 half-open and 2 open
+	CircuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_state",
+		Help:      "State of the upstream circuit breakers, 0 closed, 1 half-open and 2 open.",
+	}, []string{"upstream"})
+
+	// RateLimitedRequestsTotal counts the requests rejected by the rate
+	// limiter by route
+	RateLimitedRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Number of requests rejected by the rate limiter by route.",
+	}, []string{"route"})
+
+	// LokiErrorsTotal counts the well-known Loki errors by upstream and error
+	// code
+	LokiErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loki_errors_total",
+		Help:      "Number of well-known Loki errors, like the limits of the queries, by upstream and error code.",
+	}, []string{"upstream", "code"})
+
+	// FairnessRejectedTotal counts the queries rejected by the fair scheduler
+	// by route and reason
+	FairnessRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fairness_rejected_total",
+		Help:      "Number of queries rejected by the fair scheduler by route and reason.",
+	}, []string{"route", "reason"})
+
+	// FairnessQueueWait observes the time the queries are queued by the fair
+	// scheduler before they are sent
+	FairnessQueueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "fairness_queue_wait_seconds",
+		Help:      "Time the queries are queued by the fair scheduler before they are sent.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	})
+
+	// PluginConfigReloadsTotal counts the plugin config file reloads by result
+	PluginConfigReloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "plugin_config_reloads_total",
+		Help:      "Number of plugin config file reloads by result.",
+	}, []string{"result"})
+
+	// CertExpirySeconds is the time left before the serving certificates
+	// expire by certificate source
+	CertExpirySeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cert_expiry_seconds",
+		Help:      "Seconds left before the serving certificates expire by certificate source, negative once expired.",
+	}, []string{"certificate"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		RequestsTotal,
+		RequestDuration,
+		RequestsInFlight,
+		UpstreamErrorsTotal,
+		TLSReloadsTotal,
+		PluginConfigReloadsTotal,
+		CacheRequestsTotal,
+		DiskCacheBytes,
+		DatasourceClockSkew,
+		AuditWebhookRecordsTotal,
+		RateLimitedRequestsTotal,
+		FairnessRejectedTotal,
+		FairnessQueueWait,
+		UpstreamRejectedTotal,
+		UpstreamRetriesTotal,
+		UpstreamDialsTotal,
+		UpstreamDialDuration,
+		UpstreamConnectionsReusedTotal,
+		ShadowQueriesTotal,
+		LokiErrorsTotal,
+		CircuitBreakerState,
+		CertExpirySeconds,
+	)
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,396 @@
+// Package objectstore uploads objects to an S3-compatible bucket and signs
+// the URLs downloading them, with the AWS signature version 4
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPartSize is the size of the parts of the multipart uploads, the
+	// parts are buffered in memory
+	DefaultPartSize = 16 << 20
+	// MinPartSize is the minimum size of the parts but the last one
+	MinPartSize = 5 << 20
+	// MaxExpiry is the longest validity of a presigned URL
+	MaxExpiry = 7 * 24 * time.Hour
+
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// Config is the bucket objects are stored to, and the credentials of the
+// uploads and of the presigned URLs
+type Config struct {
+	// Endpoint is the URL of the S3 API, like https://s3.us-east-1.amazonaws.com
+	Endpoint string
+	Bucket   string
+	Region   string
+	// PathStyle addresses the bucket in the path of the URLs instead of in
+	// their host, as most S3-compatible stores expect
+	PathStyle       bool
+	AccessKeyID     string
+	SecretAccessKey string
+	// PartSize is the size of the parts of the uploads, DefaultPartSize by
+	// default
+	PartSize int
+	// Client sends the requests, http.DefaultClient by default
+	Client *http.Client
+}
+
+// Client stores the objects of a bucket
+type Client struct {
+	cfg      Config
+	endpoint *url.URL
+	now      func() time.Time
+}
+
+// Error is an error response of the S3 API
+type Error struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("object storage responded with status %d", e.Status)
+	}
+	return fmt.Sprintf("object storage responded with status %d: %s: %s", e.Status, e.Code, e.Message)
+}
+
+// NewClient returns a client of the bucket of cfg
+func NewClient(cfg Config) (*Client, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, errors.New("the bucket and the region are required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("the access key ID and the secret access key are required")
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = DefaultPartSize
+	}
+	if cfg.PartSize < MinPartSize {
+		return nil, fmt.Errorf("the part size cannot be lower than %d bytes", MinPartSize)
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Client{cfg: cfg, endpoint: endpoint, now: time.Now}, nil
+}
+
+// objectURL returns the URL of the object key with the query
+func (c *Client) objectURL(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	u.RawQuery = ""
+	if c.cfg.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.cfg.Bucket + "/" + key
+	} else {
+		u.Host = c.cfg.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	u.RawPath = canonicalURI(u.Path)
+	if query != nil {
+		u.RawQuery = canonicalQuery(query)
+	}
+	return &u
+}
+
+// Upload stores the content read from body as the object key, in one
+// request when it fits in a part and with a multipart upload otherwise. The
+// multipart upload is aborted when body or a part fails
+func (c *Client) Upload(ctx context.Context, key string, contentType string, body io.Reader) error {
+	part := make([]byte, c.cfg.PartSize)
+	n, err := io.ReadFull(body, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return c.put(ctx, key, contentType, part[:n])
+	}
+	if err != nil {
+		return err
+	}
+
+	uploadID, err := c.createMultipartUpload(ctx, key, contentType)
+	if err != nil {
+		return err
+	}
+	completed := completeMultipartUpload{}
+	for number := 1; ; number++ {
+		etag, err := c.uploadPart(ctx, key, uploadID, number, part[:n])
+		if err != nil {
+			c.abortMultipartUpload(key, uploadID)
+			return err
+		}
+		completed.Parts = append(completed.Parts, completedPart{PartNumber: number, ETag: etag})
+
+		n, err = io.ReadFull(body, part)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			c.abortMultipartUpload(key, uploadID)
+			return err
+		}
+	}
+
+	if err := c.completeMultipartUpload(ctx, key, uploadID, completed); err != nil {
+		c.abortMultipartUpload(key, uploadID)
+		return err
+	}
+	return nil
+}
+
+// PresignGet returns the URL downloading the object key without credentials
+// until expiry
+func (c *Client) PresignGet(key string, expiry time.Duration) (string, error) {
+	if expiry <= 0 || expiry > MaxExpiry {
+		return "", fmt.Errorf("the expiry must be between 1s and %s", MaxExpiry)
+	}
+	now := c.now().UTC()
+	query := url.Values{
+		"X-Amz-Algorithm":     {signingAlgorithm},
+		"X-Amz-Credential":    {c.cfg.AccessKeyID + "/" + c.scope(now)},
+		"X-Amz-Date":          {now.Format(amzDateFormat)},
+		"X-Amz-Expires":       {strconv.Itoa(int(expiry / time.Second))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	u := c.objectURL(key, query)
+	canonicalRequest := strings.Join([]string{http.MethodGet, u.RawPath, u.RawQuery, "host:" + u.Host + "\n", "host", unsignedPayload}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + c.signature(now, canonicalRequest)
+	return u.String(), nil
+}
+
+func (c *Client) put(ctx context.Context, key string, contentType string, content []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.objectURL(key, nil).String(), bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	_, err = c.do(req, content, nil)
+	return err
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+func (c *Client) createMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.objectURL(key, url.Values{"uploads": {""}}).String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	result := initiateMultipartUploadResult{}
+	if _, err := c.do(req, nil, &result); err != nil {
+		return "", err
+	}
+	if result.UploadID == "" {
+		return "", errors.New("object storage returned no upload ID")
+	}
+	return result.UploadID, nil
+}
+
+func (c *Client) uploadPart(ctx context.Context, key string, uploadID string, number int, content []byte) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.objectURL(key, query).String(), bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	res, err := c.do(req, content, nil)
+	if err != nil {
+		return "", err
+	}
+	return res.Header.Get("ETag"), nil
+}
+
+func (c *Client) completeMultipartUpload(ctx context.Context, key string, uploadID string, completed completeMultipartUpload) error {
+	content, err := xml.Marshal(completed)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.objectURL(key, url.Values{"uploadId": {uploadID}}).String(), bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	// the completion can fail after the status is sent, with an error body
+	result := struct {
+		XMLName xml.Name
+		Error
+	}{}
+	res, err := c.do(req, content, &result)
+	if err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		result.Error.Status = res.StatusCode
+		return &result.Error
+	}
+	return nil
+}
+
+// abortMultipartUpload deletes the parts of an upload that failed, even
+// when the context of the upload is done
+func (c *Client) abortMultipartUpload(key string, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.objectURL(key, url.Values{"uploadId": {uploadID}}).String(), nil)
+	if err != nil {
+		return
+	}
+	c.do(req, nil, nil)
+}
+
+// do signs and sends req with its content, and decodes the XML response
+// into result when it is not nil
+func (c *Client) do(req *http.Request, content []byte, result interface{}) (*http.Response, error) {
+	c.sign(req, content)
+	res, err := c.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		apiErr := &Error{Status: res.StatusCode}
+		xml.Unmarshal(body, apiErr)
+		return nil, apiErr
+	}
+	if result != nil {
+		if err := xml.Unmarshal(body, result); err != nil {
+			return nil, fmt.Errorf("cannot decode the object storage response: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// sign adds the Authorization header of req, signing its host and its
+// headers with the hash of content
+func (c *Client) sign(req *http.Request, content []byte) {
+	now := c.now().UTC()
+	payloadHash := sha256.Sum256(content)
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.RawPath
+	if path == "" {
+		path = canonicalURI(req.URL.Path)
+	}
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.RawQuery, canonicalHeaders, signedHeaders, hex.EncodeToString(payloadHash[:])}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, c.cfg.AccessKeyID, c.scope(now), signedHeaders, c.signature(now, canonicalRequest)))
+}
+
+// scope is the credential scope of the requests signed at now
+func (c *Client) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + c.cfg.Region + "/s3/aws4_request"
+}
+
+// signature signs canonicalRequest with the key derived from the secret
+// access key for the day of now
+func (c *Client) signature(now time.Time, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{signingAlgorithm, now.Format(amzDateFormat), c.scope(now), hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.cfg.SecretAccessKey), now.Format("20060102"))
+	for _, part := range []string{c.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalURI escapes every byte of path but the unreserved characters and
+// the slashes
+func canonicalURI(path string) string {
+	return uriEncode(path, false)
+}
+
+// canonicalQuery sorts the parameters of query by name and escapes them
+func canonicalQuery(query url.Values) string {
+	pairs := [][2]string{}
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, [2]string{uriEncode(name, true), uriEncode(value, true)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	encoded := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		encoded = append(encoded, pair[0]+"="+pair[1])
+	}
+	return strings.Join(encoded, "&")
+}
+
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// states of the circuit breaker
+const (
+	BreakerClosed   = "closed"
+	BreakerHalfOpen = "half-open"
+	BreakerOpen     = "open"
+)
+
+// breakerStateValues are the values of the circuit breaker state metric
+var breakerStateValues = map[string]float64{
+	BreakerClosed:   0,
+	BreakerHalfOpen: 1,
+	BreakerOpen:     2,
+}
+
+// BreakerConfig opens the circuit after FailureThreshold consecutive upstream
+// failures, the requests are then rejected for OpenDuration before a single
+// request is let through to probe the upstream
+type BreakerConfig struct {
+	// Name identifies the upstream in the metrics
+	Name             string
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+// Breaker is a circuit breaker of the requests sent to an upstream, a nil
+// breaker lets every request through
+type Breaker struct {
+	cfg      BreakerConfig
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	// probeAt is the start of the request probing a half-open upstream
+	probeAt time.Time
+	now     func() time.Time
+}
+
+// NewBreaker returns the circuit breaker of cfg, nil when FailureThreshold is
+// not set
+func NewBreaker(cfg BreakerConfig) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		return nil
+	}
+	b := &Breaker{cfg: cfg, state: BreakerClosed, now: time.Now}
+	b.setState(BreakerClosed)
+	return b
+}
+
+// State returns the state of the breaker, closed when b is nil
+func (b *Breaker) State() string {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow returns an error with the time to wait when the circuit is open,
+// the first request after OpenDuration probes the upstream. A probe without
+// result does not block the others longer than OpenDuration
+func (b *Breaker) allow() *Error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case BreakerOpen:
+		if wait := b.openedAt.Add(b.cfg.OpenDuration).Sub(now); wait > 0 {
+			return b.openError(wait)
+		}
+		b.setState(BreakerHalfOpen)
+	case BreakerHalfOpen:
+		if wait := b.probeAt.Add(b.cfg.OpenDuration).Sub(now); wait > 0 {
+			return b.openError(wait)
+		}
+	default:
+		return nil
+	}
+
+	b.probeAt = now
+	return nil
+}
+
+func (b *Breaker) openError(wait time.Duration) *Error {
+	metrics.UpstreamRejectedTotal.WithLabelValues(b.cfg.Name, "circuit_open").Inc()
+	retryAfter := time.Duration(math.Ceil(wait.Seconds())) * time.Second
+	return &Error{
+		Status:     http.StatusServiceUnavailable,
+		Code:       "UpstreamUnavailable",
+		Message:    fmt.Sprintf("Loki is unavailable, retry in %s", retryAfter),
+		RetryAfter: retryAfter,
+	}
+}
+
+// record updates the breaker with the result of an upstream request, the
+// server errors and the failed connections are failures
+func (b *Breaker) record(failed bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			log.Infof("%s is available again, closing the circuit", b.cfg.Name)
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.cfg.FailureThreshold) {
+		log.Warnf("%s failed %d times in a row, opening the circuit for %s", b.cfg.Name, b.failures, b.cfg.OpenDuration)
+		b.openedAt = b.now()
+		b.setState(BreakerOpen)
+	}
+}
+
+func (b *Breaker) setState(state string) {
+	b.state = state
+	metrics.CircuitBreakerState.WithLabelValues(b.cfg.Name).Set(breakerStateValues[state])
+}
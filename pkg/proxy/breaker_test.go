@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(BreakerConfig{Name: "test", FailureThreshold: 2, OpenDuration: 10 * time.Second})
+	b.now = func() time.Time { return now }
+
+	require.Nil(t, b.allow())
+	b.record(true)
+	b.record(false)
+	b.record(true)
+	require.Equal(t, BreakerClosed, b.State())
+
+	b.record(true)
+	require.Equal(t, BreakerOpen, b.State())
+
+	now = now.Add(2500 * time.Millisecond)
+	err := b.allow()
+	require.NotNil(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, err.Status)
+	require.Equal(t, 8*time.Second, err.RetryAfter)
+
+	// a single request probes the upstream once the circuit is half-open
+	now = now.Add(10 * time.Second)
+	require.Nil(t, b.allow())
+	require.Equal(t, BreakerHalfOpen, b.State())
+	require.NotNil(t, b.allow())
+
+	b.record(true)
+	require.Equal(t, BreakerOpen, b.State())
+
+	now = now.Add(10 * time.Second)
+	require.Nil(t, b.allow())
+	b.record(false)
+	require.Equal(t, BreakerClosed, b.State())
+	require.Nil(t, b.allow())
+}
+
+func TestBreakerHalfOpenProbeWithoutResult(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(BreakerConfig{Name: "test", FailureThreshold: 1, OpenDuration: time.Second})
+	b.now = func() time.Time { return now }
+
+	b.record(true)
+	now = now.Add(time.Second)
+	require.Nil(t, b.allow())
+	require.NotNil(t, b.allow())
+
+	now = now.Add(time.Second)
+	require.Nil(t, b.allow())
+}
+
+func TestNilBreaker(t *testing.T) {
+	var b *Breaker
+	require.Nil(t, NewBreaker(BreakerConfig{}))
+	require.Nil(t, b.allow())
+	b.record(true)
+	require.Equal(t, BreakerClosed, b.State())
+}
+
+func TestProxyBreaker(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	breaker := NewBreaker(BreakerConfig{Name: "test", FailureThreshold: 2, OpenDuration: time.Minute})
+	var rejected *Error
+	p := New(Config{URL: upstreamURL, Breaker: breaker, ErrorHandler: func(w http.ResponseWriter, r *http.Request, err *Error) {
+		rejected = err
+		w.WriteHeader(err.Status)
+	}})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/labels", nil))
+	}
+
+	require.Equal(t, 2, calls)
+	require.NotNil(t, rejected)
+	require.Equal(t, http.StatusServiceUnavailable, rejected.Status)
+	require.Equal(t, time.Minute, rejected.RetryAfter)
+}
+
+func TestProxyMaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	p := New(Config{URL: upstreamURL, MaxInFlight: 1})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/labels", nil))
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/labels", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	close(release)
+	wg.Wait()
+}
@@ -0,0 +1,250 @@
+package proxy
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// CacheHeader reports in the responses whether they were served from the
+// cache
+const CacheHeader = "X-Cache"
+
+// cachedEndpoints are the Loki endpoints whose responses are cached
+var cachedEndpoints = map[string]bool{
+	"/loki/api/v1/query_range":  true,
+	"/loki/api/v1/query":        true,
+	"/loki/api/v1/labels":       true,
+	"/loki/api/v1/series":       true,
+	"/loki/api/v1/index/stats":  true,
+	"/loki/api/v1/index/volume": true,
+}
+
+// cachedHeaders are the upstream response headers stored with the body
+var cachedHeaders = []string{"Content-Type", "Content-Encoding"}
+
+// CacheConfig enables the cache of the query responses when TTL and
+// MaxEntries are set
+type CacheConfig struct {
+	TTL        time.Duration
+	MaxEntries int
+	// MaxEntrySize is the size of the largest cached response body in bytes
+	MaxEntrySize int64
+	// Scope returns the part of the cache key identifying the users allowed
+	// to share the responses of r
+	Scope func(r *http.Request) string
+	// Disk also stores the responses of the queries over a completed range
+	// when set, they are served again after a restart
+	Disk *DiskCache
+}
+
+type cachedResponse struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// responseCache is a LRU cache of the upstream responses
+type responseCache struct {
+	cfg CacheConfig
+	// cacheable tells whether the responses of an endpoint are cached, the
+	// cachedEndpoints by default
+	cacheable func(endpoint string) bool
+	// upstream tells apart the responses of the upstreams sharing the disk
+	// cache
+	upstream string
+	mu       sync.Mutex
+	lru      *list.List
+	entries  map[string]*list.Element
+}
+
+type cacheKeyKey struct{}
+
+type diskCacheKeyKey struct{}
+
+func newResponseCache(cfg CacheConfig) *responseCache {
+	if cfg.TTL <= 0 || cfg.MaxEntries <= 0 {
+		return nil
+	}
+	return &responseCache{
+		cfg:       cfg,
+		cacheable: func(endpoint string) bool { return cachedEndpoints[endpoint] },
+		lru:       list.New(),
+		entries:   map[string]*list.Element{},
+	}
+}
+
+func (c *responseCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*cachedResponse)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	return entry, true
+}
+
+func (c *responseCache) set(entry *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[entry.key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.cfg.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// key returns the cache key of a request, the query parameters are sorted so
+// that equivalent requests share the same key
+func (c *responseCache) key(r *http.Request, tenant string, endpoint string) string {
+	params := r.URL.Query()
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	normalized := url.Values{}
+	for _, name := range names {
+		for _, value := range params[name] {
+			normalized.Add(name, strings.TrimSpace(value))
+		}
+	}
+
+	scope := ""
+	if c.cfg.Scope != nil {
+		scope = c.cfg.Scope(r)
+	}
+
+	return strings.Join([]string{scope, tenant, endpoint, normalized.Encode(), r.Header.Get("Accept-Encoding")}, "\x00")
+}
+
+// serve writes the cached response of r when there is one, the requests
+// sent with Cache-Control: no-cache skip the cache but refresh it. It returns
+// the request to proxy when the response is not served from the cache
+func (c *responseCache) serve(w http.ResponseWriter, r *http.Request, tenant string, endpoint string) (*http.Request, bool) {
+	if !c.cacheable(endpoint) {
+		return r, false
+	}
+
+	key := c.key(r, tenant, endpoint)
+	r = r.WithContext(context.WithValue(r.Context(), cacheKeyKey{}, key))
+	diskKey := ""
+	if c.cfg.Disk != nil && c.cfg.Disk.historical(r, endpoint) {
+		diskKey = strings.Join([]string{c.upstream, key}, "\x00")
+		r = r.WithContext(context.WithValue(r.Context(), diskCacheKeyKey{}, diskKey))
+	}
+
+	cacheControl := r.Header.Get("Cache-Control")
+	if strings.Contains(cacheControl, "no-cache") || strings.Contains(cacheControl, "no-store") {
+		metrics.CacheRequestsTotal.WithLabelValues("bypass").Inc()
+		w.Header().Set(CacheHeader, "BYPASS")
+		return r, false
+	}
+
+	result := "hit"
+	entry, ok := c.get(key)
+	if !ok && diskKey != "" {
+		// the responses read from disk are kept in memory as well
+		if entry, ok = c.cfg.Disk.get(diskKey); ok {
+			result = "disk_hit"
+			entry.key = key
+			entry.expires = time.Now().Add(c.cfg.TTL)
+			c.set(entry)
+		}
+	}
+	if !ok {
+		metrics.CacheRequestsTotal.WithLabelValues("miss").Inc()
+		w.Header().Set(CacheHeader, "MISS")
+		return r, false
+	}
+
+	metrics.CacheRequestsTotal.WithLabelValues(result).Inc()
+	for name, values := range entry.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(CacheHeader, "HIT")
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+
+	return r, true
+}
+
+// store caches the successful upstream response of a cacheable request, the
+// bodies larger than MaxEntrySize are streamed without being cached. The
+// responses over a completed range are stored on disk as well
+func (c *responseCache) store(resp *http.Response) error {
+	key, ok := resp.Request.Context().Value(cacheKeyKey{}).(string)
+	if !ok || resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	if c.cfg.MaxEntrySize > 0 && resp.ContentLength > c.cfg.MaxEntrySize {
+		return nil
+	}
+
+	limit := c.cfg.MaxEntrySize
+	if limit <= 0 {
+		limit = 1 << 20
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > limit {
+		// send what was read followed by the rest of the body
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := http.Header{}
+	for _, name := range cachedHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			header[name] = values
+		}
+	}
+
+	entry := &cachedResponse{key: key, status: resp.StatusCode, header: header, body: body, expires: time.Now().Add(c.cfg.TTL)}
+	c.set(entry)
+	if diskKey, ok := resp.Request.Context().Value(diskCacheKeyKey{}).(string); ok {
+		if err := c.cfg.Disk.set(diskKey, entry); err != nil {
+			log.WithError(err).Warn("cannot cache the response on disk")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newCountingUpstream(t *testing.T, body string) (*httptest.Server, *int32) {
+	var count int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("query") == "error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream, &count
+}
+
+func TestCache(t *testing.T) {
+	type request struct {
+		path          string
+		authorization string
+		cacheControl  string
+		expectedCache string
+	}
+
+	tests := []struct {
+		name             string
+		cache            CacheConfig
+		body             string
+		requests         []request
+		expectedUpstream int32
+	}{
+		{
+			name:  "hit",
+			cache: CacheConfig{TTL: time.Minute, MaxEntries: 10},
+			requests: []request{
+				{path: "/application/loki/api/v1/query_range?query=a&start=1&end=2", expectedCache: "MISS"},
+				{path: "/application/loki/api/v1/query_range?end=2&query=a&start=1", expectedCache: "HIT"},
+			},
+			expectedUpstream: 1,
+		},
+		{
+			name:  "different tenants",
+			cache: CacheConfig{TTL: time.Minute, MaxEntries: 10},
+			requests: []request{
+				{path: "/application/loki/api/v1/query_range?query=a", expectedCache: "MISS"},
+				{path: "/audit/loki/api/v1/query_range?query=a", expectedCache: "MISS"},
+			},
+			expectedUpstream: 2,
+		},
+		{
+			name:  "bypass",
+			cache: CacheConfig{TTL: time.Minute, MaxEntries: 10},
+			requests: []request{
+				{path: "/application/loki/api/v1/query?query=a", expectedCache: "MISS"},
+				{path: "/application/loki/api/v1/query?query=a", cacheControl: "no-cache", expectedCache: "BYPASS"},
+			},
+			expectedUpstream: 2,
+		},
+		{
+			name:  "errors are not cached",
+			cache: CacheConfig{TTL: time.Minute, MaxEntries: 10},
+			requests: []request{
+				{path: "/application/loki/api/v1/query?query=error", expectedCache: "MISS"},
+				{path: "/application/loki/api/v1/query?query=error", expectedCache: "MISS"},
+			},
+			expectedUpstream: 2,
+		},
+		{
+			name:  "uncached endpoint",
+			cache: CacheConfig{TTL: time.Minute, MaxEntries: 10},
+			requests: []request{
+				{path: "/application/loki/api/v1/label/namespace/values"},
+				{path: "/application/loki/api/v1/label/namespace/values"},
+			},
+			expectedUpstream: 2,
+		},
+		{
+			name:  "evicted entry",
+			cache: CacheConfig{TTL: time.Minute, MaxEntries: 1},
+			requests: []request{
+				{path: "/application/loki/api/v1/query?query=a", expectedCache: "MISS"},
+				{path: "/application/loki/api/v1/query?query=b", expectedCache: "MISS"},
+				{path: "/application/loki/api/v1/query?query=a", expectedCache: "MISS"},
+			},
+			expectedUpstream: 3,
+		},
+		{
+			name:  "oversized response",
+			cache: CacheConfig{TTL: time.Minute, MaxEntries: 10, MaxEntrySize: 8},
+			body:  `{"status":"success","data":{}}`,
+			requests: []request{
+				{path: "/application/loki/api/v1/query?query=a", expectedCache: "MISS"},
+				{path: "/application/loki/api/v1/query?query=a", expectedCache: "MISS"},
+			},
+			expectedUpstream: 2,
+		},
+		{
+			name: "scoped",
+			cache: CacheConfig{TTL: time.Minute, MaxEntries: 10, Scope: func(r *http.Request) string {
+				return r.Header.Get("Authorization")
+			}},
+			requests: []request{
+				{path: "/application/loki/api/v1/query?query=a", authorization: "Bearer a", expectedCache: "MISS"},
+				{path: "/application/loki/api/v1/query?query=a", authorization: "Bearer b", expectedCache: "MISS"},
+				{path: "/application/loki/api/v1/query?query=a", authorization: "Bearer a", expectedCache: "HIT"},
+			},
+			expectedUpstream: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := tc.body
+			if body == "" {
+				body = `{"status":"success"}`
+			}
+			upstream, count := newCountingUpstream(t, body)
+			upstreamURL, err := url.Parse(upstream.URL)
+			require.NoError(t, err)
+
+			p := New(Config{URL: upstreamURL, Cache: tc.cache})
+
+			for _, req := range tc.requests {
+				r := httptest.NewRequest(http.MethodGet, req.path, nil)
+				if req.authorization != "" {
+					r.Header.Set("Authorization", req.authorization)
+				}
+				if req.cacheControl != "" {
+					r.Header.Set("Cache-Control", req.cacheControl)
+				}
+				w := httptest.NewRecorder()
+
+				p.ServeHTTP(w, r)
+
+				require.Equal(t, req.expectedCache, w.Header().Get(CacheHeader), req.path)
+				require.Equal(t, body, w.Body.String())
+				if !strings.Contains(req.path, "error") {
+					require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				}
+			}
+			require.Equal(t, tc.expectedUpstream, atomic.LoadInt32(count))
+		})
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := newResponseCache(CacheConfig{TTL: time.Minute, MaxEntries: 10})
+	c.set(&cachedResponse{key: "a", status: http.StatusOK, expires: time.Now().Add(-time.Second)})
+	c.set(&cachedResponse{key: "b", status: http.StatusOK, expires: time.Now().Add(time.Minute)})
+
+	_, ok := c.get("a")
+	require.False(t, ok)
+	require.Equal(t, 1, c.lru.Len())
+
+	_, ok = c.get("b")
+	require.True(t, ok)
+}
+
+func TestCacheDisabled(t *testing.T) {
+	require.Nil(t, newResponseCache(CacheConfig{}))
+	require.Nil(t, newResponseCache(CacheConfig{TTL: time.Minute}))
+}
+
+func TestMetadataCache(t *testing.T) {
+	upstream, count := newCountingUpstream(t, `{"status":"success","data":[]}`)
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	p := NewMetadata(Config{URL: upstreamURL, Cache: CacheConfig{TTL: time.Minute, MaxEntries: 10}})
+
+	for _, path := range []string{
+		"/application/loki/api/v1/labels?start=1",
+		"/application/loki/api/v1/label/namespace/values?start=1",
+		"/application/loki/api/v1/series?match[]=%7Bnamespace%3D%22a%22%7D",
+	} {
+		for _, expectedCache := range []string{"MISS", "HIT"} {
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			require.Equal(t, http.StatusOK, w.Code, path)
+			require.Equal(t, expectedCache, w.Header().Get(CacheHeader), path)
+		}
+	}
+	require.Equal(t, int32(3), atomic.LoadInt32(count))
+
+	// the metadata proxy does not serve the log queries
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?query=a", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, int32(3), atomic.LoadInt32(count))
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/openshift/logging-view-plugin/pkg/tracing"
+)
+
+// maxClientResponseSize bounds the size of the responses decoded by Client
+const maxClientResponseSize = 64 << 20
+
+// Client sends requests to the Loki API with the bearer token of the user and
+// decodes the responses, unlike Proxy which streams them back
+type Client struct {
+	cfg    Config
+	client *http.Client
+}
+
+// NewClient builds a Loki API client
+func NewClient(cfg Config) *Client {
+	return &Client{cfg: cfg, client: &http.Client{Transport: cfg.transport()}}
+}
+
+// get sends a request to the endpoint of tenant and decodes the JSON response
+// in v, the headers of r like Authorization are forwarded to Loki
+func (c *Client) get(r *http.Request, tenant string, endpoint string, params url.Values, v interface{}) error {
+	if !tenantRegexp.MatchString(tenant) {
+		return &Error{Status: http.StatusBadRequest, Code: "InvalidTenant", Message: fmt.Sprintf("invalid tenant %q", tenant)}
+	}
+
+	r, tokenErr := c.cfg.withUpstreamToken(r)
+	if tokenErr != nil {
+		return tokenErr
+	}
+
+	ctx := r.Context()
+	if timeout := c.cfg.timeout(tenant, params); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ctx, span := c.cfg.Tracer.Start(ctx, "loki "+endpoint, tracing.KindClient, upstreamAttributes(tenant, endpoint, params)...)
+	defer span.End()
+
+	upstreamURL := *c.cfg.URL
+	upstreamURL.Path = c.cfg.upstreamPath(tenant, endpoint)
+	upstreamURL.RawPath = ""
+	upstreamURL.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstreamURL.String(), nil)
+	if err != nil {
+		return &Error{Status: http.StatusInternalServerError, Code: "InternalError", Message: "cannot build the Loki request", Err: err}
+	}
+	req.Header = c.cfg.upstreamHeaders(r.WithContext(ctx), tenant)
+	// the response is decoded here, let the transport handle the compression
+	req.Header.Del("Accept-Encoding")
+
+	if breakerErr := c.cfg.Breaker.allow(); breakerErr != nil {
+		return breakerErr
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			c.cfg.Breaker.record(true)
+		}
+		span.SetError(err)
+		metrics.UpstreamErrorsTotal.WithLabelValues("loki", "unavailable").Inc()
+		status := http.StatusBadGateway
+		if ctx.Err() == context.DeadlineExceeded {
+			status = http.StatusGatewayTimeout
+		}
+		return &Error{Status: status, Code: "UpstreamUnavailable", Message: "cannot reach Loki", Err: err}
+	}
+	defer resp.Body.Close()
+	c.cfg.Breaker.record(resp.StatusCode >= http.StatusInternalServerError)
+
+	span.SetAttributes(tracing.Int("http.status_code", int64(resp.StatusCode)))
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode >= http.StatusInternalServerError {
+			metrics.UpstreamErrorsTotal.WithLabelValues("loki", strconv.Itoa(resp.StatusCode)).Inc()
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxLokiErrorSize))
+		if lokiErr := lokiError(c.cfg.Name, resp.StatusCode, resp.Header, body); lokiErr != nil {
+			span.SetError(lokiErr)
+			return lokiErr
+		}
+		if len(body) > 1024 {
+			body = body[:1024]
+		}
+		err := fmt.Errorf("Loki replied with status %d: %s", resp.StatusCode, body)
+		span.SetError(err)
+		return &Error{Status: resp.StatusCode, Code: "UpstreamError", Message: fmt.Sprintf("Loki %s request of tenant %s failed", endpoint, tenant), Err: err}
+	}
+
+	maxSize := int64(maxClientResponseSize)
+	if c.cfg.MaxResponseSize > 0 && c.cfg.MaxResponseSize < maxSize {
+		maxSize = c.cfg.MaxResponseSize
+	}
+	if resp.ContentLength > maxSize {
+		return responseTooLargeError(maxSize, resp.ContentLength)
+	}
+	body := &limitedBody{ReadCloser: resp.Body, remaining: maxSize, limit: maxSize}
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		span.SetError(err)
+		var tooLarge *Error
+		if errors.As(err, &tooLarge) {
+			return tooLarge
+		}
+		return &Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: fmt.Sprintf("cannot decode the Loki %s response of tenant %s", endpoint, tenant), Err: err}
+	}
+
+	return nil
+}
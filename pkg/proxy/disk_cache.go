@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// diskCacheSuffix is the suffix of the files of the disk cache, the other
+// files of its directory are left alone
+const diskCacheSuffix = ".cache"
+
+// historicalEndpoints are the Loki endpoints whose responses over a completed
+// range are stored on disk, with the parameter of the end of their range
+var historicalEndpoints = map[string]string{
+	"/loki/api/v1/query_range": "end",
+	"/loki/api/v1/query":       "time",
+}
+
+// DiskCache stores the responses of the queries over a completed range in a
+// directory, like an emptyDir or a persistent volume, so that they survive the
+// restarts of the plugin. The least recently used files are removed above
+// the maximum size
+type DiskCache struct {
+	dir     string
+	maxSize int64
+	// minAge is the age of the end of a range after which its logs are
+	// complete, the more recent ranges are not stored
+	minAge time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	files map[string]*diskCacheFile
+	size  int64
+}
+
+type diskCacheFile struct {
+	size int64
+	used time.Time
+}
+
+// diskCacheEntry is the content of a file of the disk cache, the key tells
+// the entries of colliding file names apart
+type diskCacheEntry struct {
+	Key    string      `json:"key"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// NewDiskCache opens the disk cache of dir, created if missing, with the
+// files stored by the previous runs
+func NewDiskCache(dir string, maxSize int64, minAge time.Duration) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("cannot create the cache directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the cache directory: %w", err)
+	}
+
+	c := &DiskCache{dir: dir, maxSize: maxSize, minAge: minAge, now: time.Now, files: map[string]*diskCacheFile{}}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), diskCacheSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		c.files[entry.Name()] = &diskCacheFile{size: info.Size(), used: info.ModTime()}
+		c.size += info.Size()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict()
+	return c, nil
+}
+
+// historical tells whether the response of r is stored on disk: the range of
+// the queries ending before minAge, the queries without an end run until now
+func (c *DiskCache) historical(r *http.Request, endpoint string) bool {
+	param, ok := historicalEndpoints[endpoint]
+	if !ok {
+		return false
+	}
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return false
+	}
+	end, err := ParseTime(value)
+	if err != nil {
+		return false
+	}
+	return end.Before(c.now().Add(-c.minAge))
+}
+
+func (c *DiskCache) fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + diskCacheSuffix
+}
+
+func (c *DiskCache) get(key string) (*cachedResponse, bool) {
+	name := c.fileName(key)
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		return nil, false
+	}
+	entry := diskCacheEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return nil, false
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	if file, ok := c.files[name]; ok {
+		file.used = now
+	}
+	c.mu.Unlock()
+	// the use time survives the restarts in the modification time
+	os.Chtimes(filepath.Join(c.dir, name), now, now)
+
+	return &cachedResponse{status: entry.Status, header: entry.Header, body: entry.Body}, true
+}
+
+// set stores the response of key, written to a temporary file renamed into
+// place so that the readers never see a partial file
+func (c *DiskCache) set(key string, response *cachedResponse) error {
+	data, err := json.Marshal(diskCacheEntry{Key: key, Status: response.status, Header: response.header, Body: response.body})
+	if err != nil {
+		return err
+	}
+	size := int64(len(data))
+	if size > c.maxSize {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	name := c.fileName(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, name)); err != nil {
+		return err
+	}
+	if file, ok := c.files[name]; ok {
+		c.size -= file.size
+	}
+	c.files[name] = &diskCacheFile{size: size, used: c.now()}
+	c.size += size
+	c.evict()
+	return nil
+}
+
+// evict removes the least recently used files until the cache fits in
+// maxSize, c.mu must be held
+func (c *DiskCache) evict() {
+	for c.size > c.maxSize && len(c.files) > 0 {
+		oldest := ""
+		for name, file := range c.files {
+			if oldest == "" || file.used.Before(c.files[oldest].used) {
+				oldest = name
+			}
+		}
+		if err := os.Remove(filepath.Join(c.dir, oldest)); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).Warnf("cannot remove the cached response %s", oldest)
+		}
+		c.size -= c.files[oldest].size
+		delete(c.files, oldest)
+	}
+	metrics.DiskCacheBytes.Set(float64(c.size))
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskCache(t *testing.T) {
+	body := `{"status":"success","data":{"resultType":"streams","result":[]}}`
+	upstream, count := newCountingUpstream(t, body)
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+	dir := t.TempDir()
+
+	// every proxy is a restart of the plugin, with an empty memory cache
+	newProxy := func() *Proxy {
+		disk, err := NewDiskCache(dir, 1<<20, 5*time.Minute)
+		require.NoError(t, err)
+		return New(Config{URL: upstreamURL, Name: "default", Cache: CacheConfig{TTL: time.Minute, MaxEntries: 10, Disk: disk}})
+	}
+	get := func(p *Proxy, path string) string {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, body, w.Body.String())
+		return w.Header().Get(CacheHeader)
+	}
+
+	end := time.Now().Add(-time.Hour)
+	historical := fmt.Sprintf("/application/loki/api/v1/query_range?query=a&start=%d&end=%d", end.Add(-time.Hour).UnixNano(), end.UnixNano())
+	recent := fmt.Sprintf("/application/loki/api/v1/query_range?query=a&start=%d&end=%d", time.Now().Add(-time.Hour).UnixNano(), time.Now().UnixNano())
+
+	require.Equal(t, "MISS", get(newProxy(), historical))
+	require.Equal(t, "MISS", get(newProxy(), recent))
+	require.Equal(t, int32(2), atomic.LoadInt32(count))
+
+	// the completed range is served from disk after a restart, the recent
+	// one is queried again
+	p := newProxy()
+	require.Equal(t, "HIT", get(p, historical))
+	require.Equal(t, "HIT", get(p, historical))
+	require.Equal(t, "MISS", get(p, recent))
+	require.Equal(t, int32(3), atomic.LoadInt32(count))
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	c, err := NewDiskCache(dir, 400, time.Minute)
+	require.NoError(t, err)
+	c.now = func() time.Time { return now }
+
+	body := make([]byte, 100)
+	for _, key := range []string{"a", "b"} {
+		require.NoError(t, c.set(key, &cachedResponse{status: http.StatusOK, body: body}))
+		now = now.Add(time.Second)
+	}
+	// reading a refreshes it, b is the least recently used
+	_, ok := c.get("a")
+	require.True(t, ok)
+	require.NoError(t, c.set("c", &cachedResponse{status: http.StatusOK, body: body}))
+
+	_, ok = c.get("b")
+	require.False(t, ok)
+	for _, key := range []string{"a", "c"} {
+		response, ok := c.get(key)
+		require.True(t, ok, key)
+		require.Equal(t, body, response.body)
+	}
+
+	// the reopened cache finds the stored files
+	reopened, err := NewDiskCache(dir, 400, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, c.size, reopened.size)
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+}
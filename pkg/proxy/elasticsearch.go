@@ -0,0 +1,547 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/openshift/logging-view-plugin/pkg/tracing"
+)
+
+const (
+	// elasticsearchDefaultLimit and elasticsearchDefaultRange are the Loki
+	// defaults of the query_range requests without limit or start
+	elasticsearchDefaultLimit = 100
+	elasticsearchDefaultRange = time.Hour
+	// elasticsearchMaxLabelValues bounds the values returned by the label
+	// values endpoint
+	elasticsearchMaxLabelValues = 1000
+)
+
+var (
+	// elasticsearchEndpointRegexp matches the Loki endpoints served by the
+	// Elasticsearch adapter
+	elasticsearchEndpointRegexp = regexp.MustCompile(`^/loki/api/v1/(query_range|labels|label/[^/]+/values)$`)
+	labelValuesEndpointRegexp   = regexp.MustCompile(`^/loki/api/v1/label/([^/]+)/values$`)
+)
+
+// ElasticsearchMapping maps the Loki tenants and stream labels to the indices
+// and the document fields of an Elasticsearch log store
+type ElasticsearchMapping struct {
+	// Indices are the index patterns searched by tenant
+	Indices map[string]string
+	// Fields are the document fields by stream label, the labels without a
+	// field cannot be queried
+	Fields map[string]string
+	// MessageField holds the log line and TimestampField its time
+	MessageField   string
+	TimestampField string
+	// PollInterval is the time between two searches of the tail streams
+	PollInterval time.Duration
+}
+
+// Elasticsearch serves the Loki query_range, labels and label values
+// endpoints from an Elasticsearch log store. The stream selector and the
+// string line filters of the log queries are translated to the Elasticsearch
+// query DSL, the metric queries and the other pipeline stages are rejected.
+// It serves requests with the `/<tenant>/loki/api/v1/<endpoint>` path
+type Elasticsearch struct {
+	cfg     Config
+	mapping ElasticsearchMapping
+	client  *http.Client
+}
+
+// NewElasticsearch builds an Elasticsearch adapter, the URL of cfg is the
+// Elasticsearch base URL
+func NewElasticsearch(cfg Config, mapping ElasticsearchMapping) *Elasticsearch {
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err *Error) {
+			http.Error(w, err.Message, err.Status)
+		}
+	}
+	return &Elasticsearch{cfg: cfg, mapping: mapping, client: &http.Client{Transport: cfg.Transport}}
+}
+
+func (e *Elasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	endpoint = "/" + endpoint
+
+	if !tenantRegexp.MatchString(tenant) {
+		e.cfg.ErrorHandler(w, r, &Error{Status: http.StatusBadRequest, Code: "InvalidTenant", Message: fmt.Sprintf("invalid tenant %q", tenant)})
+		return
+	}
+
+	if !elasticsearchEndpointRegexp.MatchString(endpoint) {
+		e.cfg.ErrorHandler(w, r, &Error{Status: http.StatusNotFound, Code: "NotFound", Message: fmt.Sprintf("the Loki endpoint %s is not supported by Elasticsearch", endpoint)})
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		e.cfg.ErrorHandler(w, r, &Error{Status: http.StatusMethodNotAllowed, Code: "MethodNotAllowed", Message: fmt.Sprintf("method %s not allowed", r.Method)})
+		return
+	}
+
+	var response interface{}
+	var err *Error
+	switch {
+	case endpoint == queryRangeEndpoint:
+		response, err = e.queryRange(r, tenant)
+	case endpoint == "/loki/api/v1/labels":
+		response = e.labels()
+	default:
+		label := labelValuesEndpointRegexp.FindStringSubmatch(endpoint)[1]
+		response, err = e.labelValues(r, tenant, label)
+	}
+	if err != nil {
+		e.cfg.ErrorHandler(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiStreamsResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string                 `json:"resultType"`
+		Result     []lokiStream           `json:"result"`
+		Stats      map[string]interface{} `json:"stats"`
+	} `json:"data"`
+}
+
+type lokiLabelsResponse struct {
+	Status string   `json:"status"`
+	Data   []string `json:"data"`
+}
+
+type elasticsearchHit struct {
+	Source map[string]interface{} `json:"_source"`
+}
+
+type elasticsearchSearchResponse struct {
+	Hits struct {
+		Hits []elasticsearchHit `json:"hits"`
+	} `json:"hits"`
+	Aggregations struct {
+		Values struct {
+			Buckets []struct {
+				Key interface{} `json:"key"`
+			} `json:"buckets"`
+		} `json:"values"`
+	} `json:"aggregations"`
+}
+
+func (e *Elasticsearch) queryRange(r *http.Request, tenant string) (*lokiStreamsResponse, *Error) {
+	params := r.URL.Query()
+
+	start, end, err := queryRangeTimes(params)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := elasticsearchDefaultLimit
+	if value := params.Get("limit"); value != "" {
+		parsed, parseErr := strconv.Atoi(value)
+		if parseErr != nil || parsed <= 0 {
+			return nil, &Error{Status: http.StatusBadRequest, Code: "InvalidRequest", Message: fmt.Sprintf("invalid limit %q", value)}
+		}
+		limit = parsed
+	}
+
+	order := "desc"
+	switch params.Get("direction") {
+	case "", "backward":
+	case "forward":
+		order = "asc"
+	default:
+		return nil, &Error{Status: http.StatusBadRequest, Code: "InvalidRequest", Message: fmt.Sprintf("invalid direction %q", params.Get("direction"))}
+	}
+
+	query, err := e.mapping.boolQuery(params.Get("query"), true)
+	if err != nil {
+		return nil, err
+	}
+	query.filter(e.mapping.timeRange(start, end, false))
+
+	search := map[string]interface{}{
+		"size":  limit,
+		"query": map[string]interface{}{"bool": query},
+		"sort":  []interface{}{map[string]interface{}{e.mapping.TimestampField: map[string]string{"order": order}}},
+	}
+
+	result := &elasticsearchSearchResponse{}
+	if err := e.search(r, tenant, queryRangeEndpoint, search, result); err != nil {
+		return nil, err
+	}
+
+	response := &lokiStreamsResponse{Status: "success"}
+	response.Data.ResultType = "streams"
+	response.Data.Stats = map[string]interface{}{}
+	response.Data.Result = e.mapping.streams(result.Hits.Hits)
+	return response, nil
+}
+
+// labels returns the stream labels mapped to a document field
+func (e *Elasticsearch) labels() *lokiLabelsResponse {
+	labels := make([]string, 0, len(e.mapping.Fields))
+	for label := range e.mapping.Fields {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return &lokiLabelsResponse{Status: "success", Data: labels}
+}
+
+// labelValues returns the values of the field of label in the query range,
+// restricted to the streams of the optional query parameter
+func (e *Elasticsearch) labelValues(r *http.Request, tenant string, label string) (*lokiLabelsResponse, *Error) {
+	field, ok := e.mapping.Fields[label]
+	if !ok {
+		return &lokiLabelsResponse{Status: "success", Data: []string{}}, nil
+	}
+
+	params := r.URL.Query()
+	start, end, err := queryRangeTimes(params)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &elasticsearchBoolQuery{}
+	if value := params.Get("query"); value != "" {
+		if query, err = e.mapping.boolQuery(value, false); err != nil {
+			return nil, err
+		}
+	}
+	query.filter(e.mapping.timeRange(start, end, false))
+
+	search := map[string]interface{}{
+		"size":  0,
+		"query": map[string]interface{}{"bool": query},
+		"aggs": map[string]interface{}{
+			"values": map[string]interface{}{
+				"terms": map[string]interface{}{"field": field, "size": elasticsearchMaxLabelValues},
+			},
+		},
+	}
+
+	result := &elasticsearchSearchResponse{}
+	if err := e.search(r, tenant, "/loki/api/v1/label/"+label+"/values", search, result); err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(result.Aggregations.Values.Buckets))
+	for _, bucket := range result.Aggregations.Values.Buckets {
+		values = append(values, fmt.Sprint(bucket.Key))
+	}
+	sort.Strings(values)
+	return &lokiLabelsResponse{Status: "success", Data: values}, nil
+}
+
+// search sends a search request to the index of tenant and decodes the JSON
+// response in v, the bearer token of r is forwarded to Elasticsearch
+func (e *Elasticsearch) search(r *http.Request, tenant string, endpoint string, search interface{}, v interface{}) *Error {
+	index, ok := e.mapping.Indices[tenant]
+	if !ok {
+		return &Error{Status: http.StatusNotFound, Code: "NotFound", Message: fmt.Sprintf("no Elasticsearch index for tenant %s", tenant)}
+	}
+
+	r, tokenErr := e.cfg.withUpstreamToken(r)
+	if tokenErr != nil {
+		return tokenErr
+	}
+
+	ctx := r.Context()
+	if timeout := e.cfg.timeout(tenant, r.URL.Query()); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ctx, span := e.cfg.Tracer.Start(ctx, "elasticsearch "+endpoint, tracing.KindClient, upstreamAttributes(tenant, endpoint, r.URL.Query())...)
+	defer span.End()
+
+	body, err := json.Marshal(search)
+	if err != nil {
+		return &Error{Status: http.StatusInternalServerError, Code: "InternalError", Message: "cannot build the Elasticsearch request", Err: err}
+	}
+
+	upstreamURL := *e.cfg.URL
+	upstreamURL.Path = strings.TrimSuffix(upstreamURL.Path, "/") + "/" + index + "/_search"
+	upstreamURL.RawPath = ""
+	upstreamURL.RawQuery = "ignore_unavailable=true"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, upstreamURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return &Error{Status: http.StatusInternalServerError, Code: "InternalError", Message: "cannot build the Elasticsearch request", Err: err}
+	}
+	req.Header = e.cfg.upstreamHeaders(r.WithContext(ctx), tenant)
+	req.Header.Del("Accept-Encoding")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	if breakerErr := e.cfg.Breaker.allow(); breakerErr != nil {
+		return breakerErr
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			e.cfg.Breaker.record(true)
+		}
+		span.SetError(err)
+		metrics.UpstreamErrorsTotal.WithLabelValues("elasticsearch", "unavailable").Inc()
+		status := http.StatusBadGateway
+		if ctx.Err() == context.DeadlineExceeded {
+			status = http.StatusGatewayTimeout
+		}
+		return &Error{Status: status, Code: "UpstreamUnavailable", Message: "cannot reach Elasticsearch", Err: err}
+	}
+	defer resp.Body.Close()
+	e.cfg.Breaker.record(resp.StatusCode >= http.StatusInternalServerError)
+
+	span.SetAttributes(tracing.Int("http.status_code", int64(resp.StatusCode)))
+	if resp.StatusCode != http.StatusOK {
+		status := resp.StatusCode
+		if status >= http.StatusInternalServerError {
+			metrics.UpstreamErrorsTotal.WithLabelValues("elasticsearch", strconv.Itoa(resp.StatusCode)).Inc()
+			status = http.StatusBadGateway
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("Elasticsearch replied with status %d: %s", resp.StatusCode, body)
+		span.SetError(err)
+		return &Error{Status: status, Code: "UpstreamError", Message: fmt.Sprintf("Elasticsearch search of tenant %s failed", tenant), Err: err}
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxClientResponseSize)).Decode(v); err != nil {
+		span.SetError(err)
+		return &Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: fmt.Sprintf("cannot decode the Elasticsearch response of tenant %s", tenant), Err: err}
+	}
+
+	return nil
+}
+
+// queryRangeTimes returns the start and end parameters, the end defaults to
+// now and the start to one hour before the end like Loki
+func queryRangeTimes(params url.Values) (time.Time, time.Time, *Error) {
+	end := time.Now()
+	if value := params.Get("end"); value != "" {
+		parsed, err := ParseTime(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, &Error{Status: http.StatusBadRequest, Code: "InvalidRequest", Message: fmt.Sprintf("invalid end %q", value), Err: err}
+		}
+		end = parsed
+	}
+
+	start := end.Add(-elasticsearchDefaultRange)
+	if value := params.Get("start"); value != "" {
+		parsed, err := ParseTime(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, &Error{Status: http.StatusBadRequest, Code: "InvalidRequest", Message: fmt.Sprintf("invalid start %q", value), Err: err}
+		}
+		start = parsed
+	}
+
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, &Error{Status: http.StatusBadRequest, Code: "InvalidRequest", Message: "the end of the query range is before its start"}
+	}
+	return start, end, nil
+}
+
+// elasticsearchBoolQuery is a bool query of the Elasticsearch query DSL
+type elasticsearchBoolQuery struct {
+	Filter  []interface{} `json:"filter,omitempty"`
+	MustNot []interface{} `json:"must_not,omitempty"`
+}
+
+func (q *elasticsearchBoolQuery) filter(clause interface{}) {
+	q.Filter = append(q.Filter, clause)
+}
+
+func (q *elasticsearchBoolQuery) mustNot(clause interface{}) {
+	q.MustNot = append(q.MustNot, clause)
+}
+
+func clause(kind string, field string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{kind: map[string]interface{}{field: value}}
+}
+
+// boolQuery translates the stream selector of a LogQL log query to a bool
+// query, and its string line filters when lineFilters is true
+func (m ElasticsearchMapping) boolQuery(query string, lineFilters bool) (*elasticsearchBoolQuery, *Error) {
+	unsupported := func(format string, args ...interface{}) *Error {
+		return &Error{Status: http.StatusBadRequest, Code: "UnsupportedQuery", Message: fmt.Sprintf(format, args...)}
+	}
+
+	parsed, err := logql.Parse(query)
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Code: "InvalidQuery", Message: err.Error()}
+	}
+	if parsed.Metric {
+		return nil, unsupported("metric queries are not supported by Elasticsearch")
+	}
+	selector := parsed.Selectors[0]
+	if selector.Stages > 0 {
+		return nil, unsupported("only the stream selector and the |= and != line filters are supported by Elasticsearch")
+	}
+
+	boolQuery := &elasticsearchBoolQuery{}
+	for _, matcher := range selector.Matchers {
+		field, ok := m.Fields[matcher.Label]
+		if !ok {
+			return nil, unsupported("the label %s has no Elasticsearch field", matcher.Label)
+		}
+
+		switch {
+		case matcher.Operator == "=" && matcher.Value == "":
+			boolQuery.mustNot(clause("exists", "field", field))
+		case matcher.Operator == "!=" && matcher.Value == "":
+			boolQuery.filter(clause("exists", "field", field))
+		case matcher.Operator == "=":
+			boolQuery.filter(clause("term", field, matcher.Value))
+		case matcher.Operator == "!=":
+			boolQuery.mustNot(clause("term", field, matcher.Value))
+		case matcher.Operator == "=~":
+			boolQuery.filter(clause("regexp", field, matcher.Value))
+		case matcher.Operator == "!~":
+			boolQuery.mustNot(clause("regexp", field, matcher.Value))
+		}
+	}
+
+	if !lineFilters {
+		return boolQuery, nil
+	}
+
+	for _, lineFilter := range selector.LineFilters {
+		phrases := make([]interface{}, 0, len(lineFilter.Values))
+		for _, value := range lineFilter.Values {
+			phrases = append(phrases, clause("match_phrase", m.MessageField, value))
+		}
+
+		switch lineFilter.Operator {
+		case "|=":
+			boolQuery.filter(map[string]interface{}{"bool": map[string]interface{}{"should": phrases, "minimum_should_match": 1}})
+		case "!=":
+			for _, phrase := range phrases {
+				boolQuery.mustNot(phrase)
+			}
+		default:
+			return nil, unsupported("the %s line filter is not supported by Elasticsearch", lineFilter.Operator)
+		}
+	}
+
+	return boolQuery, nil
+}
+
+// timeRange returns the range clause of the entries from start to end, start
+// is excluded when afterStart is true
+func (m ElasticsearchMapping) timeRange(start time.Time, end time.Time, afterStart bool) map[string]interface{} {
+	startOperator := "gte"
+	if afterStart {
+		startOperator = "gt"
+	}
+	bounds := map[string]interface{}{startOperator: start.UTC().Format(time.RFC3339Nano)}
+	if !end.IsZero() {
+		bounds["lt"] = end.UTC().Format(time.RFC3339Nano)
+	}
+	return clause("range", m.TimestampField, bounds)
+}
+
+// streams groups the hits by label set in the Loki streams format, the hits
+// without a valid timestamp are skipped
+func (m ElasticsearchMapping) streams(hits []elasticsearchHit) []lokiStream {
+	streams := []lokiStream{}
+	indexes := map[string]int{}
+
+	for _, hit := range hits {
+		entry, ok := m.entry(hit)
+		if !ok {
+			continue
+		}
+
+		key := entryKey(Entry{Labels: entry.Labels})
+		i, found := indexes[key]
+		if !found {
+			i = len(streams)
+			indexes[key] = i
+			streams = append(streams, lokiStream{Stream: entry.Labels})
+		}
+		streams[i].Values = append(streams[i].Values, [2]string{strconv.FormatInt(entry.Timestamp.UnixNano(), 10), entry.Line})
+	}
+
+	return streams
+}
+
+// entry returns the log entry of a hit
+func (m ElasticsearchMapping) entry(hit elasticsearchHit) (Entry, bool) {
+	timestamp, ok := sourceField(hit.Source, m.TimestampField)
+	if !ok {
+		return Entry{}, false
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	line, _ := sourceField(hit.Source, m.MessageField)
+	labels := map[string]string{}
+	for label, field := range m.Fields {
+		if value, ok := sourceField(hit.Source, field); ok {
+			labels[label] = value
+		}
+	}
+
+	return Entry{Timestamp: parsed, Labels: labels, Line: line}, true
+}
+
+// sourceField returns the value of a dotted field of a document source, like
+// kubernetes.namespace_name, stored flat or in nested objects
+func sourceField(source map[string]interface{}, field string) (string, bool) {
+	if value, ok := source[field]; ok {
+		return sourceValue(value)
+	}
+
+	// the prefixes of the field may be nested objects
+	for i := 0; i < len(field); i++ {
+		if field[i] != '.' {
+			continue
+		}
+		if nested, ok := source[field[:i]].(map[string]interface{}); ok {
+			if value, ok := sourceField(nested, field[i+1:]); ok {
+				return value, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func sourceValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		return string(data), err == nil
+	default:
+		return fmt.Sprint(v), true
+	}
+}
@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// elasticsearchTailLimit bounds the entries read by every search of a tail
+// stream when the request has no limit
+const elasticsearchTailLimit = 100
+
+// ElasticsearchTail serves the Loki tail WebSocket from an Elasticsearch log
+// store, the new entries are searched every poll interval. It serves requests
+// with the `/<tenant>` path and the Loki tail query parameters
+type ElasticsearchTail struct {
+	*Tail
+	es *Elasticsearch
+}
+
+// NewElasticsearchTail builds an Elasticsearch tail adapter
+func NewElasticsearchTail(cfg Config, mapping ElasticsearchMapping, limits TailLimits) *ElasticsearchTail {
+	return &ElasticsearchTail{Tail: NewTail(cfg, limits), es: NewElasticsearch(cfg, mapping)}
+}
+
+type lokiTailMessage struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+func (t *ElasticsearchTail) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant, sse := tailRequest(r)
+	if !tenantRegexp.MatchString(tenant) {
+		t.cfg.ErrorHandler(w, r, &Error{Status: http.StatusBadRequest, Code: "InvalidTenant", Message: fmt.Sprintf("invalid tenant %q", tenant)})
+		return
+	}
+
+	if !t.checkTailTransport(w, r, sse) {
+		return
+	}
+
+	params := r.URL.Query()
+	query, queryErr := t.es.mapping.boolQuery(params.Get("query"), true)
+	if queryErr != nil {
+		t.cfg.ErrorHandler(w, r, queryErr)
+		return
+	}
+
+	limit := elasticsearchTailLimit
+	if value := params.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			t.cfg.ErrorHandler(w, r, &Error{Status: http.StatusBadRequest, Code: "InvalidRequest", Message: fmt.Sprintf("invalid limit %q", value)})
+			return
+		}
+		limit = parsed
+	}
+
+	start := time.Now()
+	if value := params.Get("start"); value != "" {
+		parsed, err := ParseTime(value)
+		if err != nil {
+			t.cfg.ErrorHandler(w, r, &Error{Status: http.StatusBadRequest, Code: "InvalidRequest", Message: fmt.Sprintf("invalid start %q", value), Err: err})
+			return
+		}
+		start = parsed
+	}
+
+	if !t.acquire() {
+		t.cfg.ErrorHandler(w, r, &Error{Status: http.StatusTooManyRequests, Code: "TooManyStreams", Message: fmt.Sprintf("the maximum of %d live streams is reached", t.limits.MaxStreams)})
+		return
+	}
+	defer t.release()
+
+	client, ok := t.openClient(w, r, sse)
+	if !ok {
+		return
+	}
+	defer client.finish()
+
+	t.poll(client, r, tenant, query, start, limit)
+}
+
+// poll searches the entries newer than the last sent ones every poll interval
+// and sends them to the client, until the client closes the connection or a
+// limit is reached. The entries of the last timestamp are searched again
+// so that the ones indexed late are not missed, they are only sent once
+func (t *ElasticsearchTail) poll(client tailClient, r *http.Request, tenant string, query *elasticsearchBoolQuery, start time.Time, limit int) {
+	interval := t.es.mapping.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	searchTicker := time.NewTicker(interval)
+	defer searchTicker.Stop()
+
+	ping, maxDuration, stopTimers := t.timers()
+	defer stopTimers()
+
+	last := start
+	sent := map[string]bool{}
+
+	for {
+		select {
+		case <-searchTicker.C:
+			search := map[string]interface{}{
+				"size": limit + len(sent),
+				"query": map[string]interface{}{"bool": elasticsearchBoolQuery{
+					Filter:  append(append([]interface{}{}, query.Filter...), t.es.mapping.timeRange(last, time.Time{}, false)),
+					MustNot: query.MustNot,
+				}},
+				"sort": []interface{}{map[string]interface{}{t.es.mapping.TimestampField: map[string]string{"order": "asc"}}},
+			}
+
+			result := &elasticsearchSearchResponse{}
+			if err := t.es.search(r, tenant, tailEndpoint, search, result); err != nil {
+				log.WithError(err).WithField("request_id", r.Header.Get(RequestIDHeader)).Warn("cannot search the Elasticsearch tail entries")
+				client.close(websocket.CloseInternalServerErr, "upstream search failed")
+				return
+			}
+
+			hits := []elasticsearchHit{}
+			for _, hit := range result.Hits.Hits {
+				entry, ok := t.es.mapping.entry(hit)
+				if !ok {
+					continue
+				}
+				key := entryKey(entry)
+				if entry.Timestamp.Equal(last) && sent[key] {
+					continue
+				}
+				if entry.Timestamp.After(last) {
+					last = entry.Timestamp
+					sent = map[string]bool{}
+				}
+				sent[key] = true
+				hits = append(hits, hit)
+			}
+			if len(hits) == 0 {
+				continue
+			}
+
+			data, err := json.Marshal(lokiTailMessage{Streams: t.es.mapping.streams(hits)})
+			if err != nil {
+				return
+			}
+			if err := client.send(data); err != nil {
+				return
+			}
+		case <-ping:
+			if err := client.ping(); err != nil {
+				return
+			}
+		case <-maxDuration:
+			client.close(websocket.CloseNormalClosure, "maximum stream duration reached")
+			return
+		case <-client.gone():
+			return
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+var testElasticsearchMapping = ElasticsearchMapping{
+	Indices: map[string]string{"application": "app-*"},
+	Fields: map[string]string{
+		"kubernetes_namespace_name": "kubernetes.namespace_name",
+		"kubernetes_pod_name":       "kubernetes.pod_name",
+		"level":                     "level",
+	},
+	MessageField:   "message",
+	TimestampField: "@timestamp",
+	PollInterval:   10 * time.Millisecond,
+}
+
+type elasticsearchRequest struct {
+	path          string
+	authorization string
+	body          map[string]interface{}
+}
+
+func newElasticsearchUpstream(t *testing.T, response string) (*url.URL, <-chan elasticsearchRequest) {
+	requests := make(chan elasticsearchRequest, 10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		data, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(data, &body))
+		select {
+		case requests <- elasticsearchRequest{path: r.URL.Path, authorization: r.Header.Get("Authorization"), body: body}:
+		default:
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(upstream.Close)
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+	return upstreamURL, requests
+}
+
+func TestElasticsearchQueryRange(t *testing.T) {
+	upstreamURL, requests := newElasticsearchUpstream(t, `{"hits":{"hits":[
+		{"_source":{"@timestamp":"2023-01-02T10:00:02.5Z","message":"error two","kubernetes":{"namespace_name":"my-app","pod_name":"pod-a"},"level":"error"}},
+		{"_source":{"@timestamp":"2023-01-02T10:00:01Z","message":"error one","kubernetes.namespace_name":"my-app","kubernetes":{"pod_name":"pod-b"}}},
+		{"_source":{"@timestamp":"2023-01-02T10:00:00Z","message":"error zero","kubernetes":{"namespace_name":"my-app","pod_name":"pod-a"},"level":"error"}},
+		{"_source":{"message":"no timestamp"}}
+	]}}`)
+
+	es := NewElasticsearch(Config{URL: upstreamURL}, testElasticsearchMapping)
+
+	query := url.Values{}
+	query.Set("query", `{kubernetes_namespace_name="my-app", level!="debug"} |= "error" != "timeout"`)
+	query.Set("start", "1672653600000000000")
+	query.Set("end", "1672657200000000000")
+	query.Set("limit", "10")
+	r := httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?"+query.Encode(), nil)
+	r.Header.Set("Authorization", "Bearer user-token")
+	w := httptest.NewRecorder()
+
+	es.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	request := <-requests
+	require.Equal(t, "/app-*/_search", request.path)
+	require.Equal(t, "Bearer user-token", request.authorization)
+	expectedBody := `{
+		"size": 10,
+		"sort": [{"@timestamp": {"order": "desc"}}],
+		"query": {"bool": {
+			"filter": [
+				{"term": {"kubernetes.namespace_name": "my-app"}},
+				{"bool": {"should": [{"match_phrase": {"message": "error"}}], "minimum_should_match": 1}},
+				{"range": {"@timestamp": {"gte": "2023-01-02T10:00:00Z", "lt": "2023-01-02T11:00:00Z"}}}
+			],
+			"must_not": [
+				{"term": {"level": "debug"}},
+				{"match_phrase": {"message": "timeout"}}
+			]
+		}}
+	}`
+	actualBody, err := json.Marshal(request.body)
+	require.NoError(t, err)
+	require.JSONEq(t, expectedBody, string(actualBody))
+
+	require.JSONEq(t, `{"status":"success","data":{"resultType":"streams","stats":{},"result":[
+		{"stream":{"kubernetes_namespace_name":"my-app","kubernetes_pod_name":"pod-a","level":"error"},"values":[["1672653602500000000","error two"],["1672653600000000000","error zero"]]},
+		{"stream":{"kubernetes_namespace_name":"my-app","kubernetes_pod_name":"pod-b"},"values":[["1672653601000000000","error one"]]}
+	]}}`, w.Body.String())
+}
+
+func TestElasticsearchUnsupportedRequests(t *testing.T) {
+	upstreamURL, _ := newElasticsearchUpstream(t, `{}`)
+	es := NewElasticsearch(Config{URL: upstreamURL}, testElasticsearchMapping)
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{path: "/application/loki/api/v1/query_range?query=" + url.QueryEscape(`rate({level="error"}[5m])`), expectedStatus: http.StatusBadRequest},
+		{path: "/application/loki/api/v1/query_range?query=" + url.QueryEscape(`{level="error"} | json`), expectedStatus: http.StatusBadRequest},
+		{path: "/application/loki/api/v1/query_range?query=" + url.QueryEscape(`{level="error"} |~ "err.*"`), expectedStatus: http.StatusBadRequest},
+		{path: "/application/loki/api/v1/query_range?query=" + url.QueryEscape(`{app="foo"}`), expectedStatus: http.StatusBadRequest},
+		{path: "/application/loki/api/v1/query_range?query=" + url.QueryEscape(`{level=`), expectedStatus: http.StatusBadRequest},
+		{path: "/audit/loki/api/v1/query_range?query=" + url.QueryEscape(`{level="error"}`), expectedStatus: http.StatusNotFound},
+		{path: "/application/loki/api/v1/series", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		es.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		require.Equal(t, tt.expectedStatus, w.Code, tt.path)
+	}
+}
+
+func TestElasticsearchLabels(t *testing.T) {
+	upstreamURL, requests := newElasticsearchUpstream(t, `{"aggregations":{"values":{"buckets":[{"key":"ns-b"},{"key":"ns-a"}]}}}`)
+	es := NewElasticsearch(Config{URL: upstreamURL}, testElasticsearchMapping)
+
+	w := httptest.NewRecorder()
+	es.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/labels", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"success","data":["kubernetes_namespace_name","kubernetes_pod_name","level"]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	es.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/label/kubernetes_namespace_name/values?query="+url.QueryEscape(`{level="error"}`), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"success","data":["ns-a","ns-b"]}`, w.Body.String())
+
+	request := <-requests
+	require.Equal(t, float64(0), request.body["size"])
+	require.Contains(t, request.body, "aggs")
+
+	w = httptest.NewRecorder()
+	es.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/label/unknown/values", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"success","data":[]}`, w.Body.String())
+}
+
+func TestElasticsearchUpstreamError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer upstream.Close()
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	es := NewElasticsearch(Config{URL: upstreamURL}, testElasticsearchMapping)
+
+	w := httptest.NewRecorder()
+	es.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?query="+url.QueryEscape(`{level="error"}`), nil))
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestElasticsearchTail(t *testing.T) {
+	upstreamURL, requests := newElasticsearchUpstream(t, `{"hits":{"hits":[
+		{"_source":{"@timestamp":"2023-01-02T10:00:00Z","message":"first","level":"error"}}
+	]}}`)
+
+	tail := NewElasticsearchTail(Config{URL: upstreamURL}, testElasticsearchMapping, TailLimits{MaxDuration: time.Second})
+	server := httptest.NewServer(http.StripPrefix("/api/tail", tail))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(server.URL, "http", "ws", 1)+"/api/tail/application?start=1672653600000000000&query="+url.QueryEscape(`{level="error"}`), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"streams":[{"stream":{"level":"error"},"values":[["1672653600000000000","first"]]}]}`, string(message))
+
+	request := <-requests
+	require.Equal(t, "/app-*/_search", request.path)
+
+	// the entry is searched again but only sent once, until the stream ends
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+}
@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const queryRangeEndpoint = "/loki/api/v1/query_range"
+
+// Entry is a log line of a stream
+type Entry struct {
+	Timestamp time.Time
+	Labels    map[string]string
+	Line      string
+}
+
+// EntriesRequest is a log query whose entries are read from the newest to the
+// oldest in pages of PageSize entries
+type EntriesRequest struct {
+	Query string
+	Start time.Time
+	End   time.Time
+	// PageSize is the limit of each query_range request
+	PageSize int
+	// Seen are the EntryID of the entries of the last nanosecond of the
+	// range already read, they are skipped to resume a previous read
+	Seen []string
+}
+
+type streamsResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// Entries calls fn with the entries of a log query from the newest to the
+// oldest until the query range is read or fn returns false. The pages
+// overlap by one nanosecond so that the entries sharing the timestamp of a
+// page boundary are not missed, they are only passed once to fn and the limit
+// of the next page is raised by their number
+func (c *Client) Entries(r *http.Request, tenant string, req EntriesRequest, fn func(Entry) bool) error {
+	end := req.End
+	boundary := map[string]bool{}
+	for _, id := range req.Seen {
+		boundary[id] = true
+	}
+
+	for {
+		params := url.Values{}
+		params.Set("query", req.Query)
+		params.Set("start", strconv.FormatInt(req.Start.UnixNano(), 10))
+		params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+		limit := req.PageSize + len(boundary)
+		params.Set("limit", strconv.Itoa(limit))
+		params.Set("direction", "backward")
+
+		resp := &streamsResponse{}
+		if err := c.get(r, tenant, queryRangeEndpoint, params, resp); err != nil {
+			return err
+		}
+		if resp.Data.ResultType != "streams" {
+			return &Error{Status: http.StatusBadRequest, Code: "InvalidQuery", Message: fmt.Sprintf("the query returned a %s result, a log query is expected", resp.Data.ResultType)}
+		}
+
+		entries, err := streamEntries(resp)
+		if err != nil {
+			return &Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: "cannot decode the Loki entries", Err: err}
+		}
+
+		emitted := 0
+		var oldest time.Time
+		nextBoundary := map[string]bool{}
+		for _, entry := range entries {
+			key := EntryID(entry)
+			if !oldest.Equal(entry.Timestamp) {
+				oldest = entry.Timestamp
+				nextBoundary = map[string]bool{}
+			}
+			nextBoundary[key] = true
+
+			if entry.Timestamp.Equal(end.Add(-time.Nanosecond)) && boundary[key] {
+				continue
+			}
+
+			if !fn(entry) {
+				return nil
+			}
+			emitted++
+		}
+
+		if len(entries) < limit || emitted == 0 || !oldest.After(req.Start) {
+			return nil
+		}
+
+		end = oldest.Add(time.Nanosecond)
+		boundary = nextBoundary
+	}
+}
+
+// DecodeEntries returns the entries of a Loki query_range response of a log
+// query from the newest to the oldest
+func DecodeEntries(body []byte) ([]Entry, error) {
+	resp := &streamsResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		return nil, &Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: "cannot decode the Loki response", Err: err}
+	}
+	if resp.Data.ResultType != "streams" {
+		return nil, &Error{Status: http.StatusBadRequest, Code: "InvalidQuery", Message: fmt.Sprintf("the query returned a %s result, a log query is expected", resp.Data.ResultType)}
+	}
+
+	entries, err := streamEntries(resp)
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: "cannot decode the Loki entries", Err: err}
+	}
+	return entries, nil
+}
+
+// streamEntries returns the entries of the streams sorted from the newest to
+// the oldest
+func streamEntries(resp *streamsResponse) ([]Entry, error) {
+	entries := []Entry{}
+	for _, stream := range resp.Data.Result {
+		for _, value := range stream.Values {
+			nanos, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q: %w", value[0], err)
+			}
+			entries = append(entries, Entry{Timestamp: time.Unix(0, nanos), Labels: stream.Stream, Line: value[1]})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+
+	return entries, nil
+}
+
+// EntryID identifies entry among the entries of its timestamp
+func EntryID(entry Entry) string {
+	sum := sha256.Sum256([]byte(entryKey(entry)))
+	return hex.EncodeToString(sum[:16])
+}
+
+func entryKey(entry Entry) string {
+	names := make([]string, 0, len(entry.Labels))
+	for name := range entry.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(entry.Labels[name])
+		b.WriteByte(',')
+	}
+	b.WriteByte(0)
+	b.WriteString(entry.Line)
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLoki serves the entries of a single stream like the Loki
+// query_range endpoint, the start is inclusive and the end exclusive
+func newTestLoki(t *testing.T, timestamps []int64, requests *int) *httptest.Server {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		params := r.URL.Query()
+		start, _ := strconv.ParseInt(params.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(params.Get("end"), 10, 64)
+		limit, _ := strconv.Atoi(params.Get("limit"))
+
+		values := [][2]string{}
+		for i, ts := range timestamps {
+			if ts >= start && ts < end {
+				values = append(values, [2]string{strconv.FormatInt(ts, 10), fmt.Sprintf("line %d", i)})
+			}
+		}
+		sort.SliceStable(values, func(i, j int) bool { return values[i][0] > values[j][0] })
+		if len(values) > limit {
+			values = values[:limit]
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "streams",
+				"result":     []interface{}{map[string]interface{}{"stream": map[string]string{"app": "a"}, "values": values}},
+			},
+		})
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+func TestEntries(t *testing.T) {
+	tests := []struct {
+		name             string
+		timestamps       []int64
+		pageSize         int
+		maxLines         int
+		expectedLines    []string
+		expectedRequests int
+	}{
+		{
+			name:             "single page",
+			timestamps:       []int64{10, 20, 30},
+			pageSize:         10,
+			expectedLines:    []string{"line 2", "line 1", "line 0"},
+			expectedRequests: 1,
+		},
+		{
+			name:             "pages",
+			timestamps:       []int64{10, 20, 30, 40, 50},
+			pageSize:         2,
+			expectedLines:    []string{"line 4", "line 3", "line 2", "line 1", "line 0"},
+			expectedRequests: 3,
+		},
+		{
+			name:             "shared timestamp on a page boundary",
+			timestamps:       []int64{10, 20, 20, 20, 30},
+			pageSize:         2,
+			expectedLines:    []string{"line 4", "line 1", "line 2", "line 3", "line 0"},
+			expectedRequests: 3,
+		},
+		{
+			name:             "stopped",
+			timestamps:       []int64{10, 20, 30, 40, 50},
+			pageSize:         2,
+			maxLines:         3,
+			expectedLines:    []string{"line 4", "line 3", "line 2"},
+			expectedRequests: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			upstream := newTestLoki(t, tc.timestamps, &requests)
+			upstreamURL, err := url.Parse(upstream.URL)
+			require.NoError(t, err)
+
+			client := NewClient(Config{URL: upstreamURL, UseTenantInHeader: true})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			lines := []string{}
+			err = client.Entries(r, "application", EntriesRequest{
+				Query:    `{app="a"}`,
+				Start:    time.Unix(0, 0),
+				End:      time.Unix(0, 100),
+				PageSize: tc.pageSize,
+			}, func(entry Entry) bool {
+				lines = append(lines, entry.Line)
+				return tc.maxLines == 0 || len(lines) < tc.maxLines
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedLines, lines)
+			require.Equal(t, tc.expectedRequests, requests)
+		})
+	}
+}
+
+func TestEntriesResumed(t *testing.T) {
+	requests := 0
+	upstream := newTestLoki(t, []int64{10, 20, 20, 20, 30}, &requests)
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	client := NewClient(Config{URL: upstreamURL, UseTenantInHeader: true})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// the read stopped after the first entry of the timestamp 20
+	seen := EntryID(Entry{Labels: map[string]string{"app": "a"}, Line: "line 1"})
+	lines := []string{}
+	err = client.Entries(r, "application", EntriesRequest{
+		Query:    `{app="a"}`,
+		Start:    time.Unix(0, 0),
+		End:      time.Unix(0, 21),
+		PageSize: 2,
+		Seen:     []string{seen},
+	}, func(entry Entry) bool {
+		lines = append(lines, entry.Line)
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"line 2", "line 3", "line 0"}, lines)
+}
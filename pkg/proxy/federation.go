@@ -0,0 +1,378 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// PartialResponseHeader lists the clusters of a federation whose query
+	// failed, the response only holds the results of the other clusters
+	PartialResponseHeader = "X-Partial-Response"
+	// DefaultClusterLabel is the label holding the cluster of the federated
+	// streams and series
+	DefaultClusterLabel = "cluster"
+	// defaultLogsLimit is the limit of the Loki log queries without one
+	defaultLogsLimit = 100
+)
+
+// FederationMember is a cluster of a federation, queried with the settings of
+// its Loki datasource
+type FederationMember struct {
+	Name   string
+	Config Config
+}
+
+// FederationConfig configures a Federation
+type FederationConfig struct {
+	Members []FederationMember
+	// ClusterLabel is the label set to the cluster name on the streams, the
+	// metrics and the series of the responses
+	ClusterLabel string
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err *Error)
+}
+
+// Federation fans the Loki queries of the /<tenant>/loki/api/v1/<endpoint>
+// requests out to the Loki datasources of several clusters concurrently and
+// merges their results, the entries are sorted by timestamp across the
+// clusters. When some clusters fail the results of the others are returned
+// with warnings
+type Federation struct {
+	cfg     FederationConfig
+	clients []*Client
+}
+
+// federationResult is the response of a cluster to a federated query
+type federationResult struct {
+	cluster string
+	data    json.RawMessage
+	err     error
+}
+
+type federationResponse struct {
+	Status   string          `json:"status"`
+	Data     json.RawMessage `json:"data"`
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+type federationQueryData struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+type federationStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type federationSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values json.RawMessage   `json:"values,omitempty"`
+	Value  json.RawMessage   `json:"value,omitempty"`
+}
+
+// NewFederation builds a federation of the Loki datasources of cfg
+func NewFederation(cfg FederationConfig) *Federation {
+	if cfg.ClusterLabel == "" {
+		cfg.ClusterLabel = DefaultClusterLabel
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err *Error) {
+			http.Error(w, err.Message, err.Status)
+		}
+	}
+
+	clients := make([]*Client, 0, len(cfg.Members))
+	for _, member := range cfg.Members {
+		clients = append(clients, NewClient(member.Config))
+	}
+	return &Federation{cfg: cfg, clients: clients}
+}
+
+func (f *Federation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		f.cfg.ErrorHandler(w, r, &Error{Status: http.StatusMethodNotAllowed, Code: "MethodNotAllowed", Message: fmt.Sprintf("method %s not allowed", r.Method)})
+		return
+	}
+
+	tenant, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	endpoint = "/" + endpoint
+	params := r.URL.Query()
+
+	// the clusters are the values of the cluster label
+	if endpoint == "/loki/api/v1/label/"+f.cfg.ClusterLabel+"/values" {
+		clusters := make([]string, 0, len(f.cfg.Members))
+		for _, member := range f.cfg.Members {
+			clusters = append(clusters, member.Name)
+		}
+		sort.Strings(clusters)
+		f.writeResponse(w, clusters, nil)
+		return
+	}
+
+	var merge func(results []federationResult) (interface{}, error)
+	switch {
+	case endpoint == "/loki/api/v1/query", endpoint == "/loki/api/v1/query_range":
+		merge = func(results []federationResult) (interface{}, error) {
+			return f.mergeQueries(results, params)
+		}
+	case endpoint == "/loki/api/v1/labels":
+		merge = func(results []federationResult) (interface{}, error) {
+			return mergeLabels(results, f.cfg.ClusterLabel)
+		}
+	case strings.HasPrefix(endpoint, "/loki/api/v1/label/") && strings.HasSuffix(endpoint, "/values"):
+		merge = func(results []federationResult) (interface{}, error) {
+			return mergeLabels(results, "")
+		}
+	case endpoint == "/loki/api/v1/series":
+		merge = f.mergeSeries
+	default:
+		f.cfg.ErrorHandler(w, r, &Error{Status: http.StatusNotFound, Code: "NotFound", Message: fmt.Sprintf("the Loki endpoint %s is not supported by the federated datasources", endpoint)})
+		return
+	}
+
+	results, warnings := f.fanOut(r, tenant, endpoint, params)
+	if len(results) == 0 {
+		f.cfg.ErrorHandler(w, r, federationError(warnings))
+		return
+	}
+
+	data, err := merge(results)
+	if err != nil {
+		f.cfg.ErrorHandler(w, r, &Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: "cannot merge the responses of the clusters", Err: err})
+		return
+	}
+	f.writeResponse(w, data, warnings)
+}
+
+// fanOut sends the query to every cluster concurrently, it returns the
+// successful results in the order of the clusters and the failures
+func (f *Federation) fanOut(r *http.Request, tenant string, endpoint string, params url.Values) ([]federationResult, []federationResult) {
+	results := make([]federationResult, len(f.clients))
+
+	var wg sync.WaitGroup
+	for i, client := range f.clients {
+		wg.Add(1)
+		go func(i int, client *Client) {
+			defer wg.Done()
+			resp := &federationResponse{}
+			err := client.get(r, tenant, endpoint, params, resp)
+			if err == nil && resp.Status != "success" {
+				err = fmt.Errorf("Loki replied with status %q", resp.Status)
+			}
+			results[i] = federationResult{cluster: f.cfg.Members[i].Name, data: resp.Data, err: err}
+		}(i, client)
+	}
+	wg.Wait()
+
+	succeeded, failed := []federationResult{}, []federationResult{}
+	for _, result := range results {
+		if result.err != nil {
+			log.WithField("request_id", r.Header.Get(RequestIDHeader)).WithError(result.err).Warnf("federated query of cluster %s failed", result.cluster)
+			failed = append(failed, result)
+			continue
+		}
+		succeeded = append(succeeded, result)
+	}
+	return succeeded, failed
+}
+
+// federationError returns the error of a query failing on every cluster, the
+// error of the first cluster
+func federationError(failed []federationResult) *Error {
+	var proxyErr *Error
+	if len(failed) > 0 && errors.As(failed[0].err, &proxyErr) {
+		return proxyErr
+	}
+	return &Error{Status: http.StatusBadGateway, Code: "UpstreamUnavailable", Message: "the query failed on every cluster"}
+}
+
+// writeResponse writes the merged data, the clusters of failed are listed in
+// the warnings of the response and in PartialResponseHeader
+func (f *Federation) writeResponse(w http.ResponseWriter, data interface{}, failed []federationResult) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := federationResponse{Status: "success", Data: encoded}
+	clusters := []string{}
+	for _, result := range failed {
+		clusters = append(clusters, result.cluster)
+		message := result.err.Error()
+		var proxyErr *Error
+		if errors.As(result.err, &proxyErr) {
+			message = proxyErr.Message
+		}
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("cluster %s: %s", result.cluster, message))
+	}
+	if len(clusters) > 0 {
+		w.Header().Set(PartialResponseHeader, strings.Join(clusters, ","))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// mergeQueries merges the query results of the clusters, the results must
+// have the same type
+func (f *Federation) mergeQueries(results []federationResult, params url.Values) (interface{}, error) {
+	resultType := ""
+	streams := []federationStream{}
+	series := []federationSeries{}
+
+	for _, result := range results {
+		data := federationQueryData{}
+		if err := json.Unmarshal(result.data, &data); err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", result.cluster, err)
+		}
+		if resultType != "" && data.ResultType != resultType {
+			return nil, fmt.Errorf("cluster %s returned a %s result, %s is expected", result.cluster, data.ResultType, resultType)
+		}
+		resultType = data.ResultType
+
+		switch resultType {
+		case "streams":
+			clusterStreams := []federationStream{}
+			if err := json.Unmarshal(data.Result, &clusterStreams); err != nil {
+				return nil, fmt.Errorf("cluster %s: %w", result.cluster, err)
+			}
+			for _, stream := range clusterStreams {
+				stream.Stream = withLabel(stream.Stream, f.cfg.ClusterLabel, result.cluster)
+				streams = append(streams, stream)
+			}
+		case "matrix", "vector":
+			clusterSeries := []federationSeries{}
+			if err := json.Unmarshal(data.Result, &clusterSeries); err != nil {
+				return nil, fmt.Errorf("cluster %s: %w", result.cluster, err)
+			}
+			for _, s := range clusterSeries {
+				s.Metric = withLabel(s.Metric, f.cfg.ClusterLabel, result.cluster)
+				series = append(series, s)
+			}
+		default:
+			return nil, fmt.Errorf("cluster %s returned an unsupported %s result", result.cluster, resultType)
+		}
+	}
+
+	if resultType == "streams" {
+		merged, err := mergeStreams(streams, params)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"resultType": resultType, "result": merged}, nil
+	}
+	return map[string]interface{}{"resultType": resultType, "result": series}, nil
+}
+
+// mergeStreams sorts the entries of the streams of the clusters by timestamp
+// in the direction of the query and keeps the limit of the query, like Loki
+// does for the streams of a single cluster
+func mergeStreams(streams []federationStream, params url.Values) ([]federationStream, error) {
+	type entry struct {
+		stream    int
+		timestamp int64
+		value     [2]string
+	}
+
+	entries := []entry{}
+	for i, stream := range streams {
+		for _, value := range stream.Values {
+			timestamp, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q: %w", value[0], err)
+			}
+			entries = append(entries, entry{stream: i, timestamp: timestamp, value: value})
+		}
+	}
+
+	forward := params.Get("direction") == "forward"
+	sort.SliceStable(entries, func(i, j int) bool {
+		if forward {
+			return entries[i].timestamp < entries[j].timestamp
+		}
+		return entries[i].timestamp > entries[j].timestamp
+	})
+
+	limit := defaultLogsLimit
+	if value := params.Get("limit"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	// the streams are listed in the order of their first entry
+	merged := []federationStream{}
+	positions := map[int]int{}
+	for _, e := range entries {
+		position, found := positions[e.stream]
+		if !found {
+			position = len(merged)
+			positions[e.stream] = position
+			merged = append(merged, federationStream{Stream: streams[e.stream].Stream})
+		}
+		merged[position].Values = append(merged[position].Values, e.value)
+	}
+	return merged, nil
+}
+
+// mergeLabels returns the sorted union of the label names or values of the
+// clusters, with extra when set
+func mergeLabels(results []federationResult, extra string) (interface{}, error) {
+	seen := map[string]bool{}
+	if extra != "" {
+		seen[extra] = true
+	}
+	for _, result := range results {
+		values := []string{}
+		if err := json.Unmarshal(result.data, &values); err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", result.cluster, err)
+		}
+		for _, value := range values {
+			seen[value] = true
+		}
+	}
+
+	merged := make([]string, 0, len(seen))
+	for value := range seen {
+		merged = append(merged, value)
+	}
+	sort.Strings(merged)
+	return merged, nil
+}
+
+// mergeSeries returns the series of the clusters with their cluster label
+func (f *Federation) mergeSeries(results []federationResult) (interface{}, error) {
+	merged := []map[string]string{}
+	for _, result := range results {
+		series := []map[string]string{}
+		if err := json.Unmarshal(result.data, &series); err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", result.cluster, err)
+		}
+		for _, labels := range series {
+			merged = append(merged, withLabel(labels, f.cfg.ClusterLabel, result.cluster))
+		}
+	}
+	return merged, nil
+}
+
+// withLabel returns a copy of labels with the label name set to value
+func withLabel(labels map[string]string, name string, value string) map[string]string {
+	copied := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	copied[name] = value
+	return copied
+}
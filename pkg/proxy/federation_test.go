@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newFederationMember(t *testing.T, name string, handler http.HandlerFunc) FederationMember {
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+	return FederationMember{Name: name, Config: Config{URL: upstreamURL, UseTenantInHeader: true}}
+}
+
+func lokiHandler(responses map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, found := responses[r.URL.Path]
+		if !found {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+}
+
+func TestFederation(t *testing.T) {
+	east := newFederationMember(t, "east", lokiHandler(map[string]string{
+		"/loki/api/v1/query_range": `{"status":"success","data":{"resultType":"streams","result":[
+			{"stream":{"app":"api"},"values":[["40","east 40"],["10","east 10"]]}]}}`,
+		"/loki/api/v1/labels": `{"status":"success","data":["app","namespace"]}`,
+		"/loki/api/v1/series": `{"status":"success","data":[{"app":"api"}]}`,
+	}))
+	west := newFederationMember(t, "west", lokiHandler(map[string]string{
+		"/loki/api/v1/query_range": `{"status":"success","data":{"resultType":"streams","result":[
+			{"stream":{"app":"api"},"values":[["30","west 30"],["20","west 20"]]}]}}`,
+		"/loki/api/v1/labels": `{"status":"success","data":["app","pod"]}`,
+		"/loki/api/v1/series": `{"status":"success","data":[{"app":"web"}]}`,
+	}))
+	down := newFederationMember(t, "north", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	get := func(f *Federation, path string) (*httptest.ResponseRecorder, federationResponse) {
+		w := httptest.NewRecorder()
+		f.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		resp := federationResponse{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w, resp
+	}
+
+	f := NewFederation(FederationConfig{Members: []FederationMember{east, west}})
+
+	// the entries are sorted by timestamp across the clusters, with the limit
+	_, resp := get(f, "/application/loki/api/v1/query_range?query=%7Bapp%3D%22api%22%7D&limit=3")
+	require.JSONEq(t, `{"resultType":"streams","result":[
+		{"stream":{"app":"api","cluster":"east"},"values":[["40","east 40"]]},
+		{"stream":{"app":"api","cluster":"west"},"values":[["30","west 30"],["20","west 20"]]}]}`, string(resp.Data))
+	require.Empty(t, resp.Warnings)
+
+	_, resp = get(f, "/application/loki/api/v1/query_range?query=%7Bapp%3D%22api%22%7D&direction=forward&limit=2")
+	require.JSONEq(t, `{"resultType":"streams","result":[
+		{"stream":{"app":"api","cluster":"east"},"values":[["10","east 10"]]},
+		{"stream":{"app":"api","cluster":"west"},"values":[["20","west 20"]]}]}`, string(resp.Data))
+
+	_, resp = get(f, "/application/loki/api/v1/labels")
+	require.JSONEq(t, `["app","cluster","namespace","pod"]`, string(resp.Data))
+
+	_, resp = get(f, "/application/loki/api/v1/label/cluster/values")
+	require.JSONEq(t, `["east","west"]`, string(resp.Data))
+
+	_, resp = get(f, "/application/loki/api/v1/series?match[]=%7Bapp%3D~%22.%2B%22%7D")
+	require.JSONEq(t, `[{"app":"api","cluster":"east"},{"app":"web","cluster":"west"}]`, string(resp.Data))
+
+	// the failed clusters are reported along the results of the others
+	f = NewFederation(FederationConfig{Members: []FederationMember{east, down}, ClusterLabel: "source_cluster"})
+	w, resp := get(f, "/application/loki/api/v1/query_range?query=%7Bapp%3D%22api%22%7D")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "north", w.Header().Get(PartialResponseHeader))
+	require.Equal(t, []string{"cluster north: Loki /loki/api/v1/query_range request of tenant application failed"}, resp.Warnings)
+	require.JSONEq(t, `{"resultType":"streams","result":[
+		{"stream":{"app":"api","source_cluster":"east"},"values":[["40","east 40"],["10","east 10"]]}]}`, string(resp.Data))
+
+	// the error of the first cluster is returned when every cluster fails
+	f = NewFederation(FederationConfig{Members: []FederationMember{down}})
+	w, _ = get(f, "/application/loki/api/v1/query_range?query=%7Bapp%3D%22api%22%7D")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GuardrailExceededCode is the error code of the requests rejected by a
+// guardrail
+const GuardrailExceededCode = "GuardrailExceeded"
+
+// ResponseTooLargeCode is the error code of the upstream responses larger than
+// MaxResponseSize
+const ResponseTooLargeCode = "ResponseTooLarge"
+
+// GuardrailViolation details the requests rejected by a guardrail
+type GuardrailViolation struct {
+	// Guardrail is the name of the exceeded setting, like maxSeries
+	Guardrail string `json:"guardrail"`
+	Limit     string `json:"limit"`
+	Value     string `json:"value"`
+}
+
+// checkSeries rejects the metric query responses of more than MaxSeries
+// series. The responses larger than maxObservedResponseSize are streamed
+// without being checked
+func (p *Proxy) checkSeries(resp *http.Response) error {
+	if p.cfg.MaxSeries <= 0 || resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	path := resp.Request.URL.Path
+	if !strings.HasSuffix(path, "/loki/api/v1/query") && !strings.HasSuffix(path, "/loki/api/v1/query_range") {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxObservedResponseSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxObservedResponseSize {
+		// send what was read followed by the rest of the body
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if series := countSeries(body, resp.Header.Get("Content-Encoding")); series > p.cfg.MaxSeries {
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    GuardrailExceededCode,
+			Message: fmt.Sprintf("the query returns %d series, more than the maxSeries guardrail of %d", series, p.cfg.MaxSeries),
+			Details: GuardrailViolation{Guardrail: "maxSeries", Limit: strconv.Itoa(p.cfg.MaxSeries), Value: strconv.Itoa(series)},
+		}
+	}
+	return nil
+}
+
+// checkResponseSize rejects the responses whose Content-Length exceeds
+// MaxResponseSize before anything is sent to the client, the other responses
+// are streamed and cut once MaxResponseSize bytes are sent
+func (p *Proxy) checkResponseSize(resp *http.Response) error {
+	if p.cfg.MaxResponseSize <= 0 {
+		return nil
+	}
+	if resp.ContentLength > p.cfg.MaxResponseSize {
+		return responseTooLargeError(p.cfg.MaxResponseSize, resp.ContentLength)
+	}
+	if resp.ContentLength < 0 {
+		resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: p.cfg.MaxResponseSize, limit: p.cfg.MaxResponseSize}
+	}
+	return nil
+}
+
+func responseTooLargeError(limit int64, size int64) *Error {
+	value := "unknown"
+	if size >= 0 {
+		value = strconv.FormatInt(size, 10)
+	}
+	return &Error{
+		Status:  http.StatusInsufficientStorage,
+		Code:    ResponseTooLargeCode,
+		Message: fmt.Sprintf("the upstream response is larger than the maxResponseSize guardrail of %d bytes, narrow down the query", limit),
+		Details: GuardrailViolation{Guardrail: "maxResponseSize", Limit: strconv.FormatInt(limit, 10), Value: value},
+	}
+}
+
+// limitedBody fails the reads past limit bytes without buffering the body
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// a single byte more tells a body of exactly limit bytes apart
+		var extra [1]byte
+		n, err := b.ReadCloser.Read(extra[:])
+		if n > 0 {
+			return 0, responseTooLargeError(b.limit, -1)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// countSeries returns the number of series of a metric query response, -1
+// for the log queries and when the response cannot be decoded
+func countSeries(data []byte, encoding string) int {
+	reader, ok := responseReader(data, encoding)
+	if !ok {
+		return -1
+	}
+
+	response := struct {
+		Data struct {
+			ResultType string            `json:"resultType"`
+			Result     []json.RawMessage `json:"result"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(reader).Decode(&response); err != nil {
+		return -1
+	}
+	if response.Data.ResultType != "matrix" && response.Data.ResultType != "vector" {
+		return -1
+	}
+	return len(response.Data.Result)
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxSeries(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("query") {
+		case "streams":
+			w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[{},{},{}]}}`))
+		case "matrix":
+			w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{},{},{}]}}`))
+		default:
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{},{}]}}`))
+		}
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	var rejected *Error
+	p := New(Config{URL: upstreamURL, UseTenantInHeader: true, MaxSeries: 2, ErrorHandler: func(w http.ResponseWriter, r *http.Request, err *Error) {
+		rejected = err
+		http.Error(w, err.Message, err.Status)
+	}})
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{name: "log query", path: "/application/loki/api/v1/query_range?query=streams", expectedStatus: http.StatusOK},
+		{name: "series under the limit", path: "/application/loki/api/v1/query?query=vector", expectedStatus: http.StatusOK},
+		{name: "series over the limit", path: "/application/loki/api/v1/query_range?query=matrix", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejected = nil
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expectedStatus == http.StatusOK {
+				require.Nil(t, rejected)
+				require.Contains(t, w.Body.String(), `"status":"success"`)
+				return
+			}
+			require.Equal(t, GuardrailExceededCode, rejected.Code)
+			require.Equal(t, "the query returns 3 series, more than the maxSeries guardrail of 2", rejected.Message)
+			require.Equal(t, GuardrailViolation{Guardrail: "maxSeries", Limit: "2", Value: "3"}, rejected.Details)
+		})
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	body := []byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("query") == "streamed" {
+			// flushing before the end of the body leaves its length unknown
+			w.Write(body[:10])
+			w.(http.Flusher).Flush()
+			w.Write(body[10:])
+			return
+		}
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name            string
+		maxResponseSize int64
+		query           string
+		expectedStatus  int
+		expectedBody    string
+	}{
+		{name: "under the limit", maxResponseSize: int64(len(body)), query: "buffered", expectedStatus: http.StatusOK, expectedBody: string(body)},
+		{name: "streamed under the limit", maxResponseSize: int64(len(body)), query: "streamed", expectedStatus: http.StatusOK, expectedBody: string(body)},
+		{name: "over the limit", maxResponseSize: 20, query: "buffered", expectedStatus: http.StatusInsufficientStorage},
+		{name: "streamed over the limit", maxResponseSize: 20, query: "streamed", expectedStatus: http.StatusOK, expectedBody: string(body[:20])},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var rejected *Error
+			p := New(Config{URL: upstreamURL, UseTenantInHeader: true, MaxResponseSize: tc.maxResponseSize, ErrorHandler: func(w http.ResponseWriter, r *http.Request, err *Error) {
+				rejected = err
+				http.Error(w, err.Message, err.Status)
+			}})
+
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?query="+tc.query, nil))
+			require.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedStatus == http.StatusInsufficientStorage {
+				require.Equal(t, ResponseTooLargeCode, rejected.Code)
+				require.Equal(t, GuardrailViolation{Guardrail: "maxResponseSize", Limit: "20", Value: strconv.Itoa(len(body))}, rejected.Details)
+				return
+			}
+			require.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+
+	// the decoded responses are bounded too
+	client := NewClient(Config{URL: upstreamURL, UseTenantInHeader: true, MaxResponseSize: 20})
+	for _, query := range []string{"buffered", "streamed"} {
+		err := client.get(httptest.NewRequest(http.MethodGet, "/", nil), "application", "/loki/api/v1/query_range", url.Values{"query": {query}}, &struct{}{})
+		var tooLarge *Error
+		require.ErrorAs(t, err, &tooLarge, query)
+		require.Equal(t, http.StatusInsufficientStorage, tooLarge.Status)
+	}
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const labelsEndpoint = "/loki/api/v1/labels"
+
+// LabelsRequest selects the label names or the values of a label of the
+// streams of query over a range
+type LabelsRequest struct {
+	// Query is the stream selector restricting the streams, every stream
+	// when empty
+	Query string
+	Start time.Time
+	End   time.Time
+}
+
+type labelsResponse struct {
+	Status string   `json:"status"`
+	Data   []string `json:"data"`
+}
+
+func (req LabelsRequest) params() url.Values {
+	params := url.Values{}
+	if req.Query != "" {
+		params.Set("query", req.Query)
+	}
+	params.Set("start", strconv.FormatInt(req.Start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(req.End.UnixNano(), 10))
+	return params
+}
+
+// Labels returns the label names of the streams of req
+func (c *Client) Labels(r *http.Request, tenant string, req LabelsRequest) ([]string, error) {
+	resp := &labelsResponse{}
+	if err := c.get(r, tenant, labelsEndpoint, req.params(), resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// LabelValues returns the values of the label name of the streams of req
+func (c *Client) LabelValues(r *http.Request, tenant string, name string, req LabelsRequest) ([]string, error) {
+	resp := &labelsResponse{}
+	if err := c.get(r, tenant, "/loki/api/v1/label/"+url.PathEscape(name)+"/values", req.params(), resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
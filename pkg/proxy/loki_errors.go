@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// error codes of the well-known Loki errors, returned instead of the Loki
+// response body
+const (
+	LokiRateLimitedCode      = "LokiRateLimited"
+	LokiMaxEntriesLimitCode  = "LokiMaxEntriesLimit"
+	LokiMaxSeriesLimitCode   = "LokiMaxSeriesLimit"
+	LokiQueryLengthLimitCode = "LokiQueryLengthLimit"
+	LokiBytesReadLimitCode   = "LokiBytesReadLimit"
+	LokiQueryTimeoutCode     = "LokiQueryTimeout"
+)
+
+// maxLokiErrorSize bounds the Loki error bodies read to recognize them
+const maxLokiErrorSize = 64 << 10
+
+// LokiErrorDetails are the details of a well-known Loki error
+type LokiErrorDetails struct {
+	// Hint suggests how to get the query through
+	Hint string `json:"hint"`
+	// Upstream is the error message of Loki
+	Upstream string `json:"upstream"`
+}
+
+// lokiErrorRule recognizes a Loki error by its status, any status when 0,
+// and by its message when pattern is set
+type lokiErrorRule struct {
+	code    string
+	status  int
+	pattern *regexp.Regexp
+	message string
+	hint    string
+}
+
+var lokiErrorRules = []lokiErrorRule{
+	{
+		code:    LokiMaxEntriesLimitCode,
+		status:  http.StatusBadRequest,
+		pattern: regexp.MustCompile(`max entries limit per query exceeded`),
+		message: "the query asks for more lines than the Loki limit",
+		hint:    "lower the number of lines of the query, the limit is max_entries_limit_per_query in the Loki limits",
+	},
+	{
+		code:    LokiMaxSeriesLimitCode,
+		status:  http.StatusBadRequest,
+		pattern: regexp.MustCompile(`maximum (number )?of series`),
+		message: "the query returns more series than the Loki limit",
+		hint:    "aggregate the query with by or without, or add label matchers; the limit is max_query_series in the Loki limits",
+	},
+	{
+		code:    LokiQueryLengthLimitCode,
+		status:  http.StatusBadRequest,
+		pattern: regexp.MustCompile(`query time range exceeds the limit`),
+		message: "the query range is longer than the Loki limit",
+		hint:    "shorten the time range of the query, the limit is max_query_length in the Loki limits",
+	},
+	{
+		code:    LokiBytesReadLimitCode,
+		status:  http.StatusBadRequest,
+		pattern: regexp.MustCompile(`would read too many bytes`),
+		message: "the query would read more data than the Loki limit",
+		hint:    "add label matchers or shorten the time range of the query, the limit is max_query_bytes_read in the Loki limits",
+	},
+	{
+		code:    LokiRateLimitedCode,
+		status:  http.StatusTooManyRequests,
+		message: "the tenant has too many queries in Loki",
+		hint:    "retry later, the queued queries of each tenant are bounded by max_outstanding_requests_per_tenant in the Loki config",
+	},
+	{
+		code:    LokiQueryTimeoutCode,
+		status:  http.StatusGatewayTimeout,
+		message: "the query timed out in Loki",
+		hint:    "shorten the time range or add label matchers, the exact matchers are faster than the regular expressions; the timeout is query_timeout in the Loki limits",
+	},
+	{
+		code:    LokiQueryTimeoutCode,
+		pattern: regexp.MustCompile(`(?i)request timed out|query timed out|context deadline exceeded`),
+		message: "the query timed out in Loki",
+		hint:    "shorten the time range or add label matchers, the exact matchers are faster than the regular expressions; the timeout is query_timeout in the Loki limits",
+	},
+}
+
+// lokiError returns the typed error of the well-known Loki error of status
+// and body data, nil for the other responses. The Retry-After delay of the
+// response is kept
+func lokiError(upstream string, status int, header http.Header, data []byte) *Error {
+	message := lokiErrorMessage(data)
+	for _, rule := range lokiErrorRules {
+		if (rule.status != 0 && rule.status != status) || (rule.pattern != nil && !rule.pattern.MatchString(message)) {
+			continue
+		}
+
+		metrics.LokiErrorsTotal.WithLabelValues(upstream, rule.code).Inc()
+		err := &Error{
+			Status:  status,
+			Code:    rule.code,
+			Message: rule.message,
+			Details: LokiErrorDetails{Hint: rule.hint, Upstream: message},
+		}
+		if retryAfter, ok := parseRetryAfter(header.Get("Retry-After")); ok {
+			err.RetryAfter = retryAfter
+		}
+		return err
+	}
+	return nil
+}
+
+// lokiErrorMessage returns the message of a Loki error body, in plain text or
+// in the error field of a JSON body
+func lokiErrorMessage(data []byte) string {
+	response := struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}{}
+	if json.Unmarshal(data, &response) == nil {
+		if response.Error != "" {
+			return response.Error
+		}
+		if response.Message != "" {
+			return response.Message
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// checkLokiError replaces the well-known Loki error responses with their
+// typed error, the other responses are sent as they are
+func (p *Proxy) checkLokiError(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxLokiErrorSize))
+	// send what was read followed by the rest of the body
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+	if err != nil {
+		return nil
+	}
+
+	reader, ok := responseReader(data, resp.Header.Get("Content-Encoding"))
+	if !ok {
+		return nil
+	}
+	decoded, err := io.ReadAll(io.LimitReader(reader, maxLokiErrorSize))
+	if err != nil {
+		return nil
+	}
+	if lokiErr := lokiError(p.cfg.Name, resp.StatusCode, resp.Header, decoded); lokiErr != nil {
+		return lokiErr
+	}
+	return nil
+}
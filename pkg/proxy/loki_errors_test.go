@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLokiErrors(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("query") {
+		case "entries":
+			http.Error(w, "max entries limit per query exceeded, limit > max_entries_limit (10000 > 5000)", http.StatusBadRequest)
+		case "series":
+			http.Error(w, "maximum of series (500) reached for a single query", http.StatusBadRequest)
+		case "length":
+			http.Error(w, "the query time range exceeds the limit (query length: 1000h0m0s, limit: 721h0m0s)", http.StatusBadRequest)
+		case "bytes":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"the query would read too many bytes (query: 20 GiB, limit: 10 GiB)"}`))
+		case "outstanding":
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "too many outstanding requests", http.StatusTooManyRequests)
+		case "timeout":
+			http.Error(w, "Request timed out, decrease the duration of the request or add more label matchers", http.StatusServiceUnavailable)
+		case "gateway":
+			http.Error(w, "upstream request timeout", http.StatusGatewayTimeout)
+		default:
+			http.Error(w, "parse error at line 1, col 1: syntax error", http.StatusBadRequest)
+		}
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	var rejected *Error
+	p := New(Config{Name: "loki-errors", URL: upstreamURL, UseTenantInHeader: true, ErrorHandler: func(w http.ResponseWriter, r *http.Request, err *Error) {
+		rejected = err
+		http.Error(w, err.Message, err.Status)
+	}})
+
+	tests := []struct {
+		query              string
+		expectedStatus     int
+		expectedCode       string
+		expectedUpstream   string
+		expectedRetryAfter time.Duration
+	}{
+		{query: "entries", expectedStatus: http.StatusBadRequest, expectedCode: LokiMaxEntriesLimitCode, expectedUpstream: "max entries limit per query exceeded, limit > max_entries_limit (10000 > 5000)"},
+		{query: "series", expectedStatus: http.StatusBadRequest, expectedCode: LokiMaxSeriesLimitCode, expectedUpstream: "maximum of series (500) reached for a single query"},
+		{query: "length", expectedStatus: http.StatusBadRequest, expectedCode: LokiQueryLengthLimitCode, expectedUpstream: "the query time range exceeds the limit (query length: 1000h0m0s, limit: 721h0m0s)"},
+		{query: "bytes", expectedStatus: http.StatusBadRequest, expectedCode: LokiBytesReadLimitCode, expectedUpstream: "the query would read too many bytes (query: 20 GiB, limit: 10 GiB)"},
+		{query: "outstanding", expectedStatus: http.StatusTooManyRequests, expectedCode: LokiRateLimitedCode, expectedUpstream: "too many outstanding requests", expectedRetryAfter: 5 * time.Second},
+		{query: "timeout", expectedStatus: http.StatusServiceUnavailable, expectedCode: LokiQueryTimeoutCode, expectedUpstream: "Request timed out, decrease the duration of the request or add more label matchers"},
+		{query: "gateway", expectedStatus: http.StatusGatewayTimeout, expectedCode: LokiQueryTimeoutCode, expectedUpstream: "upstream request timeout"},
+		{query: "syntax", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rejected = nil
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?query="+tt.query, nil))
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedCode == "" {
+				// the other errors are sent as they are
+				require.Nil(t, rejected)
+				require.Equal(t, "parse error at line 1, col 1: syntax error", strings.TrimSpace(w.Body.String()))
+				return
+			}
+
+			require.NotNil(t, rejected)
+			require.Equal(t, tt.expectedCode, rejected.Code)
+			require.Equal(t, tt.expectedUpstream, rejected.Details.(LokiErrorDetails).Upstream)
+			require.NotEmpty(t, rejected.Details.(LokiErrorDetails).Hint)
+			require.Equal(t, tt.expectedRetryAfter, rejected.RetryAfter)
+		})
+	}
+	require.Equal(t, float64(2), testutil.ToFloat64(metrics.LokiErrorsTotal.WithLabelValues("loki-errors", LokiQueryTimeoutCode)))
+}
+
+func TestClientLokiErrors(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "max entries limit per query exceeded, limit > max_entries_limit (10000 > 5000)", http.StatusBadRequest)
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	client := NewClient(Config{URL: upstreamURL, UseTenantInHeader: true})
+	err = client.get(httptest.NewRequest(http.MethodGet, "/", nil), "application", queryRangeEndpoint, url.Values{"query": {`{app="api"}`}}, &struct{}{})
+	var lokiErr *Error
+	require.ErrorAs(t, err, &lokiErr)
+	require.Equal(t, LokiMaxEntriesLimitCode, lokiErr.Code)
+	require.Equal(t, http.StatusBadRequest, lokiErr.Status)
+}
@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"time"
+)
+
+// maxObservedResponseSize bounds the size of the responses decoded to count
+// their results, the larger ones are reported without counts
+const maxObservedResponseSize = 8 << 20
+
+// observedEndpoints are the endpoints whose queries are reported to OnQuery
+var observedEndpoints = map[string]bool{
+	"/loki/api/v1/query":       true,
+	"/loki/api/v1/query_range": true,
+}
+
+// QueryStats describes a query sent to Loki
+type QueryStats struct {
+	Tenant   string
+	Endpoint string
+	Query    string
+	Start    time.Time
+	End      time.Time
+	// Duration is the time until the response body is sent back
+	Duration time.Duration
+	Status   int
+	// Results is the number of entries or series returned, -1 when unknown
+	Results int
+	// BytesProcessed is the size of the logs read by Loki, -1 when unknown
+	BytesProcessed int64
+}
+
+type queryObservation struct {
+	stats   QueryStats
+	started time.Time
+}
+
+type observationKey struct{}
+
+func newQueryObservation(tenant string, endpoint string, params url.Values) *queryObservation {
+	stats := QueryStats{Tenant: tenant, Endpoint: endpoint, Query: params.Get("query"), Results: -1, BytesProcessed: -1}
+	stats.Start, _ = ParseTime(params.Get("start"))
+	stats.End, _ = ParseTime(params.Get("end"))
+	return &queryObservation{stats: stats, started: time.Now()}
+}
+
+// observeResponse reports the query of resp to OnQuery once its body is read
+func (p *Proxy) observeResponse(ctx context.Context, obs *queryObservation, status int, body io.ReadCloser, encoding string) io.ReadCloser {
+	obs.stats.Status = status
+	if body == nil {
+		p.reportQuery(ctx, obs, nil, "")
+		return nil
+	}
+	return &observedBody{ReadCloser: body, onClose: func(data []byte) {
+		p.reportQuery(ctx, obs, data, encoding)
+	}}
+}
+
+func (p *Proxy) reportQuery(ctx context.Context, obs *queryObservation, data []byte, encoding string) {
+	obs.stats.Duration = time.Since(obs.started)
+	if data != nil {
+		obs.stats.Results, obs.stats.BytesProcessed = countResults(data, encoding)
+	}
+	p.cfg.OnQuery(ctx, obs.stats)
+}
+
+// observedBody keeps a copy of the body read by the client
+type observedBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	overflow bool
+	closed   bool
+	onClose  func(data []byte)
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.overflow {
+		if b.buf.Len()+n > maxObservedResponseSize {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		var data []byte
+		if !b.overflow {
+			data = b.buf.Bytes()
+		}
+		b.onClose(data)
+	}
+	return b.ReadCloser.Close()
+}
+
+// countResults returns the number of entries or series of a query response
+// and the bytes processed by Loki, -1 when they cannot be decoded
+func countResults(data []byte, encoding string) (int, int64) {
+	reader, ok := responseReader(data, encoding)
+	if !ok {
+		return -1, -1
+	}
+
+	response := struct {
+		Data struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Values []json.RawMessage `json:"values"`
+			} `json:"result"`
+			Stats struct {
+				Summary struct {
+					TotalBytesProcessed *int64 `json:"totalBytesProcessed"`
+				} `json:"summary"`
+			} `json:"stats"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(reader).Decode(&response); err != nil {
+		return -1, -1
+	}
+
+	results := len(response.Data.Result)
+	if response.Data.ResultType == "streams" {
+		results = 0
+		for _, stream := range response.Data.Result {
+			results += len(stream.Values)
+		}
+	}
+
+	bytesProcessed := int64(-1)
+	if response.Data.Stats.Summary.TotalBytesProcessed != nil {
+		bytesProcessed = *response.Data.Stats.Summary.TotalBytesProcessed
+	}
+
+	return results, bytesProcessed
+}
+
+// responseReader returns a reader of the decoded response body data, false
+// when its content encoding is not supported
+func responseReader(data []byte, encoding string) (io.Reader, bool) {
+	var reader io.Reader = bytes.NewReader(data)
+	switch encoding {
+	case "":
+		return reader, true
+	case "gzip":
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, false
+		}
+		return io.LimitReader(gz, 4*maxObservedResponseSize), true
+	}
+	return nil, false
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnQuery(t *testing.T) {
+	const streams = `{"status":"success","data":{"resultType":"streams","result":[{"stream":{},"values":[["1","a"],["2","b"]]},{"stream":{},"values":[["3","c"]]}],"stats":{"summary":{"totalBytesProcessed":1024}}}}`
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{}},{"metric":{}}]}}`))
+	gz.Close()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("query") {
+		case "gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped.Bytes())
+		case "error":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("parse error"))
+		default:
+			w.Write([]byte(streams))
+		}
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected *QueryStats
+	}{
+		{
+			name:     "streams",
+			path:     "/application/loki/api/v1/query_range?query=streams&start=1700000000&end=1700003600",
+			expected: &QueryStats{Tenant: "application", Endpoint: "/loki/api/v1/query_range", Query: "streams", Start: time.Unix(1700000000, 0), End: time.Unix(1700003600, 0), Status: http.StatusOK, Results: 3, BytesProcessed: 1024},
+		},
+		{
+			name:     "compressed matrix",
+			path:     "/application/loki/api/v1/query?query=gzip",
+			expected: &QueryStats{Tenant: "application", Endpoint: "/loki/api/v1/query", Query: "gzip", Status: http.StatusOK, Results: 2, BytesProcessed: -1},
+		},
+		{
+			name:     "error",
+			path:     "/application/loki/api/v1/query?query=error",
+			expected: &QueryStats{Tenant: "application", Endpoint: "/loki/api/v1/query", Query: "error", Status: http.StatusBadRequest, Results: -1, BytesProcessed: -1},
+		},
+		{
+			name: "not observed endpoint",
+			path: "/application/loki/api/v1/labels",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			upstreamURL, err := url.Parse(upstream.URL)
+			require.NoError(t, err)
+
+			var observed *QueryStats
+			p := New(Config{URL: upstreamURL, OnQuery: func(_ context.Context, stats QueryStats) {
+				observed = &stats
+			}})
+
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if tc.expected == nil {
+				require.Nil(t, observed)
+				return
+			}
+			require.NotNil(t, observed)
+			require.Greater(t, observed.Duration, time.Duration(0))
+			observed.Duration = 0
+			require.Equal(t, *tc.expected, *observed)
+		})
+	}
+}
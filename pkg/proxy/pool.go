@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// ObserveDials records the connections dialed by transport in the dial
+// metrics of the upstream name
+func ObserveDials(name string, transport *http.Transport) {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dial(ctx, network, address)
+		metrics.UpstreamDialDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		metrics.UpstreamDialsTotal.WithLabelValues(name, result).Inc()
+		return conn, err
+	}
+}
+
+// poolTransport counts the requests sent on a reused connection of the pool
+// of the transport
+type poolTransport struct {
+	name      string
+	transport http.RoundTripper
+}
+
+func newPoolTransport(name string, transport http.RoundTripper) *poolTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &poolTransport{name: name, transport: transport}
+}
+
+func (t *poolTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				metrics.UpstreamConnectionsReusedTotal.WithLabelValues(t.name).Inc()
+			}
+		},
+	}
+	return t.transport.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+}
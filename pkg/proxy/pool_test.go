@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolMetrics(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer upstream.Close()
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	defer transport.CloseIdleConnections()
+	ObserveDials("pooled", transport)
+
+	dials := testutil.ToFloat64(metrics.UpstreamDialsTotal.WithLabelValues("pooled", "success"))
+	reused := testutil.ToFloat64(metrics.UpstreamConnectionsReusedTotal.WithLabelValues("pooled"))
+	p := New(Config{URL: upstreamURL, Name: "pooled", Transport: transport})
+
+	// the second request reuses the idle connection of the first one
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/labels", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	require.Equal(t, dials+1, testutil.ToFloat64(metrics.UpstreamDialsTotal.WithLabelValues("pooled", "success")))
+	require.Equal(t, reused+1, testutil.ToFloat64(metrics.UpstreamConnectionsReusedTotal.WithLabelValues("pooled")))
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedURL := "http://" + closed.Addr().String()
+	closed.Close()
+
+	failedDials := testutil.ToFloat64(metrics.UpstreamDialsTotal.WithLabelValues("pooled", "error"))
+	_, err = (&http.Client{Transport: transport}).Get(closedURL)
+	require.Error(t, err)
+	require.Equal(t, failedDials+1, testutil.ToFloat64(metrics.UpstreamDialsTotal.WithLabelValues("pooled", "error")))
+}
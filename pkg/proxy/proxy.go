@@ -0,0 +1,502 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/openshift/logging-view-plugin/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("module", "proxy")
+
+// TenantHeader is the header used by Loki to select the tenant
+const TenantHeader = "X-Scope-OrgID"
+
+// routing modes of the tenant of the upstream requests
+const (
+	// TenantRoutingPath prefixes the Loki paths with the LokiStack gateway
+	// `/api/logs/v1/<tenant>` path
+	TenantRoutingPath = "path"
+	// TenantRoutingHeader sends the tenant in the X-Scope-OrgID header
+	TenantRoutingHeader = "header"
+	// TenantRoutingNone sends no tenant, for the single tenant Loki
+	TenantRoutingNone = "none"
+)
+
+// RequestIDHeader is the header carrying the request ID, forwarded upstream to
+// correlate the logs
+const RequestIDHeader = "X-Request-Id"
+
+// UpstreamTimeoutHeader tells the deadline applied to the upstream request,
+// scaled with the query range when the range timeouts are set
+const UpstreamTimeoutHeader = "X-Upstream-Timeout"
+
+// ImpersonateUserHeader and ImpersonateGroupHeader are the Kubernetes
+// impersonation headers, sent by the console in the "Impersonate user" mode
+const (
+	ImpersonateUserHeader  = "Impersonate-User"
+	ImpersonateGroupHeader = "Impersonate-Group"
+)
+
+var (
+	tenantRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	// queryEndpointRegexp matches the read only Loki endpoints that can be proxied
+	queryEndpointRegexp = regexp.MustCompile(`^/loki/api/v1/(query|query_range|labels|label/[^/]+/values|series|index/stats|index/volume|index/volume_range)$`)
+	// metadataEndpointRegexp matches the Loki label and series endpoints
+	metadataEndpointRegexp = regexp.MustCompile(`^/loki/api/v1/(labels|label/[^/]+/values|series)$`)
+)
+
+// forwardedHeaders are the request headers sent upstream, others like the
+// console session cookie are dropped
+var forwardedHeaders = []string{
+	"Authorization",
+	"Accept",
+	"Accept-Encoding",
+	"User-Agent",
+	RequestIDHeader,
+}
+
+// Config holds the upstream Loki settings of the proxy
+type Config struct {
+	// URL is the Loki or LokiStack gateway base URL
+	URL *url.URL
+	// UseTenantInHeader sends the tenant in the X-Scope-OrgID header instead of
+	// the LokiStack gateway `/api/logs/v1/<tenant>` path prefix
+	UseTenantInHeader bool
+	// TenantRouting is TenantRoutingPath, TenantRoutingHeader or
+	// TenantRoutingNone, UseTenantInHeader selects the path or header mode
+	// when unset
+	TenantRouting string
+	// Timeout bounds every upstream request when set
+	Timeout time.Duration
+	// TenantTimeouts overrides Timeout for the requests of some tenants
+	TenantTimeouts map[string]time.Duration
+	// MinTimeout and TimeoutPerHour scale the timeout of a request with its
+	// query range, MinTimeout plus TimeoutPerHour per hour of range, up to
+	// the tenant timeout, when MinTimeout is set
+	MinTimeout     time.Duration
+	TimeoutPerHour time.Duration
+	// Transport is used for the upstream requests, http.DefaultTransport if nil
+	Transport http.RoundTripper
+	// ErrorHandler replies to the requests that cannot be proxied
+	ErrorHandler func(http.ResponseWriter, *http.Request, *Error)
+	// Tracer records a client span per upstream request when set
+	Tracer *tracing.Tracer
+	// Cache caches the query responses when enabled
+	Cache CacheConfig
+	// Token returns the bearer token sent to Loki instead of the one of the
+	// user when set, like the service account token of the plugin
+	Token func() (string, error)
+	// OnQuery is called with the stats of the queries sent to Loki when set,
+	// the cached responses are not reported
+	OnQuery func(ctx context.Context, stats QueryStats)
+	// Name identifies the upstream in the metrics
+	Name string
+	// MaxInFlight bounds the queries proxied to Loki at the same time when
+	// set, the others are rejected
+	MaxInFlight int
+	// Breaker rejects the requests while Loki is failing when set
+	Breaker *Breaker
+	// MaxSeries rejects the metric queries returning more series when set
+	MaxSeries int
+	// MaxResponseSize bounds the size in bytes of the upstream responses when
+	// set, the larger ones are rejected or cut without being buffered
+	MaxResponseSize int64
+	// ForwardImpersonation forwards the Impersonate-User and Impersonate-Group
+	// headers to Loki, they are dropped otherwise
+	ForwardImpersonation bool
+	// Retry retries the queries failing with a transient error when enabled
+	Retry RetryConfig
+	// Shadow mirrors a share of the queries to a secondary Loki when set
+	Shadow *Shadow
+}
+
+// Error is a request that cannot be proxied
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Err     error
+	// RetryAfter is the time to wait before sending the request again when
+	// set
+	RetryAfter time.Duration
+	// Details are the structured details of the error, like the
+	// GuardrailViolation of the requests rejected by a guardrail
+	Details interface{}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Proxy forwards LogQL queries to Loki with the bearer token of the user. It
+// serves requests with the `/<tenant>/loki/api/v1/<endpoint>` path
+type Proxy struct {
+	cfg          Config
+	endpoints    *regexp.Regexp
+	reverseProxy *httputil.ReverseProxy
+	cache        *responseCache
+	inFlight     chan struct{}
+}
+
+type tenantKey struct{}
+
+// New builds a Loki proxy
+func New(cfg Config) *Proxy {
+	p := &Proxy{cfg: cfg, endpoints: queryEndpointRegexp, cache: newResponseCache(cfg.Cache)}
+	if p.cache != nil {
+		p.cache.upstream = cfg.Name
+	}
+	if cfg.MaxInFlight > 0 {
+		p.inFlight = make(chan struct{}, cfg.MaxInFlight)
+	}
+
+	if p.cfg.ErrorHandler == nil {
+		p.cfg.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err *Error) {
+			http.Error(w, err.Message, err.Status)
+		}
+	}
+
+	p.reverseProxy = &httputil.ReverseProxy{
+		Director:       p.director,
+		Transport:      cfg.transport(),
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			// the responses rejected by a guardrail and the well-known
+			// Loki errors are not upstream failures
+			var guardrailErr *Error
+			if errors.As(err, &guardrailErr) {
+				if obs, ok := r.Context().Value(observationKey{}).(*queryObservation); ok {
+					p.observeResponse(r.Context(), obs, guardrailErr.Status, nil, "")
+				}
+				p.cfg.ErrorHandler(w, r, guardrailErr)
+				return
+			}
+
+			tracing.SpanFromContext(r.Context()).SetError(err)
+			log.WithError(err).WithField("request_id", r.Header.Get(RequestIDHeader)).Warnf("cannot proxy request to %s", r.URL.Path)
+			metrics.UpstreamErrorsTotal.WithLabelValues("loki", "unavailable").Inc()
+
+			status := http.StatusBadGateway
+			if errors.Is(err, context.DeadlineExceeded) {
+				status = http.StatusGatewayTimeout
+			}
+			// the requests canceled by the clients say nothing about Loki
+			if !errors.Is(err, context.Canceled) {
+				p.cfg.Breaker.record(true)
+			}
+			if q, ok := r.Context().Value(shadowKey{}).(*shadowQuery); ok {
+				go q.compare(status, nil, "")
+			}
+			if obs, ok := r.Context().Value(observationKey{}).(*queryObservation); ok {
+				p.observeResponse(r.Context(), obs, status, nil, "")
+			}
+			p.cfg.ErrorHandler(w, r, &Error{Status: status, Code: "UpstreamUnavailable", Message: "cannot reach Loki", Err: err})
+		},
+	}
+
+	return p
+}
+
+// NewMetadata builds a Loki proxy of the label and series endpoints only,
+// queried by the label selectors of the UI. The responses of every endpoint,
+// including the label values, are cached when the cache is enabled
+func NewMetadata(cfg Config) *Proxy {
+	p := New(cfg)
+	p.endpoints = metadataEndpointRegexp
+	if p.cache != nil {
+		p.cache.cacheable = metadataEndpointRegexp.MatchString
+	}
+	return p
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	endpoint = "/" + endpoint
+
+	if !tenantRegexp.MatchString(tenant) {
+		p.cfg.ErrorHandler(w, r, &Error{Status: http.StatusBadRequest, Code: "InvalidTenant", Message: fmt.Sprintf("invalid tenant %q", tenant)})
+		return
+	}
+
+	if !p.endpoints.MatchString(endpoint) {
+		p.cfg.ErrorHandler(w, r, &Error{Status: http.StatusNotFound, Code: "NotFound", Message: fmt.Sprintf("unsupported Loki endpoint %s", endpoint)})
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		p.cfg.ErrorHandler(w, r, &Error{Status: http.StatusMethodNotAllowed, Code: "MethodNotAllowed", Message: fmt.Sprintf("method %s not allowed", r.Method)})
+		return
+	}
+
+	r, tokenErr := p.cfg.withUpstreamToken(r)
+	if tokenErr != nil {
+		p.cfg.ErrorHandler(w, r, tokenErr)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), tenantKey{}, tenant)
+	if timeout := p.cfg.timeout(tenant, r.URL.Query()); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		w.Header().Set(UpstreamTimeoutHeader, timeout.String())
+	}
+
+	ctx, span := p.cfg.Tracer.Start(ctx, "loki "+endpoint, tracing.KindClient, upstreamAttributes(tenant, endpoint, r.URL.Query())...)
+	defer span.End()
+
+	upstreamURL := *r.URL
+	upstreamURL.Path = endpoint
+	upstreamURL.RawPath = ""
+
+	if p.cfg.OnQuery != nil && observedEndpoints[endpoint] {
+		ctx = context.WithValue(ctx, observationKey{}, newQueryObservation(tenant, endpoint, r.URL.Query()))
+	}
+
+	if p.cache != nil {
+		var served bool
+		if r, served = p.cache.serve(w, r.WithContext(ctx), tenant, endpoint); served {
+			return
+		}
+		ctx = r.Context()
+	}
+
+	if breakerErr := p.cfg.Breaker.allow(); breakerErr != nil {
+		p.cfg.ErrorHandler(w, r, breakerErr)
+		return
+	}
+
+	if !p.acquire() {
+		metrics.UpstreamRejectedTotal.WithLabelValues(p.cfg.Name, "max_in_flight").Inc()
+		p.cfg.ErrorHandler(w, r, &Error{Status: http.StatusServiceUnavailable, Code: "TooManyInFlight", Message: fmt.Sprintf("the maximum of %d queries in flight is reached", p.cfg.MaxInFlight), RetryAfter: time.Second})
+		return
+	}
+	defer p.release()
+
+	if observedEndpoints[endpoint] {
+		if q := p.cfg.Shadow.mirror(r.WithContext(ctx), p.cfg.Name, tenant, endpoint); q != nil {
+			ctx = context.WithValue(ctx, shadowKey{}, q)
+		}
+	}
+
+	r = r.WithContext(ctx)
+	r.URL = &upstreamURL
+
+	p.reverseProxy.ServeHTTP(w, r)
+}
+
+func (p *Proxy) acquire() bool {
+	if p.inFlight == nil {
+		return true
+	}
+	select {
+	case p.inFlight <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Proxy) release() {
+	if p.inFlight != nil {
+		<-p.inFlight
+	}
+}
+
+func (p *Proxy) director(r *http.Request) {
+	tenant := r.Context().Value(tenantKey{}).(string)
+
+	r.URL.Scheme = p.cfg.URL.Scheme
+	r.URL.Host = p.cfg.URL.Host
+	r.URL.Path = p.cfg.upstreamPath(tenant, r.URL.Path)
+	r.Host = p.cfg.URL.Host
+	r.Header = p.cfg.upstreamHeaders(r, tenant)
+}
+
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	countUpstreamErrors(resp)
+	// the mirrored query is compared once the body is read, or closed when
+	// the response is rejected
+	if q, ok := resp.Request.Context().Value(shadowKey{}).(*shadowQuery); ok {
+		status, encoding := resp.StatusCode, resp.Header.Get("Content-Encoding")
+		resp.Body = &observedBody{ReadCloser: resp.Body, onClose: func(data []byte) {
+			go q.compare(status, data, encoding)
+		}}
+	}
+	p.cfg.Breaker.record(resp.StatusCode >= http.StatusInternalServerError)
+	if err := p.checkLokiError(resp); err != nil {
+		return err
+	}
+	if err := p.checkResponseSize(resp); err != nil {
+		return err
+	}
+	if err := p.checkSeries(resp); err != nil {
+		return err
+	}
+	if p.cache != nil {
+		if err := p.cache.store(resp); err != nil {
+			return err
+		}
+	}
+	ctx := resp.Request.Context()
+	if obs, ok := ctx.Value(observationKey{}).(*queryObservation); ok {
+		resp.Body = p.observeResponse(ctx, obs, resp.StatusCode, resp.Body, resp.Header.Get("Content-Encoding"))
+	}
+	return nil
+}
+
+func countUpstreamErrors(resp *http.Response) {
+	span := tracing.SpanFromContext(resp.Request.Context())
+	span.SetAttributes(tracing.Int("http.status_code", int64(resp.StatusCode)))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		metrics.UpstreamErrorsTotal.WithLabelValues("loki", strconv.Itoa(resp.StatusCode)).Inc()
+		span.SetError(fmt.Errorf("Loki replied with status %d", resp.StatusCode))
+	}
+}
+
+// upstreamAttributes returns the span attributes of a Loki request, the
+// query range is recorded when the start and end parameters are valid
+func upstreamAttributes(tenant string, endpoint string, query url.Values) []tracing.Attribute {
+	attributes := []tracing.Attribute{
+		tracing.String("loki.tenant", tenant),
+		tracing.String("loki.endpoint", endpoint),
+	}
+
+	start, startErr := ParseTime(query.Get("start"))
+	end, endErr := ParseTime(query.Get("end"))
+	if startErr == nil {
+		attributes = append(attributes, tracing.String("loki.query.start", start.UTC().Format(time.RFC3339)))
+	}
+	if endErr == nil {
+		attributes = append(attributes, tracing.String("loki.query.end", end.UTC().Format(time.RFC3339)))
+	}
+	if startErr == nil && endErr == nil {
+		attributes = append(attributes, tracing.Int("loki.query.range_seconds", int64(end.Sub(start).Seconds())))
+	}
+
+	return attributes
+}
+
+// ParseTime parses a Loki timestamp, either in Unix nanoseconds, Unix
+// seconds with an optional fraction or RFC3339
+func ParseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("empty timestamp")
+	}
+	if nanos, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if len(value) <= 10 {
+			return time.Unix(nanos, 0), nil
+		}
+		return time.Unix(0, nanos), nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// timeout returns the upstream request timeout of tenant, scaled with the
+// range of the start and end query parameters when MinTimeout is set. The
+// requests without a valid range get MinTimeout
+func (cfg *Config) timeout(tenant string, query url.Values) time.Duration {
+	max, ok := cfg.TenantTimeouts[tenant]
+	if !ok {
+		max = cfg.Timeout
+	}
+	if cfg.MinTimeout <= 0 {
+		return max
+	}
+
+	timeout := cfg.MinTimeout
+	start, startErr := ParseTime(query.Get("start"))
+	end, endErr := ParseTime(query.Get("end"))
+	if startErr == nil && endErr != nil {
+		end, endErr = time.Now(), nil
+	}
+	if startErr == nil && endErr == nil && end.After(start) {
+		timeout += time.Duration(end.Sub(start).Hours() * float64(cfg.TimeoutPerHour))
+	}
+
+	if max > 0 && timeout > max {
+		return max
+	}
+	return timeout
+}
+
+// tenantRouting returns the routing mode of the tenant of the upstream
+// requests
+func (cfg *Config) tenantRouting() string {
+	switch {
+	case cfg.TenantRouting != "":
+		return cfg.TenantRouting
+	case cfg.UseTenantInHeader:
+		return TenantRoutingHeader
+	default:
+		return TenantRoutingPath
+	}
+}
+
+// upstreamPath returns the Loki path of endpoint for tenant
+func (cfg *Config) upstreamPath(tenant string, endpoint string) string {
+	if cfg.tenantRouting() == TenantRoutingPath {
+		endpoint = fmt.Sprintf("/api/logs/v1/%s%s", tenant, endpoint)
+	}
+	return strings.TrimSuffix(cfg.URL.Path, "/") + endpoint
+}
+
+type upstreamTokenKey struct{}
+
+// withUpstreamToken returns r with the token of cfg.Token, sent to Loki by
+// upstreamHeaders
+func (cfg *Config) withUpstreamToken(r *http.Request) (*http.Request, *Error) {
+	if cfg.Token == nil {
+		return r, nil
+	}
+
+	token, err := cfg.Token()
+	if err != nil {
+		log.WithError(err).WithField("request_id", r.Header.Get(RequestIDHeader)).Error("cannot get the Loki bearer token")
+		return r, &Error{Status: http.StatusServiceUnavailable, Code: "Unavailable", Message: "cannot authenticate to Loki", Err: err}
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), upstreamTokenKey{}, token)), nil
+}
+
+// upstreamHeaders returns the headers of r forwarded to Loki
+func (cfg *Config) upstreamHeaders(r *http.Request, tenant string) http.Header {
+	headers := http.Header{}
+	for _, name := range forwardedHeaders {
+		if values := r.Header.Values(name); len(values) > 0 {
+			headers[name] = values
+		}
+	}
+	if cfg.ForwardImpersonation {
+		for _, name := range []string{ImpersonateUserHeader, ImpersonateGroupHeader} {
+			if values := r.Header.Values(name); len(values) > 0 {
+				headers[name] = values
+			}
+		}
+	}
+	if token, ok := r.Context().Value(upstreamTokenKey{}).(string); ok {
+		headers.Set("Authorization", "Bearer "+token)
+	}
+	if cfg.tenantRouting() == TenantRoutingHeader {
+		headers.Set(TenantHeader, tenant)
+	}
+	tracing.Inject(r.Context(), headers)
+	return headers
+}
@@ -0,0 +1,264 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type upstreamRequest struct {
+	path          string
+	query         string
+	authorization string
+	tenant        string
+	cookie        string
+	requestID     string
+}
+
+func newTestUpstream(t *testing.T, requests chan<- upstreamRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- upstreamRequest{
+			path:          r.URL.Path,
+			query:         r.URL.RawQuery,
+			authorization: r.Header.Get("Authorization"),
+			tenant:        r.Header.Get(TenantHeader),
+			cookie:        r.Header.Get("Cookie"),
+			requestID:     r.Header.Get(RequestIDHeader),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+}
+
+func TestProxy(t *testing.T) {
+	tests := []struct {
+		name              string
+		useTenantInHeader bool
+		tenantRouting     string
+		path              string
+		method            string
+		expectedStatus    int
+		expectedUpstream  *upstreamRequest
+	}{
+		{
+			name:             "tenant in path",
+			path:             "/application/loki/api/v1/query_range?query=%7Bjob%3D%22a%22%7D",
+			expectedStatus:   http.StatusOK,
+			expectedUpstream: &upstreamRequest{path: "/gateway/api/logs/v1/application/loki/api/v1/query_range", query: "query=%7Bjob%3D%22a%22%7D", authorization: "Bearer user-token", requestID: "req-1"},
+		},
+		{
+			name:              "tenant in header",
+			useTenantInHeader: true,
+			path:              "/infrastructure/loki/api/v1/label/kubernetes_namespace_name/values",
+			expectedStatus:    http.StatusOK,
+			expectedUpstream:  &upstreamRequest{path: "/gateway/loki/api/v1/label/kubernetes_namespace_name/values", authorization: "Bearer user-token", tenant: "infrastructure", requestID: "req-1"},
+		},
+		{
+			name:             "tenant routing header",
+			tenantRouting:    TenantRoutingHeader,
+			path:             "/application/loki/api/v1/query",
+			expectedStatus:   http.StatusOK,
+			expectedUpstream: &upstreamRequest{path: "/gateway/loki/api/v1/query", authorization: "Bearer user-token", tenant: "application", requestID: "req-1"},
+		},
+		{
+			name:              "tenant routing path over useTenantInHeader",
+			useTenantInHeader: true,
+			tenantRouting:     TenantRoutingPath,
+			path:              "/application/loki/api/v1/query",
+			expectedStatus:    http.StatusOK,
+			expectedUpstream:  &upstreamRequest{path: "/gateway/api/logs/v1/application/loki/api/v1/query", authorization: "Bearer user-token", requestID: "req-1"},
+		},
+		{
+			name:             "no tenant routing",
+			tenantRouting:    TenantRoutingNone,
+			path:             "/application/loki/api/v1/query",
+			expectedStatus:   http.StatusOK,
+			expectedUpstream: &upstreamRequest{path: "/gateway/loki/api/v1/query", authorization: "Bearer user-token", requestID: "req-1"},
+		},
+		{
+			name:           "invalid tenant",
+			path:           "/..%2Fadmin/loki/api/v1/query",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unsupported endpoint",
+			path:           "/application/loki/api/v1/push",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "write method",
+			path:           "/application/loki/api/v1/query",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := make(chan upstreamRequest, 1)
+			upstream := newTestUpstream(t, requests)
+			defer upstream.Close()
+
+			upstreamURL, err := url.Parse(upstream.URL + "/gateway")
+			require.NoError(t, err)
+
+			p := New(Config{URL: upstreamURL, UseTenantInHeader: tc.useTenantInHeader, TenantRouting: tc.tenantRouting})
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, tc.path, nil)
+			r.Header.Set("Authorization", "Bearer user-token")
+			r.Header.Set("Cookie", "openshift-session-token=secret")
+			r.Header.Set(RequestIDHeader, "req-1")
+			w := httptest.NewRecorder()
+
+			p.ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedUpstream == nil {
+				require.Empty(t, requests)
+				return
+			}
+			require.Equal(t, *tc.expectedUpstream, <-requests)
+		})
+	}
+}
+
+func TestProxyToken(t *testing.T) {
+	requests := make(chan upstreamRequest, 1)
+	upstream := newTestUpstream(t, requests)
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                  string
+		token                 func() (string, error)
+		expectedStatus        int
+		expectedAuthorization string
+	}{
+		{
+			name:                  "service account token",
+			token:                 func() (string, error) { return "sa-token", nil },
+			expectedStatus:        http.StatusOK,
+			expectedAuthorization: "Bearer sa-token",
+		},
+		{
+			name:           "unreadable token",
+			token:          func() (string, error) { return "", errors.New("no such file") },
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := New(Config{URL: upstreamURL, Token: tc.token})
+
+			r := httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/labels", nil)
+			r.Header.Set("Authorization", "Bearer user-token")
+			w := httptest.NewRecorder()
+
+			p.ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedAuthorization == "" {
+				require.Empty(t, requests)
+				return
+			}
+			require.Equal(t, tc.expectedAuthorization, (<-requests).authorization)
+		})
+	}
+}
+
+func TestProxyImpersonation(t *testing.T) {
+	for _, forward := range []bool{false, true} {
+		var headers http.Header
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers = r.Header.Clone()
+		}))
+		upstreamURL, err := url.Parse(upstream.URL)
+		require.NoError(t, err)
+
+		p := New(Config{URL: upstreamURL, ForwardImpersonation: forward})
+
+		r := httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/labels", nil)
+		r.Header.Set(ImpersonateUserHeader, "developer")
+		r.Header.Add(ImpersonateGroupHeader, "devs")
+		r.Header.Add(ImpersonateGroupHeader, "qa")
+		w := httptest.NewRecorder()
+
+		p.ServeHTTP(w, r)
+		upstream.Close()
+
+		require.Equal(t, http.StatusOK, w.Code)
+		if forward {
+			require.Equal(t, "developer", headers.Get(ImpersonateUserHeader))
+			require.Equal(t, []string{"devs", "qa"}, headers.Values(ImpersonateGroupHeader))
+		} else {
+			require.Empty(t, headers.Values(ImpersonateUserHeader))
+			require.Empty(t, headers.Values(ImpersonateGroupHeader))
+		}
+	}
+}
+
+func TestProxyRangeTimeout(t *testing.T) {
+	cfg := Config{
+		Timeout:        5 * time.Minute,
+		TenantTimeouts: map[string]time.Duration{"audit": 10 * time.Minute},
+		MinTimeout:     30 * time.Second,
+		TimeoutPerHour: 10 * time.Second,
+	}
+
+	tests := []struct {
+		name     string
+		tenant   string
+		query    url.Values
+		expected time.Duration
+	}{
+		{name: "no range", tenant: "application", expected: 30 * time.Second},
+		{name: "one hour", tenant: "application", query: url.Values{"start": {"1700000000"}, "end": {"1700003600"}}, expected: 40 * time.Second},
+		{name: "one day", tenant: "application", query: url.Values{"start": {"1700000000"}, "end": {"1700086400"}}, expected: 270 * time.Second},
+		{name: "one week bounded by the timeout", tenant: "application", query: url.Values{"start": {"1700000000"}, "end": {"1700604800"}}, expected: 5 * time.Minute},
+		{name: "one week bounded by the tenant timeout", tenant: "audit", query: url.Values{"start": {"1700000000"}, "end": {"1700604800"}}, expected: 10 * time.Minute},
+		{name: "invalid range", tenant: "application", query: url.Values{"start": {"1700003600"}, "end": {"1700000000"}}, expected: 30 * time.Second},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, cfg.timeout(tc.tenant, tc.query))
+		})
+	}
+
+	// the timeout is not scaled without MinTimeout
+	require.Equal(t, 5*time.Minute, (&Config{Timeout: 5 * time.Minute}).timeout("application", url.Values{"start": {"1700000000"}}))
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	cfg.URL, _ = url.Parse(upstream.URL)
+
+	w := httptest.NewRecorder()
+	New(cfg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?start=1700000000&end=1700003600", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "40s", w.Header().Get(UpstreamTimeoutHeader))
+}
+
+func TestUpstreamAttributes(t *testing.T) {
+	query := url.Values{"start": {"1700000000000000000"}, "end": {"2023-11-14T23:13:20Z"}}
+
+	attributes := upstreamAttributes("application", "/loki/api/v1/query_range", query)
+
+	values := map[string]interface{}{}
+	for _, attribute := range attributes {
+		values[attribute.Key] = attribute.Value
+	}
+	require.Equal(t, "application", values["loki.tenant"])
+	require.Equal(t, "2023-11-14T22:13:20Z", values["loki.query.start"])
+	require.Equal(t, int64(3600), values["loki.query.range_seconds"])
+}
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// maxRetryDrainSize bounds the body read from a retried response so that its
+// connection can be reused
+const maxRetryDrainSize = 64 << 10
+
+// RetryConfig retries the idempotent upstream requests failing with a
+// transient error when MaxAttempts is greater than 1
+type RetryConfig struct {
+	// MaxAttempts is the number of attempts of a request, including the first
+	// one
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled on each retry up to
+	// MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// StatusCodes are the retried response statuses, a 429 is only retried
+	// with a Retry-After header
+	StatusCodes []int
+}
+
+// retryTransport sends the GET and HEAD requests again after a failed
+// connection or a retryable status, like while the gateway pods restart
+type retryTransport struct {
+	cfg       RetryConfig
+	name      string
+	transport http.RoundTripper
+	// sleep waits d unless ctx is done, replaced in the tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryTransport(cfg RetryConfig, name string, transport http.RoundTripper) *retryTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &retryTransport{cfg: cfg, name: name, transport: transport, sleep: sleepContext}
+}
+
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return t.transport.RoundTrip(r)
+	}
+
+	backoff := t.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := t.transport.RoundTrip(r)
+		if attempt >= t.cfg.MaxAttempts || r.Context().Err() != nil {
+			return resp, err
+		}
+
+		reason, delay, retryable := t.retryable(resp, err, backoff)
+		if !retryable || (t.cfg.MaxBackoff > 0 && delay > t.cfg.MaxBackoff) {
+			return resp, err
+		}
+		if deadline, ok := r.Context().Deadline(); ok && time.Until(deadline) < delay {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.CopyN(io.Discard, resp.Body, maxRetryDrainSize)
+			resp.Body.Close()
+		}
+
+		log.WithField("request_id", r.Header.Get(RequestIDHeader)).Debugf("retrying request to %s after %s in %s", r.URL.Path, reason, delay)
+		metrics.UpstreamRetriesTotal.WithLabelValues(t.name, reason).Inc()
+		if err := t.sleep(r.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		backoff *= 2
+		if t.cfg.MaxBackoff > 0 && backoff > t.cfg.MaxBackoff {
+			backoff = t.cfg.MaxBackoff
+		}
+	}
+}
+
+// retryable tells whether the result of an attempt is retried, the reason
+// of the retry and the time to wait before it: the Retry-After delay when
+// set, backoff otherwise
+func (t *retryTransport) retryable(resp *http.Response, err error, backoff time.Duration) (string, time.Duration, bool) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return "", 0, false
+		}
+		return "error", backoff, true
+	}
+
+	retryable := false
+	for _, code := range t.cfg.StatusCodes {
+		retryable = retryable || resp.StatusCode == code
+	}
+	if !retryable {
+		return "", 0, false
+	}
+
+	retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+	if resp.StatusCode == http.StatusTooManyRequests && !hasRetryAfter {
+		return "", 0, false
+	}
+	if hasRetryAfter {
+		return strconv.Itoa(resp.StatusCode), retryAfter, true
+	}
+	return strconv.Itoa(resp.StatusCode), backoff, true
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// transport returns the transport of the upstream requests, retrying them
+// when the retries are enabled. The reuse of the pooled connections is
+// counted on every attempt
+func (cfg *Config) transport() http.RoundTripper {
+	transport := newPoolTransport(cfg.Name, cfg.Transport)
+	if cfg.Retry.MaxAttempts <= 1 {
+		return transport
+	}
+	return newRetryTransport(cfg.Retry, cfg.Name, transport)
+}
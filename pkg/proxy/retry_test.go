@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryTransport(t *testing.T) {
+	retryConfig := RetryConfig{MaxAttempts: 3, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second, StatusCodes: []int{429, 502, 503}}
+
+	tests := []struct {
+		name             string
+		statuses         []int
+		retryAfter       string
+		method           string
+		expectedStatus   int
+		expectedAttempts int32
+		expectedWaits    []time.Duration
+	}{
+		{name: "success", statuses: []int{200}, expectedStatus: 200, expectedAttempts: 1},
+		{name: "gateway restart", statuses: []int{502, 503, 200}, expectedStatus: 200, expectedAttempts: 3, expectedWaits: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}},
+		{name: "attempts exhausted", statuses: []int{503, 503, 503, 200}, expectedStatus: 503, expectedAttempts: 3, expectedWaits: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}},
+		{name: "not retryable", statuses: []int{500, 200}, expectedStatus: 500, expectedAttempts: 1},
+		{name: "too many requests without retry-after", statuses: []int{429, 200}, expectedStatus: 429, expectedAttempts: 1},
+		{name: "too many requests with retry-after", statuses: []int{429, 200}, retryAfter: "1", expectedStatus: 200, expectedAttempts: 2, expectedWaits: []time.Duration{time.Second}},
+		{name: "retry-after longer than the max backoff", statuses: []int{503, 200}, retryAfter: "60", expectedStatus: 503, expectedAttempts: 1},
+		{name: "not idempotent", statuses: []int{503, 200}, method: http.MethodPost, expectedStatus: 503, expectedAttempts: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts int32
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := atomic.AddInt32(&attempts, 1)
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(tc.statuses[attempt-1])
+			}))
+			defer upstream.Close()
+
+			waits := []time.Duration{}
+			transport := newRetryTransport(retryConfig, "test", nil)
+			transport.sleep = func(_ context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, err := http.NewRequest(method, upstream.URL, nil)
+			require.NoError(t, err)
+			resp, err := transport.RoundTrip(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			require.Equal(t, tc.expectedStatus, resp.StatusCode)
+			require.Equal(t, tc.expectedAttempts, atomic.LoadInt32(&attempts))
+			if tc.expectedWaits == nil {
+				tc.expectedWaits = []time.Duration{}
+			}
+			require.Equal(t, tc.expectedWaits, waits)
+		})
+	}
+}
+
+func TestProxyRetry(t *testing.T) {
+	var attempts int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer upstream.Close()
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	before := testutil.ToFloat64(metrics.UpstreamRetriesTotal.WithLabelValues("retried", "503"))
+	p := New(Config{URL: upstreamURL, Name: "retried", Retry: RetryConfig{MaxAttempts: 2, Backoff: time.Millisecond, StatusCodes: []int{503}}})
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/labels", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	require.Equal(t, before+1, testutil.ToFloat64(metrics.UpstreamRetriesTotal.WithLabelValues("retried", "503")))
+}
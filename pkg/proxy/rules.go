@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// rulesEndpoint is the Prometheus compatible rules API of the Loki ruler
+const rulesEndpoint = "/prometheus/api/v1/rules"
+
+// RulesResponse is the response of the Prometheus rules API
+type RulesResponse struct {
+	Status   string    `json:"status"`
+	Data     RulesData `json:"data"`
+	Warnings []string  `json:"warnings,omitempty"`
+}
+
+// RulesData holds the rule groups of a rules response
+type RulesData struct {
+	Groups []RuleGroup `json:"groups"`
+}
+
+// RuleGroup is a group of alerting and recording rules
+type RuleGroup struct {
+	Name     string  `json:"name"`
+	File     string  `json:"file"`
+	Interval float64 `json:"interval,omitempty"`
+	Rules    []Rule  `json:"rules"`
+}
+
+// Rule is an alerting or recording rule, its fields are kept as returned by
+// the ruler
+type Rule map[string]interface{}
+
+// Labels returns the string labels of the rule
+func (r Rule) Labels() map[string]string {
+	labels := map[string]string{}
+	values, _ := r["labels"].(map[string]interface{})
+	for name, value := range values {
+		if s, ok := value.(string); ok {
+			labels[name] = s
+		}
+	}
+	return labels
+}
+
+// Rules returns the rules of tenant
+func (c *Client) Rules(r *http.Request, tenant string) (*RulesResponse, error) {
+	rules := &RulesResponse{}
+	if err := c.get(r, tenant, rulesEndpoint, url.Values{}, rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// results of the comparison of a mirrored query
+const (
+	shadowResultMatch           = "match"
+	shadowResultStatusMismatch  = "status_mismatch"
+	shadowResultResultsMismatch = "results_mismatch"
+	shadowResultError           = "error"
+	shadowResultSkipped         = "skipped"
+)
+
+// ShadowConfig mirrors a share of the queries of an upstream to a secondary
+// Loki, like the LokiStack a migration moves to. The mirrored responses are
+// discarded once their status and number of results are compared
+type ShadowConfig struct {
+	// Name identifies the secondary Loki in the metrics and the logs
+	Name string
+	// URL is the Loki or LokiStack gateway base URL of the secondary Loki
+	URL *url.URL
+	// UseTenantInHeader and TenantRouting route the tenant of the secondary
+	// Loki like the fields of Config
+	UseTenantInHeader bool
+	TenantRouting     string
+	// Transport is used for the mirrored requests, http.DefaultTransport if
+	// nil
+	Transport http.RoundTripper
+	// Percentage of the queries mirrored, from 0 to 100
+	Percentage float64
+	// Timeout bounds the mirrored requests when set
+	Timeout time.Duration
+	// MaxInFlight bounds the mirrored requests at the same time when set,
+	// the queries are not mirrored beyond
+	MaxInFlight int
+}
+
+// Shadow sends the copies of the sampled queries to the secondary Loki, in
+// the background and with the token of the query
+type Shadow struct {
+	cfg      ShadowConfig
+	upstream *Config
+	client   *http.Client
+	inFlight chan struct{}
+	// sample returns a number in [0, 100), replaced in the tests
+	sample func() float64
+}
+
+// NewShadow returns the shadow of cfg, nil when no query is mirrored
+func NewShadow(cfg ShadowConfig) *Shadow {
+	if cfg.Percentage <= 0 {
+		return nil
+	}
+
+	transport := cfg.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	s := &Shadow{
+		cfg:      cfg,
+		upstream: &Config{URL: cfg.URL, UseTenantInHeader: cfg.UseTenantInHeader, TenantRouting: cfg.TenantRouting},
+		client:   &http.Client{Transport: transport, Timeout: cfg.Timeout},
+		sample:   func() float64 { return rand.Float64() * 100 },
+	}
+	if cfg.MaxInFlight > 0 {
+		s.inFlight = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return s
+}
+
+// shadowResult is the response of a mirrored query
+type shadowResult struct {
+	status  int
+	results int
+	err     error
+}
+
+// shadowQuery is a mirrored query waiting for the response of the primary
+// upstream
+type shadowQuery struct {
+	shadow    *Shadow
+	upstream  string
+	requestID string
+	query     string
+	endpoint  string
+	result    chan shadowResult
+}
+
+type shadowKey struct{}
+
+// mirror sends the copy of r to the secondary Loki when r is sampled, nil
+// otherwise
+func (s *Shadow) mirror(r *http.Request, upstream string, tenant string, endpoint string) *shadowQuery {
+	if s == nil || s.sample() >= s.cfg.Percentage {
+		return nil
+	}
+	if s.inFlight != nil {
+		select {
+		case s.inFlight <- struct{}{}:
+		default:
+			metrics.ShadowQueriesTotal.WithLabelValues(upstream, s.cfg.Name, shadowResultSkipped).Inc()
+			return nil
+		}
+	}
+
+	u := *s.cfg.URL
+	u.Path = s.upstream.upstreamPath(tenant, endpoint)
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+
+	// the mirrored query is not canceled with the client request
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		s.release()
+		return nil
+	}
+	req.Header = s.upstream.upstreamHeaders(r, tenant)
+	// the transport decompresses the responses it asks compressed
+	req.Header.Del("Accept-Encoding")
+
+	q := &shadowQuery{
+		shadow:    s,
+		upstream:  upstream,
+		requestID: r.Header.Get(RequestIDHeader),
+		query:     r.URL.Query().Get("query"),
+		endpoint:  endpoint,
+		result:    make(chan shadowResult, 1),
+	}
+	go func() {
+		defer s.release()
+		q.result <- s.send(req)
+	}()
+	return q
+}
+
+func (s *Shadow) send(req *http.Request) shadowResult {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return shadowResult{err: err}
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxObservedResponseSize+1))
+	if err != nil {
+		return shadowResult{err: err}
+	}
+	results := -1
+	if len(data) <= maxObservedResponseSize {
+		results, _ = countResults(data, "")
+	}
+	return shadowResult{status: resp.StatusCode, results: results}
+}
+
+func (s *Shadow) release() {
+	if s.inFlight != nil {
+		<-s.inFlight
+	}
+}
+
+// compare waits for the mirrored response then compares it with the
+// primary response of status and body data, nil when the body was not
+// observed. The number of results is only compared when both are known
+func (q *shadowQuery) compare(status int, data []byte, encoding string) {
+	primaryResults := -1
+	if data != nil {
+		primaryResults, _ = countResults(data, encoding)
+	}
+	shadow := <-q.result
+
+	result := shadowResultMatch
+	switch {
+	case shadow.err != nil:
+		result = shadowResultError
+	case shadow.status != status:
+		result = shadowResultStatusMismatch
+	case primaryResults >= 0 && shadow.results >= 0 && primaryResults != shadow.results:
+		result = shadowResultResultsMismatch
+	}
+	metrics.ShadowQueriesTotal.WithLabelValues(q.upstream, q.shadow.cfg.Name, result).Inc()
+	if result == shadowResultMatch {
+		return
+	}
+
+	entry := log.WithField("request_id", q.requestID).WithField("shadow", q.shadow.cfg.Name).WithField("endpoint", q.endpoint).WithField("query", q.query)
+	if shadow.err != nil {
+		entry.WithError(shadow.err).Warn("the mirrored query failed")
+		return
+	}
+	entry.WithField("status", status).WithField("shadow_status", shadow.status).WithField("results", primaryResults).WithField("shadow_results", shadow.results).Infof("the mirrored query differs: %s", result)
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	twoEntriesResponse = `{"status":"success","data":{"resultType":"streams","result":[{"stream":{"app":"api"},"values":[["1","a"],["2","b"]]}]}}`
+	oneEntryResponse   = `{"status":"success","data":{"resultType":"streams","result":[{"stream":{"app":"api"},"values":[["1","a"]]}]}}`
+)
+
+func TestShadow(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(twoEntriesResponse))
+	}))
+	defer primary.Close()
+	primaryURL, err := url.Parse(primary.URL)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var mirrored []*http.Request
+	secondaryResponse := twoEntriesResponse
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		mirrored = append(mirrored, r)
+		response := secondaryResponse
+		mu.Unlock()
+		w.Write([]byte(response))
+	}))
+	defer secondary.Close()
+	secondaryURL, err := url.Parse(secondary.URL)
+	require.NoError(t, err)
+
+	shadow := NewShadow(ShadowConfig{Name: "next", URL: secondaryURL, TenantRouting: TenantRoutingHeader, Percentage: 50, Timeout: time.Second})
+	sampled := 10.0
+	shadow.sample = func() float64 { return sampled }
+	p := New(Config{URL: primaryURL, Name: "current", Shadow: shadow})
+
+	count := func(result string) float64 {
+		return testutil.ToFloat64(metrics.ShadowQueriesTotal.WithLabelValues("current", "next", result))
+	}
+	query := func(path string) {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer user-token")
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, twoEntriesResponse, w.Body.String())
+	}
+
+	matches := count(shadowResultMatch)
+	query(`/application/loki/api/v1/query_range?query={app="api"}`)
+	require.Eventually(t, func() bool { return count(shadowResultMatch) == matches+1 }, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	require.Len(t, mirrored, 1)
+	require.Equal(t, "/loki/api/v1/query_range", mirrored[0].URL.Path)
+	require.Equal(t, `{app="api"}`, mirrored[0].URL.Query().Get("query"))
+	require.Equal(t, "application", mirrored[0].Header.Get(TenantHeader))
+	require.Equal(t, "Bearer user-token", mirrored[0].Header.Get("Authorization"))
+	secondaryResponse = oneEntryResponse
+	mu.Unlock()
+
+	mismatches := count(shadowResultResultsMismatch)
+	query(`/application/loki/api/v1/query_range?query={app="api"}`)
+	require.Eventually(t, func() bool { return count(shadowResultResultsMismatch) == mismatches+1 }, time.Second, 10*time.Millisecond)
+
+	// the queries out of the sample and the metadata queries are not mirrored
+	sampled = 60
+	query(`/application/loki/api/v1/query_range?query={app="api"}`)
+	sampled = 10
+	query(`/application/loki/api/v1/labels`)
+	mu.Lock()
+	require.Len(t, mirrored, 2)
+	mu.Unlock()
+}
+
+func TestShadowMaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(twoEntriesResponse))
+	}))
+	defer secondary.Close()
+	defer close(release)
+	secondaryURL, err := url.Parse(secondary.URL)
+	require.NoError(t, err)
+
+	shadow := NewShadow(ShadowConfig{Name: "busy", URL: secondaryURL, Percentage: 100, MaxInFlight: 1})
+	r := httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query", nil)
+
+	skipped := testutil.ToFloat64(metrics.ShadowQueriesTotal.WithLabelValues("current", "busy", shadowResultSkipped))
+	require.NotNil(t, shadow.mirror(r, "current", "application", "/loki/api/v1/query"))
+	require.Nil(t, shadow.mirror(r, "current", "application", "/loki/api/v1/query"))
+	require.Equal(t, skipped+1, testutil.ToFloat64(metrics.ShadowQueriesTotal.WithLabelValues("current", "busy", shadowResultSkipped)))
+
+	require.Nil(t, NewShadow(ShadowConfig{Name: "off", URL: secondaryURL}))
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// StatsRequest runs a query to read the statistics Loki reports about its
+// execution
+type StatsRequest struct {
+	Query string
+	Start time.Time
+	End   time.Time
+	// Limit is the limit of the log queries, Loki stops reading once it is
+	// reached
+	Limit int
+}
+
+// ExecutionStats are the statistics of the execution of a query, the times are
+// in seconds
+type ExecutionStats struct {
+	BytesProcessed          int64      `json:"bytesProcessed"`
+	LinesProcessed          int64      `json:"linesProcessed"`
+	BytesProcessedPerSecond int64      `json:"bytesProcessedPerSecond"`
+	LinesProcessedPerSecond int64      `json:"linesProcessedPerSecond"`
+	EntriesReturned         int64      `json:"entriesReturned"`
+	ExecTime                float64    `json:"execTime"`
+	QueueTime               float64    `json:"queueTime"`
+	Subqueries              int64      `json:"subqueries"`
+	Splits                  int64      `json:"splits"`
+	Shards                  int64      `json:"shards"`
+	Querier                 StoreStats `json:"querier"`
+	Ingester                StoreStats `json:"ingester"`
+	IngestersReached        int64      `json:"ingestersReached"`
+	IngesterLinesSent       int64      `json:"ingesterLinesSent"`
+	IngesterChunksMatched   int64      `json:"ingesterChunksMatched"`
+	IngesterBatches         int64      `json:"ingesterBatches"`
+}
+
+// StoreStats are the chunks read by the queriers from the object storage, or
+// by the ingesters from their memory
+type StoreStats struct {
+	ChunksRef          int64   `json:"chunksRef"`
+	ChunksDownloaded   int64   `json:"chunksDownloaded"`
+	ChunksDownloadTime float64 `json:"chunksDownloadTime"`
+	HeadChunkBytes     int64   `json:"headChunkBytes"`
+	HeadChunkLines     int64   `json:"headChunkLines"`
+	CompressedBytes    int64   `json:"compressedBytes"`
+	DecompressedBytes  int64   `json:"decompressedBytes"`
+	DecompressedLines  int64   `json:"decompressedLines"`
+	TotalDuplicates    int64   `json:"totalDuplicates"`
+}
+
+// lokiStoreStats are the store statistics of Loki, the download time is in
+// nanoseconds
+type lokiStoreStats struct {
+	TotalChunksRef        int64 `json:"totalChunksRef"`
+	TotalChunksDownloaded int64 `json:"totalChunksDownloaded"`
+	ChunksDownloadTime    int64 `json:"chunksDownloadTime"`
+	Chunk                 struct {
+		HeadChunkBytes    int64 `json:"headChunkBytes"`
+		HeadChunkLines    int64 `json:"headChunkLines"`
+		CompressedBytes   int64 `json:"compressedBytes"`
+		DecompressedBytes int64 `json:"decompressedBytes"`
+		DecompressedLines int64 `json:"decompressedLines"`
+		TotalDuplicates   int64 `json:"totalDuplicates"`
+	} `json:"chunk"`
+}
+
+type statsResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Stats struct {
+			Summary struct {
+				BytesProcessedPerSecond int64   `json:"bytesProcessedPerSecond"`
+				LinesProcessedPerSecond int64   `json:"linesProcessedPerSecond"`
+				TotalBytesProcessed     int64   `json:"totalBytesProcessed"`
+				TotalLinesProcessed     int64   `json:"totalLinesProcessed"`
+				TotalEntriesReturned    int64   `json:"totalEntriesReturned"`
+				ExecTime                float64 `json:"execTime"`
+				QueueTime               float64 `json:"queueTime"`
+				Subqueries              int64   `json:"subqueries"`
+				Splits                  int64   `json:"splits"`
+				Shards                  int64   `json:"shards"`
+			} `json:"summary"`
+			Querier struct {
+				Store lokiStoreStats `json:"store"`
+			} `json:"querier"`
+			Ingester struct {
+				TotalReached       int64          `json:"totalReached"`
+				TotalChunksMatched int64          `json:"totalChunksMatched"`
+				TotalBatches       int64          `json:"totalBatches"`
+				TotalLinesSent     int64          `json:"totalLinesSent"`
+				Store              lokiStoreStats `json:"store"`
+			} `json:"ingester"`
+		} `json:"stats"`
+	} `json:"data"`
+}
+
+func (s lokiStoreStats) stats() StoreStats {
+	return StoreStats{
+		ChunksRef:          s.TotalChunksRef,
+		ChunksDownloaded:   s.TotalChunksDownloaded,
+		ChunksDownloadTime: time.Duration(s.ChunksDownloadTime).Seconds(),
+		HeadChunkBytes:     s.Chunk.HeadChunkBytes,
+		HeadChunkLines:     s.Chunk.HeadChunkLines,
+		CompressedBytes:    s.Chunk.CompressedBytes,
+		DecompressedBytes:  s.Chunk.DecompressedBytes,
+		DecompressedLines:  s.Chunk.DecompressedLines,
+		TotalDuplicates:    s.Chunk.TotalDuplicates,
+	}
+}
+
+// Stats runs the query over the range of req and returns the statistics of
+// its execution, the result of the query is dropped
+func (c *Client) Stats(r *http.Request, tenant string, req StatsRequest) (*ExecutionStats, error) {
+	params := url.Values{}
+	params.Set("query", req.Query)
+	params.Set("start", strconv.FormatInt(req.Start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(req.End.UnixNano(), 10))
+	if req.Limit > 0 {
+		params.Set("limit", strconv.Itoa(req.Limit))
+	}
+
+	resp := &statsResponse{}
+	if err := c.get(r, tenant, queryRangeEndpoint, params, resp); err != nil {
+		return nil, err
+	}
+
+	stats := resp.Data.Stats
+	return &ExecutionStats{
+		BytesProcessed:          stats.Summary.TotalBytesProcessed,
+		LinesProcessed:          stats.Summary.TotalLinesProcessed,
+		BytesProcessedPerSecond: stats.Summary.BytesProcessedPerSecond,
+		LinesProcessedPerSecond: stats.Summary.LinesProcessedPerSecond,
+		EntriesReturned:         stats.Summary.TotalEntriesReturned,
+		ExecTime:                stats.Summary.ExecTime,
+		QueueTime:               stats.Summary.QueueTime,
+		Subqueries:              stats.Summary.Subqueries,
+		Splits:                  stats.Summary.Splits,
+		Shards:                  stats.Summary.Shards,
+		Querier:                 stats.Querier.Store.stats(),
+		Ingester:                stats.Ingester.Store.stats(),
+		IngestersReached:        stats.Ingester.TotalReached,
+		IngesterLinesSent:       stats.Ingester.TotalLinesSent,
+		IngesterChunksMatched:   stats.Ingester.TotalChunksMatched,
+		IngesterBatches:         stats.Ingester.TotalBatches,
+	}, nil
+}
@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/openshift/logging-view-plugin/pkg/tracing"
+)
+
+const (
+	tailEndpoint = "/loki/api/v1/tail"
+	// tailWriteWait is the time allowed to write a message to the client
+	tailWriteWait = 10 * time.Second
+	// tailDialTimeout is the time allowed to open the upstream connection
+	tailDialTimeout = 10 * time.Second
+	// tailClientReadLimit is the maximum size of the messages sent by the
+	// client, only control frames are expected
+	tailClientReadLimit = 512
+)
+
+// TailLimits bounds the live tail streams
+type TailLimits struct {
+	// MaxStreams is the maximum number of concurrent streams, unlimited if 0
+	MaxStreams int
+	// MaxDuration closes the streams open for longer, unlimited if 0
+	MaxDuration time.Duration
+	// PingInterval is the time between two keepalive pings to the client, the
+	// client must answer before the next ping
+	PingInterval time.Duration
+}
+
+// Tail proxies the Loki tail WebSocket for live log streaming. It serves
+// requests with the `/<tenant>` path and the Loki tail query parameters
+type Tail struct {
+	cfg      Config
+	limits   TailLimits
+	streams  chan struct{}
+	dialer   *websocket.Dialer
+	upgrader *websocket.Upgrader
+}
+
+// NewTail builds a Loki tail proxy
+func NewTail(cfg Config, limits TailLimits) *Tail {
+	t := &Tail{
+		cfg:    cfg,
+		limits: limits,
+		dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: tailDialTimeout,
+		},
+		upgrader: &websocket.Upgrader{
+			// the console proxies the connection and requests are authorized
+			// with the forwarded bearer token, not with cookies
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+
+	if transport, ok := cfg.Transport.(*http.Transport); ok {
+		t.dialer.TLSClientConfig = transport.TLSClientConfig
+		t.dialer.Proxy = transport.Proxy
+	}
+
+	if limits.MaxStreams > 0 {
+		t.streams = make(chan struct{}, limits.MaxStreams)
+	}
+
+	if t.cfg.ErrorHandler == nil {
+		t.cfg.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err *Error) {
+			http.Error(w, err.Message, err.Status)
+		}
+	}
+
+	return t
+}
+
+func (t *Tail) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant, sse := tailRequest(r)
+	if !tenantRegexp.MatchString(tenant) {
+		t.cfg.ErrorHandler(w, r, &Error{Status: http.StatusBadRequest, Code: "InvalidTenant", Message: fmt.Sprintf("invalid tenant %q", tenant)})
+		return
+	}
+
+	if !t.checkTailTransport(w, r, sse) {
+		return
+	}
+
+	r, tokenErr := t.cfg.withUpstreamToken(r)
+	if tokenErr != nil {
+		t.cfg.ErrorHandler(w, r, tokenErr)
+		return
+	}
+
+	if !t.acquire() {
+		t.cfg.ErrorHandler(w, r, &Error{Status: http.StatusTooManyRequests, Code: "TooManyStreams", Message: fmt.Sprintf("the maximum of %d live streams is reached", t.limits.MaxStreams)})
+		return
+	}
+	defer t.release()
+
+	ctx, span := t.cfg.Tracer.Start(r.Context(), "loki "+tailEndpoint, tracing.KindClient, upstreamAttributes(tenant, tailEndpoint, r.URL.Query())...)
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	upstreamURL := *t.cfg.URL
+	upstreamURL.Scheme = strings.Replace(upstreamURL.Scheme, "http", "ws", 1)
+	upstreamURL.Path = t.cfg.upstreamPath(tenant, tailEndpoint)
+	upstreamURL.RawPath = ""
+	upstreamURL.RawQuery = r.URL.RawQuery
+
+	headers := t.cfg.upstreamHeaders(r, tenant)
+	headers.Del("Accept-Encoding")
+
+	if breakerErr := t.cfg.Breaker.allow(); breakerErr != nil {
+		t.cfg.ErrorHandler(w, r, breakerErr)
+		return
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, tailDialTimeout)
+	defer cancel()
+
+	upstream, resp, err := t.dialer.DialContext(dialCtx, upstreamURL.String(), headers)
+	if err != nil {
+		span.SetError(err)
+		log.WithError(err).WithField("request_id", r.Header.Get(RequestIDHeader)).Warn("cannot open Loki tail connection")
+		if resp != nil {
+			t.cfg.Breaker.record(resp.StatusCode >= http.StatusInternalServerError)
+			metrics.UpstreamErrorsTotal.WithLabelValues("loki", strconv.Itoa(resp.StatusCode)).Inc()
+			t.cfg.ErrorHandler(w, r, &Error{Status: resp.StatusCode, Code: "UpstreamError", Message: "Loki rejected the tail connection", Err: err})
+		} else {
+			if !errors.Is(err, context.Canceled) {
+				t.cfg.Breaker.record(true)
+			}
+			metrics.UpstreamErrorsTotal.WithLabelValues("loki", "unavailable").Inc()
+			t.cfg.ErrorHandler(w, r, &Error{Status: http.StatusBadGateway, Code: "UpstreamUnavailable", Message: "cannot reach Loki", Err: err})
+		}
+		return
+	}
+	defer upstream.Close()
+	t.cfg.Breaker.record(false)
+
+	client, ok := t.openClient(w, r, sse)
+	if !ok {
+		return
+	}
+	defer client.finish()
+
+	t.stream(client, upstream)
+}
+
+// stream copies the upstream messages to the client until one of the sides
+// closes the connection or a limit is reached
+func (t *Tail) stream(client tailClient, upstream *websocket.Conn) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	messages := make(chan []byte)
+	var upstreamErr error
+	go func() {
+		defer close(messages)
+		for {
+			_, data, err := upstream.ReadMessage()
+			if err != nil {
+				upstreamErr = err
+				return
+			}
+			select {
+			case messages <- data:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	ping, maxDuration, stopTimers := t.timers()
+	defer stopTimers()
+
+	for {
+		select {
+		case data, ok := <-messages:
+			if !ok {
+				closeCode, closeText := websocket.CloseNormalClosure, ""
+				if !websocket.IsCloseError(upstreamErr, websocket.CloseNormalClosure) {
+					log.WithError(upstreamErr).Warn("Loki tail connection closed")
+					closeCode, closeText = websocket.CloseInternalServerErr, "upstream connection closed"
+				}
+				client.close(closeCode, closeText)
+				return
+			}
+			if err := client.send(data); err != nil {
+				return
+			}
+		case <-ping:
+			if err := client.ping(); err != nil {
+				return
+			}
+		case <-maxDuration:
+			client.close(websocket.CloseNormalClosure, "maximum stream duration reached")
+			return
+		case <-client.gone():
+			return
+		}
+	}
+}
+
+// timers returns the channels of the keepalive pings and of the maximum
+// duration of a stream, nil when disabled, and the function stopping them
+func (t *Tail) timers() (<-chan time.Time, <-chan time.Time, func()) {
+	var ping, maxDuration <-chan time.Time
+	var ticker *time.Ticker
+	var timer *time.Timer
+
+	if t.limits.PingInterval > 0 {
+		ticker = time.NewTicker(t.limits.PingInterval)
+		ping = ticker.C
+	}
+	if t.limits.MaxDuration > 0 {
+		timer = time.NewTimer(t.limits.MaxDuration)
+		maxDuration = timer.C
+	}
+
+	return ping, maxDuration, func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (t *Tail) acquire() bool {
+	if t.streams == nil {
+		return true
+	}
+	select {
+	case t.streams <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *Tail) release() {
+	if t.streams != nil {
+		<-t.streams
+	}
+}
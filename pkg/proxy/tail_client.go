@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// eventStreamKey marks the tail requests answered with server-sent events
+type eventStreamKey struct{}
+
+// WithEventStream returns r answered with server-sent events by the tail
+// proxies instead of a WebSocket, for the clients behind the proxies
+// stripping the WebSocket upgrades
+func WithEventStream(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), eventStreamKey{}, true))
+}
+
+// tailClient is the client side of a live tail stream, a WebSocket or a
+// server-sent events response
+type tailClient interface {
+	// send writes a Loki tail message
+	send(data []byte) error
+	// ping writes a keepalive message
+	ping() error
+	// close ends the stream with a WebSocket close code and reason
+	close(code int, text string)
+	// gone is closed when the client goes away
+	gone() <-chan struct{}
+	// finish releases the connection once the stream ended
+	finish()
+}
+
+// tailRequest returns the tenant of a tail request and true when the stream
+// is sent as server-sent events
+func tailRequest(r *http.Request) (string, bool) {
+	sse, _ := r.Context().Value(eventStreamKey{}).(bool)
+	return strings.Trim(r.URL.Path, "/"), sse
+}
+
+// checkTailTransport checks that a WebSocket stream is requested with an
+// upgrade
+func (t *Tail) checkTailTransport(w http.ResponseWriter, r *http.Request, sse bool) bool {
+	if !sse && !websocket.IsWebSocketUpgrade(r) {
+		t.cfg.ErrorHandler(w, r, &Error{Status: http.StatusBadRequest, Code: "InvalidRequest", Message: "a WebSocket upgrade is required"})
+		return false
+	}
+	return true
+}
+
+// openClient upgrades the request to a WebSocket or starts the server-sent
+// events response, it returns false when the client was already replied to
+func (t *Tail) openClient(w http.ResponseWriter, r *http.Request, sse bool) (tailClient, bool) {
+	if sse {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.cfg.ErrorHandler(w, r, &Error{Status: http.StatusInternalServerError, Code: "InternalError", Message: "the response cannot be streamed"})
+			return nil, false
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// disable the buffering of the reverse proxies, like nginx
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		return &sseTailClient{w: w, flusher: flusher, ctx: r.Context()}, true
+	}
+
+	conn, err := t.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied to the client
+		log.WithError(err).WithField("request_id", r.Header.Get(RequestIDHeader)).Warn("cannot upgrade tail connection")
+		return nil, false
+	}
+	return newWebSocketTailClient(conn, t), true
+}
+
+// webSocketTailClient streams the messages to a WebSocket, the client must
+// answer the pings before the next one
+type webSocketTailClient struct {
+	conn       *websocket.Conn
+	tail       *Tail
+	clientGone chan struct{}
+}
+
+func newWebSocketTailClient(conn *websocket.Conn, t *Tail) *webSocketTailClient {
+	c := &webSocketTailClient{conn: conn, tail: t, clientGone: make(chan struct{})}
+
+	conn.SetReadLimit(tailClientReadLimit)
+	c.extendReadDeadline()
+	conn.SetPongHandler(func(string) error {
+		c.extendReadDeadline()
+		return nil
+	})
+	go func() {
+		defer close(c.clientGone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	return c
+}
+
+func (c *webSocketTailClient) send(data []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(tailWriteWait))
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (c *webSocketTailClient) ping() error {
+	return c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(tailWriteWait))
+}
+
+func (c *webSocketTailClient) close(code int, text string) {
+	message := websocket.FormatCloseMessage(code, text)
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(tailWriteWait))
+}
+
+func (c *webSocketTailClient) gone() <-chan struct{} {
+	return c.clientGone
+}
+
+func (c *webSocketTailClient) finish() {
+	c.conn.Close()
+}
+
+func (c *webSocketTailClient) extendReadDeadline() {
+	if c.tail.limits.PingInterval > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(2 * c.tail.limits.PingInterval))
+	} else {
+		c.conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// sseCloseEvent is the data of the close event ending a server-sent events
+// stream, with the code and reason of the WebSocket close message
+type sseCloseEvent struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// sseTailClient streams the messages as server-sent events, the pings are
+// comments ignored by the EventSource clients
+type sseTailClient struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	ctx     context.Context
+}
+
+func (c *sseTailClient) send(data []byte) error {
+	event := strings.Builder{}
+	for _, line := range strings.Split(string(data), "\n") {
+		event.WriteString("data: ")
+		event.WriteString(line)
+		event.WriteString("\n")
+	}
+	event.WriteString("\n")
+	return c.write(event.String())
+}
+
+func (c *sseTailClient) ping() error {
+	return c.write(": ping\n\n")
+}
+
+// close sends the close event, the clients must close their EventSource
+// instead of reconnecting
+func (c *sseTailClient) close(code int, text string) {
+	data, _ := json.Marshal(sseCloseEvent{Code: code, Reason: text})
+	c.write(fmt.Sprintf("event: close\ndata: %s\n\n", data))
+}
+
+func (c *sseTailClient) gone() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+// finish has nothing to release, the server ends the response
+func (c *sseTailClient) finish() {}
+
+func (c *sseTailClient) write(event string) error {
+	if _, err := c.w.Write([]byte(event)); err != nil {
+		return err
+	}
+	c.flusher.Flush()
+	return nil
+}
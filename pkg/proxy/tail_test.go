@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTail(t *testing.T) {
+	requests := make(chan upstreamRequest, 1)
+	upgrader := websocket.Upgrader{}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- upstreamRequest{
+			path:          r.URL.Path,
+			query:         r.URL.RawQuery,
+			authorization: r.Header.Get("Authorization"),
+			tenant:        r.Header.Get(TenantHeader),
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		for _, message := range []string{`{"streams":[1]}`, `{"streams":[2]}`} {
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(message)))
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	tail := NewTail(Config{URL: upstreamURL, UseTenantInHeader: true}, TailLimits{MaxStreams: 1, PingInterval: time.Second})
+	server := httptest.NewServer(http.StripPrefix("/api/tail", tail))
+	defer server.Close()
+
+	headers := http.Header{"Authorization": {"Bearer user-token"}}
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(server.URL, "http", "ws", 1)+"/api/tail/application?query=%7Bjob%3D%22a%22%7D", headers)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Equal(t, upstreamRequest{
+		path:          "/loki/api/v1/tail",
+		query:         "query=%7Bjob%3D%22a%22%7D",
+		authorization: "Bearer user-token",
+		tenant:        "application",
+	}, <-requests)
+
+	for _, expected := range []string{`{"streams":[1]}`, `{"streams":[2]}`} {
+		_, message, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, expected, string(message))
+	}
+
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+}
+
+func TestTailMaxStreams(t *testing.T) {
+	upstreamURL, err := url.Parse("http://loki.invalid")
+	require.NoError(t, err)
+
+	tail := NewTail(Config{URL: upstreamURL}, TailLimits{MaxStreams: 1})
+	require.True(t, tail.acquire())
+
+	r := httptest.NewRequest(http.MethodGet, "/application", nil)
+	r.Header.Set("Connection", "upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	w := httptest.NewRecorder()
+	tail.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestTailEventStream(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"streams":[1]}`)))
+		// hold the stream until the maximum duration is reached
+		conn.ReadMessage()
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	tail := NewTail(Config{URL: upstreamURL}, TailLimits{MaxDuration: 200 * time.Millisecond, PingInterval: 50 * time.Millisecond})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tail.ServeHTTP(w, WithEventStream(r))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/application?query=%7Bjob%3D%22a%22%7D")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	events := string(body)
+	require.True(t, strings.HasPrefix(events, "data: {\"streams\":[1]}\n\n"), events)
+	require.Contains(t, events, ": ping\n\n")
+	require.True(t, strings.HasSuffix(events, "event: close\ndata: {\"code\":1000,\"reason\":\"maximum stream duration reached\"}\n\n"), events)
+
+	// the streams without the event stream mark still require an upgrade
+	w := httptest.NewRecorder()
+	tail.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const queryEndpoint = "/loki/api/v1/query"
+
+// Sample is a sample of an instant metric query
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+type vectorResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  [2]interface{}    `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// Vector evaluates the instant metric query at time t and returns its samples
+func (c *Client) Vector(r *http.Request, tenant string, query string, t time.Time) ([]Sample, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", strconv.FormatInt(t.UnixNano(), 10))
+
+	resp := &vectorResponse{}
+	if err := c.get(r, tenant, queryEndpoint, params, resp); err != nil {
+		return nil, err
+	}
+	if resp.Data.ResultType != "vector" {
+		return nil, &Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: fmt.Sprintf("the metric query returned a %s result, a vector is expected", resp.Data.ResultType)}
+	}
+
+	samples := make([]Sample, 0, len(resp.Data.Result))
+	for _, result := range resp.Data.Result {
+		value, err := strconv.ParseFloat(fmt.Sprint(result.Value[1]), 64)
+		if err != nil {
+			return nil, &Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: "cannot decode the Loki samples"}
+		}
+		labels := result.Metric
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		samples = append(samples, Sample{Labels: labels, Value: value})
+	}
+	return samples, nil
+}
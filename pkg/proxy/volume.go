@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// VolumeRequest counts the entries of a log query in buckets of Interval,
+// Start and End are aligned on the buckets
+type VolumeRequest struct {
+	Query    string
+	Start    time.Time
+	End      time.Time
+	Interval time.Duration
+	// GroupBy is the label whose values get their own counts, like level,
+	// the entries are counted together when empty
+	GroupBy string
+}
+
+// VolumeSeries are the counts of the entries of a label value, Counts has
+// one count per bucket of the request from the oldest to the newest
+type VolumeSeries struct {
+	Labels map[string]string `json:"labels"`
+	Total  int64             `json:"total"`
+	Counts []int64           `json:"counts"`
+}
+
+type matrixResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]interface{}  `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// Volume counts the entries of a log query with a count_over_time metric
+// query, the buckets without entries count 0. The series are sorted by their
+// labels
+func (c *Client) Volume(r *http.Request, tenant string, req VolumeRequest) ([]VolumeSeries, error) {
+	if req.Interval < time.Second || !req.Start.Before(req.End) {
+		return nil, &Error{Status: http.StatusBadRequest, Code: "InvalidRequest", Message: "invalid volume range"}
+	}
+	buckets := int(req.End.Sub(req.Start) / req.Interval)
+
+	step := int64(req.Interval / time.Second)
+	query := fmt.Sprintf("sum(count_over_time(%s [%ds]))", req.Query, step)
+	if req.GroupBy != "" {
+		query = fmt.Sprintf("sum by (%s) (count_over_time(%s [%ds]))", req.GroupBy, req.Query, step)
+	}
+
+	// every sample counts the entries of the interval ending at its
+	// timestamp, the first one ends with the first bucket
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(req.Start.Add(req.Interval).UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(req.End.UnixNano(), 10))
+	params.Set("step", strconv.FormatInt(step, 10))
+
+	resp := &matrixResponse{}
+	if err := c.get(r, tenant, queryRangeEndpoint, params, resp); err != nil {
+		return nil, err
+	}
+	if resp.Data.ResultType != "matrix" {
+		return nil, &Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: fmt.Sprintf("the volume query returned a %s result, a matrix is expected", resp.Data.ResultType)}
+	}
+
+	series := make([]VolumeSeries, 0, len(resp.Data.Result))
+	for _, result := range resp.Data.Result {
+		s := VolumeSeries{Labels: result.Metric, Counts: make([]int64, buckets)}
+		if s.Labels == nil {
+			s.Labels = map[string]string{}
+		}
+		for _, value := range result.Values {
+			timestamp, ok := value[0].(float64)
+			count, err := strconv.ParseFloat(fmt.Sprint(value[1]), 64)
+			if !ok || err != nil {
+				return nil, &Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: "cannot decode the Loki volume samples"}
+			}
+			// the samples are on whole seconds like the aligned buckets
+			end := time.Unix(int64(math.Round(timestamp)), 0)
+			bucket := int(end.Sub(req.Start)/req.Interval) - 1
+			if bucket < 0 || bucket >= buckets {
+				continue
+			}
+			s.Counts[bucket] += int64(count)
+			s.Total += int64(count)
+		}
+		series = append(series, s)
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		return fmt.Sprint(series[i].Labels) < fmt.Sprint(series[j].Labels)
+	})
+
+	return series, nil
+}
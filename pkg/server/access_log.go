@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/sirupsen/logrus"
+)
+
+var alog = logrus.WithField("module", "access")
+
+// log formats of the plugin backend
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// accessLogFields are the fields of the access log entries
+var accessLogFields = []string{"request_id", "method", "path", "route", "status", "bytes", "duration_ms", "remote_addr", "user_agent"}
+
+// AccessLogConfig selects the served requests logged and the fields of their
+// entries
+type AccessLogConfig struct {
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	// Level is the level of the entries, info, debug or trace
+	Level string `yaml:"level,omitempty" json:"level,omitempty"`
+	// SampleRate is the fraction of the requests logged, up to 1, the
+	// server errors are always logged
+	SampleRate float64 `yaml:"sampleRate,omitempty" json:"sampleRate,omitempty"`
+	// Fields are the fields of the entries, every field when unset
+	Fields []string `yaml:"fields,omitempty" json:"fields,omitempty"`
+	// ExcludePaths are the path.Match patterns of the requests not logged
+	// unless they fail, like the probes
+	ExcludePaths []string `yaml:"excludePaths,omitempty" json:"excludePaths,omitempty"`
+	// ExcludeExtensions are the file extensions of the static assets not
+	// logged unless they fail
+	ExcludeExtensions []string `yaml:"excludeExtensions,omitempty" json:"excludeExtensions,omitempty"`
+}
+
+var defaultAccessLogConfig = AccessLogConfig{
+	Level:             "info",
+	SampleRate:        1,
+	Fields:            accessLogFields,
+	ExcludePaths:      []string{"/health", "/health/*", "/healthz", "/readyz", "/metrics", "/locales/*/*"},
+	ExcludeExtensions: []string{".js", ".css", ".map", ".svg", ".png", ".woff", ".woff2"},
+}
+
+func (c AccessLogConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	switch c.Level {
+	case "", "info", "debug", "trace":
+	default:
+		errs = append(errs, ConfigValidationError{Field: "accessLog.level", Message: fmt.Sprintf("invalid level %q, expected info, debug or trace", c.Level)})
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		errs = append(errs, ConfigValidationError{Field: "accessLog.sampleRate", Message: "sampleRate must be between 0 and 1"})
+	}
+	for i, field := range c.Fields {
+		known := false
+		for _, accessLogField := range accessLogFields {
+			known = known || field == accessLogField
+		}
+		if !known {
+			errs = append(errs, ConfigValidationError{Field: fmt.Sprintf("accessLog.fields[%d]", i), Message: fmt.Sprintf("unknown field %q, expected one of %s", field, strings.Join(accessLogFields, ", "))})
+		}
+	}
+	for i, pattern := range c.ExcludePaths {
+		if _, err := path.Match(pattern, "/"); err != nil {
+			errs = append(errs, ConfigValidationError{Field: fmt.Sprintf("accessLog.excludePaths[%d]", i), Message: fmt.Sprintf("invalid pattern %q", pattern)})
+		}
+	}
+	return errs
+}
+
+func (c AccessLogConfig) excluded(urlPath string) bool {
+	for _, pattern := range c.ExcludePaths {
+		if matched, _ := path.Match(pattern, urlPath); matched {
+			return true
+		}
+	}
+
+	extension := strings.ToLower(path.Ext(urlPath))
+	for _, excluded := range c.ExcludeExtensions {
+		if extension != "" && strings.EqualFold(extension, excluded) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type routeKey struct{}
+
+// accessLogHandler logs the served requests as structured entries, the
+// route is filled in by the router middlewares. The excluded and unsampled
+// requests are only logged when they fail with a server error
+func accessLogHandler(cfg AccessLogConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if cfg.Disabled {
+			return next
+		}
+
+		// the level is validated when the plugin config is parsed
+		level, err := logrus.ParseLevel(cfg.Level)
+		if err != nil {
+			level = logrus.InfoLevel
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := "unknown"
+			r = r.WithContext(context.WithValue(r.Context(), routeKey{}, &route))
+
+			m := httpsnoop.CaptureMetrics(next, w, r)
+
+			if m.Code < http.StatusInternalServerError && (cfg.excluded(r.URL.Path) || rand.Float64() >= cfg.SampleRate) {
+				return
+			}
+
+			values := map[string]interface{}{
+				"request_id":  r.Header.Get(requestIDHeader),
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"route":       route,
+				"status":      m.Code,
+				"bytes":       m.Written,
+				"duration_ms": m.Duration.Milliseconds(),
+				"remote_addr": r.RemoteAddr,
+				"user_agent":  r.UserAgent(),
+			}
+			fields := logrus.Fields{}
+			for _, field := range cfg.Fields {
+				fields[field] = values[field]
+			}
+
+			alog.WithFields(fields).Log(level, "request served")
+		})
+	}
+}
+
+// setAccessLogRoute records the matched route of r for the access log
+func setAccessLogRoute(r *http.Request, route string) {
+	if holder, ok := r.Context().Value(routeKey{}).(*string); ok {
+		*holder = route
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogHandler(t *testing.T) {
+	hook := test.NewLocal(alog.Logger)
+	defer hook.Reset()
+
+	pluginConfig, err := newReloadingPluginConfig("")
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{}, pluginConfig, routeDeps{})
+	router.Use(instrumentationMiddleware)
+
+	r := httptest.NewRequest(http.MethodGet, "/features", nil)
+	r.Header.Set(requestIDHeader, "abc-123")
+	accessLogHandler(pluginConfig.get().AccessLog)(router).ServeHTTP(httptest.NewRecorder(), r)
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	require.Equal(t, "abc-123", entry.Data["request_id"])
+	require.Equal(t, "/features", entry.Data["route"])
+	require.Equal(t, http.StatusOK, entry.Data["status"])
+	require.Contains(t, entry.Data, "duration_ms")
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	hook := test.NewLocal(alog.Logger)
+	defer hook.Reset()
+
+	pluginConfig, err := parsePluginConfig([]byte(`
+accessLog:
+  sampleRate: 0.000000001
+  fields: [method, path, status]
+  excludePaths: [/health]
+`))
+	require.NoError(t, err)
+
+	handler := accessLogHandler(pluginConfig.AccessLog)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+
+	for _, urlPath := range []string{"/health", "/plugin-entry.js", "/features", "/fail"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, urlPath, nil))
+	}
+
+	// only the server error is logged, with the configured fields
+	require.Len(t, hook.AllEntries(), 1)
+	entry := hook.LastEntry()
+	require.Equal(t, logrus.Fields{"module": "access", "method": "GET", "path": "/fail", "status": http.StatusBadGateway}, entry.Data)
+	require.Equal(t, logrus.InfoLevel, entry.Level)
+}
+
+func TestAccessLogExclusion(t *testing.T) {
+	hook := test.NewLocal(alog.Logger)
+	defer hook.Reset()
+
+	level := alog.Logger.GetLevel()
+	alog.Logger.SetLevel(logrus.DebugLevel)
+	defer alog.Logger.SetLevel(level)
+
+	cfg := defaultAccessLogConfig
+	cfg.Level = "debug"
+	handler := accessLogHandler(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, urlPath := range []string{"/health", "/readyz", "/plugin-entry.js", "/locales/en/plugin__logging-view-plugin.json", "/api/proxy/application/loki/api/v1/query_range"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, urlPath, nil))
+	}
+
+	require.Len(t, hook.AllEntries(), 1)
+	require.Equal(t, "/api/proxy/application/loki/api/v1/query_range", hook.LastEntry().Data["path"])
+	require.Equal(t, logrus.DebugLevel, hook.LastEntry().Level)
+}
+
+func TestAccessLogValidation(t *testing.T) {
+	errs := AccessLogConfig{Level: "warn", SampleRate: 2, Fields: []string{"method", "token"}, ExcludePaths: []string{"["}}.validate()
+
+	fields := []string{}
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	require.Equal(t, []string{"accessLog.level", "accessLog.sampleRate", "accessLog.fields[1]", "accessLog.excludePaths[0]"}, fields)
+}
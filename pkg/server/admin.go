@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/sirupsen/logrus"
+)
+
+// maxLogLevelRequestSize bounds the body of a log level change
+const maxLogLevelRequestSize = 1 << 10
+
+// AdminConfig authorizes the users of the admin API, the runtime profiles and
+// the support bundle when -authentication is enabled
+type AdminConfig struct {
+	// Groups are the groups whose members are administrators of the plugin,
+	// the authenticated users outside of them are forbidden
+	Groups []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+}
+
+func (c AdminConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	for i, group := range c.Groups {
+		if strings.TrimSpace(group) == "" {
+			errs = append(errs, ConfigValidationError{Field: fmt.Sprintf("admin.groups[%d]", i), Message: "the group name cannot be empty"})
+		}
+	}
+	return errs
+}
+
+// isAdmin tells whether user is a member of one of the admin groups
+func (c AdminConfig) isAdmin(user *kube.UserInfo) bool {
+	for _, admin := range c.Groups {
+		for _, group := range user.Groups {
+			if group == admin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// logLevelRequest is the body of GET and PUT /api/admin/loglevel
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// registerAdminRoutes serves the admin API, changing the backend at runtime
+// without a pod restart. Without authentication, the admin routes only
+// accept the requests from the loopback interface, like a port-forward or an
+// exec in the pod, and with authentication only the members of the admin
+// groups
+func registerAdminRoutes(r *mux.Router, cfg *Config, reloadingConfig *reloadingPluginConfig, authenticator *tokenAuthenticator) {
+	middleware := adminMiddleware(authenticator, reloadingConfig)
+
+	r.Path("/api/admin/loglevel").Methods(http.MethodGet).Handler(middleware(getLogLevelHandler()))
+	r.Path("/api/admin/loglevel").Methods(http.MethodPut).Handler(middleware(setLogLevelHandler()))
+	r.Path("/api/admin/features").Methods(http.MethodPatch).Handler(middleware(patchFeaturesHandler(cfg, reloadingConfig)))
+}
+
+// adminMiddleware authenticates the requests of the admin and debug routes
+// and forbids the users outside of the admin groups of the current plugin
+// config, or only accepts the local requests without authenticator
+func adminMiddleware(authenticator *tokenAuthenticator, reloadingConfig *reloadingPluginConfig) func(next http.Handler) http.Handler {
+	if authenticator == nil {
+		return localhostMiddleware
+	}
+	authenticated := authenticationMiddleware(authenticator)
+	return func(next http.Handler) http.Handler {
+		return authenticated(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := requestUser(r)
+			if !ok {
+				writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "the request is not authenticated", nil)
+				return
+			}
+			if !reloadingConfig.get().Admin.isAdmin(user) {
+				requestLog(slog, r).WithField("user", user.Username).Warn("admin request forbidden")
+				writeError(w, r, http.StatusForbidden, errorCodeForbidden, fmt.Sprintf("user %s is not a member of the admin groups of the plugin", user.Username), nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// localhostMiddleware rejects the requests not coming from a loopback
+// address, a reverse proxy of the pod on the listen socket is not local
+func localhostMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			writeError(w, r, http.StatusForbidden, errorCodeForbidden, "the admin API is only served on localhost without -authentication", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getLogLevelHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, http.StatusOK, logLevelRequest{Level: logrus.GetLevel().String()})
+	})
+}
+
+// setLogLevelHandler changes the level of the backend logs, the audit trail
+// and its level are not affected
+func setLogLevelHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxLogLevelRequestSize))
+		if err != nil {
+			writeError(w, r, http.StatusRequestEntityTooLarge, errorCodePayloadTooLarge, "cannot read log level", err.Error())
+			return
+		}
+
+		req := logLevelRequest{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid log level", err.Error())
+			return
+		}
+		level, err := logrus.ParseLevel(strings.TrimSpace(req.Level))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid log level %q, expected one of panic, fatal, error, warn, info, debug or trace", req.Level), nil)
+			return
+		}
+
+		previous := logrus.GetLevel()
+		logrus.SetLevel(level)
+
+		entry := requestLog(slog, r).WithField("previous", previous.String())
+		if user, ok := requestUser(r); ok {
+			entry = entry.WithField("user", user.Username)
+		}
+		// logged at warning so that the change is kept at any level
+		entry.Warnf("log level set to %s", level)
+
+		writeJSON(w, r, http.StatusOK, logLevelRequest{Level: level.String()})
+	})
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUsersTokenReviewer authenticates the tokens of users
+type fakeUsersTokenReviewer struct {
+	users map[string]kube.UserInfo
+}
+
+func (f *fakeUsersTokenReviewer) ReviewToken(_ context.Context, token string, _ []string) (*kube.TokenReviewStatus, error) {
+	user, ok := f.users[token]
+	return &kube.TokenReviewStatus{Authenticated: ok, User: user}, nil
+}
+
+// newAdminTestAuthenticator authenticates the admin token as a member of the
+// admins group and the developer token as another user
+func newAdminTestAuthenticator() *tokenAuthenticator {
+	return newTokenAuthenticator(&fakeUsersTokenReviewer{users: map[string]kube.UserInfo{
+		"admin":     {Username: "admin", Groups: []string{"system:authenticated", "admins"}},
+		"developer": {Username: "developer", Groups: []string{"system:authenticated"}},
+	}})
+}
+
+func TestLogLevelAdminRoutes(t *testing.T) {
+	level := logrus.GetLevel()
+	defer logrus.SetLevel(level)
+	logrus.SetLevel(logrus.InfoLevel)
+
+	r := mux.NewRouter()
+	registerAdminRoutes(r, &Config{}, &reloadingPluginConfig{config: &PluginConfig{}}, nil)
+
+	serve := func(method string, remoteAddr string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/admin/loglevel", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodGet, "127.0.0.1:41000", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"level":"info"}`, w.Body.String())
+
+	w = serve(http.MethodPut, "[::1]:41000", `{"level":"debug"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.JSONEq(t, `{"level":"debug"}`, w.Body.String())
+	require.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+
+	w = serve(http.MethodPut, "127.0.0.1:41000", `{"level":"verbose"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+
+	// without authentication, only the local requests are served
+	w = serve(http.MethodPut, "10.128.0.12:41000", `{"level":"trace"}`)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+
+	// with authentication, the users outside of the admin groups cannot turn
+	// on the debug logs of the requests of every user
+	r = mux.NewRouter()
+	registerAdminRoutes(r, &Config{}, &reloadingPluginConfig{config: &PluginConfig{Admin: AdminConfig{Groups: []string{"admins"}}}}, newAdminTestAuthenticator())
+	for token, expected := range map[string]int{"developer": http.StatusForbidden, "admin": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/loglevel", strings.NewReader(`{"level":"trace"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, expected, w.Code, token)
+	}
+	require.Equal(t, logrus.TraceLevel, logrus.GetLevel())
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte("admin:\n  groups: [admins]\n"))
+	require.NoError(t, err)
+	handler := adminMiddleware(newAdminTestAuthenticator(), &reloadingPluginConfig{config: pluginConfig})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	for token, expected := range map[string]int{
+		"admin":     http.StatusOK,
+		"developer": http.StatusForbidden,
+		"":          http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/loglevel", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, expected, w.Code, token)
+	}
+
+	// without admin groups, every authenticated user is forbidden
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/loglevel", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	w := httptest.NewRecorder()
+	adminMiddleware(newAdminTestAuthenticator(), &reloadingPluginConfig{config: &PluginConfig{}})(handler).ServeHTTP(w, req)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	_, err = parsePluginConfig([]byte("admin:\n  groups: ['']\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+	"github.com/sirupsen/logrus"
+)
+
+// redaction modes of the audited queries
+const (
+	// AuditRedactionNone records the queries as they are sent
+	AuditRedactionNone = "none"
+	// AuditRedactionFilters records the stream selectors of the queries, the
+	// filter values are replaced
+	AuditRedactionFilters = "filters"
+	// AuditRedactionFull records a SHA-256 hash of the queries
+	AuditRedactionFull = "full"
+)
+
+// auditLogStdout is the audit log path writing the records to the standard
+// output
+const auditLogStdout = "-"
+
+// auditLogger records who queried which logs
+type auditLogger struct {
+	log       *logrus.Entry
+	redaction string
+	closer    io.Closer
+	// webhook mirrors the records when set
+	webhook *auditWebhook
+}
+
+// newAuditLogger returns the audit logger of cfg, nil when the audit is
+// disabled. The records are written with the plugin logs unless
+// AuditLogPath is set, the audit file is appended to in JSON, and mirrored to
+// AuditWebhookURL when set
+func newAuditLogger(cfg *Config) (*auditLogger, error) {
+	if !cfg.AuditEnabled {
+		if cfg.AuditWebhookURL != "" {
+			return nil, fmt.Errorf("the audit webhook requires the audit to be enabled")
+		}
+		return nil, nil
+	}
+
+	redaction := cfg.AuditRedaction
+	if redaction == "" {
+		redaction = AuditRedactionNone
+	}
+	switch redaction {
+	case AuditRedactionNone, AuditRedactionFilters, AuditRedactionFull:
+	default:
+		return nil, fmt.Errorf("invalid audit redaction %q, expected none, filters or full", redaction)
+	}
+
+	audit := &auditLogger{redaction: redaction}
+	switch cfg.AuditLogPath {
+	case "":
+		audit.log = logrus.WithField("module", "audit")
+	case auditLogStdout:
+		audit.log = newAuditFileLogger(os.Stdout)
+	default:
+		file, err := os.OpenFile(cfg.AuditLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open audit log: %w", err)
+		}
+		audit.log = newAuditFileLogger(file)
+		audit.closer = file
+	}
+
+	if cfg.AuditWebhookURL != "" {
+		webhook, err := newAuditWebhook(cfg.AuditWebhookURL)
+		if err != nil {
+			audit.Close()
+			return nil, err
+		}
+		audit.webhook = webhook
+	}
+
+	return audit, nil
+}
+
+func newAuditFileLogger(out io.Writer) *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(out)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	return logrus.NewEntry(logger)
+}
+
+// Close sends the records queued for the webhook and closes the audit file
+func (a *auditLogger) Close() error {
+	if a == nil {
+		return nil
+	}
+	a.webhook.Close()
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// redact returns the fields recording query according to the redaction mode
+func (a *auditLogger) redact(query string) logrus.Fields {
+	if query == "" {
+		return logrus.Fields{}
+	}
+
+	switch a.redaction {
+	case AuditRedactionFilters:
+		redacted, err := logql.RedactFilters(query)
+		if err == nil {
+			return logrus.Fields{"query": redacted}
+		}
+		// the filters of an invalid query cannot be told apart
+	case AuditRedactionNone:
+		return logrus.Fields{"query": query}
+	}
+
+	hash := sha256.Sum256([]byte(query))
+	return logrus.Fields{"query_sha256": hex.EncodeToString(hash[:])}
+}
+
+// auditMiddleware records the tenant queries of the route served by the
+// datasource, the request path is expected to start with the tenant. It does
+// nothing when audit is nil
+func auditMiddleware(audit *auditLogger, route string, datasource string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if audit == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m := httpsnoop.CaptureMetrics(next, w, r)
+
+			tenant, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			params := r.URL.Query()
+
+			fields := audit.redact(params.Get("query"))
+			fields["route"] = route
+			fields["datasource"] = datasource
+			fields["tenant"] = tenant
+			fields["endpoint"] = "/" + endpoint
+			fields["start"] = params.Get("start")
+			fields["end"] = params.Get("end")
+			fields["status"] = m.Code
+			fields["response_bytes"] = m.Written
+			fields["duration_ms"] = m.Duration.Milliseconds()
+			fields["request_id"] = r.Header.Get(requestIDHeader)
+			fields["remote_addr"] = r.RemoteAddr
+			if user, ok := requestUser(r); ok {
+				fields["user"] = user.Username
+				fields["groups"] = user.Groups
+			}
+			if impersonator, ok := requestImpersonator(r); ok {
+				fields["impersonator"] = impersonator.Username
+			}
+
+			audit.log.WithFields(fields).Info("logs queried")
+			if audit.webhook != nil {
+				fields["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+				audit.webhook.send(fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditMiddleware(t *testing.T) {
+	query := `{app="foo"} |= "alice"`
+	queryHash := sha256.Sum256([]byte(query))
+
+	tests := []struct {
+		name           string
+		redaction      string
+		expectedFields logrus.Fields
+	}{
+		{
+			name:           "no redaction",
+			redaction:      AuditRedactionNone,
+			expectedFields: logrus.Fields{"query": query},
+		},
+		{
+			name:           "filters redaction",
+			redaction:      AuditRedactionFilters,
+			expectedFields: logrus.Fields{"query": `{app="foo"} |= "<redacted>"`},
+		},
+		{
+			name:           "full redaction",
+			redaction:      AuditRedactionFull,
+			expectedFields: logrus.Fields{"query_sha256": hex.EncodeToString(queryHash[:])},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			audit := &auditLogger{log: logrus.NewEntry(logger), redaction: tt.redaction}
+
+			handler := auditMiddleware(audit, "proxy", "default")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?start=1&end=2&query="+url.QueryEscape(query), nil)
+			r.Header.Set(requestIDHeader, "abc-123")
+			r = r.WithContext(context.WithValue(r.Context(), userKey{}, &kube.UserInfo{Username: "alice", Groups: []string{"devs"}}))
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			entry := hook.LastEntry()
+			require.NotNil(t, entry)
+			for name, value := range tt.expectedFields {
+				require.Equal(t, value, entry.Data[name])
+			}
+			if _, ok := tt.expectedFields["query"]; !ok {
+				require.NotContains(t, entry.Data, "query")
+			}
+			require.Equal(t, "alice", entry.Data["user"])
+			require.Equal(t, []string{"devs"}, entry.Data["groups"])
+			require.Equal(t, "application", entry.Data["tenant"])
+			require.Equal(t, "/loki/api/v1/query_range", entry.Data["endpoint"])
+			require.Equal(t, "default", entry.Data["datasource"])
+			require.Equal(t, "1", entry.Data["start"])
+			require.Equal(t, "2", entry.Data["end"])
+			require.Equal(t, http.StatusTeapot, entry.Data["status"])
+			require.Equal(t, "abc-123", entry.Data["request_id"])
+		})
+	}
+}
+
+func TestAuditMiddlewareDisabled(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	require.NotNil(t, auditMiddleware(nil, "proxy", "default")(handler))
+}
+
+func TestNewAuditLogger(t *testing.T) {
+	audit, err := newAuditLogger(&Config{})
+	require.NoError(t, err)
+	require.Nil(t, audit)
+
+	_, err = newAuditLogger(&Config{AuditEnabled: true, AuditRedaction: "partial"})
+	require.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err = newAuditLogger(&Config{AuditEnabled: true, AuditLogPath: path, AuditRedaction: AuditRedactionFull})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query?query=%7Bapp%3D%22foo%22%7D", nil)
+	auditMiddleware(audit, "proxy", "default")(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), r)
+	require.NoError(t, audit.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	record := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &record))
+	require.Equal(t, "logs queried", record["msg"])
+	require.Equal(t, "application", record["tenant"])
+	require.Equal(t, float64(http.StatusNotFound), record["status"])
+	require.NotContains(t, record, "query")
+	require.Contains(t, record, "query_sha256")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
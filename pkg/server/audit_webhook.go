@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// auditWebhookQueueSize bounds the records waiting to be sent, the
+	// records audited while the queue is full are dropped
+	auditWebhookQueueSize = 10000
+	// auditWebhookBatchSize is the maximum number of records of a request
+	auditWebhookBatchSize = 100
+	// auditWebhookFlushInterval is the maximum time a record waits for its
+	// batch to fill
+	auditWebhookFlushInterval = time.Second
+	// auditWebhookTimeout bounds a request to the webhook
+	auditWebhookTimeout = 10 * time.Second
+	// auditWebhookAttempts is the number of attempts of a batch, the delay
+	// between them doubles from auditWebhookRetryDelay
+	auditWebhookAttempts   = 4
+	auditWebhookRetryDelay = time.Second
+	// auditWebhookCloseTimeout bounds the delivery of the queued records on
+	// shutdown
+	auditWebhookCloseTimeout = 5 * time.Second
+)
+
+// auditWebhook mirrors the audit records to a webhook, off the request path:
+// the records are queued and posted in JSON arrays by a single sender, and
+// the failed batches are retried with a backoff. While the webhook is slow or
+// down, the queue fills up and the records it cannot hold are dropped, they
+// are still written to the audit log
+type auditWebhook struct {
+	url    string
+	client *http.Client
+	queue  chan logrus.Fields
+	// retryDelay is the delay before the second attempt of a batch
+	retryDelay time.Duration
+
+	// mu guards the queue against the records sent once it is closed
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+	// stop aborts the retries of the sender on shutdown
+	stop context.CancelFunc
+	ctx  context.Context
+}
+
+// newAuditWebhook starts the sender of the records to rawURL
+func newAuditWebhook(rawURL string) (*auditWebhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid audit webhook URL %q, an absolute http or https URL is expected", rawURL)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	w := &auditWebhook{
+		url:        rawURL,
+		client:     &http.Client{Timeout: auditWebhookTimeout},
+		queue:      make(chan logrus.Fields, auditWebhookQueueSize),
+		retryDelay: auditWebhookRetryDelay,
+		done:       make(chan struct{}),
+		stop:       stop,
+		ctx:        ctx,
+	}
+	go w.run()
+	return w, nil
+}
+
+// send queues the record without blocking, it is dropped when the queue is
+// full. It does nothing when w is nil
+func (w *auditWebhook) send(record logrus.Fields) {
+	if w == nil {
+		return
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		metrics.AuditWebhookRecordsTotal.WithLabelValues("dropped").Inc()
+		return
+	}
+	select {
+	case w.queue <- record:
+	default:
+		metrics.AuditWebhookRecordsTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+// run posts the queued records in batches until the queue is closed and
+// drained
+func (w *auditWebhook) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(auditWebhookFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]logrus.Fields, 0, auditWebhookBatchSize)
+	for {
+		select {
+		case record, ok := <-w.queue:
+			if !ok {
+				w.post(batch)
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) < auditWebhookBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		w.post(batch)
+		batch = batch[:0]
+	}
+}
+
+// post sends batch, retried with a backoff until it is accepted or the
+// attempts are exhausted
+func (w *auditWebhook) post(batch []logrus.Fields) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		slog.WithError(err).Error("cannot marshal the audit records")
+		metrics.AuditWebhookRecordsTotal.WithLabelValues("failed").Add(float64(len(batch)))
+		return
+	}
+
+	delay := w.retryDelay
+	for attempt := 1; ; attempt++ {
+		err = w.postOnce(body)
+		if err == nil {
+			metrics.AuditWebhookRecordsTotal.WithLabelValues("sent").Add(float64(len(batch)))
+			return
+		}
+		if attempt == auditWebhookAttempts {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-w.ctx.Done():
+		}
+		if w.ctx.Err() != nil {
+			break
+		}
+		delay *= 2
+	}
+	slog.WithError(err).Errorf("cannot send %d audit records to the webhook", len(batch))
+	metrics.AuditWebhookRecordsTotal.WithLabelValues("failed").Add(float64(len(batch)))
+}
+
+func (w *auditWebhook) postOnce(body []byte) error {
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("the webhook replied %s", resp.Status)
+	}
+	return nil
+}
+
+// Close sends the queued records, for auditWebhookCloseTimeout at most. The
+// records sent after Close are dropped
+func (w *auditWebhook) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+	case <-time.After(auditWebhookCloseTimeout):
+		w.stop()
+		<-w.done
+	}
+	w.stop()
+	return nil
+}
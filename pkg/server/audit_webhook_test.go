@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditWebhook(t *testing.T) {
+	var mu sync.Mutex
+	records := []map[string]interface{}{}
+	var failures int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first batch is retried
+		if atomic.AddInt32(&failures, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		batch := []map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		mu.Lock()
+		records = append(records, batch...)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	audit, err := newAuditLogger(&Config{AuditEnabled: true, AuditWebhookURL: webhook.URL})
+	require.NoError(t, err)
+	audit.webhook.retryDelay = time.Millisecond
+
+	handler := auditMiddleware(audit, "proxy", "default")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?query="+url.QueryEscape(`{app="foo"}`), nil))
+	}
+	require.NoError(t, audit.Close())
+
+	// the queued records are sent on close
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, records, 3)
+	require.Equal(t, `{app="foo"}`, records[0]["query"])
+	require.Equal(t, "application", records[0]["tenant"])
+	require.Equal(t, float64(http.StatusOK), records[0]["status"])
+	require.Equal(t, float64(2), records[0]["response_bytes"])
+	require.NotEmpty(t, records[0]["time"])
+}
+
+func TestAuditWebhookBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer webhook.Close()
+
+	w, err := newAuditWebhook(webhook.URL)
+	require.NoError(t, err)
+
+	// the records exceeding the queue while the webhook is stuck are dropped
+	// without blocking
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*auditWebhookQueueSize; i++ {
+			w.send(map[string]interface{}{"i": i})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the records are blocked by the webhook")
+	}
+
+	close(release)
+	w.Close()
+	w.send(map[string]interface{}{"closed": true})
+}
+
+func TestAuditWebhookValidation(t *testing.T) {
+	_, err := newAuditLogger(&Config{AuditEnabled: true, AuditWebhookURL: "webhook.local"})
+	require.Error(t, err)
+	_, err = newAuditLogger(&Config{AuditWebhookURL: "https://webhook.local"})
+	require.Error(t, err)
+}
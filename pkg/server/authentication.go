@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+)
+
+const (
+	// authenticatedTokenTTL is the time an authenticated token is trusted
+	// without a new review
+	authenticatedTokenTTL = 2 * time.Minute
+	// rejectedTokenTTL is the time a rejected token is not reviewed again
+	rejectedTokenTTL = 10 * time.Second
+	// maxCachedTokens bounds the token review cache
+	maxCachedTokens = 1000
+)
+
+// tokenReviewer authenticates a bearer token, implemented by kube.Client
+type tokenReviewer interface {
+	ReviewToken(ctx context.Context, token string, audiences []string) (*kube.TokenReviewStatus, error)
+}
+
+type tokenReviewResult struct {
+	status  *kube.TokenReviewStatus
+	expires time.Time
+}
+
+// tokenAuthenticator reviews the bearer tokens with the TokenReview API and
+// caches the results by token hash
+type tokenAuthenticator struct {
+	reviewer tokenReviewer
+	mu       sync.Mutex
+	cache    map[[sha256.Size]byte]tokenReviewResult
+	// impersonator resolves the users impersonated by the authenticated
+	// users when set, the impersonation headers are ignored otherwise
+	impersonator *impersonator
+}
+
+func newTokenAuthenticator(reviewer tokenReviewer) *tokenAuthenticator {
+	return &tokenAuthenticator{
+		reviewer: reviewer,
+		cache:    map[[sha256.Size]byte]tokenReviewResult{},
+	}
+}
+
+func (a *tokenAuthenticator) authenticate(ctx context.Context, token string) (*kube.TokenReviewStatus, error) {
+	key := sha256.Sum256([]byte(token))
+
+	a.mu.Lock()
+	result, found := a.cache[key]
+	a.mu.Unlock()
+	if found && time.Now().Before(result.expires) {
+		return result.status, nil
+	}
+
+	status, err := a.reviewer.ReviewToken(ctx, token, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := rejectedTokenTTL
+	if status.Authenticated {
+		ttl = authenticatedTokenTTL
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.cache) >= maxCachedTokens {
+		a.evictExpired()
+	}
+	if len(a.cache) < maxCachedTokens {
+		a.cache[key] = tokenReviewResult{status: status, expires: time.Now().Add(ttl)}
+	}
+
+	return status, nil
+}
+
+func (a *tokenAuthenticator) evictExpired() {
+	now := time.Now()
+	for key, result := range a.cache {
+		if now.After(result.expires) {
+			delete(a.cache, key)
+		}
+	}
+}
+
+type userKey struct{}
+
+// requestUser returns the user authenticated by authenticationMiddleware
+func requestUser(r *http.Request) (*kube.UserInfo, bool) {
+	user, ok := r.Context().Value(userKey{}).(*kube.UserInfo)
+	return user, ok
+}
+
+// authenticationMiddleware rejects the requests without a valid bearer token,
+// it does nothing when authenticator is nil. The requests impersonating a
+// user are served as the impersonated user once the authenticated user is
+// allowed to impersonate them
+func authenticationMiddleware(authenticator *tokenAuthenticator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if authenticator == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// already authenticated by authenticateAllMiddleware
+			if _, ok := requestUser(r); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+				writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "a bearer token is required", nil)
+				return
+			}
+
+			status, err := authenticator.authenticate(r.Context(), token)
+			if err != nil {
+				requestLog(slog, r).WithError(err).Error("cannot review token")
+				writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "cannot authenticate the request", nil)
+				return
+			}
+
+			if !status.Authenticated {
+				writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "invalid bearer token", nil)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userKey{}, &status.User)
+			if authenticator.impersonator != nil {
+				impersonated, err := authenticator.impersonator.impersonate(r.Context(), &status.User, r)
+				if err != nil {
+					writeImpersonationError(w, r, err)
+					return
+				}
+				if impersonated != nil {
+					ctx = context.WithValue(ctx, impersonatorKey{}, &status.User)
+					ctx = context.WithValue(ctx, userKey{}, impersonated)
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// DefaultPublicPaths are the path.Match patterns served without
+// authentication by default when every path requires it: the probes and the
+// metrics
+var DefaultPublicPaths = []string{"/health", "/health/*", "/healthz", "/readyz", "/metrics"}
+
+// ParsePublicPaths parses a comma separated list of path.Match patterns
+// served without authentication, e.g. `/healthz,/plugin-manifest.json`
+func ParsePublicPaths(value string) ([]string, error) {
+	patterns := []string{}
+
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("invalid public path %q, it must start with /", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid public path %q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+// authenticateAllMiddleware authenticates the requests to every path except
+// the publicPaths patterns, the routes requiring authentication still do
+func authenticateAllMiddleware(authenticator *tokenAuthenticator, publicPaths []string) func(next http.Handler) http.Handler {
+	authenticated := authenticationMiddleware(authenticator)
+
+	return func(next http.Handler) http.Handler {
+		authenticatedNext := authenticated(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, pattern := range publicPaths {
+				if matched, _ := path.Match(pattern, r.URL.Path); matched {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			authenticatedNext.ServeHTTP(w, r)
+		})
+	}
+}
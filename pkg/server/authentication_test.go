@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTokenReviewer struct {
+	reviews int
+}
+
+func (f *fakeTokenReviewer) ReviewToken(_ context.Context, token string, _ []string) (*kube.TokenReviewStatus, error) {
+	f.reviews++
+	if token != "valid" {
+		return &kube.TokenReviewStatus{Authenticated: false}, nil
+	}
+	return &kube.TokenReviewStatus{Authenticated: true, User: kube.UserInfo{Username: "developer"}}, nil
+}
+
+func TestAuthenticationMiddleware(t *testing.T) {
+	reviewer := &fakeTokenReviewer{}
+	handler := authenticationMiddleware(newTokenAuthenticator(reviewer))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requestUser(r)
+		require.True(t, ok)
+		w.Write([]byte(user.Username))
+	}))
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "missing token", expectedStatus: http.StatusUnauthorized},
+		{name: "invalid token", authorization: "Bearer invalid", expectedStatus: http.StatusUnauthorized},
+		{name: "valid token", authorization: "Bearer valid", expectedStatus: http.StatusOK, expectedBody: "developer"},
+		{name: "cached valid token", authorization: "Bearer valid", expectedStatus: http.StatusOK, expectedBody: "developer"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/config", nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedBody != "" {
+				require.Equal(t, tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	// the second valid request is served from the cache
+	require.Equal(t, 2, reviewer.reviews)
+}
+
+func TestAuthenticateAllMiddleware(t *testing.T) {
+	reviewer := &fakeTokenReviewer{}
+	authenticator := newTokenAuthenticator(reviewer)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	routes := http.NewServeMux()
+	routes.Handle("/", ok)
+	routes.Handle("/config", authenticationMiddleware(authenticator)(ok))
+	handler := authenticateAllMiddleware(authenticator, DefaultPublicPaths)(routes)
+
+	tests := []struct {
+		name           string
+		path           string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "public path", path: "/healthz", expectedStatus: http.StatusOK},
+		{name: "public pattern", path: "/health/live", expectedStatus: http.StatusOK},
+		{name: "static file", path: "/plugin-entry.js", expectedStatus: http.StatusUnauthorized},
+		{name: "invalid token", path: "/plugin-entry.js", authorization: "Bearer invalid", expectedStatus: http.StatusUnauthorized},
+		{name: "valid token", path: "/plugin-entry.js", authorization: "Bearer valid", expectedStatus: http.StatusOK},
+		{name: "authenticated route", path: "/config", authorization: "Bearer valid", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			require.Equal(t, tc.expectedStatus, w.Code)
+		})
+	}
+
+	// the invalid and the valid tokens are reviewed once each
+	require.Equal(t, 2, reviewer.reviews)
+}
+
+func TestParsePublicPaths(t *testing.T) {
+	patterns, err := ParsePublicPaths(" /healthz, /static/*,,")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/healthz", "/static/*"}, patterns)
+
+	_, err = ParsePublicPaths("healthz")
+	require.ErrorContains(t, err, "it must start with /")
+
+	_, err = ParsePublicPaths("/static/[")
+	require.ErrorContains(t, err, "invalid public path")
+}
+
+// requireAuthenticatedRoute checks that the route of target rejects the
+// requests without a valid bearer token once the authentication is enabled
+func requireAuthenticatedRoute(t *testing.T, method string, target string, body string) {
+	pluginConfig, err := parsePluginConfig([]byte(""))
+	require.NoError(t, err)
+	router := setupRoutes(&Config{StaticPath: t.TempDir()}, &reloadingPluginConfig{config: pluginConfig}, routeDeps{authenticator: newTokenAuthenticator(&fakeTokenReviewer{})})
+
+	for token, expectedStatus := range map[string]int{"": http.StatusUnauthorized, "invalid": http.StatusUnauthorized, "valid": http.StatusOK} {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, expectedStatus, w.Code, "token %q: %s", token, w.Body.String())
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+)
+
+// namespaceLabel is the log stream label holding the namespace of the logs
+const namespaceLabel = "kubernetes_namespace_name"
+
+var (
+	namespaceRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	// tenantRegexp matches the tenants accepted by the proxy
+	tenantRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// AuthorizationConfig enables the namespace authorization of the queries sent
+// to Tenants, the user must be allowed Verb on Resource in every namespace
+// selected by the query
+type AuthorizationConfig struct {
+	Enabled     bool     `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Tenants     []string `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+	Verb        string   `yaml:"verb,omitempty" json:"verb,omitempty"`
+	Group       string   `yaml:"group,omitempty" json:"group,omitempty"`
+	Resource    string   `yaml:"resource,omitempty" json:"resource,omitempty"`
+	Subresource string   `yaml:"subresource,omitempty" json:"subresource,omitempty"`
+}
+
+var defaultAuthorizationConfig = AuthorizationConfig{
+	Tenants:     []string{"application"},
+	Verb:        "get",
+	Resource:    "pods",
+	Subresource: "log",
+}
+
+func (c AuthorizationConfig) resourceAttributes() kube.ResourceAttributes {
+	return kube.ResourceAttributes{
+		Verb:        c.Verb,
+		Group:       c.Group,
+		Resource:    c.Resource,
+		Subresource: c.Subresource,
+	}
+}
+
+// authorizationMiddleware rejects the queries to the tenants whose user is not
+// allowed to access every selected namespace, it serves the proxy and tail
+// handlers and must run after authenticationMiddleware. With serviceAccount,
+// the queries are sent with the plugin service account token and the other
+// tenants are refused. It does nothing when authorizer is nil and
+// serviceAccount is false
+func authorizationMiddleware(authorizer *authz.Authorizer, tenants []string, serviceAccount bool) func(next http.Handler) http.Handler {
+	enforcedTenants := map[string]bool{}
+	for _, tenant := range tenants {
+		enforcedTenants[tenant] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		if authorizer == nil && !serviceAccount {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if !enforcedTenants[tenant] || authorizer == nil {
+				if serviceAccount {
+					writeError(w, r, http.StatusForbidden, errorCodeForbidden, fmt.Sprintf("the %s tenant is not authorized by the plugin and cannot be queried with its service account token", tenant), nil)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, ok := requestUser(r)
+			if !ok {
+				writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "the request is not authenticated", nil)
+				return
+			}
+
+			namespaces, err := queryNamespaces(r)
+			if err != nil {
+				writeError(w, r, http.StatusForbidden, errorCodeForbidden, err.Error(), nil)
+				return
+			}
+
+			for _, namespace := range namespaces {
+				allowed, err := authorizer.Authorize(r.Context(), user, namespace)
+				if err != nil {
+					requestLog(slog, r).WithError(err).Error("cannot review access")
+					writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "cannot authorize the request", nil)
+					return
+				}
+				if !allowed {
+					writeError(w, r, http.StatusForbidden, errorCodeForbidden, fmt.Sprintf("user %s cannot access the logs of namespace %s", user.Username, namespace), nil)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, withAuthorizedQuery(r))
+		})
+	}
+}
+
+// queryNamespaces returns the namespaces selected by the queries of r, every
+// stream selector must restrict the namespace label to literal values. The
+// queries are parsed like Loki does, the queries that cannot be parsed are
+// rejected
+func queryNamespaces(r *http.Request) ([]string, error) {
+	params := r.URL.Query()
+	queries := append(append([]string{}, params["query"]...), params["match[]"]...)
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("a query selecting the %s label is required", namespaceLabel)
+	}
+	return logqlNamespaces(queries)
+}
+
+// logqlNamespaces returns the namespaces selected by queries, with the rules
+// of queryNamespaces
+func logqlNamespaces(queries []string) ([]string, error) {
+	namespaces := []string{}
+	seen := map[string]bool{}
+
+	for _, query := range queries {
+		parsed, err := logql.Parse(query)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse query: %w", err)
+		}
+		if len(parsed.Selectors) == 0 {
+			return nil, fmt.Errorf("the query must select the %s label", namespaceLabel)
+		}
+
+		for _, selector := range parsed.Selectors {
+			selectorNamespaces := selectorNamespaces(selector.Matchers)
+			if len(selectorNamespaces) == 0 {
+				return nil, fmt.Errorf("every stream selector must select the %s label with = or a =~ list of names", namespaceLabel)
+			}
+			for _, namespace := range selectorNamespaces {
+				if !seen[namespace] {
+					seen[namespace] = true
+					namespaces = append(namespaces, namespace)
+				}
+			}
+		}
+	}
+
+	return namespaces, nil
+}
+
+// selectorNamespaces returns the namespaces of the first namespace matcher of
+// the selector, nil when the matcher values cannot be listed
+func selectorNamespaces(selector []logql.Matcher) []string {
+	for _, m := range selector {
+		if m.Label != namespaceLabel {
+			continue
+		}
+
+		var values []string
+		switch m.Operator {
+		case "=":
+			values = []string{m.Value}
+		case "=~":
+			values = strings.Split(m.Value, "|")
+		default:
+			continue
+		}
+
+		for _, value := range values {
+			if !namespaceRegexp.MatchString(value) {
+				return nil
+			}
+		}
+		return values
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAccessReviewer struct {
+	allowedNamespaces map[string]bool
+}
+
+func (f *fakeAccessReviewer) ReviewAccess(_ context.Context, spec kube.SubjectAccessReviewSpec) (*kube.SubjectAccessReviewStatus, error) {
+	return &kube.SubjectAccessReviewStatus{Allowed: f.allowedNamespaces[spec.ResourceAttributes.Namespace]}, nil
+}
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	authorizer := authz.New(&fakeAccessReviewer{allowedNamespaces: map[string]bool{"my-app": true, "my-db": true}}, defaultAuthorizationConfig.resourceAttributes())
+	handler := authorizationMiddleware(authorizer, []string{"application"}, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		name           string
+		path           string
+		query          string
+		expectedStatus int
+	}{
+		{name: "allowed namespace", path: "/application/loki/api/v1/query_range", query: `{kubernetes_namespace_name="my-app"} |= "error"`, expectedStatus: http.StatusOK},
+		{name: "allowed namespaces list", path: "/application/loki/api/v1/query_range", query: `sum(rate({kubernetes_namespace_name=~"my-app|my-db"}[5m]))`, expectedStatus: http.StatusOK},
+		{name: "denied namespace", path: "/application/loki/api/v1/query_range", query: `{kubernetes_namespace_name="other"}`, expectedStatus: http.StatusForbidden},
+		{name: "unrestricted selector", path: "/application/loki/api/v1/query_range", query: `{kubernetes_namespace_name="my-app"} or {app="x"}`, expectedStatus: http.StatusForbidden},
+		{name: "namespace regex", path: "/application/loki/api/v1/query_range", query: `{kubernetes_namespace_name=~"my-.*"}`, expectedStatus: http.StatusForbidden},
+		{name: "commented selector", path: "/application/loki/api/v1/query", query: "count_over_time({kubernetes_namespace_name=\"my-app\"}[1m]) # \"\nor count_over_time({kubernetes_namespace_name=\"secret\"}[1m]) # \"", expectedStatus: http.StatusForbidden},
+		{name: "single quotes", path: "/application/loki/api/v1/query_range", query: `{kubernetes_namespace_name="my-app"} |= 'x {kubernetes_namespace_name="secret"}'`, expectedStatus: http.StatusForbidden},
+		{name: "invalid query", path: "/application/loki/api/v1/query_range", query: `{kubernetes_namespace_name="my-app"} |=`, expectedStatus: http.StatusForbidden},
+		{name: "missing query", path: "/application/loki/api/v1/labels", expectedStatus: http.StatusForbidden},
+		{name: "tail", path: "/application", query: `{kubernetes_namespace_name="my-app"}`, expectedStatus: http.StatusOK},
+		{name: "not enforced tenant", path: "/infrastructure/loki/api/v1/labels", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			target := tc.path
+			if tc.query != "" {
+				target += "?query=" + url.QueryEscape(tc.query)
+			}
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			r = r.WithContext(context.WithValue(r.Context(), userKey{}, &kube.UserInfo{Username: "developer"}))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestQueryNamespaces(t *testing.T) {
+	// the comments are skipped like Loki does, the selectors following a
+	// quote in a comment are kept
+	query := "count_over_time({kubernetes_namespace_name=\"mine\"}[1m]) # \"\nor count_over_time({kubernetes_namespace_name=\"secret\"}[1m]) # \""
+	namespaces, err := queryNamespaces(httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query?query="+url.QueryEscape(query), nil))
+	require.NoError(t, err)
+	require.Equal(t, []string{"mine", "secret"}, namespaces)
+
+	_, err = queryNamespaces(httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query?query="+url.QueryEscape(`{kubernetes_namespace_name="mine"} |= 'x'`), nil))
+	require.Error(t, err)
+}
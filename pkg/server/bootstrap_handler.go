@@ -0,0 +1,151 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+)
+
+// the capabilities of the backend besides its default features, the served
+// default features are capabilities too
+const (
+	capabilityTail         = "tail"
+	capabilityPodLogs      = "podLogs"
+	capabilitySavedQueries = "savedQueries"
+	capabilityQueryHistory = "queryHistory"
+	capabilityExportJobs   = "exportJobs"
+)
+
+// the scopes of the tenants available to a user
+const (
+	// tenantScopeCluster tenants are queried in every namespace
+	tenantScopeCluster = "cluster"
+	// tenantScopeNamespaces tenants are queried in the namespaces the user
+	// can access
+	tenantScopeNamespaces = "namespaces"
+)
+
+// bootstrapResponse is everything the front-end needs before it renders: the
+// /config payload, the /features payload, the backend capabilities and the
+// tenants available to the user
+type bootstrapResponse struct {
+	Config   json.RawMessage  `json:"config"`
+	Features featuresResponse `json:"features"`
+	// Capabilities are the backend subsystems served, like export or
+	// events
+	Capabilities   []string             `json:"capabilities"`
+	Authentication bool                 `json:"authentication"`
+	Datasources    []string             `json:"datasources"`
+	Tenants        []tenantAvailability `json:"tenants"`
+}
+
+// tenantAvailability tells whether the user can query a tenant, and in which
+// namespaces. The scope is unset when the datasource authorizes the queries
+type tenantAvailability struct {
+	Tenant    string `json:"tenant"`
+	Available bool   `json:"available"`
+	Scope     string `json:"scope,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// bootstrapHandler serves /config, in the schema version requested like
+// /config, with /features, the capabilities and the tenants available to the
+// user in one response. The config payload follows the reloads, the
+// capabilities are the routes registered at startup. The response depends on
+// the user, it is cached privately and revalidated with its ETag
+func bootstrapHandler(cfg *Config, reloadingConfig *reloadingPluginConfig, capabilities map[string]bool, authorizer *authz.Authorizer, serviceAccount bool) http.HandlerFunc {
+	cache := newResponseCache()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, err := configSchemaVersion(r)
+		if err != nil {
+			writeError(w, r, http.StatusNotAcceptable, errorCodeInvalidRequest, err.Error(), nil)
+			return
+		}
+
+		pluginConfig, generation, loadedAt := reloadingConfig.snapshot()
+		config := cache.get(generation, fmt.Sprintf("config/%d", version), func() ([]byte, error) {
+			return marshalConfigSchema(pluginConfig, version)
+		})
+		if config.err != nil {
+			requestLog(slog, r).WithError(config.err).Error("cannot marshal the config")
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal config", config.err.Error())
+			return
+		}
+
+		resolved := resolveFeatures(cfg.Features, pluginConfig)
+		response := bootstrapResponse{
+			Config:         config.body,
+			Features:       featuresResponse{Features: resolved.enabled, Disabled: resolved.disabled},
+			Capabilities:   []string{},
+			Authentication: cfg.AuthenticationEnabled,
+			Datasources:    []string{},
+			Tenants:        tenantAvailabilities(r, pluginConfig, authorizer, serviceAccount),
+		}
+		for capability, served := range capabilities {
+			if served {
+				response.Capabilities = append(response.Capabilities, capability)
+			}
+		}
+		sort.Strings(response.Capabilities)
+		for _, ds := range pluginConfig.allDatasources() {
+			response.Datasources = append(response.Datasources, ds.Name)
+		}
+
+		body, err := json.Marshal(response)
+		if err != nil {
+			requestLog(slog, r).WithError(err).Error("cannot marshal the bootstrap response")
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal the bootstrap response", err.Error())
+			return
+		}
+		hash := sha256.Sum256(body)
+
+		w.Header().Add("Vary", "Accept")
+		if w.Header().Get("Cache-Control") == "" {
+			w.Header().Set("Cache-Control", "private, no-cache")
+		}
+		writeCachedJSON(w, r, cachedResponse{body: body, etag: fmt.Sprintf("%q", hex.EncodeToString(hash[:])[:32])}, loadedAt)
+	})
+}
+
+// tenantAvailabilities returns the tenants of the plugin config available to
+// the user of r. The tenants authorized by the plugin are queried in every
+// namespace by the users allowed the access cluster-wide, and in the
+// namespaces they can access otherwise. With the service account token, the
+// other tenants are refused
+func tenantAvailabilities(r *http.Request, pluginConfig *PluginConfig, authorizer *authz.Authorizer, serviceAccount bool) []tenantAvailability {
+	enforced := map[string]bool{}
+	if authorizer != nil {
+		for _, tenant := range pluginConfig.Authorization.Tenants {
+			enforced[tenant] = true
+		}
+	}
+	user, authenticated := requestUser(r)
+
+	tenants := []tenantAvailability{}
+	for _, tenant := range pluginConfig.proxiedTenants() {
+		availability := tenantAvailability{Tenant: tenant, Available: true}
+		switch {
+		case enforced[tenant]:
+			availability.Scope = tenantScopeNamespaces
+			if authenticated {
+				allowed, err := authorizer.Authorize(r.Context(), user, "")
+				if err != nil {
+					requestLog(slog, r).WithError(err).Warnf("cannot review the cluster-wide access to the %s tenant", tenant)
+				} else if allowed {
+					availability.Scope = tenantScopeCluster
+				}
+			}
+		case serviceAccount:
+			availability.Available = false
+			availability.Reason = "the tenant is not authorized by the plugin and cannot be queried with its service account token"
+		}
+		tenants = append(tenants, availability)
+	}
+	return tenants
+}
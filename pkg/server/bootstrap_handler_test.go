@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootstrapHandler(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("lokiURL: https://loki.local\nlogsLimit: 50\nfeatures:\n  export: false\n"), 0600))
+	reloadingConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{StaticPath: t.TempDir(), Features: map[string]bool{"dev-console": true}}, reloadingConfig, routeDeps{})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bootstrap", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	response := bootstrapResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Contains(t, string(response.Config), `"logsLimit":50`)
+	require.Contains(t, response.Features.Features, "dev-console")
+	require.Contains(t, response.Capabilities, capabilityTail)
+	require.Contains(t, response.Capabilities, featureVolume)
+	require.NotContains(t, response.Capabilities, featureExport)
+	require.Equal(t, []string{"default"}, response.Datasources)
+	require.NotEmpty(t, response.Tenants)
+	for _, tenant := range response.Tenants {
+		require.True(t, tenant.Available, tenant.Tenant)
+		require.Empty(t, tenant.Scope, tenant.Tenant)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/bootstrap", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestTenantAvailabilities(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte("lokiURL: https://loki.local\nauthorization:\n  tenants: [application]\n"))
+	require.NoError(t, err)
+
+	availabilities := func(authorizer *authz.Authorizer, serviceAccount bool) map[string]tenantAvailability {
+		r := httptest.NewRequest(http.MethodGet, "/bootstrap", nil)
+		r = r.WithContext(context.WithValue(r.Context(), userKey{}, &kube.UserInfo{Username: "developer"}))
+		tenants := map[string]tenantAvailability{}
+		for _, tenant := range tenantAvailabilities(r, pluginConfig, authorizer, serviceAccount) {
+			tenants[tenant.Tenant] = tenant
+		}
+		return tenants
+	}
+
+	attributes := defaultAuthorizationConfig.resourceAttributes()
+	tenants := availabilities(authz.New(&fakeAccessReviewer{allowedNamespaces: map[string]bool{"my-app": true}}, attributes), false)
+	require.Equal(t, tenantAvailability{Tenant: "application", Available: true, Scope: tenantScopeNamespaces}, tenants["application"])
+	require.Equal(t, tenantAvailability{Tenant: "infrastructure", Available: true}, tenants["infrastructure"])
+
+	tenants = availabilities(authz.New(&fakeAccessReviewer{allowedNamespaces: map[string]bool{"": true}}, attributes), true)
+	require.Equal(t, tenantScopeCluster, tenants["application"].Scope)
+	require.False(t, tenants["infrastructure"].Available)
+	require.NotEmpty(t, tenants["infrastructure"].Reason)
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"net/http"
+	"path"
+)
+
+func cacheControlMiddleware(rules []CacheControlRule) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rule := range rules {
+				if matched, _ := path.Match(rule.Pattern, r.URL.Path); matched {
+					w.Header().Set("Cache-Control", rule.Value)
+					break
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// readinessWarning is returned by the readiness checks reporting a problem
+// that does not make the backend unready yet
+type readinessWarning struct {
+	message string
+}
+
+func (w *readinessWarning) Error() string {
+	return w.message
+}
+
+// certificateExpiryCheck reports the serving certificates expiring within
+// window as a warning and the expired ones as an error, the time left is
+// exported in the cert_expiry_seconds gauge
+func certificateExpiryCheck(certificates *servingCertificates, window time.Duration, now func() time.Time) func(context.Context) error {
+	return func(context.Context) error {
+		loaded := certificates.loaded()
+		names := make([]string, 0, len(loaded))
+		for name := range loaded {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		expired, expiring := []string{}, []string{}
+		for _, name := range names {
+			notAfter, err := certificateNotAfter(loaded[name])
+			if err != nil {
+				return fmt.Errorf("cannot parse %s certificate: %w", name, err)
+			}
+
+			left := notAfter.Sub(now())
+			metrics.CertExpirySeconds.WithLabelValues(name).Set(left.Seconds())
+
+			switch {
+			case left <= 0:
+				expired = append(expired, fmt.Sprintf("%s certificate expired at %s", name, notAfter.UTC().Format(time.RFC3339)))
+			case left <= window:
+				expiring = append(expiring, fmt.Sprintf("%s certificate expires at %s", name, notAfter.UTC().Format(time.RFC3339)))
+			}
+		}
+
+		if len(expired) > 0 {
+			return fmt.Errorf("%s", strings.Join(append(expired, expiring...), ", "))
+		}
+		if len(expiring) > 0 {
+			return &readinessWarning{message: strings.Join(expiring, ", ")}
+		}
+		return nil
+	}
+}
+
+// certificateNotAfter returns the expiry of the leaf certificate of cert
+func certificateNotAfter(cert *tls.Certificate) (time.Time, error) {
+	if cert.Leaf != nil {
+		return cert.Leaf.NotAfter, nil
+	}
+	if len(cert.Certificate) == 0 {
+		return time.Time{}, fmt.Errorf("no certificate")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return time.Time{}, err
+	}
+	return leaf.NotAfter, nil
+}
@@ -0,0 +1,394 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+var clog = logrus.WithField("module", "certificates")
+
+// certificateCheckInterval is the minimum time between two checks of the
+// certificate files on disk
+var certificateCheckInterval = 10 * time.Second
+
+// SNICertificate is a certificate and private key pair served to the clients
+// requesting Hostname, wildcard hostnames like `*.example.com` are supported
+type SNICertificate struct {
+	Hostname       string
+	CertFile       string
+	PrivateKeyFile string
+}
+
+// ParseSNICertificates parses a comma separated list of SNI certificates with
+// the form `<hostname>=<cert-file>:<key-file>`
+func ParseSNICertificates(value string) ([]SNICertificate, error) {
+	certificates := []SNICertificate{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		hostname, files, found := strings.Cut(entry, "=")
+		if !found || hostname == "" {
+			return nil, fmt.Errorf("invalid SNI certificate %q, expected <hostname>=<cert-file>:<key-file>", entry)
+		}
+
+		certFile, keyFile, found := strings.Cut(files, ":")
+		if !found || certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("invalid SNI certificate %q, expected <hostname>=<cert-file>:<key-file>", entry)
+		}
+
+		certificates = append(certificates, SNICertificate{
+			Hostname:       strings.ToLower(hostname),
+			CertFile:       certFile,
+			PrivateKeyFile: keyFile,
+		})
+	}
+
+	return certificates, nil
+}
+
+// reloadingCertificate loads a certificate from disk and reloads it when the
+// files change
+type reloadingCertificate struct {
+	certFile  string
+	keyFile   string
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newReloadingCertificate(certFile, keyFile string) (*reloadingCertificate, error) {
+	c := &reloadingCertificate{certFile: certFile, keyFile: keyFile}
+	if _, err := c.get(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *reloadingCertificate) get() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cert != nil && time.Since(c.lastCheck) < certificateCheckInterval {
+		return c.cert, nil
+	}
+	c.lastCheck = time.Now()
+
+	modTime, err := latestModTime(c.certFile, c.keyFile)
+	if err != nil {
+		if c.cert != nil {
+			clog.WithError(err).Warnf("cannot check certificate %s, using the loaded one", c.certFile)
+			return c.cert, nil
+		}
+		return nil, err
+	}
+
+	if c.cert != nil && modTime.Equal(c.modTime) {
+		return c.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		if c.cert != nil {
+			clog.WithError(err).Warnf("cannot reload certificate %s, using the loaded one", c.certFile)
+			metrics.TLSReloadsTotal.WithLabelValues("file", "failure").Inc()
+			return c.cert, nil
+		}
+		return nil, err
+	}
+
+	if c.cert != nil {
+		clog.Infof("reloaded certificate %s", c.certFile)
+		metrics.TLSReloadsTotal.WithLabelValues("file", "success").Inc()
+	}
+
+	c.cert = &cert
+	c.modTime = modTime
+
+	return c.cert, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// sniCertificates selects the serving certificate by the hostname requested
+// by the client
+type sniCertificates map[string]*reloadingCertificate
+
+func newSNICertificates(certificates []SNICertificate) (sniCertificates, error) {
+	sni := sniCertificates{}
+
+	for _, c := range certificates {
+		cert, err := newReloadingCertificate(c.CertFile, c.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load certificate for %s: %w", c.Hostname, err)
+		}
+		sni[c.Hostname] = cert
+	}
+
+	return sni, nil
+}
+
+// getCertificate implements tls.Config.GetCertificate, a nil certificate
+// makes the handshake fall back to tls.Config.Certificates
+func (sni sniCertificates) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	hostname := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if hostname == "" {
+		return nil, nil
+	}
+
+	if cert, ok := sni[hostname]; ok {
+		return cert.get()
+	}
+
+	if _, domain, found := strings.Cut(hostname, "."); found {
+		if cert, ok := sni["*."+domain]; ok {
+			return cert.get()
+		}
+	}
+
+	return nil, nil
+}
+
+// ServiceCADirs are the usual mount directories of the serving certificate
+// secret generated by the OpenShift service-ca operator
+var ServiceCADirs = []string{
+	"/var/serving-cert",
+	"/var/run/secrets/serving-cert",
+	"/etc/tls/private",
+}
+
+// DetectServingCertificate looks for a serving certificate mounted in dirs,
+// the first directory holding both tls.crt and tls.key wins, and returns the
+// certificate and private key paths when found
+func DetectServingCertificate(dirs ...string) (string, string, bool) {
+	for _, dir := range dirs {
+		certFile := filepath.Join(dir, "tls.crt")
+		keyFile := filepath.Join(dir, "tls.key")
+
+		if _, err := latestModTime(certFile, keyFile); err == nil {
+			return certFile, keyFile, true
+		}
+	}
+
+	return "", "", false
+}
+
+// secretCertificatePollInterval is the time between two fetches of the
+// serving certificate secret
+const secretCertificatePollInterval = time.Minute
+
+// secretCertificate serves the certificate stored in a kubernetes.io/tls
+// Secret, polled through the API to pick up rotations
+type secretCertificate struct {
+	client          *kube.Client
+	namespace       string
+	name            string
+	mu              sync.RWMutex
+	cert            *tls.Certificate
+	resourceVersion string
+}
+
+func newSecretCertificate(client *kube.Client, reference string) (*secretCertificate, error) {
+	namespace, name, err := kube.ParseNamespacedName(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &secretCertificate{client: client, namespace: namespace, name: name}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := s.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *secretCertificate) sync(ctx context.Context) error {
+	secret, err := s.client.GetSecret(ctx, s.namespace, s.name)
+	if err != nil {
+		return fmt.Errorf("cannot get certificate secret %s/%s: %w", s.namespace, s.name, err)
+	}
+
+	s.mu.RLock()
+	unchanged := s.cert != nil && secret.Metadata.ResourceVersion == s.resourceVersion
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(secret.Data["tls.crt"], secret.Data["tls.key"])
+	if err != nil {
+		metrics.TLSReloadsTotal.WithLabelValues("secret", "failure").Inc()
+		return fmt.Errorf("invalid certificate in secret %s/%s: %w", s.namespace, s.name, err)
+	}
+
+	s.mu.Lock()
+	if s.cert != nil {
+		clog.Infof("reloaded certificate from secret %s/%s", s.namespace, s.name)
+		metrics.TLSReloadsTotal.WithLabelValues("secret", "success").Inc()
+	}
+	s.cert = &cert
+	s.resourceVersion = secret.Metadata.ResourceVersion
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *secretCertificate) poll(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := s.sync(ctx); err != nil {
+			clog.WithError(err).Warn("cannot refresh certificate secret, using the loaded one")
+		}
+		cancel()
+	}
+}
+
+func (s *secretCertificate) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cert, nil
+}
+
+// servingCertificates are the dynamic serving certificate sources of the
+// config, CertFile is served when no other source matches
+type servingCertificates struct {
+	sni    sniCertificates
+	secret *secretCertificate
+	file   *reloadingCertificate
+}
+
+// newServingCertificates loads the serving certificates of the config, nil
+// when none is configured
+func newServingCertificates(cfg *Config) (*servingCertificates, error) {
+	certificates := &servingCertificates{}
+
+	if len(cfg.SNICertificates) > 0 {
+		var err error
+		if certificates.sni, err = newSNICertificates(cfg.SNICertificates); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.CertSecret != "" {
+		client, err := kube.NewInClusterClient()
+		if err != nil {
+			return nil, err
+		}
+		if certificates.secret, err = newSecretCertificate(client, cfg.CertSecret); err != nil {
+			return nil, err
+		}
+		go certificates.secret.poll(secretCertificatePollInterval)
+	}
+
+	if cfg.CertFile != "" && cfg.PrivateKeyFile != "" {
+		var err error
+		if certificates.file, err = newReloadingCertificate(cfg.CertFile, cfg.PrivateKeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if certificates.sni == nil && certificates.secret == nil && certificates.file == nil {
+		return nil, nil
+	}
+	return certificates, nil
+}
+
+// getCertificate implements tls.Config.GetCertificate
+func (c *servingCertificates) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if c.sni != nil {
+		if cert, err := c.sni.getCertificate(hello); cert != nil || err != nil {
+			return cert, err
+		}
+	}
+	if c.secret != nil {
+		return c.secret.getCertificate(hello)
+	}
+	if c.file != nil {
+		return c.file.get()
+	}
+	return nil, nil
+}
+
+// loaded returns the loaded serving certificates by source: the SNI
+// hostnames, secret and file
+func (c *servingCertificates) loaded() map[string]*tls.Certificate {
+	loaded := map[string]*tls.Certificate{}
+	for hostname, sniCert := range c.sni {
+		if cert, err := sniCert.get(); err == nil {
+			loaded[hostname] = cert
+		}
+	}
+	if c.secret != nil {
+		if cert, _ := c.secret.getCertificate(nil); cert != nil {
+			loaded["secret"] = cert
+		}
+	}
+	if c.file != nil {
+		if cert, err := c.file.get(); err == nil {
+			loaded["file"] = cert
+		}
+	}
+	return loaded
+}
+
+// newTLSConfig builds the server TLS config serving certificates, which may
+// be nil
+func newTLSConfig(cfg *Config, certificates *servingCertificates) (*tls.Config, error) {
+	// clients must use TLS 1.2 or higher
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if err := applyTLSPolicy(tlsConfig, cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.ClientCAFile != "" {
+		clientCA, err := newReloadingCAPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientAuth = tls.RequestClientCert
+		tlsConfig.VerifyConnection = clientCA.verifyConnection
+	}
+
+	if certificates != nil {
+		tlsConfig.GetCertificate = certificates.getCertificate
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSNICertificates(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "certificates-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	internalCert := filepath.Join(tmpDir, "internal.cert")
+	internalKey := filepath.Join(tmpDir, "internal.key")
+	require.NoError(t, generateCertificate(t, internalCert, internalKey, "plugin.svc"))
+
+	routeCert := filepath.Join(tmpDir, "route.cert")
+	routeKey := filepath.Join(tmpDir, "route.key")
+	require.NoError(t, generateCertificate(t, routeCert, routeKey, "logs.example.com"))
+
+	certificates, err := ParseSNICertificates("plugin.svc=" + internalCert + ":" + internalKey + ",*.example.com=" + routeCert + ":" + routeKey)
+	require.NoError(t, err)
+
+	sni, err := newSNICertificates(certificates)
+	require.NoError(t, err)
+
+	cert, err := sni.getCertificate(&tls.ClientHelloInfo{ServerName: "plugin.svc"})
+	require.NoError(t, err)
+	require.Equal(t, "plugin.svc", certificateHostname(t, cert))
+
+	cert, err = sni.getCertificate(&tls.ClientHelloInfo{ServerName: "Logs.Example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "logs.example.com", certificateHostname(t, cert))
+
+	cert, err = sni.getCertificate(&tls.ClientHelloInfo{ServerName: "unknown.host"})
+	require.NoError(t, err)
+	require.Nil(t, cert)
+
+	// rotate the route certificate on disk
+	require.NoError(t, generateCertificate(t, routeCert, routeKey, "logs-rotated.example.com"))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(routeCert, future, future))
+	sni["*.example.com"].lastCheck = time.Time{}
+
+	cert, err = sni.getCertificate(&tls.ClientHelloInfo{ServerName: "logs.example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "logs-rotated.example.com", certificateHostname(t, cert))
+}
+
+func certificateHostname(t *testing.T, cert *tls.Certificate) string {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.DNSNames[0]
+}
+
+func TestDetectServingCertificate(t *testing.T) {
+	tmpDir := t.TempDir()
+	missingDir := filepath.Join(tmpDir, "missing")
+	partialDir := filepath.Join(tmpDir, "partial")
+	servingCertDir := filepath.Join(tmpDir, "serving-cert")
+	otherDir := filepath.Join(tmpDir, "other")
+	for _, dir := range []string{partialDir, servingCertDir, otherDir} {
+		require.NoError(t, os.Mkdir(dir, 0700))
+	}
+
+	// a directory without the private key is skipped
+	require.NoError(t, os.WriteFile(filepath.Join(partialDir, "tls.crt"), []byte("cert"), 0600))
+	require.NoError(t, generateCertificate(t, filepath.Join(servingCertDir, "tls.crt"), filepath.Join(servingCertDir, "tls.key"), "plugin.svc"))
+	require.NoError(t, generateCertificate(t, filepath.Join(otherDir, "tls.crt"), filepath.Join(otherDir, "tls.key"), "other.svc"))
+
+	certFile, keyFile, found := DetectServingCertificate(missingDir, partialDir, servingCertDir, otherDir)
+	require.True(t, found)
+	require.Equal(t, filepath.Join(servingCertDir, "tls.crt"), certFile)
+	require.Equal(t, filepath.Join(servingCertDir, "tls.key"), keyFile)
+
+	_, _, found = DetectServingCertificate(missingDir, partialDir)
+	require.False(t, found)
+
+	_, _, found = DetectServingCertificate()
+	require.False(t, found)
+}
+
+func TestTLSConfigReloadsCertFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "certificates-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	certFile := filepath.Join(tmpDir, "tls.crt")
+	keyFile := filepath.Join(tmpDir, "tls.key")
+	require.NoError(t, generateCertificate(t, certFile, keyFile, "plugin.svc"))
+
+	cfg := &Config{CertFile: certFile, PrivateKeyFile: keyFile}
+	certificates, err := newServingCertificates(cfg)
+	require.NoError(t, err)
+	tlsConfig, err := newTLSConfig(cfg, certificates)
+	require.NoError(t, err)
+
+	cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "plugin.svc"})
+	require.NoError(t, err)
+	require.Equal(t, "plugin.svc", certificateHostname(t, cert))
+
+	// check the files on every handshake
+	defer func(interval time.Duration) { certificateCheckInterval = interval }(certificateCheckInterval)
+	certificateCheckInterval = 0
+
+	require.NoError(t, generateCertificate(t, certFile, keyFile, "plugin-rotated.svc"))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+
+	cert, err = tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "plugin.svc"})
+	require.NoError(t, err)
+	require.Equal(t, "plugin-rotated.svc", certificateHostname(t, cert))
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// clientCertPaths are the path prefixes of the routes requiring a client
+// certificate, the probes and the frontend files stay open
+var clientCertPaths = []string{"/api/"}
+
+// reloadingCAPool loads the CA bundle verifying the client certificates and
+// reloads it when the file changes
+type reloadingCAPool struct {
+	caFile    string
+	mu        sync.Mutex
+	pool      *x509.CertPool
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newReloadingCAPool(caFile string) (*reloadingCAPool, error) {
+	p := &reloadingCAPool{caFile: caFile}
+	if _, err := p.get(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *reloadingCAPool) get() (*x509.CertPool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pool != nil && time.Since(p.lastCheck) < certificateCheckInterval {
+		return p.pool, nil
+	}
+	p.lastCheck = time.Now()
+
+	modTime, err := latestModTime(p.caFile)
+	if err != nil {
+		if p.pool != nil {
+			clog.WithError(err).Warnf("cannot check client CA %s, using the loaded one", p.caFile)
+			return p.pool, nil
+		}
+		return nil, err
+	}
+
+	if p.pool != nil && modTime.Equal(p.modTime) {
+		return p.pool, nil
+	}
+
+	pool, err := loadCAPool(p.caFile)
+	if err != nil {
+		if p.pool != nil {
+			clog.WithError(err).Warnf("cannot reload client CA %s, using the loaded one", p.caFile)
+			metrics.TLSReloadsTotal.WithLabelValues("client-ca", "failure").Inc()
+			return p.pool, nil
+		}
+		return nil, err
+	}
+
+	if p.pool != nil {
+		clog.Infof("reloaded client CA %s", p.caFile)
+		metrics.TLSReloadsTotal.WithLabelValues("client-ca", "success").Inc()
+	}
+
+	p.pool = pool
+	p.modTime = modTime
+
+	return p.pool, nil
+}
+
+func loadCAPool(caFile string) (*x509.CertPool, error) {
+	caData, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no certificate found in client CA %s", caFile)
+	}
+	return pool, nil
+}
+
+// verifyConnection verifies the client certificate of the connections
+// presenting one against the current CA bundle. The certificates are
+// requested but not required, the routes requiring one check it with
+// clientCertMiddleware
+func (p *reloadingCAPool) verifyConnection(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	pool, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return errors.New("the client certificate is not trusted")
+	}
+	return nil
+}
+
+// clientCertMiddleware rejects the requests of the clientCertPaths sent
+// without a client certificate, the certificates presented are verified
+// during the handshake. It does nothing when enabled is false
+func clientCertMiddleware(enabled bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range clientCertPaths {
+				if strings.HasPrefix(r.URL.Path, prefix) && (r.TLS == nil || len(r.TLS.PeerCertificates) == 0) {
+					writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "a client certificate is required", nil)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
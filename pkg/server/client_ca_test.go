@@ -0,0 +1,142 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCertificateVerification(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "client-ca-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	caFile := filepath.Join(tmpDir, "ca.crt")
+	ca, caKey := generateClientCA(t, caFile, "client-ca")
+	clientCert := generateClientCertificate(t, ca, caKey, "console")
+
+	otherCA, otherCAKey := generateClientCA(t, filepath.Join(tmpDir, "other-ca.crt"), "other-ca")
+	untrustedCert := generateClientCertificate(t, otherCA, otherCAKey, "console")
+
+	clientCA, err := newReloadingCAPool(caFile)
+	require.NoError(t, err)
+
+	handler := clientCertMiddleware(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		ClientAuth:       tls.RequestClientCert,
+		VerifyConnection: clientCA.verifyConnection,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	client := func(certificates ...tls.Certificate) *http.Client {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = certificates
+		return &http.Client{Transport: transport}
+	}
+
+	get := func(client *http.Client, path string) int {
+		resp, err := client.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, get(client(clientCert), "/api/proxy/loki"))
+	require.Equal(t, http.StatusUnauthorized, get(client(), "/api/proxy/loki"))
+	require.Equal(t, http.StatusOK, get(client(), "/health"))
+
+	_, err = client(untrustedCert).Get(server.URL + "/health")
+	require.Error(t, err)
+
+	// rotate the CA bundle on disk
+	defer func(interval time.Duration) { certificateCheckInterval = interval }(certificateCheckInterval)
+	certificateCheckInterval = 0
+
+	otherCAData, err := os.ReadFile(filepath.Join(tmpDir, "other-ca.crt"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(caFile, otherCAData, 0600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(caFile, future, future))
+
+	require.Equal(t, http.StatusOK, get(client(untrustedCert), "/api/proxy/loki"))
+}
+
+func TestClientCertMiddlewareDisabled(t *testing.T) {
+	handler := clientCertMiddleware(false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/proxy/loki", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestReloadingCAPoolInvalidFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "client-ca-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	caFile := filepath.Join(tmpDir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0600))
+
+	_, err = newReloadingCAPool(caFile)
+	require.Error(t, err)
+
+	_, err = newReloadingCAPool(filepath.Join(tmpDir, "missing.crt"))
+	require.Error(t, err)
+}
+
+func generateClientCA(t *testing.T, caPath string, name string) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes}), 0600))
+
+	ca, err := x509.ParseCertificate(derBytes)
+	require.NoError(t, err)
+	return ca, key
+}
+
+func generateClientCertificate(t *testing.T, ca *x509.Certificate, caKey *rsa.PrivateKey, name string) tls.Certificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{derBytes}, PrivateKey: key}
+}
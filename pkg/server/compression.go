@@ -0,0 +1,277 @@
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/mux"
+)
+
+// CompressionConfig sets the gzip and deflate compression of the responses
+type CompressionConfig struct {
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	// Level is the compression level from 1 (best speed) to 9 (best
+	// compression), the default level is used when unset
+	Level int `yaml:"level,omitempty" json:"level,omitempty"`
+	// ExportLevel is the compression level of the exports, large files for
+	// which a faster level saves CPU, Level is used when unset
+	ExportLevel int `yaml:"exportLevel,omitempty" json:"exportLevel,omitempty"`
+	// MinSize is the size in bytes under which the responses are sent
+	// uncompressed, the default size is used when unset
+	MinSize int `yaml:"minSize,omitempty" json:"minSize,omitempty"`
+	// ExcludePaths are the path prefixes of the responses sent uncompressed,
+	// like streaming endpoints: /api/tail excludes /api/tail and every path
+	// below it
+	ExcludePaths []string `yaml:"excludePaths,omitempty" json:"excludePaths,omitempty"`
+	// ExcludeExtensions are the file extensions of the already compressed
+	// assets sent as is
+	ExcludeExtensions []string `yaml:"excludeExtensions,omitempty" json:"excludeExtensions,omitempty"`
+}
+
+var defaultCompressionConfig = CompressionConfig{
+	Level:             gzip.DefaultCompression,
+	MinSize:           1024,
+	ExcludePaths:      []string{"/api/tail", "/metrics"},
+	ExcludeExtensions: []string{".gz", ".br", ".zip", ".png", ".jpg", ".jpeg", ".gif", ".webp", ".woff", ".woff2"},
+}
+
+const (
+	gzipEncoding    = "gzip"
+	deflateEncoding = "deflate"
+	// exportPathPrefix is the prefix of the exports compressed at ExportLevel
+	exportPathPrefix = "/api/export/"
+)
+
+// compressionMiddleware compresses the responses of the clients accepting
+// gzip or deflate encodings, except the excluded paths and extensions and
+// the responses smaller than the MinSize of compression
+func compressionMiddleware(compression CompressionConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if compression.Disabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if compression.excluded(r.URL.Path) || servesPrecompressed(r) || acceptsEventStream(r) || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// the caches must keep the responses of each encoding apart
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := acceptedEncoding(r)
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// the handlers, like the proxies, send the response uncompressed
+			r.Header.Del("Accept-Encoding")
+
+			level := compression.Level
+			if compression.ExportLevel != 0 && strings.HasPrefix(r.URL.Path, exportPathPrefix) {
+				level = compression.ExportLevel
+			}
+			cw := &compressResponseWriter{w: w, encoding: encoding, level: level, minSize: compression.MinSize}
+			defer cw.close()
+
+			next.ServeHTTP(httpsnoop.Wrap(w, httpsnoop.Hooks{
+				WriteHeader: func(httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+					return cw.WriteHeader
+				},
+				Write: func(httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+					return cw.Write
+				},
+				ReadFrom: func(httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+					return func(src io.Reader) (int64, error) {
+						return io.Copy(cw, src)
+					}
+				},
+				Flush: func(next httpsnoop.FlushFunc) httpsnoop.FlushFunc {
+					return func() {
+						cw.Flush()
+						next()
+					}
+				},
+			}), r)
+		})
+	}
+}
+
+// acceptedEncoding returns the first of the gzip and deflate encodings
+// accepted by the client, empty when it accepts none
+func acceptedEncoding(r *http.Request) string {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding = strings.TrimSpace(encoding)
+		if encoding == gzipEncoding || encoding == deflateEncoding {
+			return encoding
+		}
+	}
+	return ""
+}
+
+func (c CompressionConfig) excluded(urlPath string) bool {
+	for _, prefix := range c.ExcludePaths {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+			return true
+		}
+	}
+
+	extension := strings.ToLower(path.Ext(urlPath))
+	for _, excluded := range c.ExcludeExtensions {
+		if extension != "" && strings.EqualFold(extension, excluded) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compressResponseWriter holds the response until minSize bytes are written,
+// then sends it compressed. The smaller responses are sent as is once the
+// handler returns
+type compressResponseWriter struct {
+	w        http.ResponseWriter
+	encoding string
+	level    int
+	minSize  int
+
+	status     int
+	buffer     []byte
+	started    bool
+	compressor io.WriteCloser
+}
+
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.started {
+		cw.w.WriteHeader(status)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if !cw.started {
+		if len(cw.buffer)+len(b) < cw.minSize {
+			cw.buffer = append(cw.buffer, b...)
+			return len(b), nil
+		}
+		if err := cw.start(true, b); err != nil {
+			return 0, err
+		}
+	}
+	return cw.writer().Write(b)
+}
+
+// Flush sends the response compressed whatever its size, the flushed
+// responses are streamed
+func (cw *compressResponseWriter) Flush() {
+	if !cw.started {
+		if err := cw.start(true, nil); err != nil {
+			return
+		}
+	}
+	if flusher, ok := cw.compressor.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+}
+
+// start sends the status and the held bytes, compressed when compress is
+// set and the response has a body that is not already encoded. next are the
+// bytes about to be written, their content type is detected when nothing is
+// held
+func (cw *compressResponseWriter) start(compress bool, next []byte) error {
+	cw.started = true
+	header := cw.w.Header()
+
+	if compress && header.Get("Content-Encoding") == "" && bodyAllowedForStatus(cw.status) {
+		// the content type is detected on the uncompressed bytes
+		if header.Get("Content-Type") == "" {
+			sniffed := cw.buffer
+			if len(sniffed) == 0 {
+				sniffed = next
+			}
+			if len(sniffed) > 0 {
+				header.Set("Content-Type", http.DetectContentType(sniffed))
+			}
+		}
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		if cw.encoding == gzipEncoding {
+			cw.compressor, _ = gzip.NewWriterLevel(cw.w, cw.level)
+		} else {
+			cw.compressor, _ = flate.NewWriter(cw.w, cw.level)
+		}
+	}
+
+	if cw.status != 0 {
+		cw.w.WriteHeader(cw.status)
+	}
+	if len(cw.buffer) == 0 {
+		return nil
+	}
+	_, err := cw.writer().Write(cw.buffer)
+	cw.buffer = nil
+	return err
+}
+
+func (cw *compressResponseWriter) writer() io.Writer {
+	if cw.compressor != nil {
+		return cw.compressor
+	}
+	return cw.w
+}
+
+// close sends the responses smaller than minSize and ends the compressed
+// ones
+func (cw *compressResponseWriter) close() error {
+	if !cw.started {
+		if err := cw.start(false, nil); err != nil {
+			return err
+		}
+	}
+	if cw.compressor != nil {
+		return cw.compressor.Close()
+	}
+	return nil
+}
+
+// bodyAllowedForStatus tells whether a response of the status has a body,
+// a zero status is the implicit 200
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// acceptsEventStream returns true for the server-sent events requests, their
+// events must reach the client as soon as they are flushed
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// servesPrecompressed returns true when the matched route serves a
+// precompressed sibling of the requested file
+func servesPrecompressed(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	files, ok := route.GetHandler().(*filesHandler)
+	if !ok {
+		return false
+	}
+	_, _, _, found := files.precompressed(r)
+	return found
+}
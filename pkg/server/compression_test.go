@@ -0,0 +1,113 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	pluginConfig, err := parsePluginConfig(nil)
+	require.NoError(t, err)
+
+	body := strings.Repeat("log line\n", 200)
+	handler := compressionMiddleware(pluginConfig.Compression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/small.json" {
+			w.Write([]byte(body[:100]))
+			return
+		}
+		// the body is written in two parts, the first under the minimum size
+		w.Write([]byte(body[:100]))
+		w.Write([]byte(body[100:]))
+	}))
+
+	tests := []struct {
+		name             string
+		path             string
+		acceptEncoding   string
+		accept           string
+		expectedEncoding string
+	}{
+		{name: "gzip", path: "/plugin-entry.js", acceptEncoding: "gzip, deflate", expectedEncoding: "gzip"},
+		{name: "deflate", path: "/plugin-entry.js", acceptEncoding: "deflate", expectedEncoding: "deflate"},
+		{name: "not accepted", path: "/plugin-entry.js"},
+		{name: "excluded path", path: "/api/tail/application", acceptEncoding: "gzip"},
+		{name: "excluded datasource path", path: "/api/tail/infra/application", acceptEncoding: "gzip"},
+		{name: "excluded sse path", path: "/api/tail/sse/application", acceptEncoding: "gzip"},
+		{name: "excluded path prefix only", path: "/api/tailored", acceptEncoding: "gzip", expectedEncoding: "gzip"},
+		{name: "excluded extension", path: "/assets/logo.PNG", acceptEncoding: "gzip"},
+		{name: "under the minimum size", path: "/small.json", acceptEncoding: "gzip"},
+		{name: "event stream", path: "/api/tail/infra/sse/application", acceptEncoding: "gzip", accept: "text/event-stream"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			r.Header.Set("Accept", tc.accept)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedEncoding, w.Header().Get("Content-Encoding"))
+			if tc.expectedEncoding == "gzip" {
+				reader, err := gzip.NewReader(w.Body)
+				require.NoError(t, err)
+				content, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				require.Equal(t, body, string(content))
+			} else if tc.path == "/small.json" {
+				require.Equal(t, body[:100], w.Body.String())
+			} else if tc.expectedEncoding == "" {
+				require.Equal(t, body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCompressionMiddlewareStatus(t *testing.T) {
+	handler := compressionMiddleware(defaultCompressionConfig)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("none"))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/missing", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	// the small responses keep their status and length
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, "4", w.Header().Get("Content-Length"))
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	require.Equal(t, "none", w.Body.String())
+}
+
+func TestCompressionMiddlewareExportLevel(t *testing.T) {
+	compression := defaultCompressionConfig
+	compression.Level = gzip.BestCompression
+	compression.ExportLevel = gzip.BestSpeed
+
+	body := strings.Repeat("log line\n", 200)
+	handler := compressionMiddleware(compression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+
+	// the XFL byte of the gzip header tells the fastest and best levels
+	for path, expectedFlags := range map[string]byte{"/api/export/application": 4, "/api/volume/application": 2} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		require.Equal(t, "gzip", w.Header().Get("Content-Encoding"), path)
+		require.Equal(t, expectedFlags, w.Body.Bytes()[8], path)
+	}
+}
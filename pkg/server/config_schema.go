@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// configSchemaVersion1 is the /config shape of the frontends predating the
+	// schema versions, served when no version is requested
+	configSchemaVersion1 = 1
+	// configSchemaVersion2 fixes the name of lokiTenantLabelKey
+	configSchemaVersion2 = 2
+	// latestConfigSchemaVersion is the newest /config shape
+	latestConfigSchemaVersion = configSchemaVersion2
+)
+
+// formats of the /config payload
+const (
+	configFormatJSON = "json"
+	// configFormatYAML is the effective config with the keys of the plugin
+	// config file, to compare it with the deployed one
+	configFormatYAML = "yaml"
+)
+
+// configSchemaUpgrades convert the /config payload of a version to the next
+// one, the payload of PluginConfig is the version 1. A version only adds its
+// conversion so that every older shape keeps being served
+var configSchemaUpgrades = map[int]func(payload map[string]interface{}){
+	configSchemaVersion2: func(payload map[string]interface{}) {
+		if value, found := payload["lokiTenanLabelKey"]; found {
+			delete(payload, "lokiTenanLabelKey")
+			payload["lokiTenantLabelKey"] = value
+		}
+	},
+}
+
+// configSchemaVersion returns the /config schema version requested with the
+// version query parameter, or with the version parameter of a JSON media
+// type of the Accept header, like application/json;version=2
+func configSchemaVersion(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("version")
+	if value == "" {
+		for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+			mediaType, params, err := mime.ParseMediaType(mediaRange)
+			if err == nil && mediaType == "application/json" && params["version"] != "" {
+				value = params["version"]
+				break
+			}
+		}
+	}
+	if value == "" {
+		return configSchemaVersion1, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(value, "v"))
+	if err != nil || version < configSchemaVersion1 || version > latestConfigSchemaVersion {
+		return 0, fmt.Errorf("unsupported config schema version %q, versions 1 to %d are supported", value, latestConfigSchemaVersion)
+	}
+	return version, nil
+}
+
+// marshalConfigSchema returns the JSON payload of pluginConfig in the schema
+// version, with its schemaVersion
+func marshalConfigSchema(pluginConfig *PluginConfig, version int) ([]byte, error) {
+	data, err := json.Marshal(pluginConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	for v := configSchemaVersion1 + 1; v <= version; v++ {
+		configSchemaUpgrades[v](payload)
+	}
+	payload["schemaVersion"] = version
+
+	return json.Marshal(payload)
+}
+
+// configFormat returns the /config format requested with the format query
+// parameter, or with a YAML media type of the Accept header
+func configFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case configFormatJSON, configFormatYAML:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unsupported config format %q, json and yaml are supported", format)
+	}
+
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return configFormatJSON, nil
+		case "application/yaml", "application/x-yaml", "text/yaml":
+			return configFormatYAML, nil
+		}
+	}
+	return configFormatJSON, nil
+}
+
+// marshalConfigYAML returns the YAML payload of pluginConfig, with the
+// defaults applied when it was parsed. The schema versions do not apply to
+// the keys of the plugin config file
+func marshalConfigYAML(pluginConfig *PluginConfig) ([]byte, error) {
+	return yaml.Marshal(pluginConfig)
+}
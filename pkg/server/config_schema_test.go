@@ -0,0 +1,151 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigSchemaVersions(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("logsLimit: 50\nlokiTenanLabelKey: tenant\ntimeout: 45s\n"), 0600))
+	reloadingConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+	handler := configHandler(reloadingConfig)
+
+	tests := []struct {
+		name           string
+		path           string
+		accept         string
+		expectedStatus int
+		expected       map[string]interface{}
+	}{
+		{
+			name:           "unversioned",
+			path:           "/config",
+			expectedStatus: http.StatusOK,
+			expected:       map[string]interface{}{"schemaVersion": 1.0, "logsLimit": 50.0, "lokiTenanLabelKey": "tenant", "timeout": "45s"},
+		},
+		{
+			name:           "version 1",
+			path:           "/config?version=1",
+			expectedStatus: http.StatusOK,
+			expected:       map[string]interface{}{"schemaVersion": 1.0, "logsLimit": 50.0, "lokiTenanLabelKey": "tenant", "timeout": "45s"},
+		},
+		{
+			name:           "version 2",
+			path:           "/config?version=2",
+			expectedStatus: http.StatusOK,
+			expected:       map[string]interface{}{"schemaVersion": 2.0, "logsLimit": 50.0, "lokiTenantLabelKey": "tenant", "timeout": "45s"},
+		},
+		{
+			name:           "accept header",
+			path:           "/config",
+			accept:         "application/json; version=2",
+			expectedStatus: http.StatusOK,
+			expected:       map[string]interface{}{"schemaVersion": 2.0, "logsLimit": 50.0, "lokiTenantLabelKey": "tenant", "timeout": "45s"},
+		},
+		{
+			name:           "query over accept header",
+			path:           "/config?version=v1",
+			accept:         "application/json; version=2",
+			expectedStatus: http.StatusOK,
+			expected:       map[string]interface{}{"schemaVersion": 1.0, "logsLimit": 50.0, "lokiTenanLabelKey": "tenant", "timeout": "45s"},
+		},
+		{
+			name:           "unsupported version",
+			path:           "/config?version=3",
+			expectedStatus: http.StatusNotAcceptable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expected == nil {
+				return
+			}
+
+			body := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			for key, value := range tt.expected {
+				require.Equal(t, value, body[key], key)
+			}
+			// the label key is only served with the name of the version
+			if tt.expected["schemaVersion"] == 1.0 {
+				require.NotContains(t, body, "lokiTenantLabelKey")
+			} else {
+				require.NotContains(t, body, "lokiTenanLabelKey")
+			}
+		})
+	}
+}
+
+func TestConfigYAML(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("logsLimit: 50\nlokiTenanLabelKey: tenant\ntimeout: 45s\n"), 0600))
+	reloadingConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+	handler := configHandler(reloadingConfig)
+
+	tests := []struct {
+		name           string
+		path           string
+		accept         string
+		expectedStatus int
+		expectedType   string
+	}{
+		{name: "format parameter", path: "/config?format=yaml", expectedStatus: http.StatusOK, expectedType: "application/yaml"},
+		{name: "accept header", path: "/config", accept: "application/yaml", expectedStatus: http.StatusOK, expectedType: "application/yaml"},
+		{name: "parameter over accept header", path: "/config?format=json", accept: "application/yaml", expectedStatus: http.StatusOK, expectedType: "application/json"},
+		{name: "json accept header", path: "/config", accept: "application/json, application/yaml", expectedStatus: http.StatusOK, expectedType: "application/json"},
+		{name: "unsupported format", path: "/config?format=toml", expectedStatus: http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tt.expectedType, w.Header().Get("Content-Type"))
+			if tt.expectedType != "application/yaml" {
+				return
+			}
+
+			// the keys are the ones of the config file, with the defaults
+			body := map[string]interface{}{}
+			require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &body))
+			require.Equal(t, 50, body["logsLimit"])
+			require.Equal(t, "tenant", body["lokiTenanLabelKey"])
+			require.Equal(t, "45s", body["timeout"])
+			require.Equal(t, 100, body["upstream"].(map[string]interface{})["maxInFlight"])
+			require.Equal(t, "30s", body["upstream"].(map[string]interface{})["openDuration"])
+		})
+	}
+}
+
+func TestConfigSchemaUpgrades(t *testing.T) {
+	// every version above the first one converts from the previous one
+	for version := configSchemaVersion1 + 1; version <= latestConfigSchemaVersion; version++ {
+		require.Contains(t, configSchemaUpgrades, version)
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+// configureConnections applies the HTTP/2, header size and keep-alive
+// settings of the config to the server, its TLS config must be set
+func configureConnections(httpServer *http.Server, cfg *Config) error {
+	if cfg.MaxHeaderBytes < 0 {
+		return fmt.Errorf("invalid max header bytes %d, it must be positive", cfg.MaxHeaderBytes)
+	}
+	httpServer.MaxHeaderBytes = cfg.MaxHeaderBytes
+	httpServer.SetKeepAlivesEnabled(!cfg.KeepAlivesDisabled)
+
+	if cfg.HTTP2Disabled {
+		if cfg.HTTP2MaxStreams != 0 {
+			return fmt.Errorf("the HTTP/2 max concurrent streams cannot be set with HTTP/2 disabled")
+		}
+		// a non-nil empty map disables the automatic HTTP/2 upgrade
+		httpServer.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		return nil
+	}
+
+	if cfg.HTTP2MaxStreams == 0 {
+		return nil
+	}
+
+	// ConfigureServer also checks that the cipher suites allow HTTP/2
+	if err := http2.ConfigureServer(httpServer, &http2.Server{
+		MaxConcurrentStreams: cfg.HTTP2MaxStreams,
+		IdleTimeout:          cfg.IdleTimeout,
+	}); err != nil {
+		return fmt.Errorf("cannot configure HTTP/2: %w", err)
+	}
+	return nil
+}
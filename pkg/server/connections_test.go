@@ -0,0 +1,58 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureConnections(t *testing.T) {
+	tests := []struct {
+		cfg           Config
+		expectedProto string
+	}{
+		{cfg: Config{}, expectedProto: "HTTP/2.0"},
+		{cfg: Config{HTTP2MaxStreams: 10}, expectedProto: "HTTP/2.0"},
+		{cfg: Config{HTTP2Disabled: true}, expectedProto: "HTTP/1.1"},
+	}
+
+	for _, tc := range tests {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		// like ServeTLS, h2 is only advertised when the server can serve it
+		server.EnableHTTP2 = !tc.cfg.HTTP2Disabled
+		server.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+		require.NoError(t, configureConnections(server.Config, &tc.cfg))
+		server.StartTLS()
+
+		resp, err := server.Client().Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		server.Close()
+
+		require.Equal(t, tc.expectedProto, resp.Proto, tc.cfg)
+	}
+}
+
+func TestConfigureConnectionsValidation(t *testing.T) {
+	require.Error(t, configureConnections(&http.Server{}, &Config{MaxHeaderBytes: -1}))
+	require.Error(t, configureConnections(&http.Server{}, &Config{HTTP2Disabled: true, HTTP2MaxStreams: 10}))
+
+	// HTTP/2 requires an AES-128-GCM cipher suite
+	err := configureConnections(&http.Server{TLSConfig: &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+	}}, &Config{HTTP2MaxStreams: 10})
+	require.Error(t, err)
+
+	httpServer := &http.Server{}
+	require.NoError(t, configureConnections(httpServer, &Config{MaxHeaderBytes: 4096}))
+	require.Equal(t, 4096, httpServer.MaxHeaderBytes)
+
+	httpServer = &http.Server{}
+	require.NoError(t, configureConnections(httpServer, &Config{HTTP2Disabled: true}))
+	require.NotNil(t, httpServer.TLSNextProto)
+	require.Empty(t, httpServer.TLSNextProto)
+}
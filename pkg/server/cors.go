@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig is the cross-origin policy of the backend responses
+type CORSConfig struct {
+	// Disabled removes the CORS headers, only same-origin requests are allowed
+	Disabled         bool          `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	AllowedOrigins   []string      `yaml:"allowedOrigins,omitempty" json:"allowedOrigins,omitempty"`
+	AllowedMethods   []string      `yaml:"allowedMethods,omitempty" json:"allowedMethods,omitempty"`
+	AllowedHeaders   []string      `yaml:"allowedHeaders,omitempty" json:"allowedHeaders,omitempty"`
+	AllowCredentials bool          `yaml:"allowCredentials,omitempty" json:"allowCredentials,omitempty"`
+	MaxAge           time.Duration `yaml:"maxAge,omitempty" json:"maxAge,omitempty"`
+}
+
+var defaultCORSConfig = CORSConfig{
+	AllowedOrigins: []string{"*"},
+	AllowedMethods: []string{http.MethodGet, http.MethodPost},
+	AllowedHeaders: []string{"Authorization", "Content-Type", requestIDHeader},
+}
+
+// corsHeaderMiddleware sets the CORS headers of the allowed origins and
+// answers the preflight requests, it wraps the router so that preflight
+// requests are answered for the routes restricted to other methods
+func corsHeaderMiddleware(cors CORSConfig) func(next http.Handler) http.Handler {
+	allowedMethods := strings.Join(cors.AllowedMethods, ", ")
+	allowedHeaders := strings.Join(cors.AllowedHeaders, ", ")
+
+	return func(next http.Handler) http.Handler {
+		if cors.Disabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			headers := w.Header()
+			headers.Add("Vary", "Origin")
+
+			allowedOrigin, ok := cors.allowedOrigin(origin)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			headers.Set("Access-Control-Allow-Origin", allowedOrigin)
+			if cors.AllowCredentials {
+				headers.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// preflight request
+			headers.Set("Access-Control-Allow-Methods", allowedMethods)
+			if allowedHeaders != "" {
+				headers.Set("Access-Control-Allow-Headers", allowedHeaders)
+			}
+			if cors.MaxAge > 0 {
+				headers.Set("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for origin
+func (c CORSConfig) allowedOrigin(origin string) (string, bool) {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSHeaderMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name            string
+		config          string
+		method          string
+		origin          string
+		requestMethod   string
+		expectedStatus  int
+		expectedOrigin  string
+		expectedMethods string
+		expectedMaxAge  string
+	}{
+		{
+			name:           "default wildcard",
+			method:         http.MethodGet,
+			origin:         "https://console.example.com",
+			expectedStatus: http.StatusOK,
+			expectedOrigin: "*",
+		},
+		{
+			name:           "disabled",
+			config:         "cors:\n  disabled: true\n",
+			method:         http.MethodGet,
+			origin:         "https://console.example.com",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "origin not allowed",
+			config:         "cors:\n  allowedOrigins: [https://console.example.com]\n",
+			method:         http.MethodGet,
+			origin:         "https://other.example.com",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "preflight",
+			config:          "cors:\n  allowedOrigins: [https://console.example.com]\n  allowedMethods: [GET]\n  maxAge: 10m\n",
+			method:          http.MethodOptions,
+			origin:          "https://console.example.com",
+			requestMethod:   http.MethodGet,
+			expectedStatus:  http.StatusNoContent,
+			expectedOrigin:  "https://console.example.com",
+			expectedMethods: "GET",
+			expectedMaxAge:  "600",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pluginConfig, err := parsePluginConfig([]byte(tc.config))
+			require.NoError(t, err)
+
+			r := httptest.NewRequest(tc.method, "/config", nil)
+			r.Header.Set("Origin", tc.origin)
+			if tc.requestMethod != "" {
+				r.Header.Set("Access-Control-Request-Method", tc.requestMethod)
+			}
+
+			w := httptest.NewRecorder()
+			corsHeaderMiddleware(pluginConfig.CORS)(next).ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code)
+			require.Equal(t, tc.expectedOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			require.Equal(t, tc.expectedMethods, w.Header().Get("Access-Control-Allow-Methods"))
+			require.Equal(t, tc.expectedMaxAge, w.Header().Get("Access-Control-Max-Age"))
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/openshift/logging-view-plugin/pkg/datasource"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+	"golang.org/x/net/http/httpproxy"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultDatasourceName is the name of the datasource defined by lokiURL
+	defaultDatasourceName = "default"
+	// eventStreamRouteName is the path segment of the tail streams sent as
+	// server-sent events, /api/tail/sse/<tenant>, it cannot name a datasource
+	eventStreamRouteName = "sse"
+	// datasourceTypeKubernetes serves the logs of the pods from the API
+	// server, when no Loki is installed
+	datasourceTypeKubernetes = "kubernetes"
+)
+
+var datasourceNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// DatasourceConfig is a named Loki backend, its queries are proxied at
+// /api/proxy/<name>/<tenant>/loki/api/v1/<endpoint>. The elasticsearch
+// datasources serve the same endpoints from Elasticsearch, the kubernetes
+// datasources serve the pod logs at /api/pods/<name>/<namespace>/<pod>/log
+// instead
+type DatasourceConfig struct {
+	Name string `yaml:"name" json:"name"`
+	// Type is loki, elasticsearch or kubernetes, loki by default
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+	// URL is the Loki or LokiStack gateway URL, the Elasticsearch URL, or
+	// the API server URL of the kubernetes datasources, the in-cluster one by
+	// default
+	URL               string `yaml:"url" json:"url"`
+	UseTenantInHeader bool   `yaml:"useTenantInHeader,omitempty" json:"useTenantInHeader,omitempty"`
+	// TenantRoutingMode sends the tenant in the gateway path, in the
+	// X-Scope-OrgID header or not at all: path, header or none.
+	// useTenantInHeader selects the header or path mode when unset
+	TenantRoutingMode string `yaml:"tenantRoutingMode,omitempty" json:"tenantRoutingMode,omitempty"`
+	// CAFile is the PEM bundle verifying the Loki certificate, the system
+	// roots are used when unset
+	CAFile string `yaml:"caFile,omitempty" json:"caFile,omitempty"`
+	// ClientCertFile and ClientKeyFile authenticate the plugin to the
+	// gateways requiring mutual TLS
+	ClientCertFile string `yaml:"clientCertFile,omitempty" json:"clientCertFile,omitempty"`
+	ClientKeyFile  string `yaml:"clientKeyFile,omitempty" json:"clientKeyFile,omitempty"`
+	// InsecureSkipVerify disables the verification of the Loki certificate,
+	// for testing only
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty"`
+	// NoProxy connects to the datasource directly, without the proxy of
+	// proxyURL or of the HTTP_PROXY and HTTPS_PROXY variables
+	NoProxy bool `yaml:"noProxy,omitempty" json:"noProxy,omitempty"`
+	// Default also serves the datasource at /api/proxy/<tenant>, like lokiURL
+	Default bool `yaml:"default,omitempty" json:"default,omitempty"`
+	// Elasticsearch maps the queries of the elasticsearch datasources
+	Elasticsearch ElasticsearchConfig `yaml:"elasticsearch,omitempty" json:"elasticsearch,omitempty"`
+	// Federation lists the clusters of the federated datasources
+	Federation FederationConfig `yaml:"federation,omitempty" json:"federation,omitempty"`
+	// Options are the settings of the datasources of the other registered
+	// types, decoded by their factory
+	Options map[string]interface{} `yaml:"options,omitempty" json:"options,omitempty"`
+}
+
+// allDatasources returns the configured datasources, lokiURL is the default
+// datasource when set
+func (c *PluginConfig) allDatasources() []DatasourceConfig {
+	datasources := make([]DatasourceConfig, 0, len(c.Datasources)+1)
+	if c.LokiURL != "" {
+		datasources = append(datasources, DatasourceConfig{
+			Name:               defaultDatasourceName,
+			URL:                c.LokiURL,
+			UseTenantInHeader:  c.UseTenantInHeader,
+			TenantRoutingMode:  c.TenantRoutingMode,
+			CAFile:             c.LokiCAFile,
+			ClientCertFile:     c.LokiClientCertFile,
+			ClientKeyFile:      c.LokiClientKeyFile,
+			InsecureSkipVerify: c.InsecureSkipVerify,
+			Default:            true,
+		})
+	}
+	return append(datasources, c.Datasources...)
+}
+
+// decodeOptions decodes the options of the datasource in v
+func (ds DatasourceConfig) decodeOptions(v interface{}) error {
+	if len(ds.Options) == 0 {
+		return nil
+	}
+	data, err := yaml.Marshal(ds.Options)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid options of datasource %s: %w", ds.Name, err)
+	}
+	return nil
+}
+
+// datasourceTypes returns the types of the datasources in order, the
+// registered ones and the ones served by the server itself
+func datasourceTypes() []string {
+	types := append(datasource.Types(), datasourceTypeElasticsearch, datasourceTypeFederated, datasourceTypeKubernetes)
+	sort.Strings(types)
+	return types
+}
+
+// isLoki returns true when the queries of the datasource are proxied to Loki
+func (ds DatasourceConfig) isLoki() bool {
+	return ds.Type == "" || ds.Type == datasource.TypeLoki
+}
+
+// defaultDatasource returns the datasource served at /api/proxy/<tenant>
+func (c *PluginConfig) defaultDatasource() (DatasourceConfig, bool) {
+	for _, ds := range c.allDatasources() {
+		if ds.Default {
+			return ds, true
+		}
+	}
+	return DatasourceConfig{}, false
+}
+
+func (c *PluginConfig) validateDatasources() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+
+	names := map[string]bool{}
+	defaults := 0
+	if c.LokiURL != "" {
+		names[defaultDatasourceName] = true
+		defaults++
+	}
+	errs = append(errs, validateTenantRoutingMode("tenantRoutingMode", c.TenantRoutingMode, c.UseTenantInHeader)...)
+	if (c.LokiClientCertFile == "") != (c.LokiClientKeyFile == "") {
+		errs = append(errs, ConfigValidationError{Field: "lokiClientCertFile", Message: "lokiClientCertFile and lokiClientKeyFile must be set together"})
+	}
+
+	for i, ds := range c.Datasources {
+		field := fmt.Sprintf("datasources[%d]", i)
+		if !datasourceNameRegexp.MatchString(ds.Name) {
+			errs = append(errs, ConfigValidationError{Field: field + ".name", Message: fmt.Sprintf("invalid name %q, lowercase alphanumeric characters and - are expected", ds.Name)})
+		} else if ds.Name == eventStreamRouteName {
+			errs = append(errs, ConfigValidationError{Field: field + ".name", Message: fmt.Sprintf("name %q is reserved for the server-sent events tail route", ds.Name)})
+		} else if names[ds.Name] {
+			errs = append(errs, ConfigValidationError{Field: field + ".name", Message: fmt.Sprintf("duplicate name %q", ds.Name)})
+		}
+		names[ds.Name] = true
+
+		switch {
+		case ds.Type == datasourceTypeElasticsearch:
+			errs = append(errs, ds.Elasticsearch.validate(field+".elasticsearch")...)
+		case ds.Type == datasourceTypeFederated:
+			errs = append(errs, ds.Federation.validate(field+".federation", c.allDatasources())...)
+		case ds.Type == "", ds.Type == datasourceTypeKubernetes, datasource.Registered(ds.Type):
+		default:
+			types := datasourceTypes()
+			expected := strings.Join(types[:len(types)-1], ", ") + " or " + types[len(types)-1]
+			errs = append(errs, ConfigValidationError{Field: field + ".type", Message: fmt.Sprintf("unknown type %q, %s is expected", ds.Type, expected)})
+		}
+
+		// the kubernetes datasources use the in-cluster API server by default,
+		// the federated datasources query the URLs of their clusters
+		if ds.Type == datasourceTypeFederated {
+			if ds.URL != "" {
+				errs = append(errs, ConfigValidationError{Field: field + ".url", Message: "a federated datasource has no URL, its clusters are queried"})
+			}
+		} else if ds.URL != "" || ds.Type != datasourceTypeKubernetes {
+			if u, err := url.Parse(ds.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, ConfigValidationError{Field: field + ".url", Message: fmt.Sprintf("invalid URL %q, an absolute http or https URL is expected", ds.URL)})
+			}
+		}
+
+		if (ds.ClientCertFile == "") != (ds.ClientKeyFile == "") {
+			errs = append(errs, ConfigValidationError{Field: field + ".clientCertFile", Message: "clientCertFile and clientKeyFile must be set together"})
+		}
+
+		errs = append(errs, validateTenantRoutingMode(field+".tenantRoutingMode", ds.TenantRoutingMode, ds.UseTenantInHeader)...)
+
+		if ds.Default {
+			if !ds.isLoki() {
+				errs = append(errs, ConfigValidationError{Field: field + ".default", Message: fmt.Sprintf("a %s datasource cannot be the default datasource, only the Loki ones can", ds.Type)})
+			}
+			defaults++
+		}
+	}
+
+	if defaults > 1 {
+		errs = append(errs, ConfigValidationError{Field: "datasources", Message: "only one default datasource can be set, lokiURL is the default datasource when set"})
+	}
+
+	return errs
+}
+
+// validateTenantRoutingMode checks the tenant routing mode of a datasource,
+// useTenantInHeader can only be combined with the header mode
+func validateTenantRoutingMode(field string, mode string, useTenantInHeader bool) ConfigValidationErrors {
+	switch mode {
+	case "":
+		return nil
+	case proxy.TenantRoutingPath, proxy.TenantRoutingHeader, proxy.TenantRoutingNone:
+	default:
+		return ConfigValidationErrors{{Field: field, Message: fmt.Sprintf("unknown tenant routing mode %q, header, path or none is expected", mode)}}
+	}
+	if useTenantInHeader && mode != proxy.TenantRoutingHeader {
+		return ConfigValidationErrors{{Field: field, Message: fmt.Sprintf("the %s mode conflicts with useTenantInHeader", mode)}}
+	}
+	return nil
+}
+
+// upstreamTLS is the TLS configuration of the requests sent to a backend
+type upstreamTLS struct {
+	CAFile             string
+	ClientCertFile     string
+	ClientKeyFile      string
+	InsecureSkipVerify bool
+}
+
+// datasourceTransport returns the dedicated transport of the requests sent
+// to ds, through proxyURL when set, with the pool settings of connections.
+// Its dials are counted in the metrics of the datasource
+func datasourceTransport(ds DatasourceConfig, proxyURL string, connections UpstreamConnectionsConfig) (http.RoundTripper, error) {
+	transport, err := newUpstreamTransport(upstreamTLS{
+		CAFile:             ds.CAFile,
+		ClientCertFile:     ds.ClientCertFile,
+		ClientKeyFile:      ds.ClientKeyFile,
+		InsecureSkipVerify: ds.InsecureSkipVerify,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("datasource %s: %w", ds.Name, err)
+	}
+	transport.Proxy = upstreamProxy(proxyURL, ds.NoProxy)
+	connections.apply(transport)
+	proxy.ObserveDials(ds.Name, transport)
+	return transport, nil
+}
+
+// upstreamProxy returns the proxy of the requests sent to a datasource: none
+// with noProxy, proxyURL when set, the proxy of the HTTP_PROXY and
+// HTTPS_PROXY variables otherwise. The hosts of NO_PROXY are always reached
+// directly
+func upstreamProxy(proxyURL string, noProxy bool) func(*http.Request) (*url.URL, error) {
+	if noProxy {
+		return nil
+	}
+
+	// the variables are read when the transport is built
+	cfg := httpproxy.FromEnvironment()
+	if proxyURL != "" {
+		cfg.HTTPProxy, cfg.HTTPSProxy = proxyURL, proxyURL
+	}
+	proxyFunc := cfg.ProxyFunc()
+	return func(r *http.Request) (*url.URL, error) {
+		return proxyFunc(r.URL)
+	}
+}
+
+// caTransport returns a transport verifying the server certificates with the
+// PEM bundle of caFile, nil when caFile is empty
+func caTransport(caFile string) (http.RoundTripper, error) {
+	if caFile == "" {
+		return nil, nil
+	}
+
+	transport, err := newUpstreamTransport(upstreamTLS{CAFile: caFile})
+	if err != nil {
+		return nil, err
+	}
+	return transport, nil
+}
+
+// newUpstreamTransport returns a transport with the settings of the default
+// transport and the TLS configuration cfg, the system roots verify the server
+// certificates when cfg.CAFile is empty. The client certificate is reloaded
+// when its files change
+func newUpstreamTransport(cfg upstreamTLS) (*http.Transport, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		caData, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCertFile != "" {
+		cert, err := newReloadingCertificate(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.get()
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
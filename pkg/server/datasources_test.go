@@ -0,0 +1,353 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/datasource"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatasourceRoutes(t *testing.T) {
+	newLoki := func(name string, paths chan<- string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths <- name + " " + r.URL.Path
+			w.Write([]byte(`{"status":"success"}`))
+		}))
+	}
+
+	paths := make(chan string, 1)
+	applicationLoki := newLoki("application", paths)
+	defer applicationLoki.Close()
+	infrastructureLoki := newLoki("infrastructure", paths)
+	defer infrastructureLoki.Close()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf(`
+lokiURL: %s
+metadataCache:
+  disabled: true
+datasources:
+  - name: infra
+    url: %s
+    useTenantInHeader: true
+`, applicationLoki.URL, infrastructureLoki.URL)), 0600))
+
+	pluginConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{StaticPath: t.TempDir()}, pluginConfig, routeDeps{})
+
+	tests := []struct {
+		path         string
+		expectedPath string
+	}{
+		{path: "/api/proxy/application/loki/api/v1/labels", expectedPath: "application /api/logs/v1/application/loki/api/v1/labels"},
+		{path: "/api/proxy/default/application/loki/api/v1/labels", expectedPath: "application /api/logs/v1/application/loki/api/v1/labels"},
+		{path: "/api/proxy/infra/infrastructure/loki/api/v1/labels", expectedPath: "infrastructure /loki/api/v1/labels"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, tt.expectedPath, <-paths)
+		})
+	}
+}
+
+func TestDatasourceUnavailable(t *testing.T) {
+	handler := datasource.QueryHandler(newDatasource(DatasourceConfig{Name: "infra", URL: "https://loki.local", CAFile: "missing-ca.crt"}, &PluginConfig{}, routeDeps{}), writeProxyError)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/infrastructure/loki/api/v1/labels", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), "datasource infra is unavailable")
+}
+
+func TestDatasourceTransportTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	clientCert, clientKey := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	require.NoError(t, generateCertificate(t, serverCert, serverKey, "127.0.0.1"))
+	require.NoError(t, generateCertificate(t, clientCert, clientKey, "logging-view-plugin"))
+
+	cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	require.NoError(t, err)
+
+	loki := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	loki.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequireAnyClientCert}
+	loki.StartTLS()
+	defer loki.Close()
+
+	tests := []struct {
+		name        string
+		ds          DatasourceConfig
+		expectedErr string
+	}{
+		{
+			name: "CA and client certificate",
+			ds:   DatasourceConfig{CAFile: serverCert, ClientCertFile: clientCert, ClientKeyFile: clientKey},
+		},
+		{
+			name: "insecure skip verify",
+			ds:   DatasourceConfig{InsecureSkipVerify: true, ClientCertFile: clientCert, ClientKeyFile: clientKey},
+		},
+		{
+			name:        "system roots",
+			ds:          DatasourceConfig{ClientCertFile: clientCert, ClientKeyFile: clientKey},
+			expectedErr: "certificate",
+		},
+		{
+			name:        "no client certificate",
+			ds:          DatasourceConfig{CAFile: serverCert},
+			expectedErr: "tls",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.ds.Name = "infra"
+			transport, err := datasourceTransport(tt.ds, "", UpstreamConnectionsConfig{})
+			require.NoError(t, err)
+
+			resp, err := (&http.Client{Transport: transport}).Get(loki.URL)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestDatasourceTransportInvalidClientCertificate(t *testing.T) {
+	_, err := datasourceTransport(DatasourceConfig{Name: "infra", ClientCertFile: "missing.crt", ClientKeyFile: "missing.key"}, "", UpstreamConnectionsConfig{})
+	require.ErrorContains(t, err, "datasource infra: cannot load client certificate")
+}
+
+func TestDatasourceTransportProxy(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://env-proxy.example:3128")
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("NO_PROXY", "direct.example")
+
+	// the proxy receives the requests with the absolute URL of the datasource
+	proxied := ""
+	forwardProxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer forwardProxy.Close()
+
+	transport, err := datasourceTransport(DatasourceConfig{Name: "infra"}, forwardProxy.URL, UpstreamConnectionsConfig{})
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: transport}).Get("http://loki.example:3100/ready")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "http://loki.example:3100/ready", proxied)
+
+	proxyOf := func(proxyFunc func(*http.Request) (*url.URL, error), target string) string {
+		if proxyFunc == nil {
+			return ""
+		}
+		u, err := proxyFunc(httptest.NewRequest(http.MethodGet, target, nil))
+		require.NoError(t, err)
+		if u == nil {
+			return ""
+		}
+		return u.String()
+	}
+
+	require.Equal(t, "http://env-proxy.example:3128", proxyOf(upstreamProxy("", false), "http://loki.example:3100"))
+	require.Equal(t, "http://proxy.example:8080", proxyOf(upstreamProxy("http://proxy.example:8080", false), "http://loki.example:3100"))
+	require.Equal(t, "", proxyOf(upstreamProxy("http://proxy.example:8080", false), "http://direct.example:3100"))
+	require.Equal(t, "", proxyOf(upstreamProxy("http://proxy.example:8080", true), "http://loki.example:3100"))
+
+	_, err = parsePluginConfig([]byte("proxyURL: proxy.example:8080"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "proxyURL", Message: `invalid URL "proxy.example:8080", an absolute http, https or socks5 URL is expected`},
+	}, err)
+}
+
+func TestValidateDatasources(t *testing.T) {
+	_, err := parsePluginConfig([]byte(`
+lokiURL: https://loki.local
+lokiClientCertFile: /etc/tls/client.crt
+datasources:
+  - name: Infra
+    url: https://infra.local
+    clientKeyFile: /etc/tls/client.key
+  - name: audit
+    url: loki.local
+    default: true
+  - name: audit
+    url: https://audit.local
+  - name: sse
+    url: https://sse.local
+`))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "lokiClientCertFile", Message: "lokiClientCertFile and lokiClientKeyFile must be set together"},
+		{Field: "datasources[0].name", Message: `invalid name "Infra", lowercase alphanumeric characters and - are expected`},
+		{Field: "datasources[0].clientCertFile", Message: "clientCertFile and clientKeyFile must be set together"},
+		{Field: "datasources[1].url", Message: `invalid URL "loki.local", an absolute http or https URL is expected`},
+		{Field: "datasources[2].name", Message: `duplicate name "audit"`},
+		{Field: "datasources[3].name", Message: `name "sse" is reserved for the server-sent events tail route`},
+		{Field: "datasources", Message: "only one default datasource can be set, lokiURL is the default datasource when set"},
+	}, err)
+}
+
+func TestTenantRoutingMode(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(`
+lokiURL: https://loki.local
+tenantRoutingMode: none
+datasources:
+  - name: infra
+    url: https://infra.local
+    tenantRoutingMode: header
+`))
+	require.NoError(t, err)
+	datasources := pluginConfig.allDatasources()
+	require.Equal(t, "none", datasources[0].TenantRoutingMode)
+	require.Equal(t, "header", datasources[1].TenantRoutingMode)
+
+	_, err = parsePluginConfig([]byte(`
+lokiURL: https://loki.local
+tenantRoutingMode: query
+datasources:
+  - name: infra
+    url: https://infra.local
+    useTenantInHeader: true
+    tenantRoutingMode: path
+`))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "tenantRoutingMode", Message: `unknown tenant routing mode "query", header, path or none is expected`},
+		{Field: "datasources[0].tenantRoutingMode", Message: "the path mode conflicts with useTenantInHeader"},
+	}, err)
+}
+
+func TestValidateKubernetesDatasources(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(`
+datasources:
+  - name: pods
+    type: kubernetes
+`))
+	require.NoError(t, err)
+	require.False(t, pluginConfig.Datasources[0].isLoki())
+
+	_, err = parsePluginConfig([]byte(`
+datasources:
+  - name: pods
+    type: kubernetes
+    url: api.local
+    default: true
+  - name: es
+    type: elastic
+    url: https://es.local
+`))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "datasources[0].url", Message: `invalid URL "api.local", an absolute http or https URL is expected`},
+		{Field: "datasources[0].default", Message: "a kubernetes datasource cannot be the default datasource, only the Loki ones can"},
+		{Field: "datasources[1].type", Message: `unknown type "elastic", elasticsearch, federated, kubernetes or loki is expected`},
+	}, err)
+}
+
+func TestElasticsearchDatasource(t *testing.T) {
+	_, err := parsePluginConfig([]byte(`
+datasources:
+  - name: legacy
+    type: elasticsearch
+    url: https://elasticsearch.openshift-logging.svc:9200
+    elasticsearch:
+      indices:
+        "app/logs": app-*
+      pollInterval: -1s
+`))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "datasources[0].elasticsearch.indices", Message: `invalid tenant "app/logs"`},
+		{Field: "datasources[0].elasticsearch.pollInterval", Message: "pollInterval cannot be negative"},
+	}, err)
+
+	mapping := ElasticsearchConfig{Indices: map[string]string{"application": "logs-*"}}.mapping()
+	require.Equal(t, "logs-*", mapping.Indices["application"])
+	require.Equal(t, defaultElasticsearchConfig.Fields, mapping.Fields)
+	require.Equal(t, "@timestamp", mapping.TimestampField)
+
+	elasticsearch := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"hits":{"hits":[]}}`))
+	}))
+	defer elasticsearch.Close()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf(`
+datasources:
+  - name: legacy
+    type: elasticsearch
+    url: %s
+`, elasticsearch.URL)), 0600))
+
+	pluginConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{StaticPath: t.TempDir()}, pluginConfig, routeDeps{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/proxy/legacy/application/loki/api/v1/query_range?query=%7Blevel%3D%22error%22%7D", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.JSONEq(t, `{"status":"success","data":{"resultType":"streams","result":[],"stats":{}}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/proxy/legacy/application/loki/api/v1/query_range?query="+url.QueryEscape(`rate({level="error"}[5m])`), nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "UnsupportedQuery")
+}
+
+func TestFederatedDatasource(t *testing.T) {
+	_, err := parsePluginConfig([]byte(`
+datasources:
+  - name: east
+    url: https://east.local
+  - name: pods
+    type: kubernetes
+  - name: fleet
+    type: federated
+    url: https://fleet.local
+    federation:
+      clusters: [east, east, pods, north]
+      clusterLabel: cluster-name
+  - name: empty
+    type: federated
+`))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "datasources[2].federation.clusters[1]", Message: `duplicate cluster "east"`},
+		{Field: "datasources[2].federation.clusters[2]", Message: "datasource pods is not a Loki datasource"},
+		{Field: "datasources[2].federation.clusters[3]", Message: `unknown datasource "north"`},
+		{Field: "datasources[2].federation.clusterLabel", Message: `invalid label name "cluster-name"`},
+		{Field: "datasources[2].url", Message: "a federated datasource has no URL, its clusters are queried"},
+		{Field: "datasources[3].federation.clusters", Message: "at least one cluster is required"},
+	}, err)
+
+	pluginConfig, err := parsePluginConfig([]byte(`
+datasources:
+  - name: east
+    url: https://east.local
+  - name: west
+    url: https://west.local
+  - name: fleet
+    type: federated
+    federation:
+      clusters: [east, west]
+`))
+	require.NoError(t, err)
+	_, isFederated := newDatasource(pluginConfig.Datasources[2], pluginConfig, routeDeps{}).(*datasource.Federated)
+	require.True(t, isFederated)
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+)
+
+const (
+	// devCacheControl disables the caching of every response in dev mode
+	devCacheControl = "no-store"
+	// devWatchInterval is the interval the static path is checked for changes
+	devWatchInterval = time.Second
+)
+
+// devMiddleware disables the caching of the responses: the validators are
+// removed so the browsers always load the current files
+func devMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+
+		noCache := func(headers http.Header) {
+			headers.Set("Cache-Control", devCacheControl)
+			headers.Del("ETag")
+			headers.Del("Last-Modified")
+		}
+
+		written := false
+		next.ServeHTTP(httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					if !written {
+						written = true
+						noCache(w.Header())
+					}
+					next(code)
+				}
+			},
+			Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					if !written {
+						written = true
+						noCache(w.Header())
+					}
+					return next(b)
+				}
+			},
+		}), r)
+	})
+}
+
+// newDevServerProxy returns the proxy to the webpack dev server serving the
+// assets missing from the static path
+func newDevServerProxy(rawURL string) (http.Handler, error) {
+	devServerURL, err := url.Parse(rawURL)
+	if err != nil || devServerURL.Scheme == "" || devServerURL.Host == "" {
+		return nil, fmt.Errorf("invalid dev server URL %q", rawURL)
+	}
+
+	devServer := httputil.NewSingleHostReverseProxy(devServerURL)
+	devServer.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		requestLog(slog, r).WithError(err).Warn("cannot proxy request to the dev server")
+		writeError(w, r, http.StatusBadGateway, errorCodeUpstreamUnavailable, "cannot reach the dev server", err.Error())
+	}
+	return devServer, nil
+}
+
+// staticFileState identifies a version of a static file
+type staticFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchStaticPath calls onChange with the files of dir added, changed or
+// removed since the previous check, every interval until stop is closed
+func watchStaticPath(dir string, interval time.Duration, stop <-chan struct{}, onChange func(changed []string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := staticPathState(dir)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current := staticPathState(dir)
+		if changed := changedStaticFiles(previous, current); len(changed) > 0 {
+			onChange(changed)
+		}
+		previous = current
+	}
+}
+
+// staticPathState returns the state of the files of dir by relative path, the
+// unreadable entries are skipped
+func staticPathState(dir string) map[string]staticFileState {
+	state := map[string]staticFileState{}
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		state[filepath.ToSlash(name)] = staticFileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return state
+}
+
+func changedStaticFiles(previous map[string]staticFileState, current map[string]staticFileState) []string {
+	changed := []string{}
+	for name, state := range current {
+		if before, found := previous[name]; !found || !before.modTime.Equal(state.modTime) || before.size != state.size {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previous {
+		if _, found := current[name]; !found {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevMiddleware(t *testing.T) {
+	staticDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "plugin-entry.0123456789abcdef.min.js"), []byte("entry"), 0600))
+
+	handler := devMiddleware(newFilesHandler(staticDir, "", ""))
+
+	r := httptest.NewRequest(http.MethodGet, "/plugin-entry.0123456789abcdef.min.js", nil)
+	r.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "entry", w.Body.String())
+	require.Equal(t, devCacheControl, w.Header().Get("Cache-Control"))
+	require.Empty(t, w.Header().Get("ETag"))
+	require.Empty(t, w.Header().Get("Last-Modified"))
+}
+
+func TestFilesHandlerDevServer(t *testing.T) {
+	staticDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "plugin-entry.js"), []byte("built"), 0600))
+
+	devServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("dev server " + r.URL.Path))
+	}))
+	defer devServer.Close()
+
+	proxy, err := newDevServerProxy(devServer.URL)
+	require.NoError(t, err)
+	handler := newFilesHandler(staticDir, "", "").withDevServer(proxy)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plugin-entry.js", nil))
+	require.Equal(t, "built", w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/main.hot-update.js", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "dev server /main.hot-update.js", w.Body.String())
+
+	_, err = newDevServerProxy("localhost:9003")
+	require.Error(t, err)
+}
+
+func TestWatchStaticPath(t *testing.T) {
+	staticDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "removed.js"), []byte("removed"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "changed.js"), []byte("v1"), 0600))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	changes := make(chan []string, 10)
+	go watchStaticPath(staticDir, 10*time.Millisecond, stop, func(changed []string) {
+		changes <- changed
+	})
+	// let the watcher take its first snapshot
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, os.Remove(filepath.Join(staticDir, "removed.js")))
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "changed.js"), []byte("v2 longer"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(staticDir, "assets"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "assets", "added.svg"), []byte("svg"), 0600))
+
+	seen := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(seen) < 3 {
+		select {
+		case changed := <-changes:
+			for _, name := range changed {
+				seen[name] = true
+			}
+		case <-timeout:
+			t.Fatalf("changes not reported, got %v", seen)
+		}
+	}
+
+	names := []string{}
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	require.Equal(t, []string{"assets/added.svg", "changed.js", "removed.js"}, names)
+}
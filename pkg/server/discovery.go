@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+const (
+	// lokiDiscoveryTimeout bounds the probe of a discovery candidate
+	lokiDiscoveryTimeout = 2 * time.Second
+	// serviceCAFile is the OpenShift service CA injected in the service
+	// account volume, it verifies the LokiStack gateways
+	serviceCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+	// podNamespaceFile is the namespace of the plugin pod
+	podNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// lokiGateway is a well-known Loki gateway service probed when no datasource
+// is configured
+type lokiGateway struct {
+	service string
+	scheme  string
+	port    string
+	// routing is the tenant routing mode of the gateway
+	routing string
+}
+
+// lokiGateways are the gateways of the LokiStack of the logging operator
+// and of the community Loki chart, by preference
+var lokiGateways = []lokiGateway{
+	{service: "logging-loki-gateway-http", scheme: "https", port: "8080", routing: proxy.TenantRoutingPath},
+	{service: "loki-gateway", scheme: "http", port: "80", routing: proxy.TenantRoutingHeader},
+}
+
+// lokiDiscoveryNamespaces are the namespaces the gateways are usually
+// installed in, after the namespace of the plugin
+var lokiDiscoveryNamespaces = []string{"openshift-logging", "loki", "logging", "monitoring"}
+
+// lokiDiscoveryCandidates returns the datasources of the well-known gateways
+// in the namespace of the plugin, when known, and the usual namespaces, by
+// preference. The LokiStack gateways are verified with the service CA when
+// it is mounted
+func lokiDiscoveryCandidates(podNamespace string, caFile string) []DatasourceConfig {
+	namespaces := []string{}
+	if podNamespace != "" {
+		namespaces = append(namespaces, podNamespace)
+	}
+	for _, namespace := range lokiDiscoveryNamespaces {
+		if namespace != podNamespace {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	candidates := []DatasourceConfig{}
+	for _, gateway := range lokiGateways {
+		for _, namespace := range namespaces {
+			u := url.URL{Scheme: gateway.scheme, Host: net.JoinHostPort(gateway.service+"."+namespace+".svc", gateway.port)}
+			candidate := DatasourceConfig{Name: defaultDatasourceName, URL: u.String(), TenantRoutingMode: gateway.routing, Default: true}
+			if gateway.scheme == "https" {
+				candidate.CAFile = caFile
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// podNamespace returns the namespace of the plugin pod, empty outside a
+// cluster
+func podNamespace() string {
+	data, err := os.ReadFile(podNamespaceFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// serviceCA returns the service CA file when it is mounted
+func serviceCA() string {
+	if _, err := os.Stat(serviceCAFile); err != nil {
+		return ""
+	}
+	return serviceCAFile
+}
+
+// discoverLoki connects to the candidates concurrently and returns the first
+// reachable one by preference, false when none is reachable. A resolved and
+// listening service is reachable, the TLS settings and the clock of the
+// chosen one are checked by the self-test
+func discoverLoki(ctx context.Context, candidates []DatasourceConfig, dial func(ctx context.Context, network string, address string) (net.Conn, error)) (DatasourceConfig, bool) {
+	ctx, cancel := context.WithTimeout(ctx, lokiDiscoveryTimeout)
+	defer cancel()
+
+	reachable := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i, candidate := range candidates {
+		u, err := url.Parse(candidate.URL)
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			conn, err := dial(ctx, "tcp", address)
+			if err != nil {
+				return
+			}
+			conn.Close()
+			reachable[i] = true
+		}(i, u.Host)
+	}
+	wg.Wait()
+
+	for i, candidate := range candidates {
+		if reachable[i] {
+			return candidate, true
+		}
+	}
+	return DatasourceConfig{}, false
+}
+
+// useDiscovered sets the datasource of the loaded and the next configs
+// without any to ds, until the restart
+func (c *reloadingPluginConfig) useDiscovered(ds DatasourceConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.discovered = &ds
+	c.set(c.config)
+}
+
+// discoveredDatasource returns the discovered datasource when the loaded
+// config uses it
+func (c *reloadingPluginConfig) discoveredDatasource() (DatasourceConfig, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.discoveryApplied {
+		return DatasourceConfig{}, false
+	}
+	return *c.discovered, true
+}
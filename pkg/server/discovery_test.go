@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLokiDiscoveryCandidates(t *testing.T) {
+	candidates := lokiDiscoveryCandidates("openshift-logging", serviceCAFile)
+	require.Len(t, candidates, 2*len(lokiDiscoveryNamespaces))
+	require.Equal(t, DatasourceConfig{Name: defaultDatasourceName, URL: "https://logging-loki-gateway-http.openshift-logging.svc:8080", TenantRoutingMode: proxy.TenantRoutingPath, CAFile: serviceCAFile, Default: true}, candidates[0])
+	require.Equal(t, "https://logging-loki-gateway-http.loki.svc:8080", candidates[1].URL)
+	require.Equal(t, DatasourceConfig{Name: defaultDatasourceName, URL: "http://loki-gateway.openshift-logging.svc:80", TenantRoutingMode: proxy.TenantRoutingHeader, Default: true}, candidates[len(lokiDiscoveryNamespaces)])
+
+	// the namespace of the plugin is probed first
+	require.Equal(t, "https://logging-loki-gateway-http.my-logging.svc:8080", lokiDiscoveryCandidates("my-logging", "")[0].URL)
+}
+
+func TestDiscoverLoki(t *testing.T) {
+	candidates := lokiDiscoveryCandidates("", "")
+	dial := func(reachable ...string) func(context.Context, string, string) (net.Conn, error) {
+		return func(_ context.Context, _ string, address string) (net.Conn, error) {
+			for _, host := range reachable {
+				if address == host {
+					client, server := net.Pipe()
+					server.Close()
+					return client, nil
+				}
+			}
+			return nil, errors.New("no such host")
+		}
+	}
+
+	// the preferred reachable candidate wins
+	ds, ok := discoverLoki(context.Background(), candidates, dial("loki-gateway.loki.svc:80", "logging-loki-gateway-http.logging.svc:8080"))
+	require.True(t, ok)
+	require.Equal(t, "https://logging-loki-gateway-http.logging.svc:8080", ds.URL)
+
+	_, ok = discoverLoki(context.Background(), candidates, dial())
+	require.False(t, ok)
+}
+
+func TestReloadingPluginConfigDiscovered(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0600))
+		require.NoError(t, os.Chtimes(configFile, modTime, modTime))
+	}
+	now := time.Now()
+	writeConfig("logsLimit: 50\n", now)
+	reloadingConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+
+	reloadingConfig.useDiscovered(DatasourceConfig{Name: defaultDatasourceName, URL: "https://logging-loki-gateway-http.openshift-logging.svc:8080", TenantRoutingMode: proxy.TenantRoutingPath, CAFile: serviceCAFile, Default: true})
+	ds, ok := reloadingConfig.get().defaultDatasource()
+	require.True(t, ok)
+	require.Equal(t, "https://logging-loki-gateway-http.openshift-logging.svc:8080", ds.URL)
+	require.Equal(t, serviceCAFile, ds.CAFile)
+	require.Equal(t, 50, reloadingConfig.get().LogsLimit)
+
+	// the runtime changes keep it
+	reloadingConfig.overrideFeatures(map[string]bool{"dev-console": true})
+	_, ok = reloadingConfig.discoveredDatasource()
+	require.True(t, ok)
+
+	report := newSelfTester(reloadingConfig).test(context.Background())
+	require.Contains(t, report.Checks[0].Message, "the discovered Loki gateway")
+
+	// the configured datasource replaces it
+	writeConfig("lokiURL: https://loki.local\n", now.Add(time.Minute))
+	_, err = reloadingConfig.reload()
+	require.NoError(t, err)
+	require.Equal(t, "https://loki.local", reloadingConfig.get().LokiURL)
+	_, ok = reloadingConfig.discoveredDatasource()
+	require.False(t, ok)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration read from a Go duration string like "30s" or
+// "1m", or from a number of seconds. It is served as a duration string
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalYAML accepts a duration string or a number of seconds
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: a duration like 30s or a number of seconds is expected", value.Line)
+	}
+
+	switch value.ShortTag() {
+	case "!!int", "!!float":
+		seconds, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid number of seconds %q", value.Line, value.Value)
+		}
+		d.Duration = time.Duration(seconds * float64(time.Second))
+	default:
+		duration, err := time.ParseDuration(value.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid duration %q, a duration like 30s or a number of seconds is expected", value.Line, value.Value)
+		}
+		d.Duration = duration
+	}
+
+	return nil
+}
+
+// MarshalYAML returns the duration string
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// MarshalJSON returns the duration string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts a duration string or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		d.Duration = time.Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("a duration like 30s or a number of seconds is expected")
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, a duration like 30s or a number of seconds is expected", value)
+	}
+	d.Duration = duration
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name             string
+		config           string
+		expectedTimeout  time.Duration
+		expectedErrorMsg string
+	}{
+		{name: "duration string", config: "timeout: 30s", expectedTimeout: 30 * time.Second},
+		{name: "minutes", config: "timeout: 1m", expectedTimeout: time.Minute},
+		{name: "compound duration", config: "timeout: 1m30s", expectedTimeout: 90 * time.Second},
+		{name: "integer seconds", config: "timeout: 45", expectedTimeout: 45 * time.Second},
+		{name: "fractional seconds", config: "timeout: 1.5", expectedTimeout: 1500 * time.Millisecond},
+		{name: "quoted duration", config: `timeout: "2m"`, expectedTimeout: 2 * time.Minute},
+		{name: "invalid string", config: "timeout: soon", expectedErrorMsg: `line 1: invalid duration "soon", a duration like 30s or a number of seconds is expected`},
+		{name: "list", config: "timeout: [30s]", expectedErrorMsg: "line 1: a duration like 30s or a number of seconds is expected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pluginConfig, err := parsePluginConfig([]byte(tt.config))
+			if tt.expectedErrorMsg != "" {
+				require.EqualError(t, err, tt.expectedErrorMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTimeout, pluginConfig.Timeout.Duration)
+		})
+	}
+}
+
+func TestDurationJSONRoundTrip(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte("timeout: 90"))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(pluginConfig)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"timeout":"1m30s"`)
+
+	decoded := PluginConfig{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, 90*time.Second, decoded.Timeout.Duration)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"timeout":30}`), &decoded))
+	require.Equal(t, 30*time.Second, decoded.Timeout.Duration)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// datasourceTypeElasticsearch translates the Loki queries to the legacy
+// Elasticsearch log store
+const datasourceTypeElasticsearch = "elasticsearch"
+
+// ElasticsearchConfig maps the tenants and the stream labels of an
+// elasticsearch datasource to the indices and the document fields of the log
+// store, the defaults follow the OpenShift Logging data model
+type ElasticsearchConfig struct {
+	Indices        map[string]string `yaml:"indices,omitempty" json:"indices,omitempty"`
+	Fields         map[string]string `yaml:"fields,omitempty" json:"fields,omitempty"`
+	MessageField   string            `yaml:"messageField,omitempty" json:"messageField,omitempty"`
+	TimestampField string            `yaml:"timestampField,omitempty" json:"timestampField,omitempty"`
+	// PollInterval is the time between two searches of the live tail streams
+	PollInterval time.Duration `yaml:"pollInterval,omitempty" json:"pollInterval,omitempty"`
+}
+
+var defaultElasticsearchConfig = ElasticsearchConfig{
+	Indices: map[string]string{
+		"application":    "app-*",
+		"infrastructure": "infra-*",
+		"audit":          "audit-*",
+	},
+	Fields: map[string]string{
+		"kubernetes_namespace_name": "kubernetes.namespace_name",
+		"kubernetes_pod_name":       "kubernetes.pod_name",
+		"kubernetes_container_name": "kubernetes.container_name",
+		"kubernetes_host":           "hostname",
+		"log_type":                  "log_type",
+		"level":                     "level",
+	},
+	MessageField:   "message",
+	TimestampField: "@timestamp",
+	PollInterval:   2 * time.Second,
+}
+
+// mapping returns the proxy mapping of the config, the unset settings have
+// their default
+func (c ElasticsearchConfig) mapping() proxy.ElasticsearchMapping {
+	mapping := proxy.ElasticsearchMapping{
+		Indices:        c.Indices,
+		Fields:         c.Fields,
+		MessageField:   c.MessageField,
+		TimestampField: c.TimestampField,
+		PollInterval:   c.PollInterval,
+	}
+	if len(mapping.Indices) == 0 {
+		mapping.Indices = defaultElasticsearchConfig.Indices
+	}
+	if len(mapping.Fields) == 0 {
+		mapping.Fields = defaultElasticsearchConfig.Fields
+	}
+	if mapping.MessageField == "" {
+		mapping.MessageField = defaultElasticsearchConfig.MessageField
+	}
+	if mapping.TimestampField == "" {
+		mapping.TimestampField = defaultElasticsearchConfig.TimestampField
+	}
+	if mapping.PollInterval == 0 {
+		mapping.PollInterval = defaultElasticsearchConfig.PollInterval
+	}
+	return mapping
+}
+
+func (c ElasticsearchConfig) validate(field string) ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	for tenant := range c.Indices {
+		if !tenantRegexp.MatchString(tenant) {
+			errs = append(errs, ConfigValidationError{Field: field + ".indices", Message: fmt.Sprintf("invalid tenant %q", tenant)})
+		}
+	}
+	if c.PollInterval < 0 {
+		errs = append(errs, ConfigValidationError{Field: field + ".pollInterval", Message: "pollInterval cannot be negative"})
+	}
+	return errs
+}
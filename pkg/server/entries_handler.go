@@ -0,0 +1,173 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// entriesResponse is a page of the entries of a log query, from the newest to
+// the oldest. Start and end are the nanosecond Unix epochs of the range, sent
+// back with the cursor to read the next page, there is none without cursor
+type entriesResponse struct {
+	Start   string          `json:"start"`
+	End     string          `json:"end"`
+	Entries []exportedEntry `json:"entries"`
+	Cursor  string          `json:"cursor,omitempty"`
+}
+
+// entriesCursor is where a page stopped: the entries older than End, and the
+// entries of the nanosecond before End that are not Seen, are left to read.
+// Digest binds it to the tenant, query and start of the first page
+type entriesCursor struct {
+	End    int64    `json:"e"`
+	Seen   []string `json:"s,omitempty"`
+	Digest string   `json:"d"`
+}
+
+// entriesDigest identifies the paginated read of query against tenant from
+// start
+func entriesDigest(tenant string, query string, start time.Time) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{tenant, query, strconv.FormatInt(start.UnixNano(), 10)}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c entriesCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeEntriesCursor(value string) (entriesCursor, error) {
+	cursor := entriesCursor{}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
+
+// entriesHandler executes the log query of the query parameter against the
+// tenant of the `/<tenant>` path and serves a page of limit entries from the
+// newest to the oldest, with the cursor of the next page when there are more.
+// The range is the one of the exports, the last hour by default. The next
+// pages are read with the same query, start and end, and the cursor: they
+// resume where the previous page stopped instead of querying its range again
+func entriesHandler(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps) http.Handler {
+	proxyConfig, err := lokiProxyConfig(ds, pluginConfig, deps)
+	if err != nil {
+		return unavailableDatasourceHandler(ds, err)
+	}
+	client := proxy.NewClient(proxyConfig)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := strings.Trim(r.URL.Path, "/")
+		if !tenantRegexp.MatchString(tenant) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid tenant %q", tenant), nil)
+			return
+		}
+
+		params := r.URL.Query()
+		query := params.Get("query")
+		parsed, err := logql.Parse(query)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid query", err.Error())
+			return
+		}
+		if parsed.Metric {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "only log queries can be paginated", nil)
+			return
+		}
+
+		req, err := exportRange(params.Get("start"), params.Get("end"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, err.Error(), nil)
+			return
+		}
+		req.Query = query
+		response := entriesResponse{
+			Start:   strconv.FormatInt(req.Start.UnixNano(), 10),
+			End:     strconv.FormatInt(req.End.UnixNano(), 10),
+			Entries: []exportedEntry{},
+		}
+
+		// the limit is validated by queryParamsMiddleware and bounded by the
+		// logsLimit guardrail
+		limit := lokiDefaultLimit
+		if value := params.Get("limit"); value != "" {
+			limit, _ = strconv.Atoi(value)
+		}
+		if limit > maxExportPageSize {
+			limit = maxExportPageSize
+		}
+
+		digest := entriesDigest(tenant, query, req.Start)
+		if value := params.Get("cursor"); value != "" {
+			cursor, err := decodeEntriesCursor(value)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid cursor", err.Error())
+				return
+			}
+			end := time.Unix(0, cursor.End)
+			if cursor.Digest != digest || !end.After(req.Start) || end.After(req.End) {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "the cursor does not belong to the query, its start and its end", nil)
+				return
+			}
+			req.End = end
+			req.Seen = cursor.Seen
+		}
+
+		// one more entry than the page tells whether there is a next one
+		req.PageSize = limit + 1
+		entries := []proxy.Entry{}
+		more := false
+		err = client.Entries(r, tenant, req, func(entry proxy.Entry) bool {
+			if len(entries) == limit {
+				more = true
+				return false
+			}
+			entries = append(entries, entry)
+			return true
+		})
+		if err != nil {
+			var proxyErr *proxy.Error
+			if !errors.As(err, &proxyErr) {
+				proxyErr = &proxy.Error{Status: http.StatusBadGateway, Code: errorCodeUpstreamUnavailable, Message: "cannot query the logs", Err: err}
+			}
+			writeProxyError(w, r, proxyErr)
+			return
+		}
+
+		for _, entry := range entries {
+			response.Entries = append(response.Entries, exportedEntry{Timestamp: entry.Timestamp.UTC().Format(time.RFC3339Nano), Labels: entry.Labels, Line: entry.Line})
+		}
+		if more {
+			// the next page skips the entries of the last timestamp already
+			// read, with the ones of the previous pages when it is the same
+			last := entries[len(entries)-1].Timestamp
+			cursor := entriesCursor{End: last.UnixNano() + 1, Digest: digest}
+			if last.Equal(req.End.Add(-time.Nanosecond)) {
+				cursor.Seen = append(cursor.Seen, req.Seen...)
+			}
+			for _, entry := range entries {
+				if entry.Timestamp.Equal(last) {
+					cursor.Seen = append(cursor.Seen, proxy.EntryID(entry))
+				}
+			}
+			response.Cursor = cursor.encode()
+		}
+
+		w.Header().Set("Cache-Control", "private, no-store")
+		writeJSON(w, r, http.StatusOK, response)
+	})
+}
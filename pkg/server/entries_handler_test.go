@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntriesHandler(t *testing.T) {
+	const base = int64(1700000000000000000)
+	timestamps := []int64{base + 10, base + 20, base + 20, base + 20, base + 30}
+	requests := []url.Values{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		requests = append(requests, params)
+		start, _ := strconv.ParseInt(params.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(params.Get("end"), 10, 64)
+		limit, _ := strconv.Atoi(params.Get("limit"))
+
+		values := [][2]string{}
+		for i, ts := range timestamps {
+			if ts >= start && ts < end {
+				values = append(values, [2]string{strconv.FormatInt(ts, 10), fmt.Sprintf("line %d", i)})
+			}
+		}
+		sort.SliceStable(values, func(i, j int) bool { return values[i][0] > values[j][0] })
+		if len(values) > limit {
+			values = values[:limit]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "streams",
+				"result":     []interface{}{map[string]interface{}{"stream": map[string]string{"app": "a"}, "values": values}},
+			},
+		})
+	}))
+	defer upstream.Close()
+
+	pluginConfig, err := parsePluginConfig([]byte("lokiURL: " + upstream.URL))
+	require.NoError(t, err)
+	ds, _ := pluginConfig.defaultDatasource()
+	handler := entriesHandler(ds, pluginConfig, routeDeps{})
+	page := func(params url.Values) (int, entriesResponse) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application?"+params.Encode(), nil))
+		response := entriesResponse{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response
+	}
+
+	// the pages resume where the previous one stopped, even in the middle of
+	// the entries of a timestamp
+	params := url.Values{"query": {`{app="a"}`}, "start": {strconv.FormatInt(base, 10)}, "end": {strconv.FormatInt(base+100, 10)}, "limit": {"2"}}
+	lines := []string{}
+	for pages := 1; ; pages++ {
+		status, response := page(params)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, params.Get("start"), response.Start)
+		for _, entry := range response.Entries {
+			lines = append(lines, entry.Line)
+		}
+		if response.Cursor == "" {
+			require.Equal(t, 3, pages)
+			break
+		}
+		params.Set("cursor", response.Cursor)
+	}
+	require.Equal(t, []string{"line 4", "line 1", "line 2", "line 3", "line 0"}, lines)
+
+	// the second page is not queried again from the end of the range
+	require.Equal(t, strconv.FormatInt(base+21, 10), requests[1].Get("end"))
+
+	// the cursor of another query or range is rejected
+	for _, override := range []url.Values{
+		{"query": {`{app="b"}`}},
+		{"start": {strconv.FormatInt(base+1, 10)}},
+		{"cursor": {"not a cursor"}},
+	} {
+		rejected := url.Values{}
+		for name, values := range params {
+			rejected[name] = values
+		}
+		for name, values := range override {
+			rejected[name] = values
+		}
+		status, _ := page(rejected)
+		require.Equal(t, http.StatusBadRequest, status, override.Encode())
+	}
+}
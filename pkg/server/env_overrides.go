@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// envOverridePrefix prefixes the environment variables overriding the plugin
+// config fields, like LOGGING_VIEW_PLUGIN_TAIL_MAX_STREAMS for tail.maxStreams
+const envOverridePrefix = "LOGGING_VIEW_PLUGIN_"
+
+var (
+	durationType       = reflect.TypeOf(time.Duration(0))
+	configDurationType = reflect.TypeOf(Duration{})
+)
+
+// applyEnvOverrides sets the scalar and string list fields of c from the
+// environment variables named after their YAML path, the lists are comma
+// separated. The lists of rules cannot be overridden and the fields tagged
+// env:"-" are skipped
+func applyEnvOverrides(c *PluginConfig, lookupEnv func(string) (string, bool)) ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	applyEnvOverridesTo(reflect.ValueOf(c).Elem(), "", lookupEnv, &errs)
+	return errs
+}
+
+func applyEnvOverridesTo(v reflect.Value, fieldPath string, lookupEnv func(string) (string, bool), errs *ConfigValidationErrors) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || field.Tag.Get("env") == "-" {
+			continue
+		}
+
+		path := name
+		if fieldPath != "" {
+			path = fieldPath + "." + name
+		}
+		value := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct && field.Type != configDurationType {
+			applyEnvOverridesTo(value, path, lookupEnv, errs)
+			continue
+		}
+
+		envName := envOverrideName(path)
+		envValue, ok := lookupEnv(envName)
+		if !ok {
+			continue
+		}
+
+		if err := setFromEnv(value, strings.TrimSpace(envValue)); err != nil {
+			*errs = append(*errs, ConfigValidationError{Field: path, Message: fmt.Sprintf("invalid %s value %q: %s", envName, envValue, err)})
+		}
+	}
+}
+
+func setFromEnv(v reflect.Value, envValue string) error {
+	switch {
+	case v.Type() == configDurationType:
+		duration, err := parseEnvDuration(envValue)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(Duration{duration}))
+	case v.Type() == durationType:
+		duration, err := parseEnvDuration(envValue)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(duration))
+	case v.Kind() == reflect.String:
+		v.SetString(envValue)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(envValue)
+		if err != nil {
+			return fmt.Errorf("a boolean is expected")
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(envValue)
+		if err != nil {
+			return fmt.Errorf("an integer is expected")
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		items := []string{}
+		for _, item := range strings.Split(envValue, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("the field cannot be set from the environment")
+	}
+	return nil
+}
+
+// parseEnvDuration accepts a duration string or a number of seconds, like the
+// durations of the config file
+func parseEnvDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("a duration like 30s or a number of seconds is expected")
+	}
+	return duration, nil
+}
+
+// envOverrideName returns the environment variable of a YAML field path, the
+// camel case words are separated with underscores
+func envOverrideName(fieldPath string) string {
+	var b strings.Builder
+	b.WriteString(envOverridePrefix)
+
+	for _, part := range strings.Split(fieldPath, ".") {
+		if b.Len() > len(envOverridePrefix) {
+			b.WriteByte('_')
+		}
+		runes := []rune(part)
+		for i, r := range runes {
+			if i > 0 && unicode.IsUpper(r) {
+				previousLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if previousLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvOverrideName(t *testing.T) {
+	require.Equal(t, "LOGGING_VIEW_PLUGIN_LOKI_URL", envOverrideName("lokiURL"))
+	require.Equal(t, "LOGGING_VIEW_PLUGIN_USE_TENANT_IN_HEADER", envOverrideName("useTenantInHeader"))
+	require.Equal(t, "LOGGING_VIEW_PLUGIN_TAIL_MAX_STREAMS", envOverrideName("tail.maxStreams"))
+	require.Equal(t, "LOGGING_VIEW_PLUGIN_CORS_ALLOWED_ORIGINS", envOverrideName("cors.allowedOrigins"))
+}
+
+func TestParsePluginConfigWithEnv(t *testing.T) {
+	env := map[string]string{
+		"LOGGING_VIEW_PLUGIN_LOKI_URL":                "https://loki.example.com",
+		"LOGGING_VIEW_PLUGIN_TIMEOUT":                 "45",
+		"LOGGING_VIEW_PLUGIN_LOGS_LIMIT":              "200",
+		"LOGGING_VIEW_PLUGIN_TAIL_MAX_DURATION":       "10m",
+		"LOGGING_VIEW_PLUGIN_CORS_ALLOWED_ORIGINS":    "https://a.example.com, https://b.example.com",
+		"LOGGING_VIEW_PLUGIN_AUTHORIZATION_ENABLED":   "true",
+		"LOGGING_VIEW_PLUGIN_COMPRESSION_DISABLED":    "1",
+		"LOGGING_VIEW_PLUGIN_USE_TENANT_IN_HEADER":    "false",
+		"LOGGING_VIEW_PLUGIN_UNRELATED_SETTING_VALUE": "ignored",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	pluginConfig, err := parsePluginConfigWithEnv([]byte("lokiURL: https://loki.local\ntimeout: 30s\nuseTenantInHeader: true\ntail:\n  maxStreams: 5"), lookupEnv)
+	require.NoError(t, err)
+
+	require.Equal(t, "https://loki.example.com", pluginConfig.LokiURL)
+	require.Equal(t, 45*time.Second, pluginConfig.Timeout.Duration)
+	require.Equal(t, 200, pluginConfig.LogsLimit)
+	require.False(t, pluginConfig.UseTenantInHeader)
+	require.Equal(t, 5, pluginConfig.Tail.MaxStreams)
+	require.Equal(t, 10*time.Minute, pluginConfig.Tail.MaxDuration)
+	require.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, pluginConfig.CORS.AllowedOrigins)
+	require.True(t, pluginConfig.Authorization.Enabled)
+	require.True(t, pluginConfig.Compression.Disabled)
+}
+
+func TestParsePluginConfigWithInvalidEnv(t *testing.T) {
+	env := map[string]string{
+		"LOGGING_VIEW_PLUGIN_TAIL_MAX_STREAMS": "many",
+		"LOGGING_VIEW_PLUGIN_CACHE_CONTROL":    "no-cache",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	_, err := parsePluginConfigWithEnv(nil, lookupEnv)
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "tail.maxStreams", Message: `invalid LOGGING_VIEW_PLUGIN_TAIL_MAX_STREAMS value "many": an integer is expected`},
+		{Field: "cacheControl", Message: `invalid LOGGING_VIEW_PLUGIN_CACHE_CONTROL value "no-cache": the field cannot be set from the environment`},
+	}, err)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// error codes returned in the error responses, the frontend relies on them to
+// render the error states
+const (
+	errorCodeInvalidRequest  = "InvalidRequest"
+	errorCodeUnprocessable   = "Unprocessable"
+	errorCodePayloadTooLarge = "PayloadTooLarge"
+	errorCodeInternal        = "InternalError"
+	errorCodeInjectedFault   = "InjectedFault"
+	errorCodeUnauthorized    = "Unauthorized"
+	errorCodeForbidden       = "Forbidden"
+	errorCodeUnavailable     = "Unavailable"
+	errorCodeConflict        = "Conflict"
+	errorCodeTimeout         = "Timeout"
+	errorCodeTooManyRequests = "TooManyRequests"
+	// errorCodeOutsideRetention rejects the queries whose range ends before
+	// the retention of their tenant
+	errorCodeOutsideRetention = "OutsideRetention"
+	// the codes of the proxy errors
+	errorCodeNotFound            = "NotFound"
+	errorCodeMethodNotAllowed    = "MethodNotAllowed"
+	errorCodeUpstreamUnavailable = "UpstreamUnavailable"
+)
+
+// requestIDHeader is the header carrying the request ID echoed in errors
+const requestIDHeader = "X-Request-Id"
+
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// writeError replies to the request with a JSON error envelope, or with the
+// plain text message when the client does not accept JSON, like curl
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string, message string, details interface{}) {
+	if !acceptsJSON(r) {
+		http.Error(w, message, status)
+		return
+	}
+
+	body, err := json.Marshal(errorResponse{Error: apiError{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: r.Header.Get(requestIDHeader),
+	}})
+	if err != nil {
+		requestLog(slog, r).WithError(err).Errorf("cannot marshal error response: %s", message)
+		http.Error(w, message, status)
+		return
+	}
+
+	headers := w.Header()
+	headers.Del("Content-Length")
+	headers.Set("Content-Type", "application/json")
+	headers.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// acceptsJSON tells whether the error of r is returned as JSON: when the
+// client does not send an Accept header or lists a JSON media type in it. The
+// wildcard alone sent by curl gets plain text
+func acceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+
+	for _, value := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		if mediaType == "application/json" || mediaType == "application/*" || strings.HasSuffix(mediaType, "+json") {
+			return true
+		}
+	}
+	return false
+}
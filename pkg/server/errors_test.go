@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name         string
+		accept       string
+		expectedJSON bool
+	}{
+		{name: "no accept header", expectedJSON: true},
+		{name: "json", accept: "application/json", expectedJSON: true},
+		{name: "json with parameters", accept: "text/plain, application/json; charset=utf-8", expectedJSON: true},
+		{name: "json suffix", accept: "application/problem+json", expectedJSON: true},
+		{name: "json refused", accept: "application/json;q=0, text/plain", expectedJSON: false},
+		{name: "curl", accept: "*/*", expectedJSON: false},
+		{name: "text", accept: "text/plain", expectedJSON: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/config", nil)
+			r.Header.Set(requestIDHeader, "abc-123")
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			w := httptest.NewRecorder()
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid query", "details")
+			require.Equal(t, http.StatusBadRequest, w.Code)
+
+			if !tt.expectedJSON {
+				require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+				require.Equal(t, "invalid query\n", w.Body.String())
+				return
+			}
+
+			require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			response := errorResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Equal(t, apiError{
+				Code:      errorCodeInvalidRequest,
+				Message:   "invalid query",
+				Details:   "details",
+				RequestID: "abc-123",
+			}, response.Error)
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+)
+
+const (
+	// featureEvents serves the Kubernetes events of the log streams at
+	// /api/events
+	featureEvents = "events"
+	// eventsWatchTimeout is the duration of a watch request, lower than the
+	// timeout of the kube client
+	eventsWatchTimeout = configMapWatchTimeout
+	// eventsWatchRetryInterval is waited before listing the events again
+	// after a failed list or watch
+	eventsWatchRetryInterval = configMapWatchRetryInterval
+	// maxEventsResponse bounds the events served for a namespace, the most
+	// recent are kept
+	maxEventsResponse = 500
+)
+
+// EventsConfig bounds the cache of the Kubernetes events served at
+// /api/events
+type EventsConfig struct {
+	// MaxAge drops the events last seen before, like the API server does
+	// after its event TTL
+	MaxAge Duration `yaml:"maxAge,omitempty" json:"maxAge,omitempty"`
+	// MaxEvents bounds the cached events, the oldest are dropped first
+	MaxEvents int `yaml:"maxEvents,omitempty" json:"maxEvents,omitempty"`
+}
+
+var defaultEventsConfig = EventsConfig{
+	MaxAge:    Duration{time.Hour},
+	MaxEvents: 10000,
+}
+
+func (c EventsConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.MaxAge.Duration < 0 {
+		errs = append(errs, ConfigValidationError{Field: "events.maxAge", Message: "maxAge cannot be negative"})
+	}
+	if c.MaxEvents < 0 {
+		errs = append(errs, ConfigValidationError{Field: "events.maxEvents", Message: "maxEvents cannot be negative"})
+	}
+	return errs
+}
+
+// eventWatcher lists and watches the events, implemented by kube.Client
+type eventWatcher interface {
+	ListEvents(ctx context.Context, namespace string) (*kube.EventList, error)
+	WatchEvents(ctx context.Context, namespace string, resourceVersion string, timeout time.Duration, onEvent func(kube.EventWatchEvent)) error
+}
+
+// eventsCache keeps the recent events of every namespace, listed then
+// watched with the plugin service account, so that the events of a log
+// stream are served without a request to the API server
+type eventsCache struct {
+	client    eventWatcher
+	maxAge    time.Duration
+	maxEvents int
+	now       func() time.Time
+
+	mu              sync.RWMutex
+	events          map[string]*kube.Event
+	resourceVersion string
+	synced          bool
+}
+
+func newEventsCache(client eventWatcher, cfg EventsConfig) *eventsCache {
+	return &eventsCache{
+		client:    client,
+		maxAge:    cfg.MaxAge.Duration,
+		maxEvents: cfg.MaxEvents,
+		now:       time.Now,
+		events:    map[string]*kube.Event{},
+	}
+}
+
+// run lists then watches the events until ctx is done. The watch is resumed
+// from the last seen resource version, the events are listed again once that
+// version expired
+func (c *eventsCache) run(ctx context.Context) {
+	listed := false
+	for ctx.Err() == nil {
+		var err error
+		if !listed {
+			err = c.list(ctx)
+			listed = err == nil
+		}
+		if err == nil {
+			c.mu.RLock()
+			resourceVersion := c.resourceVersion
+			c.mu.RUnlock()
+
+			err = c.client.WatchEvents(ctx, "", resourceVersion, eventsWatchTimeout, c.apply)
+			c.prune()
+			if err == nil || ctx.Err() != nil {
+				continue
+			}
+			if kube.IsGone(err) {
+				listed = false
+				continue
+			}
+		}
+
+		slog.WithError(err).Warnf("cannot watch the events, retrying in %s", eventsWatchRetryInterval)
+		select {
+		case <-time.After(eventsWatchRetryInterval):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// list replaces the cached events with the current ones
+func (c *eventsCache) list(ctx context.Context) error {
+	list, err := c.client.ListEvents(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	events := make(map[string]*kube.Event, len(list.Items))
+	for i := range list.Items {
+		event := &list.Items[i]
+		events[eventKey(event)] = event
+	}
+
+	c.mu.Lock()
+	c.events = events
+	c.resourceVersion = list.Metadata.ResourceVersion
+	c.synced = true
+	c.mu.Unlock()
+
+	c.prune()
+	return nil
+}
+
+func (c *eventsCache) apply(watchEvent kube.EventWatchEvent) {
+	event := watchEvent.Event
+
+	c.mu.Lock()
+	if event.Metadata.ResourceVersion != "" {
+		c.resourceVersion = event.Metadata.ResourceVersion
+	}
+	switch watchEvent.Type {
+	case kube.EventAdded, kube.EventModified:
+		c.events[eventKey(event)] = event
+	case kube.EventDeleted:
+		delete(c.events, eventKey(event))
+	}
+	full := c.maxEvents > 0 && len(c.events) > c.maxEvents
+	c.mu.Unlock()
+
+	if full {
+		c.prune()
+	}
+}
+
+// prune drops the events older than maxAge, then the oldest events beyond
+// maxEvents. A tenth of maxEvents is freed so that the next events are
+// added without pruning again
+func (c *eventsCache) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxAge > 0 {
+		oldest := c.now().Add(-c.maxAge)
+		for key, event := range c.events {
+			if event.LastSeen().Before(oldest) {
+				delete(c.events, key)
+			}
+		}
+	}
+
+	if c.maxEvents <= 0 || len(c.events) <= c.maxEvents {
+		return
+	}
+	keys := make([]string, 0, len(c.events))
+	for key := range c.events {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.events[keys[i]].LastSeen().Before(c.events[keys[j]].LastSeen())
+	})
+	for _, key := range keys[:len(keys)-c.maxEvents*9/10] {
+		delete(c.events, key)
+	}
+}
+
+// isSynced returns true once the events have been listed
+func (c *eventsCache) isSynced() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.synced
+}
+
+// forObject returns the recent events of the namespace, only the ones of the
+// object kind named name when name is set, the most recent first
+func (c *eventsCache) forObject(namespace string, kind string, name string) []kube.Event {
+	oldest := time.Time{}
+	if c.maxAge > 0 {
+		oldest = c.now().Add(-c.maxAge)
+	}
+
+	c.mu.RLock()
+	events := []kube.Event{}
+	for _, event := range c.events {
+		object := event.InvolvedObject
+		if object.Namespace != namespace || (name != "" && (object.Kind != kind || object.Name != name)) {
+			continue
+		}
+		if event.LastSeen().Before(oldest) {
+			continue
+		}
+		events = append(events, *event)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].LastSeen().After(events[j].LastSeen())
+	})
+	if len(events) > maxEventsResponse {
+		events = events[:maxEventsResponse]
+	}
+	return events
+}
+
+func eventKey(event *kube.Event) string {
+	return event.Metadata.Namespace + "/" + event.Metadata.Name
+}
+
+// eventResponse is an event of the resource of a log stream
+type eventResponse struct {
+	// Type is Normal or Warning
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Count   int32  `json:"count,omitempty"`
+	// FirstSeen is zero for the events reported with the events.k8s.io API
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	// Kind, Name and FieldPath are the object of the event, like a container
+	// of the pod
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	FieldPath string `json:"fieldPath,omitempty"`
+	Component string `json:"component,omitempty"`
+	Host      string `json:"host,omitempty"`
+}
+
+type eventsResponse struct {
+	Events []eventResponse `json:"events"`
+}
+
+// eventsHandler serves the recent events of the namespace and pod
+// parameters, the pod parameter is optional. The users must be allowed to
+// list the events of the namespace when authorizer is set
+func eventsHandler(cache *eventsCache, authorizer *authz.Authorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace, pod := r.URL.Query().Get("namespace"), r.URL.Query().Get("pod")
+		if !namespaceRegexp.MatchString(namespace) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid namespace %q", namespace), nil)
+			return
+		}
+		if pod != "" && !podNameRegexp.MatchString(pod) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid pod %q", pod), nil)
+			return
+		}
+
+		if authorizer != nil {
+			user, ok := requestUser(r)
+			if !ok {
+				writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "the request is not authenticated", nil)
+				return
+			}
+			allowed, err := authorizer.Authorize(r.Context(), user, namespace)
+			if err != nil {
+				requestLog(slog, r).WithError(err).Error("cannot review access")
+				writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "cannot authorize the request", nil)
+				return
+			}
+			if !allowed {
+				writeError(w, r, http.StatusForbidden, errorCodeForbidden, fmt.Sprintf("user %s cannot list the events of namespace %s", user.Username, namespace), nil)
+				return
+			}
+		}
+
+		if !cache.isSynced() {
+			writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "the events are not loaded yet", nil)
+			return
+		}
+
+		events := cache.forObject(namespace, "Pod", pod)
+		response := eventsResponse{Events: make([]eventResponse, 0, len(events))}
+		for i := range events {
+			event := &events[i]
+			response.Events = append(response.Events, eventResponse{
+				Type:      event.Type,
+				Reason:    event.Reason,
+				Message:   event.Message,
+				Count:     event.Count,
+				FirstSeen: event.FirstTimestamp,
+				LastSeen:  event.LastSeen(),
+				Kind:      event.InvolvedObject.Kind,
+				Name:      event.InvolvedObject.Name,
+				FieldPath: event.InvolvedObject.FieldPath,
+				Component: event.Source.Component,
+				Host:      event.Source.Host,
+			})
+		}
+
+		writeJSON(w, r, http.StatusOK, response)
+	}
+}
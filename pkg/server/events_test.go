@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEventWatcher serves list and replays its watch events once, the watch
+// then fails with a 410 Gone and the next watches wait for ctx
+type fakeEventWatcher struct {
+	mu       sync.Mutex
+	list     *kube.EventList
+	watch    []kube.EventWatchEvent
+	lists    int
+	versions []string
+	done     chan struct{}
+}
+
+func (f *fakeEventWatcher) ListEvents(_ context.Context, namespace string) (*kube.EventList, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lists++
+	return f.list, nil
+}
+
+func (f *fakeEventWatcher) WatchEvents(ctx context.Context, namespace string, resourceVersion string, _ time.Duration, onEvent func(kube.EventWatchEvent)) error {
+	f.mu.Lock()
+	f.versions = append(f.versions, resourceVersion)
+	events := f.watch
+	f.watch = nil
+	f.mu.Unlock()
+
+	if events == nil {
+		close(f.done)
+		<-ctx.Done()
+		return nil
+	}
+	for _, event := range events {
+		onEvent(event)
+	}
+	return &kube.StatusError{Code: 410, Reason: "Expired"}
+}
+
+func testEvent(name string, pod string, reason string, lastSeen time.Time) kube.Event {
+	return kube.Event{
+		Metadata:       kube.ObjectMeta{Name: name, Namespace: "shop", ResourceVersion: name},
+		InvolvedObject: kube.ObjectReference{Kind: "Pod", Namespace: "shop", Name: pod},
+		Reason:         reason,
+		Type:           "Warning",
+		LastTimestamp:  lastSeen,
+	}
+}
+
+func TestEventsCache(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	added := testEvent("3", "api", "Pulled", now.Add(-time.Minute))
+	watcher := &fakeEventWatcher{
+		list: &kube.EventList{Items: []kube.Event{
+			testEvent("1", "api", "BackOff", now.Add(-10*time.Minute)),
+			testEvent("2", "db", "Killing", now.Add(-5*time.Minute)),
+			testEvent("old", "api", "Scheduled", now.Add(-2*time.Hour)),
+		}},
+		watch: []kube.EventWatchEvent{
+			{Type: kube.EventAdded, Event: &added},
+			{Type: kube.EventDeleted, Event: &kube.Event{Metadata: kube.ObjectMeta{Name: "2", Namespace: "shop", ResourceVersion: "4"}}},
+		},
+		done: make(chan struct{}),
+	}
+	watcher.list.Metadata.ResourceVersion = "2"
+
+	cache := newEventsCache(watcher, defaultEventsConfig)
+	cache.now = func() time.Time { return now }
+	require.False(t, cache.isSynced())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.run(ctx)
+	<-watcher.done
+
+	require.True(t, cache.isSynced())
+	// the expired watch lists the events again, the watch resumes from the
+	// version of the list
+	watcher.mu.Lock()
+	require.Equal(t, 2, watcher.lists)
+	require.Equal(t, []string{"2", "2"}, watcher.versions)
+	watcher.mu.Unlock()
+
+	events := cache.forObject("shop", "Pod", "api")
+	require.Len(t, events, 1)
+	require.Equal(t, "BackOff", events[0].Reason)
+	require.Empty(t, cache.forObject("other", "Pod", "api"))
+
+	cache.apply(kube.EventWatchEvent{Type: kube.EventAdded, Event: &added})
+	events = cache.forObject("shop", "Pod", "")
+	require.Equal(t, []string{"Pulled", "Killing", "BackOff"}, []string{events[0].Reason, events[1].Reason, events[2].Reason})
+}
+
+func TestEventsCacheMaxEvents(t *testing.T) {
+	now := time.Now()
+	cache := newEventsCache(&fakeEventWatcher{}, EventsConfig{MaxEvents: 10})
+	for i := 0; i < 11; i++ {
+		event := testEvent(string(rune('a'+i)), "api", "BackOff", now.Add(time.Duration(i)*time.Second))
+		cache.apply(kube.EventWatchEvent{Type: kube.EventAdded, Event: &event})
+	}
+
+	// the oldest events are dropped down to 90% of maxEvents
+	events := cache.forObject("shop", "Pod", "api")
+	require.Len(t, events, 9)
+	require.Equal(t, "k", events[0].Metadata.Name)
+	require.Equal(t, "c", events[8].Metadata.Name)
+}
+
+func TestEventsHandler(t *testing.T) {
+	now := time.Now()
+	cache := newEventsCache(&fakeEventWatcher{list: &kube.EventList{}}, defaultEventsConfig)
+	authorizer := authz.New(&fakeAccessReviewer{allowedNamespaces: map[string]bool{"shop": true}}, kube.ResourceAttributes{Verb: "list", Resource: "events"})
+	handler := eventsHandler(cache, authorizer)
+
+	request := func(query string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/events?"+query, nil)
+		r = r.WithContext(context.WithValue(r.Context(), userKey{}, &kube.UserInfo{Username: "developer"}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusServiceUnavailable, request("namespace=shop").Code)
+
+	event := testEvent("1", "api", "BackOff", now)
+	event.InvolvedObject.FieldPath = "spec.containers{server}"
+	require.NoError(t, cache.list(context.Background()))
+	cache.apply(kube.EventWatchEvent{Type: kube.EventAdded, Event: &event})
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedEvents int
+	}{
+		{name: "pod", query: "namespace=shop&pod=api", expectedStatus: http.StatusOK, expectedEvents: 1},
+		{name: "other pod", query: "namespace=shop&pod=db", expectedStatus: http.StatusOK},
+		{name: "namespace", query: "namespace=shop", expectedStatus: http.StatusOK, expectedEvents: 1},
+		{name: "missing namespace", query: "pod=api", expectedStatus: http.StatusBadRequest},
+		{name: "invalid pod", query: "namespace=shop&pod=API", expectedStatus: http.StatusBadRequest},
+		{name: "denied namespace", query: "namespace=other&pod=api", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(tt.query)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			response := eventsResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Events, tt.expectedEvents)
+			if tt.expectedEvents > 0 {
+				require.Equal(t, "BackOff", response.Events[0].Reason)
+				require.Equal(t, "spec.containers{server}", response.Events[0].FieldPath)
+			}
+		})
+	}
+}
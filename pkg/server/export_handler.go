@@ -0,0 +1,314 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+	exportFormatText   = "text"
+	// defaultExportRange is the range exported when the request has no start
+	defaultExportRange = time.Hour
+	// maxExportPageSize is the default max_entries_limit_per_query of Loki
+	maxExportPageSize = 5000
+)
+
+// ExportConfig bounds the log exports served at /api/export
+type ExportConfig struct {
+	// MaxLines is the maximum number of exported log lines
+	MaxLines int `yaml:"maxLines,omitempty" json:"maxLines,omitempty"`
+	// MaxBytes is the maximum size of an export
+	MaxBytes int `yaml:"maxBytes,omitempty" json:"maxBytes,omitempty"`
+	// PageSize is the number of lines read from Loki per request
+	PageSize int `yaml:"pageSize,omitempty" json:"pageSize,omitempty"`
+	// ObjectStorage is the bucket of the export jobs served at
+	// /api/export-jobs
+	ObjectStorage ExportObjectStorageConfig `yaml:"objectStorage,omitempty" json:"objectStorage,omitempty"`
+}
+
+var defaultExportConfig = ExportConfig{
+	MaxLines: 100000,
+	MaxBytes: 100 << 20,
+	PageSize: 1000,
+}
+
+type exportedEntry struct {
+	Timestamp string            `json:"timestamp"`
+	Labels    map[string]string `json:"labels"`
+	Line      string            `json:"line"`
+}
+
+// exportHandler executes the log query of the query parameter against the
+// tenant of the `/<tenant>` path and streams its entries from the newest to
+// the oldest as CSV, NDJSON or text lines, the format parameter defaulting
+// to text for the clients accepting text/plain and to CSV otherwise. The
+// export stops at the line limit or at the
+// size limit, whichever comes first
+func exportHandler(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps) http.Handler {
+	proxyConfig, err := lokiProxyConfig(ds, pluginConfig, deps)
+	if err != nil {
+		return unavailableDatasourceHandler(ds, err)
+	}
+	client := proxy.NewClient(proxyConfig)
+	limits := pluginConfig.Export
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		export, exportErr := parseExportRequest(r, limits.MaxLines, limits.PageSize)
+		if exportErr != nil {
+			writeProxyError(w, r, exportErr)
+			return
+		}
+
+		e := &exporter{w: w, format: export.format, filename: exportFilename(export.tenant, export.format, export.entries.End)}
+		lines, _, err := exportEntries(r, client, export, e, int64(limits.MaxBytes))
+
+		if err != nil && !e.started {
+			var proxyErr *proxy.Error
+			if !errors.As(err, &proxyErr) {
+				proxyErr = &proxy.Error{Status: http.StatusBadGateway, Code: errorCodeUpstreamUnavailable, Message: "cannot export logs", Err: err}
+			}
+			writeProxyError(w, r, proxyErr)
+			return
+		}
+		if err != nil {
+			requestLog(slog, r).WithError(err).Warnf("export interrupted after %d lines", lines)
+			return
+		}
+
+		// an empty export still has the CSV header
+		e.start()
+		e.flush()
+	})
+}
+
+// exportRequest is the validated query, range and format of an export
+type exportRequest struct {
+	tenant   string
+	format   string
+	entries  proxy.EntriesRequest
+	maxLines int
+}
+
+// parseExportRequest validates the tenant of the `/<tenant>` path and the
+// query, start, end, format and limit parameters of an export, the limit
+// is bounded by maxLines
+func parseExportRequest(r *http.Request, maxLines int, pageSize int) (exportRequest, *proxy.Error) {
+	export := exportRequest{tenant: strings.Trim(r.URL.Path, "/"), maxLines: maxLines}
+	if !tenantRegexp.MatchString(export.tenant) {
+		return export, &proxy.Error{Status: http.StatusBadRequest, Code: errorCodeInvalidRequest, Message: fmt.Sprintf("invalid tenant %q", export.tenant)}
+	}
+
+	params := r.URL.Query()
+
+	export.format = params.Get("format")
+	if export.format == "" {
+		export.format = exportFormatCSV
+		if acceptsText(r) {
+			export.format = exportFormatText
+		}
+	}
+	if export.format != exportFormatCSV && export.format != exportFormatNDJSON && export.format != exportFormatText {
+		return export, &proxy.Error{Status: http.StatusBadRequest, Code: errorCodeInvalidRequest, Message: fmt.Sprintf("unsupported format %q, csv, ndjson or text is expected", export.format)}
+	}
+
+	query := params.Get("query")
+	parsed, err := logql.Parse(query)
+	if err != nil {
+		return export, &proxy.Error{Status: http.StatusBadRequest, Code: errorCodeInvalidRequest, Message: "invalid query", Err: err}
+	}
+	if parsed.Metric {
+		return export, &proxy.Error{Status: http.StatusBadRequest, Code: errorCodeInvalidRequest, Message: "only log queries can be exported"}
+	}
+
+	export.entries, err = exportRange(params.Get("start"), params.Get("end"))
+	if err != nil {
+		return export, &proxy.Error{Status: http.StatusBadRequest, Code: errorCodeInvalidRequest, Message: err.Error()}
+	}
+	export.entries.Query = query
+	export.entries.PageSize = pageSize
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return export, &proxy.Error{Status: http.StatusBadRequest, Code: errorCodeInvalidRequest, Message: fmt.Sprintf("invalid limit %q", limit)}
+		}
+		if n < export.maxLines {
+			export.maxLines = n
+		}
+	}
+	if export.maxLines < export.entries.PageSize {
+		export.entries.PageSize = export.maxLines
+	}
+	return export, nil
+}
+
+// exportEntries writes the entries of export with e until its line limit, or
+// before exceeding maxBytes, and returns the number of lines and bytes
+// written
+func exportEntries(r *http.Request, client *proxy.Client, export exportRequest, e *exporter, maxBytes int64) (int, int64, error) {
+	lines, size := 0, int64(0)
+	err := client.Entries(r, export.tenant, export.entries, func(entry proxy.Entry) bool {
+		record, err := e.record(entry)
+		if err != nil {
+			requestLog(slog, r).WithError(err).Error("cannot format exported entry")
+			return false
+		}
+		if size+int64(len(record)) > maxBytes {
+			return false
+		}
+		if err := e.write(record); err != nil {
+			// the client went away
+			return false
+		}
+		lines++
+		size += int64(len(record))
+		return lines < export.maxLines
+	})
+	return lines, size, err
+}
+
+// exportRange returns the range of an export, the last hour by default
+func exportRange(start string, end string) (proxy.EntriesRequest, error) {
+	req := proxy.EntriesRequest{End: time.Now()}
+
+	if end != "" {
+		t, err := proxy.ParseTime(end)
+		if err != nil {
+			return req, fmt.Errorf("invalid end %q", end)
+		}
+		req.End = t
+	}
+
+	req.Start = req.End.Add(-defaultExportRange)
+	if start != "" {
+		t, err := proxy.ParseTime(start)
+		if err != nil {
+			return req, fmt.Errorf("invalid start %q", start)
+		}
+		req.Start = t
+	}
+
+	if !req.Start.Before(req.End) {
+		return req, fmt.Errorf("start must be before end")
+	}
+
+	return req, nil
+}
+
+func exportFilename(tenant string, format string, end time.Time) string {
+	extension := format
+	if format == exportFormatText {
+		extension = "log"
+	}
+	return fmt.Sprintf("%s-logs-%s.%s", tenant, end.UTC().Format("20060102T150405Z"), extension)
+}
+
+// exporter writes the exported entries, the response headers are only sent
+// with the first entry so that the errors of the first Loki request can
+// still be reported
+type exporter struct {
+	w        http.ResponseWriter
+	format   string
+	filename string
+	started  bool
+	pending  int
+}
+
+func (e *exporter) start() {
+	if e.started {
+		return
+	}
+	e.started = true
+
+	e.w.Header().Set("Content-Type", exportContentType(e.format))
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename))
+	e.w.WriteHeader(http.StatusOK)
+
+	if e.format == exportFormatCSV {
+		e.w.Write([]byte("timestamp,labels,line\n"))
+	}
+}
+
+// exportContentType is the media type of the exports of format
+func exportContentType(format string) string {
+	switch format {
+	case exportFormatNDJSON:
+		return "application/x-ndjson"
+	case exportFormatText:
+		return textContentType
+	}
+	return "text/csv; charset=utf-8"
+}
+
+func (e *exporter) record(entry proxy.Entry) ([]byte, error) {
+	timestamp := entry.Timestamp.UTC().Format(time.RFC3339Nano)
+
+	if e.format == exportFormatText {
+		return []byte(textLogLine(entry)), nil
+	}
+
+	if e.format == exportFormatNDJSON {
+		record, err := json.Marshal(exportedEntry{Timestamp: timestamp, Labels: entry.Labels, Line: entry.Line})
+		if err != nil {
+			return nil, err
+		}
+		return append(record, '\n'), nil
+	}
+
+	var b bytes.Buffer
+	csvWriter := csv.NewWriter(&b)
+	if err := csvWriter.Write([]string{timestamp, formatLabels(entry.Labels), entry.Line}); err != nil {
+		return nil, err
+	}
+	csvWriter.Flush()
+	return b.Bytes(), csvWriter.Error()
+}
+
+func (e *exporter) write(record []byte) error {
+	e.start()
+	if _, err := e.w.Write(record); err != nil {
+		return err
+	}
+
+	// flush regularly so that the download progresses
+	e.pending++
+	if e.pending >= 100 {
+		e.flush()
+	}
+	return nil
+}
+
+func (e *exporter) flush() {
+	e.pending = 0
+	if flusher, ok := e.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// formatLabels formats labels like a stream selector with sorted names
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
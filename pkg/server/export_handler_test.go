@@ -0,0 +1,116 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testStreams = `{"status":"success","data":{"resultType":"streams","result":[
+{"stream":{"kubernetes_namespace_name":"my-app","app":"api"},"values":[["1700000000000000003","GET /orders 500"],["1700000000000000001","started"]]},
+{"stream":{"kubernetes_namespace_name":"my-app","app":"db"},"values":[["1700000000000000002","slow query, \"orders\""]]}]}}`
+
+func TestExportHandler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") == `{app="missing"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testStreams))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name                string
+		maxBytes            int
+		params              url.Values
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "csv",
+			params:              url.Values{"query": {`{app=~"api|db"}`}, "start": {"1699999999"}, "end": {"1700000001"}},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody: "timestamp,labels,line\n" +
+				`2023-11-14T22:13:20.000000003Z,"{app=""api"", kubernetes_namespace_name=""my-app""}",GET /orders 500` + "\n" +
+				`2023-11-14T22:13:20.000000002Z,"{app=""db"", kubernetes_namespace_name=""my-app""}","slow query, ""orders"""` + "\n" +
+				`2023-11-14T22:13:20.000000001Z,"{app=""api"", kubernetes_namespace_name=""my-app""}",started` + "\n",
+		},
+		{
+			name:                "ndjson with limit",
+			params:              url.Values{"query": {`{app=~"api|db"}`}, "format": {"ndjson"}, "limit": {"1"}, "start": {"1699999999"}, "end": {"1700000001"}},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody:        `{"timestamp":"2023-11-14T22:13:20.000000003Z","labels":{"app":"api","kubernetes_namespace_name":"my-app"},"line":"GET /orders 500"}` + "\n",
+		},
+		{
+			name:                "text",
+			params:              url.Values{"query": {`{app=~"api|db"}`}, "start": {"1699999999"}, "end": {"1700000001"}},
+			accept:              "text/plain",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody: "2023-11-14T22:13:20.000000003Z GET /orders 500\n" +
+				"2023-11-14T22:13:20.000000002Z slow query, \"orders\"\n" +
+				"2023-11-14T22:13:20.000000001Z started\n",
+		},
+		{
+			name:                "size limit",
+			maxBytes:            200,
+			params:              url.Values{"query": {`{app=~"api|db"}`}, "format": {"ndjson"}, "start": {"1699999999"}, "end": {"1700000001"}},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody:        `{"timestamp":"2023-11-14T22:13:20.000000003Z","labels":{"app":"api","kubernetes_namespace_name":"my-app"},"line":"GET /orders 500"}` + "\n",
+		},
+		{
+			name:           "metric query",
+			params:         url.Values{"query": {`count_over_time({app="api"}[5m])`}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid format",
+			params:         url.Values{"query": {`{app="api"}`}, "format": {"xml"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid range",
+			params:         url.Values{"query": {`{app="api"}`}, "start": {"1700000001"}, "end": {"1699999999"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "upstream error",
+			params:         url.Values{"query": {`{app="missing"}`}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pluginConfig, err := parsePluginConfig([]byte(fmt.Sprintf("lokiURL: %s\nexport:\n  maxBytes: %d", upstream.URL, tc.maxBytes)))
+			require.NoError(t, err)
+			ds, _ := pluginConfig.defaultDatasource()
+			handler := exportHandler(ds, pluginConfig, routeDeps{})
+
+			r := httptest.NewRequest(http.MethodGet, "/application?"+tc.params.Encode(), nil)
+			r.Header.Set("Accept", tc.accept)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+			require.Regexp(t, `^attachment; filename="application-logs-2023\d+T\d+Z\.(csv|ndjson|log)"$`, w.Header().Get("Content-Disposition"))
+			require.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/openshift/logging-view-plugin/pkg/objectstore"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+const (
+	exportJobRunning   = "running"
+	exportJobSucceeded = "succeeded"
+	exportJobFailed    = "failed"
+)
+
+// ExportObjectStorageConfig is the S3-compatible bucket the export jobs are
+// uploaded to, the jobs are disabled without bucket. The credentials are
+// read from files, like the keys of a mounted Secret, when each job starts
+type ExportObjectStorageConfig struct {
+	// Endpoint is the URL of the S3 API
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Bucket   string `yaml:"bucket,omitempty" json:"bucket,omitempty"`
+	Region   string `yaml:"region,omitempty" json:"region,omitempty"`
+	// PathStyle addresses the bucket in the path of the URLs, as most
+	// S3-compatible stores expect, instead of in their host
+	PathStyle bool `yaml:"pathStyle,omitempty" json:"pathStyle,omitempty"`
+	// Prefix is prepended to the keys of the exports
+	Prefix              string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	AccessKeyIDFile     string `yaml:"accessKeyIDFile,omitempty" json:"accessKeyIDFile,omitempty"`
+	SecretAccessKeyFile string `yaml:"secretAccessKeyFile,omitempty" json:"secretAccessKeyFile,omitempty"`
+	// URLExpiry is the validity of the presigned URLs of the exports, the
+	// jobs are forgotten once it expires
+	URLExpiry time.Duration `yaml:"urlExpiry,omitempty" json:"urlExpiry,omitempty"`
+	// MaxLines and MaxBytes bound each job like the streamed exports
+	MaxLines int   `yaml:"maxLines,omitempty" json:"maxLines,omitempty"`
+	MaxBytes int64 `yaml:"maxBytes,omitempty" json:"maxBytes,omitempty"`
+	// MaxJobs is the number of jobs running at the same time
+	MaxJobs int `yaml:"maxJobs,omitempty" json:"maxJobs,omitempty"`
+	// Timeout bounds each job
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+var defaultExportObjectStorageConfig = ExportObjectStorageConfig{
+	Region:    "us-east-1",
+	URLExpiry: time.Hour,
+	MaxLines:  10000000,
+	MaxBytes:  10 << 30,
+	MaxJobs:   2,
+	Timeout:   time.Hour,
+}
+
+func (c ExportObjectStorageConfig) enabled() bool {
+	return c.Bucket != ""
+}
+
+func (c ExportObjectStorageConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if !c.enabled() && c.Endpoint == "" {
+		return errs
+	}
+
+	if u, err := url.Parse(c.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, ConfigValidationError{Field: "export.objectStorage.endpoint", Message: fmt.Sprintf("invalid endpoint %q, an http or https URL is expected", c.Endpoint)})
+	}
+	if c.Bucket == "" {
+		errs = append(errs, ConfigValidationError{Field: "export.objectStorage.bucket", Message: "bucket is required"})
+	}
+	if c.AccessKeyIDFile == "" {
+		errs = append(errs, ConfigValidationError{Field: "export.objectStorage.accessKeyIDFile", Message: "accessKeyIDFile is required"})
+	}
+	if c.SecretAccessKeyFile == "" {
+		errs = append(errs, ConfigValidationError{Field: "export.objectStorage.secretAccessKeyFile", Message: "secretAccessKeyFile is required"})
+	}
+	if c.URLExpiry < 0 || c.URLExpiry > objectstore.MaxExpiry {
+		errs = append(errs, ConfigValidationError{Field: "export.objectStorage.urlExpiry", Message: fmt.Sprintf("urlExpiry must be between 0 and %s", objectstore.MaxExpiry)})
+	}
+	if c.MaxLines < 0 {
+		errs = append(errs, ConfigValidationError{Field: "export.objectStorage.maxLines", Message: "maxLines cannot be negative"})
+	}
+	if c.MaxBytes < 0 {
+		errs = append(errs, ConfigValidationError{Field: "export.objectStorage.maxBytes", Message: "maxBytes cannot be negative"})
+	}
+	if c.MaxJobs < 0 {
+		errs = append(errs, ConfigValidationError{Field: "export.objectStorage.maxJobs", Message: "maxJobs cannot be negative"})
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, ConfigValidationError{Field: "export.objectStorage.timeout", Message: "timeout cannot be negative"})
+	}
+	return errs
+}
+
+// client returns the client of the bucket with the credentials read from
+// the files
+func (c ExportObjectStorageConfig) client() (*objectstore.Client, error) {
+	accessKeyID, err := os.ReadFile(c.AccessKeyIDFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the access key ID: %w", err)
+	}
+	secretAccessKey, err := os.ReadFile(c.SecretAccessKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the secret access key: %w", err)
+	}
+	return objectstore.NewClient(objectstore.Config{
+		Endpoint:        c.Endpoint,
+		Bucket:          c.Bucket,
+		Region:          c.Region,
+		PathStyle:       c.PathStyle,
+		AccessKeyID:     strings.TrimSpace(string(accessKeyID)),
+		SecretAccessKey: strings.TrimSpace(string(secretAccessKey)),
+	})
+}
+
+// exportJob is an export uploaded to the object storage in the background,
+// the URL downloads it once the job succeeded
+type exportJob struct {
+	ID         string     `json:"id"`
+	Tenant     string     `json:"tenant"`
+	Format     string     `json:"format"`
+	Status     string     `json:"status"`
+	Lines      int        `json:"lines"`
+	Bytes      int64      `json:"bytes"`
+	URL        string     `json:"url,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	owner      string
+}
+
+// exportJobs are the jobs of the users, kept in memory until the URLs of
+// the finished jobs expire. The jobs are lost when the plugin restarts
+type exportJobs struct {
+	mu      sync.Mutex
+	jobs    map[string]*exportJob
+	running int
+	config  ExportObjectStorageConfig
+}
+
+func newExportJobs(config ExportObjectStorageConfig) *exportJobs {
+	return &exportJobs{jobs: map[string]*exportJob{}, config: config}
+}
+
+// start records a running job of owner, it returns false when MaxJobs jobs
+// are already running
+func (j *exportJobs) start(owner string, tenant string, format string) (*exportJob, bool, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, false, fmt.Errorf("cannot generate export job ID: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for key, job := range j.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > j.config.URLExpiry {
+			delete(j.jobs, key)
+		}
+	}
+	if j.running >= j.config.MaxJobs {
+		return nil, false, nil
+	}
+	j.running++
+	job := &exportJob{ID: hex.EncodeToString(id), Tenant: tenant, Format: format, Status: exportJobRunning, CreatedAt: now.UTC(), owner: owner}
+	j.jobs[job.ID] = job
+	return job, true, nil
+}
+
+// finish records the outcome of a running job
+func (j *exportJobs) finish(job *exportJob, lines int, size int64, link string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.running--
+	finishedAt := time.Now().UTC()
+	job.FinishedAt = &finishedAt
+	job.Lines, job.Bytes = lines, size
+	if err != nil {
+		job.Status, job.Error = exportJobFailed, err.Error()
+		return
+	}
+	job.Status, job.URL = exportJobSucceeded, link
+}
+
+// get returns a copy of the job of tenant and owner
+func (j *exportJobs) get(id string, tenant string, owner string) (exportJob, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok || job.Tenant != tenant || job.owner != owner {
+		return exportJob{}, false
+	}
+	return *job, true
+}
+
+// detachedContext keeps the values of a request context, like its user and
+// its request ID, without its deadline and its cancellation
+type detachedContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
+// uploadResponseWriter sends the records of an exporter to an upload
+// instead of a response
+type uploadResponseWriter struct {
+	header http.Header
+	w      io.Writer
+}
+
+func (w *uploadResponseWriter) Header() http.Header         { return w.header }
+func (w *uploadResponseWriter) Write(b []byte) (int, error) { return w.w.Write(b) }
+func (w *uploadResponseWriter) WriteHeader(int)             {}
+
+// exportJobsHandler starts a job exporting the entries of the log query of
+// the query parameter against the tenant of the `/<tenant>` path, with the
+// parameters of the streamed exports, and responds with the job. The job
+// uploads the export to the object storage with the identity of the
+// request, and presigns the URL downloading it once uploaded
+func exportJobsHandler(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps, jobs *exportJobs) http.Handler {
+	proxyConfig, err := lokiProxyConfig(ds, pluginConfig, deps)
+	if err != nil {
+		return unavailableDatasourceHandler(ds, err)
+	}
+	client := proxy.NewClient(proxyConfig)
+	limits := pluginConfig.Export
+	storage := limits.ObjectStorage
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		export, exportErr := parseExportRequest(r, storage.MaxLines, limits.PageSize)
+		if exportErr != nil {
+			writeProxyError(w, r, exportErr)
+			return
+		}
+
+		owner := ""
+		if user, ok := requestUser(r); ok {
+			owner = user.Username
+		}
+		job, started, err := jobs.start(owner, export.tenant, export.format)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot start the export job", err.Error())
+			return
+		}
+		if !started {
+			writeError(w, r, http.StatusTooManyRequests, errorCodeTooManyRequests, fmt.Sprintf("%d export jobs are already running", storage.MaxJobs), nil)
+			return
+		}
+		requestLog(slog, r).WithField("job", job.ID).Info("export job started")
+
+		// the job outlives the request, with its user and its token
+		ctx, cancel := context.WithTimeout(detachedContext{Context: context.Background(), values: r.Context()}, storage.Timeout)
+		jobReq := r.Clone(ctx)
+		jobReq.Body = http.NoBody
+		go func() {
+			defer cancel()
+			lines, size, link, err := runExportJob(jobReq, client, storage, export, job.ID)
+			if err != nil {
+				requestLog(slog, jobReq).WithField("job", job.ID).WithError(err).Warnf("export job failed after %d lines", lines)
+			} else {
+				requestLog(slog, jobReq).WithField("job", job.ID).Infof("export job uploaded %d lines", lines)
+			}
+			jobs.finish(job, lines, size, link, err)
+		}()
+
+		snapshot, _ := jobs.get(job.ID, job.Tenant, owner)
+		w.Header().Set("Location", fmt.Sprintf("/api/export-jobs/%s/%s", job.Tenant, job.ID))
+		w.Header().Set("Cache-Control", "private, no-store")
+		writeJSON(w, r, http.StatusAccepted, snapshot)
+	})
+}
+
+// runExportJob uploads the entries of export as the object
+// `<prefix><tenant>/<id>/<filename>` and returns its presigned URL, the
+// records are piped to the upload so that only a part of the export is
+// buffered
+func runExportJob(r *http.Request, client *proxy.Client, storage ExportObjectStorageConfig, export exportRequest, id string) (int, int64, string, error) {
+	store, err := storage.client()
+	if err != nil {
+		return 0, 0, "", err
+	}
+	filename := exportFilename(export.tenant, export.format, export.entries.End)
+	key := fmt.Sprintf("%s%s/%s/%s", storage.Prefix, export.tenant, id, filename)
+
+	pr, pw := io.Pipe()
+	uploaded := make(chan error, 1)
+	go func() {
+		err := store.Upload(r.Context(), key, exportContentType(export.format), pr)
+		// the exporter stops writing when the upload failed
+		pr.CloseWithError(err)
+		uploaded <- err
+	}()
+
+	e := &exporter{w: &uploadResponseWriter{header: http.Header{}, w: pw}, format: export.format, filename: filename}
+	lines, size, err := exportEntries(r, client, export, e, storage.MaxBytes)
+	if err == nil {
+		// an empty export still has the CSV header
+		e.start()
+	}
+	pw.CloseWithError(err)
+	uploadErr := <-uploaded
+	if err != nil {
+		return lines, size, "", err
+	}
+	if uploadErr != nil {
+		return lines, size, "", fmt.Errorf("cannot upload the export: %w", uploadErr)
+	}
+
+	link, err := store.PresignGet(key, storage.URLExpiry)
+	return lines, size, link, err
+}
+
+// exportJobHandler serves the export job of the `/{tenant}/{id}` path to
+// the user who started it
+func exportJobHandler(jobs *exportJobs) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		owner := ""
+		if user, ok := requestUser(r); ok {
+			owner = user.Username
+		}
+		job, ok := jobs.get(vars["id"], vars["tenant"], owner)
+		if !ok {
+			writeError(w, r, http.StatusNotFound, errorCodeNotFound, "export job not found", nil)
+			return
+		}
+		w.Header().Set("Cache-Control", "private, no-store")
+		writeJSON(w, r, http.StatusOK, job)
+	})
+}
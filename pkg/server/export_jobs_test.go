@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportJobs(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testStreams))
+	}))
+	defer upstream.Close()
+
+	var mu sync.Mutex
+	objects := map[string]string{}
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=access-key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		objects[r.URL.Path] = string(body)
+	}))
+	defer store.Close()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "access-key-id"), []byte("access-key\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret-access-key"), []byte("secret\n"), 0600))
+	pluginConfig, err := parsePluginConfig([]byte(`lokiURL: ` + upstream.URL + `
+export:
+  objectStorage:
+    endpoint: ` + store.URL + `
+    bucket: exports
+    pathStyle: true
+    prefix: logs/
+    accessKeyIDFile: ` + filepath.Join(dir, "access-key-id") + `
+    secretAccessKeyFile: ` + filepath.Join(dir, "secret-access-key") + `
+    maxJobs: 1
+`))
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, pluginConfig.Export.ObjectStorage.URLExpiry)
+	router := setupRoutes(&Config{StaticPath: t.TempDir()}, &reloadingPluginConfig{config: pluginConfig}, routeDeps{})
+
+	params := url.Values{"query": {`{app=~"api|db"}`}, "format": {"ndjson"}, "start": {"1699999999"}, "end": {"1700000001"}}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/export-jobs/application?"+params.Encode(), nil))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	job := exportJob{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	require.Equal(t, exportJobRunning, job.Status)
+	location := w.Header().Get("Location")
+	require.Equal(t, "/api/export-jobs/application/"+job.ID, location)
+
+	require.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, location, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		return job.Status != exportJobRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, exportJobSucceeded, job.Status, job.Error)
+	require.Equal(t, 3, job.Lines)
+
+	// the export is uploaded under the prefix and the tenant, and downloaded
+	// from the presigned URL
+	key := "/exports/logs/application/" + job.ID + "/application-logs-20231114T221321Z.ndjson"
+	mu.Lock()
+	require.Equal(t, 3, strings.Count(objects[key], "\n"))
+	mu.Unlock()
+	link, err := url.Parse(job.URL)
+	require.NoError(t, err)
+	require.Equal(t, key, link.Path)
+	require.Equal(t, "3600", link.Query().Get("X-Amz-Expires"))
+	require.NotEmpty(t, link.Query().Get("X-Amz-Signature"))
+
+	// the jobs of other tenants are not found, and the requests are
+	// validated like the streamed exports
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/export-jobs/audit/"+job.ID, nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/export-jobs/application?query=sum(rate({app=\"api\"}[1m]))", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// the jobs failing to upload report the error
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "access-key-id"), []byte("revoked"), 0600))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/export-jobs/application?"+params.Encode(), nil))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	require.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/export-jobs/application/"+job.ID, nil))
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		return job.Status != exportJobRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, exportJobFailed, job.Status)
+	require.Contains(t, job.Error, "status 403")
+}
+
+func TestExportObjectStorageConfigValidation(t *testing.T) {
+	_, err := parsePluginConfig([]byte(`
+export:
+  objectStorage:
+    endpoint: s3.amazonaws.com
+    bucket: exports
+    urlExpiry: 192h
+`))
+	require.Error(t, err)
+	for _, field := range []string{"export.objectStorage.endpoint", "export.objectStorage.accessKeyIDFile", "export.objectStorage.secretAccessKeyFile", "export.objectStorage.urlExpiry"} {
+		require.Contains(t, err.Error(), field)
+	}
+}
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// FairnessConfig shares the queries in flight between the users, so that the
+// heavy queries of a user cannot starve the other ones. The queries beyond
+// MaxInFlight, or beyond MaxInFlightPerUser for their user, are queued, and a
+// freed slot goes to the queued user with the fewest queries in flight for
+// their weight
+type FairnessConfig struct {
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	// MaxInFlight bounds the queries of all the users at the same time
+	MaxInFlight int `yaml:"maxInFlight,omitempty" json:"maxInFlight,omitempty"`
+	// MaxInFlightPerUser bounds the queries of each user at the same time
+	MaxInFlightPerUser int `yaml:"maxInFlightPerUser,omitempty" json:"maxInFlightPerUser,omitempty"`
+	// MaxQueued bounds the queued queries, the queries beyond are rejected
+	MaxQueued int `yaml:"maxQueued,omitempty" json:"maxQueued,omitempty"`
+	// MaxWait is the time a query is queued before it is rejected
+	MaxWait time.Duration `yaml:"maxWait,omitempty" json:"maxWait,omitempty"`
+	// Weights give a larger share to the members of the groups, the users
+	// get the largest weight of their groups and 1 without one
+	Weights []FairnessWeight `yaml:"weights,omitempty" json:"weights,omitempty"`
+}
+
+// FairnessWeight is the weight of the members of a group
+type FairnessWeight struct {
+	Group  string `yaml:"group,omitempty" json:"group,omitempty"`
+	Weight int    `yaml:"weight,omitempty" json:"weight,omitempty"`
+}
+
+var defaultFairnessConfig = FairnessConfig{
+	MaxInFlight:        50,
+	MaxInFlightPerUser: 5,
+	MaxQueued:          100,
+	MaxWait:            10 * time.Second,
+}
+
+func (c FairnessConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.MaxInFlight < 0 {
+		errs = append(errs, ConfigValidationError{Field: "fairness.maxInFlight", Message: "maxInFlight cannot be negative"})
+	}
+	if c.MaxInFlightPerUser < 0 {
+		errs = append(errs, ConfigValidationError{Field: "fairness.maxInFlightPerUser", Message: "maxInFlightPerUser cannot be negative"})
+	}
+	if c.MaxQueued < 0 {
+		errs = append(errs, ConfigValidationError{Field: "fairness.maxQueued", Message: "maxQueued cannot be negative"})
+	}
+	if c.MaxWait < 0 || c.MaxWait > maxTimeout {
+		errs = append(errs, ConfigValidationError{Field: "fairness.maxWait", Message: fmt.Sprintf("maxWait must be between 0 and %s", maxTimeout)})
+	}
+	for i, weight := range c.Weights {
+		field := fmt.Sprintf("fairness.weights[%d]", i)
+		if weight.Group == "" {
+			errs = append(errs, ConfigValidationError{Field: field + ".group", Message: "group is required"})
+		}
+		if weight.Weight < 1 {
+			errs = append(errs, ConfigValidationError{Field: field + ".weight", Message: "weight must be at least 1"})
+		}
+	}
+	return errs
+}
+
+// fairnessRejection describes a query rejected by the fair scheduler, it is
+// returned in the details of the 429 error
+type fairnessRejection struct {
+	Reason string `json:"reason"`
+	// Position is the position of the query in the queue, 0 when it was
+	// not queued
+	Position int `json:"position"`
+	Queued   int `json:"queued"`
+	// UserInFlight is the number of queries of the user in flight
+	UserInFlight       int    `json:"userInFlight"`
+	MaxInFlightPerUser int    `json:"maxInFlightPerUser"`
+	MaxWait            string `json:"maxWait"`
+}
+
+// reasons of the rejections of the fair scheduler
+const (
+	fairnessQueueFull = "queue_full"
+	fairnessMaxWait   = "max_wait"
+)
+
+type fairWaiter struct {
+	seq     uint64
+	ready   chan struct{}
+	granted bool
+}
+
+type fairUser struct {
+	weight   int
+	inFlight int
+	waiters  []*fairWaiter
+}
+
+// fairScheduler grants the slots of the queries in flight to the users
+type fairScheduler struct {
+	cfg     FairnessConfig
+	mu      sync.Mutex
+	users   map[string]*fairUser
+	running int
+	queued  int
+	seq     uint64
+}
+
+// newFairScheduler returns the scheduler of cfg, nil when disabled
+func newFairScheduler(cfg FairnessConfig) *fairScheduler {
+	if !cfg.Enabled || cfg.MaxInFlight <= 0 {
+		return nil
+	}
+	if cfg.MaxInFlightPerUser <= 0 || cfg.MaxInFlightPerUser > cfg.MaxInFlight {
+		cfg.MaxInFlightPerUser = cfg.MaxInFlight
+	}
+	return &fairScheduler{cfg: cfg, users: map[string]*fairUser{}}
+}
+
+// weight returns the largest weight of the groups
+func (s *fairScheduler) weight(groups []string) int {
+	weight := 1
+	for _, w := range s.cfg.Weights {
+		for _, group := range groups {
+			if group == w.Group && w.Weight > weight {
+				weight = w.Weight
+			}
+		}
+	}
+	return weight
+}
+
+// acquire waits for a slot for the query of the user key, the slot must be
+// released once the query is served. It returns the rejection when the queue
+// is full or MaxWait is reached, and the context error when the request is
+// canceled while queued
+func (s *fairScheduler) acquire(r *http.Request, key string, groups []string) (*fairnessRejection, error) {
+	s.mu.Lock()
+	user, ok := s.users[key]
+	if !ok {
+		user = &fairUser{weight: s.weight(groups)}
+		s.users[key] = user
+	}
+	if s.running < s.cfg.MaxInFlight && user.inFlight < s.cfg.MaxInFlightPerUser && len(user.waiters) == 0 {
+		s.running++
+		user.inFlight++
+		s.mu.Unlock()
+		return nil, nil
+	}
+	if s.queued >= s.cfg.MaxQueued {
+		rejection := s.rejection(fairnessQueueFull, user, 0)
+		s.removeIdle(key, user)
+		s.mu.Unlock()
+		return rejection, nil
+	}
+	s.seq++
+	waiter := &fairWaiter{seq: s.seq, ready: make(chan struct{})}
+	user.waiters = append(user.waiters, waiter)
+	s.queued++
+	s.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(s.cfg.MaxWait)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-waiter.ready:
+	case <-timer.C:
+	case <-r.Context().Done():
+		err = r.Context().Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if waiter.granted {
+		if err != nil {
+			s.releaseLocked(key)
+			return nil, err
+		}
+		metrics.FairnessQueueWait.Observe(time.Since(start).Seconds())
+		return nil, nil
+	}
+
+	position := s.position(waiter)
+	for i, w := range user.waiters {
+		if w == waiter {
+			user.waiters = append(user.waiters[:i], user.waiters[i+1:]...)
+			break
+		}
+	}
+	s.queued--
+	s.removeIdle(key, user)
+	if err != nil {
+		return nil, err
+	}
+	return s.rejection(fairnessMaxWait, user, position), nil
+}
+
+// release frees the slot of a query of the user key
+func (s *fairScheduler) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked(key)
+}
+
+func (s *fairScheduler) releaseLocked(key string) {
+	user := s.users[key]
+	user.inFlight--
+	s.running--
+	s.removeIdle(key, user)
+	s.dispatch()
+}
+
+// dispatch grants the free slots to the first waiter of the users below
+// their cap, the users with the fewest queries in flight for their weight
+// first, then the longest waiting
+func (s *fairScheduler) dispatch() {
+	for s.running < s.cfg.MaxInFlight {
+		var next *fairUser
+		for _, user := range s.users {
+			if len(user.waiters) == 0 || user.inFlight >= s.cfg.MaxInFlightPerUser {
+				continue
+			}
+			if next == nil {
+				next = user
+				continue
+			}
+			share, nextShare := user.inFlight*next.weight, next.inFlight*user.weight
+			if share < nextShare || (share == nextShare && user.waiters[0].seq < next.waiters[0].seq) {
+				next = user
+			}
+		}
+		if next == nil {
+			return
+		}
+
+		waiter := next.waiters[0]
+		next.waiters = next.waiters[1:]
+		waiter.granted = true
+		close(waiter.ready)
+		next.inFlight++
+		s.running++
+		s.queued--
+	}
+}
+
+// position returns the position of waiter in the queue, by arrival
+func (s *fairScheduler) position(waiter *fairWaiter) int {
+	position := 1
+	for _, user := range s.users {
+		for _, w := range user.waiters {
+			if w.seq < waiter.seq {
+				position++
+			}
+		}
+	}
+	return position
+}
+
+func (s *fairScheduler) rejection(reason string, user *fairUser, position int) *fairnessRejection {
+	return &fairnessRejection{
+		Reason:             reason,
+		Position:           position,
+		Queued:             s.queued,
+		UserInFlight:       user.inFlight,
+		MaxInFlightPerUser: s.cfg.MaxInFlightPerUser,
+		MaxWait:            s.cfg.MaxWait.String(),
+	}
+}
+
+// removeIdle forgets the users without query, their weight is resolved again
+// on their next query
+func (s *fairScheduler) removeIdle(key string, user *fairUser) {
+	if user.inFlight == 0 && len(user.waiters) == 0 {
+		delete(s.users, key)
+	}
+}
+
+// fairnessMiddleware queues the queries beyond the in-flight caps of the
+// scheduler, and replies with a 429 error whose details hold the queue
+// position when the queue is full or the query waited for MaxWait. It must run
+// after authenticationMiddleware. It does nothing when scheduler is nil
+func fairnessMiddleware(scheduler *fairScheduler, route string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if scheduler == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := rateLimitKey(r)
+			var groups []string
+			if user, ok := requestUser(r); ok {
+				groups = user.Groups
+			}
+
+			rejection, err := scheduler.acquire(r, key, groups)
+			if err != nil {
+				// the client is gone
+				return
+			}
+			if rejection != nil {
+				metrics.FairnessRejectedTotal.WithLabelValues(route, rejection.Reason).Inc()
+				w.Header().Set("Retry-After", "1")
+				writeError(w, r, http.StatusTooManyRequests, errorCodeTooManyRequests, fmt.Sprintf("too many queries in flight, %d queued", rejection.Queued), rejection)
+				return
+			}
+			defer scheduler.release(key)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
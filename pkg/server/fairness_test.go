@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFairScheduler(t *testing.T) {
+	scheduler := newFairScheduler(FairnessConfig{Enabled: true, MaxInFlight: 2, MaxInFlightPerUser: 2, MaxQueued: 10, MaxWait: time.Minute, Weights: []FairnessWeight{{Group: "admins", Weight: 2}}})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// alice takes every slot
+	for i := 0; i < 2; i++ {
+		rejection, err := scheduler.acquire(r, "user:alice", nil)
+		require.NoError(t, err)
+		require.Nil(t, rejection)
+	}
+
+	// alice and bob queue, bob is served first as alice has the slots
+	served := make(chan string, 2)
+	queue := func(key string) {
+		rejection, err := scheduler.acquire(r, key, nil)
+		require.NoError(t, err)
+		require.Nil(t, rejection)
+		served <- key
+	}
+	go queue("user:alice")
+	require.Eventually(t, func() bool { return queued(scheduler) == 1 }, time.Second, time.Millisecond)
+	go queue("user:bob")
+	require.Eventually(t, func() bool { return queued(scheduler) == 2 }, time.Second, time.Millisecond)
+
+	scheduler.release("user:alice")
+	require.Equal(t, "user:bob", <-served)
+	scheduler.release("user:alice")
+	require.Equal(t, "user:alice", <-served)
+
+	require.Equal(t, 2, scheduler.weight([]string{"devs", "admins"}))
+	require.Equal(t, 1, scheduler.weight([]string{"devs"}))
+}
+
+func TestFairSchedulerRejections(t *testing.T) {
+	scheduler := newFairScheduler(FairnessConfig{Enabled: true, MaxInFlight: 1, MaxInFlightPerUser: 1, MaxQueued: 1, MaxWait: 200 * time.Millisecond})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	rejection, err := scheduler.acquire(r, "user:alice", nil)
+	require.NoError(t, err)
+	require.Nil(t, rejection)
+
+	// the query waits for maxWait
+	rejection, err = scheduler.acquire(r, "user:bob", nil)
+	require.NoError(t, err)
+	require.Equal(t, &fairnessRejection{Reason: fairnessMaxWait, Position: 1, UserInFlight: 0, MaxInFlightPerUser: 1, MaxWait: "200ms"}, rejection)
+
+	// the queue is full
+	go scheduler.acquire(r, "user:bob", nil)
+	require.Eventually(t, func() bool { return queued(scheduler) == 1 }, time.Second, time.Millisecond)
+	rejection, err = scheduler.acquire(r, "user:carol", nil)
+	require.NoError(t, err)
+	require.Equal(t, &fairnessRejection{Reason: fairnessQueueFull, Queued: 1, MaxInFlightPerUser: 1, MaxWait: "200ms"}, rejection)
+
+	// the canceled queries leave the queue
+	require.Eventually(t, func() bool { return queued(scheduler) == 0 }, time.Second, time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = scheduler.acquire(r.WithContext(ctx), "user:bob", nil)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 0, queued(scheduler))
+
+	scheduler.release("user:alice")
+	scheduler.mu.Lock()
+	require.Empty(t, scheduler.users)
+	scheduler.mu.Unlock()
+}
+
+func TestFairnessMiddleware(t *testing.T) {
+	scheduler := newFairScheduler(FairnessConfig{Enabled: true, MaxInFlight: 1, MaxInFlightPerUser: 1, MaxQueued: 1, MaxWait: 10 * time.Millisecond})
+	block := make(chan struct{})
+	handler := fairnessMiddleware(scheduler, "proxy")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+
+	send := func(user string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range", nil)
+		r = r.WithContext(context.WithValue(r.Context(), userKey{}, &kube.UserInfo{Username: user}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	done := make(chan int)
+	go func() { done <- send("alice").Code }()
+	require.Eventually(t, func() bool {
+		scheduler.mu.Lock()
+		defer scheduler.mu.Unlock()
+		return scheduler.running == 1
+	}, time.Second, time.Millisecond)
+
+	w := send("bob")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	response := struct {
+		Error struct {
+			Code    string            `json:"code"`
+			Details fairnessRejection `json:"details"`
+		} `json:"error"`
+	}{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, errorCodeTooManyRequests, response.Error.Code)
+	require.Equal(t, fairnessMaxWait, response.Error.Details.Reason)
+	require.Equal(t, 1, response.Error.Details.Position)
+
+	close(block)
+	require.Equal(t, http.StatusOK, <-done)
+	require.Equal(t, http.StatusOK, send("bob").Code)
+}
+
+func TestFairnessConfig(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte("fairness:\n  enabled: true"))
+	require.NoError(t, err)
+	require.Equal(t, FairnessConfig{Enabled: true, MaxInFlight: 50, MaxInFlightPerUser: 5, MaxQueued: 100, MaxWait: 10 * time.Second}, pluginConfig.Fairness)
+	require.Nil(t, newFairScheduler(FairnessConfig{MaxInFlight: 10}))
+
+	_, err = parsePluginConfig([]byte("fairness:\n  maxInFlightPerUser: -1\n  maxWait: 1h\n  weights:\n    - group: admins\n    - weight: 2"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "fairness.maxInFlightPerUser", Message: "maxInFlightPerUser cannot be negative"},
+		{Field: "fairness.maxWait", Message: "maxWait must be between 0 and 10m0s"},
+		{Field: "fairness.weights[0].weight", Message: "weight must be at least 1"},
+		{Field: "fairness.weights[1].group", Message: "group is required"},
+	}, err)
+}
+
+func queued(scheduler *fairScheduler) int {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	return scheduler.queued
+}
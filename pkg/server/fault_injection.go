@@ -0,0 +1,115 @@
+package server
+
+import (
+	"math/rand"
+	"net/http"
+	"path"
+	"time"
+)
+
+// slowWriteChunkSize is the size of the chunks written by the slow stream
+// fault, each one of them delayed
+const slowWriteChunkSize = 512
+
+// FaultInjectionRule injects faults on a Percentage of the requests whose path
+// matches Path, using the path.Match syntax. Meant for testing the frontend
+// error handling only
+type FaultInjectionRule struct {
+	Path       string  `yaml:"path" json:"path"`
+	Percentage float64 `yaml:"percentage" json:"percentage"`
+	// Delay is waited before handling the request
+	Delay time.Duration `yaml:"delay,omitempty" json:"delay,omitempty"`
+	// StatusCode is returned instead of handling the request when set
+	StatusCode int `yaml:"statusCode,omitempty" json:"statusCode,omitempty"`
+	// TruncateBytes cuts the response body after the given number of bytes
+	TruncateBytes int `yaml:"truncateBytes,omitempty" json:"truncateBytes,omitempty"`
+	// SlowWriteDelay is waited before writing every chunk of the response body
+	SlowWriteDelay time.Duration `yaml:"slowWriteDelay,omitempty" json:"slowWriteDelay,omitempty"`
+}
+
+func faultInjectionMiddleware(rules []FaultInjectionRule) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rule := matchingFaultRule(rules, r.URL.Path)
+			if rule == nil || rand.Float64()*100 >= rule.Percentage {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			requestLog(slog, r).Debugf("injecting fault on %s: %+v", r.URL.Path, *rule)
+
+			if rule.Delay > 0 {
+				select {
+				case <-time.After(rule.Delay):
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+			if rule.StatusCode != 0 {
+				writeError(w, r, rule.StatusCode, errorCodeInjectedFault, "injected fault", nil)
+				return
+			}
+
+			next.ServeHTTP(&faultyResponseWriter{
+				ResponseWriter: w,
+				remaining:      rule.TruncateBytes,
+				truncate:       rule.TruncateBytes > 0,
+				writeDelay:     rule.SlowWriteDelay,
+			}, r)
+		})
+	}
+}
+
+func matchingFaultRule(rules []FaultInjectionRule, requestPath string) *FaultInjectionRule {
+	for i := range rules {
+		if matched, _ := path.Match(rules[i].Path, requestPath); matched {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+type faultyResponseWriter struct {
+	http.ResponseWriter
+	truncate   bool
+	remaining  int
+	writeDelay time.Duration
+}
+
+func (w *faultyResponseWriter) Write(data []byte) (int, error) {
+	written := 0
+
+	for len(data) > 0 {
+		chunk := data
+		if w.writeDelay > 0 && len(chunk) > slowWriteChunkSize {
+			chunk = chunk[:slowWriteChunkSize]
+		}
+		if w.truncate && len(chunk) > w.remaining {
+			chunk = chunk[:w.remaining]
+		}
+		if len(chunk) == 0 {
+			// pretend the truncated data was written so handlers keep going
+			return written + len(data), nil
+		}
+
+		if w.writeDelay > 0 {
+			time.Sleep(w.writeDelay)
+		}
+
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		w.remaining -= n
+		if err != nil {
+			return written, err
+		}
+
+		if flusher, ok := w.ResponseWriter.(http.Flusher); ok && w.writeDelay > 0 {
+			flusher.Flush()
+		}
+
+		data = data[n:]
+	}
+
+	return written, nil
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjectionMiddleware(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(`
+faultInjection:
+  - path: /features
+    percentage: 100
+    statusCode: 503
+  - path: /plugin-*
+    percentage: 100
+    delay: 10ms
+    truncateBytes: 5
+  - path: /health
+    percentage: 0
+    statusCode: 500
+`))
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Millisecond, pluginConfig.FaultInjection[1].Delay)
+
+	handler := faultInjectionMiddleware(pluginConfig.FaultInjection)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("response body"))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/features", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plugin-entry.js", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "respo", w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, "response body", w.Body.String())
+}
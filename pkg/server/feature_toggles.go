@@ -0,0 +1,224 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"gopkg.in/yaml.v3"
+)
+
+// maxFeaturesRequestSize bounds the body of a feature change
+const maxFeaturesRequestSize = 4 << 10
+
+// errNoConfigMap is returned when a change is persisted while the plugin
+// config is read from a file
+var errNoConfigMap = errors.New("the plugin config is not read from a ConfigMap")
+
+// featuresPatchRequest is the body of PATCH /api/admin/features
+type featuresPatchRequest struct {
+	// Features enables and disables the features by name
+	Features map[string]bool `json:"features"`
+	// Persist writes the change to the features section of the plugin config
+	// ConfigMap, the change is lost on restart otherwise
+	Persist bool `json:"persist,omitempty"`
+}
+
+// overrideFeatures applies features over the features section of the loaded
+// and the next configs, until the restart
+func (c *reloadingPluginConfig) overrideFeatures(features map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.featureOverrides == nil {
+		c.featureOverrides = map[string]bool{}
+	}
+	for feature, enabled := range features {
+		c.featureOverrides[feature] = enabled
+	}
+	c.set(c.config)
+}
+
+// persistFeatures writes features to the features section of the plugin
+// config ConfigMap and applies the updated config. The other settings and the
+// comments of the config are kept, the update fails with a conflict when the
+// ConfigMap changes meanwhile
+func (c *reloadingPluginConfig) persistFeatures(ctx context.Context, features map[string]bool) error {
+	source := c.configMap
+	if source == nil {
+		return errNoConfigMap
+	}
+
+	configMap, err := source.client.GetConfigMap(ctx, source.namespace, source.name)
+	if err != nil {
+		return err
+	}
+	key, err := source.key(configMap)
+	if err != nil {
+		return err
+	}
+	content, err := setYAMLFeatures([]byte(configMap.Data[key]), features)
+	if err != nil {
+		return fmt.Errorf("cannot change plugin config ConfigMap %s/%s: %w", source.namespace, source.name, err)
+	}
+	configMap.Data[key] = string(content)
+	if _, err := source.parse(configMap); err != nil {
+		return err
+	}
+
+	updated, err := source.client.UpdateConfigMap(ctx, configMap)
+	if err != nil {
+		return err
+	}
+
+	// the persisted features replace the runtime changes
+	c.mu.Lock()
+	for feature := range features {
+		delete(c.featureOverrides, feature)
+	}
+	c.mu.Unlock()
+	c.applyConfigMapEvent(kube.ConfigMapEvent{Type: kube.EventModified, ConfigMap: updated})
+
+	return nil
+}
+
+// setYAMLFeatures sets features in the features section of the YAML plugin
+// config content, editing its nodes so that the rest of the document is kept
+func setYAMLFeatures(content []byte, features map[string]bool) ([]byte, error) {
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal(content, document); err != nil {
+		return nil, err
+	}
+	if document.Kind == 0 {
+		document.Kind = yaml.DocumentNode
+		document.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the plugin config is not a mapping")
+	}
+
+	section := yamlMappingValue(root, "features")
+	if section == nil {
+		section = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "features"}, section)
+	} else if section.Kind == yaml.ScalarNode && section.Tag == "!!null" {
+		// an empty features: key
+		*section = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	} else if section.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the features section is not a mapping")
+	}
+
+	names := make([]string, 0, len(features))
+	for feature := range features {
+		names = append(names, feature)
+	}
+	sort.Strings(names)
+	for _, feature := range names {
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(features[feature])}
+		if existing := yamlMappingValue(section, feature); existing != nil {
+			value.HeadComment, value.LineComment, value.FootComment = existing.HeadComment, existing.LineComment, existing.FootComment
+			*existing = *value
+			continue
+		}
+		section.Content = append(section.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: feature}, value)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlMappingValue returns the value of key in mapping, nil when missing
+func yamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// patchFeaturesHandler enables and disables features at runtime, over the
+// flags and the features section of the plugin config. The features read at
+// startup, like korrel8r, still require a restart
+func patchFeaturesHandler(cfg *Config, reloadingConfig *reloadingPluginConfig) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFeaturesRequestSize))
+		if err != nil {
+			writeError(w, r, http.StatusRequestEntityTooLarge, errorCodePayloadTooLarge, "cannot read features", err.Error())
+			return
+		}
+
+		req := featuresPatchRequest{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid features", err.Error())
+			return
+		}
+		if len(req.Features) == 0 {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "at least one feature is required", nil)
+			return
+		}
+
+		// the feature rules are checked against the changed features section
+		pluginConfig := reloadingConfig.get()
+		candidate := make(map[string]bool, len(pluginConfig.Features)+len(req.Features))
+		for feature, enabled := range pluginConfig.Features {
+			candidate[feature] = enabled
+		}
+		for feature, enabled := range req.Features {
+			candidate[feature] = enabled
+		}
+		errs := append(validateFeatures(req.Features), pluginConfig.FeatureRules.validate(candidate)...)
+		if len(errs) > 0 {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid features", errs)
+			return
+		}
+
+		if req.Persist {
+			err := reloadingConfig.persistFeatures(r.Context(), req.Features)
+			var validationErrs ConfigValidationErrors
+			switch {
+			case errors.Is(err, errNoConfigMap):
+				writeError(w, r, http.StatusConflict, errorCodeConflict, err.Error(), nil)
+				return
+			case kube.IsConflict(err):
+				writeError(w, r, http.StatusConflict, errorCodeConflict, "the plugin config ConfigMap changed meanwhile, retry the change", nil)
+				return
+			case errors.As(err, &validationErrs):
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "the changed plugin config is invalid", validationErrs)
+				return
+			case err != nil:
+				requestLog(slog, r).WithError(err).Error("cannot persist features")
+				writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "cannot persist the features", err.Error())
+				return
+			}
+		} else {
+			reloadingConfig.overrideFeatures(req.Features)
+		}
+
+		entry := requestLog(slog, r).WithField("persisted", req.Persist)
+		if user, ok := requestUser(r); ok {
+			entry = entry.WithField("user", user.Username)
+		}
+		// logged at warning so that the change is kept at any level
+		entry.Warnf("features changed: %v", req.Features)
+
+		resolved := resolveFeatures(cfg.Features, reloadingConfig.get())
+		writeJSON(w, r, http.StatusOK, featuresResponse{Features: resolved.enabled, Disabled: resolved.disabled})
+	})
+}
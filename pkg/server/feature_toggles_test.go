@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetYAMLFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "empty config",
+			content:  "",
+			expected: "features:\n  alerts: false\n  dev-console: true\n",
+		},
+		{
+			name: "existing features",
+			content: `# plugin config
+logsLimit: 100
+features:
+  alerts: true # enabled by the operator
+  dev-console: false
+`,
+			expected: `# plugin config
+logsLimit: 100
+features:
+  alerts: false # enabled by the operator
+  dev-console: true
+`,
+		},
+		{
+			name:     "empty features section",
+			content:  "features:\nlogsLimit: 100\n",
+			expected: "features:\n  alerts: false\n  dev-console: true\nlogsLimit: 100\n",
+		},
+		{
+			name:    "invalid features section",
+			content: "features: [alerts]\n",
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := setYAMLFeatures([]byte(tt.content), map[string]bool{"dev-console": true, "alerts": false})
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(content))
+		})
+	}
+}
+
+func TestPatchFeaturesHandler(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(`
+features:
+  alerts: true
+featureRules:
+  requires:
+    dev-alerts: [dev-console]
+`))
+	require.NoError(t, err)
+	reloadingConfig := &reloadingPluginConfig{config: pluginConfig}
+
+	r := mux.NewRouter()
+	registerAdminRoutes(r, &Config{Features: map[string]bool{"dev-console": true}}, reloadingConfig, nil)
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/admin/features", strings.NewReader(body))
+		req.RemoteAddr = "127.0.0.1:41000"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := patch(`{"features":{"alerts":false,"dev-alerts":true}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	response := featuresResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, map[string]bool{"dev-console": true, "dev-alerts": true}, response.Features)
+
+	// the runtime changes are kept over the reloaded configs
+	reloaded, err := parsePluginConfig([]byte("features:\n  alerts: true\n"))
+	require.NoError(t, err)
+	reloadingConfig.mu.Lock()
+	reloadingConfig.set(reloaded)
+	reloadingConfig.mu.Unlock()
+	require.Equal(t, map[string]bool{"dev-console": true, "dev-alerts": true}, reloadingConfig.features(&Config{Features: map[string]bool{"dev-console": true}}))
+
+	require.Equal(t, http.StatusBadRequest, patch(`{"features":{"Alerts":true}}`).Code)
+	require.Equal(t, http.StatusBadRequest, patch(`{"features":{}}`).Code)
+	// without a ConfigMap the changes cannot be persisted
+	require.Equal(t, http.StatusConflict, patch(`{"features":{"alerts":true},"persist":true}`).Code)
+
+	// with authentication, only the members of the admin groups change them
+	reloadingConfig.get().Admin.Groups = []string{"admins"}
+	r = mux.NewRouter()
+	registerAdminRoutes(r, &Config{}, reloadingConfig, newAdminTestAuthenticator())
+	for token, expected := range map[string]int{"developer": http.StatusForbidden, "admin": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodPatch, "/api/admin/features", strings.NewReader(`{"features":{"alerts":false}}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, expected, w.Code, w.Body.String())
+	}
+}
+
+func TestPersistFeatures(t *testing.T) {
+	watcher := &fakeConfigMapWatcher{configMap: testConfigMap("1", "logsLimit: 100\n")}
+	reloadingConfig, err := newConfigMapPluginConfig(context.Background(), watcher, "openshift-logging", "plugin-config")
+	require.NoError(t, err)
+
+	reloadingConfig.overrideFeatures(map[string]bool{"dev-console": true, "alerts": true})
+	require.NoError(t, reloadingConfig.persistFeatures(context.Background(), map[string]bool{"dev-console": false}))
+
+	require.Equal(t, "2", watcher.configMap.Metadata.ResourceVersion)
+	require.Equal(t, "logsLimit: 100\nfeatures:\n  dev-console: false\n", watcher.configMap.Data["config.yaml"])
+	// the persisted feature replaces its runtime change, the other runtime
+	// changes are kept
+	require.Equal(t, map[string]bool{"dev-console": false, "alerts": true}, reloadingConfig.get().Features)
+	require.Equal(t, map[string]bool{"alerts": true}, reloadingConfig.featureOverrides)
+}
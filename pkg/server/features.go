@@ -0,0 +1,277 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// featureNameRegexp matches the feature names of the plugin config, lower
+// case like the names of the -features flag
+var featureNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// FeatureRulesConfig declares the relations between the features, the
+// requested features breaking them are disabled
+type FeatureRulesConfig struct {
+	// Requires lists the features each feature depends on, like dev-alerts
+	// requiring dev-console
+	Requires map[string][]string `yaml:"requires,omitempty" json:"requires,omitempty"`
+	// Conflicts are the groups of mutually exclusive features, only the first
+	// requested feature of a group is enabled
+	Conflicts [][]string `yaml:"conflicts,omitempty" json:"conflicts,omitempty"`
+}
+
+func (c FeatureRulesConfig) validate(features map[string]bool) ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	invalidName := func(field string, feature string) {
+		errs = append(errs, ConfigValidationError{Field: field, Message: fmt.Sprintf("invalid feature name %q, lower case letters, digits and dashes are expected", feature)})
+	}
+
+	for _, feature := range sortedRuleFeatures(c.Requires) {
+		field := "featureRules.requires." + feature
+		if !featureNameRegexp.MatchString(feature) {
+			invalidName(field, feature)
+		}
+		for _, required := range c.Requires[feature] {
+			switch {
+			case !featureNameRegexp.MatchString(required):
+				invalidName(field, required)
+			case required == feature:
+				errs = append(errs, ConfigValidationError{Field: field, Message: fmt.Sprintf("feature %s cannot require itself", feature)})
+			case features[feature] && hasKey(features, required) && !features[required]:
+				errs = append(errs, ConfigValidationError{Field: "features." + feature, Message: fmt.Sprintf("feature %s requires %s, which is disabled", feature, required)})
+			}
+		}
+		if cycle := c.requiresCycle(feature); cycle != nil {
+			errs = append(errs, ConfigValidationError{Field: field, Message: fmt.Sprintf("circular requirement %s", strings.Join(cycle, " -> "))})
+		}
+	}
+
+	for i, group := range c.Conflicts {
+		field := fmt.Sprintf("featureRules.conflicts[%d]", i)
+		if len(group) < 2 {
+			errs = append(errs, ConfigValidationError{Field: field, Message: "a conflict needs at least 2 features"})
+		}
+		enabled := []string{}
+		for _, feature := range group {
+			if !featureNameRegexp.MatchString(feature) {
+				invalidName(field, feature)
+			}
+			if features[feature] {
+				enabled = append(enabled, feature)
+			}
+		}
+		if len(enabled) > 1 {
+			errs = append(errs, ConfigValidationError{Field: "features." + enabled[1], Message: fmt.Sprintf("feature %s conflicts with %s", enabled[1], enabled[0])})
+		}
+	}
+
+	return errs
+}
+
+// requiresCycle returns the circular requirement starting from feature, nil
+// when there is none
+func (c FeatureRulesConfig) requiresCycle(feature string) []string {
+	var visit func(path []string) []string
+	visit = func(path []string) []string {
+		current := path[len(path)-1]
+		for _, required := range c.Requires[current] {
+			if required == feature {
+				return append(path, required)
+			}
+			for _, visited := range path {
+				if visited == required {
+					// a cycle not going through feature is reported by its
+					// own features
+					return nil
+				}
+			}
+			if cycle := visit(append(append([]string{}, path...), required)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	// the self requirements are reported on their own
+	for _, required := range c.Requires[feature] {
+		if required == feature {
+			return nil
+		}
+	}
+	return visit([]string{feature})
+}
+
+// the backend subsystems served unless the features section of the plugin
+// config disables them, their routes are then not registered and answer 404
+const (
+	featureExport   = "export"
+	featureVolume   = "volume"
+	featureStats    = "stats"
+	featureSeverity = "severity"
+	featureParse    = "parse"
+	featureRules    = "rules"
+	featureSuggest  = "suggest"
+	featureEntries  = "entries"
+)
+
+// defaultFeatureEnabled tells whether the backend subsystem feature, served
+// by default, is not disabled by the features section of pluginConfig
+func defaultFeatureEnabled(pluginConfig *PluginConfig, feature string) bool {
+	enabled, set := pluginConfig.Features[feature]
+	return !set || enabled
+}
+
+// resolvedFeatures are the effective features and the reasons the other
+// requested features are disabled
+type resolvedFeatures struct {
+	enabled  map[string]bool
+	disabled map[string]string
+}
+
+// resolveFeatures applies the feature rules of the plugin config to the
+// features requested by the flags and the plugin config: the features
+// conflicting with a previous feature of their group, then the features
+// missing a requirement are disabled
+func resolveFeatures(flags map[string]bool, pluginConfig *PluginConfig) resolvedFeatures {
+	resolved := resolvedFeatures{enabled: enabledFeatures(flags, pluginConfig), disabled: map[string]string{}}
+	rules := pluginConfig.FeatureRules
+
+	for _, group := range rules.Conflicts {
+		winner := ""
+		for _, feature := range group {
+			if !resolved.enabled[feature] {
+				continue
+			}
+			if winner == "" {
+				winner = feature
+				continue
+			}
+			delete(resolved.enabled, feature)
+			resolved.disabled[feature] = fmt.Sprintf("conflicts with %s", winner)
+		}
+	}
+
+	// disabling a feature may disable the features requiring it
+	for changed := true; changed; {
+		changed = false
+		for _, feature := range sortedFeatures(resolved.enabled) {
+			for _, required := range rules.Requires[feature] {
+				if !resolved.enabled[required] {
+					delete(resolved.enabled, feature)
+					resolved.disabled[feature] = fmt.Sprintf("requires %s", required)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	return resolved
+}
+
+// enabledFeatures merges the features of the flags with the features section
+// of the plugin config, the plugin config enables and disables them. Only the
+// enabled features are returned
+func enabledFeatures(flags map[string]bool, pluginConfig *PluginConfig) map[string]bool {
+	features := make(map[string]bool, len(flags)+len(pluginConfig.Features))
+	for feature, enabled := range flags {
+		if enabled {
+			features[feature] = true
+		}
+	}
+	for feature, enabled := range pluginConfig.Features {
+		if enabled {
+			features[feature] = true
+		} else {
+			delete(features, feature)
+		}
+	}
+	return features
+}
+
+// sortedFeatures returns the names of the enabled features in order
+func sortedFeatures(features map[string]bool) []string {
+	names := make([]string, 0, len(features))
+	for feature, enabled := range features {
+		if enabled {
+			names = append(names, feature)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// features returns the effective features of the flags of cfg and the
+// current plugin config
+func (c *reloadingPluginConfig) features(cfg *Config) map[string]bool {
+	return resolveFeatures(cfg.Features, c.get()).enabled
+}
+
+// sortedRuleFeatures returns the features of the requires rules in order
+func sortedRuleFeatures(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedDisabledFeatures returns the names of the disabled features in order
+func sortedDisabledFeatures(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func hasKey(m map[string]bool, key string) bool {
+	_, found := m[key]
+	return found
+}
+
+func validateFeatures(features map[string]bool) ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	for feature := range features {
+		if !featureNameRegexp.MatchString(feature) {
+			errs = append(errs, ConfigValidationError{Field: "features." + feature, Message: fmt.Sprintf("invalid feature name %q, lower case letters, digits and dashes are expected", feature)})
+		}
+	}
+	// the map order is random, keep the errors stable
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// featuresResponse is the effective set of features, and the reasons the
+// other requested features are disabled by the feature rules
+type featuresResponse struct {
+	Features map[string]bool   `json:"features"`
+	Disabled map[string]string `json:"disabled,omitempty"`
+}
+
+// featuresHandler serves the effective features to the front-end, following
+// the plugin config reloads
+func featuresHandler(cfg *Config, reloadingConfig *reloadingPluginConfig) http.HandlerFunc {
+	cache := newResponseCache()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pluginConfig, generation, loadedAt := reloadingConfig.snapshot()
+		cached := cache.get(generation, "features", func() ([]byte, error) {
+			resolved := resolveFeatures(cfg.Features, pluginConfig)
+			return json.Marshal(featuresResponse{Features: resolved.enabled, Disabled: resolved.disabled})
+		})
+
+		if cached.err != nil {
+			requestLog(slog, r).WithError(cached.err).Error("cannot marshal features")
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal features", cached.err.Error())
+			return
+		}
+
+		writeCachedJSON(w, r, cached, loadedAt)
+	})
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnabledFeatures(t *testing.T) {
+	features := enabledFeatures(
+		map[string]bool{"dev-console": true, "alerts": true, "disabled": false},
+		&PluginConfig{Features: map[string]bool{"alerts": false, "korrel8r": true}},
+	)
+	require.Equal(t, map[string]bool{"dev-console": true, "korrel8r": true}, features)
+	require.Equal(t, []string{"dev-console", "korrel8r"}, sortedFeatures(features))
+}
+
+func TestFeaturesValidation(t *testing.T) {
+	_, err := parsePluginConfig([]byte("features:\n  Dev_Console: true\n  alerts: true\n"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "features.Dev_Console", Message: `invalid feature name "Dev_Console", lower case letters, digits and dashes are expected`},
+	}, err)
+
+	// the variable of the -features flag does not override the section
+	pluginConfig, err := parsePluginConfigWithEnv([]byte("features:\n  alerts: true\n"), func(key string) (string, bool) {
+		return "dev-console", key == "LOGGING_VIEW_PLUGIN_FEATURES"
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"alerts": true}, pluginConfig.Features)
+}
+
+func TestFeaturesReload(t *testing.T) {
+	configPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(configPath, "plugin-manifest.json"), []byte(`{"extensions":[]}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(configPath, "alerts.patch.json"), []byte(`[{"op":"add","path":"/extensions/0","value":"alerts"}]`), 0600))
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0600))
+		require.NoError(t, os.Chtimes(configFile, modTime, modTime))
+	}
+
+	now := time.Now()
+	writeConfig("features:\n  alerts: true\n", now)
+
+	reloadingConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+
+	cfg := &Config{ConfigPath: configPath, Features: map[string]bool{"dev-console": true}}
+	features := featuresHandler(cfg, reloadingConfig)
+	manifest := manifestHandler(cfg, reloadingConfig)
+
+	get := func(handler http.Handler, path string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		body := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	require.Equal(t, map[string]interface{}{"dev-console": true, "alerts": true}, get(features, "/features")["features"])
+	require.Equal(t, []interface{}{"alerts"}, get(manifest, "/plugin-manifest.json")["extensions"])
+
+	writeConfig("features:\n  alerts: false\n", now.Add(time.Minute))
+	reloaded, err := reloadingConfig.reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+
+	require.Equal(t, map[string]interface{}{"dev-console": true}, get(features, "/features")["features"])
+	require.Equal(t, []interface{}{}, get(manifest, "/plugin-manifest.json")["extensions"])
+
+	// the features disabled by the rules are reported with their reason
+	writeConfig("featureRules:\n  requires:\n    alerts: [korrel8r]\nfeatures:\n  alerts: true\n", now.Add(2*time.Minute))
+	reloaded, err = reloadingConfig.reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+
+	body := get(features, "/features")
+	require.Equal(t, map[string]interface{}{"dev-console": true}, body["features"])
+	require.Equal(t, map[string]interface{}{"alerts": "requires korrel8r"}, body["disabled"])
+	require.Equal(t, []interface{}{}, get(manifest, "/plugin-manifest.json")["extensions"])
+}
+
+func TestResolveFeatures(t *testing.T) {
+	rules := FeatureRulesConfig{
+		Requires:  map[string][]string{"dev-alerts": {"dev-console"}, "alerts-tab": {"dev-alerts"}},
+		Conflicts: [][]string{{"korrel8r", "dev-profiling", "tracing"}},
+	}
+
+	resolved := resolveFeatures(
+		map[string]bool{"dev-alerts": true, "alerts-tab": true, "dev-profiling": true, "tracing": true},
+		&PluginConfig{FeatureRules: rules},
+	)
+	require.Equal(t, map[string]bool{"dev-profiling": true}, resolved.enabled)
+	require.Equal(t, map[string]string{
+		"dev-alerts": "requires dev-console",
+		"alerts-tab": "requires dev-alerts",
+		"tracing":    "conflicts with dev-profiling",
+	}, resolved.disabled)
+
+	resolved = resolveFeatures(
+		map[string]bool{"dev-console": true, "dev-alerts": true, "korrel8r": true},
+		&PluginConfig{FeatureRules: rules, Features: map[string]bool{"dev-profiling": true}},
+	)
+	require.Equal(t, map[string]bool{"dev-console": true, "dev-alerts": true, "korrel8r": true}, resolved.enabled)
+	require.Equal(t, map[string]string{"dev-profiling": "conflicts with korrel8r"}, resolved.disabled)
+}
+
+func TestFeatureRulesValidation(t *testing.T) {
+	_, err := parsePluginConfig([]byte(`
+featureRules:
+  requires:
+    dev-alerts: [dev-console]
+    a: [b]
+    b: [a]
+    c: [c]
+  conflicts:
+    - [korrel8r, dev-profiling]
+    - [alerts]
+features:
+  dev-alerts: true
+  dev-console: false
+  korrel8r: true
+  dev-profiling: true
+`))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "featureRules.requires.a", Message: "circular requirement a -> b -> a"},
+		{Field: "featureRules.requires.b", Message: "circular requirement b -> a -> b"},
+		{Field: "featureRules.requires.c", Message: "feature c cannot require itself"},
+		{Field: "features.dev-alerts", Message: "feature dev-alerts requires dev-console, which is disabled"},
+		{Field: "features.dev-profiling", Message: "feature dev-profiling conflicts with korrel8r"},
+		{Field: "featureRules.conflicts[1]", Message: "a conflict needs at least 2 features"},
+	}, err)
+}
+
+func TestDefaultFeatureRoutes(t *testing.T) {
+	route := func(features string, method string, path string) int {
+		configFile := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(configFile, []byte("lokiURL: https://loki.local\n"+features), 0600))
+		pluginConfig, err := newReloadingPluginConfig(configFile)
+		require.NoError(t, err)
+
+		router := setupRoutes(&Config{StaticPath: t.TempDir()}, pluginConfig, routeDeps{})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	require.NotEqual(t, http.StatusNotFound, route("", http.MethodGet, "/api/export/application?format=xml"))
+	require.NotEqual(t, http.StatusNotFound, route("", http.MethodPost, "/api/parse"))
+
+	disabled := "features:\n  export: false\n  parse: false\n"
+	require.Equal(t, http.StatusNotFound, route(disabled, http.MethodGet, "/api/export/application?format=xml"))
+	require.Equal(t, http.StatusNotFound, route(disabled, http.MethodPost, "/api/parse"))
+	require.NotEqual(t, http.StatusNotFound, route(disabled, http.MethodGet, "/api/rules"))
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/openshift/logging-view-plugin/pkg/datasource"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// datasourceTypeFederated fans the queries out to the Loki datasources of
+// several clusters
+const datasourceTypeFederated = "federated"
+
+// FederationConfig lists the Loki datasources queried by a federated
+// datasource, like the LokiStacks of the spoke clusters, and the label
+// holding their names in the merged results
+type FederationConfig struct {
+	Clusters     []string `yaml:"clusters,omitempty" json:"clusters,omitempty"`
+	ClusterLabel string   `yaml:"clusterLabel,omitempty" json:"clusterLabel,omitempty"`
+}
+
+// validate checks that the clusters are Loki datasources of datasources
+func (c FederationConfig) validate(field string, datasources []DatasourceConfig) ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+
+	lokiDatasources := map[string]bool{}
+	for _, ds := range datasources {
+		lokiDatasources[ds.Name] = ds.isLoki()
+	}
+
+	if len(c.Clusters) == 0 {
+		errs = append(errs, ConfigValidationError{Field: field + ".clusters", Message: "at least one cluster is required"})
+	}
+	seen := map[string]bool{}
+	for i, cluster := range c.Clusters {
+		clusterField := fmt.Sprintf("%s.clusters[%d]", field, i)
+		isLoki, found := lokiDatasources[cluster]
+		switch {
+		case !found:
+			errs = append(errs, ConfigValidationError{Field: clusterField, Message: fmt.Sprintf("unknown datasource %q", cluster)})
+		case !isLoki:
+			errs = append(errs, ConfigValidationError{Field: clusterField, Message: fmt.Sprintf("datasource %s is not a Loki datasource", cluster)})
+		case seen[cluster]:
+			errs = append(errs, ConfigValidationError{Field: clusterField, Message: fmt.Sprintf("duplicate cluster %q", cluster)})
+		}
+		seen[cluster] = true
+	}
+
+	if c.ClusterLabel != "" && !labelNameRegexp.MatchString(c.ClusterLabel) {
+		errs = append(errs, ConfigValidationError{Field: field + ".clusterLabel", Message: fmt.Sprintf("invalid label name %q", c.ClusterLabel)})
+	}
+
+	return errs
+}
+
+// newFederatedDatasource builds the datasource fanning the queries out to the
+// clusters of ds, each cluster is queried with the settings of its datasource
+func newFederatedDatasource(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps) datasource.Datasource {
+	datasources := map[string]DatasourceConfig{}
+	for _, member := range pluginConfig.allDatasources() {
+		datasources[member.Name] = member
+	}
+
+	cfg := proxy.FederationConfig{ClusterLabel: ds.Federation.ClusterLabel, ErrorHandler: writeProxyError}
+	for _, cluster := range ds.Federation.Clusters {
+		proxyConfig, err := lokiProxyConfig(datasources[cluster], pluginConfig, deps)
+		if err != nil {
+			return unavailableDatasource{unavailableDatasourceHandler(ds, err)}
+		}
+		cfg.Members = append(cfg.Members, proxy.FederationMember{Name: cluster, Config: proxyConfig})
+	}
+	return datasource.NewFederated(cfg)
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// immutableCacheControl is set on the fingerprinted files, their content never
+// changes for a given name
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// fingerprintedFileRegexp matches the file names containing a content hash,
+// like the `<name>-chunk-<hash>.min.js` webpack chunks
+var fingerprintedFileRegexp = regexp.MustCompile(`[.-][0-9a-f]{8,}(\.min)?\.(js|css)$`)
+
+// precompressedEncodings are the encodings of the precompressed siblings built
+// with the frontend, by order of preference
+var precompressedEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{encoding: "br", extension: ".br"},
+	{encoding: "gzip", extension: ".gz"},
+}
+
+// filesHandler serves the static files of a directory, the precompressed
+// `.br` and `.gz` siblings of a file are served to the clients accepting
+// their encoding
+type filesHandler struct {
+	root         http.FileSystem
+	prefix       string
+	cacheControl string
+	fileServer   http.Handler
+	mu           sync.Mutex
+	etags        map[string]fileETag
+	// fallbackPrefixes are the path prefixes served with fallbackFile when no
+	// file matches, see withSPAFallback
+	fallbackPrefixes []string
+	fallbackFile     string
+	// devServer serves the missing files in dev mode, see withDevServer
+	devServer http.Handler
+}
+
+// fileETag is the content hash of a file, valid while the file modification
+// time and size are unchanged
+type fileETag struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+// newFilesHandler serves the files of dir under prefix, dir may list several
+// directories by priority separated by colons
+func newFilesHandler(dir string, prefix string, cacheControl string) *filesHandler {
+	return newFilesHandlerFS(newStaticFileSystem(staticDirs(dir)), prefix, cacheControl)
+}
+
+// newFilesHandlerFS serves the files of root under prefix
+func newFilesHandlerFS(root http.FileSystem, prefix string, cacheControl string) *filesHandler {
+	return &filesHandler{
+		root:         root,
+		prefix:       prefix,
+		cacheControl: cacheControl,
+		fileServer:   http.StripPrefix(prefix, http.FileServer(root)),
+		etags:        map[string]fileETag{},
+	}
+}
+
+func (h *filesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.devServer != nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) && h.missing(r.URL.Path) {
+		h.devServer.ServeHTTP(w, r)
+		return
+	}
+
+	if h.spaFallback(r) {
+		h.serveSPAFallback(w, r)
+		return
+	}
+
+	if h.cacheControl != "" {
+		w.Header().Set("Cache-Control", h.cacheControl)
+	} else if fingerprintedFileRegexp.MatchString(r.URL.Path) {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	}
+
+	name, compressedName, encoding, ok := h.precompressed(r)
+	if !ok {
+		// the file server answers the conditional requests with the ETag
+		if etag, err := h.etag(path.Clean("/" + strings.TrimPrefix(r.URL.Path, h.prefix))); err == nil && etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		h.fileServer.ServeHTTP(w, r)
+		return
+	}
+
+	file, err := h.root.Open(compressedName)
+	if err != nil {
+		h.fileServer.ServeHTTP(w, r)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		h.fileServer.ServeHTTP(w, r)
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	headers := w.Header()
+	if etag, err := h.etag(compressedName); err == nil && etag != "" {
+		headers.Set("ETag", etag)
+	}
+	headers.Set("Content-Type", contentType)
+	headers.Set("Content-Encoding", encoding)
+	headers.Add("Vary", "Accept-Encoding")
+
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
+
+// withDevServer proxies the requests of the missing files to devServer, like
+// the assets built on the fly by the webpack dev server
+func (h *filesHandler) withDevServer(devServer http.Handler) *filesHandler {
+	h.devServer = devServer
+	return h
+}
+
+// missing tells whether no file or directory matches the URL path
+func (h *filesHandler) missing(urlPath string) bool {
+	file, err := h.root.Open(path.Clean("/" + strings.TrimPrefix(urlPath, h.prefix)))
+	if err != nil {
+		return true
+	}
+	file.Close()
+	return false
+}
+
+// etag returns the content hash ETag of the file name, an empty ETag for the
+// directories
+func (h *filesHandler) etag(name string) (string, error) {
+	file, err := h.root.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return "", err
+	}
+
+	h.mu.Lock()
+	cached, found := h.etags[name]
+	h.mu.Unlock()
+	if found && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.etag, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	etag := fmt.Sprintf("%q", hex.EncodeToString(hash.Sum(nil))[:32])
+
+	h.mu.Lock()
+	h.etags[name] = fileETag{modTime: info.ModTime(), size: info.Size(), etag: etag}
+	h.mu.Unlock()
+
+	return etag, nil
+}
+
+// precompressed returns the requested file name, the name and encoding of its
+// preferred precompressed sibling accepted by the client
+func (h *filesHandler) precompressed(r *http.Request) (string, string, string, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "", "", "", false
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, h.prefix)
+	if name == "" || strings.HasSuffix(name, "/") {
+		return "", "", "", false
+	}
+	name = path.Clean("/" + name)
+
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	for _, precompressed := range precompressedEncodings {
+		if !accepted[precompressed.encoding] {
+			continue
+		}
+		compressedName := name + precompressed.extension
+		file, err := h.root.Open(compressedName)
+		if err != nil {
+			continue
+		}
+		info, err := file.Stat()
+		file.Close()
+		if err == nil && !info.IsDir() {
+			return name, compressedName, precompressed.encoding, true
+		}
+	}
+
+	return "", "", "", false
+}
+
+// acceptedEncodings parses an Accept-Encoding header, the encodings with a
+// zero quality are not accepted
+func acceptedEncodings(header string) map[string]bool {
+	accepted := map[string]bool{}
+
+	for _, value := range strings.Split(header, ",") {
+		encoding, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+		if encoding == "" {
+			continue
+		}
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") && strings.Trim(params[2:], "0.") == "" {
+			continue
+		}
+		accepted[strings.ToLower(encoding)] = true
+	}
+
+	return accepted
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesHandlerPrecompressed(t *testing.T) {
+	staticDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "plugin-entry.js"), []byte("plain"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "plugin-entry.js.br"), []byte("brotli"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "plugin-entry.js.gz"), []byte("gzip"), 0600))
+
+	pluginConfig, err := newReloadingPluginConfig("")
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{StaticPath: staticDir}, pluginConfig, routeDeps{})
+	router.Use(compressionMiddleware(pluginConfig.get().Compression))
+
+	tests := []struct {
+		name             string
+		acceptEncoding   string
+		expectedEncoding string
+		expectedBody     string
+	}{
+		{name: "brotli preferred", acceptEncoding: "gzip, deflate, br", expectedEncoding: "br", expectedBody: "brotli"},
+		{name: "gzip", acceptEncoding: "gzip", expectedEncoding: "gzip", expectedBody: "gzip"},
+		{name: "brotli refused", acceptEncoding: "br;q=0, gzip", expectedEncoding: "gzip", expectedBody: "gzip"},
+		{name: "identity", expectedBody: "plain"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/plugin-entry.js", nil)
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, tc.expectedEncoding, w.Header().Get("Content-Encoding"))
+			require.Equal(t, tc.expectedBody, w.Body.String())
+			require.Contains(t, w.Header().Get("Content-Type"), "javascript")
+		})
+	}
+}
+
+func TestFilesHandlerCaching(t *testing.T) {
+	staticDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "plugin-entry.js"), []byte("entry"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "logs-chunk-0a1b2c3d4e5f6a7b8c9d.min.js"), []byte("chunk"), 0600))
+
+	handler := newFilesHandler(staticDir, "", "")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logs-chunk-0a1b2c3d4e5f6a7b8c9d.min.js", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, immutableCacheControl, w.Header().Get("Cache-Control"))
+
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	r := httptest.NewRequest(http.MethodGet, "/logs-chunk-0a1b2c3d4e5f6a7b8c9d.min.js", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	// the entry point is not fingerprinted
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plugin-entry.js", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("Cache-Control"))
+	require.NotEmpty(t, w.Header().Get("ETag"))
+	require.NotEqual(t, etag, w.Header().Get("ETag"))
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+)
+
+// severityFilters are the severities of the severity filter of the logs
+// pages, see web/src/severity.ts
+var severityFilters = map[string]bool{
+	"critical": true,
+	"error":    true,
+	"warning":  true,
+	"info":     true,
+	"debug":    true,
+	"trace":    true,
+	"unknown":  true,
+	"other":    true,
+}
+
+// validateFrontendDefaults checks the defaults of the logs pages, so that a
+// typo is reported when the config is loaded rather than as a broken page
+func (c *PluginConfig) validateFrontendDefaults() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+
+	errs = append(errs, validateDefaultQuery("defaultQuery", c.DefaultQuery)...)
+
+	seen := map[string]bool{}
+	for i, severity := range c.DefaultSeverityFilter {
+		field := fmt.Sprintf("defaultSeverityFilter[%d]", i)
+		switch {
+		case !severityFilters[severity]:
+			errs = append(errs, ConfigValidationError{Field: field, Message: fmt.Sprintf("unknown severity %q, critical, error, warning, info, debug, trace, unknown or other is expected", severity)})
+		case seen[severity]:
+			errs = append(errs, ConfigValidationError{Field: field, Message: fmt.Sprintf("duplicate severity %q", severity)})
+		}
+		seen[severity] = true
+	}
+
+	if c.DefaultTimeRange.Duration < 0 {
+		errs = append(errs, ConfigValidationError{Field: "defaultTimeRange", Message: "defaultTimeRange cannot be negative"})
+	} else if maxRange := c.Guardrails.MaxTimeRange; maxRange > 0 && c.DefaultTimeRange.Duration > maxRange {
+		errs = append(errs, ConfigValidationError{Field: "defaultTimeRange", Message: fmt.Sprintf("defaultTimeRange cannot exceed the maxTimeRange guardrail of %s", maxRange)})
+	}
+
+	if c.DefaultTenant != "" && !tenantRegexp.MatchString(c.DefaultTenant) {
+		errs = append(errs, ConfigValidationError{Field: "defaultTenant", Message: fmt.Sprintf("invalid tenant %q", c.DefaultTenant)})
+	}
+
+	return errs
+}
+
+// validateDefaultQuery checks the LogQL syntax of a default query, the logs
+// pages only run log queries
+func validateDefaultQuery(field string, query string) ConfigValidationErrors {
+	if query == "" {
+		return nil
+	}
+	parsed, err := logql.Parse(query)
+	if err != nil {
+		return ConfigValidationErrors{{Field: field, Message: fmt.Sprintf("invalid query: %s", err)}}
+	}
+	if parsed.Metric {
+		return ConfigValidationErrors{{Field: field, Message: "a log query is expected, not a metric query"}}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrontendDefaults(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(`
+defaultQuery: '{log_type="application"} |= "error"'
+defaultSeverityFilter: [critical, error]
+defaultTimeRange: 15m
+defaultTenant: infrastructure
+`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"critical", "error"}, pluginConfig.DefaultSeverityFilter)
+	require.Equal(t, 15*time.Minute, pluginConfig.DefaultTimeRange.Duration)
+
+	// the defaults are served at /config
+	payload, err := json.Marshal(pluginConfig)
+	require.NoError(t, err)
+	served := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(payload, &served))
+	require.Equal(t, []interface{}{"critical", "error"}, served["defaultSeverityFilter"])
+	require.Equal(t, "15m0s", served["defaultTimeRange"])
+	require.Equal(t, "infrastructure", served["defaultTenant"])
+
+	_, err = parsePluginConfig([]byte(`
+defaultQuery: '{log_type="application"'
+defaultSeverityFilter: [error, fatal, error]
+defaultTimeRange: 48h
+defaultTenant: 'infra structure'
+guardrails:
+  maxTimeRange: 24h
+tenants:
+  audit:
+    defaultQuery: 'rate({log_type="audit"}[5m])'
+`))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "tenants.audit.defaultQuery", Message: "a log query is expected, not a metric query"},
+		{Field: "defaultQuery", Message: "invalid query: syntax error at line 1, column 24: expected , or }, found end of query"},
+		{Field: "defaultSeverityFilter[1]", Message: `unknown severity "fatal", critical, error, warning, info, debug, trace, unknown or other is expected`},
+		{Field: "defaultSeverityFilter[2]", Message: `duplicate severity "error"`},
+		{Field: "defaultTimeRange", Message: "defaultTimeRange cannot exceed the maxTimeRange guardrail of 24h0m0s"},
+		{Field: "defaultTenant", Message: `invalid tenant "infra structure"`},
+	}, err)
+}
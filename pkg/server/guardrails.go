@@ -0,0 +1,192 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// lokiDefaultLimit is the limit of the Loki queries sent without one
+const lokiDefaultLimit = 100
+
+// GuardrailsConfig bounds the queries sent to the datasources, the requests
+// exceeding a guardrail are rejected with a GuardrailExceeded error. The
+// logsLimit of the plugin config and its tenant overrides also bound the
+// limit of the queries. The unset guardrails are disabled
+type GuardrailsConfig struct {
+	// MaxTimeRange bounds the time range of the queries and the ranges of
+	// their range aggregations, like [5m]
+	MaxTimeRange time.Duration `yaml:"maxTimeRange,omitempty" json:"maxTimeRange,omitempty"`
+	// MaxRegexComplexity bounds the size of the parsed regular expressions of
+	// the stream selectors and the line filters
+	MaxRegexComplexity int `yaml:"maxRegexComplexity,omitempty" json:"maxRegexComplexity,omitempty"`
+	// MaxSeries bounds the series returned by the metric queries, checked on
+	// the responses of Loki
+	MaxSeries int `yaml:"maxSeries,omitempty" json:"maxSeries,omitempty"`
+	// MaxResponseSize bounds the size in bytes of the responses of Loki, so
+	// that a large response cannot exhaust the memory of the plugin
+	MaxResponseSize int `yaml:"maxResponseSize,omitempty" json:"maxResponseSize,omitempty"`
+}
+
+func (c GuardrailsConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.MaxTimeRange < 0 {
+		errs = append(errs, ConfigValidationError{Field: "guardrails.maxTimeRange", Message: "maxTimeRange cannot be negative"})
+	}
+	if c.MaxRegexComplexity < 0 {
+		errs = append(errs, ConfigValidationError{Field: "guardrails.maxRegexComplexity", Message: "maxRegexComplexity cannot be negative"})
+	}
+	if c.MaxSeries < 0 {
+		errs = append(errs, ConfigValidationError{Field: "guardrails.maxSeries", Message: "maxSeries cannot be negative"})
+	}
+	if c.MaxResponseSize < 0 {
+		errs = append(errs, ConfigValidationError{Field: "guardrails.maxResponseSize", Message: "maxResponseSize cannot be negative"})
+	}
+	return errs
+}
+
+// guardrailsMiddleware rejects the queries exceeding the guardrails of the
+// plugin config before they reach the datasource. It serves the proxy, tail
+// and metadata handlers, whose paths start with the tenant
+func guardrailsMiddleware(pluginConfig *PluginConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			cfg := pluginConfig.forTenant(tenant)
+
+			params := r.URL.Query()
+			if err := checkGuardrails(cfg, params, time.Now()); err != nil {
+				writeProxyError(w, r, err)
+				return
+			}
+
+			// the queries without a limit get the one of Loki, lower it to
+			// logsLimit
+			isQuery := endpoint == "" || endpoint == "loki/api/v1/query" || endpoint == "loki/api/v1/query_range"
+			if isQuery && params.Get("limit") == "" && cfg.LogsLimit > 0 && cfg.LogsLimit < lokiDefaultLimit {
+				params.Set("limit", strconv.Itoa(cfg.LogsLimit))
+				r = r.Clone(r.Context())
+				r.URL.RawQuery = params.Encode()
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkGuardrails returns the error of the first guardrail of cfg exceeded by
+// the query parameters, nil when none is
+func checkGuardrails(cfg *PluginConfig, params url.Values, now time.Time) *proxy.Error {
+	if value := params.Get("limit"); value != "" && cfg.LogsLimit > 0 {
+		if limit, err := strconv.Atoi(value); err == nil && limit > cfg.LogsLimit {
+			return guardrailError("logsLimit", strconv.Itoa(cfg.LogsLimit), value,
+				fmt.Sprintf("the limit %d is greater than the logsLimit guardrail of %d", limit, cfg.LogsLimit))
+		}
+	}
+
+	guardrails := cfg.Guardrails
+	if maxRange := guardrails.MaxTimeRange; maxRange > 0 && params.Get("start") != "" {
+		start, startErr := proxy.ParseTime(params.Get("start"))
+		end := now
+		var endErr error
+		if value := params.Get("end"); value != "" {
+			end, endErr = proxy.ParseTime(value)
+		}
+		if startErr == nil && endErr == nil && end.Sub(start) > maxRange {
+			return guardrailError("maxTimeRange", maxRange.String(), end.Sub(start).String(),
+				fmt.Sprintf("the time range of %s is longer than the maxTimeRange guardrail of %s", end.Sub(start), maxRange))
+		}
+	}
+
+	if guardrails.MaxTimeRange <= 0 && guardrails.MaxRegexComplexity <= 0 {
+		return nil
+	}
+
+	queries := append(append([]string{}, params["query"]...), params["match[]"]...)
+	for _, query := range queries {
+		parsed, err := logql.Parse(query)
+		if err != nil {
+			// the guardrails cannot be checked, Loki would reject the query
+			// anyway
+			return &proxy.Error{Status: http.StatusBadRequest, Code: errorCodeInvalidRequest, Message: "cannot parse query", Err: err}
+		}
+
+		if maxRange := guardrails.MaxTimeRange; maxRange > 0 {
+			for _, logRange := range parsed.Ranges {
+				if logRange > maxRange {
+					return guardrailError("maxTimeRange", maxRange.String(), logRange.String(),
+						fmt.Sprintf("the range [%s] of the query is longer than the maxTimeRange guardrail of %s", logRange, maxRange))
+				}
+			}
+		}
+
+		if maxComplexity := guardrails.MaxRegexComplexity; maxComplexity > 0 {
+			for _, regex := range queryRegexes(parsed) {
+				if complexity := regexComplexity(regex); complexity > maxComplexity {
+					return guardrailError("maxRegexComplexity", strconv.Itoa(maxComplexity), strconv.Itoa(complexity),
+						fmt.Sprintf("the regular expression %q has a complexity of %d, more than the maxRegexComplexity guardrail of %d", regex, complexity, maxComplexity))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func guardrailError(guardrail string, limit string, value string, message string) *proxy.Error {
+	return &proxy.Error{
+		Status:  http.StatusBadRequest,
+		Code:    proxy.GuardrailExceededCode,
+		Message: message,
+		Details: proxy.GuardrailViolation{Guardrail: guardrail, Limit: limit, Value: value},
+	}
+}
+
+// queryRegexes returns the regular expressions of the stream selectors and
+// the line filters of a query
+func queryRegexes(query *logql.Query) []string {
+	regexes := []string{}
+	for _, selector := range query.Selectors {
+		for _, m := range selector.Matchers {
+			if m.Operator == "=~" || m.Operator == "!~" {
+				regexes = append(regexes, m.Value)
+			}
+		}
+		for _, filter := range selector.LineFilters {
+			if filter.Operator == "|~" || filter.Operator == "!~" {
+				regexes = append(regexes, filter.Values...)
+			}
+		}
+	}
+	return regexes
+}
+
+// regexComplexity returns the number of nodes of the parsed regular
+// expression, the bounded repetitions count their repeated nodes. The invalid
+// ones are left to Loki and count 0
+func regexComplexity(regex string) int {
+	re, err := syntax.Parse(regex, syntax.Perl)
+	if err != nil {
+		return 0
+	}
+
+	var count func(re *syntax.Regexp) int
+	count = func(re *syntax.Regexp) int {
+		n := 1
+		for _, sub := range re.Sub {
+			n += count(sub)
+		}
+		if re.Op == syntax.OpRepeat && re.Max > 1 {
+			n *= re.Max
+		}
+		return n
+	}
+	return count(re)
+}
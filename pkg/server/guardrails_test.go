@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardrailsMiddleware(t *testing.T) {
+	pluginConfig := &PluginConfig{
+		LogsLimit: 50,
+		Tenants:   map[string]TenantConfig{"audit": {LogsLimit: 500}},
+		Guardrails: GuardrailsConfig{
+			MaxTimeRange:       24 * time.Hour,
+			MaxRegexComplexity: 20,
+		},
+	}
+
+	var forwarded *http.Request
+	handler := guardrailsMiddleware(pluginConfig)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r
+	}))
+
+	now := time.Now()
+	tests := []struct {
+		name              string
+		path              string
+		params            url.Values
+		expectedGuardrail string
+		expectedCode      string
+		expectedLimit     string
+	}{
+		{
+			name:          "query under the guardrails",
+			path:          "/application/loki/api/v1/query_range",
+			params:        url.Values{"query": {`{app=~"foo|bar"} |~ "err(or)?"`}, "limit": {"50"}, "start": {now.Add(-time.Hour).Format(time.RFC3339)}},
+			expectedLimit: "50",
+		},
+		{
+			name:          "query without limit",
+			path:          "/application/loki/api/v1/query_range",
+			params:        url.Values{"query": {`{app="foo"}`}},
+			expectedLimit: "50",
+		},
+		{
+			name:              "limit over logsLimit",
+			path:              "/application/loki/api/v1/query_range",
+			params:            url.Values{"query": {`{app="foo"}`}, "limit": {"5000"}},
+			expectedGuardrail: "logsLimit",
+		},
+		{
+			name:          "tenant logsLimit",
+			path:          "/audit/loki/api/v1/query_range",
+			params:        url.Values{"query": {`{app="foo"}`}, "limit": {"500"}},
+			expectedLimit: "500",
+		},
+		{
+			name:              "tail limit over logsLimit",
+			path:              "/application",
+			params:            url.Values{"query": {`{app="foo"}`}, "limit": {"5000"}},
+			expectedGuardrail: "logsLimit",
+		},
+		{
+			name:              "time range",
+			path:              "/application/loki/api/v1/query_range",
+			params:            url.Values{"query": {`{app="foo"}`}, "start": {now.Add(-48 * time.Hour).Format(time.RFC3339)}, "end": {now.Format(time.RFC3339)}},
+			expectedGuardrail: "maxTimeRange",
+		},
+		{
+			name:              "time range without end",
+			path:              "/application/loki/api/v1/labels",
+			params:            url.Values{"start": {now.Add(-48 * time.Hour).Format(time.RFC3339)}},
+			expectedGuardrail: "maxTimeRange",
+		},
+		{
+			name:              "range aggregation",
+			path:              "/application/loki/api/v1/query",
+			params:            url.Values{"query": {`sum(count_over_time({app="foo"}[7d]))`}},
+			expectedGuardrail: "maxTimeRange",
+		},
+		{
+			name:              "stream selector regex",
+			path:              "/application/loki/api/v1/series",
+			params:            url.Values{"match[]": {`{app=~"(a|b){10}"}`}},
+			expectedGuardrail: "maxRegexComplexity",
+		},
+		{
+			name:              "line filter regex",
+			path:              "/application/loki/api/v1/query_range",
+			params:            url.Values{"query": {`{app="foo"} |~ "` + "(a+b+c+d+e+f+g+h+i+j+k+)" + `"`}},
+			expectedGuardrail: "maxRegexComplexity",
+		},
+		{
+			name:         "invalid query",
+			path:         "/application/loki/api/v1/query_range",
+			params:       url.Values{"query": {`{app="foo"`}},
+			expectedCode: errorCodeInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarded = nil
+			r := httptest.NewRequest(http.MethodGet, tt.path+"?"+tt.params.Encode(), nil)
+			r.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if tt.expectedGuardrail == "" && tt.expectedCode == "" {
+				require.NotNil(t, forwarded, w.Body.String())
+				require.Equal(t, tt.expectedLimit, forwarded.URL.Query().Get("limit"))
+				return
+			}
+
+			require.Nil(t, forwarded)
+			require.Equal(t, http.StatusBadRequest, w.Code)
+
+			if tt.expectedCode != "" {
+				require.Contains(t, w.Body.String(), `"code":"`+tt.expectedCode+`"`)
+				return
+			}
+
+			response := struct {
+				Error struct {
+					Code    string `json:"code"`
+					Details struct {
+						Guardrail string `json:"guardrail"`
+					} `json:"details"`
+				} `json:"error"`
+			}{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Equal(t, "GuardrailExceeded", response.Error.Code)
+			require.Equal(t, tt.expectedGuardrail, response.Error.Details.Guardrail)
+		})
+	}
+}
+
+func TestRegexComplexity(t *testing.T) {
+	require.Equal(t, 1, regexComplexity("foo"))
+	require.Less(t, regexComplexity("foo|bar"), regexComplexity("(foo|bar){5}"))
+	require.Zero(t, regexComplexity("(foo"))
+}
+
+func TestGuardrailsValidation(t *testing.T) {
+	errs := GuardrailsConfig{MaxTimeRange: -time.Hour, MaxRegexComplexity: -1, MaxSeries: -1}.validate()
+	require.Len(t, errs, 3)
+	require.Equal(t, "guardrails.maxTimeRange", errs[0].Field)
+	require.Equal(t, "guardrails.maxRegexComplexity", errs[1].Field)
+	require.Equal(t, "guardrails.maxSeries", errs[2].Field)
+}
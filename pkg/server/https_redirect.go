@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// httpsRedirectHandler redirects the plain HTTP requests to the same host and
+// path on the HTTPS port, for the console proxies and probes configured with
+// the wrong scheme
+func httpsRedirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if host == "" {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "the request has no host to redirect to", nil)
+			return
+		}
+
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			host = "[" + host + "]"
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// redirectAddress returns the address of the HTTP redirect listener, on the
+// host of the HTTPS listener address and the redirect port
+func redirectAddress(cfg *Config, addr string) (string, error) {
+	if cfg.ListenSocket != "" {
+		return "", fmt.Errorf("the HTTP redirect port cannot be used with the listen socket")
+	}
+	if cfg.HTTPRedirectPort < 1 || cfg.HTTPRedirectPort > 65535 {
+		return "", fmt.Errorf("invalid HTTP redirect port %d, it must be between 1 and 65535", cfg.HTTPRedirectPort)
+	}
+	if cfg.HTTPRedirectPort == cfg.Port {
+		return "", fmt.Errorf("the HTTP redirect port must differ from the HTTPS port %d", cfg.Port)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(cfg.HTTPRedirectPort)), nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		port     int
+		host     string
+		target   string
+		expected string
+	}{
+		{port: 9443, host: "plugin.svc:9080", target: "/api/proxy/loki?query=%7B%7D", expected: "https://plugin.svc:9443/api/proxy/loki?query=%7B%7D"},
+		{port: 9443, host: "plugin.svc", target: "/health", expected: "https://plugin.svc:9443/health"},
+		{port: 443, host: "logs.example.com:80", target: "/", expected: "https://logs.example.com/"},
+		{port: 9443, host: "[fd00::1]:9080", target: "/", expected: "https://[fd00::1]:9443/"},
+		{port: 443, host: "[fd00::1]:80", target: "/", expected: "https://[fd00::1]/"},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodPost, tc.target, nil)
+		req.Host = tc.host
+		rr := httptest.NewRecorder()
+
+		httpsRedirectHandler(tc.port).ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusMovedPermanently, rr.Code, tc.host)
+		require.Equal(t, tc.expected, rr.Header().Get("Location"), tc.host)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = ""
+	rr := httptest.NewRecorder()
+	httpsRedirectHandler(9443).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestRedirectAddress(t *testing.T) {
+	addr, err := redirectAddress(&Config{Port: 9443, HTTPRedirectPort: 9080}, "[fd00::1]:9443")
+	require.NoError(t, err)
+	require.Equal(t, "[fd00::1]:9080", addr)
+
+	addr, err = redirectAddress(&Config{Port: 9443, HTTPRedirectPort: 9080}, ":9443")
+	require.NoError(t, err)
+	require.Equal(t, ":9080", addr)
+
+	_, err = redirectAddress(&Config{Port: 9443, HTTPRedirectPort: 9443}, ":9443")
+	require.Error(t, err)
+
+	_, err = redirectAddress(&Config{Port: 9443, HTTPRedirectPort: 70000}, ":9443")
+	require.Error(t, err)
+
+	_, err = redirectAddress(&Config{ListenSocket: "/run/plugin/plugin.sock", HTTPRedirectPort: 9080}, "/run/plugin/plugin.sock")
+	require.Error(t, err)
+}
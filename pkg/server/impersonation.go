@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+const serviceAccountUserPrefix = "system:serviceaccount:"
+
+// impersonator checks that the users impersonating another user with the
+// Impersonate-User and Impersonate-Group headers, like the console admins in
+// the "Impersonate user" mode, are allowed to impersonate them
+type impersonator struct {
+	users           *authz.Authorizer
+	groups          *authz.Authorizer
+	serviceAccounts *authz.Authorizer
+}
+
+func newImpersonator(reviewer authz.Reviewer) *impersonator {
+	return &impersonator{
+		users:           authz.New(reviewer, kube.ResourceAttributes{Verb: "impersonate", Resource: "users"}),
+		groups:          authz.New(reviewer, kube.ResourceAttributes{Verb: "impersonate", Resource: "groups"}),
+		serviceAccounts: authz.New(reviewer, kube.ResourceAttributes{Verb: "impersonate", Resource: "serviceaccounts"}),
+	}
+}
+
+type impersonatorKey struct{}
+
+// requestImpersonator returns the authenticated user impersonating the user
+// of the request, if any
+func requestImpersonator(r *http.Request) (*kube.UserInfo, bool) {
+	user, ok := r.Context().Value(impersonatorKey{}).(*kube.UserInfo)
+	return user, ok
+}
+
+// impersonate returns the user impersonated by the headers of r, nil when r
+// does not impersonate a user. The error is an *proxy.Error when user is not
+// allowed to impersonate them
+func (i *impersonator) impersonate(ctx context.Context, user *kube.UserInfo, r *http.Request) (*kube.UserInfo, error) {
+	username := r.Header.Get(proxy.ImpersonateUserHeader)
+	groups := r.Header.Values(proxy.ImpersonateGroupHeader)
+	if username == "" {
+		if len(groups) > 0 {
+			return nil, &proxy.Error{Status: http.StatusBadRequest, Code: errorCodeInvalidRequest, Message: "impersonating groups requires impersonating a user"}
+		}
+		return nil, nil
+	}
+
+	allowed, err := i.allowedUser(ctx, user, username)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, &proxy.Error{Status: http.StatusForbidden, Code: errorCodeForbidden, Message: fmt.Sprintf("user %s cannot impersonate user %s", user.Username, username)}
+	}
+
+	for _, group := range groups {
+		allowed, err := i.groups.AuthorizeName(ctx, user, "", group)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, &proxy.Error{Status: http.StatusForbidden, Code: errorCodeForbidden, Message: fmt.Sprintf("user %s cannot impersonate group %s", user.Username, group)}
+		}
+	}
+
+	// like the API server, the impersonated users are authenticated
+	impersonated := &kube.UserInfo{Username: username, Groups: append([]string{}, groups...)}
+	if username != "system:anonymous" {
+		impersonated.Groups = append(impersonated.Groups, "system:authenticated")
+	}
+	return impersonated, nil
+}
+
+// allowedUser returns true when user can impersonate username, the service
+// accounts are impersonated in their namespace
+func (i *impersonator) allowedUser(ctx context.Context, user *kube.UserInfo, username string) (bool, error) {
+	if serviceAccount := strings.TrimPrefix(username, serviceAccountUserPrefix); serviceAccount != username {
+		namespace, name, found := strings.Cut(serviceAccount, ":")
+		if found {
+			return i.serviceAccounts.AuthorizeName(ctx, user, namespace, name)
+		}
+	}
+	return i.users.AuthorizeName(ctx, user, "", username)
+}
+
+func writeImpersonationError(w http.ResponseWriter, r *http.Request, err error) {
+	var proxyErr *proxy.Error
+	if errors.As(err, &proxyErr) {
+		writeError(w, r, proxyErr.Status, proxyErr.Code, proxyErr.Message, nil)
+		return
+	}
+	requestLog(slog, r).WithError(err).Error("cannot review impersonation")
+	writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "cannot authorize the impersonation", nil)
+}
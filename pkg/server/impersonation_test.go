@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeImpersonationReviewer allows the impersonation of the listed
+// "<resource>/<namespace>/<name>"
+type fakeImpersonationReviewer struct {
+	allowed map[string]bool
+}
+
+func (f *fakeImpersonationReviewer) ReviewAccess(_ context.Context, spec kube.SubjectAccessReviewSpec) (*kube.SubjectAccessReviewStatus, error) {
+	attributes := spec.ResourceAttributes
+	key := strings.Join([]string{attributes.Resource, attributes.Namespace, attributes.Name}, "/")
+	return &kube.SubjectAccessReviewStatus{Allowed: spec.User == "developer" && attributes.Verb == "impersonate" && f.allowed[key]}, nil
+}
+
+func TestImpersonation(t *testing.T) {
+	authenticator := newTokenAuthenticator(&fakeTokenReviewer{})
+	authenticator.impersonator = newImpersonator(&fakeImpersonationReviewer{allowed: map[string]bool{
+		"users//alice":                    true,
+		"groups//devs":                    true,
+		"serviceaccounts/my-app/deployer": true,
+	}})
+	handler := authenticationMiddleware(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := requestUser(r)
+		impersonator, ok := requestImpersonator(r)
+		if ok {
+			w.Write([]byte(impersonator.Username + " as "))
+		}
+		w.Write([]byte(user.Username + " " + strings.Join(user.Groups, ",")))
+	}))
+
+	tests := []struct {
+		name           string
+		user           string
+		groups         []string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "no impersonation", expectedStatus: http.StatusOK, expectedBody: "developer "},
+		{name: "allowed user", user: "alice", expectedStatus: http.StatusOK, expectedBody: "developer as alice system:authenticated"},
+		{name: "allowed user and group", user: "alice", groups: []string{"devs"}, expectedStatus: http.StatusOK, expectedBody: "developer as alice devs,system:authenticated"},
+		{name: "allowed service account", user: "system:serviceaccount:my-app:deployer", expectedStatus: http.StatusOK, expectedBody: "developer as system:serviceaccount:my-app:deployer system:authenticated"},
+		{name: "denied user", user: "bob", expectedStatus: http.StatusForbidden},
+		{name: "denied group", user: "alice", groups: []string{"devs", "admins"}, expectedStatus: http.StatusForbidden},
+		{name: "denied service account", user: "system:serviceaccount:other:deployer", expectedStatus: http.StatusForbidden},
+		{name: "group without user", groups: []string{"devs"}, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/config", nil)
+			r.Header.Set("Authorization", "Bearer valid")
+			if tc.user != "" {
+				r.Header.Set(proxy.ImpersonateUserHeader, tc.user)
+			}
+			for _, group := range tc.groups {
+				r.Header.Add(proxy.ImpersonateGroupHeader, group)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedBody != "" {
+				require.Equal(t, tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestImpersonationDisabled(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(""))
+	require.NoError(t, err)
+	require.False(t, pluginConfig.AllowImpersonation)
+
+	// without the impersonator the headers are ignored
+	handler := authenticationMiddleware(newTokenAuthenticator(&fakeTokenReviewer{}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := requestUser(r)
+		_, impersonated := requestImpersonator(r)
+		require.False(t, impersonated)
+		w.Write([]byte(user.Username))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/config", nil)
+	r.Header.Set("Authorization", "Bearer valid")
+	r.Header.Set(proxy.ImpersonateUserHeader, "alice")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "developer", w.Body.String())
+}
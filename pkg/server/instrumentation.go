@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/mux"
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// instrumentationMiddleware records the request metrics labeled with the
+// matched route template to keep the cardinality bounded
+func instrumentationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+
+		setAccessLogRoute(r, route)
+
+		metrics.RequestsInFlight.Inc()
+		defer metrics.RequestsInFlight.Dec()
+
+		m := httpsnoop.CaptureMetrics(next, w, r)
+
+		metrics.RequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(m.Code)).Inc()
+		metrics.RequestDuration.WithLabelValues(route, r.Method).Observe(m.Duration.Seconds())
+	})
+}
+
+// routeTemplate returns the path template of the route matched by r
+func routeTemplate(r *http.Request) string {
+	if currentRoute := mux.CurrentRoute(r); currentRoute != nil {
+		if template, err := currentRoute.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentationMiddleware(t *testing.T) {
+	pluginConfig, err := newReloadingPluginConfig("")
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{}, pluginConfig, routeDeps{})
+	router.Use(instrumentationMiddleware)
+
+	before := testutil.ToFloat64(metrics.RequestsTotal.WithLabelValues("/features", http.MethodGet, "200"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/features", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.Equal(t, before+1, testutil.ToFloat64(metrics.RequestsTotal.WithLabelValues("/features", http.MethodGet, "200")))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `logging_view_plugin_http_requests_total{code="200",method="GET",route="/features"}`)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// registerInternalRoutes registers the probes and the metrics, on the main
+// router or on the router of the internal listener. The metrics require the
+// token of metricsToken when set
+func registerInternalRoutes(r *mux.Router, cfg *Config, pluginConfig *PluginConfig, deps routeDeps) {
+	// liveness and readiness probes, registered before the /health prefix
+	r.Path("/healthz").HandlerFunc(healthHandler(deps.watchdog))
+	r.Path("/readyz").HandlerFunc(readinessHandler(newReadinessChecker(cfg, pluginConfig, deps.breakers, deps.certificates)))
+
+	r.PathPrefix("/health").HandlerFunc(healthHandler(deps.watchdog))
+
+	// serve prometheus metrics
+	r.Path("/metrics").Handler(metricsTokenMiddleware(deps.metricsToken)(metrics.Handler()))
+}
+
+// metricsTokenMiddleware rejects the requests without the bearer token of
+// tokenFile, the file is read again when it changes. It does nothing when
+// tokenFile is nil
+func metricsTokenMiddleware(tokenFile *kube.TokenFile) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if tokenFile == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			expected, err := tokenFile.Token()
+			if err != nil {
+				requestLog(slog, r).WithError(err).Error("cannot read the metrics token")
+				writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "cannot authenticate the request", nil)
+				return
+			}
+
+			scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+				writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "the metrics token is required", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// internalAddress returns the address of the internal listener, on the host
+// of the main address addr, or on every interface with the listen socket
+func internalAddress(cfg *Config, addr string) (string, error) {
+	if cfg.InternalPort < 1 || cfg.InternalPort > 65535 {
+		return "", fmt.Errorf("invalid internal port %d, it must be between 1 and 65535", cfg.InternalPort)
+	}
+	if cfg.ListenSocket != "" {
+		return net.JoinHostPort("", strconv.Itoa(cfg.InternalPort)), nil
+	}
+	if cfg.InternalPort == cfg.Port || cfg.InternalPort == cfg.HTTPRedirectPort {
+		return "", fmt.Errorf("the internal port %d must differ from the main and HTTP redirect ports", cfg.InternalPort)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(cfg.InternalPort)), nil
+}
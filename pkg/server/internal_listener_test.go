@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsTokenMiddleware(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("scraper\n"), 0600))
+	handler := metricsTokenMiddleware(kube.NewTokenFile(tokenPath))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("metrics"))
+	}))
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "missing token", expectedStatus: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer other", expectedStatus: http.StatusUnauthorized},
+		{name: "token", authorization: "Bearer scraper", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			require.Equal(t, tc.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestInternalAddress(t *testing.T) {
+	addr, err := internalAddress(&Config{Port: 9443, InternalPort: 9090}, "[fd00::1]:9443")
+	require.NoError(t, err)
+	require.Equal(t, "[fd00::1]:9090", addr)
+
+	addr, err = internalAddress(&Config{ListenSocket: "/run/plugin/plugin.sock", InternalPort: 9090}, "/run/plugin/plugin.sock")
+	require.NoError(t, err)
+	require.Equal(t, ":9090", addr)
+
+	_, err = internalAddress(&Config{Port: 9443, InternalPort: 9443}, ":9443")
+	require.Error(t, err)
+
+	_, err = internalAddress(&Config{Port: 9443, HTTPRedirectPort: 9080, InternalPort: 9080}, ":9443")
+	require.Error(t, err)
+
+	_, err = internalAddress(&Config{Port: 9443, InternalPort: 70000}, ":9443")
+	require.Error(t, err)
+}
+
+func TestServerInternalPort(t *testing.T) {
+	port, err := getFreePort(testHostname)
+	require.NoError(t, err)
+	internalPort, err := getFreePort(testHostname)
+	require.NoError(t, err)
+
+	tmpDir := prepareServerAssets(t)
+	defer os.RemoveAll(tmpDir)
+
+	s, err := New(&Config{Address: testHostname, Port: port, InternalPort: internalPort, ShutdownTimeout: time.Second})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- s.Run(ctx)
+	}()
+	<-s.Ready()
+
+	get := func(port int, path string) int {
+		resp, err := http.Get(fmt.Sprintf("http://%s:%d%s", testHostname, port, path))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// the probes and the metrics are only served by the internal listener
+	require.Equal(t, http.StatusOK, get(internalPort, "/healthz"))
+	require.Equal(t, http.StatusOK, get(internalPort, "/metrics"))
+	require.Equal(t, http.StatusNotFound, get(internalPort, "/features"))
+	require.Equal(t, http.StatusNotFound, get(port, "/metrics"))
+	require.Equal(t, http.StatusOK, get(port, "/features"))
+
+	http.DefaultClient.CloseIdleConnections()
+	cancel()
+	require.NoError(t, <-serverErr)
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+const (
+	// featureKorrel8r serves the korrel8r API at /api/korrel8r/
+	featureKorrel8r = "korrel8r"
+	// korrel8rAPIPrefix is the path prefix of the proxied korrel8r endpoints
+	korrel8rAPIPrefix = "/api/v1alpha1/"
+	// maxKorrel8rRequestSize bounds the size of the correlation requests
+	maxKorrel8rRequestSize = 1 << 20
+)
+
+// korrel8rForwardedHeaders are the request headers sent to korrel8r, which
+// queries the cluster stores with the bearer token of the user
+var korrel8rForwardedHeaders = []string{
+	"Authorization",
+	"Accept",
+	"Content-Type",
+	proxy.RequestIDHeader,
+}
+
+// Korrel8rConfig is the korrel8r service correlating the log lines with other
+// cluster signals
+type Korrel8rConfig struct {
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+	// CAFile is the PEM bundle verifying the korrel8r certificate, the system
+	// roots are used when unset
+	CAFile string `yaml:"caFile,omitempty" json:"caFile,omitempty"`
+}
+
+func (c Korrel8rConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.URL == "" {
+		return errs
+	}
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, ConfigValidationError{Field: "korrel8r.url", Message: fmt.Sprintf("invalid URL %q, an absolute http or https URL is expected", c.URL)})
+	}
+	return errs
+}
+
+// korrel8rHandler proxies the korrel8r API requests with the
+// /api/v1alpha1/<endpoint> path to the configured service
+func korrel8rHandler(cfg Korrel8rConfig) http.Handler {
+	if cfg.URL == "" {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "korrel8r is not configured", nil)
+		})
+	}
+
+	// the URL is validated when the plugin config is parsed
+	korrel8rURL, _ := url.Parse(cfg.URL)
+
+	transport, err := caTransport(cfg.CAFile)
+	if err != nil {
+		slog.WithError(err).Error("korrel8r is unavailable")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "korrel8r is unavailable", err.Error())
+		})
+	}
+
+	reverseProxy := &httputil.ReverseProxy{
+		Transport: transport,
+		Director: func(r *http.Request) {
+			headers := http.Header{}
+			for _, name := range korrel8rForwardedHeaders {
+				if values := r.Header.Values(name); len(values) > 0 {
+					headers[name] = values
+				}
+			}
+
+			r.URL.Scheme = korrel8rURL.Scheme
+			r.URL.Host = korrel8rURL.Host
+			r.URL.Path = strings.TrimSuffix(korrel8rURL.Path, "/") + r.URL.Path
+			r.URL.RawPath = ""
+			r.Host = korrel8rURL.Host
+			r.Header = headers
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.StatusCode >= http.StatusInternalServerError {
+				metrics.UpstreamErrorsTotal.WithLabelValues("korrel8r", strconv.Itoa(resp.StatusCode)).Inc()
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			requestLog(slog, r).WithError(err).Warn("cannot proxy request to korrel8r")
+			metrics.UpstreamErrorsTotal.WithLabelValues("korrel8r", "unavailable").Inc()
+			writeError(w, r, http.StatusBadGateway, errorCodeUpstreamUnavailable, "cannot reach korrel8r", err.Error())
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, korrel8rAPIPrefix) || strings.Contains(r.URL.Path, "..") {
+			writeError(w, r, http.StatusNotFound, errorCodeNotFound, fmt.Sprintf("unsupported korrel8r endpoint %s", r.URL.Path), nil)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			writeError(w, r, http.StatusMethodNotAllowed, errorCodeMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method), nil)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxKorrel8rRequestSize)
+		reverseProxy.ServeHTTP(w, r)
+	})
+}
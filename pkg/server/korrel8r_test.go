@@ -0,0 +1,98 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKorrel8rHandler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + r.Header.Get("Authorization") + " " + r.Header.Get("Cookie") + string(body)))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name           string
+		cfg            Korrel8rConfig
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "domains",
+			cfg:            Korrel8rConfig{URL: upstream.URL + "/korrel8r"},
+			method:         http.MethodGet,
+			path:           "/api/v1alpha1/domains",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "GET /korrel8r/api/v1alpha1/domains Bearer user-token ",
+		},
+		{
+			name:           "neighbours",
+			cfg:            Korrel8rConfig{URL: upstream.URL},
+			method:         http.MethodPost,
+			path:           "/api/v1alpha1/graphs/neighbours",
+			body:           `{"depth":1}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `POST /api/v1alpha1/graphs/neighbours Bearer user-token {"depth":1}`,
+		},
+		{
+			name:           "unsupported endpoint",
+			cfg:            Korrel8rConfig{URL: upstream.URL},
+			method:         http.MethodGet,
+			path:           "/metrics",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "write method",
+			cfg:            Korrel8rConfig{URL: upstream.URL},
+			method:         http.MethodDelete,
+			path:           "/api/v1alpha1/domains",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "not configured",
+			method:         http.MethodGet,
+			path:           "/api/v1alpha1/domains",
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "missing CA file",
+			cfg:            Korrel8rConfig{URL: upstream.URL, CAFile: "/missing/ca.crt"},
+			method:         http.MethodGet,
+			path:           "/api/v1alpha1/domains",
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			r.Header.Set("Authorization", "Bearer user-token")
+			r.Header.Set("Cookie", "openshift-session-token=secret")
+			w := httptest.NewRecorder()
+
+			korrel8rHandler(tc.cfg).ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedBody != "" {
+				require.Equal(t, tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestKorrel8rConfigValidation(t *testing.T) {
+	_, err := parsePluginConfig([]byte("korrel8r:\n  url: korrel8r:8443"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "korrel8r.url", Message: `invalid URL "korrel8r:8443", an absolute http or https URL is expected`},
+	}, err)
+}
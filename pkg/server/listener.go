@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	IPFamilyIPv4      = "ipv4"
+	IPFamilyIPv6      = "ipv6"
+	IPFamilyDualStack = "dual"
+)
+
+const (
+	// socketMode lets the reverse proxy of the pod, running with the same
+	// group, connect to the socket
+	socketMode os.FileMode = 0660
+	// maxSocketPathLength is the size of sun_path on Linux, minus the
+	// terminating null byte
+	maxSocketPathLength = 107
+)
+
+// lookupInterface returns the network interface of an IPv6 zone, by name or
+// index, replaced in the tests
+var lookupInterface = func(zone string) (*net.Interface, error) {
+	if index, err := strconv.Atoi(zone); err == nil {
+		return net.InterfaceByIndex(index)
+	}
+	return net.InterfaceByName(zone)
+}
+
+// ParseListenAddress splits a `<ip>:<port>` listen address, IPv6 addresses
+// are bracketed and link-local ones have a zone, like `[fe80::1%eth0]:9443`
+func ParseListenAddress(value string) (string, int, error) {
+	host, portValue, err := net.SplitHostPort(value)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid listen address %q, expected <ip>:<port> or [<ipv6>]:<port>: %w", value, err)
+	}
+
+	port, err := strconv.Atoi(portValue)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid listen address %q, the port must be between 1 and 65535", value)
+	}
+
+	return host, port, nil
+}
+
+// listenAddress validates the bind address and IP family of the config and
+// returns the network and address to listen on. Without IP family, the
+// specified addresses are bound with their own family
+func listenAddress(cfg *Config) (string, string, error) {
+	if cfg.ListenSocket != "" {
+		if cfg.Address != "" || cfg.IPFamily != "" {
+			return "", "", fmt.Errorf("the listen socket cannot be used with a bind address or an IP family")
+		}
+		if len(cfg.ListenSocket) > maxSocketPathLength {
+			return "", "", fmt.Errorf("listen socket path %q is longer than %d characters", cfg.ListenSocket, maxSocketPathLength)
+		}
+		return "unix", cfg.ListenSocket, nil
+	}
+
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return "", "", fmt.Errorf("invalid port %d, it must be between 0 and 65535", cfg.Port)
+	}
+
+	host := strings.TrimSuffix(strings.TrimPrefix(cfg.Address, "["), "]")
+
+	var ip net.IP
+	if host != "" {
+		literal, zone, _ := strings.Cut(host, "%")
+		ip = net.ParseIP(literal)
+		if ip == nil {
+			return "", "", fmt.Errorf("invalid bind address %q, it must be an IPv4 or IPv6 literal", cfg.Address)
+		}
+		if err := validateZone(ip, zone); err != nil {
+			return "", "", fmt.Errorf("invalid bind address %q: %w", cfg.Address, err)
+		}
+	}
+
+	network := "tcp"
+
+	switch cfg.IPFamily {
+	case "":
+		if ip != nil && !ip.IsUnspecified() {
+			network = "tcp6"
+			if ip.To4() != nil {
+				network = "tcp4"
+			}
+		}
+	case IPFamilyDualStack:
+		if ip != nil && !ip.IsUnspecified() {
+			return "", "", fmt.Errorf("bind address %q cannot be used with the dual-stack IP family, only unspecified addresses can", cfg.Address)
+		}
+	case IPFamilyIPv4:
+		if ip != nil && ip.To4() == nil {
+			return "", "", fmt.Errorf("bind address %q is not an IPv4 address", cfg.Address)
+		}
+		network = "tcp4"
+	case IPFamilyIPv6:
+		if ip != nil && ip.To4() != nil {
+			return "", "", fmt.Errorf("bind address %q is not an IPv6 address", cfg.Address)
+		}
+		network = "tcp6"
+	default:
+		return "", "", fmt.Errorf("invalid IP family %q, expected one of %s, %s or %s", cfg.IPFamily, IPFamilyIPv4, IPFamilyIPv6, IPFamilyDualStack)
+	}
+
+	return network, net.JoinHostPort(host, strconv.Itoa(cfg.Port)), nil
+}
+
+// validateZone checks the zone of an IPv6 address: the link-local addresses
+// are bound on the interface of their zone, the other ones have none
+func validateZone(ip net.IP, zone string) error {
+	linkLocal := ip.To4() == nil && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast())
+	switch {
+	case zone == "" && linkLocal:
+		return fmt.Errorf("link-local addresses need the zone of their interface, like %s%%eth0", ip)
+	case zone == "":
+		return nil
+	case !linkLocal:
+		return fmt.Errorf("only IPv6 link-local addresses have a zone")
+	}
+
+	if _, err := lookupInterface(zone); err != nil {
+		return fmt.Errorf("unknown interface %q: %w", zone, err)
+	}
+	return nil
+}
+
+// listen listens on the address of listenAddress. The socket file left by a
+// previous process is removed, and the new one is removed when the listener
+// is closed
+func listen(network string, addr string) (net.Listener, error) {
+	if network != "unix" {
+		return net.Listen(network, addr)
+	}
+
+	if info, err := os.Lstat(addr); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("cannot listen on %s, the file exists and is not a socket", addr)
+		}
+		if err := os.Remove(addr); err != nil {
+			return nil, fmt.Errorf("cannot remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(addr, socketMode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("cannot set the socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+const (
+	// listenRetryInterval is the first wait before binding an address in use
+	// again, doubled up to maxListenRetryInterval
+	listenRetryInterval    = 100 * time.Millisecond
+	maxListenRetryInterval = 5 * time.Second
+)
+
+// ListenError is the failure to listen on the address of the server
+type ListenError struct {
+	Network string
+	Addr    string
+	// Attempts is the number of binds attempted
+	Attempts int
+	Err      error
+}
+
+func (e *ListenError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("cannot listen on %s %s after %d attempts: %s", e.Network, e.Addr, e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("cannot listen on %s %s: %s", e.Network, e.Addr, e.Err)
+}
+
+func (e *ListenError) Unwrap() error {
+	return e.Err
+}
+
+// listenWithRetry listens on addr, binding it again with a backoff for up to
+// timeout while it is in use. It stops retrying when ctx is done
+func listenWithRetry(ctx context.Context, network string, addr string, timeout time.Duration) (net.Listener, error) {
+	deadline := time.Now().Add(timeout)
+	interval := listenRetryInterval
+	for attempts := 1; ; attempts++ {
+		listener, err := listen(network, addr)
+		if err == nil {
+			return listener, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) || time.Now().Add(interval).After(deadline) {
+			return nil, &ListenError{Network: network, Addr: addr, Attempts: attempts, Err: err}
+		}
+
+		slog.WithError(err).Warnf("address %s in use, binding again in %s", addr, interval)
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, &ListenError{Network: network, Addr: addr, Attempts: attempts, Err: err}
+		}
+
+		interval *= 2
+		if interval > maxListenRetryInterval {
+			interval = maxListenRetryInterval
+		}
+	}
+}
+
+// listenerHost returns the host of the listener URLs, the unix socket path
+// is prefixed with unix:
+func listenerHost(listener net.Listener) string {
+	if listener.Addr().Network() == "unix" {
+		return "unix:" + listener.Addr().String()
+	}
+	return listener.Addr().String()
+}
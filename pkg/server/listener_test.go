@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenAddress(t *testing.T) {
+	defer func(lookup func(string) (*net.Interface, error)) { lookupInterface = lookup }(lookupInterface)
+	lookupInterface = func(zone string) (*net.Interface, error) {
+		if zone != "eth0" && zone != "2" {
+			return nil, fmt.Errorf("no such network interface")
+		}
+		return &net.Interface{Name: "eth0", Index: 2}, nil
+	}
+
+	tests := []struct {
+		cfg             Config
+		expectedNetwork string
+		expectedAddr    string
+		expectError     bool
+	}{
+		{cfg: Config{Port: 9443}, expectedNetwork: "tcp", expectedAddr: ":9443"},
+		{cfg: Config{Port: 9443, Address: "::", IPFamily: IPFamilyDualStack}, expectedNetwork: "tcp", expectedAddr: "[::]:9443"},
+		{cfg: Config{Port: 9443, Address: "10.0.0.1", IPFamily: IPFamilyIPv4}, expectedNetwork: "tcp4", expectedAddr: "10.0.0.1:9443"},
+		{cfg: Config{Port: 9443, Address: "[fd00::1]", IPFamily: IPFamilyIPv6}, expectedNetwork: "tcp6", expectedAddr: "[fd00::1]:9443"},
+		{cfg: Config{Port: 9443, Address: "fd00::1", IPFamily: IPFamilyIPv4}, expectError: true},
+		{cfg: Config{Port: 9443, Address: "10.0.0.1", IPFamily: IPFamilyIPv6}, expectError: true},
+		{cfg: Config{Port: 9443, Address: "10.0.0.1", IPFamily: IPFamilyDualStack}, expectError: true},
+		{cfg: Config{Port: 9443, Address: "localhost"}, expectError: true},
+		{cfg: Config{Port: 9443, IPFamily: "ipv5"}, expectError: true},
+		{cfg: Config{Port: 9443, Address: "10.0.0.1"}, expectedNetwork: "tcp4", expectedAddr: "10.0.0.1:9443"},
+		{cfg: Config{Port: 9443, Address: "fd00::1"}, expectedNetwork: "tcp6", expectedAddr: "[fd00::1]:9443"},
+		{cfg: Config{Port: 9443, Address: "[fe80::1%eth0]"}, expectedNetwork: "tcp6", expectedAddr: "[fe80::1%eth0]:9443"},
+		{cfg: Config{Port: 9443, Address: "fe80::1%2", IPFamily: IPFamilyIPv6}, expectedNetwork: "tcp6", expectedAddr: "[fe80::1%2]:9443"},
+		{cfg: Config{Port: 9443, Address: "fe80::1"}, expectError: true},
+		{cfg: Config{Port: 9443, Address: "fe80::1%wlan9"}, expectError: true},
+		{cfg: Config{Port: 9443, Address: "fd00::1%eth0"}, expectError: true},
+		{cfg: Config{Port: 70000}, expectError: true},
+		{cfg: Config{Port: 9443, ListenSocket: "/run/plugin/plugin.sock"}, expectedNetwork: "unix", expectedAddr: "/run/plugin/plugin.sock"},
+		{cfg: Config{ListenSocket: "/run/plugin/plugin.sock", Address: "10.0.0.1"}, expectError: true},
+		{cfg: Config{ListenSocket: "/run/" + strings.Repeat("a", 110) + ".sock"}, expectError: true},
+	}
+
+	for _, tc := range tests {
+		network, addr, err := listenAddress(&tc.cfg)
+		if tc.expectError {
+			require.Error(t, err, tc.cfg)
+			continue
+		}
+		require.NoError(t, err, tc.cfg)
+		require.Equal(t, tc.expectedNetwork, network)
+		require.Equal(t, tc.expectedAddr, addr)
+	}
+}
+
+func TestParseListenAddress(t *testing.T) {
+	tests := []struct {
+		value           string
+		expectedAddress string
+		expectedPort    int
+		expectError     bool
+	}{
+		{value: "10.0.0.1:9443", expectedAddress: "10.0.0.1", expectedPort: 9443},
+		{value: "[fd00::1]:9443", expectedAddress: "fd00::1", expectedPort: 9443},
+		{value: "[fe80::1%eth0]:9443", expectedAddress: "fe80::1%eth0", expectedPort: 9443},
+		{value: ":9443", expectedAddress: "", expectedPort: 9443},
+		{value: "fd00::1:9443", expectError: true},
+		{value: "10.0.0.1", expectError: true},
+		{value: "10.0.0.1:0", expectError: true},
+		{value: "10.0.0.1:https", expectError: true},
+	}
+
+	for _, tc := range tests {
+		address, port, err := ParseListenAddress(tc.value)
+		if tc.expectError {
+			require.Error(t, err, tc.value)
+			continue
+		}
+		require.NoError(t, err, tc.value)
+		require.Equal(t, tc.expectedAddress, address)
+		require.Equal(t, tc.expectedPort, port)
+	}
+}
+
+func TestListenSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "plugin.sock")
+
+	// a socket left by a previous process is replaced
+	stale, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	listener, err := listen("unix", socketPath)
+	require.NoError(t, err)
+	require.Equal(t, "unix:"+socketPath, listenerHost(listener))
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	require.Equal(t, socketMode, info.Mode().Perm())
+
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	client := http.Client{Transport: &http.Transport{
+		Dial: func(_, _ string) (net.Conn, error) { return net.Dial("unix", socketPath) },
+	}}
+	resp, err := client.Get("http://plugin/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, listener.Close())
+	_, err = os.Stat(socketPath)
+	require.True(t, os.IsNotExist(err))
+
+	// a regular file is not removed
+	require.NoError(t, os.WriteFile(socketPath, []byte("data"), 0600))
+	_, err = listen("unix", socketPath)
+	require.Error(t, err)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultLanguage is the last language of the fallbacks, the one every
+// translation namespace has
+const defaultLanguage = "en"
+
+var (
+	languageRegexp      = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+	i18nNamespaceRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// localesHandler serves the translation bundles of
+// <static-path>/locales/<language>/<namespace>.json, the missing languages
+// fall back to their base language, the Accept-Language ones and then
+// defaultLanguage
+type localesHandler struct {
+	files *filesHandler
+}
+
+func newLocalesHandler(root http.FileSystem) *localesHandler {
+	return &localesHandler{files: newFilesHandlerFS(root, "", "")}
+}
+
+func (h *localesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	language, namespace := vars["lng"], vars["ns"]
+	if !languageRegexp.MatchString(language) {
+		writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid language %q", language), nil)
+		return
+	}
+	if !i18nNamespaceRegexp.MatchString(namespace) || strings.HasPrefix(namespace, ".") {
+		writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid namespace %q", namespace), nil)
+		return
+	}
+
+	for _, candidate := range languageFallbacks(language, r.Header.Get("Accept-Language")) {
+		name := "/locales/" + candidate + "/" + namespace + ".json"
+		etag, err := h.files.etag(name)
+		if err != nil || etag == "" {
+			continue
+		}
+
+		file, err := h.files.root.Open(name)
+		if err != nil {
+			continue
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			continue
+		}
+		defer file.Close()
+
+		headers := w.Header()
+		headers.Set("Content-Type", "application/json")
+		headers.Set("Content-Language", candidate)
+		headers.Set("ETag", etag)
+		headers.Add("Vary", "Accept-Language")
+		// the URLs keyed by the bundle hash never change, the other ones
+		// are revalidated with the ETag
+		if r.URL.Query().Get("hash") == strings.Trim(etag, `"`) {
+			headers.Set("Cache-Control", immutableCacheControl)
+		} else {
+			headers.Set("Cache-Control", "no-cache")
+		}
+
+		http.ServeContent(w, r, name, info.ModTime(), file)
+		return
+	}
+
+	writeError(w, r, http.StatusNotFound, errorCodeNotFound, fmt.Sprintf("no %s translations for language %s", namespace, language), nil)
+}
+
+// languageFallbacks returns the languages to look for, by order: the
+// requested one and its base languages, like pt-BR and pt, the
+// Accept-Language ones and their base languages, and defaultLanguage
+func languageFallbacks(language string, acceptLanguage string) []string {
+	fallbacks := []string{}
+	seen := map[string]bool{}
+
+	add := func(language string) {
+		// the primary language is lower case, like the locales directories
+		if cut := strings.IndexAny(language, "-_"); cut >= 0 {
+			language = strings.ToLower(language[:cut]) + language[cut:]
+		} else {
+			language = strings.ToLower(language)
+		}
+		for language != "" {
+			if key := strings.ToLower(language); !seen[key] {
+				seen[key] = true
+				fallbacks = append(fallbacks, language)
+			}
+			cut := strings.LastIndexAny(language, "-_")
+			if cut < 0 {
+				break
+			}
+			language = language[:cut]
+		}
+	}
+
+	add(language)
+	for _, accepted := range acceptedLanguages(acceptLanguage) {
+		add(accepted)
+	}
+	add(defaultLanguage)
+
+	return fallbacks
+}
+
+// acceptedLanguages parses an Accept-Language header, the languages are
+// sorted by quality and the ones with a zero quality or invalid are skipped
+func acceptedLanguages(header string) []string {
+	type acceptedLanguage struct {
+		language string
+		quality  float64
+	}
+	accepted := []acceptedLanguage{}
+
+	for _, value := range strings.Split(header, ",") {
+		language, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+		if !languageRegexp.MatchString(language) {
+			continue
+		}
+
+		quality := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			q, err := strconv.ParseFloat(params[2:], 64)
+			if err != nil {
+				continue
+			}
+			quality = q
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		accepted = append(accepted, acceptedLanguage{language: language, quality: quality})
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].quality > accepted[j].quality })
+
+	languages := make([]string, 0, len(accepted))
+	for _, a := range accepted {
+		languages = append(languages, a.language)
+	}
+	return languages
+}
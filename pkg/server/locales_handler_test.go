@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguageFallbacks(t *testing.T) {
+	require.Equal(t, []string{"pt-BR", "pt", "en"}, languageFallbacks("pt-BR", ""))
+	require.Equal(t, []string{"zh-Hant-TW", "zh-Hant", "zh", "fr-CA", "fr", "en"}, languageFallbacks("zh-Hant-TW", "en;q=0.5, fr-CA, *, de;q=0"))
+	require.Equal(t, []string{"ja", "es", "en"}, languageFallbacks("ja", "EN;q=0.8, es;q=0.9"))
+}
+
+func TestLocalesHandler(t *testing.T) {
+	staticDir := t.TempDir()
+	for lng, content := range map[string]string{"en": `{"Logs":"Logs"}`, "pt": `{"Logs":"Registros"}`, "fr": `{"Logs":"Journaux"}`} {
+		require.NoError(t, os.MkdirAll(filepath.Join(staticDir, "locales", lng), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(staticDir, "locales", lng, "plugin__logging-view-plugin.json"), []byte(content), 0600))
+	}
+
+	pluginConfig, err := newReloadingPluginConfig("")
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{StaticPath: staticDir}, pluginConfig, routeDeps{})
+
+	get := func(target string, acceptLanguage string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		language         string
+		acceptLanguage   string
+		expectedLanguage string
+		expectedBody     string
+	}{
+		{language: "pt-BR", expectedLanguage: "pt", expectedBody: `{"Logs":"Registros"}`},
+		{language: "de", acceptLanguage: "de-DE, fr;q=0.8", expectedLanguage: "fr", expectedBody: `{"Logs":"Journaux"}`},
+		{language: "ja", expectedLanguage: "en", expectedBody: `{"Logs":"Logs"}`},
+	}
+
+	for _, tc := range tests {
+		w := get("/locales/"+tc.language+"/plugin__logging-view-plugin.json", tc.acceptLanguage)
+		require.Equal(t, http.StatusOK, w.Code, tc.language)
+		require.Equal(t, tc.expectedBody, w.Body.String(), tc.language)
+		require.Equal(t, tc.expectedLanguage, w.Header().Get("Content-Language"), tc.language)
+		require.Equal(t, "no-cache", w.Header().Get("Cache-Control"), tc.language)
+		require.Equal(t, "Accept-Language", w.Header().Get("Vary"), tc.language)
+	}
+
+	// the URLs keyed by the bundle hash are cached for a year
+	etag := get("/locales/en/plugin__logging-view-plugin.json", "").Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	w := get("/locales/en/plugin__logging-view-plugin.json?hash="+strings.Trim(etag, `"`), "")
+	require.Equal(t, immutableCacheControl, w.Header().Get("Cache-Control"))
+
+	r := httptest.NewRequest(http.MethodGet, "/locales/en/plugin__logging-view-plugin.json", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	require.Equal(t, http.StatusNotFound, get("/locales/en/unknown.json", "").Code)
+	require.Equal(t, http.StatusBadRequest, get("/locales/en/..json", "").Code)
+	require.Equal(t, http.StatusBadRequest, get("/locales/english!/plugin__logging-view-plugin.json", "").Code)
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// formats of the log lines parsed by /api/parse, the lines of no known format
+// are text
+const (
+	logFormatJSON   = "json"
+	logFormatLogfmt = "logfmt"
+	logFormatKlog   = "klog"
+	logFormatText   = "text"
+)
+
+// logFormatParsers are tried in order when the format is detected
+var logFormatParsers = []struct {
+	format string
+	parse  func(line string) (map[string]string, bool)
+}{
+	{logFormatJSON, parseJSONLine},
+	{logFormatKlog, parseKlogLine},
+	{logFormatLogfmt, parseLogfmtLine},
+}
+
+// parseLogLine returns the fields of line in format, detected when empty
+func parseLogLine(line string, format string) (string, map[string]string) {
+	for _, parser := range logFormatParsers {
+		if format != "" && format != parser.format {
+			continue
+		}
+		if fields, ok := parser.parse(line); ok {
+			return parser.format, fields
+		}
+	}
+	return logFormatText, nil
+}
+
+var invalidFieldRegexp = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// sanitizeField returns key as a Loki label name, like the json and logfmt
+// parsers of LogQL, so that the fields can be filtered in the queries
+func sanitizeField(key string) string {
+	key = invalidFieldRegexp.ReplaceAllString(key, "_")
+	if key != "" && key[0] >= '0' && key[0] <= '9' {
+		key = "_" + key
+	}
+	return key
+}
+
+// parseJSONLine flattens the JSON object of line, the nested keys are joined
+// with _ and the arrays kept as JSON
+func parseJSONLine(line string) (map[string]string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	object := map[string]interface{}{}
+	if err := decoder.Decode(&object); err != nil || decoder.More() {
+		return nil, false
+	}
+
+	fields := map[string]string{}
+	flattenJSON("", object, fields)
+	return fields, true
+}
+
+func flattenJSON(prefix string, object map[string]interface{}, fields map[string]string) {
+	for key, value := range object {
+		key = sanitizeField(key)
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenJSON(key, v, fields)
+		case string:
+			fields[key] = v
+		case json.Number:
+			fields[key] = v.String()
+		case bool:
+			fields[key] = strconv.FormatBool(v)
+		case nil:
+			fields[key] = ""
+		default:
+			buf := &bytes.Buffer{}
+			encoder := json.NewEncoder(buf)
+			encoder.SetEscapeHTML(false)
+			if err := encoder.Encode(v); err == nil {
+				fields[key] = strings.TrimSuffix(buf.String(), "\n")
+			}
+		}
+	}
+}
+
+// parseLogfmtLine returns the key=value pairs of line, it fails when a pair
+// has no value or a quoted value is not terminated
+func parseLogfmtLine(line string) (map[string]string, bool) {
+	fields := map[string]string{}
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		end := strings.IndexAny(rest, "= ")
+		if end <= 0 || rest[end] != '=' {
+			return nil, false
+		}
+		key := sanitizeField(rest[:end])
+		rest = rest[end+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, false
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+			if rest != "" && rest[0] != ' ' {
+				return nil, false
+			}
+		} else if end := strings.IndexByte(rest, ' '); end >= 0 {
+			value, rest = rest[:end], rest[end:]
+		} else {
+			value, rest = rest, ""
+		}
+		fields[key] = value
+		rest = strings.TrimLeft(rest, " ")
+	}
+	return fields, len(fields) > 0
+}
+
+// klogRegexp matches the header of the klog lines of the Kubernetes
+// components: Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg
+var klogRegexp = regexp.MustCompile(`^([IWEF])(\d{4} \d{2}:\d{2}:\d{2}\.\d{6})\s+(\d+) ([^:\]\s]+):(\d+)\] ?(.*)$`)
+
+var klogLevels = map[string]string{"I": "info", "W": "warning", "E": "error", "F": "fatal"}
+
+// parseKlogLine returns the header fields of the klog line and its msg. The
+// key=value pairs following the quoted msg of the structured klog lines are
+// returned as fields
+func parseKlogLine(line string) (map[string]string, bool) {
+	match := klogRegexp.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+	if match == nil {
+		return nil, false
+	}
+
+	fields := map[string]string{
+		"level":     klogLevels[match[1]],
+		"timestamp": match[2],
+		"thread":    match[3],
+		"file":      match[4],
+		"line":      match[5],
+		"msg":       match[6],
+	}
+	if quoted, err := strconv.QuotedPrefix(match[6]); err == nil {
+		if pairs, ok := parseLogfmtLine(match[6][len(quoted):]); ok || strings.TrimSpace(match[6][len(quoted):]) == "" {
+			fields["msg"], _ = strconv.Unquote(quoted)
+			for key, value := range pairs {
+				if _, found := fields[key]; !found {
+					fields[key] = value
+				}
+			}
+		}
+	}
+	return fields, true
+}
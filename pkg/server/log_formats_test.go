@@ -0,0 +1,74 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		name           string
+		line           string
+		format         string
+		expectedFormat string
+		expectedFields map[string]string
+	}{
+		{
+			name:           "nested json",
+			line:           `{"level":"info","kubernetes":{"namespace_name":"ns-a","labels":{"app.kubernetes.io/name":"api"}},"status":200,"ok":true,"trace":null,"tags":["a","<b>"]}`,
+			expectedFormat: logFormatJSON,
+			expectedFields: map[string]string{
+				"level":                     "info",
+				"kubernetes_namespace_name": "ns-a",
+				"kubernetes_labels_app_kubernetes_io_name": "api",
+				"status": "200",
+				"ok":     "true",
+				"trace":  "",
+				"tags":   `["a","<b>"]`,
+			},
+		},
+		{
+			name:           "logfmt",
+			line:           `level=warn ts=2024-05-01T10:00:00Z msg="slow query took \"12s\"" duration=12s 1st=x`,
+			expectedFormat: logFormatLogfmt,
+			expectedFields: map[string]string{"level": "warn", "ts": "2024-05-01T10:00:00Z", "msg": `slow query took "12s"`, "duration": "12s", "_1st": "x"},
+		},
+		{
+			name:           "klog",
+			line:           `E0501 10:00:00.123456       1 reflector.go:138] failed to watch *v1.Pod: unauthorized`,
+			expectedFormat: logFormatKlog,
+			expectedFields: map[string]string{"level": "error", "timestamp": "0501 10:00:00.123456", "thread": "1", "file": "reflector.go", "line": "138", "msg": "failed to watch *v1.Pod: unauthorized"},
+		},
+		{
+			name:           "structured klog",
+			line:           `I0501 10:00:00.123456    4242 controller.go:42] "Reconciled" pod="ns-a/api-0" attempt=2`,
+			expectedFormat: logFormatKlog,
+			expectedFields: map[string]string{"level": "info", "timestamp": "0501 10:00:00.123456", "thread": "4242", "file": "controller.go", "line": "42", "msg": "Reconciled", "pod": "ns-a/api-0", "attempt": "2"},
+		},
+		{
+			name:           "text",
+			line:           "GET /healthz 200 took 1ms",
+			expectedFormat: logFormatText,
+		},
+		{
+			name:           "unterminated quote",
+			line:           `level=info msg="unterminated`,
+			expectedFormat: logFormatText,
+		},
+		{
+			name:           "forced format",
+			line:           `{"level":"info"}`,
+			format:         logFormatLogfmt,
+			expectedFormat: logFormatText,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, fields := parseLogLine(tt.line, tt.format)
+			require.Equal(t, tt.expectedFormat, format)
+			require.Equal(t, tt.expectedFields, fields)
+		})
+	}
+}
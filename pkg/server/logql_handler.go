@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+)
+
+type logQLValidationResult struct {
+	Valid  bool                 `json:"valid"`
+	Query  string               `json:"query,omitempty"`
+	Metric bool                 `json:"metric,omitempty"`
+	Errors []*logql.SyntaxError `json:"errors,omitempty"`
+}
+
+// logQLValidateHandler parses the LogQL expression of the query parameter and
+// reports its syntax errors with their position. The matchers of the matcher
+// parameters and the namespaces of the namespace parameters are injected in
+// every stream selector of the returned query
+func logQLValidateHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		matchers := []logql.Matcher{}
+		for _, value := range params["matcher"] {
+			m, err := logql.ParseMatcher(value)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid matcher", err.Error())
+				return
+			}
+			matchers = append(matchers, m)
+		}
+
+		if namespaces := params["namespace"]; len(namespaces) > 0 {
+			for _, namespace := range namespaces {
+				if !namespaceRegexp.MatchString(namespace) {
+					writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid namespace %q", namespace), nil)
+					return
+				}
+			}
+			m := logql.Matcher{Label: namespaceLabel, Operator: "=", Value: namespaces[0]}
+			if len(namespaces) > 1 {
+				m = logql.Matcher{Label: namespaceLabel, Operator: "=~", Value: strings.Join(quoteNamespaces(namespaces), "|")}
+			}
+			matchers = append(matchers, m)
+		}
+
+		query := params.Get("query")
+		result := logQLValidationResult{Valid: true, Query: query}
+
+		parsed, err := logql.Parse(query)
+		if err == nil && len(matchers) > 0 {
+			result.Query, err = logql.InjectMatchers(query, matchers...)
+		}
+
+		if err != nil {
+			var syntaxErr *logql.SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				syntaxErr = &logql.SyntaxError{Message: err.Error()}
+			}
+			result = logQLValidationResult{Valid: false, Errors: []*logql.SyntaxError{syntaxErr}}
+		} else {
+			result.Metric = parsed.Metric
+		}
+
+		response, err := json.Marshal(result)
+		if err != nil {
+			requestLog(slog, r).WithError(err).Error("cannot marshal LogQL validation result")
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal validation result", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+	})
+}
+
+func quoteNamespaces(namespaces []string) []string {
+	quoted := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		quoted = append(quoted, regexp.QuoteMeta(namespace))
+	}
+	return quoted
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogQLValidateHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		params         url.Values
+		expectedStatus int
+		expectedResult logQLValidationResult
+	}{
+		{
+			name:           "valid log query",
+			params:         url.Values{"query": {`{app="foo"} |= "error"`}},
+			expectedStatus: http.StatusOK,
+			expectedResult: logQLValidationResult{Valid: true, Query: `{app="foo"} |= "error"`},
+		},
+		{
+			name:           "namespaces injected in a metric query",
+			params:         url.Values{"query": {`sum(rate({app="foo"}[5m]))`}, "namespace": {"ns-a", "ns-b"}, "matcher": {`log_type="application"`}},
+			expectedStatus: http.StatusOK,
+			expectedResult: logQLValidationResult{Valid: true, Metric: true, Query: `sum(rate({app="foo", log_type="application", kubernetes_namespace_name=~"ns-a|ns-b"}[5m]))`},
+		},
+		{
+			name:           "syntax error",
+			params:         url.Values{"query": {`{app="foo"} | jsn`}, "namespace": {"ns-a"}},
+			expectedStatus: http.StatusOK,
+			expectedResult: logQLValidationResult{Errors: []*logql.SyntaxError{{Message: "unknown pipeline stage jsn", Position: logql.Position{Offset: 14, Line: 1, Column: 15}}}},
+		},
+		{
+			name:           "invalid matcher",
+			params:         url.Values{"query": {`{app="foo"}`}, "matcher": {"log_type"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid namespace",
+			params:         url.Values{"query": {`{app="foo"}`}, "namespace": {".*"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			logQLValidateHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logql/validate?"+tt.params.Encode(), nil))
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			result := logQLValidationResult{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			require.Equal(t, tt.expectedResult, result)
+		})
+	}
+}
+
+func TestLogQLValidateRoute(t *testing.T) {
+	requireAuthenticatedRoute(t, http.MethodGet, "/api/logql/validate?"+url.Values{"query": {`{app="foo"}`}}.Encode(), "")
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const logsPagePath = "/monitoring/logs"
+
+type labelMatcher struct {
+	Label    string
+	Operator string
+	Value    string
+}
+
+// metricLabelMapping maps metric labels to the log stream labels carrying the
+// same information, jobs are matched by the prefix of the pods they create
+var metricLabelMapping = []struct {
+	metricLabel string
+	logLabel    string
+	prefixOnly  bool
+}{
+	{metricLabel: "namespace", logLabel: "kubernetes_namespace_name"},
+	{metricLabel: "pod", logLabel: "kubernetes_pod_name"},
+	{metricLabel: "job_name", logLabel: "kubernetes_pod_name", prefixOnly: true},
+	{metricLabel: "container", logLabel: "kubernetes_container_name"},
+}
+
+type logsLinkResponse struct {
+	Query string `json:"query"`
+	Link  string `json:"link"`
+}
+
+// logsLinkHandler derives a best-effort LogQL selector from a PromQL
+// expression (`query` parameter) and/or metric labels (`label` parameters
+// with the form `<name>=<value>`), labels take precedence over the query
+func logsLinkHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		matchers := []labelMatcher{}
+		for _, label := range params["label"] {
+			name, value, found := strings.Cut(label, "=")
+			if !found || name == "" {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid label %q, expected <name>=<value>", label), nil)
+				return
+			}
+			matchers = append(matchers, labelMatcher{Label: name, Operator: "=", Value: value})
+		}
+
+		queryMatchers, err := parsePromQLMatchers(params.Get("query"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "cannot parse query", err.Error())
+			return
+		}
+		matchers = append(matchers, queryMatchers...)
+
+		logQuery := logQLSelector(matchers)
+		if logQuery == "" {
+			writeError(w, r, http.StatusUnprocessableEntity, errorCodeUnprocessable, "no log labels can be derived from the query or labels", nil)
+			return
+		}
+
+		response, err := json.Marshal(logsLinkResponse{
+			Query: logQuery,
+			Link:  fmt.Sprintf("%s?q=%s", logsPagePath, url.QueryEscape(logQuery)),
+		})
+		if err != nil {
+			requestLog(slog, r).WithError(err).Error("cannot marshal logs link")
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal logs link", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+	})
+}
+
+// logQLSelector builds a stream selector from the first matcher found for
+// every mapped metric label
+func logQLSelector(matchers []labelMatcher) string {
+	selectors := []string{}
+	usedLabels := map[string]bool{}
+
+	for _, mapping := range metricLabelMapping {
+		if usedLabels[mapping.logLabel] {
+			continue
+		}
+
+		for _, m := range matchers {
+			if m.Label != mapping.metricLabel {
+				continue
+			}
+
+			operator, value := m.Operator, m.Value
+			if mapping.prefixOnly {
+				if operator != "=" {
+					continue
+				}
+				operator, value = "=~", regexp.QuoteMeta(value)+"-.*"
+			}
+
+			selectors = append(selectors, fmt.Sprintf("%s%s%s", mapping.logLabel, operator, strconv.Quote(value)))
+			usedLabels[mapping.logLabel] = true
+			break
+		}
+	}
+
+	if len(selectors) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("{ %s }", strings.Join(selectors, ", "))
+}
+
+// parsePromQLMatchers extracts the label matchers of every vector selector
+// found in a PromQL expression
+func parsePromQLMatchers(query string) ([]labelMatcher, error) {
+	selectors, err := parseSelectors(query)
+	if err != nil {
+		return nil, err
+	}
+
+	matchers := []labelMatcher{}
+	for _, selector := range selectors {
+		matchers = append(matchers, selector...)
+	}
+
+	return matchers, nil
+}
+
+// parseSelectors returns the label matchers of every selector found in a
+// PromQL expression, the quoted strings and the comments are skipped. The
+// LogQL queries are parsed with the logql package
+func parseSelectors(query string) ([][]labelMatcher, error) {
+	selectors := [][]labelMatcher{}
+
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case '"', '\'', '`':
+			end, err := skipQuoted(query, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case '{':
+			selectorMatchers, end, err := parseSelector(query, i+1)
+			if err != nil {
+				return nil, err
+			}
+			selectors = append(selectors, selectorMatchers)
+			i = end
+		}
+	}
+
+	return selectors, nil
+}
+
+func parseSelector(query string, pos int) ([]labelMatcher, int, error) {
+	matchers := []labelMatcher{}
+
+	for {
+		pos = skipSpaces(query, pos)
+		if pos >= len(query) {
+			return nil, pos, fmt.Errorf("unterminated selector")
+		}
+		if query[pos] == '}' {
+			return matchers, pos, nil
+		}
+
+		start := pos
+		for pos < len(query) && isLabelChar(query[pos]) {
+			pos++
+		}
+		label := query[start:pos]
+		if label == "" {
+			return nil, pos, fmt.Errorf("expected label name at position %d", pos)
+		}
+
+		pos = skipSpaces(query, pos)
+		operator := ""
+		for _, op := range []string{"=~", "!~", "!=", "="} {
+			if strings.HasPrefix(query[pos:], op) {
+				operator = op
+				break
+			}
+		}
+		if operator == "" {
+			return nil, pos, fmt.Errorf("expected matcher operator at position %d", pos)
+		}
+		pos = skipSpaces(query, pos+len(operator))
+
+		end, err := skipQuoted(query, pos)
+		if err != nil {
+			return nil, pos, err
+		}
+		value, err := unquote(query[pos : end+1])
+		if err != nil {
+			return nil, pos, fmt.Errorf("invalid value for label %s: %w", label, err)
+		}
+		matchers = append(matchers, labelMatcher{Label: label, Operator: operator, Value: value})
+
+		pos = skipSpaces(query, end+1)
+		if pos < len(query) && query[pos] == ',' {
+			pos++
+		}
+	}
+}
+
+func skipQuoted(query string, pos int) (int, error) {
+	if pos >= len(query) || !strings.ContainsRune("\"'`", rune(query[pos])) {
+		return pos, fmt.Errorf("expected quoted string at position %d", pos)
+	}
+
+	quote := query[pos]
+	for i := pos + 1; i < len(query); i++ {
+		switch {
+		case query[i] == '\\' && quote != '`':
+			i++
+		case query[i] == quote:
+			return i, nil
+		}
+	}
+
+	return pos, fmt.Errorf("unterminated string at position %d", pos)
+}
+
+func unquote(value string) (string, error) {
+	if value[0] == '\'' {
+		value = `"` + strings.ReplaceAll(strings.ReplaceAll(value[1:len(value)-1], `\'`, `'`), `"`, `\"`) + `"`
+	}
+	return strconv.Unquote(value)
+}
+
+func skipSpaces(query string, pos int) int {
+	for pos < len(query) && strings.ContainsRune(" \t\n\r", rune(query[pos])) {
+		pos++
+	}
+	return pos
+}
+
+func isLabelChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogsLinkHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        url.Values
+		expectedQuery string
+		expectedCode  int
+	}{
+		{
+			name:          "promql selector",
+			params:        url.Values{"query": {`sum(rate(container_cpu_usage_seconds_total{namespace="my-app", pod=~"api-.*", container!='db'}[5m])) > 1`}},
+			expectedQuery: `{ kubernetes_namespace_name="my-app", kubernetes_pod_name=~"api-.*", kubernetes_container_name!="db" }`,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "comment",
+			params:        url.Values{"query": {"up{namespace=\"my-app\"} # up{namespace=\"other\"} \"\n> 0"}},
+			expectedQuery: `{ kubernetes_namespace_name="my-app" }`,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "alert labels take precedence",
+			params:        url.Values{"query": {`kube_job_failed{namespace="other"}`}, "label": {"namespace=openshift-logging", "job_name=collector.1"}},
+			expectedQuery: `{ kubernetes_namespace_name="openshift-logging", kubernetes_pod_name=~"collector\\.1-.*" }`,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:         "no mapped labels",
+			params:       url.Values{"query": {`up{job="prometheus"}`}},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "invalid query",
+			params:       url.Values{"query": {`up{namespace="unterminated}`}},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			logsLinkHandler()(w, httptest.NewRequest(http.MethodGet, "/api/links/logs?"+tc.params.Encode(), nil))
+
+			require.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			if tc.expectedCode != http.StatusOK {
+				errResponse := errorResponse{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResponse))
+				require.NotEmpty(t, errResponse.Error.Code)
+				require.NotEmpty(t, errResponse.Error.Message)
+				return
+			}
+
+			response := logsLinkResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Equal(t, tc.expectedQuery, response.Query)
+			require.Equal(t, "/monitoring/logs?q="+url.QueryEscape(tc.expectedQuery), response.Link)
+		})
+	}
+}
+
+func TestLogsLinkRoute(t *testing.T) {
+	requireAuthenticatedRoute(t, http.MethodGet, "/api/links/logs?"+url.Values{"query": {`up{namespace="my-app"}`}}.Encode(), "")
+}
@@ -5,6 +5,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/sirupsen/logrus"
@@ -12,28 +15,68 @@ import (
 
 var mlog = logrus.WithField("module", "manifest")
 
-func manifestHandler(cfg *Config) http.HandlerFunc {
-	baseManifestData, err := os.ReadFile(filepath.Join(cfg.ConfigPath, "plugin-manifest.json"))
-	if err != nil {
-		mlog.WithError(err).Error("cannot read base manifest file")
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		})
-	}
-
-	patchedManifest := baseManifestData
+// backendFeatures are the features handled by the backend only, they have no
+// manifest patch
+var backendFeatures = map[string]bool{
+	featureDevProfiling: true,
+	featureKorrel8r:     true,
+}
 
-	for k := range cfg.Features {
-		patchedManifest = patchManifest(patchedManifest, filepath.Join(cfg.ConfigPath, fmt.Sprintf("%s.patch.json", k)))
-	}
+// manifestHandler serves the plugin manifest of the enabled features, it is
+// built again when the plugin config or its features change and on every
+// request in dev mode
+func manifestHandler(cfg *Config, reloadingConfig *reloadingPluginConfig) http.HandlerFunc {
+	var mu sync.Mutex
+	var patchedFeatures string
+	var patchedManifest []byte
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
+		features := sortedFeatures(reloadingConfig.features(cfg))
+		pluginConfig, generation, _ := reloadingConfig.snapshot()
+		key := strconv.FormatUint(generation, 10) + ":" + strings.Join(features, ",")
+
+		mu.Lock()
+		manifest := patchedManifest
+		if cfg.Dev || manifest == nil || key != patchedFeatures {
+			var err error
+			if manifest, err = loadManifest(cfg, pluginConfig, features); err != nil {
+				mu.Unlock()
+				mlog.WithError(err).Error("cannot read base manifest file")
+				writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot read base manifest file", err.Error())
+				return
+			}
+			patchedManifest, patchedFeatures = manifest, key
+		}
+		mu.Unlock()
 
-		w.Write(patchedManifest)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(manifest)
 	})
 }
 
+// loadManifest renders the manifest template when found, otherwise it reads
+// the base manifest and applies the patches of the enabled features
+func loadManifest(cfg *Config, pluginConfig *PluginConfig, features []string) ([]byte, error) {
+	templatePath := filepath.Join(cfg.ConfigPath, manifestTemplateFile)
+	if _, err := os.Stat(templatePath); err == nil {
+		return renderManifest(cfg, pluginConfig, templatePath, features)
+	}
+
+	patchedManifest, err := os.ReadFile(filepath.Join(cfg.ConfigPath, "plugin-manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, feature := range features {
+		if backendFeatures[feature] {
+			continue
+		}
+		patchedManifest = patchManifest(patchedManifest, filepath.Join(cfg.ConfigPath, fmt.Sprintf("%s.patch.json", feature)))
+	}
+
+	return patchedManifest, nil
+}
+
 func patchManifest(originalData []byte, patchFilePath string) []byte {
 	patchData, err := os.ReadFile(patchFilePath)
 	if err != nil {
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/openshift/logging-view-plugin/pkg/version"
+)
+
+const (
+	// manifestTemplateFile is the manifest template of the config path, it is
+	// rendered instead of patching the base manifest when found
+	manifestTemplateFile = "plugin-manifest.json.tmpl"
+	// manifestExtensionsDir holds the extension points of the config path
+	// available to the template, one JSON file per extension
+	manifestExtensionsDir = "extensions"
+	// defaultI18nNamespace is the namespace of the console plugin
+	// translations, used when the static path has no locales
+	defaultI18nNamespace = "plugin__logging-view-plugin"
+)
+
+// manifestTemplateData are the variables of the manifest template
+type manifestTemplateData struct {
+	// Version is the version of the backend build
+	Version string
+	// Features are the enabled features by name, EnabledFeatures their
+	// sorted names
+	Features        map[string]bool
+	EnabledFeatures []string
+	// Extensions are the JSON extension points of the extensions directory,
+	// by file name without the .json extension
+	Extensions map[string]string
+	// I18nNamespaces are the translation namespaces found in the locales
+	// of the static path
+	I18nNamespaces []string
+	// ProxyServices are the proxy entries of the ConsolePlugin resource of
+	// the cluster services the backend queries
+	ProxyServices []manifestProxyService
+}
+
+// manifestProxyService is a proxy entry of the ConsolePlugin resource, the
+// console forwards /api/proxy/plugin/<plugin>/<alias>/ to the service with
+// the token of the user
+type manifestProxyService struct {
+	Alias         string                `json:"alias"`
+	Authorization string                `json:"authorization"`
+	Endpoint      manifestProxyEndpoint `json:"endpoint"`
+}
+
+type manifestProxyEndpoint struct {
+	Type    string              `json:"type"`
+	Service manifestProxyTarget `json:"service"`
+}
+
+type manifestProxyTarget struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Port      int    `json:"port"`
+}
+
+// consoleProxyServices returns the proxy entries of the Loki datasources and
+// of the korrel8r service of pluginConfig, by alias. Only the URLs of the
+// cluster services, <name>.<namespace>.svc, have one: the console cannot
+// proxy the other hosts. The backend talks to no Alertmanager, the rules are
+// read from the Loki datasources
+func consoleProxyServices(pluginConfig *PluginConfig, features map[string]bool) []manifestProxyService {
+	services := []manifestProxyService{}
+	add := func(alias string, rawURL string) {
+		target, ok := clusterService(rawURL)
+		if !ok {
+			return
+		}
+		services = append(services, manifestProxyService{
+			Alias:         alias,
+			Authorization: "UserToken",
+			Endpoint:      manifestProxyEndpoint{Type: "Service", Service: target},
+		})
+	}
+
+	for _, ds := range pluginConfig.allDatasources() {
+		if ds.isLoki() {
+			add(ds.Name, ds.URL)
+		}
+	}
+	if features[featureKorrel8r] {
+		add(featureKorrel8r, pluginConfig.Korrel8r.URL)
+	}
+	return services
+}
+
+// clusterService returns the service of rawURL when its host is a cluster
+// service, the port defaults to the one of the scheme
+func clusterService(rawURL string) (manifestProxyTarget, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return manifestProxyTarget{}, false
+	}
+	host := strings.TrimSuffix(u.Hostname(), ".cluster.local")
+	labels := strings.Split(host, ".")
+	if len(labels) != 3 || labels[2] != "svc" || net.ParseIP(u.Hostname()) != nil {
+		return manifestProxyTarget{}, false
+	}
+
+	port := 443
+	if u.Scheme == "http" {
+		port = 80
+	}
+	if u.Port() != "" {
+		if port, err = strconv.Atoi(u.Port()); err != nil {
+			return manifestProxyTarget{}, false
+		}
+	}
+	return manifestProxyTarget{Name: labels[0], Namespace: labels[1], Port: port}, true
+}
+
+var manifestTemplateFuncs = template.FuncMap{
+	// json renders a value as JSON, like {{ json .EnabledFeatures }}
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// renderManifest renders the manifest template with the enabled features and
+// the services of pluginConfig, the result must be valid JSON
+func renderManifest(cfg *Config, pluginConfig *PluginConfig, templatePath string, features []string) ([]byte, error) {
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(manifestTemplateFile).Funcs(manifestTemplateFuncs).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest template: %w", err)
+	}
+
+	extensions, err := loadManifestExtensions(filepath.Join(cfg.ConfigPath, manifestExtensionsDir))
+	if err != nil {
+		return nil, err
+	}
+
+	data := manifestTemplateData{
+		Version:         version.Get().Version,
+		Features:        make(map[string]bool, len(features)),
+		EnabledFeatures: features,
+		Extensions:      extensions,
+		I18nNamespaces:  i18nNamespaces(staticFileSystem(cfg)),
+	}
+	for _, feature := range features {
+		data.Features[feature] = true
+	}
+	data.ProxyServices = consoleProxyServices(pluginConfig, data.Features)
+
+	var manifest bytes.Buffer
+	if err := tmpl.Execute(&manifest, data); err != nil {
+		return nil, fmt.Errorf("cannot render manifest template: %w", err)
+	}
+	if !json.Valid(manifest.Bytes()) {
+		return nil, fmt.Errorf("the rendered manifest template is not valid JSON")
+	}
+
+	return manifest.Bytes(), nil
+}
+
+// loadManifestExtensions reads the JSON files of the extensions directory, a
+// missing directory has no extensions
+func loadManifestExtensions(dir string) (map[string]string, error) {
+	extensions := map[string]string{}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(content) {
+			return nil, fmt.Errorf("extension %s is not valid JSON", file)
+		}
+		extensions[strings.TrimSuffix(filepath.Base(file), ".json")] = strings.TrimSpace(string(content))
+	}
+
+	return extensions, nil
+}
+
+// i18nNamespaces returns the namespaces of the translation files found in
+// <static-path>/locales/<language>/<namespace>.json
+func i18nNamespaces(root http.FileSystem) []string {
+	found := map[string]bool{}
+	for _, language := range readDirEntries(root, "/locales") {
+		if !language.IsDir() {
+			continue
+		}
+		for _, file := range readDirEntries(root, "/locales/"+language.Name()) {
+			if !file.IsDir() && path.Ext(file.Name()) == ".json" {
+				found[strings.TrimSuffix(file.Name(), ".json")] = true
+			}
+		}
+	}
+	if len(found) == 0 {
+		return []string{defaultI18nNamespace}
+	}
+
+	namespaces := make([]string, 0, len(found))
+	for namespace := range found {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// readDirEntries lists the entries of the directory name of root, none when
+// it cannot be read
+func readDirEntries(root http.FileSystem, name string) []fs.FileInfo {
+	dir, err := root.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer dir.Close()
+
+	entries, _ := dir.Readdir(-1)
+	return entries
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/version"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifestTemplate = `{
+  "name": "logging-view-plugin",
+  "version": {{ json .Version }},
+  "i18n": {{ json .I18nNamespaces }},
+  "features": {{ json .EnabledFeatures }},
+  "extensions": [
+    {{ index .Extensions "logs-page" }}{{ if index .Features "alerts" }},
+    {{ index .Extensions "alerts-source" }}{{ end }}
+  ]
+}`
+
+func TestManifestTemplate(t *testing.T) {
+	defer func(v string) { version.Version = v }(version.Version)
+	version.Version = "v5.8.0"
+
+	configPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(configPath, manifestTemplateFile), []byte(testManifestTemplate), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(configPath, manifestExtensionsDir), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(configPath, manifestExtensionsDir, "logs-page.json"), []byte(`{"type":"console.page/route"}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(configPath, manifestExtensionsDir, "alerts-source.json"), []byte(`{"type":"console.alerts/rules-source"}`+"\n"), 0600))
+
+	staticPath := t.TempDir()
+	for _, lng := range []string{"en", "ja"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(staticPath, "locales", lng), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(staticPath, "locales", lng, "plugin__logging-view-plugin.json"), []byte("{}"), 0600))
+	}
+
+	pluginConfig, err := newReloadingPluginConfig("")
+	require.NoError(t, err)
+
+	render := func(features map[string]bool) map[string]interface{} {
+		cfg := &Config{ConfigPath: configPath, StaticPath: staticPath, Features: features}
+		w := httptest.NewRecorder()
+		manifestHandler(cfg, pluginConfig).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plugin-manifest.json", nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		manifest := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &manifest))
+		return manifest
+	}
+
+	require.Equal(t, map[string]interface{}{
+		"name":     "logging-view-plugin",
+		"version":  "v5.8.0",
+		"i18n":     []interface{}{"plugin__logging-view-plugin"},
+		"features": []interface{}{"alerts", "dev-console"},
+		"extensions": []interface{}{
+			map[string]interface{}{"type": "console.page/route"},
+			map[string]interface{}{"type": "console.alerts/rules-source"},
+		},
+	}, render(map[string]bool{"alerts": true, "dev-console": true}))
+
+	manifest := render(nil)
+	require.Equal(t, []interface{}{}, manifest["features"])
+	require.Equal(t, []interface{}{map[string]interface{}{"type": "console.page/route"}}, manifest["extensions"])
+}
+
+func TestManifestTemplateInvalid(t *testing.T) {
+	configPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(configPath, manifestTemplateFile), []byte(`{"version": {{ json .Version }},}`), 0600))
+
+	_, err := loadManifest(&Config{ConfigPath: configPath}, &PluginConfig{}, nil)
+	require.EqualError(t, err, "the rendered manifest template is not valid JSON")
+
+	require.NoError(t, os.WriteFile(filepath.Join(configPath, manifestTemplateFile), []byte(`{{ .Unknown`), 0600))
+	_, err = loadManifest(&Config{ConfigPath: configPath}, &PluginConfig{}, nil)
+	require.Error(t, err)
+}
+
+func TestI18nNamespacesDefault(t *testing.T) {
+	require.Equal(t, []string{defaultI18nNamespace}, i18nNamespaces(http.Dir(t.TempDir())))
+}
+
+func TestConsoleProxyServices(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(`
+lokiURL: https://logging-loki-gateway-http.openshift-logging.svc:8080/
+datasources:
+  - name: infra
+    url: http://loki-gateway.loki.svc.cluster.local
+  - name: external
+    url: https://loki.example.com
+  - name: pods
+    type: kubernetes
+korrel8r:
+  url: https://korrel8r.korrel8r.svc:9443
+`))
+	require.NoError(t, err)
+
+	service := func(alias string, name string, namespace string, port int) manifestProxyService {
+		return manifestProxyService{Alias: alias, Authorization: "UserToken", Endpoint: manifestProxyEndpoint{Type: "Service", Service: manifestProxyTarget{Name: name, Namespace: namespace, Port: port}}}
+	}
+	// the hosts outside of the cluster cannot be proxied by the console
+	require.Equal(t, []manifestProxyService{
+		service(defaultDatasourceName, "logging-loki-gateway-http", "openshift-logging", 8080),
+		service("infra", "loki-gateway", "loki", 80),
+	}, consoleProxyServices(pluginConfig, nil))
+	require.Equal(t, service(featureKorrel8r, "korrel8r", "korrel8r", 9443), consoleProxyServices(pluginConfig, map[string]bool{featureKorrel8r: true})[2])
+
+	configPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(configPath, manifestTemplateFile), []byte(`{"proxy": {{ json .ProxyServices }}}`), 0600))
+	manifest, err := loadManifest(&Config{ConfigPath: configPath, StaticPath: t.TempDir()}, pluginConfig, nil)
+	require.NoError(t, err)
+	require.Contains(t, string(manifest), `{"alias":"infra","authorization":"UserToken","endpoint":{"type":"Service","service":{"name":"loki-gateway","namespace":"loki","port":80}}}`)
+}
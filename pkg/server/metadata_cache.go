@@ -0,0 +1,42 @@
+package server
+
+import (
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// MetadataCacheConfig caches the responses of the label and series queries
+// served at /api/metadata, enabled by default with a short TTL
+type MetadataCacheConfig struct {
+	Disabled   bool          `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	TTL        time.Duration `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+	MaxEntries int           `yaml:"maxEntries,omitempty" json:"maxEntries,omitempty"`
+}
+
+var defaultMetadataCacheConfig = MetadataCacheConfig{
+	TTL:        15 * time.Second,
+	MaxEntries: 1000,
+}
+
+func (c MetadataCacheConfig) proxyCacheConfig() proxy.CacheConfig {
+	if c.Disabled {
+		return proxy.CacheConfig{}
+	}
+	return proxy.CacheConfig{
+		TTL:        c.TTL,
+		MaxEntries: c.MaxEntries,
+		Scope:      queryCacheScope,
+	}
+}
+
+func (c MetadataCacheConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.TTL < 0 {
+		errs = append(errs, ConfigValidationError{Field: "metadataCache.ttl", Message: "ttl cannot be negative"})
+	}
+	if c.MaxEntries < 0 {
+		errs = append(errs, ConfigValidationError{Field: "metadataCache.maxEntries", Message: "maxEntries cannot be negative"})
+	}
+	return errs
+}
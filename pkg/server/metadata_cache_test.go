@@ -0,0 +1,29 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataCacheDefaults(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(""))
+	require.NoError(t, err)
+
+	cacheConfig := pluginConfig.MetadataCache.proxyCacheConfig()
+	require.Equal(t, 15*time.Second, cacheConfig.TTL)
+	require.Equal(t, 1000, cacheConfig.MaxEntries)
+	require.NotNil(t, cacheConfig.Scope)
+
+	pluginConfig, err = parsePluginConfig([]byte("metadataCache:\n  disabled: true"))
+	require.NoError(t, err)
+	require.Zero(t, pluginConfig.MetadataCache.proxyCacheConfig().TTL)
+}
+
+func TestMetadataCacheValidation(t *testing.T) {
+	errs := MetadataCacheConfig{TTL: -time.Second, MaxEntries: -1}.validate()
+	require.Len(t, errs, 2)
+	require.Equal(t, "metadataCache.ttl", errs[0].Field)
+	require.Equal(t, "metadataCache.maxEntries", errs[1].Field)
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware wraps the handlers of the server
+type Middleware func(next http.Handler) http.Handler
+
+// names of the built-in middlewares, listed in the Middlewares of the Config
+const (
+	MiddlewareRequestID        = "request-id"
+	MiddlewareAccessLog        = "access-log"
+	MiddlewareCORS             = "cors"
+	MiddlewareStandalone       = "standalone"
+	MiddlewareAuthenticateAll  = "authenticate-all"
+	MiddlewareMetrics          = "metrics"
+	MiddlewareClientCert       = "client-cert"
+	MiddlewareRequestBodyLimit = "request-body-limit"
+	MiddlewareTracing          = "tracing"
+	MiddlewareCacheControl     = "cache-control"
+	MiddlewareSecurityHeaders  = "security-headers"
+	MiddlewareCompression      = "compression"
+	MiddlewareTimeout          = "timeout"
+	MiddlewareFaultInjection   = "fault-injection"
+	// the route-level middlewares are applied by the routes themselves, in
+	// the fixed order of each route: route-auth authenticates the requests,
+	// rate-limit and audit follow and route-auth authorizes the queries last
+	MiddlewareRouteAuth = "route-auth"
+	MiddlewareRateLimit = "rate-limit"
+	MiddlewareAudit     = "audit"
+)
+
+// DefaultMiddlewares are the built-in middlewares, the outermost first
+var DefaultMiddlewares = []string{
+	MiddlewareRequestID,
+	MiddlewareAccessLog,
+	MiddlewareCORS,
+	MiddlewareStandalone,
+	MiddlewareAuthenticateAll,
+	MiddlewareMetrics,
+	MiddlewareClientCert,
+	MiddlewareRequestBodyLimit,
+	MiddlewareTracing,
+	MiddlewareCacheControl,
+	MiddlewareSecurityHeaders,
+	MiddlewareCompression,
+	MiddlewareTimeout,
+	MiddlewareFaultInjection,
+	MiddlewareRouteAuth,
+	MiddlewareRateLimit,
+	MiddlewareAudit,
+}
+
+// routedMiddlewares are the built-in middlewares run once the request is
+// routed, they need its route
+var routedMiddlewares = map[string]bool{
+	MiddlewareMetrics:          true,
+	MiddlewareClientCert:       true,
+	MiddlewareRequestBodyLimit: true,
+	MiddlewareTracing:          true,
+	MiddlewareCacheControl:     true,
+	MiddlewareSecurityHeaders:  true,
+	MiddlewareCompression:      true,
+	MiddlewareTimeout:          true,
+	MiddlewareFaultInjection:   true,
+}
+
+// routeMiddlewares are the built-in middlewares applied by the routes, they
+// come last and are only removed by the DisabledMiddlewares of the Config
+var routeMiddlewares = map[string]bool{
+	MiddlewareRouteAuth: true,
+	MiddlewareRateLimit: true,
+	MiddlewareAudit:     true,
+}
+
+var middlewareNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// registeredMiddlewares are the middlewares added by RegisterMiddleware, in
+// registration order
+var (
+	registeredMiddlewaresMu   sync.Mutex
+	registeredMiddlewares     = map[string]Middleware{}
+	registeredMiddlewareNames []string
+)
+
+// RegisterMiddleware adds the middleware name to the server, for the builds
+// inserting their own middlewares without changing the routing, usually from
+// an init function. The registered middlewares follow the default ones, or
+// run where the Middlewares of the Config list them. It panics when the name
+// is invalid or taken, like http.Handle
+func RegisterMiddleware(name string, middleware Middleware) {
+	if !middlewareNameRegexp.MatchString(name) {
+		panic(fmt.Sprintf("invalid middleware name %q", name))
+	}
+	if middleware == nil {
+		panic(fmt.Sprintf("nil middleware %s", name))
+	}
+
+	registeredMiddlewaresMu.Lock()
+	defer registeredMiddlewaresMu.Unlock()
+	if isBuiltinMiddleware(name) || registeredMiddlewares[name] != nil {
+		panic(fmt.Sprintf("middleware %s is already registered", name))
+	}
+	registeredMiddlewares[name] = middleware
+	registeredMiddlewareNames = append(registeredMiddlewareNames, name)
+}
+
+// registeredMiddleware returns the middleware registered as name, nil when
+// there is none
+func registeredMiddleware(name string) Middleware {
+	registeredMiddlewaresMu.Lock()
+	defer registeredMiddlewaresMu.Unlock()
+	return registeredMiddlewares[name]
+}
+
+func isBuiltinMiddleware(name string) bool {
+	for _, builtin := range DefaultMiddlewares {
+		if name == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMiddlewares parses a comma separated list of middleware names, e.g.
+// `request-id,access-log,metrics`
+func ParseMiddlewares(value string) ([]string, error) {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !middlewareNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("invalid middleware name %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("middleware %s is listed twice", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// middlewareNames returns the middlewares of cfg, the outermost first: its
+// Middlewares followed by the route-level ones they miss, or the default ones
+// followed by the registered ones, without its DisabledMiddlewares. The
+// middlewares wrapping the router must come before the routed ones, and the
+// built-in ones before the route-level ones
+func middlewareNames(cfg *Config) ([]string, error) {
+	names := cfg.Middlewares
+	if len(names) == 0 {
+		registeredMiddlewaresMu.Lock()
+		names = append(append([]string{}, DefaultMiddlewares...), registeredMiddlewareNames...)
+		registeredMiddlewaresMu.Unlock()
+	} else {
+		// the routes cannot be served without their middlewares by omission
+		listed := map[string]bool{}
+		for _, name := range names {
+			listed[name] = true
+		}
+		names = append([]string{}, names...)
+		for _, name := range DefaultMiddlewares {
+			if routeMiddlewares[name] && !listed[name] {
+				names = append(names, name)
+			}
+		}
+	}
+
+	disabled := map[string]bool{}
+	for _, name := range cfg.DisabledMiddlewares {
+		if !isBuiltinMiddleware(name) && registeredMiddleware(name) == nil {
+			return nil, fmt.Errorf("cannot disable unknown middleware %s", name)
+		}
+		disabled[name] = true
+	}
+
+	enabled := []string{}
+	routed, route := "", ""
+	for _, name := range names {
+		if !isBuiltinMiddleware(name) && registeredMiddleware(name) == nil {
+			return nil, fmt.Errorf("unknown middleware %s", name)
+		}
+		if disabled[name] {
+			continue
+		}
+		switch {
+		case routeMiddlewares[name]:
+			if route == "" {
+				route = name
+			}
+		case route != "" && isBuiltinMiddleware(name):
+			return nil, fmt.Errorf("middleware %s must come before %s, it is applied by the routes", name, route)
+		case routedMiddlewares[name]:
+			if routed == "" {
+				routed = name
+			}
+		case routed != "" && isBuiltinMiddleware(name):
+			return nil, fmt.Errorf("middleware %s must come before %s, it runs before the request is routed", name, routed)
+		}
+		enabled = append(enabled, name)
+	}
+
+	return enabled, nil
+}
+
+// middlewareEnabled tells whether the middleware name is enabled in cfg
+func middlewareEnabled(cfg *Config, name string) bool {
+	names, err := middlewareNames(cfg)
+	if err != nil {
+		return false
+	}
+	for _, enabled := range names {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// chainMiddlewares wraps router with the middlewares names, the built-in
+// ones are taken from builtins where nil skips them, and the route-level ones
+// are left to the routes. The middlewares following the first routed one are
+// used by the router, so that they run once the request is routed, the ones
+// before wrap the router
+func chainMiddlewares(router *mux.Router, names []string, builtins map[string]Middleware) http.Handler {
+	outer := []Middleware{}
+	routed := false
+	for _, name := range names {
+		if routeMiddlewares[name] {
+			continue
+		}
+		routed = routed || routedMiddlewares[name]
+		middleware, ok := builtins[name]
+		if !ok {
+			middleware = registeredMiddleware(name)
+		}
+		if middleware == nil {
+			continue
+		}
+		if routed {
+			router.Use(mux.MiddlewareFunc(middleware))
+		} else {
+			outer = append(outer, middleware)
+		}
+	}
+
+	var handler http.Handler = router
+	for i := len(outer) - 1; i >= 0; i-- {
+		handler = outer[i](handler)
+	}
+	return handler
+}
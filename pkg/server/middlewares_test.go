@@ -0,0 +1,143 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMiddlewares(t *testing.T) {
+	names, err := ParseMiddlewares(" request-id, access-log,,metrics ")
+	require.NoError(t, err)
+	require.Equal(t, []string{"request-id", "access-log", "metrics"}, names)
+
+	_, err = ParseMiddlewares("request-id,Access_Log")
+	require.EqualError(t, err, `invalid middleware name "Access_Log"`)
+
+	_, err = ParseMiddlewares("metrics,metrics")
+	require.EqualError(t, err, "middleware metrics is listed twice")
+}
+
+func TestMiddlewareNames(t *testing.T) {
+	names, err := middlewareNames(&Config{})
+	require.NoError(t, err)
+	require.Equal(t, DefaultMiddlewares, names[:len(DefaultMiddlewares)])
+
+	names, err = middlewareNames(&Config{DisabledMiddlewares: []string{MiddlewareCompression, MiddlewareTracing}})
+	require.NoError(t, err)
+	require.NotContains(t, names, MiddlewareCompression)
+	require.NotContains(t, names, MiddlewareTracing)
+	require.Contains(t, names, MiddlewareTimeout)
+
+	// the route-level middlewares are only removed when disabled
+	names, err = middlewareNames(&Config{Middlewares: []string{MiddlewareRequestID, MiddlewareMetrics}})
+	require.NoError(t, err)
+	require.Equal(t, []string{MiddlewareRequestID, MiddlewareMetrics, MiddlewareRouteAuth, MiddlewareRateLimit, MiddlewareAudit}, names)
+	require.False(t, middlewareEnabled(&Config{Middlewares: names}, MiddlewareCompression))
+
+	names, err = middlewareNames(&Config{Middlewares: []string{MiddlewareRequestID, MiddlewareAudit}, DisabledMiddlewares: []string{MiddlewareRateLimit}})
+	require.NoError(t, err)
+	require.Equal(t, []string{MiddlewareRequestID, MiddlewareAudit, MiddlewareRouteAuth}, names)
+
+	_, err = middlewareNames(&Config{Middlewares: []string{MiddlewareRequestID, "unknown"}})
+	require.EqualError(t, err, "unknown middleware unknown")
+
+	_, err = middlewareNames(&Config{DisabledMiddlewares: []string{"unknown"}})
+	require.EqualError(t, err, "cannot disable unknown middleware unknown")
+
+	_, err = middlewareNames(&Config{Middlewares: []string{MiddlewareMetrics, MiddlewareAccessLog}})
+	require.EqualError(t, err, "middleware access-log must come before metrics, it runs before the request is routed")
+
+	_, err = middlewareNames(&Config{Middlewares: []string{MiddlewareRateLimit, MiddlewareMetrics}})
+	require.EqualError(t, err, "middleware metrics must come before rate-limit, it is applied by the routes")
+}
+
+func TestRegisterMiddleware(t *testing.T) {
+	header := func(value string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Chain", value)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	RegisterMiddleware("test-outer", header("outer"))
+	RegisterMiddleware("test-routed", header("routed"))
+	defer func() {
+		registeredMiddlewaresMu.Lock()
+		defer registeredMiddlewaresMu.Unlock()
+		delete(registeredMiddlewares, "test-outer")
+		delete(registeredMiddlewares, "test-routed")
+		registeredMiddlewareNames = nil
+	}()
+
+	require.Panics(t, func() { RegisterMiddleware("test-outer", header("again")) })
+	require.Panics(t, func() { RegisterMiddleware(MiddlewareCORS, header("cors")) })
+	require.Panics(t, func() { RegisterMiddleware("Test", header("invalid")) })
+
+	// the registered middlewares follow the default ones
+	names, err := middlewareNames(&Config{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"test-outer", "test-routed"}, names[len(DefaultMiddlewares):])
+
+	names, err = middlewareNames(&Config{Middlewares: []string{"test-outer", MiddlewareRequestID, MiddlewareMetrics, "test-routed"}})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Path("/routed").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Chain", "handler")
+	})
+	handler := chainMiddlewares(router, names, map[string]Middleware{
+		MiddlewareRequestID: header("request-id"),
+		MiddlewareMetrics:   header("metrics"),
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/routed", nil))
+	require.Equal(t, "outer,request-id,metrics,routed,handler", strings.Join(w.Header().Values("X-Chain"), ","))
+
+	// the routed middlewares only run on the routes
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, "outer,request-id", strings.Join(w.Header().Values("X-Chain"), ","))
+}
+
+func TestRequiredMiddlewares(t *testing.T) {
+	_, err := New(&Config{ClientCAFile: "ca.crt", DisabledMiddlewares: []string{MiddlewareClientCert}})
+	require.EqualError(t, err, "the client-cert middleware is required by its settings and cannot be disabled")
+}
+
+func TestRouteMiddlewares(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`))
+	}))
+	defer upstream.Close()
+	pluginConfig, err := parsePluginConfig([]byte("lokiURL: " + upstream.URL + "\nrateLimit:\n  enabled: true\n  burst: 5\n"))
+	require.NoError(t, err)
+	deps := routeDeps{authenticator: newTokenAuthenticator(&fakeTokenReviewer{})}
+	query := func(cfg *Config, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/proxy/application/loki/api/v1/query_range?query={app=%22a%22}", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		setupRoutes(cfg, &reloadingPluginConfig{config: pluginConfig}, deps).ServeHTTP(w, r)
+		return w
+	}
+
+	w := query(&Config{}, "valid")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "5", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, http.StatusUnauthorized, query(&Config{}, "").Code)
+
+	// the route-level middlewares are toggled like the other ones
+	w = query(&Config{DisabledMiddlewares: []string{MiddlewareRateLimit}}, "valid")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, http.StatusOK, query(&Config{DisabledMiddlewares: []string{MiddlewareRouteAuth}}, "").Code)
+}
@@ -0,0 +1,426 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/openshift/logging-view-plugin/pkg/version"
+)
+
+// openAPIPath is the route of the OpenAPI document of the backend
+const openAPIPath = "/api/openapi.json"
+
+// openAPIComponents are the types of the documented request and response
+// bodies, their schemas are generated from their JSON fields so that the
+// document follows the changes of the code
+var openAPIComponents = []interface{}{
+	PluginConfig{},
+	errorResponse{},
+	featuresResponse{},
+	versionResponse{},
+	readinessResponse{},
+	logLevelRequest{},
+	featuresPatchRequest{},
+	statusResponse{},
+	eventsResponse{},
+	parseRequest{},
+	parseResponse{},
+	statsResponse{},
+	severityResponse{},
+	bootstrapResponse{},
+	suggestResponse{},
+	entriesResponse{},
+	exportJob{},
+}
+
+// openAPIDocument returns the OpenAPI 3 document of the backend routes
+func openAPIDocument() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	for _, component := range openAPIComponents {
+		openAPISchema(reflect.TypeOf(component), schemas)
+	}
+
+	tenant := openAPIParameter("tenant", "path", "the tenant of the query, or auto to resolve it from the namespaces of the query", true)
+	query := openAPIParameter("query", "query", "the LogQL query", true)
+	start := openAPIParameter("start", "query", "the start of the range, a nanosecond Unix epoch or RFC3339 date", false)
+	end := openAPIParameter("end", "query", "the end of the range, a nanosecond Unix epoch or RFC3339 date", false)
+	limit := openAPIParameter("limit", "query", "the maximum number of lines", false)
+
+	paths := map[string]interface{}{
+		"/health": map[string]interface{}{
+			"get": openAPIOperation("liveness probe", nil, textResponse("ok")),
+		},
+		"/healthz": map[string]interface{}{
+			"get": openAPIOperation("liveness probe", nil, textResponse("ok")),
+		},
+		"/readyz": map[string]interface{}{
+			"get": openAPIOperation("readiness probe, 503 when a check fails", nil, jsonResponse("the results of the readiness checks", "ReadinessResponse")),
+		},
+		"/version": map[string]interface{}{
+			"get": openAPIOperation("the build information and the enabled features", nil, jsonResponse("the build information", "VersionResponse")),
+		},
+		"/config": map[string]interface{}{
+			"get": openAPIOperation("the plugin config, with the overrides of the tenant when set", []interface{}{
+				openAPIParameter("tenant", "query", "the tenant of the overrides", false),
+				openAPIParameter("version", "query", "the schema version of the payload, also read from the version parameter of an application/json Accept header, 1 by default", false),
+				openAPIParameter("format", "query", "json, or yaml for the effective config with the keys of the config file, also read from an application/yaml Accept header", false),
+			}, configResponse()),
+		},
+		"/features": map[string]interface{}{
+			"get": openAPIOperation("the effective features", nil, jsonResponse("the enabled features and the reasons the other requested features are disabled", "FeaturesResponse")),
+		},
+		"/bootstrap": map[string]interface{}{
+			"get": openAPIOperation("the plugin config, the effective features, the capabilities of the backend and the tenants available to the user", []interface{}{
+				openAPIParameter("version", "query", "the schema version of the config, like /config", false),
+			}, jsonResponse("the bootstrap payload of the console", "BootstrapResponse")),
+		},
+		"/api/status": map[string]interface{}{
+			"get": openAPIOperation("the report of the last self-test of the plugin config and the datasources, 503 before the first one", nil, jsonResponse("the status and the results of the checks", "StatusResponse")),
+		},
+		"/api/proxy/{tenant}/loki/api/v1/query": map[string]interface{}{
+			"get": openAPIOperation("Loki instant query", []interface{}{tenant, query, limit, openAPIParameter("time", "query", "the evaluation time", false)}, lokiLinesResponse()),
+		},
+		"/api/proxy/{tenant}/loki/api/v1/query_range": map[string]interface{}{
+			"get": openAPIOperation("Loki range query", []interface{}{tenant, query, start, end, limit}, lokiLinesResponse()),
+		},
+		"/api/proxy/{tenant}/loki/api/v1/labels": map[string]interface{}{
+			"get": openAPIOperation("Loki label names", []interface{}{tenant, start, end}, lokiResponse()),
+		},
+		"/api/proxy/{tenant}/loki/api/v1/label/{name}/values": map[string]interface{}{
+			"get": openAPIOperation("Loki label values", []interface{}{tenant, openAPIParameter("name", "path", "the label name", true), start, end}, lokiResponse()),
+		},
+		"/api/metadata/{tenant}/loki/api/v1/series": map[string]interface{}{
+			"get": openAPIOperation("Loki series", []interface{}{tenant, openAPIParameter("match[]", "query", "the stream selectors", true), start, end}, lokiResponse()),
+		},
+		"/api/tail/{tenant}/loki/api/v1/tail": map[string]interface{}{
+			"get": openAPIOperation("live tail of the Loki streams, upgraded to a WebSocket", []interface{}{tenant, query, limit, start}, map[string]interface{}{
+				"101": map[string]interface{}{"description": "the WebSocket of the tailed lines"},
+			}),
+		},
+		"/api/tail/sse/{tenant}": map[string]interface{}{
+			"get": openAPIOperation("live tail of the Loki streams as server-sent events, for the clients behind the proxies stripping the WebSocket upgrades", []interface{}{tenant, query, limit, start}, map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "the events of the tailed lines, a close event ends the stream",
+					"content": map[string]interface{}{
+						"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+					},
+				},
+			}),
+		},
+		"/api/export/{tenant}": map[string]interface{}{
+			"get": openAPIOperation("export the lines of a log query as a file", []interface{}{
+				tenant, query, start, end, limit,
+				openAPIParameter("format", "query", "csv, ndjson or text, text by default with a text/plain Accept header and csv otherwise", false),
+			}, map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "the exported lines",
+					"content": map[string]interface{}{
+						"text/csv":             map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+						"application/x-ndjson": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+						"text/plain":           map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+					},
+				},
+			}),
+		},
+		"/api/export-jobs/{tenant}": map[string]interface{}{
+			"post": openAPIOperation("start a job uploading the export of a log query to the object storage, with the export.objectStorage bucket", []interface{}{
+				tenant, query, start, end, limit,
+				openAPIParameter("format", "query", "csv, ndjson or text, text by default with a text/plain Accept header and csv otherwise", false),
+			}, map[string]interface{}{
+				"202": map[string]interface{}{
+					"description": "the running job, its Location header is the URL of its status",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": openAPIRef("ExportJob")},
+					},
+				},
+			}),
+		},
+		"/api/export-jobs/{tenant}/{id}": map[string]interface{}{
+			"get": openAPIOperation("the status of an export job of the user, with the presigned URL of the export once uploaded", []interface{}{
+				tenant,
+				openAPIParameter("id", "path", "the ID of the job", true),
+			}, jsonResponse("the export job", "ExportJob")),
+		},
+		"/api/entries/{tenant}": map[string]interface{}{
+			"get": openAPIOperation("a page of the lines of a log query, from the newest to the oldest", []interface{}{
+				tenant, query, start, end, limit,
+				openAPIParameter("cursor", "query", "the cursor of the previous page, sent with its query, start and end", false),
+			}, jsonResponse("the lines of the page and the cursor of the next one", "EntriesResponse")),
+		},
+		"/api/volume/{tenant}": map[string]interface{}{
+			"get": openAPIOperation("count the lines of a log query for the histogram", []interface{}{tenant, query, start, end}, lokiResponse()),
+		},
+		"/api/severity/{tenant}": map[string]interface{}{
+			"get": openAPIOperation("count the error, warning and info lines of namespaces", []interface{}{
+				tenant,
+				openAPIParameter("namespace", "query", "a counted namespace, repeated for each namespace, every namespace when unset", false),
+				start, end,
+			}, jsonResponse("the counts of each namespace", "SeverityResponse")),
+		},
+		"/api/suggest/{tenant}": map[string]interface{}{
+			"get": openAPIOperation("complete a query with the labels and the saved and recent queries of the user", []interface{}{
+				tenant,
+				openAPIParameter("prefix", "query", "the text the suggestions start with or contain", false),
+				openAPIParameter("label", "query", "the label whose values are suggested instead", false),
+				openAPIParameter("namespace", "query", "a namespace of the suggested labels, repeated for each namespace, every stream when unset", false),
+				start, end,
+				openAPIParameter("limit", "query", "the maximum number of suggestions, 20 by default", false),
+			}, jsonResponse("the ranked suggestions", "SuggestResponse")),
+		},
+		"/api/stats/{tenant}": map[string]interface{}{
+			"get": openAPIOperation("the statistics of the execution of a query, to understand why it is slow", []interface{}{tenant, query, start, end, limit}, jsonResponse("the bytes and lines processed and the execution breakdown", "StatsResponse")),
+		},
+		"/api/events": map[string]interface{}{
+			"get": openAPIOperation("the recent Kubernetes events of a namespace, with the events feature", []interface{}{
+				openAPIParameter("namespace", "query", "the namespace of the log stream", true),
+				openAPIParameter("pod", "query", "the pod of the log stream, all the events of the namespace are served when unset", false),
+			}, jsonResponse("the events, the most recent first", "EventsResponse")),
+		},
+		"/api/parse": map[string]interface{}{
+			"post": openAPIBody(openAPIOperation("parse the fields of a batch of JSON, logfmt or klog log lines", nil, jsonResponse("the flattened fields of each line", "ParseResponse")), "ParseRequest"),
+		},
+		supportBundlePath: map[string]interface{}{
+			"get": openAPIOperation("download the support bundle, only on localhost without -authentication", nil, map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "a tar.gz of the redacted plugin config, the features, the version, the last self-test report, the goroutine and heap profiles and the last error logs",
+					"content": map[string]interface{}{
+						"application/gzip": map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+					},
+				},
+			}),
+		},
+		"/api/admin/loglevel": map[string]interface{}{
+			"get": openAPIOperation("the log level of the backend", nil, jsonResponse("the log level", "LogLevelRequest")),
+			"put": openAPIBody(openAPIOperation("change the log level of the backend", nil, jsonResponse("the new log level", "LogLevelRequest")), "LogLevelRequest"),
+		},
+		"/api/admin/features": map[string]interface{}{
+			"patch": openAPIBody(openAPIOperation("enable and disable features at runtime, optionally persisted to the plugin config ConfigMap", nil, jsonResponse("the effective features", "FeaturesResponse")), "FeaturesPatchRequest"),
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "logging-view-plugin backend",
+			"version":     version.Get().Version,
+			"description": "The proxy routes are also served per datasource under /api/proxy/<datasource>/, /api/tail/<datasource>/, /api/tail/<datasource>/sse/ and /api/metadata/<datasource>/.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		// the bearer token is only required with -authentication
+		"security": []interface{}{map[string]interface{}{}, map[string]interface{}{"bearer": []string{}}},
+	}
+}
+
+// openAPIHandler serves the OpenAPI document, generated once
+func openAPIHandler() http.HandlerFunc {
+	document, err := json.Marshal(openAPIDocument())
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal OpenAPI document", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	})
+}
+
+func openAPIParameter(name string, in string, description string, required bool) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          in,
+		"description": description,
+		"required":    required || in == "path",
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+}
+
+// openAPIOperation documents an operation, every operation can fail with the
+// error envelope
+func openAPIOperation(summary string, parameters []interface{}, responses map[string]interface{}) map[string]interface{} {
+	responses["default"] = jsonResponse("the error envelope, or the plain text message when JSON is not accepted", "ErrorResponse")["200"]
+	operation := map[string]interface{}{
+		"summary":   summary,
+		"responses": responses,
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	return operation
+}
+
+func openAPIBody(operation map[string]interface{}, schema string) map[string]interface{} {
+	operation["requestBody"] = map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": openAPIRef(schema)},
+		},
+	}
+	return operation
+}
+
+func jsonResponse(description string, schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": description,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": openAPIRef(schema)},
+			},
+		},
+	}
+}
+
+// configResponse is the plugin config in JSON, or in YAML when requested
+func configResponse() map[string]interface{} {
+	response := jsonResponse("the plugin config in the schema version 1, with its schemaVersion, or in YAML", "PluginConfig")
+	content := response["200"].(map[string]interface{})["content"].(map[string]interface{})
+	content["application/yaml"] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+	return response
+}
+
+func textResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": description,
+			"content": map[string]interface{}{
+				"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			},
+		},
+	}
+}
+
+// lokiResponse is the response of the Loki API, documented by Loki
+func lokiResponse() map[string]interface{} {
+	return map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "the Loki API response",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}},
+			},
+		},
+	}
+}
+
+// lokiLinesResponse is lokiResponse, or the flat log lines of the log queries
+// with a text/plain Accept header
+func lokiLinesResponse() map[string]interface{} {
+	return map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "the Loki API response, or the timestamped lines of a log query",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}},
+				"text/plain":       map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			},
+		},
+	}
+}
+
+func openAPIRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// openAPISchema returns the schema of the JSON encoding of t, the named
+// structs are added to schemas and referenced
+func openAPISchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case configDurationType:
+		return map[string]interface{}{"type": "string", "description": "a duration like 30s"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "a duration in nanoseconds"}
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": openAPISchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": openAPISchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return openAPIObject(t, schemas)
+		}
+		name := openAPISchemaName(t)
+		if _, found := schemas[name]; !found {
+			// registered before its fields for the recursive types
+			schemas[name] = nil
+			schemas[name] = openAPIObject(t, schemas)
+		}
+		return openAPIRef(name)
+	default:
+		// interface{} accepts any value
+		return map[string]interface{}{}
+	}
+}
+
+// openAPIObject returns the schema of the JSON fields of the struct t, the
+// fields of the embedded structs are inlined like encoding/json does
+func openAPIObject(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+			if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+				addFields(field.Type)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = openAPISchema(field.Type, schemas)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// openAPISchemaName returns the exported name of the type t
+func openAPISchemaName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
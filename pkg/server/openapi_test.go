@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	w := httptest.NewRecorder()
+	openAPIHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, openAPIPath, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	document := struct {
+		OpenAPI    string                            `json:"openapi"`
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+				Required   []string                          `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &document))
+	require.Equal(t, "3.0.3", document.OpenAPI)
+
+	for _, path := range []string{"/config", "/features", "/health", "/api/proxy/{tenant}/loki/api/v1/query_range", "/api/export/{tenant}"} {
+		require.Contains(t, document.Paths, path)
+		require.Contains(t, document.Paths[path], "get")
+	}
+
+	// the schemas follow the JSON fields of the types
+	config := document.Components.Schemas["PluginConfig"]
+	require.Equal(t, map[string]interface{}{"type": "string"}, config.Properties["lokiURL"])
+	require.Equal(t, map[string]interface{}{"type": "string", "description": "a duration like 30s"}, config.Properties["timeout"])
+	require.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/UpstreamConfig"}, config.Properties["upstream"])
+	require.Contains(t, document.Components.Schemas, "UpstreamConfig")
+
+	envelope := document.Components.Schemas["ApiError"]
+	require.Equal(t, []string{"code", "message"}, envelope.Required)
+	require.Equal(t, map[string]interface{}{}, envelope.Properties["details"])
+
+	version := document.Components.Schemas["VersionResponse"]
+	require.Contains(t, version.Properties, "features")
+	require.Contains(t, version.Properties, "version")
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+const (
+	// maxParseLines bounds the lines of a /api/parse request
+	maxParseLines = 5000
+	// maxParseSize is the maximum size of a /api/parse request
+	maxParseSize = 10 << 20
+)
+
+type parseRequest struct {
+	// Lines are the raw log lines
+	Lines []string `json:"lines"`
+	// Format is json, logfmt or klog, it is detected per line when unset
+	Format string `json:"format,omitempty"`
+}
+
+type parsedLine struct {
+	Format string            `json:"format"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type parseResponse struct {
+	// Lines are the parsed lines in the order of the request
+	Lines []parsedLine `json:"lines"`
+	// Fields are the sorted fields of all the lines
+	Fields []string `json:"fields"`
+}
+
+// parseHandler returns the flattened fields of a batch of log lines, so that
+// the UI does not parse the large results in the browser
+func parseHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxParseSize))
+		if err != nil {
+			writeError(w, r, http.StatusRequestEntityTooLarge, errorCodePayloadTooLarge, "cannot read the lines", err.Error())
+			return
+		}
+
+		request := parseRequest{}
+		if err := json.Unmarshal(content, &request); err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid parse request", err.Error())
+			return
+		}
+		switch request.Format {
+		case "", logFormatJSON, logFormatLogfmt, logFormatKlog:
+		default:
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("unsupported format %q, expected json, logfmt or klog", request.Format), nil)
+			return
+		}
+		if len(request.Lines) > maxParseLines {
+			writeError(w, r, http.StatusRequestEntityTooLarge, errorCodePayloadTooLarge, fmt.Sprintf("too many lines, at most %d lines can be parsed at once", maxParseLines), nil)
+			return
+		}
+
+		response := parseResponse{Lines: make([]parsedLine, 0, len(request.Lines)), Fields: []string{}}
+		seen := map[string]bool{}
+		for _, line := range request.Lines {
+			format, fields := parseLogLine(line, request.Format)
+			response.Lines = append(response.Lines, parsedLine{Format: format, Fields: fields})
+			for key := range fields {
+				if !seen[key] {
+					seen[key] = true
+					response.Fields = append(response.Fields, key)
+				}
+			}
+		}
+		sort.Strings(response.Fields)
+
+		writeJSON(w, r, http.StatusOK, response)
+	})
+}
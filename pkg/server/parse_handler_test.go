@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		expectedStatus   int
+		expectedResponse parseResponse
+	}{
+		{
+			name:           "detected formats",
+			body:           `{"lines":["{\"level\":\"info\",\"kubernetes\":{\"pod_name\":\"api-0\"}}","level=error msg=failed","plain text"]}`,
+			expectedStatus: http.StatusOK,
+			expectedResponse: parseResponse{
+				Lines: []parsedLine{
+					{Format: logFormatJSON, Fields: map[string]string{"level": "info", "kubernetes_pod_name": "api-0"}},
+					{Format: logFormatLogfmt, Fields: map[string]string{"level": "error", "msg": "failed"}},
+					{Format: logFormatText},
+				},
+				Fields: []string{"kubernetes_pod_name", "level", "msg"},
+			},
+		},
+		{
+			name:             "no lines",
+			body:             `{"lines":[]}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: parseResponse{Lines: []parsedLine{}, Fields: []string{}},
+		},
+		{
+			name:           "unsupported format",
+			body:           `{"lines":["a=b"],"format":"xml"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid body",
+			body:           `["a=b"]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "too many lines",
+			body:           `{"lines":[` + strings.TrimSuffix(strings.Repeat(`"a=b",`, maxParseLines+1), ",") + `]}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			parseHandler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/parse", strings.NewReader(tt.body)))
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			response := parseResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Equal(t, tt.expectedResponse, response)
+		})
+	}
+}
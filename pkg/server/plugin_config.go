@@ -0,0 +1,793 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"gopkg.in/yaml.v3"
+)
+
+// pluginConfigCheckInterval is the time between two checks of the plugin
+// config file on disk
+const pluginConfigCheckInterval = 10 * time.Second
+
+// maxTimeout bounds the upstream request timeout of the plugin config
+const maxTimeout = 10 * time.Minute
+
+// PluginConfig holds the backend settings read from the plugin config file
+type PluginConfig struct {
+	// LokiURL is the Loki or LokiStack gateway URL queried by the proxy, the
+	// proxy is disabled when empty
+	LokiURL           string               `yaml:"lokiURL,omitempty" json:"lokiURL,omitempty"`
+	UseTenantInHeader bool                 `yaml:"useTenantInHeader,omitempty" json:"useTenantInHeader,omitempty"`
+	TenantRoutingMode string               `yaml:"tenantRoutingMode,omitempty" json:"tenantRoutingMode,omitempty"`
+	Timeout           Duration             `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Datasources       []DatasourceConfig   `yaml:"datasources,omitempty" json:"datasources,omitempty"`
+	Tail              TailConfig           `yaml:"tail,omitempty" json:"tail,omitempty"`
+	CacheControl      []CacheControlRule   `yaml:"cacheControl,omitempty" json:"cacheControl,omitempty"`
+	CORS              CORSConfig           `yaml:"cors,omitempty" json:"cors,omitempty"`
+	Authorization     AuthorizationConfig  `yaml:"authorization,omitempty" json:"authorization,omitempty"`
+	Compression       CompressionConfig    `yaml:"compression,omitempty" json:"compression,omitempty"`
+	FaultInjection    []FaultInjectionRule `yaml:"faultInjection,omitempty" json:"faultInjection,omitempty"`
+	QueryCache        QueryCacheConfig     `yaml:"queryCache,omitempty" json:"queryCache,omitempty"`
+	MetadataCache     MetadataCacheConfig  `yaml:"metadataCache,omitempty" json:"metadataCache,omitempty"`
+	RateLimit         RateLimitConfig      `yaml:"rateLimit,omitempty" json:"rateLimit,omitempty"`
+	Fairness          FairnessConfig       `yaml:"fairness,omitempty" json:"fairness,omitempty"`
+	Upstream          UpstreamConfig       `yaml:"upstream,omitempty" json:"upstream,omitempty"`
+	Shadow            []ShadowConfig       `yaml:"shadow,omitempty" json:"shadow,omitempty"`
+	Korrel8r          Korrel8rConfig       `yaml:"korrel8r,omitempty" json:"korrel8r,omitempty"`
+	Events            EventsConfig         `yaml:"events,omitempty" json:"events,omitempty"`
+	Export            ExportConfig         `yaml:"export,omitempty" json:"export,omitempty"`
+	SavedQueries      SavedQueriesConfig   `yaml:"savedQueries,omitempty" json:"savedQueries,omitempty"`
+	QueryHistory      QueryHistoryConfig   `yaml:"queryHistory,omitempty" json:"queryHistory,omitempty"`
+	Guardrails        GuardrailsConfig     `yaml:"guardrails,omitempty" json:"guardrails,omitempty"`
+	Retention         RetentionConfig      `yaml:"retention,omitempty" json:"retention,omitempty"`
+	TenantMapping     TenantMappingConfig  `yaml:"tenantMapping,omitempty" json:"tenantMapping,omitempty"`
+	AccessLog         AccessLogConfig      `yaml:"accessLog,omitempty" json:"accessLog,omitempty"`
+	Watchdog          WatchdogConfig       `yaml:"watchdog,omitempty" json:"watchdog,omitempty"`
+	Admin             AdminConfig          `yaml:"admin,omitempty" json:"admin,omitempty"`
+	// Tenants overrides the settings of the queries of each tenant
+	Tenants map[string]TenantConfig `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+	// AlertingRuleTenantLabelKey and AlertingRuleNamespaceLabelKey are the
+	// labels holding the tenant and the namespace of the rules served at
+	// /api/rules
+	AlertingRuleTenantLabelKey    string `yaml:"alertingRuleTenantLabelKey,omitempty" json:"alertingRuleTenantLabelKey,omitempty"`
+	AlertingRuleNamespaceLabelKey string `yaml:"alertingRuleNamespaceLabelKey,omitempty" json:"alertingRuleNamespaceLabelKey,omitempty"`
+
+	// LokiCAFile verifies the certificate of lokiURL, LokiClientCertFile and
+	// LokiClientKeyFile authenticate the plugin to the gateways requiring
+	// mutual TLS. InsecureSkipVerify disables the verification, for testing
+	// only
+	LokiCAFile         string `yaml:"lokiCAFile,omitempty" json:"lokiCAFile,omitempty"`
+	LokiClientCertFile string `yaml:"lokiClientCertFile,omitempty" json:"lokiClientCertFile,omitempty"`
+	LokiClientKeyFile  string `yaml:"lokiClientKeyFile,omitempty" json:"lokiClientKeyFile,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty"`
+	// ProxyURL is the proxy of the requests sent to the datasources, instead
+	// of the HTTP_PROXY and HTTPS_PROXY variables
+	ProxyURL string `yaml:"proxyURL,omitempty" json:"proxyURL,omitempty"`
+	// ServiceAccountAuth queries Loki with the plugin service account token
+	ServiceAccountAuth ServiceAccountAuthConfig `yaml:"serviceAccountAuth,omitempty" json:"serviceAccountAuth,omitempty"`
+	SecurityHeaders    SecurityHeadersConfig    `yaml:"securityHeaders,omitempty" json:"securityHeaders,omitempty"`
+	// AllowImpersonation forwards the Impersonate-User and Impersonate-Group
+	// headers of the console "Impersonate user" mode to Loki and the API
+	// server, once the user is allowed to impersonate them
+	AllowImpersonation bool `yaml:"allowImpersonation,omitempty" json:"allowImpersonation,omitempty"`
+	// Features enables and disables the features of the -features flag, the
+	// backend features are read at startup. LOGGING_VIEW_PLUGIN_FEATURES is
+	// the variable of the flag, not an override of the section
+	Features map[string]bool `yaml:"features,omitempty" json:"features,omitempty" env:"-"`
+	// FeatureRules are the dependencies and conflicts of the features,
+	// checked against the features section and applied to the effective set
+	FeatureRules FeatureRulesConfig `yaml:"featureRules,omitempty" json:"featureRules,omitempty" env:"-"`
+
+	// the front-end settings are only validated and served at /config,
+	// LogsLimit is the maximum number of log lines of a query and DefaultQuery
+	// the query of the logs pages opened without one
+	LogsLimit    int    `yaml:"logsLimit,omitempty" json:"logsLimit,omitempty"`
+	DefaultQuery string `yaml:"defaultQuery,omitempty" json:"defaultQuery,omitempty"`
+	// DefaultSeverityFilter, DefaultTimeRange and DefaultTenant are the
+	// filters of the logs pages opened without them
+	DefaultSeverityFilter           []string `yaml:"defaultSeverityFilter,omitempty" json:"defaultSeverityFilter,omitempty"`
+	DefaultTimeRange                Duration `yaml:"defaultTimeRange,omitempty" json:"defaultTimeRange,omitempty"`
+	DefaultTenant                   string   `yaml:"defaultTenant,omitempty" json:"defaultTenant,omitempty"`
+	IsStreamingEnabledInDefaultPage bool     `yaml:"isStreamingEnabledInDefaultPage,omitempty" json:"isStreamingEnabledInDefaultPage,omitempty"`
+	LokiTenantLabelKey              string   `yaml:"lokiTenanLabelKey,omitempty" json:"lokiTenanLabelKey,omitempty"`
+}
+
+// CacheControlRule sets the Cache-Control header Value on the responses whose
+// path matches Pattern, using the path.Match syntax
+type CacheControlRule struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Value   string `yaml:"value" json:"value"`
+}
+
+// TailConfig limits the live tail streams proxied to Loki
+type TailConfig struct {
+	MaxStreams   int           `yaml:"maxStreams,omitempty" json:"maxStreams,omitempty"`
+	MaxDuration  time.Duration `yaml:"maxDuration,omitempty" json:"maxDuration,omitempty"`
+	PingInterval time.Duration `yaml:"pingInterval,omitempty" json:"pingInterval,omitempty"`
+}
+
+var defaultTailConfig = TailConfig{
+	MaxStreams:   100,
+	MaxDuration:  time.Hour,
+	PingInterval: 30 * time.Second,
+}
+
+var defaultCacheControlRules = []CacheControlRule{
+	{Pattern: "/plugin-entry.js", Value: "no-cache"},
+}
+
+// ConfigValidationError describes an invalid plugin config field
+type ConfigValidationError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ConfigValidationErrors is the list of problems found in a plugin config
+type ConfigValidationErrors []ConfigValidationError
+
+func (e ConfigValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		if err.Field == "" {
+			messages = append(messages, err.Message)
+		} else {
+			messages = append(messages, fmt.Sprintf("%s: %s", err.Field, err.Message))
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// readPluginConfig reads the plugin config file, the environment variables
+// override the values of the file
+func readPluginConfig(filePath string) (*PluginConfig, error) {
+	if filePath == "" {
+		pluginConfig, err := parsePluginConfigWithEnv(nil, os.LookupEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plugin config environment: %w", err)
+		}
+		return pluginConfig, nil
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read plugin config file %s: %w", filePath, err)
+	}
+
+	pluginConfig, err := parsePluginConfigWithEnv(content, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin config file %s: %w", filePath, err)
+	}
+
+	return pluginConfig, nil
+}
+
+// reloadingPluginConfig holds the plugin config read from disk, or from a
+// ConfigMap, and reloads it when the file changes, an invalid file keeps the
+// loaded config
+type reloadingPluginConfig struct {
+	filePath string
+	mu       sync.RWMutex
+	config   *PluginConfig
+	modTime  time.Time
+
+	// configMap is the ConfigMap the config is read from when set, and
+	// appliedVersion the resource version of the loaded config
+	configMap      *configMapSource
+	appliedVersion string
+
+	// generation is incremented by every config change and loadedAt is the
+	// time of the change, they key the cached responses of the config
+	generation uint64
+	loadedAt   time.Time
+
+	// featureOverrides are the features changed at runtime, applied over the
+	// features section of the loaded configs until the restart
+	featureOverrides map[string]bool
+
+	// discovered is the Loki gateway discovered at startup, the datasource
+	// of the loaded configs without any, and discoveryApplied tells whether
+	// the loaded config uses it
+	discovered       *DatasourceConfig
+	discoveryApplied bool
+
+	// reloadErr is the error of the last change of the config source that
+	// could not be loaded, until a change is loaded
+	reloadErr error
+
+	// heartbeat is beaten by the watchers on each sync
+	heartbeat *heartbeat
+}
+
+func newReloadingPluginConfig(filePath string) (*reloadingPluginConfig, error) {
+	c := &reloadingPluginConfig{filePath: filePath}
+
+	if filePath != "" {
+		modTime, err := latestModTime(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read plugin config file %s: %w", filePath, err)
+		}
+		c.modTime = modTime
+	}
+
+	config, err := readPluginConfig(filePath)
+	if err != nil {
+		return nil, err
+	}
+	c.config = config
+	c.loadedAt = time.Now()
+
+	return c, nil
+}
+
+func (c *reloadingPluginConfig) get() *PluginConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
+
+// snapshot returns the loaded config with its generation and load time
+func (c *reloadingPluginConfig) snapshot() (*PluginConfig, uint64, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config, c.generation, c.loadedAt
+}
+
+// lastReloadError returns the error of the last change of the config source
+// that could not be loaded, nil once a change is loaded
+func (c *reloadingPluginConfig) lastReloadError() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.reloadErr
+}
+
+// set replaces the loaded config, c.mu must be held
+func (c *reloadingPluginConfig) set(config *PluginConfig) {
+	// the loaded config is set again by the runtime changes, it keeps the
+	// discovered datasource
+	reapplied := config == c.config
+	if len(c.featureOverrides) > 0 {
+		overridden := *config
+		overridden.Features = make(map[string]bool, len(config.Features)+len(c.featureOverrides))
+		for feature, enabled := range config.Features {
+			overridden.Features[feature] = enabled
+		}
+		for feature, enabled := range c.featureOverrides {
+			overridden.Features[feature] = enabled
+		}
+		config = &overridden
+	}
+	if c.discovered != nil && len(config.allDatasources()) == 0 {
+		withDiscovered := *config
+		withDiscovered.LokiURL = c.discovered.URL
+		withDiscovered.TenantRoutingMode = c.discovered.TenantRoutingMode
+		withDiscovered.LokiCAFile = c.discovered.CAFile
+		config = &withDiscovered
+		c.discoveryApplied = true
+	} else if !reapplied {
+		c.discoveryApplied = false
+	}
+	c.config = config
+	c.generation++
+	c.loadedAt = time.Now()
+}
+
+// reload reads the config file again when its modification time changed, it
+// returns true when a new config is loaded
+func (c *reloadingPluginConfig) reload() (bool, error) {
+	if c.filePath == "" {
+		return false, nil
+	}
+
+	modTime, err := latestModTime(c.filePath)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.RLock()
+	unchanged := modTime.Equal(c.modTime)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	config, err := readPluginConfig(c.filePath)
+	if err != nil {
+		metrics.PluginConfigReloadsTotal.WithLabelValues("failure").Inc()
+		// do not retry the same invalid file on every check
+		c.mu.Lock()
+		c.modTime = modTime
+		c.reloadErr = err
+		c.mu.Unlock()
+		return false, err
+	}
+
+	c.mu.Lock()
+	c.set(config)
+	c.modTime = modTime
+	c.reloadErr = nil
+	c.mu.Unlock()
+
+	metrics.PluginConfigReloadsTotal.WithLabelValues("success").Inc()
+
+	return true, nil
+}
+
+// watch reloads the plugin config file every interval until ctx is done
+func (c *reloadingPluginConfig) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.heartbeat.beat()
+
+		reloaded, err := c.reload()
+		if err != nil {
+			slog.WithError(err).Warn("cannot reload plugin config, using the loaded one")
+		} else if reloaded {
+			slog.Infof("reloaded plugin config %s", c.filePath)
+		}
+	}
+}
+
+// ValidatePluginConfigFile reads and validates the plugin config file at
+// filePath, the problems found are returned as ConfigValidationErrors
+func ValidatePluginConfigFile(filePath string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("cannot read plugin config file %s: %w", filePath, err)
+	}
+
+	_, err = parsePluginConfigWithEnv(content, os.LookupEnv)
+	return err
+}
+
+// parsePluginConfig decodes and validates a YAML plugin config, applying the
+// defaults of the unset fields. Unknown fields are rejected to catch typos
+func parsePluginConfig(content []byte) (*PluginConfig, error) {
+	return parsePluginConfigWithEnv(content, nil)
+}
+
+// parsePluginConfigWithEnv is parsePluginConfig with the values of the
+// environment variables found by lookupEnv overriding the YAML content
+func parsePluginConfigWithEnv(content []byte, lookupEnv func(string) (string, bool)) (*PluginConfig, error) {
+	pluginConfig := &PluginConfig{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(pluginConfig); err != nil && !errors.Is(err, io.EOF) {
+		return nil, yamlValidationErrors(err)
+	}
+
+	if lookupEnv != nil {
+		if errs := applyEnvOverrides(pluginConfig, lookupEnv); len(errs) > 0 {
+			return nil, errs
+		}
+	}
+
+	if errs := pluginConfig.validate(); len(errs) > 0 {
+		return nil, errs
+	}
+
+	if pluginConfig.Tail.MaxStreams == 0 {
+		pluginConfig.Tail.MaxStreams = defaultTailConfig.MaxStreams
+	}
+	if pluginConfig.Tail.MaxDuration == 0 {
+		pluginConfig.Tail.MaxDuration = defaultTailConfig.MaxDuration
+	}
+	if pluginConfig.Tail.PingInterval == 0 {
+		pluginConfig.Tail.PingInterval = defaultTailConfig.PingInterval
+	}
+
+	if pluginConfig.CacheControl == nil {
+		pluginConfig.CacheControl = defaultCacheControlRules
+	}
+
+	if pluginConfig.CORS.AllowedOrigins == nil {
+		pluginConfig.CORS.AllowedOrigins = defaultCORSConfig.AllowedOrigins
+	}
+	if pluginConfig.CORS.AllowedMethods == nil {
+		pluginConfig.CORS.AllowedMethods = defaultCORSConfig.AllowedMethods
+	}
+	if pluginConfig.CORS.AllowedHeaders == nil {
+		pluginConfig.CORS.AllowedHeaders = defaultCORSConfig.AllowedHeaders
+	}
+
+	if pluginConfig.SecurityHeaders.ContentSecurityPolicy == "" {
+		pluginConfig.SecurityHeaders.ContentSecurityPolicy = defaultSecurityHeadersConfig.ContentSecurityPolicy
+	}
+	if pluginConfig.SecurityHeaders.FrameOptions == "" {
+		pluginConfig.SecurityHeaders.FrameOptions = defaultSecurityHeadersConfig.FrameOptions
+	}
+	if pluginConfig.SecurityHeaders.HSTSMaxAge == 0 {
+		pluginConfig.SecurityHeaders.HSTSMaxAge = defaultSecurityHeadersConfig.HSTSMaxAge
+	}
+
+	if pluginConfig.Compression.Level == 0 {
+		pluginConfig.Compression.Level = defaultCompressionConfig.Level
+	}
+	if pluginConfig.Compression.MinSize == 0 {
+		pluginConfig.Compression.MinSize = defaultCompressionConfig.MinSize
+	}
+	if pluginConfig.Compression.ExcludePaths == nil {
+		pluginConfig.Compression.ExcludePaths = defaultCompressionConfig.ExcludePaths
+	}
+	if pluginConfig.Compression.ExcludeExtensions == nil {
+		pluginConfig.Compression.ExcludeExtensions = defaultCompressionConfig.ExcludeExtensions
+	}
+
+	if pluginConfig.QueryCache.TTL == 0 {
+		pluginConfig.QueryCache.TTL = defaultQueryCacheConfig.TTL
+	}
+	if pluginConfig.QueryCache.MaxEntries == 0 {
+		pluginConfig.QueryCache.MaxEntries = defaultQueryCacheConfig.MaxEntries
+	}
+	if pluginConfig.QueryCache.MaxEntrySize == 0 {
+		pluginConfig.QueryCache.MaxEntrySize = defaultQueryCacheConfig.MaxEntrySize
+	}
+	if pluginConfig.QueryCache.Disk.MaxSize == 0 {
+		pluginConfig.QueryCache.Disk.MaxSize = defaultQueryCacheConfig.Disk.MaxSize
+	}
+	if pluginConfig.QueryCache.Disk.MinAge == 0 {
+		pluginConfig.QueryCache.Disk.MinAge = defaultQueryCacheConfig.Disk.MinAge
+	}
+
+	if pluginConfig.MetadataCache.TTL == 0 {
+		pluginConfig.MetadataCache.TTL = defaultMetadataCacheConfig.TTL
+	}
+	if pluginConfig.MetadataCache.MaxEntries == 0 {
+		pluginConfig.MetadataCache.MaxEntries = defaultMetadataCacheConfig.MaxEntries
+	}
+
+	if pluginConfig.TenantMapping.Rules == nil {
+		pluginConfig.TenantMapping.Rules = defaultTenantMappingConfig.Rules
+	}
+	if pluginConfig.TenantMapping.Default == "" {
+		pluginConfig.TenantMapping.Default = defaultTenantMappingConfig.Default
+	}
+
+	if pluginConfig.AccessLog.Level == "" {
+		pluginConfig.AccessLog.Level = defaultAccessLogConfig.Level
+	}
+	if pluginConfig.AccessLog.SampleRate == 0 {
+		pluginConfig.AccessLog.SampleRate = defaultAccessLogConfig.SampleRate
+	}
+	if pluginConfig.AccessLog.Fields == nil {
+		pluginConfig.AccessLog.Fields = defaultAccessLogConfig.Fields
+	}
+	if pluginConfig.AccessLog.ExcludePaths == nil {
+		pluginConfig.AccessLog.ExcludePaths = defaultAccessLogConfig.ExcludePaths
+	}
+	if pluginConfig.AccessLog.ExcludeExtensions == nil {
+		pluginConfig.AccessLog.ExcludeExtensions = defaultAccessLogConfig.ExcludeExtensions
+	}
+
+	if pluginConfig.RateLimit.RequestsPerSecond == 0 {
+		pluginConfig.RateLimit.RequestsPerSecond = defaultRateLimitConfig.RequestsPerSecond
+	}
+	if pluginConfig.RateLimit.Burst == 0 {
+		pluginConfig.RateLimit.Burst = defaultRateLimitConfig.Burst
+	}
+
+	if pluginConfig.Fairness.MaxInFlight == 0 {
+		pluginConfig.Fairness.MaxInFlight = defaultFairnessConfig.MaxInFlight
+	}
+	if pluginConfig.Fairness.MaxInFlightPerUser == 0 {
+		pluginConfig.Fairness.MaxInFlightPerUser = defaultFairnessConfig.MaxInFlightPerUser
+	}
+	if pluginConfig.Fairness.MaxQueued == 0 {
+		pluginConfig.Fairness.MaxQueued = defaultFairnessConfig.MaxQueued
+	}
+	if pluginConfig.Fairness.MaxWait == 0 {
+		pluginConfig.Fairness.MaxWait = defaultFairnessConfig.MaxWait
+	}
+
+	if pluginConfig.Upstream.MaxInFlight == 0 {
+		pluginConfig.Upstream.MaxInFlight = defaultUpstreamConfig.MaxInFlight
+	}
+	if pluginConfig.Upstream.FailureThreshold == 0 {
+		pluginConfig.Upstream.FailureThreshold = defaultUpstreamConfig.FailureThreshold
+	}
+	if pluginConfig.Upstream.OpenDuration == 0 {
+		pluginConfig.Upstream.OpenDuration = defaultUpstreamConfig.OpenDuration
+	}
+	if pluginConfig.Upstream.Retry.MaxAttempts == 0 {
+		pluginConfig.Upstream.Retry.MaxAttempts = defaultUpstreamConfig.Retry.MaxAttempts
+	}
+	if pluginConfig.Upstream.Retry.Backoff == 0 {
+		pluginConfig.Upstream.Retry.Backoff = defaultUpstreamConfig.Retry.Backoff
+	}
+	if pluginConfig.Upstream.Retry.MaxBackoff == 0 {
+		pluginConfig.Upstream.Retry.MaxBackoff = defaultUpstreamConfig.Retry.MaxBackoff
+		if pluginConfig.Upstream.Retry.Backoff > pluginConfig.Upstream.Retry.MaxBackoff {
+			pluginConfig.Upstream.Retry.MaxBackoff = pluginConfig.Upstream.Retry.Backoff
+		}
+	}
+	if pluginConfig.Upstream.Retry.StatusCodes == nil {
+		pluginConfig.Upstream.Retry.StatusCodes = defaultUpstreamConfig.Retry.StatusCodes
+	}
+	if pluginConfig.Upstream.Connections.MaxIdleConns == 0 {
+		pluginConfig.Upstream.Connections.MaxIdleConns = defaultUpstreamConfig.Connections.MaxIdleConns
+	}
+	if pluginConfig.Upstream.Connections.MaxIdleConnsPerHost == 0 {
+		pluginConfig.Upstream.Connections.MaxIdleConnsPerHost = defaultUpstreamConfig.Connections.MaxIdleConnsPerHost
+		if pluginConfig.Upstream.Connections.MaxIdleConnsPerHost > pluginConfig.Upstream.Connections.MaxIdleConns {
+			pluginConfig.Upstream.Connections.MaxIdleConnsPerHost = pluginConfig.Upstream.Connections.MaxIdleConns
+		}
+	}
+	if pluginConfig.Upstream.Connections.IdleConnTimeout == 0 {
+		pluginConfig.Upstream.Connections.IdleConnTimeout = defaultUpstreamConfig.Connections.IdleConnTimeout
+	}
+
+	for i := range pluginConfig.Shadow {
+		if pluginConfig.Shadow[i].Timeout == 0 {
+			pluginConfig.Shadow[i].Timeout = defaultShadowConfig.Timeout
+		}
+		if pluginConfig.Shadow[i].MaxInFlight == 0 {
+			pluginConfig.Shadow[i].MaxInFlight = defaultShadowConfig.MaxInFlight
+		}
+	}
+
+	if pluginConfig.Export.MaxLines == 0 {
+		pluginConfig.Export.MaxLines = defaultExportConfig.MaxLines
+	}
+	if pluginConfig.Export.MaxBytes == 0 {
+		pluginConfig.Export.MaxBytes = defaultExportConfig.MaxBytes
+	}
+	if pluginConfig.Export.PageSize == 0 {
+		pluginConfig.Export.PageSize = defaultExportConfig.PageSize
+	}
+	if storage := &pluginConfig.Export.ObjectStorage; storage.enabled() {
+		if storage.Region == "" {
+			storage.Region = defaultExportObjectStorageConfig.Region
+		}
+		if storage.URLExpiry == 0 {
+			storage.URLExpiry = defaultExportObjectStorageConfig.URLExpiry
+		}
+		if storage.MaxLines == 0 {
+			storage.MaxLines = defaultExportObjectStorageConfig.MaxLines
+		}
+		if storage.MaxBytes == 0 {
+			storage.MaxBytes = defaultExportObjectStorageConfig.MaxBytes
+		}
+		if storage.MaxJobs == 0 {
+			storage.MaxJobs = defaultExportObjectStorageConfig.MaxJobs
+		}
+		if storage.Timeout == 0 {
+			storage.Timeout = defaultExportObjectStorageConfig.Timeout
+		}
+	}
+
+	if pluginConfig.Watchdog.Timeout.Duration == 0 {
+		pluginConfig.Watchdog.Timeout = defaultWatchdogConfig.Timeout
+	}
+	if pluginConfig.Watchdog.MaxSchedulingDelay.Duration == 0 {
+		pluginConfig.Watchdog.MaxSchedulingDelay = defaultWatchdogConfig.MaxSchedulingDelay
+	}
+
+	if pluginConfig.Events.MaxAge.Duration == 0 {
+		pluginConfig.Events.MaxAge = defaultEventsConfig.MaxAge
+	}
+	if pluginConfig.Events.MaxEvents == 0 {
+		pluginConfig.Events.MaxEvents = defaultEventsConfig.MaxEvents
+	}
+
+	if pluginConfig.SavedQueries.MaxPerUser == 0 {
+		pluginConfig.SavedQueries.MaxPerUser = defaultSavedQueriesConfig.MaxPerUser
+	}
+
+	if pluginConfig.QueryHistory.MaxPerUser == 0 {
+		pluginConfig.QueryHistory.MaxPerUser = defaultQueryHistoryConfig.MaxPerUser
+	}
+
+	if pluginConfig.AlertingRuleTenantLabelKey == "" {
+		pluginConfig.AlertingRuleTenantLabelKey = defaultAlertingRuleTenantLabelKey
+	}
+	if pluginConfig.AlertingRuleNamespaceLabelKey == "" {
+		pluginConfig.AlertingRuleNamespaceLabelKey = defaultAlertingRuleNamespaceLabelKey
+	}
+
+	if pluginConfig.Authorization.Tenants == nil {
+		pluginConfig.Authorization.Tenants = defaultAuthorizationConfig.Tenants
+	}
+	if pluginConfig.Authorization.Verb == "" {
+		pluginConfig.Authorization.Verb = defaultAuthorizationConfig.Verb
+	}
+	if pluginConfig.Authorization.Resource == "" {
+		pluginConfig.Authorization.Resource = defaultAuthorizationConfig.Resource
+		pluginConfig.Authorization.Subresource = defaultAuthorizationConfig.Subresource
+	}
+
+	return pluginConfig, nil
+}
+
+// yamlValidationErrors reports each field of a YAML decoding error separately
+func yamlValidationErrors(err error) ConfigValidationErrors {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return ConfigValidationErrors{{Message: err.Error()}}
+	}
+
+	errs := make(ConfigValidationErrors, 0, len(typeErr.Errors))
+	for _, message := range typeErr.Errors {
+		errs = append(errs, ConfigValidationError{Message: message})
+	}
+	return errs
+}
+
+func (c *PluginConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+
+	if c.LokiURL != "" {
+		if u, err := url.Parse(c.LokiURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, ConfigValidationError{Field: "lokiURL", Message: fmt.Sprintf("invalid URL %q, an absolute http or https URL is expected", c.LokiURL)})
+		}
+	}
+
+	if c.ProxyURL != "" {
+		if u, err := url.Parse(c.ProxyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || u.Host == "" {
+			errs = append(errs, ConfigValidationError{Field: "proxyURL", Message: fmt.Sprintf("invalid URL %q, an absolute http, https or socks5 URL is expected", c.ProxyURL)})
+		}
+	}
+
+	errs = append(errs, c.validateDatasources()...)
+	errs = append(errs, c.validateTenants()...)
+	errs = append(errs, c.Korrel8r.validate()...)
+	errs = append(errs, c.Events.validate()...)
+	errs = append(errs, c.SavedQueries.validate()...)
+	errs = append(errs, c.QueryHistory.validate()...)
+	errs = append(errs, c.ServiceAccountAuth.validate(c.Authorization, c.proxiedTenants())...)
+	errs = append(errs, c.MetadataCache.validate()...)
+	errs = append(errs, c.Guardrails.validate()...)
+	errs = append(errs, c.Retention.validate()...)
+	errs = append(errs, c.TenantMapping.validate()...)
+	errs = append(errs, c.AccessLog.validate()...)
+	errs = append(errs, c.Watchdog.validate()...)
+	errs = append(errs, c.Admin.validate()...)
+	errs = append(errs, c.RateLimit.validate()...)
+	errs = append(errs, c.Fairness.validate()...)
+	errs = append(errs, c.Upstream.validate()...)
+	errs = append(errs, c.validateShadow()...)
+	errs = append(errs, c.SecurityHeaders.validate(c.CORS)...)
+	errs = append(errs, validateFeatures(c.Features)...)
+	errs = append(errs, c.FeatureRules.validate(c.Features)...)
+	errs = append(errs, c.validateFrontendDefaults()...)
+
+	if c.Timeout.Duration < 0 || c.Timeout.Duration > maxTimeout {
+		errs = append(errs, ConfigValidationError{Field: "timeout", Message: fmt.Sprintf("timeout must be between 0 and %s", maxTimeout)})
+	}
+
+	if c.LogsLimit < 0 {
+		errs = append(errs, ConfigValidationError{Field: "logsLimit", Message: "logsLimit must be greater than 0"})
+	}
+
+	if c.Tail.MaxStreams < 0 {
+		errs = append(errs, ConfigValidationError{Field: "tail.maxStreams", Message: "maxStreams cannot be negative"})
+	}
+	if c.Tail.MaxDuration < 0 {
+		errs = append(errs, ConfigValidationError{Field: "tail.maxDuration", Message: "maxDuration cannot be negative"})
+	}
+	if c.Tail.PingInterval < 0 {
+		errs = append(errs, ConfigValidationError{Field: "tail.pingInterval", Message: "pingInterval cannot be negative"})
+	}
+
+	for i, rule := range c.CacheControl {
+		field := fmt.Sprintf("cacheControl[%d]", i)
+		if _, err := path.Match(rule.Pattern, ""); err != nil || rule.Pattern == "" {
+			errs = append(errs, ConfigValidationError{Field: field + ".pattern", Message: fmt.Sprintf("invalid pattern %q", rule.Pattern)})
+		}
+		if rule.Value == "" {
+			errs = append(errs, ConfigValidationError{Field: field + ".value", Message: "value is required"})
+		}
+	}
+
+	for i, origin := range c.CORS.AllowedOrigins {
+		field := fmt.Sprintf("cors.allowedOrigins[%d]", i)
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				errs = append(errs, ConfigValidationError{Field: field, Message: "the * origin cannot be used with allowCredentials"})
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			errs = append(errs, ConfigValidationError{Field: field, Message: fmt.Sprintf("invalid origin %q, * or <scheme>://<host>[:<port>] is expected", origin)})
+		}
+	}
+	for i, method := range c.CORS.AllowedMethods {
+		if method == "" || strings.ContainsAny(method, " ,") {
+			errs = append(errs, ConfigValidationError{Field: fmt.Sprintf("cors.allowedMethods[%d]", i), Message: fmt.Sprintf("invalid method %q", method)})
+		}
+	}
+	for i, header := range c.CORS.AllowedHeaders {
+		if header == "" || strings.ContainsAny(header, " ,") {
+			errs = append(errs, ConfigValidationError{Field: fmt.Sprintf("cors.allowedHeaders[%d]", i), Message: fmt.Sprintf("invalid header %q", header)})
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, ConfigValidationError{Field: "cors.maxAge", Message: "maxAge cannot be negative"})
+	}
+
+	if c.Compression.Level < 0 || c.Compression.Level > 9 {
+		errs = append(errs, ConfigValidationError{Field: "compression.level", Message: "level must be between 1 and 9"})
+	}
+	if c.Compression.ExportLevel < 0 || c.Compression.ExportLevel > 9 {
+		errs = append(errs, ConfigValidationError{Field: "compression.exportLevel", Message: "exportLevel must be between 1 and 9"})
+	}
+	if c.Compression.MinSize < 0 {
+		errs = append(errs, ConfigValidationError{Field: "compression.minSize", Message: "minSize cannot be negative"})
+	}
+	for i, prefix := range c.Compression.ExcludePaths {
+		if !strings.HasPrefix(prefix, "/") || strings.ContainsAny(prefix, "*?[") {
+			errs = append(errs, ConfigValidationError{Field: fmt.Sprintf("compression.excludePaths[%d]", i), Message: fmt.Sprintf("invalid path prefix %q, expected /<path>", prefix)})
+		}
+	}
+	for i, extension := range c.Compression.ExcludeExtensions {
+		if !strings.HasPrefix(extension, ".") || len(extension) < 2 {
+			errs = append(errs, ConfigValidationError{Field: fmt.Sprintf("compression.excludeExtensions[%d]", i), Message: fmt.Sprintf("invalid extension %q, expected .<extension>", extension)})
+		}
+	}
+
+	if c.QueryCache.TTL < 0 {
+		errs = append(errs, ConfigValidationError{Field: "queryCache.ttl", Message: "ttl cannot be negative"})
+	}
+	if c.QueryCache.MaxEntries < 0 {
+		errs = append(errs, ConfigValidationError{Field: "queryCache.maxEntries", Message: "maxEntries cannot be negative"})
+	}
+	if c.QueryCache.MaxEntrySize < 0 {
+		errs = append(errs, ConfigValidationError{Field: "queryCache.maxEntrySize", Message: "maxEntrySize cannot be negative"})
+	}
+	if c.QueryCache.Disk.MaxSize < 0 {
+		errs = append(errs, ConfigValidationError{Field: "queryCache.disk.maxSize", Message: "maxSize cannot be negative"})
+	}
+	if c.QueryCache.Disk.MinAge < 0 {
+		errs = append(errs, ConfigValidationError{Field: "queryCache.disk.minAge", Message: "minAge cannot be negative"})
+	}
+
+	if c.Export.MaxLines < 0 {
+		errs = append(errs, ConfigValidationError{Field: "export.maxLines", Message: "maxLines cannot be negative"})
+	}
+	if c.Export.MaxBytes < 0 {
+		errs = append(errs, ConfigValidationError{Field: "export.maxBytes", Message: "maxBytes cannot be negative"})
+	}
+	if c.Export.PageSize < 0 || c.Export.PageSize > maxExportPageSize {
+		errs = append(errs, ConfigValidationError{Field: "export.pageSize", Message: fmt.Sprintf("pageSize must be between 0 and %d", maxExportPageSize)})
+	}
+	errs = append(errs, c.Export.ObjectStorage.validate()...)
+
+	for i, tenant := range c.Authorization.Tenants {
+		if !tenantRegexp.MatchString(tenant) {
+			errs = append(errs, ConfigValidationError{Field: fmt.Sprintf("authorization.tenants[%d]", i), Message: fmt.Sprintf("invalid tenant %q", tenant)})
+		}
+	}
+
+	for i, rule := range c.FaultInjection {
+		field := fmt.Sprintf("faultInjection[%d]", i)
+		if _, err := path.Match(rule.Path, ""); err != nil || rule.Path == "" {
+			errs = append(errs, ConfigValidationError{Field: field + ".path", Message: fmt.Sprintf("invalid path pattern %q", rule.Path)})
+		}
+		if rule.Percentage < 0 || rule.Percentage > 100 {
+			errs = append(errs, ConfigValidationError{Field: field + ".percentage", Message: "percentage must be between 0 and 100"})
+		}
+		if rule.StatusCode != 0 && (rule.StatusCode < 400 || rule.StatusCode > 599) {
+			errs = append(errs, ConfigValidationError{Field: field + ".statusCode", Message: "status code must be an error code between 400 and 599"})
+		}
+	}
+
+	return errs
+}
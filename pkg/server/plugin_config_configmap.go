@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+const (
+	// pluginConfigMapKey is the key of the plugin config in the ConfigMap,
+	// the only key of the ConfigMap is read when it is missing
+	pluginConfigMapKey = "config.yaml"
+	// configMapWatchTimeout is the duration of a watch request, lower than
+	// the timeout of the kube client
+	configMapWatchTimeout = 25 * time.Second
+	// configMapWatchRetryInterval is waited before watching again after a
+	// failed watch
+	configMapWatchRetryInterval = 5 * time.Second
+)
+
+// configMapWatcher reads, watches and updates the ConfigMaps, implemented by
+// kube.Client
+type configMapWatcher interface {
+	GetConfigMap(ctx context.Context, namespace string, name string) (*kube.ConfigMap, error)
+	UpdateConfigMap(ctx context.Context, configMap *kube.ConfigMap) (*kube.ConfigMap, error)
+	WatchConfigMap(ctx context.Context, namespace string, name string, resourceVersion string, timeout time.Duration, onEvent func(kube.ConfigMapEvent)) error
+}
+
+// configMapSource is the ConfigMap the plugin config is read from, instead
+// of a mounted file
+type configMapSource struct {
+	client          configMapWatcher
+	namespace       string
+	name            string
+	resourceVersion string
+}
+
+// newConfigMapPluginConfig reads the plugin config from the ConfigMap name in
+// namespace, its changes are applied by watchConfigMap
+func newConfigMapPluginConfig(ctx context.Context, client configMapWatcher, namespace string, name string) (*reloadingPluginConfig, error) {
+	c := &reloadingPluginConfig{configMap: &configMapSource{client: client, namespace: namespace, name: name}}
+
+	configMap, err := client.GetConfigMap(ctx, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read plugin config ConfigMap %s/%s: %w", namespace, name, err)
+	}
+
+	config, err := c.configMap.parse(configMap)
+	if err != nil {
+		return nil, err
+	}
+	c.config = config
+	c.loadedAt = time.Now()
+	c.configMap.resourceVersion = configMap.Metadata.ResourceVersion
+	c.appliedVersion = configMap.Metadata.ResourceVersion
+
+	return c, nil
+}
+
+// parse decodes the plugin config of configMap, the environment variables
+// override its values like the ones of the file
+func (s *configMapSource) parse(configMap *kube.ConfigMap) (*PluginConfig, error) {
+	key, err := s.key(configMap)
+	if err != nil {
+		return nil, err
+	}
+
+	pluginConfig, err := parsePluginConfigWithEnv([]byte(configMap.Data[key]), os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin config ConfigMap %s/%s: %w", s.namespace, s.name, err)
+	}
+	return pluginConfig, nil
+}
+
+// key returns the key of the plugin config in configMap
+func (s *configMapSource) key(configMap *kube.ConfigMap) (string, error) {
+	if _, found := configMap.Data[pluginConfigMapKey]; found {
+		return pluginConfigMapKey, nil
+	}
+	if len(configMap.Data) == 1 {
+		for key := range configMap.Data {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("plugin config ConfigMap %s/%s has no %s key", s.namespace, s.name, pluginConfigMapKey)
+}
+
+// watchConfigMap applies the changes of the ConfigMap until ctx is done, an
+// invalid or deleted ConfigMap keeps the loaded config. The watch is resumed
+// from the last seen resource version, or from the current ConfigMap once
+// that version expired
+func (c *reloadingPluginConfig) watchConfigMap(ctx context.Context) {
+	source := c.configMap
+	for ctx.Err() == nil {
+		c.heartbeat.beat()
+		err := source.client.WatchConfigMap(ctx, source.namespace, source.name, source.resourceVersion, configMapWatchTimeout, c.applyConfigMapEvent)
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+
+		if kube.IsGone(err) {
+			err = c.resyncConfigMap(ctx)
+			if err == nil {
+				continue
+			}
+		}
+
+		slog.WithError(err).Warnf("cannot watch plugin config ConfigMap %s/%s, retrying in %s", source.namespace, source.name, configMapWatchRetryInterval)
+		select {
+		case <-time.After(configMapWatchRetryInterval):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// resyncConfigMap applies the current ConfigMap and resumes the watch from
+// its resource version
+func (c *reloadingPluginConfig) resyncConfigMap(ctx context.Context) error {
+	source := c.configMap
+	configMap, err := source.client.GetConfigMap(ctx, source.namespace, source.name)
+	if err != nil {
+		return err
+	}
+	c.applyConfigMapEvent(kube.ConfigMapEvent{Type: kube.EventModified, ConfigMap: configMap})
+	return nil
+}
+
+func (c *reloadingPluginConfig) applyConfigMapEvent(event kube.ConfigMapEvent) {
+	source := c.configMap
+	resourceVersion := event.ConfigMap.Metadata.ResourceVersion
+	if resourceVersion != "" {
+		source.resourceVersion = resourceVersion
+	}
+
+	switch event.Type {
+	case kube.EventAdded, kube.EventModified:
+	case kube.EventDeleted:
+		slog.Warnf("plugin config ConfigMap %s/%s deleted, using the loaded config", source.namespace, source.name)
+		return
+	default:
+		return
+	}
+
+	c.mu.RLock()
+	unchanged := resourceVersion != "" && resourceVersion == c.appliedVersion
+	c.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	config, err := source.parse(event.ConfigMap)
+	if err != nil {
+		c.mu.Lock()
+		c.reloadErr = err
+		c.mu.Unlock()
+		metrics.PluginConfigReloadsTotal.WithLabelValues("failure").Inc()
+		slog.WithError(err).Warn("cannot reload plugin config, using the loaded one")
+		return
+	}
+
+	c.mu.Lock()
+	c.set(config)
+	c.appliedVersion = resourceVersion
+	c.reloadErr = nil
+	c.mu.Unlock()
+
+	metrics.PluginConfigReloadsTotal.WithLabelValues("success").Inc()
+	slog.Infof("reloaded plugin config ConfigMap %s/%s", source.namespace, source.name)
+}
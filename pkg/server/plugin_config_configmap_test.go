@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConfigMapWatcher serves configMap and replays the events of each watch
+// request in turn, the next watches fail with a 410 Gone
+type fakeConfigMapWatcher struct {
+	configMap *kube.ConfigMap
+	watches   [][]kube.ConfigMapEvent
+	versions  []string
+	done      chan struct{}
+}
+
+func (f *fakeConfigMapWatcher) GetConfigMap(_ context.Context, namespace string, name string) (*kube.ConfigMap, error) {
+	if f.configMap == nil {
+		return nil, &kube.StatusError{Code: 404, Reason: "NotFound"}
+	}
+	return f.configMap, nil
+}
+
+func (f *fakeConfigMapWatcher) UpdateConfigMap(_ context.Context, configMap *kube.ConfigMap) (*kube.ConfigMap, error) {
+	if configMap.Metadata.ResourceVersion != f.configMap.Metadata.ResourceVersion {
+		return nil, &kube.StatusError{Code: 409, Reason: "Conflict"}
+	}
+	version, _ := strconv.Atoi(configMap.Metadata.ResourceVersion)
+	updated := *configMap
+	updated.Metadata.ResourceVersion = strconv.Itoa(version + 1)
+	f.configMap = &updated
+	return &updated, nil
+}
+
+func (f *fakeConfigMapWatcher) WatchConfigMap(ctx context.Context, namespace string, name string, resourceVersion string, _ time.Duration, onEvent func(kube.ConfigMapEvent)) error {
+	f.versions = append(f.versions, resourceVersion)
+	if len(f.watches) == 0 {
+		close(f.done)
+		<-ctx.Done()
+		return nil
+	}
+
+	events := f.watches[0]
+	f.watches = f.watches[1:]
+	for _, event := range events {
+		onEvent(event)
+	}
+	if len(f.watches) == 0 {
+		return &kube.StatusError{Code: 410, Reason: "Expired"}
+	}
+	return nil
+}
+
+func testConfigMap(version string, config string) *kube.ConfigMap {
+	return &kube.ConfigMap{
+		Metadata: kube.ObjectMeta{Name: "plugin-config", ResourceVersion: version},
+		Data:     map[string]string{"config.yaml": config},
+	}
+}
+
+func TestConfigMapPluginConfig(t *testing.T) {
+	watcher := &fakeConfigMapWatcher{
+		configMap: testConfigMap("1", "logsLimit: 100"),
+		done:      make(chan struct{}),
+	}
+
+	_, err := newConfigMapPluginConfig(context.Background(), &fakeConfigMapWatcher{}, "openshift-logging", "plugin-config")
+	require.Error(t, err)
+
+	reloadingConfig, err := newConfigMapPluginConfig(context.Background(), watcher, "openshift-logging", "plugin-config")
+	require.NoError(t, err)
+	require.Equal(t, 100, reloadingConfig.get().LogsLimit)
+
+	watcher.watches = [][]kube.ConfigMapEvent{
+		{
+			{Type: kube.EventModified, ConfigMap: testConfigMap("2", "logsLimit: 200")},
+			// the invalid and deleted ConfigMaps keep the loaded config
+			{Type: kube.EventModified, ConfigMap: testConfigMap("3", "logsLimit: -1")},
+			{Type: kube.EventDeleted, ConfigMap: testConfigMap("4", "")},
+		},
+		{
+			{Type: kube.EventAdded, ConfigMap: testConfigMap("5", "logsLimit: 500")},
+		},
+	}
+	// the watch expires and is resumed from the current ConfigMap
+	watcher.configMap = testConfigMap("6", "logsLimit: 600")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloadingConfig.watchConfigMap(ctx)
+
+	select {
+	case <-watcher.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the ConfigMap is not watched again")
+	}
+	require.Equal(t, []string{"1", "4", "6"}, watcher.versions)
+	require.Equal(t, 600, reloadingConfig.get().LogsLimit)
+}
+
+func TestConfigMapPluginConfigKey(t *testing.T) {
+	source := &configMapSource{namespace: "openshift-logging", name: "plugin-config"}
+
+	config, err := source.parse(&kube.ConfigMap{Data: map[string]string{"plugin.yaml": "logsLimit: 10"}})
+	require.NoError(t, err)
+	require.Equal(t, 10, config.LogsLimit)
+
+	_, err = source.parse(&kube.ConfigMap{Data: map[string]string{"a.yaml": "", "b.yaml": ""}})
+	require.EqualError(t, err, "plugin config ConfigMap openshift-logging/plugin-config has no config.yaml key")
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadingPluginConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0600))
+		require.NoError(t, os.Chtimes(configFile, modTime, modTime))
+	}
+
+	now := time.Now()
+	writeConfig("timeout: 10s", now)
+
+	reloadingConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Second, reloadingConfig.get().Timeout.Duration)
+
+	reloaded, err := reloadingConfig.reload()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	writeConfig("timeout: 20s", now.Add(time.Minute))
+
+	reloaded, err = reloadingConfig.reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+
+	w := httptest.NewRecorder()
+	configHandler(reloadingConfig).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	pluginConfig := PluginConfig{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pluginConfig))
+	require.Equal(t, 20*time.Second, pluginConfig.Timeout.Duration)
+
+	writeConfig("timeout: -1s", now.Add(2*time.Minute))
+
+	reloaded, err = reloadingConfig.reload()
+	require.Error(t, err)
+	require.False(t, reloaded)
+	require.Equal(t, 20*time.Second, reloadingConfig.get().Timeout.Duration)
+}
+
+func TestWatchPluginConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("timeout: 10s"), 0600))
+
+	reloadingConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reloadingConfig.watch(ctx, time.Millisecond)
+	}()
+
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.WriteFile(configFile, []byte("timeout: 20s"), 0600))
+	require.NoError(t, os.Chtimes(configFile, modTime, modTime))
+	require.Eventually(t, func() bool {
+		return reloadingConfig.get().Timeout.Duration == 20*time.Second
+	}, 5*time.Second, time.Millisecond)
+
+	// the watch stops with its context
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the plugin config is still watched")
+	}
+}
+
+func TestParsePluginConfigValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         string
+		expectedErrors ConfigValidationErrors
+	}{
+		{
+			name:   "empty",
+			config: "",
+		},
+		{
+			name:   "front-end settings",
+			config: "logsLimit: 500\nisStreamingEnabledInDefaultPage: true\nlokiTenanLabelKey: tenantId",
+		},
+		{
+			name:   "unknown field",
+			config: "lokiUrl: https://loki:3100",
+			expectedErrors: ConfigValidationErrors{
+				{Message: "line 1: field lokiUrl not found in type server.PluginConfig"},
+			},
+		},
+		{
+			name:   "invalid types",
+			config: "logsLimit: many\ntail:\n  maxStreams: all",
+			expectedErrors: ConfigValidationErrors{
+				{Message: "line 1: cannot unmarshal !!str `many` into int"},
+				{Message: "line 3: cannot unmarshal !!str `all` into int"},
+			},
+		},
+		{
+			name:   "out of range",
+			config: "timeout: 1h\nlogsLimit: -1",
+			expectedErrors: ConfigValidationErrors{
+				{Field: "timeout", Message: "timeout must be between 0 and 10m0s"},
+				{Field: "logsLimit", Message: "logsLimit must be greater than 0"},
+			},
+		},
+		{
+			name:   "negative query cache",
+			config: "queryCache:\n  enabled: true\n  ttl: -1s\n  maxEntrySize: -1\n  disk:\n    path: /var/cache/plugin\n    maxSize: -1",
+			expectedErrors: ConfigValidationErrors{
+				{Field: "queryCache.ttl", Message: "ttl cannot be negative"},
+				{Field: "queryCache.maxEntrySize", Message: "maxEntrySize cannot be negative"},
+				{Field: "queryCache.disk.maxSize", Message: "maxSize cannot be negative"},
+			},
+		},
+		{
+			name:   "invalid compression",
+			config: "compression:\n  exportLevel: 10\n  minSize: -1\n  excludePaths: [/api/tail/*, metrics]",
+			expectedErrors: ConfigValidationErrors{
+				{Field: "compression.exportLevel", Message: "exportLevel must be between 1 and 9"},
+				{Field: "compression.minSize", Message: "minSize cannot be negative"},
+				{Field: "compression.excludePaths[0]", Message: `invalid path prefix "/api/tail/*", expected /<path>`},
+				{Field: "compression.excludePaths[1]", Message: `invalid path prefix "metrics", expected /<path>`},
+			},
+		},
+		{
+			name:   "invalid watchdog",
+			config: "watchdog:\n  timeout: 30s\n  maxSchedulingDelay: -1s",
+			expectedErrors: ConfigValidationErrors{
+				{Field: "watchdog.timeout", Message: "timeout must be at least 1m0s"},
+				{Field: "watchdog.maxSchedulingDelay", Message: "maxSchedulingDelay cannot be negative"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePluginConfig([]byte(tt.config))
+			if tt.expectedErrors == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Equal(t, tt.expectedErrors, err)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// podNameRegexp matches the pod names, DNS subdomains
+var podNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// podLogParams are the parameters of the pod log subresource sent to the API
+// server, the other ones are dropped
+var podLogParams = []string{"container", "follow", "previous", "sinceSeconds", "sinceTime", "tailLines", "limitBytes", "timestamps"}
+
+// podLogsHandler serves the logs of the pods of a kubernetes datasource at
+// /<namespace>/<pod>/log from the log subresource of the API server. The
+// requests are sent with the bearer token of the user, the API server
+// authorizes them. With follow=true the logs are streamed up to the maximum
+// tail duration
+func podLogsHandler(ds DatasourceConfig, pluginConfig *PluginConfig) http.Handler {
+	if ds.URL == "" {
+		apiServerURL, caFile, err := kube.InClusterAPIServer()
+		if err != nil {
+			return unavailableDatasourceHandler(ds, err)
+		}
+		ds.URL = apiServerURL
+		if ds.CAFile == "" {
+			ds.CAFile = caFile
+		}
+	}
+	// the URL is validated when the plugin config is parsed
+	apiServerURL, _ := url.Parse(ds.URL)
+
+	transport, err := datasourceTransport(ds, pluginConfig.ProxyURL, pluginConfig.Upstream.Connections)
+	if err != nil {
+		return unavailableDatasourceHandler(ds, err)
+	}
+
+	forwardedHeaders := []string{"Authorization", requestIDHeader}
+	if pluginConfig.AllowImpersonation {
+		// the API server authorizes the impersonation
+		forwardedHeaders = append(forwardedHeaders, proxy.ImpersonateUserHeader, proxy.ImpersonateGroupHeader)
+	}
+
+	reverseProxy := &httputil.ReverseProxy{
+		Transport: transport,
+		// flush the followed logs as soon as they are written
+		FlushInterval: -1,
+		Director: func(r *http.Request) {
+			headers := http.Header{}
+			for _, name := range forwardedHeaders {
+				if values := r.Header.Values(name); len(values) > 0 {
+					headers[name] = values
+				}
+			}
+
+			r.URL.Scheme = apiServerURL.Scheme
+			r.URL.Host = apiServerURL.Host
+			r.Host = apiServerURL.Host
+			r.Header = headers
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.StatusCode >= http.StatusBadRequest {
+				return kube.ResponseError(resp)
+			}
+			return nil
+		},
+		ErrorHandler: writePodLogsError,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, errorCodeMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method), nil)
+			return
+		}
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if len(parts) != 3 || parts[2] != "log" {
+			writeError(w, r, http.StatusNotFound, errorCodeNotFound, "the pod logs are served at /<namespace>/<pod>/log", nil)
+			return
+		}
+		namespace, pod := parts[0], parts[1]
+		if !namespaceRegexp.MatchString(namespace) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid namespace %q", namespace), nil)
+			return
+		}
+		if !podNameRegexp.MatchString(pod) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid pod name %q", pod), nil)
+			return
+		}
+
+		params := r.URL.Query()
+		query := url.Values{}
+		for _, name := range podLogParams {
+			if values, ok := params[name]; ok {
+				query[name] = values
+			}
+		}
+
+		timeout := pluginConfig.Timeout.Duration
+		if follow, _ := strconv.ParseBool(params.Get("follow")); follow {
+			timeout = pluginConfig.Tail.MaxDuration
+		}
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
+		r.URL.Path = strings.TrimSuffix(apiServerURL.Path, "/") + fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log", namespace, pod)
+		r.URL.RawPath = ""
+		r.URL.RawQuery = query.Encode()
+
+		reverseProxy.ServeHTTP(w, r)
+	})
+}
+
+// writePodLogsError replies with the error of the API server, or a bad gateway
+// error when it cannot be reached
+func writePodLogsError(w http.ResponseWriter, r *http.Request, err error) {
+	var statusErr *kube.StatusError
+	if !errors.As(err, &statusErr) {
+		requestLog(slog, r).WithError(err).Warn("cannot proxy request to the API server")
+		metrics.UpstreamErrorsTotal.WithLabelValues("kubernetes", "unavailable").Inc()
+		writeError(w, r, http.StatusBadGateway, errorCodeUpstreamUnavailable, "cannot reach the API server", err.Error())
+		return
+	}
+
+	switch statusErr.Code {
+	case http.StatusBadRequest:
+		writeError(w, r, statusErr.Code, errorCodeInvalidRequest, statusErr.Message, nil)
+	case http.StatusUnauthorized:
+		writeError(w, r, statusErr.Code, errorCodeUnauthorized, statusErr.Message, nil)
+	case http.StatusForbidden:
+		writeError(w, r, statusErr.Code, errorCodeForbidden, statusErr.Message, nil)
+	case http.StatusNotFound:
+		writeError(w, r, statusErr.Code, errorCodeNotFound, statusErr.Message, nil)
+	default:
+		metrics.UpstreamErrorsTotal.WithLabelValues("kubernetes", strconv.Itoa(statusErr.Code)).Inc()
+		writeError(w, r, http.StatusBadGateway, errorCodeUpstreamUnavailable, "the API server cannot serve the pod logs", statusErr.Error())
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodLogsHandler(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/namespaces/my-app/pods/missing/log" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","reason":"NotFound","message":"pods \"missing\" not found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%s?%s %s", r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization"))
+	}))
+	defer apiServer.Close()
+
+	handler := podLogsHandler(DatasourceConfig{Name: "pods", Type: datasourceTypeKubernetes, URL: apiServer.URL}, &PluginConfig{})
+
+	tests := []struct {
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			method:         http.MethodGet,
+			path:           "/my-app/my-pod-1/log?container=app&tailLines=10&insecureSkipTLSVerifyBackend=true",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/api/v1/namespaces/my-app/pods/my-pod-1/log?container=app&tailLines=10 Bearer user-token",
+		},
+		{method: http.MethodGet, path: "/my-app/missing/log", expectedStatus: http.StatusNotFound, expectedBody: `pods \"missing\" not found`},
+		{method: http.MethodGet, path: "/My-App/my-pod-1/log", expectedStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/my-app/my-pod-1/exec", expectedStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/my-app/../log", expectedStatus: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/my-app/my-pod-1/log", expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			r.Header.Set("Authorization", "Bearer user-token")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			require.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestPodLogsHandlerFollow(t *testing.T) {
+	done := make(chan struct{})
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "true", r.URL.Query().Get("follow"))
+		w.Write([]byte("first line\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer apiServer.Close()
+	defer close(done)
+
+	plugin := httptest.NewServer(podLogsHandler(
+		DatasourceConfig{Name: "pods", Type: datasourceTypeKubernetes, URL: apiServer.URL},
+		&PluginConfig{Tail: TailConfig{MaxDuration: time.Minute}},
+	))
+	defer plugin.Close()
+
+	resp, err := http.Get(plugin.URL + "/my-app/my-pod-1/log?follow=true")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the first line is received while the API server still streams
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "first line\n", line)
+}
+
+func TestPodLogsRoutes(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+datasources:
+  - name: pods
+    type: kubernetes
+    url: https://api.cluster.local:6443
+    caFile: missing-ca.crt
+`), 0600))
+
+	pluginConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{StaticPath: t.TempDir()}, pluginConfig, routeDeps{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/pods/pods/my-app/my-pod-1/log", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), "datasource pods is unavailable")
+
+	// the kubernetes datasources do not proxy Loki queries
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/proxy/pods/application/loki/api/v1/labels", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+)
+
+// featureDevProfiling enables the pprof endpoints under /debug/pprof/
+const featureDevProfiling = "dev-profiling"
+
+// registerProfilingRoutes serves the runtime profiles of the net/http/pprof
+// package behind middleware, the adminMiddleware of the routes. The profiles
+// have no response deadline
+func registerProfilingRoutes(r *mux.Router, middleware func(http.Handler) http.Handler) {
+	r.Path("/debug/pprof/cmdline").Handler(middleware(http.HandlerFunc(pprof.Cmdline)))
+	r.Path("/debug/pprof/profile").Handler(middleware(http.HandlerFunc(pprof.Profile)))
+	r.Path("/debug/pprof/symbol").Handler(middleware(http.HandlerFunc(pprof.Symbol)))
+	r.Path("/debug/pprof/trace").Handler(middleware(http.HandlerFunc(pprof.Trace)))
+	// the index also serves the named profiles like heap and goroutine
+	r.PathPrefix("/debug/pprof/").Handler(middleware(http.HandlerFunc(pprof.Index)))
+}
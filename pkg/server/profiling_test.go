@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfilingRoutes(t *testing.T) {
+	config, err := parsePluginConfig([]byte("admin:\n  groups: [admins]\n"))
+	require.NoError(t, err)
+	pluginConfig := &reloadingPluginConfig{config: config}
+
+	staticDir := t.TempDir()
+
+	tests := []struct {
+		name           string
+		features       map[string]bool
+		path           string
+		remoteAddr     string
+		token          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "disabled",
+			features:       map[string]bool{},
+			path:           "/debug/pprof/",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "index",
+			features:       map[string]bool{featureDevProfiling: true},
+			path:           "/debug/pprof/",
+			remoteAddr:     "127.0.0.1:41000",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "goroutine",
+		},
+		{
+			name:           "named profile",
+			features:       map[string]bool{featureDevProfiling: true},
+			path:           "/debug/pprof/heap?debug=1",
+			remoteAddr:     "127.0.0.1:41000",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "heap profile",
+		},
+		{
+			name:           "remote without authentication",
+			features:       map[string]bool{featureDevProfiling: true},
+			path:           "/debug/pprof/cmdline",
+			remoteAddr:     "10.128.0.12:41000",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "not an admin",
+			features:       map[string]bool{featureDevProfiling: true},
+			path:           "/debug/pprof/cmdline",
+			token:          "developer",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "admin",
+			features:       map[string]bool{featureDevProfiling: true},
+			path:           "/debug/pprof/heap?debug=1",
+			token:          "admin",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "heap profile",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := routeDeps{}
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.remoteAddr != "" {
+				r.RemoteAddr = tt.remoteAddr
+			}
+			if tt.token != "" {
+				deps.authenticator = newAdminTestAuthenticator()
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			router := setupRoutes(&Config{Features: tt.features, StaticPath: staticDir}, pluginConfig, deps)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/openshift/logging-view-plugin/pkg/datasource"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// newDatasource builds the backend serving the queries of ds, by type
+func newDatasource(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps) datasource.Datasource {
+	if ds.Type == datasourceTypeFederated {
+		return newFederatedDatasource(ds, pluginConfig, deps)
+	}
+
+	proxyConfig, err := lokiProxyConfig(ds, pluginConfig, deps)
+	if err != nil {
+		return unavailableDatasource{unavailableDatasourceHandler(ds, err)}
+	}
+
+	cfg := datasource.Config{
+		Name:          ds.Name,
+		Proxy:         proxyConfig,
+		MetadataCache: pluginConfig.MetadataCache.proxyCacheConfig(),
+		Tail: proxy.TailLimits{
+			MaxStreams:   pluginConfig.Tail.MaxStreams,
+			MaxDuration:  pluginConfig.Tail.MaxDuration,
+			PingInterval: pluginConfig.Tail.PingInterval,
+		},
+		Options: ds.decodeOptions,
+	}
+
+	typ := ds.Type
+	switch typ {
+	case "":
+		typ = datasource.TypeLoki
+	case datasourceTypeElasticsearch:
+		return datasource.NewElasticsearch(cfg, ds.Elasticsearch.mapping())
+	}
+
+	backend, err := datasource.New(typ, cfg)
+	if err != nil {
+		return unavailableDatasource{unavailableDatasourceHandler(ds, err)}
+	}
+	return backend
+}
+
+// unavailableDatasource replies to every query with its handler
+type unavailableDatasource struct {
+	http.Handler
+}
+
+func (d unavailableDatasource) Query(w http.ResponseWriter, r *http.Request) {
+	d.ServeHTTP(w, r)
+}
+
+func (d unavailableDatasource) QueryRange(w http.ResponseWriter, r *http.Request) {
+	d.ServeHTTP(w, r)
+}
+
+func (d unavailableDatasource) Labels(w http.ResponseWriter, r *http.Request) {
+	d.ServeHTTP(w, r)
+}
+
+func (d unavailableDatasource) Tail(w http.ResponseWriter, r *http.Request) {
+	d.ServeHTTP(w, r)
+}
+
+func lokiProxyConfig(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps) (proxy.Config, error) {
+	// the URL is validated when the plugin config is parsed
+	lokiURL, _ := url.Parse(ds.URL)
+
+	transport, err := datasourceTransport(ds, pluginConfig.ProxyURL, pluginConfig.Upstream.Connections)
+	if err != nil {
+		return proxy.Config{}, err
+	}
+
+	proxyConfig := proxy.Config{
+		URL:                  lokiURL,
+		UseTenantInHeader:    ds.UseTenantInHeader,
+		TenantRouting:        ds.TenantRoutingMode,
+		Timeout:              pluginConfig.Timeout.Duration,
+		TenantTimeouts:       pluginConfig.tenantTimeouts(),
+		MinTimeout:           pluginConfig.Upstream.MinTimeout,
+		TimeoutPerHour:       pluginConfig.Upstream.TimeoutPerHour,
+		Transport:            transport,
+		ErrorHandler:         writeProxyError,
+		Tracer:               deps.tracer,
+		Cache:                pluginConfig.QueryCache.proxyCacheConfig(),
+		OnQuery:              queryHistoryRecorder(deps.queryHistory),
+		Name:                 ds.Name,
+		MaxInFlight:          pluginConfig.Upstream.MaxInFlight,
+		Breaker:              deps.breakers[ds.Name],
+		MaxSeries:            pluginConfig.Guardrails.MaxSeries,
+		MaxResponseSize:      int64(pluginConfig.Guardrails.MaxResponseSize),
+		ForwardImpersonation: pluginConfig.AllowImpersonation,
+		Retry:                pluginConfig.Upstream.Retry.proxyRetryConfig(),
+		Shadow:               deps.shadows[ds.Name],
+	}
+	if deps.serviceAccountToken != nil {
+		proxyConfig.Token = deps.serviceAccountToken.Token
+	}
+	proxyConfig.Cache.Disk = deps.queryDiskCache
+
+	return proxyConfig, nil
+}
+
+// unavailableDatasourceHandler replies to the queries of a datasource whose
+// transport cannot be set up, like when its CA file is missing
+func unavailableDatasourceHandler(ds DatasourceConfig, err error) http.Handler {
+	slog.WithError(err).Errorf("datasource %s is unavailable", ds.Name)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, fmt.Sprintf("datasource %s is unavailable", ds.Name), err.Error())
+	})
+}
+
+func writeProxyError(w http.ResponseWriter, r *http.Request, err *proxy.Error) {
+	details := err.Details
+	if details == nil && err.Err != nil {
+		details = err.Err.Error()
+	}
+	if err.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	}
+	writeError(w, r, err.Status, err.Code, err.Message, details)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// QueryCacheConfig caches the responses of the proxied queries in memory
+type QueryCacheConfig struct {
+	Enabled    bool          `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	TTL        time.Duration `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+	MaxEntries int           `yaml:"maxEntries,omitempty" json:"maxEntries,omitempty"`
+	// MaxEntrySize is the size in bytes of the largest cached response
+	MaxEntrySize int `yaml:"maxEntrySize,omitempty" json:"maxEntrySize,omitempty"`
+	// Disk also stores the responses of the queries over a completed range
+	// on disk when its path is set
+	Disk QueryDiskCacheConfig `yaml:"disk,omitempty" json:"disk,omitempty"`
+}
+
+// QueryDiskCacheConfig stores the responses of the queries over a completed
+// range in a directory, like an emptyDir or a persistent volume, so that they
+// survive the restarts of the plugin
+type QueryDiskCacheConfig struct {
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	// MaxSize is the size in bytes of the cached files, the least recently
+	// used are removed above it
+	MaxSize int64 `yaml:"maxSize,omitempty" json:"maxSize,omitempty"`
+	// MinAge is the age of the end of a range after which its logs are
+	// complete, the more recent ranges are only cached in memory
+	MinAge time.Duration `yaml:"minAge,omitempty" json:"minAge,omitempty"`
+}
+
+var defaultQueryCacheConfig = QueryCacheConfig{
+	TTL:          30 * time.Second,
+	MaxEntries:   500,
+	MaxEntrySize: 1 << 20,
+	Disk: QueryDiskCacheConfig{
+		MaxSize: 1 << 30,
+		MinAge:  5 * time.Minute,
+	},
+}
+
+// newQueryDiskCache opens the disk cache of the queries, nil when the query
+// cache or its disk are disabled
+func newQueryDiskCache(c QueryCacheConfig) (*proxy.DiskCache, error) {
+	if !c.Enabled || c.Disk.Path == "" {
+		return nil, nil
+	}
+	return proxy.NewDiskCache(c.Disk.Path, c.Disk.MaxSize, c.Disk.MinAge)
+}
+
+type authorizedQueryKey struct{}
+
+// withAuthorizedQuery marks r as authorized by the namespace authorization
+func withAuthorizedQuery(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), authorizedQueryKey{}, true))
+}
+
+// queryCacheScope shares the cached responses between the users whose access
+// to the queried namespaces was checked by the authorization middleware, the
+// other responses are only served again to the same bearer token and
+// impersonated user
+func queryCacheScope(r *http.Request) string {
+	if authorized, _ := r.Context().Value(authorizedQueryKey{}).(bool); authorized {
+		return ""
+	}
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return ""
+	}
+	h := sha256.New()
+	h.Write([]byte(authorization))
+	for _, name := range []string{proxy.ImpersonateUserHeader, proxy.ImpersonateGroupHeader} {
+		for _, value := range r.Header.Values(name) {
+			h.Write([]byte("\n" + name + ": " + value))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c QueryCacheConfig) proxyCacheConfig() proxy.CacheConfig {
+	if !c.Enabled {
+		return proxy.CacheConfig{}
+	}
+	return proxy.CacheConfig{
+		TTL:          c.TTL,
+		MaxEntries:   c.MaxEntries,
+		MaxEntrySize: int64(c.MaxEntrySize),
+		Scope:        queryCacheScope,
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCacheScope(t *testing.T) {
+	anonymous := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.Equal(t, "", queryCacheScope(anonymous))
+
+	user := httptest.NewRequest(http.MethodGet, "/", nil)
+	user.Header.Set("Authorization", "Bearer user-token")
+	other := httptest.NewRequest(http.MethodGet, "/", nil)
+	other.Header.Set("Authorization", "Bearer other-token")
+	require.NotEmpty(t, queryCacheScope(user))
+	require.NotContains(t, queryCacheScope(user), "user-token")
+	require.NotEqual(t, queryCacheScope(user), queryCacheScope(other))
+
+	impersonating := user.Clone(user.Context())
+	impersonating.Header.Set(proxy.ImpersonateUserHeader, "alice")
+	require.NotEqual(t, queryCacheScope(user), queryCacheScope(impersonating))
+
+	require.Equal(t, "", queryCacheScope(withAuthorizedQuery(user)))
+}
+
+func TestQueryCacheDefaults(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte("queryCache:\n  enabled: true"))
+	require.NoError(t, err)
+
+	cacheConfig := pluginConfig.QueryCache.proxyCacheConfig()
+	require.Equal(t, 30*time.Second, cacheConfig.TTL)
+	require.Equal(t, 500, cacheConfig.MaxEntries)
+	require.Equal(t, int64(1<<20), cacheConfig.MaxEntrySize)
+	require.NotNil(t, cacheConfig.Scope)
+
+	pluginConfig, err = parsePluginConfig([]byte(""))
+	require.NoError(t, err)
+	require.Zero(t, pluginConfig.QueryCache.proxyCacheConfig().TTL)
+}
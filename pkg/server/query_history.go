@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+	"github.com/openshift/logging-view-plugin/pkg/store"
+)
+
+const (
+	// queryHistorySyncInterval is the time between two persistences of the
+	// query history
+	queryHistorySyncInterval = 30 * time.Second
+	// defaultSlowestQueries is the number of queries listed to the admins
+	defaultSlowestQueries = 50
+)
+
+// QueryHistoryConfig records the last queries of every user, the history is
+// kept in memory and persisted in ConfigMap when set
+type QueryHistoryConfig struct {
+	Enabled    bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	MaxPerUser int  `yaml:"maxPerUser,omitempty" json:"maxPerUser,omitempty"`
+	// ConfigMap is the <namespace>/<name> of the ConfigMap persisting the
+	// history
+	ConfigMap string `yaml:"configMap,omitempty" json:"configMap,omitempty"`
+	// AdminGroups are the groups whose members can list the slowest queries
+	// of all the users
+	AdminGroups []string `yaml:"adminGroups,omitempty" json:"adminGroups,omitempty"`
+}
+
+var defaultQueryHistoryConfig = QueryHistoryConfig{
+	MaxPerUser: 20,
+}
+
+func (c QueryHistoryConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.ConfigMap != "" {
+		if _, _, err := kube.ParseNamespacedName(c.ConfigMap); err != nil {
+			errs = append(errs, ConfigValidationError{Field: "queryHistory.configMap", Message: err.Error()})
+		}
+	}
+	if c.MaxPerUser < 0 {
+		errs = append(errs, ConfigValidationError{Field: "queryHistory.maxPerUser", Message: "maxPerUser cannot be negative"})
+	}
+	return errs
+}
+
+// newQueryHistory builds the query history and loads its persisted entries
+func newQueryHistory(ctx context.Context, client *kube.Client, cfg QueryHistoryConfig) (*store.History, error) {
+	if cfg.ConfigMap == "" {
+		return store.NewHistory(cfg.MaxPerUser), nil
+	}
+
+	// the reference is validated when the plugin config is parsed
+	namespace, name, _ := kube.ParseNamespacedName(cfg.ConfigMap)
+	history := store.NewConfigMapHistory(cfg.MaxPerUser, client, namespace, name)
+	if err := history.Load(ctx); err != nil {
+		return nil, err
+	}
+
+	slog.Infof("persisting query history in ConfigMap %s/%s", namespace, name)
+	go history.Run(ctx, queryHistorySyncInterval)
+
+	return history, nil
+}
+
+// queryHistoryRecorder returns the proxy callback recording the queries of
+// the authenticated users, nil when the history is disabled
+func queryHistoryRecorder(history *store.History) func(context.Context, proxy.QueryStats) {
+	if history == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, stats proxy.QueryStats) {
+		user, ok := ctx.Value(userKey{}).(*kube.UserInfo)
+		if !ok {
+			return
+		}
+
+		entry := store.HistoryEntry{
+			User:            user.Username,
+			Query:           stats.Query,
+			Tenant:          stats.Tenant,
+			Endpoint:        stats.Endpoint,
+			Time:            time.Now().UTC(),
+			DurationSeconds: stats.Duration.Seconds(),
+			Status:          stats.Status,
+		}
+		if !stats.Start.IsZero() {
+			entry.Start = &stats.Start
+		}
+		if !stats.End.IsZero() {
+			entry.End = &stats.End
+		}
+		if stats.Results >= 0 {
+			entry.Results = &stats.Results
+		}
+		if stats.BytesProcessed >= 0 {
+			entry.BytesProcessed = &stats.BytesProcessed
+		}
+
+		history.Record(entry)
+	}
+}
+
+// queryHistoryHandler serves the recent queries of the user, the members of
+// adminGroups can list the slowest queries of all the users with scope=all
+func queryHistoryHandler(history *store.History, adminGroups []string) http.HandlerFunc {
+	admins := map[string]bool{}
+	for _, group := range adminGroups {
+		admins[group] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requestUser(r)
+		if !ok {
+			writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "the request is not authenticated", nil)
+			return
+		}
+
+		params := r.URL.Query()
+		if params.Get("scope") != "all" {
+			writeJSON(w, r, http.StatusOK, history.List(user.Username))
+			return
+		}
+
+		isAdmin := false
+		for _, group := range user.Groups {
+			isAdmin = isAdmin || admins[group]
+		}
+		if !isAdmin {
+			writeError(w, r, http.StatusForbidden, errorCodeForbidden, fmt.Sprintf("user %s cannot list the queries of all the users", user.Username), nil)
+			return
+		}
+
+		limit := defaultSlowestQueries
+		if value := params.Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid limit %q", value), nil)
+				return
+			}
+			limit = n
+		}
+
+		writeJSON(w, r, http.StatusOK, history.Slowest(limit))
+	})
+}
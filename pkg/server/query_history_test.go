@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/datasource"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryHistory(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[{"stream":{},"values":[["1","a"],["2","b"]]}]}}`))
+	}))
+	defer upstream.Close()
+
+	history := store.NewHistory(10)
+	pluginConfig, err := parsePluginConfig([]byte("lokiURL: " + upstream.URL))
+	require.NoError(t, err)
+	ds, _ := pluginConfig.defaultDatasource()
+	proxyHandler := datasource.QueryHandler(newDatasource(ds, pluginConfig, routeDeps{queryHistory: history}), writeProxyError)
+	handler := queryHistoryHandler(history, []string{"cluster-admins"})
+
+	users := map[string]*kube.UserInfo{
+		"alice": {Username: "alice"},
+		"admin": {Username: "admin", Groups: []string{"cluster-admins"}},
+	}
+	withUser := func(r *http.Request, user string) *http.Request {
+		return r.WithContext(context.WithValue(r.Context(), userKey{}, users[user]))
+	}
+
+	query := url.Values{"query": {`{app="api"}`}, "start": {"1700000000"}, "end": {"1700003600"}}
+	r := withUser(httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?"+query.Encode(), nil), "alice")
+	proxyHandler.ServeHTTP(httptest.NewRecorder(), r)
+
+	tests := []struct {
+		name            string
+		user            string
+		query           string
+		expectedStatus  int
+		expectedEntries int
+	}{
+		{name: "own history", user: "alice", expectedStatus: http.StatusOK, expectedEntries: 1},
+		{name: "empty history", user: "admin", expectedStatus: http.StatusOK, expectedEntries: 0},
+		{name: "slowest queries", user: "admin", query: "scope=all", expectedStatus: http.StatusOK, expectedEntries: 1},
+		{name: "slowest queries of non admin", user: "alice", query: "scope=all", expectedStatus: http.StatusForbidden},
+		{name: "invalid limit", user: "admin", query: "scope=all&limit=0", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, withUser(httptest.NewRequest(http.MethodGet, "/api/history?"+tc.query, nil), tc.user))
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			entries := []store.HistoryEntry{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+			require.Len(t, entries, tc.expectedEntries)
+			if tc.expectedEntries > 0 {
+				require.Equal(t, "alice", entries[0].User)
+				require.Equal(t, `{app="api"}`, entries[0].Query)
+				require.Equal(t, "application", entries[0].Tenant)
+				require.Equal(t, http.StatusOK, entries[0].Status)
+				require.Equal(t, 2, *entries[0].Results)
+				require.Nil(t, entries[0].BytesProcessed)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// queryParamViolation is the detail of an InvalidRequest error on a query
+// parameter
+type queryParamViolation struct {
+	Parameter string `json:"parameter"`
+	Value     string `json:"value"`
+}
+
+// queryParamsMiddleware rejects the queries with invalid parameters before
+// they reach the datasource, instead of relaying its errors: the tenant of
+// the path, the start, end and time, the positive limit, the direction and
+// the positive step. It serves the handlers whose paths start with the tenant
+func queryParamsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := checkQueryParams(r.URL.Path, r.URL.Query(), time.Now()); err != nil {
+			writeProxyError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkQueryParams returns the error of the first invalid query parameter,
+// nil when they are valid. The missing end is now
+func checkQueryParams(urlPath string, params url.Values, now time.Time) *proxy.Error {
+	tenant, _, _ := strings.Cut(strings.TrimPrefix(urlPath, "/"), "/")
+	if !tenantRegexp.MatchString(tenant) {
+		return queryParamError("tenant", tenant, fmt.Sprintf("invalid tenant %q, letters, digits, _ and - are expected", tenant))
+	}
+
+	times := map[string]time.Time{}
+	for _, name := range []string{"start", "end", "time"} {
+		value := params.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := proxy.ParseTime(value)
+		if err != nil {
+			return queryParamError(name, value, fmt.Sprintf("invalid %s %q, a nanosecond Unix epoch or RFC3339 date is expected", name, value))
+		}
+		times[name] = t
+	}
+	if start, ok := times["start"]; ok {
+		end, ok := times["end"]
+		if !ok {
+			end = now
+		}
+		if !start.Before(end) {
+			return queryParamError("start", params.Get("start"), "the start of the range must be before its end")
+		}
+	}
+
+	if value := params.Get("limit"); value != "" {
+		if limit, err := strconv.Atoi(value); err != nil || limit <= 0 {
+			return queryParamError("limit", value, fmt.Sprintf("invalid limit %q, a positive number of lines is expected", value))
+		}
+	}
+
+	if value := params.Get("direction"); value != "" {
+		if direction := strings.ToLower(value); direction != "forward" && direction != "backward" {
+			return queryParamError("direction", value, fmt.Sprintf("invalid direction %q, forward or backward is expected", value))
+		}
+	}
+
+	if value := params.Get("step"); value != "" {
+		step, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			var d time.Duration
+			d, err = logql.ParseDuration(value)
+			step = d.Seconds()
+		}
+		if err != nil || step <= 0 {
+			return queryParamError("step", value, fmt.Sprintf("invalid step %q, a positive duration or number of seconds is expected", value))
+		}
+	}
+
+	return nil
+}
+
+func queryParamError(parameter string, value string, message string) *proxy.Error {
+	return &proxy.Error{
+		Status:  http.StatusBadRequest,
+		Code:    errorCodeInvalidRequest,
+		Message: message,
+		Details: queryParamViolation{Parameter: parameter, Value: value},
+	}
+}
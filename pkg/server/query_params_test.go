@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckQueryParams(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name              string
+		path              string
+		params            url.Values
+		expectedParameter string
+	}{
+		{
+			name:   "valid query_range",
+			path:   "/application/loki/api/v1/query_range",
+			params: url.Values{"query": {`{app="foo"}`}, "start": {"1699990000"}, "end": {"2023-11-14T22:13:00Z"}, "limit": {"100"}, "direction": {"FORWARD"}, "step": {"1m"}},
+		},
+		{
+			name:   "start without end",
+			path:   "/application",
+			params: url.Values{"start": {"1699990000000000000"}, "step": {"30.5"}},
+		},
+		{
+			name:              "invalid tenant",
+			path:              "/app.lication/loki/api/v1/query",
+			expectedParameter: "tenant",
+		},
+		{
+			name:              "invalid start",
+			path:              "/application/loki/api/v1/query_range",
+			params:            url.Values{"start": {"yesterday"}},
+			expectedParameter: "start",
+		},
+		{
+			name:              "invalid time",
+			path:              "/application/loki/api/v1/query",
+			params:            url.Values{"time": {"now"}},
+			expectedParameter: "time",
+		},
+		{
+			name:              "start after end",
+			path:              "/application/loki/api/v1/query_range",
+			params:            url.Values{"start": {"1699990000"}, "end": {"1699980000"}},
+			expectedParameter: "start",
+		},
+		{
+			name:              "start after now",
+			path:              "/application/loki/api/v1/query_range",
+			params:            url.Values{"start": {"1700000001"}},
+			expectedParameter: "start",
+		},
+		{
+			name:              "negative limit",
+			path:              "/application/loki/api/v1/query_range",
+			params:            url.Values{"limit": {"-1"}},
+			expectedParameter: "limit",
+		},
+		{
+			name:              "invalid direction",
+			path:              "/application/loki/api/v1/query_range",
+			params:            url.Values{"direction": {"sideways"}},
+			expectedParameter: "direction",
+		},
+		{
+			name:              "zero step",
+			path:              "/application/loki/api/v1/query_range",
+			params:            url.Values{"step": {"0s"}},
+			expectedParameter: "step",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkQueryParams(tt.path, tt.params, now)
+			if tt.expectedParameter == "" {
+				require.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			require.Equal(t, http.StatusBadRequest, err.Status)
+			require.Equal(t, errorCodeInvalidRequest, err.Code)
+			require.Equal(t, tt.expectedParameter, err.Details.(queryParamViolation).Parameter)
+		})
+	}
+}
+
+func TestQueryParamsMiddleware(t *testing.T) {
+	called := false
+	handler := queryParamsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?limit=abc", nil)
+	r.Header.Set("Accept", "application/json")
+	handler.ServeHTTP(w, r)
+	require.False(t, called)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	body := struct {
+		Error struct {
+			Code    string              `json:"code"`
+			Details queryParamViolation `json:"details"`
+		} `json:"error"`
+	}{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, errorCodeInvalidRequest, body.Error.Code)
+	require.Equal(t, queryParamViolation{Parameter: "limit", Value: "abc"}, body.Error.Details)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/query_range?limit=10", nil))
+	require.True(t, called)
+}
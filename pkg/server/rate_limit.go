@@ -0,0 +1,175 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+// maxRateLimitKeys bounds the number of users and clients tracked by the
+// rate limiter, the idle ones are evicted first
+const maxRateLimitKeys = 10000
+
+// RateLimitConfig limits the requests of every user to the proxy and config
+// routes, or of every client IP without authentication, with a token bucket
+// refilled with RequestsPerSecond tokens up to Burst
+type RateLimitConfig struct {
+	Enabled           bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	RequestsPerSecond int  `yaml:"requestsPerSecond,omitempty" json:"requestsPerSecond,omitempty"`
+	Burst             int  `yaml:"burst,omitempty" json:"burst,omitempty"`
+}
+
+var defaultRateLimitConfig = RateLimitConfig{
+	RequestsPerSecond: 10,
+	Burst:             20,
+}
+
+func (c RateLimitConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.RequestsPerSecond < 0 {
+		errs = append(errs, ConfigValidationError{Field: "rateLimit.requestsPerSecond", Message: "requestsPerSecond cannot be negative"})
+	}
+	if c.Burst < 0 {
+		errs = append(errs, ConfigValidationError{Field: "rateLimit.burst", Message: "burst cannot be negative"})
+	}
+	return errs
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps a token bucket per key
+type rateLimiter struct {
+	rate    float64
+	burst   float64
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// newRateLimiter returns the rate limiter of cfg, nil when disabled
+func newRateLimiter(cfg RateLimitConfig) *rateLimiter {
+	if !cfg.Enabled || cfg.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := cfg.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    float64(cfg.RequestsPerSecond),
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+		now:     time.Now,
+	}
+}
+
+// rateLimitQuota is the state of the bucket of a key after a request
+type rateLimitQuota struct {
+	allowed bool
+	// remaining is the number of requests allowed right away
+	remaining int
+	// wait is the time to wait for the next token when the bucket is empty
+	wait time.Duration
+	// reset is the time until the bucket is full again
+	reset time.Duration
+}
+
+// allow takes a token from the bucket of key, the quota tells the time to
+// wait for the next token when the bucket is empty
+func (l *rateLimiter) allow(key string) rateLimitQuota {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitKeys {
+			l.evictIdle(now)
+		}
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	quota := rateLimitQuota{allowed: bucket.tokens >= 1}
+	if quota.allowed {
+		bucket.tokens--
+	} else {
+		quota.wait = time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+	quota.remaining = int(bucket.tokens)
+	quota.reset = time.Duration((l.burst - bucket.tokens) / l.rate * float64(time.Second))
+	return quota
+}
+
+// evictIdle removes the buckets refilled since their last request, they are
+// identical to new buckets. The oldest half is removed when none is full
+func (l *rateLimiter) evictIdle(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+	if len(l.buckets) < maxRateLimitKeys {
+		return
+	}
+	for key := range l.buckets {
+		delete(l.buckets, key)
+		if len(l.buckets) < maxRateLimitKeys/2 {
+			return
+		}
+	}
+}
+
+// rateLimitKey identifies the authenticated user of r, or its client IP
+func rateLimitKey(r *http.Request) string {
+	if user, ok := requestUser(r); ok {
+		return "user:" + user.Username
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// rateLimitMiddleware replies with a 429 error and a Retry-After header to the
+// requests exceeding the rate of their user or client, it must run after
+// authenticationMiddleware. Every response tells the quota left in the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, so that
+// the clients can slow down before they are limited. It does nothing when
+// limiter is nil
+func rateLimitMiddleware(limiter *rateLimiter, route string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			quota := limiter.allow(rateLimitKey(r))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(int(limiter.burst)))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(quota.remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(quota.reset.Seconds()))))
+			if !quota.allowed {
+				metrics.RateLimitedRequestsTotal.WithLabelValues(route).Inc()
+				retryAfter := int(math.Ceil(quota.wait.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeError(w, r, http.StatusTooManyRequests, errorCodeTooManyRequests, fmt.Sprintf("too many requests, retry in %ds", retryAfter), nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(RateLimitConfig{Enabled: true, RequestsPerSecond: 2, Burst: 3})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		require.True(t, limiter.allow("user:alice").allowed)
+	}
+
+	quota := limiter.allow("user:alice")
+	require.False(t, quota.allowed)
+	require.Equal(t, 500*time.Millisecond, quota.wait)
+	require.Equal(t, 0, quota.remaining)
+	require.Equal(t, 1500*time.Millisecond, quota.reset)
+
+	// the buckets are independent
+	quota = limiter.allow("user:bob")
+	require.True(t, quota.allowed)
+	require.Equal(t, 2, quota.remaining)
+	require.Equal(t, 500*time.Millisecond, quota.reset)
+
+	now = now.Add(500 * time.Millisecond)
+	require.True(t, limiter.allow("user:alice").allowed)
+	require.False(t, limiter.allow("user:alice").allowed)
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	require.Nil(t, newRateLimiter(RateLimitConfig{RequestsPerSecond: 10, Burst: 10}))
+	require.Nil(t, newRateLimiter(RateLimitConfig{Enabled: true}))
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{Enabled: true, RequestsPerSecond: 1, Burst: 1})
+	handler := rateLimitMiddleware(limiter, "proxy")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(remoteAddr string, user string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/labels", nil)
+		r.RemoteAddr = remoteAddr
+		if user != "" {
+			r = r.WithContext(context.WithValue(r.Context(), userKey{}, &kube.UserInfo{Username: user}))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send("10.0.0.1:40000", "alice")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "1", w.Header().Get("RateLimit-Reset"))
+
+	// the users are limited regardless of their client
+	w = send("10.0.0.2:40000", "alice")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	require.Contains(t, w.Body.String(), `"code":"TooManyRequests"`)
+
+	// the anonymous requests are limited by client IP
+	require.Equal(t, http.StatusOK, send("10.0.0.1:40001", "").Code)
+	require.Equal(t, http.StatusTooManyRequests, send("10.0.0.1:40002", "").Code)
+	require.Equal(t, http.StatusOK, send("10.0.0.3:40000", "").Code)
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte("rateLimit:\n  enabled: true"))
+	require.NoError(t, err)
+	require.Equal(t, RateLimitConfig{Enabled: true, RequestsPerSecond: 10, Burst: 20}, pluginConfig.RateLimit)
+
+	_, err = parsePluginConfig([]byte("rateLimit:\n  requestsPerSecond: -1"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "rateLimit.requestsPerSecond", Message: "requestsPerSecond cannot be negative"},
+	}, err)
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+const (
+	// readinessCacheTTL is the time the readiness checks results are reused
+	readinessCacheTTL = 10 * time.Second
+	// readinessCheckTimeout bounds every readiness check
+	readinessCheckTimeout = 5 * time.Second
+)
+
+// readinessCheck verifies one dependency of the backend
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+type readinessCheckResult struct {
+	Name    string `json:"name"`
+	Ready   bool   `json:"ready"`
+	Error   string `json:"error,omitempty"`
+	Warning string `json:"warning,omitempty"`
+}
+
+// readiness statuses, a warning keeps the backend ready
+const (
+	readinessStatusOK      = "ok"
+	readinessStatusWarning = "warning"
+	readinessStatusUnready = "unready"
+)
+
+type readinessResponse struct {
+	Ready  bool                   `json:"ready"`
+	Status string                 `json:"status"`
+	Checks []readinessCheckResult `json:"checks"`
+	// CircuitBreakers are the states of the datasource circuit breakers, an
+	// open circuit does not make the backend unready
+	CircuitBreakers map[string]string `json:"circuitBreakers,omitempty"`
+}
+
+// readinessChecker runs the readiness checks and caches their results so that
+// frequent probes do not overload the dependencies
+type readinessChecker struct {
+	checks    []readinessCheck
+	breakers  map[string]*proxy.Breaker
+	mu        sync.Mutex
+	response  readinessResponse
+	lastCheck time.Time
+}
+
+func newReadinessChecker(cfg *Config, pluginConfig *PluginConfig, breakers map[string]*proxy.Breaker, certificates *servingCertificates) *readinessChecker {
+	checks := []readinessCheck{}
+
+	if cfg.CertFile != "" && cfg.PrivateKeyFile != "" {
+		checks = append(checks, readinessCheck{name: "certificate", check: func(context.Context) error {
+			_, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.PrivateKeyFile)
+			return err
+		}})
+	}
+
+	if certificates != nil {
+		checks = append(checks, readinessCheck{name: "certificate-expiry", check: certificateExpiryCheck(certificates, cfg.CertExpiryWarning, time.Now)})
+	}
+
+	for _, ds := range pluginConfig.allDatasources() {
+		if !ds.isLoki() {
+			continue
+		}
+		name := "loki"
+		if ds.Name != defaultDatasourceName {
+			name = "loki-" + ds.Name
+		}
+		readyURL := strings.TrimSuffix(ds.URL, "/") + "/ready"
+		client := &http.Client{Timeout: readinessCheckTimeout}
+		if transport, err := datasourceTransport(ds, pluginConfig.ProxyURL, pluginConfig.Upstream.Connections); err != nil {
+			checks = append(checks, readinessCheck{name: name, check: func(context.Context) error {
+				return err
+			}})
+			continue
+		} else if transport != nil {
+			client.Transport = transport
+		}
+		checks = append(checks, readinessCheck{name: name, check: func(ctx context.Context) error {
+			return checkLokiReachable(ctx, client, readyURL)
+		}})
+	}
+
+	return &readinessChecker{checks: checks, breakers: breakers}
+}
+
+// checkLokiReachable considers Loki reachable unless the request fails or
+// returns a server error, the gateway may reject the unauthenticated request
+func checkLokiReachable(ctx context.Context, client *http.Client, readyURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, readyURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s returned %s", readyURL, resp.Status)
+	}
+
+	return nil
+}
+
+func (c *readinessChecker) check(ctx context.Context) readinessResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.lastCheck.IsZero() && time.Since(c.lastCheck) < readinessCacheTTL {
+		return c.response
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	response := readinessResponse{Ready: true, Status: readinessStatusOK, Checks: make([]readinessCheckResult, 0, len(c.checks))}
+	for _, check := range c.checks {
+		result := readinessCheckResult{Name: check.name, Ready: true}
+		var warning *readinessWarning
+		if err := check.check(ctx); errors.As(err, &warning) {
+			slog.Warnf("readiness check %s: %s", check.name, warning.message)
+			result.Warning = warning.message
+			if response.Ready {
+				response.Status = readinessStatusWarning
+			}
+		} else if err != nil {
+			slog.WithError(err).Warnf("readiness check %s failed", check.name)
+			result.Ready = false
+			result.Error = err.Error()
+			response.Ready = false
+			response.Status = readinessStatusUnready
+		}
+		response.Checks = append(response.Checks, result)
+	}
+
+	c.response = response
+	c.lastCheck = time.Now()
+
+	return response
+}
+
+// breakerStates returns the current states of the circuit breakers, they are
+// not cached
+func (c *readinessChecker) breakerStates() map[string]string {
+	if len(c.breakers) == 0 {
+		return nil
+	}
+	states := make(map[string]string, len(c.breakers))
+	for name, breaker := range c.breakers {
+		states[name] = breaker.State()
+	}
+	return states
+}
+
+func readinessHandler(checker *readinessChecker) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := checker.check(r.Context())
+		response.CircuitBreakers = checker.breakerStates()
+
+		body, err := json.Marshal(response)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal readiness", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !response.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(body)
+	})
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	status := http.StatusOK
+	loki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/ready", r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer loki.Close()
+
+	checker := newReadinessChecker(&Config{}, &PluginConfig{LokiURL: loki.URL}, nil, nil)
+	handler := readinessHandler(checker)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// the cached result is served until it expires
+	status = http.StatusServiceUnavailable
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	checker.lastCheck = checker.lastCheck.Add(-readinessCacheTTL)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	response := readinessResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.False(t, response.Ready)
+	require.Len(t, response.Checks, 1)
+	require.Equal(t, "loki", response.Checks[0].Name)
+	require.False(t, response.Checks[0].Ready)
+}
+
+func TestReadinessHandlerCertificate(t *testing.T) {
+	checker := newReadinessChecker(&Config{CertFile: "missing.crt", PrivateKeyFile: "missing.key"}, &PluginConfig{}, nil, nil)
+
+	w := httptest.NewRecorder()
+	readinessHandler(checker).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), `"name":"certificate"`)
+}
+
+func TestReadinessHandlerCertificateExpiry(t *testing.T) {
+	tmpDir := t.TempDir()
+	certFile := filepath.Join(tmpDir, "tls.crt")
+	keyFile := filepath.Join(tmpDir, "tls.key")
+	require.NoError(t, generateCertificate(t, certFile, keyFile, "plugin.svc"))
+
+	cfg := &Config{CertFile: certFile, PrivateKeyFile: keyFile, CertExpiryWarning: 30 * 24 * time.Hour}
+	certificates, err := newServingCertificates(cfg)
+	require.NoError(t, err)
+	checker := newReadinessChecker(cfg, &PluginConfig{}, nil, certificates)
+
+	w := httptest.NewRecorder()
+	readinessHandler(checker).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	response := readinessResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, readinessStatusOK, response.Status)
+
+	// the certificate is valid for a year
+	tests := []struct {
+		name    string
+		after   time.Duration
+		wantErr bool
+		warning bool
+	}{
+		{name: "valid", after: 300 * 24 * time.Hour},
+		{name: "expiring", after: 360 * 24 * time.Hour, warning: true},
+		{name: "expired", after: 366 * 24 * time.Hour, wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now := func() time.Time { return time.Now().Add(tc.after) }
+			err := certificateExpiryCheck(certificates, cfg.CertExpiryWarning, now)(context.Background())
+
+			var warning *readinessWarning
+			require.Equal(t, tc.warning, errors.As(err, &warning), err)
+			require.Equal(t, tc.wantErr || tc.warning, err != nil)
+
+			left := testutil.ToFloat64(metrics.CertExpirySeconds.WithLabelValues("file"))
+			require.InDelta(t, (365*24*time.Hour - tc.after).Seconds(), left, 60)
+		})
+	}
+
+	checker.checks = []readinessCheck{{name: "certificate-expiry", check: certificateExpiryCheck(certificates, cfg.CertExpiryWarning, func() time.Time {
+		return time.Now().Add(360 * 24 * time.Hour)
+	})}}
+	checker.lastCheck = time.Time{}
+	w = httptest.NewRecorder()
+	readinessHandler(checker).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	response = readinessResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.True(t, response.Ready)
+	require.Equal(t, readinessStatusWarning, response.Status)
+	require.Contains(t, response.Checks[0].Warning, "file certificate expires at")
+}
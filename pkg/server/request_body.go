@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RequestBodyLimit bounds the body of the requests under Prefix
+type RequestBodyLimit struct {
+	Prefix string
+	// MaxSize is the maximum body size in bytes, unlimited if 0
+	MaxSize int64
+}
+
+// ParseRequestBodyLimits parses a comma separated list of request body limits
+// with the form `<prefix>=<bytes>`, e.g. `/api/queries=65536`
+func ParseRequestBodyLimits(value string) ([]RequestBodyLimit, error) {
+	limits := []RequestBodyLimit{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		prefix, size, found := strings.Cut(entry, "=")
+		if !found || prefix == "" || size == "" {
+			return nil, fmt.Errorf("invalid request body limit %q, expected <prefix>=<bytes>", entry)
+		}
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid request body limit prefix %q, it must start with /", prefix)
+		}
+
+		maxSize, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || maxSize < 0 {
+			return nil, fmt.Errorf("invalid request body limit size %q of %s, a number of bytes is expected", size, prefix)
+		}
+
+		limits = append(limits, RequestBodyLimit{Prefix: prefix, MaxSize: maxSize})
+	}
+
+	return limits, nil
+}
+
+// requestBodyLimit returns the body limit of urlPath, the one of the longest
+// matching prefix or maxSize
+func requestBodyLimit(urlPath string, maxSize int64, limits []RequestBodyLimit) int64 {
+	longest := -1
+	for _, limit := range limits {
+		if strings.HasPrefix(urlPath, limit.Prefix) && len(limit.Prefix) > longest {
+			longest = len(limit.Prefix)
+			maxSize = limit.MaxSize
+		}
+	}
+	return maxSize
+}
+
+// requestBodyLimitMiddleware replies with a 413 error to the requests whose
+// body is larger than the limit of their path, the bodies without a length
+// fail to be read past the limit. The handlers with a smaller limit of their
+// own, like the saved queries, keep it
+func requestBodyLimitMiddleware(maxSize int64, limits []RequestBodyLimit) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := requestBodyLimit(r.URL.Path, maxSize, limits)
+			if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.ContentLength > limit {
+				writeError(w, r, http.StatusRequestEntityTooLarge, errorCodePayloadTooLarge,
+					fmt.Sprintf("the request body of %d bytes exceeds the limit of %d bytes", r.ContentLength, limit),
+					map[string]int64{"limit": limit})
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequestBodyLimits(t *testing.T) {
+	limits, err := ParseRequestBodyLimits(" /api/queries=65536,, /api/export/=0 ")
+	require.NoError(t, err)
+	require.Equal(t, []RequestBodyLimit{{Prefix: "/api/queries", MaxSize: 65536}, {Prefix: "/api/export/", MaxSize: 0}}, limits)
+
+	for _, value := range []string{"/api/queries", "api=10", "/api=1MB", "/api=-1"} {
+		_, err = ParseRequestBodyLimits(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestRequestBodyLimitMiddleware(t *testing.T) {
+	handler := requestBodyLimitMiddleware(16, []RequestBodyLimit{
+		{Prefix: "/api/", MaxSize: 8},
+		{Prefix: "/api/export/", MaxSize: 32},
+		{Prefix: "/api/unlimited", MaxSize: 0},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, http.StatusRequestEntityTooLarge, errorCodePayloadTooLarge, "cannot read body", err.Error())
+			return
+		}
+		w.Write(body)
+	}))
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		chunked        bool
+		expectedStatus int
+	}{
+		{name: "global limit", path: "/validate-config", body: strings.Repeat("a", 16), expectedStatus: http.StatusOK},
+		{name: "global limit exceeded", path: "/validate-config", body: strings.Repeat("a", 17), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "route limit exceeded", path: "/api/queries", body: strings.Repeat("a", 9), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "longest prefix", path: "/api/export/application", body: strings.Repeat("a", 32), expectedStatus: http.StatusOK},
+		{name: "unlimited route", path: "/api/unlimited", body: strings.Repeat("a", 64), expectedStatus: http.StatusOK},
+		{name: "chunked body exceeded", path: "/api/queries", body: strings.Repeat("a", 9), chunked: true, expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "no body", path: "/api/queries", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			r.Header.Set("Accept", "application/json")
+			if tc.chunked {
+				r.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedStatus == http.StatusOK {
+				require.Equal(t, tc.body, w.Body.String())
+				return
+			}
+			resp := errorResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, errorCodePayloadTooLarge, resp.Error.Code)
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/queries", strings.NewReader(strings.Repeat("a", 9)))
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.JSONEq(t, `{"error":{"code":"PayloadTooLarge","message":"the request body of 9 bytes exceeds the limit of 8 bytes","details":{"limit":8}}}`, w.Body.String())
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// requestIDRegexp matches the request IDs accepted from the clients, others
+// are replaced with a generated ID
+var requestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestIDMiddleware makes sure every request has an ID in the X-Request-Id
+// header, generating one when the client did not send a valid ID. The ID is
+// echoed in the response and forwarded to the upstream requests
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !requestIDRegexp.MatchString(requestID) {
+			requestID = newRequestID()
+			r.Header.Set(requestIDHeader, requestID)
+		}
+
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		slog.WithError(err).Error("cannot generate request ID")
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// requestLog returns log with the request ID field of r
+func requestLog(log *logrus.Entry, r *http.Request) *logrus.Entry {
+	return log.WithField("request_id", r.Header.Get(requestIDHeader))
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "bad request", nil)
+	}))
+
+	tests := []struct {
+		name       string
+		requestID  string
+		generated  bool
+		expectedID string
+	}{
+		{name: "client ID", requestID: "console-42", expectedID: "console-42"},
+		{name: "missing ID", generated: true},
+		{name: "invalid ID", requestID: "bad id\twith spaces", generated: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/config", nil)
+			if tc.requestID != "" {
+				r.Header.Set(requestIDHeader, tc.requestID)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			requestID := w.Header().Get(requestIDHeader)
+			if tc.generated {
+				require.Len(t, requestID, 32)
+			} else {
+				require.Equal(t, tc.expectedID, requestID)
+			}
+
+			response := errorResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Equal(t, requestID, response.Error.RequestID)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// configResponseTTL bounds the lifetime of the cached /features and
+	// /config payloads, they are also dropped when the config is reloaded
+	configResponseTTL = 10 * time.Second
+	// maxConfigResponses bounds the cached payloads, one per tenant and
+	// schema version of /config
+	maxConfigResponses = 256
+)
+
+// cachedResponse is a marshalled JSON payload with its ETag, or the error of
+// its marshalling, cached so that a failing payload is not built again on
+// every request
+type cachedResponse struct {
+	body    []byte
+	etag    string
+	err     error
+	expires time.Time
+}
+
+// responseCache caches the payloads built from a config generation, the
+// entries of the previous generations are dropped on the first request of a
+// new one
+type responseCache struct {
+	mu         sync.Mutex
+	generation uint64
+	entries    map[string]cachedResponse
+	now        func() time.Time
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{entries: map[string]cachedResponse{}, now: time.Now}
+}
+
+// get returns the cached payload of key for the config generation, or the
+// one built by build
+func (c *responseCache) get(generation uint64, key string, build func() ([]byte, error)) cachedResponse {
+	now := c.now()
+
+	c.mu.Lock()
+	if generation != c.generation {
+		c.generation = generation
+		c.entries = map[string]cachedResponse{}
+	}
+	cached, found := c.entries[key]
+	c.mu.Unlock()
+	if found && now.Before(cached.expires) {
+		return cached
+	}
+
+	body, err := build()
+	cached = cachedResponse{body: body, err: err, expires: now.Add(configResponseTTL)}
+	if err == nil {
+		hash := sha256.Sum256(body)
+		cached.etag = fmt.Sprintf("%q", hex.EncodeToString(hash[:])[:32])
+	}
+
+	c.mu.Lock()
+	if generation == c.generation {
+		if len(c.entries) >= maxConfigResponses {
+			c.entries = map[string]cachedResponse{}
+		}
+		c.entries[key] = cached
+	}
+	c.mu.Unlock()
+
+	return cached
+}
+
+// writeCachedJSON replies with the cached JSON payload, the conditional
+// requests with its ETag or a later If-Modified-Since get a 304. The clients
+// revalidate it on every use unless a cacheControl rule is set
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, cached cachedResponse, modTime time.Time) {
+	writeCachedResponse(w, r, cached, "application/json", modTime)
+}
+
+// writeCachedResponse is writeCachedJSON for a payload of contentType
+func writeCachedResponse(w http.ResponseWriter, r *http.Request, cached cachedResponse, contentType string, modTime time.Time) {
+	headers := w.Header()
+	headers.Set("Content-Type", contentType)
+	headers.Set("ETag", cached.etag)
+	if headers.Get("Cache-Control") == "" {
+		headers.Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(cached.body))
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseCache(t *testing.T) {
+	now := time.Now()
+	cache := newResponseCache()
+	cache.now = func() time.Time { return now }
+
+	builds := 0
+	build := func() ([]byte, error) {
+		builds++
+		return []byte(`{"features":{}}`), nil
+	}
+
+	first := cache.get(1, "features", build)
+	require.Equal(t, `{"features":{}}`, string(first.body))
+	require.NotEmpty(t, first.etag)
+	require.Equal(t, first.etag, cache.get(1, "features", build).etag)
+	require.Equal(t, 1, builds)
+
+	// a new config generation or an expired entry is built again
+	cache.get(2, "features", build)
+	require.Equal(t, 2, builds)
+	now = now.Add(configResponseTTL)
+	cache.get(2, "features", build)
+	require.Equal(t, 3, builds)
+
+	// the failures are cached as well
+	failures := 0
+	fail := func() ([]byte, error) {
+		failures++
+		return nil, errors.New("cannot marshal")
+	}
+	require.Error(t, cache.get(2, "config", fail).err)
+	require.Error(t, cache.get(2, "config", fail).err)
+	require.Equal(t, 1, failures)
+}
+
+func TestConfigHandlerConditionalRequests(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	now := time.Now()
+	writeConfig := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0600))
+		require.NoError(t, os.Chtimes(configFile, modTime, modTime))
+	}
+	writeConfig("timeout: 30s\n", now)
+
+	reloadingConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+
+	handlers := map[string]http.Handler{
+		"/config":   configHandler(reloadingConfig),
+		"/features": featuresHandler(&Config{}, reloadingConfig),
+	}
+
+	get := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		handlers[r.URL.Path].ServeHTTP(w, r)
+		return w
+	}
+
+	for path := range handlers {
+		w := get(path, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		etag := w.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+		require.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+		require.NotEmpty(t, w.Header().Get("Last-Modified"))
+
+		w = get(path, map[string]string{"If-None-Match": etag})
+		require.Equal(t, http.StatusNotModified, w.Code, path)
+		require.Empty(t, w.Body.String())
+	}
+
+	etag := get("/config", nil).Header().Get("ETag")
+	require.NotEqual(t, etag, get("/config?version=2", nil).Header().Get("ETag"))
+
+	// the cached payloads are dropped on reload
+	writeConfig("timeout: 1m\n", now.Add(time.Minute))
+	reloaded, err := reloadingConfig.reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+
+	w := get("/config", map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"timeout":"1m0s"`)
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// lokiStackRetentionSyncInterval is the time between two reads of the
+// retention limits of the LokiStack
+const lokiStackRetentionSyncInterval = 5 * time.Minute
+
+// RetentionConfig sets the retention of the logs of each tenant, the queries
+// whose time range ends before it are rejected instead of returning no logs.
+// The retentions are read from the limits of LokiStack when set, the
+// configured ones take precedence. The tenants without a retention are not
+// checked
+type RetentionConfig struct {
+	// Default is the retention of the tenants without their own
+	Default Duration `yaml:"default,omitempty" json:"default,omitempty"`
+	// Tenants are the retentions of the tenants
+	Tenants map[string]Duration `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+	// LokiStack is the <namespace>/<name> of the LokiStack whose global and
+	// tenant retention limits are read with the plugin service account
+	LokiStack string `yaml:"lokiStack,omitempty" json:"lokiStack,omitempty"`
+}
+
+func (c RetentionConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.Default.Duration < 0 {
+		errs = append(errs, ConfigValidationError{Field: "retention.default", Message: "default cannot be negative"})
+	}
+
+	tenants := make([]string, 0, len(c.Tenants))
+	for tenant := range c.Tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		field := fmt.Sprintf("retention.tenants.%s", tenant)
+		if !tenantRegexp.MatchString(tenant) {
+			errs = append(errs, ConfigValidationError{Field: field, Message: fmt.Sprintf("invalid tenant %q", tenant)})
+		}
+		if c.Tenants[tenant].Duration < 0 {
+			errs = append(errs, ConfigValidationError{Field: field, Message: "retention cannot be negative"})
+		}
+	}
+
+	if c.LokiStack != "" {
+		if _, _, err := kube.ParseNamespacedName(c.LokiStack); err != nil {
+			errs = append(errs, ConfigValidationError{Field: "retention.lokiStack", Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// enabled tells whether a retention is configured or read
+func (c RetentionConfig) enabled() bool {
+	return c.Default.Duration > 0 || len(c.Tenants) > 0 || c.LokiStack != ""
+}
+
+// lokiStackGetter reads the LokiStacks, implemented by kube.Client
+type lokiStackGetter interface {
+	GetLokiStack(ctx context.Context, namespace string, name string) (*kube.LokiStack, error)
+}
+
+// retentionPolicy resolves the retention of the tenants from the config and
+// the last read limits of the LokiStack
+type retentionPolicy struct {
+	cfg       RetentionConfig
+	client    lokiStackGetter
+	namespace string
+	name      string
+	now       func() time.Time
+
+	mu sync.RWMutex
+	// global and tenants are the retentions of the LokiStack
+	global  time.Duration
+	tenants map[string]time.Duration
+}
+
+// newRetentionPolicy returns the retention policy of cfg, client reads its
+// LokiStack
+func newRetentionPolicy(cfg RetentionConfig, client lokiStackGetter) *retentionPolicy {
+	p := &retentionPolicy{cfg: cfg, client: client, now: time.Now, tenants: map[string]time.Duration{}}
+	p.namespace, p.name, _ = kube.ParseNamespacedName(cfg.LokiStack)
+	return p
+}
+
+// retention returns the retention of tenant, zero when the logs are kept
+// forever
+func (p *retentionPolicy) retention(tenant string) time.Duration {
+	if retention, ok := p.cfg.Tenants[tenant]; ok {
+		return retention.Duration
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if retention, ok := p.tenants[tenant]; ok {
+		return retention
+	}
+	if p.cfg.Default.Duration > 0 {
+		return p.cfg.Default.Duration
+	}
+	return p.global
+}
+
+// sync reads the retention limits of the LokiStack
+func (p *retentionPolicy) sync(ctx context.Context) error {
+	lokiStack, err := p.client.GetLokiStack(ctx, p.namespace, p.name)
+	if err != nil {
+		return fmt.Errorf("cannot read the retention of LokiStack %s: %w", p.cfg.LokiStack, err)
+	}
+
+	days := func(limits kube.LokiStackLimits) time.Duration {
+		return time.Duration(limits.Retention.Days) * 24 * time.Hour
+	}
+	tenants := map[string]time.Duration{}
+	for tenant, limits := range lokiStack.Spec.Limits.Tenants {
+		if limits.Retention.Days > 0 {
+			tenants[tenant] = days(limits)
+		}
+	}
+
+	p.mu.Lock()
+	p.global = days(lokiStack.Spec.Limits.Global)
+	p.tenants = tenants
+	p.mu.Unlock()
+	return nil
+}
+
+// run reads the retention limits of the LokiStack every interval until ctx
+// is done, the last read limits are kept on errors
+func (p *retentionPolicy) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.sync(ctx); err != nil && ctx.Err() == nil {
+			slog.WithError(err).Warnf("cannot refresh the retention of the tenants, retrying in %s", interval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check returns an OutsideRetention error when the time range of the query
+// parameters of tenant ends before its retention, nil otherwise. The range
+// ends at the end parameter, at the time of the instant queries, or now
+func (p *retentionPolicy) check(tenant string, params map[string][]string) *proxy.Error {
+	retention := p.retention(tenant)
+	if retention <= 0 {
+		return nil
+	}
+
+	now := p.now()
+	end := now
+	for _, name := range []string{"end", "time"} {
+		if values := params[name]; len(values) > 0 && values[0] != "" {
+			t, err := proxy.ParseTime(values[0])
+			if err != nil {
+				// the invalid times are left to the datasource
+				return nil
+			}
+			end = t
+			break
+		}
+	}
+
+	oldest := now.Add(-retention)
+	if !end.Before(oldest) {
+		return nil
+	}
+	return &proxy.Error{
+		Status:  http.StatusBadRequest,
+		Code:    errorCodeOutsideRetention,
+		Message: fmt.Sprintf("the logs of the %s tenant are kept for %s, the time range of the query ends before the oldest logs of %s", tenant, retention, oldest.UTC().Format(time.RFC3339)),
+		Details: map[string]string{
+			"tenant":    tenant,
+			"retention": retention.String(),
+			"oldest":    oldest.UTC().Format(time.RFC3339),
+		},
+	}
+}
+
+// retentionMiddleware rejects the queries of the paths starting with the
+// tenant whose time range is outside the retention of the tenant. It does
+// nothing when policy is nil
+func retentionMiddleware(policy *retentionPolicy) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if policy == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if err := policy.check(tenant, r.URL.Query()); err != nil {
+				writeProxyError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
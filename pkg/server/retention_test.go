@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLokiStackGetter struct {
+	lokiStack *kube.LokiStack
+	err       error
+}
+
+func (f *fakeLokiStackGetter) GetLokiStack(ctx context.Context, namespace string, name string) (*kube.LokiStack, error) {
+	return f.lokiStack, f.err
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	lokiStack := &kube.LokiStack{}
+	require.NoError(t, json.Unmarshal([]byte(`{"spec":{"limits":{
+		"global":{"retention":{"days":7}},
+		"tenants":{"audit":{"retention":{"days":90}},"infrastructure":{"retention":{"days":3}}}}}}`), lokiStack))
+	getter := &fakeLokiStackGetter{lokiStack: lokiStack}
+
+	policy := newRetentionPolicy(RetentionConfig{
+		Tenants:   map[string]Duration{"infrastructure": {24 * time.Hour}},
+		LokiStack: "openshift-logging/logging-loki",
+	}, getter)
+	require.Equal(t, time.Duration(0), policy.retention("application"))
+
+	require.NoError(t, policy.sync(context.Background()))
+	require.Equal(t, 7*24*time.Hour, policy.retention("application"))
+	require.Equal(t, 90*24*time.Hour, policy.retention("audit"))
+	// the configured retentions take precedence
+	require.Equal(t, 24*time.Hour, policy.retention("infrastructure"))
+
+	// the last read limits are kept on errors
+	getter.err = errors.New("forbidden")
+	require.Error(t, policy.sync(context.Background()))
+	require.Equal(t, 90*24*time.Hour, policy.retention("audit"))
+}
+
+func TestRetentionMiddleware(t *testing.T) {
+	now := time.Now()
+	policy := newRetentionPolicy(RetentionConfig{Default: Duration{24 * time.Hour}, Tenants: map[string]Duration{"audit": {}}}, nil)
+	policy.now = func() time.Time { return now }
+	handler := retentionMiddleware(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	unix := func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+	}{
+		{name: "no range", target: "/application/loki/api/v1/query_range?query=%7Bapp%3D%22api%22%7D", expectedStatus: http.StatusOK},
+		{name: "range ending in the retention", target: "/application/loki/api/v1/query_range?start=" + unix(now.Add(-48*time.Hour)) + "&end=" + unix(now.Add(-time.Hour)), expectedStatus: http.StatusOK},
+		{name: "range ending before the retention", target: "/application/loki/api/v1/query_range?start=" + unix(now.Add(-72*time.Hour)) + "&end=" + unix(now.Add(-48*time.Hour)), expectedStatus: http.StatusBadRequest},
+		{name: "instant query before the retention", target: "/application/loki/api/v1/query?time=" + unix(now.Add(-48*time.Hour)), expectedStatus: http.StatusBadRequest},
+		{name: "export before the retention", target: "/application?end=" + unix(now.Add(-48*time.Hour)), expectedStatus: http.StatusBadRequest},
+		{name: "tenant kept forever", target: "/audit/loki/api/v1/query_range?end=" + unix(now.Add(-48*time.Hour)), expectedStatus: http.StatusOK},
+		{name: "invalid end", target: "/application/loki/api/v1/query_range?end=yesterday", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedStatus == http.StatusBadRequest {
+				require.Contains(t, w.Body.String(), `"code":"OutsideRetention"`)
+				require.Contains(t, w.Body.String(), `"retention":"24h0m0s"`)
+			}
+		})
+	}
+}
+
+func TestRetentionConfigValidation(t *testing.T) {
+	_, err := parsePluginConfig([]byte("retention:\n  default: -1h\n  tenants:\n    audit/logs: 720h\n  lokiStack: logging-loki"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "retention.default", Message: "default cannot be negative"},
+		{Field: "retention.tenants.audit/logs", Message: `invalid tenant "audit/logs"`},
+		{Field: "retention.lokiStack", Message: `invalid reference "logging-loki", expected <namespace>/<name>`},
+	}, err)
+}
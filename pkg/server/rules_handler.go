@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+const (
+	defaultAlertingRuleTenantLabelKey    = "tenantId"
+	defaultAlertingRuleNamespaceLabelKey = namespaceLabel
+)
+
+// ruleTenants are the tenants whose rules are served when the request does
+// not select any
+var ruleTenants = []string{"application", "infrastructure", "audit"}
+
+// rulesFilter keeps the rules of a tenant that the user is allowed to see
+type rulesFilter struct {
+	tenantLabelKey    string
+	namespaceLabelKey string
+	// namespaces restricts the rules to the requested namespaces when set
+	namespaces map[string]bool
+	// enforcedTenants are the tenants whose rules are filtered by the access
+	// of the user to their namespace
+	enforcedTenants map[string]bool
+	authorize       func(namespace string) (bool, error)
+}
+
+// filter returns the groups of tenant without the rules of other tenants and
+// of the namespaces the user cannot access, the empty groups are dropped
+func (f *rulesFilter) filter(tenant string, groups []proxy.RuleGroup) ([]proxy.RuleGroup, error) {
+	filtered := []proxy.RuleGroup{}
+
+	for _, group := range groups {
+		rules := []proxy.Rule{}
+		for _, rule := range group.Rules {
+			allowed, err := f.allowed(tenant, rule.Labels())
+			if err != nil {
+				return nil, err
+			}
+			if allowed {
+				rules = append(rules, rule)
+			}
+		}
+		if len(rules) > 0 {
+			group.Rules = rules
+			filtered = append(filtered, group)
+		}
+	}
+
+	return filtered, nil
+}
+
+func (f *rulesFilter) allowed(tenant string, labels map[string]string) (bool, error) {
+	if ruleTenant, ok := labels[f.tenantLabelKey]; ok && ruleTenant != tenant {
+		return false, nil
+	}
+
+	namespace, hasNamespace := labels[f.namespaceLabelKey]
+	if len(f.namespaces) > 0 && !f.namespaces[namespace] {
+		return false, nil
+	}
+
+	if f.authorize == nil || !f.enforcedTenants[tenant] {
+		return true, nil
+	}
+	if !hasNamespace {
+		return false, nil
+	}
+	return f.authorize(namespace)
+}
+
+// rulesHandler serves the alerting and recording rules of the ruler of ds
+// merged across the tenants of the tenant parameters. The rules labeled with
+// another tenant are dropped and, when the authorization is enabled, the rules
+// of the enforced tenants are only served if the user can access their
+// namespace. The namespace parameters restrict the rules to some namespaces
+func rulesHandler(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps) http.Handler {
+	proxyConfig, err := lokiProxyConfig(ds, pluginConfig, deps)
+	authorizer := deps.authorizer
+	if err != nil {
+		return unavailableDatasourceHandler(ds, err)
+	}
+	client := proxy.NewClient(proxyConfig)
+
+	enforcedTenants := map[string]bool{}
+	for _, tenant := range pluginConfig.Authorization.Tenants {
+		enforcedTenants[tenant] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		tenants := params["tenant"]
+		if len(tenants) == 0 {
+			tenants = ruleTenants
+		}
+		for _, tenant := range tenants {
+			if !tenantRegexp.MatchString(tenant) {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid tenant %q", tenant), nil)
+				return
+			}
+			// the rules are read with the plugin service account token
+			if deps.serviceAccountToken != nil && !enforcedTenants[tenant] {
+				writeError(w, r, http.StatusForbidden, errorCodeForbidden, fmt.Sprintf("the %s tenant is not authorized by the plugin and cannot be queried with its service account token", tenant), nil)
+				return
+			}
+		}
+
+		f := &rulesFilter{
+			tenantLabelKey:    pluginConfig.AlertingRuleTenantLabelKey,
+			namespaceLabelKey: pluginConfig.AlertingRuleNamespaceLabelKey,
+			namespaces:        map[string]bool{},
+			enforcedTenants:   enforcedTenants,
+		}
+		for _, namespace := range params["namespace"] {
+			if !namespaceRegexp.MatchString(namespace) {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid namespace %q", namespace), nil)
+				return
+			}
+			f.namespaces[namespace] = true
+		}
+
+		if authorizer != nil {
+			user, ok := requestUser(r)
+			if !ok {
+				writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "the request is not authenticated", nil)
+				return
+			}
+			f.authorize = namespaceAuthorizer(r, authorizer, user)
+		}
+
+		responses := make([]*proxy.RulesResponse, len(tenants))
+		errs := make([]error, len(tenants))
+		var wg sync.WaitGroup
+		for i, tenant := range tenants {
+			wg.Add(1)
+			go func(i int, tenant string) {
+				defer wg.Done()
+				responses[i], errs[i] = client.Rules(r, tenant)
+			}(i, tenant)
+		}
+		wg.Wait()
+
+		merged := &proxy.RulesResponse{Status: "success", Data: proxy.RulesData{Groups: []proxy.RuleGroup{}}}
+		var lastErr *proxy.Error
+		for i, tenant := range tenants {
+			if errs[i] != nil {
+				if !errors.As(errs[i], &lastErr) {
+					lastErr = &proxy.Error{Status: http.StatusBadGateway, Code: "UpstreamError", Message: errs[i].Error()}
+				}
+				merged.Warnings = append(merged.Warnings, lastErr.Error())
+				continue
+			}
+
+			groups, err := f.filter(tenant, responses[i].Data.Groups)
+			if err != nil {
+				requestLog(slog, r).WithError(err).Error("cannot review access")
+				writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "cannot authorize the request", nil)
+				return
+			}
+			merged.Data.Groups = append(merged.Data.Groups, groups...)
+			merged.Warnings = append(merged.Warnings, responses[i].Warnings...)
+		}
+
+		if lastErr != nil && len(merged.Warnings) == len(tenants) {
+			writeProxyError(w, r, lastErr)
+			return
+		}
+
+		response, err := json.Marshal(merged)
+		if err != nil {
+			requestLog(slog, r).WithError(err).Error("cannot marshal rules")
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal rules", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+	})
+}
+
+// namespaceAuthorizer returns a function checking the access of user to a
+// namespace, the decisions are kept for the duration of r
+func namespaceAuthorizer(r *http.Request, authorizer *authz.Authorizer, user *kube.UserInfo) func(string) (bool, error) {
+	decisions := map[string]bool{}
+	return func(namespace string) (bool, error) {
+		if allowed, ok := decisions[namespace]; ok {
+			return allowed, nil
+		}
+		allowed, err := authorizer.Authorize(r.Context(), user, namespace)
+		if err != nil {
+			return false, err
+		}
+		decisions[namespace] = allowed
+		return allowed, nil
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `{"status":"success","data":{"groups":[
+{"name":"app","file":"app.yaml","rules":[
+  {"name":"AppErrors","type":"alerting","labels":{"tenantId":"application","kubernetes_namespace_name":"my-app"}},
+  {"name":"OtherAppErrors","type":"alerting","labels":{"tenantId":"application","kubernetes_namespace_name":"other"}},
+  {"name":"Unlabeled","type":"alerting","labels":{}}
+]},
+{"name":"infra","file":"infra.yaml","rules":[
+  {"name":"InfraErrors","type":"alerting","labels":{"tenantId":"infrastructure","kubernetes_namespace_name":"openshift-etcd"}}
+]}]}}`
+
+// the test upstream replies with the same rules to every tenant, the rules
+// labeled with another tenant are dropped
+func TestRulesHandler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer user-token", r.Header.Get("Authorization"))
+		if strings.HasPrefix(r.URL.Path, "/api/logs/v1/audit/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testRules))
+	}))
+	defer upstream.Close()
+
+	pluginConfig, err := parsePluginConfig([]byte("lokiURL: " + upstream.URL))
+	require.NoError(t, err)
+	ds, _ := pluginConfig.defaultDatasource()
+	authorizer := authz.New(&fakeAccessReviewer{allowedNamespaces: map[string]bool{"my-app": true}}, defaultAuthorizationConfig.resourceAttributes())
+
+	tests := []struct {
+		name             string
+		authorizer       *authz.Authorizer
+		query            string
+		expectedStatus   int
+		expectedRules    []string
+		expectedWarnings int
+	}{
+		{
+			name:           "tenant labels",
+			query:          "tenant=application&tenant=infrastructure",
+			expectedStatus: http.StatusOK,
+			expectedRules:  []string{"AppErrors", "OtherAppErrors", "Unlabeled", "Unlabeled", "InfraErrors"},
+		},
+		{
+			name:           "authorized namespaces",
+			authorizer:     authorizer,
+			query:          "tenant=application&tenant=infrastructure",
+			expectedStatus: http.StatusOK,
+			expectedRules:  []string{"AppErrors", "Unlabeled", "InfraErrors"},
+		},
+		{
+			name:           "namespace",
+			query:          "tenant=application&namespace=other",
+			expectedStatus: http.StatusOK,
+			expectedRules:  []string{"OtherAppErrors"},
+		},
+		{
+			name:             "unavailable tenant",
+			expectedStatus:   http.StatusOK,
+			expectedRules:    []string{"AppErrors", "OtherAppErrors", "Unlabeled", "Unlabeled", "InfraErrors"},
+			expectedWarnings: 1,
+		},
+		{
+			name:           "only unavailable tenants",
+			query:          "tenant=audit",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "invalid tenant",
+			query:          "tenant=../audit",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := rulesHandler(ds, pluginConfig, routeDeps{authorizer: tc.authorizer})
+
+			r := httptest.NewRequest(http.MethodGet, "/api/rules?"+tc.query, nil)
+			r.Header.Set("Authorization", "Bearer user-token")
+			r = r.WithContext(context.WithValue(r.Context(), userKey{}, &kube.UserInfo{Username: "developer"}))
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response proxy.RulesResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			rules := []string{}
+			for _, group := range response.Data.Groups {
+				for _, rule := range group.Rules {
+					rules = append(rules, rule["name"].(string))
+				}
+			}
+			require.Equal(t, tc.expectedRules, rules)
+			require.Len(t, response.Warnings, tc.expectedWarnings)
+		})
+	}
+}
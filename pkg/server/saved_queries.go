@@ -0,0 +1,213 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+	"github.com/openshift/logging-view-plugin/pkg/store"
+)
+
+const (
+	defaultSavedQueriesConfigMapName = "logging-view-plugin-saved-queries"
+	// maxSavedQuerySize bounds the size of the saved query requests
+	maxSavedQuerySize = 64 << 10
+	maxSavedQueryName = 256
+)
+
+// SavedQueriesConfig stores the queries saved by the users in a ConfigMap
+type SavedQueriesConfig struct {
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	// ConfigMap is the <namespace>/<name> of the ConfigMap, the
+	// logging-view-plugin-saved-queries ConfigMap of the plugin namespace by
+	// default
+	ConfigMap string `yaml:"configMap,omitempty" json:"configMap,omitempty"`
+	// MaxPerUser is the maximum number of queries saved by a user
+	MaxPerUser int `yaml:"maxPerUser,omitempty" json:"maxPerUser,omitempty"`
+}
+
+var defaultSavedQueriesConfig = SavedQueriesConfig{
+	MaxPerUser: 50,
+}
+
+func (c SavedQueriesConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.ConfigMap != "" {
+		if _, _, err := kube.ParseNamespacedName(c.ConfigMap); err != nil {
+			errs = append(errs, ConfigValidationError{Field: "savedQueries.configMap", Message: err.Error()})
+		}
+	}
+	if c.MaxPerUser < 0 {
+		errs = append(errs, ConfigValidationError{Field: "savedQueries.maxPerUser", Message: "maxPerUser cannot be negative"})
+	}
+	return errs
+}
+
+func newSavedQueriesStore(client *kube.Client, cfg SavedQueriesConfig) (*store.Store, error) {
+	var namespace, name string
+	if cfg.ConfigMap != "" {
+		// the reference is validated when the plugin config is parsed
+		namespace, name, _ = kube.ParseNamespacedName(cfg.ConfigMap)
+	} else {
+		var err error
+		if namespace, err = kube.InClusterNamespace(); err != nil {
+			return nil, err
+		}
+		name = defaultSavedQueriesConfigMapName
+	}
+
+	slog.Infof("saving queries in ConfigMap %s/%s", namespace, name)
+	return store.New(store.NewConfigMapBackend(client, namespace, name), cfg.MaxPerUser), nil
+}
+
+type savedQueryRequest struct {
+	Name   string   `json:"name"`
+	Query  string   `json:"query"`
+	Tenant string   `json:"tenant,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// registerSavedQueriesRoutes serves the queries saved by the authenticated
+// user and the queries shared with its groups at /api/queries
+func registerSavedQueriesRoutes(r *mux.Router, authenticated func(http.Handler) http.Handler, s *store.Store) {
+	r.Path("/api/queries").Methods(http.MethodGet).Handler(authenticated(listSavedQueriesHandler(s)))
+	r.Path("/api/queries").Methods(http.MethodPost).Handler(authenticated(addSavedQueryHandler(s)))
+	r.Path("/api/queries/{id}").Methods(http.MethodDelete).Handler(authenticated(deleteSavedQueryHandler(s)))
+}
+
+func listSavedQueriesHandler(s *store.Store) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requestUser(r)
+		if !ok {
+			writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "the request is not authenticated", nil)
+			return
+		}
+
+		queries, err := s.List(r.Context(), user.Username, user.Groups)
+		if err != nil {
+			writeSavedQueriesError(w, r, err)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, queries)
+	})
+}
+
+func addSavedQueryHandler(s *store.Store) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requestUser(r)
+		if !ok {
+			writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "the request is not authenticated", nil)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSavedQuerySize))
+		if err != nil {
+			writeError(w, r, http.StatusRequestEntityTooLarge, errorCodePayloadTooLarge, "cannot read saved query", err.Error())
+			return
+		}
+
+		req := savedQueryRequest{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid saved query", err.Error())
+			return
+		}
+		if err := validateSavedQuery(req, user); err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid saved query", err.Error())
+			return
+		}
+
+		saved, err := s.Add(r.Context(), store.Query{
+			Name:   strings.TrimSpace(req.Name),
+			Query:  req.Query,
+			Tenant: req.Tenant,
+			Owner:  user.Username,
+			Groups: req.Groups,
+		})
+		if err != nil {
+			writeSavedQueriesError(w, r, err)
+			return
+		}
+
+		writeJSON(w, r, http.StatusCreated, saved)
+	})
+}
+
+func deleteSavedQueryHandler(s *store.Store) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requestUser(r)
+		if !ok {
+			writeError(w, r, http.StatusUnauthorized, errorCodeUnauthorized, "the request is not authenticated", nil)
+			return
+		}
+
+		if err := s.Delete(r.Context(), user.Username, mux.Vars(r)["id"]); err != nil {
+			writeSavedQueriesError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// validateSavedQuery checks the query syntax, the queries can only be shared
+// with the groups of the user
+func validateSavedQuery(req savedQueryRequest, user *kube.UserInfo) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxSavedQueryName {
+		return fmt.Errorf("a name of up to %d characters is required", maxSavedQueryName)
+	}
+	if _, err := logql.Parse(req.Query); err != nil {
+		return err
+	}
+	if req.Tenant != "" && !tenantRegexp.MatchString(req.Tenant) {
+		return fmt.Errorf("invalid tenant %q", req.Tenant)
+	}
+
+	userGroups := map[string]bool{}
+	for _, group := range user.Groups {
+		userGroups[group] = true
+	}
+	for _, group := range req.Groups {
+		if !userGroups[group] {
+			return fmt.Errorf("the query cannot be shared with group %s, the user is not a member", group)
+		}
+	}
+
+	return nil
+}
+
+func writeSavedQueriesError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, errorCodeNotFound, err.Error(), nil)
+	case errors.Is(err, store.ErrForbidden):
+		writeError(w, r, http.StatusForbidden, errorCodeForbidden, err.Error(), nil)
+	case errors.Is(err, store.ErrLimitReached):
+		writeError(w, r, http.StatusUnprocessableEntity, errorCodeUnprocessable, err.Error(), nil)
+	case errors.Is(err, store.ErrConflict):
+		writeError(w, r, http.StatusConflict, errorCodeConflict, err.Error(), nil)
+	default:
+		requestLog(slog, r).WithError(err).Error("cannot access saved queries")
+		writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "cannot access saved queries", nil)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	response, err := json.Marshal(v)
+	if err != nil {
+		requestLog(slog, r).WithError(err).Error("cannot marshal response")
+		writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal response", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedQueriesRoutes(t *testing.T) {
+	users := map[string]*kube.UserInfo{
+		"alice": {Username: "alice", Groups: []string{"team-a"}},
+		"bob":   {Username: "bob", Groups: []string{"team-a"}},
+		"carol": {Username: "carol"},
+	}
+	// the test authentication takes the user name from the Authorization header
+	authenticated := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := users[r.Header.Get("Authorization")]
+			if ok {
+				r = r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	router := mux.NewRouter()
+	registerSavedQueriesRoutes(router, authenticated, store.New(store.NewMemoryBackend(), 1))
+
+	send := func(method string, path string, user string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send(http.MethodPost, "/api/queries", "alice", `{"name":"errors","query":"{app=\"api\"} |= \"error\"","tenant":"application","groups":["team-a"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	saved := store.Query{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+	require.Equal(t, "alice", saved.Owner)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		user           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "unauthenticated", method: http.MethodGet, path: "/api/queries", expectedStatus: http.StatusUnauthorized},
+		{name: "invalid query", method: http.MethodPost, path: "/api/queries", user: "carol", body: `{"name":"bad","query":"{app="}`, expectedStatus: http.StatusBadRequest},
+		{name: "missing name", method: http.MethodPost, path: "/api/queries", user: "carol", body: `{"query":"{app=\"api\"}"}`, expectedStatus: http.StatusBadRequest},
+		{name: "foreign group", method: http.MethodPost, path: "/api/queries", user: "carol", body: `{"name":"all","query":"{app=\"api\"}","groups":["team-a"]}`, expectedStatus: http.StatusBadRequest},
+		{name: "limit reached", method: http.MethodPost, path: "/api/queries", user: "alice", body: `{"name":"all","query":"{app=\"api\"}"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "delete of another user", method: http.MethodDelete, path: "/api/queries/" + saved.ID, user: "bob", expectedStatus: http.StatusForbidden},
+		{name: "delete missing", method: http.MethodDelete, path: "/api/queries/missing", user: "alice", expectedStatus: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := send(tc.method, tc.path, tc.user, tc.body)
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+
+	for user, expected := range map[string]int{"alice": 1, "bob": 1, "carol": 0} {
+		w := send(http.MethodGet, "/api/queries", user, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		queries := []store.Query{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &queries))
+		require.Len(t, queries, expected, user)
+	}
+
+	w = send(http.MethodDelete, "/api/queries/"+saved.ID, "alice", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// SecurityHeadersConfig sets the security headers of every response, the
+// Strict-Transport-Security header is only sent over TLS
+type SecurityHeadersConfig struct {
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	// ContentSecurityPolicy is a text/template of the policy, the console
+	// origins allowed by the CORS config are in {{.AllowedOrigins}}
+	ContentSecurityPolicy string `yaml:"contentSecurityPolicy,omitempty" json:"contentSecurityPolicy,omitempty"`
+	// FrameOptions is DENY or SAMEORIGIN
+	FrameOptions          string        `yaml:"frameOptions,omitempty" json:"frameOptions,omitempty"`
+	HSTSMaxAge            time.Duration `yaml:"hstsMaxAge,omitempty" json:"hstsMaxAge,omitempty"`
+	HSTSIncludeSubdomains bool          `yaml:"hstsIncludeSubdomains,omitempty" json:"hstsIncludeSubdomains,omitempty"`
+}
+
+// defaultSecurityHeadersConfig allows the inline styles of the login page
+// and the data images of the frontend, the console loads the plugin scripts
+// without framing the plugin
+var defaultSecurityHeadersConfig = SecurityHeadersConfig{
+	ContentSecurityPolicy: "default-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+	FrameOptions:          "DENY",
+	HSTSMaxAge:            365 * 24 * time.Hour,
+}
+
+// contentSecurityPolicyData is the data of the policy template
+type contentSecurityPolicyData struct {
+	// AllowedOrigins are the CORS allowed origins separated by spaces
+	AllowedOrigins string
+}
+
+func (c SecurityHeadersConfig) validate(cors CORSConfig) ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if _, err := c.contentSecurityPolicy(cors); err != nil {
+		errs = append(errs, ConfigValidationError{Field: "securityHeaders.contentSecurityPolicy", Message: err.Error()})
+	}
+	if c.FrameOptions != "" && c.FrameOptions != "DENY" && c.FrameOptions != "SAMEORIGIN" {
+		errs = append(errs, ConfigValidationError{Field: "securityHeaders.frameOptions", Message: fmt.Sprintf("invalid frame options %q, expected DENY or SAMEORIGIN", c.FrameOptions)})
+	}
+	if c.HSTSMaxAge < 0 {
+		errs = append(errs, ConfigValidationError{Field: "securityHeaders.hstsMaxAge", Message: "hstsMaxAge cannot be negative"})
+	}
+	return errs
+}
+
+// contentSecurityPolicy renders the policy template
+func (c SecurityHeadersConfig) contentSecurityPolicy(cors CORSConfig) (string, error) {
+	tmpl, err := template.New("contentSecurityPolicy").Option("missingkey=error").Parse(c.ContentSecurityPolicy)
+	if err != nil {
+		return "", fmt.Errorf("invalid policy template: %w", err)
+	}
+
+	policy := strings.Builder{}
+	if err := tmpl.Execute(&policy, contentSecurityPolicyData{AllowedOrigins: strings.Join(cors.AllowedOrigins, " ")}); err != nil {
+		return "", fmt.Errorf("cannot render policy template: %w", err)
+	}
+	return strings.Join(strings.Fields(policy.String()), " "), nil
+}
+
+// securityHeadersMiddleware sets the security headers before the handlers,
+// they can override them. It does nothing when the headers are disabled
+func securityHeadersMiddleware(cfg SecurityHeadersConfig, cors CORSConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if cfg.Disabled {
+			return next
+		}
+
+		// the config is validated when loaded
+		policy, _ := cfg.contentSecurityPolicy(cors)
+		hsts := "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers := w.Header()
+			headers.Set("X-Content-Type-Options", "nosniff")
+			if cfg.FrameOptions != "" {
+				headers.Set("X-Frame-Options", cfg.FrameOptions)
+			}
+			if policy != "" {
+				headers.Set("Content-Security-Policy", policy)
+			}
+			if r.TLS != nil && cfg.HSTSMaxAge > 0 {
+				headers.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
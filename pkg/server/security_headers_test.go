@@ -0,0 +1,57 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(`
+cors:
+  allowedOrigins: [https://console.example.com]
+securityHeaders:
+  contentSecurityPolicy: "default-src 'self'; frame-ancestors {{.AllowedOrigins}}"
+  frameOptions: SAMEORIGIN
+`))
+	require.NoError(t, err)
+
+	handler := securityHeadersMiddleware(pluginConfig.SecurityHeaders, pluginConfig.CORS)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plugin-entry.js", nil))
+	require.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	require.Equal(t, "SAMEORIGIN", w.Header().Get("X-Frame-Options"))
+	require.Equal(t, "default-src 'self'; frame-ancestors https://console.example.com", w.Header().Get("Content-Security-Policy"))
+	require.Empty(t, w.Header().Get("Strict-Transport-Security"))
+
+	r := httptest.NewRequest(http.MethodGet, "/plugin-entry.js", nil)
+	r.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, "max-age=31536000", w.Header().Get("Strict-Transport-Security"))
+
+	disabled := securityHeadersMiddleware(SecurityHeadersConfig{Disabled: true}, CORSConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w = httptest.NewRecorder()
+	disabled.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Empty(t, w.Header().Get("X-Frame-Options"))
+}
+
+func TestSecurityHeadersConfig(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte("securityHeaders:\n  hstsIncludeSubdomains: true"))
+	require.NoError(t, err)
+	require.Equal(t, "DENY", pluginConfig.SecurityHeaders.FrameOptions)
+	require.Equal(t, 365*24*time.Hour, pluginConfig.SecurityHeaders.HSTSMaxAge)
+	require.Equal(t, defaultSecurityHeadersConfig.ContentSecurityPolicy, pluginConfig.SecurityHeaders.ContentSecurityPolicy)
+
+	_, err = parsePluginConfig([]byte("securityHeaders:\n  contentSecurityPolicy: \"frame-ancestors {{.Origins}}\"\n  frameOptions: ALLOW"))
+	errs, ok := err.(ConfigValidationErrors)
+	require.True(t, ok, err)
+	require.Len(t, errs, 2)
+	require.Equal(t, "securityHeaders.contentSecurityPolicy", errs[0].Field)
+	require.Equal(t, "securityHeaders.frameOptions", errs[1].Field)
+}
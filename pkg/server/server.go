@@ -1,100 +1,984 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+	"github.com/openshift/logging-view-plugin/pkg/datasource"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+	"github.com/openshift/logging-view-plugin/pkg/store"
+	"github.com/openshift/logging-view-plugin/pkg/tracing"
 	"github.com/sirupsen/logrus"
 )
 
 var slog = logrus.WithField("module", "server")
 
 type Config struct {
-	Port           int
-	CertFile       string
-	PrivateKeyFile string
-	Features       map[string]bool
-	StaticPath     string
-	ConfigPath     string
+	Port                  int
+	Address               string
+	IPFamily              string
+	CertFile              string
+	PrivateKeyFile        string
+	CertSecret            string
+	SNICertificates       []SNICertificate
+	CertExpiryWarning     time.Duration
+	Features              map[string]bool
+	StaticPath            string
+	StaticFS              fs.FS
+	StaticRoots           []StaticRoot
+	ConfigPath            string
+	PluginConfigPath      string
+	PluginConfigMap       string
+	FaultInjection        bool
+	ShutdownTimeout       time.Duration
+	LogFormat             string
+	AuthenticationEnabled bool
+	// AuthenticateAll requires the authentication on every path except the
+	// PublicPaths patterns, AuthenticationEnabled must be set
+	AuthenticateAll bool
+	PublicPaths     []string
+	// MetricsTokenFile holds the bearer token required on /metrics when set
+	MetricsTokenFile string
+	// InternalPort is the port of a plain HTTP listener serving the probes
+	// and the metrics instead of the main listener, disabled when 0
+	InternalPort int
+	// TracingEndpoint is the OTLP/HTTP collector URL the spans are exported
+	// to, tracing is disabled when empty
+	TracingEndpoint string
+	// AuditEnabled records the log queries of the users, in AuditLogPath
+	// when set or with the plugin logs otherwise, and mirrors them to
+	// AuditWebhookURL when set
+	AuditEnabled    bool
+	AuditLogPath    string
+	AuditRedaction  string
+	AuditWebhookURL string
+	// ReadTimeout, ReadHeaderTimeout and IdleTimeout bound the connections,
+	// WriteTimeout bounds the responses of the routes other than the
+	// streaming ones, the probes and the API routes bound by the plugin
+	// config timeout
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// SPAFallbackPrefixes are the path prefixes of the frontend routes served
+	// with SPAFallbackFile when no static file matches
+	SPAFallbackPrefixes []string
+	SPAFallbackFile     string
+	// Standalone serves the frontend outside of the console, with a login
+	// form and the console proxy path routed to the backend
+	Standalone bool
+	// Dev disables the caching and reports the changes of the static files,
+	// the missing files are proxied to DevServerURL when set
+	Dev          bool
+	DevServerURL string
+	// ListenSocket is the path of the unix socket to listen on instead of
+	// the TCP port, for a reverse proxy in the pod
+	ListenSocket string
+	// TLSMinVersion and TLSMaxVersion bound the TLS versions of the served
+	// connections, TLSCipherSuites restricts the TLS 1.2 cipher suites
+	TLSMinVersion   uint16
+	TLSMaxVersion   uint16
+	TLSCipherSuites []uint16
+	// ClientCAFile verifies the client certificates, required on the /api/
+	// routes when set
+	ClientCAFile string
+	// HTTPRedirectPort is the port of a plain HTTP listener redirecting to
+	// the HTTPS port when TLS is enabled, disabled when 0
+	HTTPRedirectPort int
+	// HTTP2Disabled serves HTTP/1.1 only, for the proxies breaking with h2,
+	// HTTP2MaxStreams bounds the streams of an h2 connection
+	HTTP2Disabled   bool
+	HTTP2MaxStreams uint32
+	// MaxHeaderBytes bounds the size of the request headers, 1MB when 0
+	MaxHeaderBytes int
+	// KeepAlivesDisabled closes the connections after each response
+	KeepAlivesDisabled bool
+	// MaxRequestBodySize bounds the request bodies in bytes, unlimited when
+	// 0 or negative, RequestBodyLimits overrides it for path prefixes
+	MaxRequestBodySize int64
+	RequestBodyLimits  []RequestBodyLimit
+	// ListenRetryTimeout is the time the address is bound again with a
+	// backoff while it is in use, like by the previous container during a
+	// restart, the bind fails at once when 0
+	ListenRetryTimeout time.Duration
+	// Middlewares are the names of the middlewares wrapping the routes, the
+	// outermost first, DefaultMiddlewares and the registered ones when
+	// empty. DisabledMiddlewares are removed from them
+	Middlewares         []string
+	DisabledMiddlewares []string
 }
 
-func Start(cfg *Config) {
-	router := setupRoutes(cfg)
-	router.Use(corsHeaderMiddleware(cfg))
+// Server is the plugin backend set up by New and served by Run
+type Server struct {
+	cfg             *Config
+	reloadingConfig *reloadingPluginConfig
+	httpServer      *http.Server
+	tlsConfig       *tls.Config
+	tlsEnabled      bool
+	network         string
+	addr            string
+	redirectAddr    string
+	// internalServer serves the probes and the metrics on internalAddr
+	internalServer *http.Server
+	internalAddr   string
+	// watchdog finds the stuck subsystems for the liveness probe
+	watchdog *watchdog
+	// closers release the services of the server once it stops
+	closers []func()
 
-	loggedRouter := handlers.LoggingHandler(slog.Logger.Out, router)
+	ready    chan struct{}
+	listener net.Listener
+}
 
-	// clients must use TLS 1.2 or higher
-	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
+// New validates cfg and the plugin config and sets up the services and the
+// routes of the plugin, the errors describe the invalid settings
+func New(cfg *Config) (*Server, error) {
+	s := &Server{cfg: cfg, ready: make(chan struct{})}
+	if err := s.setup(); err != nil {
+		s.close()
+		return nil, err
 	}
+	return s, nil
+}
 
-	httpServer := &http.Server{
-		Handler:      loggedRouter,
-		Addr:         fmt.Sprintf(":%d", cfg.Port),
-		TLSConfig:    tlsConfig,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-	}
+func (s *Server) setup() error {
+	cfg := s.cfg
+	// ctx bounds the background work of the services, like the query
+	// history sync, until the server stops
+	ctx, cancel := context.WithCancel(context.Background())
+	s.closers = append(s.closers, cancel)
+
+	// keep the last error logs for the support bundles
+	errorLogsHookSet.Do(func() { logrus.AddHook(errorLogs) })
 
-	if cfg.CertFile != "" && cfg.PrivateKeyFile != "" {
-		slog.Infof("listening on https://:%d", cfg.Port)
-		panic(httpServer.ListenAndServeTLS(cfg.CertFile, cfg.PrivateKeyFile))
+	var reloadingConfig *reloadingPluginConfig
+	var err error
+	if cfg.PluginConfigMap != "" {
+		if cfg.PluginConfigPath != "" {
+			return fmt.Errorf("the plugin config cannot be read from both a file and a ConfigMap")
+		}
+		namespace, name, err := kube.ParseNamespacedName(cfg.PluginConfigMap)
+		if err != nil {
+			return fmt.Errorf("invalid plugin config ConfigMap: %w", err)
+		}
+		client, err := kube.NewInClusterClient()
+		if err != nil {
+			return fmt.Errorf("cannot read the plugin config ConfigMap: %w", err)
+		}
+		if reloadingConfig, err = newConfigMapPluginConfig(ctx, client, namespace, name); err != nil {
+			return err
+		}
 	} else {
-		slog.Infof("listening on http://:%d", cfg.Port)
-		panic(httpServer.ListenAndServe())
+		reloadingConfig, err = newReloadingPluginConfig(cfg.PluginConfigPath)
+		if err != nil {
+			return err
+		}
+	}
+	s.reloadingConfig = reloadingConfig
+
+	// without datasource, the well-known Loki gateways of the cluster are
+	// probed so that the standard installs work without config
+	if len(reloadingConfig.get().allDatasources()) == 0 {
+		dialer := &net.Dialer{}
+		if ds, ok := discoverLoki(ctx, lokiDiscoveryCandidates(podNamespace(), serviceCA()), dialer.DialContext); ok {
+			slog.Infof("no datasource is configured, using the discovered Loki gateway %s", ds.URL)
+			reloadingConfig.useDiscovered(ds)
+		} else {
+			slog.Warn("no datasource is configured and no Loki gateway was discovered, set lokiURL")
+		}
+	}
+	pluginConfig := reloadingConfig.get()
+
+	for _, ds := range pluginConfig.allDatasources() {
+		if ds.InsecureSkipVerify {
+			slog.Warnf("the certificate of datasource %s is not verified, do not use in production", ds.Name)
+		}
+	}
+
+	var authenticator *tokenAuthenticator
+	var authorizer *authz.Authorizer
+	var savedQueries *store.Store
+	var queryHistory *store.History
+	if cfg.AuthenticationEnabled {
+		client, err := kube.NewInClusterClient()
+		if err != nil {
+			return fmt.Errorf("cannot enable authentication: %w", err)
+		}
+		authenticator = newTokenAuthenticator(client)
+		if pluginConfig.AllowImpersonation {
+			authenticator.impersonator = newImpersonator(client)
+		}
+
+		if pluginConfig.Authorization.Enabled {
+			authorizer = authz.New(client, pluginConfig.Authorization.resourceAttributes())
+		}
+
+		if pluginConfig.SavedQueries.Enabled {
+			savedQueries, err = newSavedQueriesStore(client, pluginConfig.SavedQueries)
+			if err != nil {
+				return fmt.Errorf("cannot enable saved queries: %w", err)
+			}
+		}
+
+		if pluginConfig.QueryHistory.Enabled {
+			queryHistory, err = newQueryHistory(ctx, client, pluginConfig.QueryHistory)
+			if err != nil {
+				return fmt.Errorf("cannot enable query history: %w", err)
+			}
+		}
+	} else if pluginConfig.Authorization.Enabled {
+		return fmt.Errorf("authorization requires authentication to be enabled")
+	} else if pluginConfig.SavedQueries.Enabled {
+		return fmt.Errorf("saved queries require authentication to be enabled")
+	} else if pluginConfig.QueryHistory.Enabled {
+		return fmt.Errorf("query history requires authentication to be enabled")
+	} else if pluginConfig.AllowImpersonation {
+		return fmt.Errorf("impersonation requires authentication to be enabled")
+	} else if cfg.Standalone {
+		return fmt.Errorf("standalone mode requires authentication to be enabled")
+	} else if cfg.AuthenticateAll {
+		return fmt.Errorf("authenticating every path requires authentication to be enabled")
+	}
+
+	// the events are cached from the start, the users are authorized to list
+	// the events of the namespaces they request
+	var events *eventsCache
+	var eventsAuthorizer *authz.Authorizer
+	if resolveFeatures(cfg.Features, pluginConfig).enabled[featureEvents] {
+		if !cfg.AuthenticationEnabled {
+			return fmt.Errorf("the %s feature requires authentication to be enabled", featureEvents)
+		}
+		client, err := kube.NewInClusterClient()
+		if err != nil {
+			return fmt.Errorf("cannot enable the %s feature: %w", featureEvents, err)
+		}
+		events = newEventsCache(client, pluginConfig.Events)
+		eventsAuthorizer = authz.New(client, kube.ResourceAttributes{Verb: "list", Resource: "events"})
+		go events.run(ctx)
+	}
+
+	// the retention of the LokiStack is read with the plugin service account
+	var retention *retentionPolicy
+	if pluginConfig.Retention.enabled() {
+		var client lokiStackGetter
+		if pluginConfig.Retention.LokiStack != "" {
+			kubeClient, err := kube.NewInClusterClient()
+			if err != nil {
+				return fmt.Errorf("cannot read the retention of the LokiStack: %w", err)
+			}
+			client = kubeClient
+		}
+		retention = newRetentionPolicy(pluginConfig.Retention, client)
+		if client != nil {
+			go retention.run(ctx, lokiStackRetentionSyncInterval)
+		}
+	}
+
+	// the liveness probe fails once a lock of the plugin config or of the
+	// caches is held too long, or the goroutines are starved
+	s.watchdog = newWatchdog(pluginConfig.Watchdog)
+	s.watchdog.watchLock("plugin config", reloadingConfig.mu.RLocker())
+	if authenticator != nil {
+		s.watchdog.watchLock("token cache", &authenticator.mu)
+	}
+	if events != nil {
+		s.watchdog.watchLock("events cache", events.mu.RLocker())
+	}
+	go s.watchdog.run(ctx, watchdogCheckInterval)
+
+	// the self-test follows the plugin config reloads
+	selfTest := newSelfTester(reloadingConfig)
+	go selfTest.run(ctx, selfTestInterval)
+
+	var tracer *tracing.Tracer
+	if cfg.TracingEndpoint != "" {
+		tracer, err = tracing.New(tracing.Config{Endpoint: cfg.TracingEndpoint})
+		if err != nil {
+			return fmt.Errorf("cannot enable tracing: %w", err)
+		}
+		slog.Infof("exporting traces to %s", cfg.TracingEndpoint)
+		s.closers = append(s.closers, func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tracer.Shutdown(shutdownCtx); err != nil {
+				slog.WithError(err).Warn("cannot flush traces")
+			}
+		})
+	}
+
+	serviceAccountToken, err := newServiceAccountToken(pluginConfig.ServiceAccountAuth)
+	if err != nil {
+		return fmt.Errorf("cannot enable service account authentication: %w", err)
+	}
+
+	auditor, err := newAuditLogger(cfg)
+	if err != nil {
+		return err
+	}
+	s.closers = append(s.closers, func() { auditor.Close() })
+
+	var devServer http.Handler
+	if cfg.Dev {
+		slog.Warn("dev mode enabled, caching is disabled, do not use in production")
+		if cfg.DevServerURL != "" {
+			devServer, err = newDevServerProxy(cfg.DevServerURL)
+			if err != nil {
+				return err
+			}
+			slog.Infof("proxying the missing static files to %s", cfg.DevServerURL)
+		}
+	} else if cfg.DevServerURL != "" {
+		return fmt.Errorf("the dev server URL requires dev mode to be enabled")
+	}
+
+	certificates, err := newServingCertificates(cfg)
+	if err != nil {
+		return err
+	}
+
+	var metricsToken *kube.TokenFile
+	if cfg.MetricsTokenFile != "" {
+		metricsToken = kube.NewTokenFile(cfg.MetricsTokenFile)
+		if _, err := metricsToken.Token(); err != nil {
+			return fmt.Errorf("cannot read the metrics token: %w", err)
+		}
+	}
+
+	middlewares, err := middlewareNames(cfg)
+	if err != nil {
+		return err
+	}
+	// the middlewares enforcing a setting cannot be disabled
+	for name, required := range map[string]bool{
+		MiddlewareAuthenticateAll: cfg.AuthenticateAll,
+		MiddlewareStandalone:      cfg.Standalone,
+		MiddlewareClientCert:      cfg.ClientCAFile != "",
+	} {
+		if required && !middlewareEnabled(cfg, name) {
+			return fmt.Errorf("the %s middleware is required by its settings and cannot be disabled", name)
+		}
+	}
+
+	shadows, err := newDatasourceShadows(pluginConfig)
+	if err != nil {
+		return err
+	}
+
+	queryDiskCache, err := newQueryDiskCache(pluginConfig.QueryCache)
+	if err != nil {
+		return fmt.Errorf("cannot enable the disk cache of the queries: %w", err)
+	}
+
+	deps := routeDeps{
+		authenticator:       authenticator,
+		authorizer:          authorizer,
+		tracer:              tracer,
+		savedQueries:        savedQueries,
+		queryHistory:        queryHistory,
+		auditor:             auditor,
+		serviceAccountToken: serviceAccountToken,
+		breakers:            newDatasourceBreakers(pluginConfig),
+		shadows:             shadows,
+		queryDiskCache:      queryDiskCache,
+		devServer:           devServer,
+		certificates:        certificates,
+		events:              events,
+		eventsAuthorizer:    eventsAuthorizer,
+		retention:           retention,
+		selfTest:            selfTest,
+		metricsToken:        metricsToken,
+		watchdog:            s.watchdog,
+	}
+	router := setupRoutes(cfg, reloadingConfig, deps)
+
+	builtins := map[string]Middleware{
+		MiddlewareRequestID:        requestIDMiddleware,
+		MiddlewareAccessLog:        accessLogHandler(pluginConfig.AccessLog),
+		MiddlewareCORS:             corsHeaderMiddleware(pluginConfig.CORS),
+		MiddlewareStandalone:       nil,
+		MiddlewareAuthenticateAll:  nil,
+		MiddlewareMetrics:          instrumentationMiddleware,
+		MiddlewareClientCert:       clientCertMiddleware(cfg.ClientCAFile != ""),
+		MiddlewareRequestBodyLimit: requestBodyLimitMiddleware(cfg.MaxRequestBodySize, cfg.RequestBodyLimits),
+		MiddlewareTracing:          tracingMiddleware(tracer),
+		MiddlewareCacheControl:     cacheControlMiddleware(pluginConfig.CacheControl),
+		MiddlewareSecurityHeaders:  securityHeadersMiddleware(pluginConfig.SecurityHeaders, pluginConfig.CORS),
+		MiddlewareCompression:      compressionMiddleware(pluginConfig.Compression),
+		MiddlewareTimeout:          timeoutMiddleware(newRouteDeadlines(cfg, pluginConfig)),
+		MiddlewareFaultInjection:   nil,
+	}
+	if cfg.Dev {
+		builtins[MiddlewareCacheControl] = devMiddleware
+	}
+	if cfg.FaultInjection && middlewareEnabled(cfg, MiddlewareFaultInjection) {
+		slog.Warnf("fault injection enabled with %d rules, do not use in production", len(pluginConfig.FaultInjection))
+		builtins[MiddlewareFaultInjection] = faultInjectionMiddleware(pluginConfig.FaultInjection)
+	}
+	if cfg.AuthenticateAll {
+		publicPaths := cfg.PublicPaths
+		if cfg.Standalone {
+			publicPaths = append([]string{standaloneLoginPath, standaloneLogoutPath}, publicPaths...)
+		}
+		slog.Infof("authentication required on every path except %s", strings.Join(publicPaths, ", "))
+		builtins[MiddlewareAuthenticateAll] = authenticateAllMiddleware(authenticator, publicPaths)
+	}
+	if cfg.Standalone {
+		slog.Info("standalone mode enabled, serving the frontend with a login form")
+		builtins[MiddlewareStandalone] = standaloneMiddleware
+	}
+	slog.Infof("middlewares: %s", strings.Join(middlewares, ", "))
+	loggedRouter := chainMiddlewares(router, middlewares, builtins)
+
+	tlsConfig, err := newTLSConfig(cfg, certificates)
+	if err != nil {
+		return err
+	}
+	s.tlsConfig = tlsConfig
+
+	s.network, s.addr, err = listenAddress(cfg)
+	if err != nil {
+		return err
+	}
+
+	s.httpServer = &http.Server{
+		Handler:           loggedRouter,
+		Addr:              s.addr,
+		TLSConfig:         tlsConfig,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		// the response deadlines are set per route by timeoutMiddleware
+	}
+	if err := configureConnections(s.httpServer, cfg); err != nil {
+		return err
+	}
+
+	s.tlsEnabled = (cfg.CertFile != "" && cfg.PrivateKeyFile != "") || cfg.CertSecret != ""
+
+	if cfg.InternalPort != 0 {
+		if s.internalAddr, err = internalAddress(cfg, s.addr); err != nil {
+			return err
+		}
+		internalRouter := mux.NewRouter()
+		registerInternalRoutes(internalRouter, cfg, pluginConfig, deps)
+		s.internalServer = &http.Server{
+			Handler:           requestIDMiddleware(internalRouter),
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		}
+	}
+
+	if cfg.HTTPRedirectPort != 0 {
+		if !s.tlsEnabled {
+			return fmt.Errorf("the HTTP redirect port requires TLS to be enabled")
+		}
+		if s.redirectAddr, err = redirectAddress(cfg, s.addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close releases the services of the server
+func (s *Server) close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+	s.closers = nil
+}
+
+// Ready is closed once Run listens, Addr then returns the listened address
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Addr returns the address the server listens on, nil before Ready is closed
+func (s *Server) Addr() net.Addr {
+	select {
+	case <-s.ready:
+		return s.listener.Addr()
+	default:
+		return nil
+	}
+}
+
+// Run serves the plugin until ctx is done, then stops accepting connections
+// and waits up to ShutdownTimeout for the in-flight requests to complete. A
+// Server is run once, its services are released when Run returns
+func (s *Server) Run(ctx context.Context) error {
+	defer s.close()
+	cfg := s.cfg
+
+	// the plugin config is watched until Run returns, the watchdog fails the
+	// liveness probe when the watch stops syncing
+	watchCtx, stopConfigWatch := context.WithCancel(ctx)
+	defer stopConfigWatch()
+	if cfg.PluginConfigPath != "" || cfg.PluginConfigMap != "" {
+		s.reloadingConfig.heartbeat = s.watchdog.heartbeat("the plugin config watcher")
+	}
+	if cfg.PluginConfigPath != "" {
+		go s.reloadingConfig.watch(watchCtx, pluginConfigCheckInterval)
+	} else if cfg.PluginConfigMap != "" {
+		go s.reloadingConfig.watchConfigMap(watchCtx)
+	}
+
+	if cfg.Dev && (cfg.StaticPath != "" || cfg.StaticFS == nil) {
+		stopWatch := make(chan struct{})
+		defer close(stopWatch)
+		for _, dir := range staticDirs(cfg.StaticPath) {
+			dir := dir
+			go watchStaticPath(dir, devWatchInterval, stopWatch, func(changed []string) {
+				slog.Infof("static files changed in %s: %s", dir, strings.Join(changed, ", "))
+			})
+		}
 	}
+
+	listener, err := listenWithRetry(ctx, s.network, s.addr, cfg.ListenRetryTimeout)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 3)
+
+	var redirectServer *http.Server
+	if s.redirectAddr != "" {
+		redirectListener, err := listenWithRetry(ctx, s.network, s.redirectAddr, cfg.ListenRetryTimeout)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+
+		redirectServer = &http.Server{
+			Handler:           httpsRedirectHandler(listener.Addr().(*net.TCPAddr).Port),
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		}
+
+		go func() {
+			slog.Infof("redirecting http://%s to https", listenerHost(redirectListener))
+			serveErr <- redirectServer.Serve(redirectListener)
+		}()
+	}
+
+	if s.internalServer != nil {
+		network := s.network
+		if network == "unix" {
+			network = "tcp"
+		}
+		internalListener, err := listenWithRetry(ctx, network, s.internalAddr, cfg.ListenRetryTimeout)
+		if err != nil {
+			listener.Close()
+			if redirectServer != nil {
+				redirectServer.Close()
+			}
+			return err
+		}
+
+		go func() {
+			slog.Infof("serving the probes and the metrics on http://%s", listenerHost(internalListener))
+			serveErr <- s.internalServer.Serve(internalListener)
+		}()
+	}
+
+	s.listener = listener
+	close(s.ready)
+
+	go func() {
+		if s.tlsEnabled {
+			slog.Infof("listening on https://%s", listenerHost(listener))
+			slog.Infof("serving %s", describeTLSPolicy(s.tlsConfig))
+			if cfg.HTTP2Disabled {
+				slog.Info("HTTP/2 disabled, serving HTTP/1.1 only")
+			}
+			// the certificates are served by tlsConfig.GetCertificate
+			serveErr <- s.httpServer.ServeTLS(listener, "", "")
+		} else {
+			slog.Infof("listening on http://%s", listenerHost(listener))
+			serveErr <- s.httpServer.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Infof("shutting down, waiting up to %s for in-flight requests", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if redirectServer != nil {
+		// the redirects have no in-flight work to wait for
+		redirectServer.Close()
+	}
+	if s.internalServer != nil {
+		// the probes and the metrics are served until the requests drain
+		defer s.internalServer.Close()
+	}
+
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("cannot drain connections: %w", err)
+	}
+
+	return nil
+}
+
+// routeDeps are the services shared by the routes, the nil ones disable the
+// features depending on them
+type routeDeps struct {
+	authenticator *tokenAuthenticator
+	authorizer    *authz.Authorizer
+	tracer        *tracing.Tracer
+	savedQueries  *store.Store
+	queryHistory  *store.History
+	auditor       *auditLogger
+	// serviceAccountToken is sent to Loki instead of the user tokens when set
+	serviceAccountToken *kube.TokenFile
+	// breakers are the circuit breakers of the datasources by name
+	breakers map[string]*proxy.Breaker
+	// shadows mirror the queries of the datasources by name
+	shadows map[string]*proxy.Shadow
+	// queryDiskCache stores the responses of the queries over a completed
+	// range on disk, shared by the datasources
+	queryDiskCache *proxy.DiskCache
+	// devServer serves the static files missing in dev mode
+	devServer http.Handler
+	// certificates are the serving certificates checked for expiry
+	certificates *servingCertificates
+	// events are the cached Kubernetes events, the users must be allowed by
+	// eventsAuthorizer to list the events of a namespace
+	events           *eventsCache
+	eventsAuthorizer *authz.Authorizer
+	// retention rejects the queries outside the retention of their tenant
+	retention *retentionPolicy
+	// selfTest reports the problems of the plugin config and datasources
+	selfTest *selfTester
+	// metricsToken is the token required on /metrics when set
+	metricsToken *kube.TokenFile
+	// watchdog fails the liveness probe when a subsystem is stuck
+	watchdog *watchdog
 }
 
-func setupRoutes(cfg *Config) *mux.Router {
+// setupRoutes registers the routes, only the /config content follows the
+// plugin config reloads, the other settings are read once
+func setupRoutes(cfg *Config, reloadingConfig *reloadingPluginConfig, deps routeDeps) *mux.Router {
 	r := mux.NewRouter()
+	pluginConfig := reloadingConfig.get()
+	authenticated := authenticationMiddleware(deps.authenticator)
+	authorized := authorizationMiddleware(deps.authorizer, pluginConfig.Authorization.Tenants, deps.serviceAccountToken != nil)
+	limiter := newRateLimiter(pluginConfig.RateLimit)
+	// the route-level middlewares removed by -disable-middlewares, the admin
+	// routes keep their own authorization
+	if !middlewareEnabled(cfg, MiddlewareRouteAuth) {
+		slog.Warn("the routes are served without authentication and authorization, do not use in production")
+		authenticated = func(next http.Handler) http.Handler { return next }
+		authorized = authenticated
+	}
+	if !middlewareEnabled(cfg, MiddlewareRateLimit) {
+		limiter = nil
+	}
+	if !middlewareEnabled(cfg, MiddlewareAudit) {
+		deps.auditor = nil
+	}
+	scheduler := newFairScheduler(pluginConfig.Fairness)
+	// the routes of the backend features are registered once
+	resolved := resolveFeatures(cfg.Features, pluginConfig)
+	startupFeatures := resolved.enabled
+	for _, feature := range sortedDisabledFeatures(resolved.disabled) {
+		slog.Warnf("feature %s is disabled: %s", feature, resolved.disabled[feature])
+	}
+
+	// the auto tenant of the queries of a datasource is resolved, then the
+	// queries are authenticated, their parameters validated, and they are
+	// rate limited, audited, authorized and scheduled. The long-lived tail
+	// streams are not scheduled, and only the ranges of the queries, pages,
+	// exports, volumes, stats and severity counts are checked against the
+	// retention
+	tenantMapper := newTenantMapper(pluginConfig.TenantMapping)
+	tenants := tenantMappingMiddleware(tenantMapper)
+	queries := func(route string, ds DatasourceConfig, h http.Handler) http.Handler {
+		if route == "proxy" || route == "entries" || route == "export" || route == "volume" || route == "stats" || route == "severity" {
+			h = retentionMiddleware(deps.retention)(h)
+		}
+		if route != "tail" {
+			h = fairnessMiddleware(scheduler, route)(h)
+		}
+		return tenants(authenticated(queryParamsMiddleware(rateLimitMiddleware(limiter, route)(auditMiddleware(deps.auditor, route, ds.Name)(authorized(h))))))
+	}
+
+	// liveness and readiness probes and metrics, unless they are served by
+	// the internal listener
+	if cfg.InternalPort == 0 {
+		registerInternalRoutes(r, cfg, pluginConfig, deps)
+	}
+
+	// serve the build information
+	r.Path("/version").Methods(http.MethodGet).HandlerFunc(versionHandler(cfg, reloadingConfig))
 
-	r.PathPrefix("/health").HandlerFunc(healthHandler())
+	// serve the contract of the backend routes
+	r.Path(openAPIPath).Methods(http.MethodGet).HandlerFunc(openAPIHandler())
 
 	// serve plugin manifest according to enabled features
-	r.Path("/plugin-manifest.json").Handler(manifestHandler(cfg))
+	r.Path("/plugin-manifest.json").Handler(manifestHandler(cfg, reloadingConfig))
+
+	// serve the last self-test report, the front-end shows its problems
+	if deps.selfTest != nil {
+		r.Path("/api/status").Methods(http.MethodGet).Handler(authenticated(statusHandler(deps.selfTest)))
+	}
 
 	// serve enabled features list to the front-end
-	r.PathPrefix("/features").HandlerFunc(featuresHandler(cfg))
+	r.PathPrefix("/features").Handler(authenticated(featuresHandler(cfg, reloadingConfig)))
+
+	// resolve the tenant of the namespaces for the front-end
+	r.Path("/api/tenant").Methods(http.MethodGet).Handler(authenticated(tenantHandler(tenantMapper)))
+
+	// serve the plugin config to the front-end
+	r.Path("/config").Handler(authenticated(rateLimitMiddleware(limiter, "config")(configHandler(reloadingConfig))))
+
+	// serve the config, the features, the capabilities and the available
+	// tenants in one response, the capabilities are recorded below as their
+	// routes are registered
+	capabilities := map[string]bool{}
+	r.Path("/bootstrap").Methods(http.MethodGet).Handler(authenticated(rateLimitMiddleware(limiter, "config")(bootstrapHandler(cfg, reloadingConfig, capabilities, deps.authorizer, deps.serviceAccountToken != nil))))
+
+	// proxy LogQL queries to the datasources forwarding the user bearer
+	// token, the named routes take precedence over the default datasource
+	// tenants
+	backends := map[string]datasource.Datasource{}
+	guardrails := guardrailsMiddleware(pluginConfig)
+	for _, ds := range pluginConfig.allDatasources() {
+		// serve the pod logs from the API server when no Loki is installed,
+		// the API server authorizes the users
+		if ds.Type == datasourceTypeKubernetes {
+			podsPrefix := "/api/pods/" + ds.Name
+			r.PathPrefix(podsPrefix + "/").Handler(http.StripPrefix(podsPrefix, authenticated(rateLimitMiddleware(limiter, "pods")(auditMiddleware(deps.auditor, "pods", ds.Name)(podLogsHandler(ds, pluginConfig))))))
+			capabilities[capabilityPodLogs] = true
+			continue
+		}
+
+		backend := newDatasource(ds, pluginConfig, deps)
+		backends[ds.Name] = backend
+		proxyPrefix, tailPrefix, metadataPrefix := "/api/proxy/"+ds.Name, "/api/tail/"+ds.Name, "/api/metadata/"+ds.Name
+		r.PathPrefix(proxyPrefix + "/").Handler(http.StripPrefix(proxyPrefix, queries("proxy", ds, textLogsMiddleware(guardrails(datasource.QueryHandler(backend, writeProxyError))))))
+		r.PathPrefix(tailPrefix + "/sse/").Handler(http.StripPrefix(tailPrefix+"/sse", queries("tail", ds, guardrails(datasource.EventStreamTailHandler(backend)))))
+		r.PathPrefix(tailPrefix + "/").Handler(http.StripPrefix(tailPrefix, queries("tail", ds, guardrails(datasource.TailHandler(backend)))))
+		r.PathPrefix(metadataPrefix + "/").Handler(http.StripPrefix(metadataPrefix, queries("metadata", ds, guardrails(datasource.MetadataHandler(backend, writeProxyError)))))
+	}
+	if ds, ok := pluginConfig.defaultDatasource(); ok {
+		backend := backends[ds.Name]
+		r.PathPrefix("/api/proxy/").Handler(http.StripPrefix("/api/proxy", queries("proxy", ds, textLogsMiddleware(guardrails(datasource.QueryHandler(backend, writeProxyError))))))
+		// the tail streams sent as server-sent events, for the clients
+		// behind the proxies stripping the WebSocket upgrades
+		r.PathPrefix("/api/tail/sse/").Handler(http.StripPrefix("/api/tail/sse", queries("tail", ds, guardrails(datasource.EventStreamTailHandler(backend)))))
+		r.PathPrefix("/api/tail/").Handler(http.StripPrefix("/api/tail", queries("tail", ds, guardrails(datasource.TailHandler(backend)))))
+		r.PathPrefix("/api/metadata/").Handler(http.StripPrefix("/api/metadata", queries("metadata", ds, guardrails(datasource.MetadataHandler(backend, writeProxyError)))))
+		capabilities[capabilityTail] = true
+
+		// paginate the logs of the default datasource with a cursor, for the
+		// infinite scroll of the UI
+		if defaultFeatureEnabled(pluginConfig, featureEntries) {
+			r.PathPrefix("/api/entries/").Handler(http.StripPrefix("/api/entries", queries("entries", ds, guardrails(entriesHandler(ds, pluginConfig, deps)))))
+			capabilities[featureEntries] = true
+		}
+
+		// export the logs of the default datasource as files
+		if defaultFeatureEnabled(pluginConfig, featureExport) {
+			r.PathPrefix("/api/export/").Handler(http.StripPrefix("/api/export", queries("export", ds, exportHandler(ds, pluginConfig, deps))))
+			capabilities[featureExport] = true
+
+			// upload the larger exports to the object storage in the
+			// background, the jobs are authorized like the exports
+			if pluginConfig.Export.ObjectStorage.enabled() {
+				jobs := newExportJobs(pluginConfig.Export.ObjectStorage)
+				r.Path("/api/export-jobs/{tenant}/{id}").Methods(http.MethodGet).Handler(authenticated(exportJobHandler(jobs)))
+				r.PathPrefix("/api/export-jobs/").Methods(http.MethodPost).Handler(http.StripPrefix("/api/export-jobs", queries("export", ds, exportJobsHandler(ds, pluginConfig, deps, jobs))))
+				capabilities[capabilityExportJobs] = true
+			}
+		}
+
+		// count the logs of the default datasource for the histogram
+		if defaultFeatureEnabled(pluginConfig, featureVolume) {
+			r.PathPrefix("/api/volume/").Handler(http.StripPrefix("/api/volume", queries("volume", ds, guardrails(volumeHandler(ds, pluginConfig, deps)))))
+			capabilities[featureVolume] = true
+		}
+
+		// count the errors, warnings and infos of the namespaces for the
+		// overview cards of the dashboards
+		if defaultFeatureEnabled(pluginConfig, featureSeverity) {
+			r.PathPrefix("/api/severity/").Handler(http.StripPrefix("/api/severity", namespacesSelectorMiddleware(queries("severity", ds, guardrails(severityHandler(ds, pluginConfig, deps))))))
+			capabilities[featureSeverity] = true
+		}
+
+		// complete the queries of the editor with the labels of the default
+		// datasource and the saved and recent queries of the user
+		if defaultFeatureEnabled(pluginConfig, featureSuggest) {
+			r.PathPrefix("/api/suggest/").Handler(http.StripPrefix("/api/suggest", namespacesSelectorMiddleware(queries("suggest", ds, suggestHandler(ds, pluginConfig, deps)))))
+			capabilities[featureSuggest] = true
+		}
+
+		// explain the execution of the queries of the default datasource
+		if defaultFeatureEnabled(pluginConfig, featureStats) {
+			r.PathPrefix("/api/stats/").Handler(http.StripPrefix("/api/stats", queries("stats", ds, guardrails(statsHandler(ds, pluginConfig, deps)))))
+			capabilities[featureStats] = true
+		}
+
+		// serve the rules of the default datasource filtered by tenant and
+		// namespace access
+		if defaultFeatureEnabled(pluginConfig, featureRules) {
+			r.Path("/api/rules").Handler(authenticated(rulesHandler(ds, pluginConfig, deps)))
+			capabilities[featureRules] = true
+		}
+	}
+
+	// expose the runtime profiles to investigate the plugin pod
+	if startupFeatures[featureDevProfiling] {
+		registerProfilingRoutes(r, adminMiddleware(deps.authenticator, reloadingConfig))
+	}
+
+	// correlate the log lines with other signals, korrel8r queries the
+	// cluster with the user bearer token
+	if startupFeatures[featureKorrel8r] {
+		r.PathPrefix("/api/korrel8r/").Handler(http.StripPrefix("/api/korrel8r", authenticated(korrel8rHandler(pluginConfig.Korrel8r))))
+		capabilities[featureKorrel8r] = true
+	}
+
+	// serve the Kubernetes events of the resources of the log streams, from
+	// the cache of the plugin service account
+	if startupFeatures[featureEvents] && deps.events != nil {
+		r.Path("/api/events").Methods(http.MethodGet).Handler(authenticated(eventsHandler(deps.events, deps.eventsAuthorizer)))
+		capabilities[featureEvents] = true
+	}
+
+	// persist the queries saved by the users
+	if deps.savedQueries != nil {
+		registerSavedQueriesRoutes(r, authenticated, deps.savedQueries)
+		capabilities[capabilitySavedQueries] = true
+	}
+
+	// list the recent queries of the users
+	if deps.queryHistory != nil {
+		r.Path("/api/history").Methods(http.MethodGet).Handler(authenticated(queryHistoryHandler(deps.queryHistory, pluginConfig.QueryHistory.AdminGroups)))
+		capabilities[capabilityQueryHistory] = true
+	}
+
+	// change the backend at runtime, like its log level and the features
+	registerAdminRoutes(r, cfg, reloadingConfig, deps.authenticator)
+
+	// package the diagnostics asked in the support cases
+	r.Path(supportBundlePath).Methods(http.MethodGet).Handler(adminMiddleware(deps.authenticator, reloadingConfig)(supportBundleHandler(cfg, reloadingConfig, deps.selfTest)))
+
+	// validate candidate plugin configs before they are rolled out
+	r.Path("/validate-config").Methods(http.MethodPost).Handler(authenticated(validateConfigHandler()))
+
+	// validate LogQL queries and scope them to namespaces before they are sent
+	r.Path("/api/logql/validate").Handler(authenticated(logQLValidateHandler()))
+
+	// derive logs page links from metric queries and alert labels
+	r.Path("/api/links/logs").Handler(authenticated(logsLinkHandler()))
+
+	// parse the fields of the log lines for the column and field selectors
+	if defaultFeatureEnabled(pluginConfig, featureParse) {
+		r.Path("/api/parse").Methods(http.MethodPost).Handler(authenticated(rateLimitMiddleware(limiter, "parse")(parseHandler())))
+		capabilities[featureParse] = true
+	}
+
+	// serve the translation bundles with language fallbacks
+	r.Path("/locales/{lng}/{ns}.json").Methods(http.MethodGet, http.MethodHead).Handler(newLocalesHandler(staticFileSystem(cfg)))
+
+	// serve additional static roots mounted at sub-paths
+	for _, root := range cfg.StaticRoots {
+		r.PathPrefix(root.Prefix + "/").Handler(staticRootHandler(root))
+	}
+
+	// log in the users of the standalone frontend
+	fallbackPrefixes := cfg.SPAFallbackPrefixes
+	if cfg.Standalone && deps.authenticator != nil {
+		r.Path(standaloneLoginPath).Methods(http.MethodGet, http.MethodPost).Handler(rateLimitMiddleware(limiter, "login")(standaloneLoginHandler(deps.authenticator)))
+		r.Path(standaloneLogoutPath).Methods(http.MethodGet, http.MethodPost).HandlerFunc(standaloneLogoutHandler())
+		if len(fallbackPrefixes) == 0 {
+			fallbackPrefixes = []string{"/"}
+		}
+	}
 
 	// serve front end files
-	r.PathPrefix("/").Handler(http.FileServer(http.Dir(cfg.StaticPath)))
+	r.PathPrefix("/").Handler(newFilesHandlerFS(staticFileSystem(cfg), "", "").withSPAFallback(fallbackPrefixes, cfg.SPAFallbackFile).withDevServer(deps.devServer))
 
 	return r
 }
 
-func healthHandler() http.HandlerFunc {
+func healthHandler(watchdog *watchdog) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if problems := watchdog.problems(); len(problems) > 0 {
+			writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "stuck: "+strings.Join(problems, ", "), problems)
+			return
+		}
 		w.Write([]byte("ok"))
 	})
 }
 
-func corsHeaderMiddleware(cfg *Config) func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			headers := w.Header()
-			headers.Set("Access-Control-Allow-Origin", "*")
-			next.ServeHTTP(w, r)
-		})
-	}
-}
+// configHandler serves the plugin config, merged with the overrides of the
+// tenant query parameter when set, in the requested schema version, or in
+// YAML when requested
+func configHandler(reloadingConfig *reloadingPluginConfig) http.HandlerFunc {
+	cache := newResponseCache()
 
-func featuresHandler(cfg *Config) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		jsonFeatures, err := json.Marshal(cfg.Features)
-
+		format, err := configFormat(r)
 		if err != nil {
-			slog.WithError(err).Errorf("cannot unmarshal, features were: %v", string(jsonFeatures))
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, http.StatusNotAcceptable, errorCodeInvalidRequest, err.Error(), nil)
+			return
+		}
+		version, err := configSchemaVersion(r)
+		if err != nil {
+			writeError(w, r, http.StatusNotAcceptable, errorCodeInvalidRequest, err.Error(), nil)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(jsonFeatures)
+		tenant := r.URL.Query().Get("tenant")
+		if tenant != "" && !tenantRegexp.MatchString(tenant) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid tenant %q", tenant), nil)
+			return
+		}
+
+		pluginConfig, generation, loadedAt := reloadingConfig.snapshot()
+		cached := cache.get(generation, fmt.Sprintf("%s/%d/%s", tenant, version, format), func() ([]byte, error) {
+			config := pluginConfig
+			if tenant != "" {
+				config = pluginConfig.forTenant(tenant)
+			}
+			if format == configFormatYAML {
+				return marshalConfigYAML(config)
+			}
+			return marshalConfigSchema(config, version)
+		})
+
+		if cached.err != nil {
+			requestLog(slog, r).WithError(cached.err).Errorf("cannot marshal the config of tenant %q", tenant)
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal config", cached.err.Error())
+			return
+		}
+
+		w.Header().Add("Vary", "Accept")
+		if format == configFormatYAML {
+			writeCachedResponse(w, r, cached, "application/yaml", loadedAt)
+			return
+		}
+		writeCachedJSON(w, r, cached, loadedAt)
 	})
 }
@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -16,6 +17,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -51,10 +53,18 @@ func TestServerRunning(t *testing.T) {
 	tmpDir := prepareServerAssets(t)
 	defer os.RemoveAll(tmpDir)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s, err := New(&Config{
+		Port:            testPort,
+		ShutdownTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	serverErr := make(chan error, 1)
 	go func() {
-		Start(&Config{
-			Port: testPort,
-		})
+		serverErr <- s.Run(ctx)
 	}()
 
 	t.Logf("Started test http server: %v", serverURL)
@@ -84,6 +94,16 @@ func TestServerRunning(t *testing.T) {
 	if _, err = getRequestResults(t, httpClient, serverURL+"/badroot"); err == nil {
 		t.Fatalf("Failed: Should have failed going to /badroot")
 	}
+
+	// the server returns without error once drained, the client closes its
+	// kept-alive connections first so that the shutdown does not wait on them
+	httpClient.CloseIdleConnections()
+	cancel()
+	require.NoError(t, <-serverErr)
+
+	if _, err = getRequestResults(t, httpClient, serverURL+"/health"); err == nil {
+		t.Fatalf("Failed: Should not accept connections after shutdown")
+	}
 }
 
 func TestSecureServerRunning(t *testing.T) {
@@ -137,9 +157,9 @@ func TestSecureServerRunning(t *testing.T) {
 	tmpDirAssets := prepareServerAssets(t)
 	defer os.RemoveAll(tmpDirAssets)
 
-	go func() {
-		Start(conf)
-	}()
+	s, err := New(conf)
+	require.NoError(t, err)
+	go s.Run(context.Background())
 	t.Logf("Started test http server: %v", serverURL)
 
 	httpConfig := httpClientConfig{
@@ -184,6 +204,60 @@ func TestSecureServerRunning(t *testing.T) {
 	}
 }
 
+func TestServerListenRetry(t *testing.T) {
+	held, err := net.Listen("tcp", testHostname+":0")
+	require.NoError(t, err)
+	port := held.Addr().(*net.TCPAddr).Port
+
+	s, err := New(&Config{Address: testHostname, Port: port, ShutdownTimeout: time.Second, ListenRetryTimeout: 5 * time.Second})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- s.Run(ctx)
+	}()
+
+	// the port is bound once released by the previous process
+	time.Sleep(150 * time.Millisecond)
+	require.Nil(t, s.Addr())
+	held.Close()
+
+	select {
+	case <-s.Ready():
+	case err := <-serverErr:
+		t.Fatalf("server stopped: %v", err)
+	}
+	require.Equal(t, port, s.Addr().(*net.TCPAddr).Port)
+
+	cancel()
+	require.NoError(t, <-serverErr)
+}
+
+func TestServerListenError(t *testing.T) {
+	held, err := net.Listen("tcp", testHostname+":0")
+	require.NoError(t, err)
+	defer held.Close()
+
+	s, err := New(&Config{Address: testHostname, Port: held.Addr().(*net.TCPAddr).Port, ListenRetryTimeout: 300 * time.Millisecond})
+	require.NoError(t, err)
+
+	err = s.Run(context.Background())
+	var listenErr *ListenError
+	require.ErrorAs(t, err, &listenErr)
+	require.ErrorIs(t, err, syscall.EADDRINUSE)
+	require.Greater(t, listenErr.Attempts, 1)
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	_, err := New(&Config{Port: 70000})
+	require.Error(t, err)
+
+	_, err = New(&Config{HTTPRedirectPort: 8080})
+	require.EqualError(t, err, "the HTTP redirect port requires TLS to be enabled")
+}
+
 func getFreePort(host string) (int, error) {
 	addr, err := net.ResolveTCPAddr("tcp", host+":0")
 	if err != nil {
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+)
+
+// ServiceAccountAuthConfig sends the queries to Loki with the token of the
+// plugin service account instead of the token of the user, the users are
+// then only authorized by the plugin
+type ServiceAccountAuthConfig struct {
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	// TokenFile is the token sent to Loki, the projected service account
+	// token by default. It is read again when it expires
+	TokenFile string `yaml:"tokenFile,omitempty" json:"tokenFile,omitempty"`
+}
+
+// validate checks that the plugin authorizes every tenant proxied with the
+// service account token, the other tenants would be readable by any user
+func (c ServiceAccountAuthConfig) validate(authorization AuthorizationConfig, proxiedTenants []string) ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if !c.Enabled {
+		return errs
+	}
+	if !authorization.Enabled {
+		errs = append(errs, ConfigValidationError{Field: "serviceAccountAuth.enabled", Message: "the queries sent with the service account token are not authorized by Loki, authorization.enabled is required"})
+		return errs
+	}
+
+	tenants := authorization.Tenants
+	if tenants == nil {
+		tenants = defaultAuthorizationConfig.Tenants
+	}
+	enforced := map[string]bool{}
+	for _, tenant := range tenants {
+		enforced[tenant] = true
+	}
+	for _, tenant := range proxiedTenants {
+		if !enforced[tenant] {
+			errs = append(errs, ConfigValidationError{Field: "authorization.tenants", Message: fmt.Sprintf("tenant %s is queried with the service account token, authorization.tenants must list it", tenant)})
+		}
+	}
+	return errs
+}
+
+// proxiedTenants returns the tenants the plugin queries: the tenants of the
+// rules, of the tenant mapping and of the tenant overrides, sorted
+func (c *PluginConfig) proxiedTenants() []string {
+	mapping := c.TenantMapping
+	if mapping.Rules == nil {
+		mapping.Rules = defaultTenantMappingConfig.Rules
+	}
+	if mapping.Default == "" {
+		mapping.Default = defaultTenantMappingConfig.Default
+	}
+
+	seen := map[string]bool{}
+	for _, tenant := range ruleTenants {
+		seen[tenant] = true
+	}
+	for _, rule := range mapping.Rules {
+		seen[rule.Tenant] = true
+	}
+	seen[mapping.Default] = true
+	for tenant := range c.Tenants {
+		seen[tenant] = true
+	}
+
+	tenants := make([]string, 0, len(seen))
+	for tenant := range seen {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// newServiceAccountToken returns the provider of the token sent to Loki, nil
+// when the user tokens are forwarded
+func newServiceAccountToken(cfg ServiceAccountAuthConfig) (*kube.TokenFile, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tokenFile := cfg.TokenFile
+	if tokenFile == "" {
+		tokenFile = kube.ServiceAccountTokenFile
+	}
+
+	token := kube.NewTokenFile(tokenFile)
+	if _, err := token.Token(); err != nil {
+		return nil, err
+	}
+
+	slog.Infof("querying Loki with the service account token %s", tokenFile)
+	return token, nil
+}
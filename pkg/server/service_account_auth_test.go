@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/datasource"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceAccountAuth(t *testing.T) {
+	authorizations := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations <- r.Header.Get("Authorization")
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer upstream.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("sa-token\n"), 0600))
+
+	token, err := newServiceAccountToken(ServiceAccountAuthConfig{Enabled: true, TokenFile: tokenFile})
+	require.NoError(t, err)
+
+	pluginConfig, err := parsePluginConfig([]byte("lokiURL: " + upstream.URL))
+	require.NoError(t, err)
+	ds, _ := pluginConfig.defaultDatasource()
+
+	r := httptest.NewRequest(http.MethodGet, "/application/loki/api/v1/labels", nil)
+	r.Header.Set("Authorization", "Bearer user-token")
+	w := httptest.NewRecorder()
+	datasource.QueryHandler(newDatasource(ds, pluginConfig, routeDeps{serviceAccountToken: token}), writeProxyError).ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "Bearer sa-token", <-authorizations)
+}
+
+func TestServiceAccountAuthDisabled(t *testing.T) {
+	token, err := newServiceAccountToken(ServiceAccountAuthConfig{})
+	require.NoError(t, err)
+	require.Nil(t, token)
+
+	_, err = newServiceAccountToken(ServiceAccountAuthConfig{Enabled: true, TokenFile: filepath.Join(t.TempDir(), "token")})
+	require.Error(t, err)
+}
+
+func TestServiceAccountAuthRequiresAuthorization(t *testing.T) {
+	_, err := parsePluginConfig([]byte("serviceAccountAuth:\n  enabled: true"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "serviceAccountAuth.enabled", Message: "the queries sent with the service account token are not authorized by Loki, authorization.enabled is required"},
+	}, err)
+
+	// every proxied tenant must be authorized by the plugin
+	_, err = parsePluginConfig([]byte("serviceAccountAuth:\n  enabled: true\nauthorization:\n  enabled: true"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "authorization.tenants", Message: "tenant audit is queried with the service account token, authorization.tenants must list it"},
+		{Field: "authorization.tenants", Message: "tenant infrastructure is queried with the service account token, authorization.tenants must list it"},
+	}, err)
+
+	_, err = parsePluginConfig([]byte(`
+serviceAccountAuth:
+  enabled: true
+authorization:
+  enabled: true
+  tenants: [application, infrastructure, audit]
+tenantMapping:
+  default: team-a
+`))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "authorization.tenants", Message: "tenant team-a is queried with the service account token, authorization.tenants must list it"},
+	}, err)
+
+	_, err = parsePluginConfig([]byte("serviceAccountAuth:\n  enabled: true\nauthorization:\n  enabled: true\n  tenants: [application, infrastructure, audit]"))
+	require.NoError(t, err)
+}
+
+func TestServiceAccountAuthRefusesNotEnforcedTenants(t *testing.T) {
+	handler := authorizationMiddleware(nil, []string{"application"}, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	for _, path := range []string{"/infrastructure/loki/api/v1/query_range", "/audit", "/custom/loki/api/v1/labels"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusForbidden, w.Code, path)
+	}
+}
+
+func TestServiceAccountAuthRules(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("sa-token\n"), 0600))
+	token, err := newServiceAccountToken(ServiceAccountAuthConfig{Enabled: true, TokenFile: tokenFile})
+	require.NoError(t, err)
+
+	pluginConfig, err := parsePluginConfig([]byte("lokiURL: http://127.0.0.1:1"))
+	require.NoError(t, err)
+	ds, _ := pluginConfig.defaultDatasource()
+
+	w := httptest.NewRecorder()
+	rulesHandler(ds, pluginConfig, routeDeps{serviceAccountToken: token}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rules?tenant=infrastructure", nil))
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+}
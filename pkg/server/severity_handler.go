@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// severityLevels are the values of the level label counted by the severity
+// counts, like the abbreviations of web/src/severity.ts. The critical levels
+// count as errors
+var severityLevels = map[string]string{
+	"critical": "error", "emerg": "error", "fatal": "error", "alert": "error", "crit": "error",
+	"error": "error", "err": "error", "eror": "error",
+	"warning": "warning", "warn": "warning",
+	"info": "info", "inf": "info", "information": "info", "notice": "info",
+}
+
+// severityResponse are the counts of the namespaces, the times are in Unix
+// milliseconds
+type severityResponse struct {
+	Start      int64            `json:"start"`
+	End        int64            `json:"end"`
+	Namespaces []severityCounts `json:"namespaces"`
+}
+
+// severityCounts are the error, warning and info entries of a namespace
+type severityCounts struct {
+	Namespace string `json:"namespace"`
+	Error     int64  `json:"error"`
+	Warning   int64  `json:"warning"`
+	Info      int64  `json:"info"`
+}
+
+// namespacesSelector returns the stream selector of namespaces, every
+// namespace when there is none
+func namespacesSelector(namespaces []string) string {
+	if len(namespaces) == 0 {
+		return fmt.Sprintf(`{%s=~".+"}`, namespaceLabel)
+	}
+	return fmt.Sprintf(`{%s=~"%s"}`, namespaceLabel, strings.Join(namespaces, "|"))
+}
+
+// namespacesSelectorMiddleware sets the query parameter to the stream
+// selector of the namespace parameters, so that the tenant mapping,
+// authorization and guardrails of the queries check the namespaces of the
+// severity counts and of the suggestions
+func namespacesSelectorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		for _, namespace := range params["namespace"] {
+			if !namespaceRegexp.MatchString(namespace) {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid namespace %q", namespace), nil)
+				return
+			}
+		}
+
+		params.Set("query", namespacesSelector(params["namespace"]))
+		r = r.Clone(r.Context())
+		r.URL.RawQuery = params.Encode()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// severityHandler counts the error, warning and info entries of each
+// namespace parameter, or of every namespace, against the tenant of the
+// `/<tenant>` path with a count_over_time query over the range. The range is
+// the one of the exports, the last hour by default. The namespaces are sorted
+// by name, the requested ones without entries count 0
+func severityHandler(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps) http.Handler {
+	proxyConfig, err := lokiProxyConfig(ds, pluginConfig, deps)
+	if err != nil {
+		return unavailableDatasourceHandler(ds, err)
+	}
+	client := proxy.NewClient(proxyConfig)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := strings.Trim(r.URL.Path, "/")
+		if !tenantRegexp.MatchString(tenant) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid tenant %q", tenant), nil)
+			return
+		}
+
+		params := r.URL.Query()
+		namespaces := params["namespace"]
+		for _, namespace := range namespaces {
+			if !namespaceRegexp.MatchString(namespace) {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid namespace %q", namespace), nil)
+				return
+			}
+		}
+
+		rng, err := exportRange(params.Get("start"), params.Get("end"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, err.Error(), nil)
+			return
+		}
+		window := int64(rng.End.Sub(rng.Start).Seconds())
+		if window < 1 {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "the range must last at least a second", nil)
+			return
+		}
+
+		query := fmt.Sprintf("sum by (%s, %s) (count_over_time(%s [%ds]))", namespaceLabel, defaultVolumeGroupBy, namespacesSelector(namespaces), window)
+		samples, err := client.Vector(r, tenant, query, rng.End)
+		if err != nil {
+			var proxyErr *proxy.Error
+			if !errors.As(err, &proxyErr) {
+				proxyErr = &proxy.Error{Status: http.StatusBadGateway, Code: errorCodeUpstreamUnavailable, Message: "cannot count the logs", Err: err}
+			}
+			writeProxyError(w, r, proxyErr)
+			return
+		}
+
+		counts := map[string]*severityCounts{}
+		for _, namespace := range namespaces {
+			counts[namespace] = &severityCounts{Namespace: namespace}
+		}
+		for _, sample := range samples {
+			namespace := sample.Labels[namespaceLabel]
+			if namespace == "" {
+				continue
+			}
+			c, ok := counts[namespace]
+			if !ok {
+				c = &severityCounts{Namespace: namespace}
+				counts[namespace] = c
+			}
+			switch severityLevels[strings.ToLower(sample.Labels[defaultVolumeGroupBy])] {
+			case "error":
+				c.Error += int64(sample.Value)
+			case "warning":
+				c.Warning += int64(sample.Value)
+			case "info":
+				c.Info += int64(sample.Value)
+			}
+		}
+
+		response := severityResponse{Start: rng.Start.UnixMilli(), End: rng.End.UnixMilli(), Namespaces: make([]severityCounts, 0, len(counts))}
+		for _, c := range counts {
+			response.Namespaces = append(response.Namespaces, *c)
+		}
+		sort.Slice(response.Namespaces, func(i, j int) bool {
+			return response.Namespaces[i].Namespace < response.Namespaces[j].Namespace
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeverityHandler(t *testing.T) {
+	queries := make(chan url.Values, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries <- r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+{"metric":{"kubernetes_namespace_name":"api","level":"error"},"value":[1700003580,"2"]},
+{"metric":{"kubernetes_namespace_name":"api","level":"crit"},"value":[1700003580,"1"]},
+{"metric":{"kubernetes_namespace_name":"api","level":"warn"},"value":[1700003580,"5"]},
+{"metric":{"kubernetes_namespace_name":"api","level":"INFO"},"value":[1700003580,"120"]},
+{"metric":{"kubernetes_namespace_name":"api","level":"debug"},"value":[1700003580,"40"]}]}}`))
+	}))
+	defer upstream.Close()
+
+	pluginConfig, err := parsePluginConfig([]byte(fmt.Sprintf("lokiURL: %s", upstream.URL)))
+	require.NoError(t, err)
+	ds, _ := pluginConfig.defaultDatasource()
+	handler := namespacesSelectorMiddleware(severityHandler(ds, pluginConfig, routeDeps{}))
+
+	params := url.Values{"namespace": {"web", "api"}, "start": {"1699999980"}, "end": {"1700003580"}}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application?"+params.Encode(), nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	upstreamParams := <-queries
+	require.Equal(t, `sum by (kubernetes_namespace_name, level) (count_over_time({kubernetes_namespace_name=~"web|api"} [3600s]))`, upstreamParams.Get("query"))
+	require.Equal(t, "1700003580000000000", upstreamParams.Get("time"))
+
+	response := severityResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, int64(1699999980000), response.Start)
+	require.Equal(t, []severityCounts{
+		{Namespace: "api", Error: 3, Warning: 5, Info: 120},
+		{Namespace: "web"},
+	}, response.Namespaces)
+
+	for _, params := range []url.Values{
+		{"namespace": {"Web"}},
+		{"start": {"1700000000"}, "end": {"1700000000"}},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application?"+params.Encode(), nil))
+		require.Equal(t, http.StatusBadRequest, w.Code, params.Encode())
+	}
+}
+
+func TestNamespacesSelector(t *testing.T) {
+	require.Equal(t, `{kubernetes_namespace_name=~".+"}`, namespacesSelector(nil))
+	require.Equal(t, `{kubernetes_namespace_name=~"api"}`, namespacesSelector([]string{"api"}))
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// ShadowConfig mirrors a share of the queries of a Loki datasource to a
+// target datasource, like the LokiStack a migration moves to. The responses
+// of the target are discarded once compared with the responses of the
+// datasource, the differences are logged and counted
+type ShadowConfig struct {
+	// Datasource is the name of the datasource whose queries are mirrored
+	Datasource string `yaml:"datasource,omitempty" json:"datasource,omitempty"`
+	// Target is the name of the datasource receiving the mirrored queries
+	Target string `yaml:"target,omitempty" json:"target,omitempty"`
+	// Percentage of the queries mirrored, from 0 to 100
+	Percentage float64 `yaml:"percentage,omitempty" json:"percentage,omitempty"`
+	// Timeout bounds each mirrored query
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// MaxInFlight bounds the mirrored queries at the same time, the queries
+	// are not mirrored beyond
+	MaxInFlight int `yaml:"maxInFlight,omitempty" json:"maxInFlight,omitempty"`
+}
+
+var defaultShadowConfig = ShadowConfig{
+	Timeout:     30 * time.Second,
+	MaxInFlight: 10,
+}
+
+func (c *PluginConfig) validateShadow() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+
+	datasources := map[string]DatasourceConfig{}
+	for _, ds := range c.allDatasources() {
+		datasources[ds.Name] = ds
+	}
+
+	mirrored := map[string]bool{}
+	for i, shadow := range c.Shadow {
+		field := fmt.Sprintf("shadow[%d]", i)
+		for _, ref := range []struct{ name, value string }{{"datasource", shadow.Datasource}, {"target", shadow.Target}} {
+			ds, found := datasources[ref.value]
+			switch {
+			case ref.value == "":
+				errs = append(errs, ConfigValidationError{Field: field + "." + ref.name, Message: ref.name + " is required"})
+			case !found:
+				errs = append(errs, ConfigValidationError{Field: field + "." + ref.name, Message: fmt.Sprintf("unknown datasource %q", ref.value)})
+			case !ds.isLoki():
+				errs = append(errs, ConfigValidationError{Field: field + "." + ref.name, Message: fmt.Sprintf("datasource %q is not a Loki datasource", ref.value)})
+			}
+		}
+		if shadow.Datasource != "" && shadow.Datasource == shadow.Target {
+			errs = append(errs, ConfigValidationError{Field: field + ".target", Message: "target must differ from datasource"})
+		}
+		if mirrored[shadow.Datasource] {
+			errs = append(errs, ConfigValidationError{Field: field + ".datasource", Message: fmt.Sprintf("datasource %q is already mirrored", shadow.Datasource)})
+		}
+		mirrored[shadow.Datasource] = true
+
+		if shadow.Percentage <= 0 || shadow.Percentage > 100 {
+			errs = append(errs, ConfigValidationError{Field: field + ".percentage", Message: "percentage must be greater than 0 and at most 100"})
+		}
+		if shadow.Timeout < 0 || shadow.Timeout > maxTimeout {
+			errs = append(errs, ConfigValidationError{Field: field + ".timeout", Message: fmt.Sprintf("timeout must be between 0 and %s", maxTimeout)})
+		}
+		if shadow.MaxInFlight < 0 {
+			errs = append(errs, ConfigValidationError{Field: field + ".maxInFlight", Message: "maxInFlight cannot be negative"})
+		}
+	}
+	return errs
+}
+
+// newDatasourceShadows returns the shadows of the mirrored datasources by
+// name
+func newDatasourceShadows(pluginConfig *PluginConfig) (map[string]*proxy.Shadow, error) {
+	datasources := map[string]DatasourceConfig{}
+	for _, ds := range pluginConfig.allDatasources() {
+		datasources[ds.Name] = ds
+	}
+
+	shadows := map[string]*proxy.Shadow{}
+	for _, shadow := range pluginConfig.Shadow {
+		// the datasources are validated when the plugin config is parsed
+		target := datasources[shadow.Target]
+		targetURL, _ := url.Parse(target.URL)
+
+		transport, err := datasourceTransport(target, pluginConfig.ProxyURL, pluginConfig.Upstream.Connections)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create the transport of the shadow datasource %s: %w", target.Name, err)
+		}
+
+		shadows[shadow.Datasource] = proxy.NewShadow(proxy.ShadowConfig{
+			Name:              target.Name,
+			URL:               targetURL,
+			UseTenantInHeader: target.UseTenantInHeader,
+			TenantRouting:     target.TenantRoutingMode,
+			Transport:         transport,
+			Percentage:        shadow.Percentage,
+			Timeout:           shadow.Timeout,
+			MaxInFlight:       shadow.MaxInFlight,
+		})
+	}
+	return shadows, nil
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowConfig(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(`
+datasources:
+  - name: current
+    url: https://lokistack-current:8080
+  - name: next
+    url: https://lokistack-next:8080
+    tenantRoutingMode: header
+shadow:
+  - datasource: current
+    target: next
+    percentage: 5
+`))
+	require.NoError(t, err)
+	require.Equal(t, []ShadowConfig{{Datasource: "current", Target: "next", Percentage: 5, Timeout: 30 * time.Second, MaxInFlight: 10}}, pluginConfig.Shadow)
+
+	shadows, err := newDatasourceShadows(pluginConfig)
+	require.NoError(t, err)
+	require.Len(t, shadows, 1)
+	require.NotNil(t, shadows["current"])
+
+	_, err = parsePluginConfig([]byte(`
+datasources:
+  - name: current
+    url: https://lokistack-current:8080
+  - name: es
+    type: elasticsearch
+    url: https://elasticsearch:9200
+shadow:
+  - datasource: current
+    target: current
+    percentage: 0
+  - datasource: current
+    target: es
+    percentage: 150
+    timeout: -1s
+  - target: missing
+    percentage: 10
+    maxInFlight: -1
+`))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "shadow[0].target", Message: "target must differ from datasource"},
+		{Field: "shadow[0].percentage", Message: "percentage must be greater than 0 and at most 100"},
+		{Field: "shadow[1].target", Message: `datasource "es" is not a Loki datasource`},
+		{Field: "shadow[1].datasource", Message: `datasource "current" is already mirrored`},
+		{Field: "shadow[1].percentage", Message: "percentage must be greater than 0 and at most 100"},
+		{Field: "shadow[1].timeout", Message: "timeout must be between 0 and 10m0s"},
+		{Field: "shadow[2].datasource", Message: "datasource is required"},
+		{Field: "shadow[2].target", Message: `unknown datasource "missing"`},
+		{Field: "shadow[2].maxInFlight", Message: "maxInFlight cannot be negative"},
+	}, err)
+}
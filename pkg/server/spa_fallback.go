@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// defaultSPAFallbackFile is the file served for the unknown frontend routes
+const defaultSPAFallbackFile = "index.html"
+
+var (
+	// spaFallbackExcludedPaths are the path prefixes of the backend routes,
+	// their unknown paths are never served with the fallback file
+	spaFallbackExcludedPaths = []string{"/api/", "/health", "/readyz", "/metrics", "/version", "/debug/"}
+	// spaAssetExtensions are the extensions of the static assets, a missing
+	// asset is a 404 rather than the fallback file
+	spaAssetExtensions = map[string]bool{
+		".js": true, ".mjs": true, ".css": true, ".map": true, ".json": true,
+		".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".ico": true,
+		".woff": true, ".woff2": true, ".ttf": true, ".eot": true, ".txt": true, ".wasm": true,
+		".br": true, ".gz": true,
+	}
+)
+
+// ParseSPAFallbackPrefixes parses a comma separated list of path prefixes
+// served with the fallback file when no file matches, e.g. `/logs,/k8s`
+func ParseSPAFallbackPrefixes(value string) ([]string, error) {
+	prefixes := []string{}
+
+	for _, prefix := range strings.Split(value, ",") {
+		prefix = strings.TrimSpace(prefix)
+		if prefix == "" {
+			continue
+		}
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid SPA fallback prefix %q, it must start with /", prefix)
+		}
+		if prefix != "/" {
+			prefix = strings.TrimSuffix(prefix, "/")
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+// withSPAFallback serves file for the unknown paths under prefixes, the
+// frontend routes deep-linked by the console then load the UI
+func (h *filesHandler) withSPAFallback(prefixes []string, file string) *filesHandler {
+	if file == "" {
+		file = defaultSPAFallbackFile
+	}
+	h.fallbackPrefixes = prefixes
+	h.fallbackFile = path.Clean("/" + file)
+	return h
+}
+
+// spaFallback tells whether r is answered with the fallback file: a GET of a
+// missing file under a fallback prefix, other than a backend route or an asset
+func (h *filesHandler) spaFallback(r *http.Request) bool {
+	if len(h.fallbackPrefixes) == 0 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+
+	urlPath := path.Clean("/" + r.URL.Path)
+	for _, excluded := range spaFallbackExcludedPaths {
+		if strings.HasPrefix(urlPath+"/", excluded) {
+			return false
+		}
+	}
+	if spaAssetExtensions[strings.ToLower(path.Ext(urlPath))] {
+		return false
+	}
+
+	matched := false
+	for _, prefix := range h.fallbackPrefixes {
+		if prefix == "/" || urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+			matched = true
+			break
+		}
+	}
+	return matched && h.missing(urlPath)
+}
+
+// serveSPAFallback serves the fallback file, it is revalidated by the
+// clients as it references the current assets
+func (h *filesHandler) serveSPAFallback(w http.ResponseWriter, r *http.Request) {
+	file, err := h.root.Open(h.fallbackFile)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, h.fallbackFile, info.ModTime(), file)
+}
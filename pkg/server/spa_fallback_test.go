@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSPAFallbackPrefixes(t *testing.T) {
+	prefixes, err := ParseSPAFallbackPrefixes(" /logs/, /k8s ,, /")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/logs", "/k8s", "/"}, prefixes)
+
+	_, err = ParseSPAFallbackPrefixes("logs")
+	require.Error(t, err)
+}
+
+func TestFilesHandlerSPAFallback(t *testing.T) {
+	staticDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "index.html"), []byte("index"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "plugin-entry.js"), []byte("entry"), 0600))
+
+	pluginConfig, err := newReloadingPluginConfig("")
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{StaticPath: staticDir, SPAFallbackPrefixes: []string{"/logs", "/k8s"}}, pluginConfig, routeDeps{})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "frontend route", path: "/logs/application", expectedStatus: http.StatusOK, expectedBody: "index"},
+		{name: "prefix", path: "/k8s", expectedStatus: http.StatusOK, expectedBody: "index"},
+		{name: "dotted resource name", path: "/k8s/ns/default/pods/web.1", expectedStatus: http.StatusOK, expectedBody: "index"},
+		{name: "existing file", path: "/plugin-entry.js", expectedStatus: http.StatusOK, expectedBody: "entry"},
+		{name: "missing asset", path: "/logs/chunk.js", expectedStatus: http.StatusNotFound},
+		{name: "outside prefixes", path: "/alerts/1", expectedStatus: http.StatusNotFound},
+		{name: "prefix lookalike", path: "/logsx", expectedStatus: http.StatusNotFound},
+		{name: "post", method: http.MethodPost, path: "/logs/application", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(method, tt.path, nil))
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				require.Equal(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestFilesHandlerSPAFallbackExcludedPaths(t *testing.T) {
+	staticDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "index.html"), []byte("index"), 0600))
+
+	handler := newFilesHandler(staticDir, "", "").withSPAFallback([]string{"/"}, "")
+
+	for _, path := range []string{"/api/unknown", "/healthz", "/metrics/extra", "/debug/vars"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+}
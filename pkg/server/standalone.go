@@ -0,0 +1,151 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+const (
+	// consoleProxyPrefix is the path of the backend behind the console proxy,
+	// the frontend sends its requests there
+	consoleProxyPrefix = "/api/proxy/plugin/logging-view-plugin/backend"
+	// pluginBackendProxyPrefix is the path of the console proxy alias of the
+	// plugin backend routes, like the tail server-sent events
+	pluginBackendProxyPrefix = "/api/proxy/plugin/logging-view-plugin/plugin-backend"
+	// standaloneTokenCookie holds the bearer token of the standalone user
+	standaloneTokenCookie = "logging-view-plugin-token"
+	standaloneLoginPath   = "/login"
+	standaloneLogoutPath  = "/logout"
+	// maxLoginFormSize bounds the login form, a token is a few KB at most
+	maxLoginFormSize = 64 << 10
+)
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <title>Logging View - Log in</title>
+</head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 4em auto">
+  <h1>Logging View</h1>
+  <p>Log in with a bearer token of the cluster, like the output of <code>oc whoami -t</code>
+  or a token from the OAuth server <code>/oauth/token/request</code> page.</p>
+  {{if .}}<p role="alert" style="color: #c9190b">{{.}}</p>{{end}}
+  <form method="post" action="/login">
+    <label for="token">Token</label><br>
+    <input id="token" name="token" type="password" autocomplete="off" required style="width: 100%"><br><br>
+    <button type="submit">Log in</button>
+  </form>
+</body>
+</html>
+`))
+
+// standaloneMiddleware serves the frontend outside of the console: the
+// requests sent to the console proxy paths are routed to the backend, and the
+// token of the login cookie is sent as bearer token. The pages, not the
+// backend requests, are redirected to the login form while there is no token
+func standaloneMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied := false
+		for _, prefix := range []string{consoleProxyPrefix, pluginBackendProxyPrefix} {
+			if strings.HasPrefix(r.URL.Path, prefix+"/") {
+				proxied = true
+				r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+				r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+				break
+			}
+		}
+
+		cookie, err := r.Cookie(standaloneTokenCookie)
+		hasToken := err == nil && cookie.Value != ""
+		if hasToken && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+cookie.Value)
+		}
+
+		if !hasToken && r.Header.Get("Authorization") == "" && !proxied && isStandalonePage(r) {
+			http.Redirect(w, r, standaloneLoginPath, http.StatusFound)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isStandalonePage tells whether r loads a page of the frontend
+func isStandalonePage(r *http.Request) bool {
+	if r.Method != http.MethodGet || r.URL.Path == standaloneLoginPath || r.URL.Path == standaloneLogoutPath {
+		return false
+	}
+	for _, excluded := range spaFallbackExcludedPaths {
+		if strings.HasPrefix(r.URL.Path+"/", excluded) {
+			return false
+		}
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// standaloneLoginHandler renders the login form and stores the reviewed
+// token in an HTTP only cookie
+func standaloneLoginHandler(authenticator *tokenAuthenticator) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method != http.MethodPost {
+			renderLoginPage(w, http.StatusOK, "")
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxLoginFormSize)
+		token := strings.TrimSpace(r.PostFormValue("token"))
+		if token == "" {
+			renderLoginPage(w, http.StatusBadRequest, "A token is required.")
+			return
+		}
+
+		status, err := authenticator.authenticate(r.Context(), token)
+		if err != nil {
+			requestLog(slog, r).WithError(err).Error("cannot review token")
+			renderLoginPage(w, http.StatusServiceUnavailable, "The token cannot be checked, try again later.")
+			return
+		}
+		if !status.Authenticated {
+			renderLoginPage(w, http.StatusUnauthorized, "The token is invalid or expired.")
+			return
+		}
+
+		requestLog(slog, r).Infof("user %s logged in", status.User.Username)
+		http.SetCookie(w, &http.Cookie{
+			Name:     standaloneTokenCookie,
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+}
+
+// standaloneLogoutHandler removes the login cookie
+func standaloneLogoutHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     standaloneTokenCookie,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(w, r, standaloneLoginPath, http.StatusSeeOther)
+	})
+}
+
+func renderLoginPage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := loginPage.Execute(w, message); err != nil {
+		slog.WithError(err).Error("cannot render login page")
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandaloneLogin(t *testing.T) {
+	staticDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "index.html"), []byte("shell"), 0600))
+
+	pluginConfig, err := newReloadingPluginConfig("")
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{StaticPath: staticDir, Standalone: true}, pluginConfig, routeDeps{
+		authenticator: newTokenAuthenticator(&fakeTokenReviewer{}),
+	})
+	handler := standaloneMiddleware(router)
+
+	send := func(method string, target string, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Accept", "text/html")
+		if body != "" {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// the pages redirect to the login form without a token
+	w := send(http.MethodGet, "/logs/application", "", nil)
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Equal(t, standaloneLoginPath, w.Header().Get("Location"))
+
+	w = send(http.MethodGet, standaloneLoginPath, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `<form method="post" action="/login">`)
+
+	w = send(http.MethodPost, standaloneLoginPath, url.Values{"token": {"invalid"}}.Encode(), nil)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Empty(t, w.Result().Cookies())
+
+	w = send(http.MethodPost, standaloneLoginPath, url.Values{"token": {"valid"}}.Encode(), nil)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, standaloneTokenCookie, cookies[0].Name)
+	require.True(t, cookies[0].HttpOnly)
+	require.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+
+	// the shell is served for the frontend routes once logged in
+	w = send(http.MethodGet, "/logs/application", "", cookies[0])
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "shell", w.Body.String())
+
+	// the console proxy path is routed to the backend with the token
+	w = send(http.MethodGet, consoleProxyPrefix+"/config", "", nil)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = send(http.MethodGet, consoleProxyPrefix+"/config", "", cookies[0])
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = send(http.MethodPost, standaloneLogoutPath, "", cookies[0])
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	require.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+}
+
+func TestStandaloneMiddlewareAPI(t *testing.T) {
+	handler := standaloneMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("Authorization")))
+	}))
+
+	// the API requests are not redirected, they get the authentication error
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, consoleProxyPrefix+"/config", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "/config ", w.Body.String())
+
+	// so are the requests to the plugin backend alias
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, pluginBackendProxyPrefix+"/api/tail/sse/application", nil))
+	require.Equal(t, "/api/tail/sse/application ", w.Body.String())
+
+	// a bearer token sent by the client is kept
+	r := httptest.NewRequest(http.MethodGet, "/config", nil)
+	r.Header.Set("Authorization", "Bearer client")
+	r.AddCookie(&http.Cookie{Name: standaloneTokenCookie, Value: "cookie"})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, "/config Bearer client", w.Body.String())
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// staticDirs splits a static path into its directories by priority, the
+// directories are separated by colons like in $PATH. An empty static path is
+// the working directory
+func staticDirs(staticPath string) []string {
+	dirs := []string{}
+	for _, dir := range filepath.SplitList(staticPath) {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return []string{"."}
+	}
+	return dirs
+}
+
+// newStaticFileSystem serves the files of dirs, a file of a directory
+// overrides the same file of the next ones
+func newStaticFileSystem(dirs []string) http.FileSystem {
+	if len(dirs) == 1 {
+		return http.Dir(dirs[0])
+	}
+	layers := make(overlayFileSystem, 0, len(dirs))
+	for _, dir := range dirs {
+		layers = append(layers, http.Dir(dir))
+	}
+	return layers
+}
+
+// staticFileSystem serves the static files of the config: the directories of
+// StaticPath, or StaticFS, the frontend embedded in the binary, when no
+// StaticPath is set
+func staticFileSystem(cfg *Config) http.FileSystem {
+	if cfg.StaticPath == "" && cfg.StaticFS != nil {
+		return http.FS(cfg.StaticFS)
+	}
+	return newStaticFileSystem(staticDirs(cfg.StaticPath))
+}
+
+// overlayFileSystem is a composite http.FileSystem opening the files from the
+// first layer having them, the directories list the files of every layer so
+// that an override directory only holds the overridden files, like branded
+// logos and strings laid over the upstream bundle
+type overlayFileSystem []http.FileSystem
+
+func (layers overlayFileSystem) Open(name string) (http.File, error) {
+	var dir http.File
+	var dirLayers []http.FileSystem
+
+	for i, layer := range layers {
+		file, err := layer.Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		// a file hides the next layers, a directory is merged with the
+		// directories of the next layers
+		if !info.IsDir() {
+			if dir != nil {
+				file.Close()
+				continue
+			}
+			return file, nil
+		}
+		if dir == nil {
+			dir = file
+			dirLayers = layers[i+1:]
+		} else {
+			file.Close()
+		}
+	}
+
+	if dir == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &overlayDir{File: dir, name: name, layers: dirLayers}, nil
+}
+
+// overlayDir is a directory of the first layer listing the files of the same
+// directory in the next layers
+type overlayDir struct {
+	http.File
+	name    string
+	layers  []http.FileSystem
+	entries []fs.FileInfo
+	read    bool
+}
+
+func (d *overlayDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.read {
+		d.read = true
+		entries, err := d.merge()
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+// merge lists the entries of the directory in every layer, an entry of a
+// layer hides the entries with the same name of the next ones
+func (d *overlayDir) merge() ([]fs.FileInfo, error) {
+	entries, err := d.File.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		seen[entry.Name()] = true
+	}
+
+	for _, layer := range d.layers {
+		file, err := layer.Open(d.name)
+		if err != nil {
+			continue
+		}
+		layerEntries, err := file.Readdir(-1)
+		file.Close()
+		if err != nil {
+			continue
+		}
+		for _, entry := range layerEntries {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
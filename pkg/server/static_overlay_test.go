@@ -0,0 +1,105 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticPathOverlay(t *testing.T) {
+	brandingDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(brandingDir, "images"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(brandingDir, "images", "logo.svg"), []byte("branded"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(brandingDir, "locales", "en"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(brandingDir, "locales", "en", "plugin__logging-view-plugin.json"), []byte(`{"Logs":"Branded logs"}`), 0600))
+
+	upstreamDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(upstreamDir, "images"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "images", "logo.svg"), []byte("upstream"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "images", "icon.svg"), []byte("icon"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "plugin-entry.js"), []byte("entry"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(upstreamDir, "locales", "en"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "locales", "en", "plugin__logging-view-plugin.json"), []byte(`{"Logs":"Logs"}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "locales", "en", "extra.json"), []byte(`{}`), 0600))
+
+	staticPath := brandingDir + string(filepath.ListSeparator) + upstreamDir
+	require.Equal(t, []string{brandingDir, upstreamDir}, staticDirs(staticPath))
+
+	pluginConfig, err := newReloadingPluginConfig("")
+	require.NoError(t, err)
+	router := setupRoutes(&Config{StaticPath: staticPath}, pluginConfig, routeDeps{})
+
+	tests := []struct {
+		path         string
+		expectedBody string
+	}{
+		{path: "/images/logo.svg", expectedBody: "branded"},
+		{path: "/images/icon.svg", expectedBody: "icon"},
+		{path: "/plugin-entry.js", expectedBody: "entry"},
+		{path: "/locales/en/plugin__logging-view-plugin.json", expectedBody: `{"Logs":"Branded logs"}`},
+		{path: "/locales/en/extra.json", expectedBody: `{}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/images/missing.svg", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// the directories list the files of every layer
+	dir, err := newStaticFileSystem(staticDirs(staticPath)).Open("/images")
+	require.NoError(t, err)
+	defer dir.Close()
+	first, err := dir.Readdir(1)
+	require.NoError(t, err)
+	require.Equal(t, "icon.svg", first[0].Name())
+	rest, err := dir.Readdir(5)
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	require.Equal(t, "logo.svg", rest[0].Name())
+	_, err = dir.Readdir(1)
+	require.ErrorIs(t, err, io.EOF)
+
+	require.Equal(t, []string{"extra", "plugin__logging-view-plugin"}, i18nNamespaces(newStaticFileSystem(staticDirs(staticPath))))
+}
+
+func TestStaticFSEmbedded(t *testing.T) {
+	embedded := fstest.MapFS{
+		"plugin-entry.js": {Data: []byte("embedded")},
+		"locales/en/plugin__logging-view-plugin.json": {Data: []byte(`{"Logs":"Logs"}`)},
+	}
+	pluginConfig, err := newReloadingPluginConfig("")
+	require.NoError(t, err)
+
+	router := setupRoutes(&Config{StaticFS: embedded}, pluginConfig, routeDeps{})
+	for path, expectedBody := range map[string]string{
+		"/plugin-entry.js": "embedded",
+		"/locales/en/plugin__logging-view-plugin.json": `{"Logs":"Logs"}`,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code, path)
+		require.Equal(t, expectedBody, w.Body.String(), path)
+	}
+	require.Equal(t, []string{"plugin__logging-view-plugin"}, i18nNamespaces(staticFileSystem(&Config{StaticFS: embedded})))
+
+	// the static path overrides the embedded frontend
+	staticDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(staticDir, "plugin-entry.js"), []byte("static path"), 0600))
+	router = setupRoutes(&Config{StaticPath: staticDir, StaticFS: embedded}, pluginConfig, routeDeps{})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plugin-entry.js", nil))
+	require.Equal(t, "static path", w.Body.String())
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StaticRoot is an additional directory of static files served under Prefix
+type StaticRoot struct {
+	Prefix string
+	Path   string
+	// MaxAge is the cache lifetime of the served files, a zero value disables
+	// caching and a negative value leaves the Cache-Control header unset
+	MaxAge time.Duration
+}
+
+// ParseStaticRoots parses a comma separated list of static roots with the
+// form `<prefix>=<path>[:<max-age>]`, e.g. `/docs=/srv/docs:1h`. The suffix
+// after the last colon of the path is the max-age when it is a duration
+func ParseStaticRoots(value string) ([]StaticRoot, error) {
+	roots := []StaticRoot{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		prefix, path, found := strings.Cut(entry, "=")
+		if !found || prefix == "" || path == "" {
+			return nil, fmt.Errorf("invalid static root %q, expected <prefix>=<path>[:<max-age>]", entry)
+		}
+
+		if !strings.HasPrefix(prefix, "/") || prefix == "/" {
+			return nil, fmt.Errorf("invalid static root prefix %q, it must start with / and not be the root path", prefix)
+		}
+
+		root := StaticRoot{
+			Prefix: strings.TrimSuffix(prefix, "/"),
+			Path:   path,
+			MaxAge: -1,
+		}
+
+		// the paths can contain colons, only a duration after the last one
+		// is a max-age
+		if i := strings.LastIndex(path, ":"); i > 0 {
+			if duration, err := time.ParseDuration(path[i+1:]); err == nil {
+				root.Path = path[:i]
+				root.MaxAge = duration
+			}
+		}
+
+		roots = append(roots, root)
+	}
+
+	return roots, nil
+}
+
+func staticRootHandler(root StaticRoot) *filesHandler {
+	cacheControl := ""
+	switch {
+	case root.MaxAge == 0:
+		cacheControl = "no-cache"
+	case root.MaxAge > 0:
+		cacheControl = fmt.Sprintf("public, max-age=%d", int(root.MaxAge.Seconds()))
+	}
+
+	return newFilesHandler(root.Path, root.Prefix, cacheControl)
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStaticRoots(t *testing.T) {
+	roots, err := ParseStaticRoots("/docs=/srv/docs:1h, /vendor-assets/=/srv/vendor")
+	require.NoError(t, err)
+	require.Equal(t, []StaticRoot{
+		{Prefix: "/docs", Path: "/srv/docs", MaxAge: time.Hour},
+		{Prefix: "/vendor-assets", Path: "/srv/vendor", MaxAge: -1},
+	}, roots)
+
+	// the colons of the paths are kept unless followed by a duration
+	roots, err = ParseStaticRoots("/docs=/srv/docs:v2,/assets=/srv/assets:v1:10m,/files=C:/files")
+	require.NoError(t, err)
+	require.Equal(t, []StaticRoot{
+		{Prefix: "/docs", Path: "/srv/docs:v2", MaxAge: -1},
+		{Prefix: "/assets", Path: "/srv/assets:v1", MaxAge: 10 * time.Minute},
+		{Prefix: "/files", Path: "C:/files", MaxAge: -1},
+	}, roots)
+
+	roots, err = ParseStaticRoots("")
+	require.NoError(t, err)
+	require.Empty(t, roots)
+
+	for _, invalid := range []string{"/docs", "docs=/srv/docs", "/=/srv"} {
+		_, err = ParseStaticRoots(invalid)
+		require.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// statsResponse are the statistics of a query, the times of the range are in
+// Unix milliseconds
+type statsResponse struct {
+	Query string                `json:"query"`
+	Start int64                 `json:"start"`
+	End   int64                 `json:"end"`
+	Stats *proxy.ExecutionStats `json:"stats"`
+}
+
+// statsHandler runs the query of the query parameter against the tenant of
+// the `/<tenant>` path and returns the statistics Loki reports about its
+// execution: the bytes and lines processed, and the breakdown of the time
+// and chunks between the queriers and the ingesters. The range is the one of
+// the exports, the log queries read at most limit lines like the UI
+func statsHandler(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps) http.Handler {
+	proxyConfig, err := lokiProxyConfig(ds, pluginConfig, deps)
+	if err != nil {
+		return unavailableDatasourceHandler(ds, err)
+	}
+	client := proxy.NewClient(proxyConfig)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := strings.Trim(r.URL.Path, "/")
+		if !tenantRegexp.MatchString(tenant) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid tenant %q", tenant), nil)
+			return
+		}
+
+		params := r.URL.Query()
+
+		query := params.Get("query")
+		if _, err := logql.Parse(query); err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid query", err.Error())
+			return
+		}
+
+		rng, err := exportRange(params.Get("start"), params.Get("end"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, err.Error(), nil)
+			return
+		}
+
+		req := proxy.StatsRequest{Query: query, Start: rng.Start, End: rng.End}
+		if limit := params.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n <= 0 {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid limit %q", limit), nil)
+				return
+			}
+			req.Limit = n
+		}
+
+		stats, err := client.Stats(r, tenant, req)
+		if err != nil {
+			var proxyErr *proxy.Error
+			if !errors.As(err, &proxyErr) {
+				proxyErr = &proxy.Error{Status: http.StatusBadGateway, Code: errorCodeUpstreamUnavailable, Message: "cannot read the query statistics", Err: err}
+			}
+			writeProxyError(w, r, proxyErr)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(statsResponse{
+			Query: query,
+			Start: req.Start.UnixMilli(),
+			End:   req.End.UnixMilli(),
+			Stats: stats,
+		})
+	})
+}
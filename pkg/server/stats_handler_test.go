@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsHandler(t *testing.T) {
+	queries := make(chan url.Values, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries <- r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[],"stats":{
+"summary":{"bytesProcessedPerSecond":2000,"linesProcessedPerSecond":20,"totalBytesProcessed":4000,"totalLinesProcessed":40,"totalEntriesReturned":10,"execTime":2.5,"queueTime":0.5,"subqueries":3,"splits":2,"shards":16},
+"querier":{"store":{"totalChunksRef":8,"totalChunksDownloaded":6,"chunksDownloadTime":1500000000,"chunk":{"compressedBytes":1000,"decompressedBytes":3000,"decompressedLines":30}}},
+"ingester":{"totalReached":3,"totalChunksMatched":4,"totalBatches":5,"totalLinesSent":10,"store":{"chunk":{"headChunkBytes":1000,"headChunkLines":10}}}}}}`))
+	}))
+	defer upstream.Close()
+
+	pluginConfig, err := parsePluginConfig([]byte(fmt.Sprintf("lokiURL: %s", upstream.URL)))
+	require.NoError(t, err)
+	ds, _ := pluginConfig.defaultDatasource()
+	handler := statsHandler(ds, pluginConfig, routeDeps{})
+
+	params := url.Values{"query": {`{app="api"} |= "GET"`}, "start": {"1699999980"}, "end": {"1700003580"}, "limit": {"10"}}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application?"+params.Encode(), nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	upstreamParams := <-queries
+	require.Equal(t, `{app="api"} |= "GET"`, upstreamParams.Get("query"))
+	require.Equal(t, "1699999980000000000", upstreamParams.Get("start"))
+	require.Equal(t, "10", upstreamParams.Get("limit"))
+
+	response := statsResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, int64(1699999980000), response.Start)
+	require.Equal(t, int64(4000), response.Stats.BytesProcessed)
+	require.Equal(t, int64(40), response.Stats.LinesProcessed)
+	require.Equal(t, 2.5, response.Stats.ExecTime)
+	require.Equal(t, int64(16), response.Stats.Shards)
+	require.Equal(t, int64(6), response.Stats.Querier.ChunksDownloaded)
+	require.Equal(t, 1.5, response.Stats.Querier.ChunksDownloadTime)
+	require.Equal(t, int64(3000), response.Stats.Querier.DecompressedBytes)
+	require.Equal(t, int64(10), response.Stats.Ingester.HeadChunkLines)
+	require.Equal(t, int64(3), response.Stats.IngestersReached)
+
+	for _, params := range []url.Values{
+		{"query": {`{app="api"`}},
+		{"query": {`{app="api"}`}, "limit": {"-1"}},
+		{"query": {`{app="api"}`}, "start": {"1700000001"}, "end": {"1699999999"}},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application?"+params.Encode(), nil))
+		require.Equal(t, http.StatusBadRequest, w.Code, params.Encode())
+	}
+}
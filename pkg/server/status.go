@@ -0,0 +1,314 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/metrics"
+)
+
+const (
+	// selfTestInterval is the time between two self-tests
+	selfTestInterval = time.Minute
+	// selfTestTimeout bounds the checks of a datasource
+	selfTestTimeout = 5 * time.Second
+	// maxClockSkew is the offset of the clock of a datasource from the clock
+	// of the plugin above which the relative ranges, like the last 5 minutes,
+	// and the live tail miss logs
+	maxClockSkew = 10 * time.Second
+)
+
+// statuses of the self-test checks and reports, from the best to the worst
+const (
+	selfTestStatusOK      = "ok"
+	selfTestStatusWarning = "warning"
+	selfTestStatusError   = "error"
+)
+
+// self-test steps of a datasource
+const (
+	selfTestStepConfig  = "config"
+	selfTestStepResolve = "resolve"
+	selfTestStepConnect = "connect"
+	selfTestStepTLS     = "tls"
+	selfTestStepClock   = "clock"
+)
+
+// selfTestCheck is the result of the check of the plugin config or of a
+// datasource
+type selfTestCheck struct {
+	// Name is config, or the name of the checked datasource
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Step is the failed step: config, resolve, connect, tls or clock
+	Step    string `json:"step,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Hint suggests how to fix the problem
+	Hint string `json:"hint,omitempty"`
+}
+
+// statusResponse is the report of the last self-test, its status is the
+// worst status of its checks
+type statusResponse struct {
+	Status    string          `json:"status"`
+	CheckedAt time.Time       `json:"checkedAt"`
+	Checks    []selfTestCheck `json:"checks"`
+}
+
+// selfTester periodically checks the changes of the plugin config and the
+// connectivity of the datasources, so that a misconfigured backend is
+// reported before the users query it
+type selfTester struct {
+	reloadingConfig *reloadingPluginConfig
+	lookupHost      func(ctx context.Context, host string) ([]string, error)
+	dial            func(ctx context.Context, network string, address string) (net.Conn, error)
+	now             func() time.Time
+
+	mu     sync.RWMutex
+	report *statusResponse
+}
+
+func newSelfTester(reloadingConfig *reloadingPluginConfig) *selfTester {
+	dialer := &net.Dialer{}
+	return &selfTester{
+		reloadingConfig: reloadingConfig,
+		lookupHost:      net.DefaultResolver.LookupHost,
+		dial:            dialer.DialContext,
+		now:             time.Now,
+	}
+}
+
+// run tests the backend every interval until ctx is done, the loaded plugin
+// config is read on every test
+func (t *selfTester) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report := t.test(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if report.Status != selfTestStatusOK {
+			for _, check := range report.Checks {
+				if check.Status != selfTestStatusOK {
+					slog.Warnf("self-test %s %s: %s", check.Name, check.Status, check.Message)
+				}
+			}
+		}
+
+		t.mu.Lock()
+		t.report = &report
+		t.mu.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// latest returns the report of the last self-test, nil before the first one
+func (t *selfTester) latest() *statusResponse {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.report
+}
+
+// test checks the plugin config, then the datasources concurrently
+func (t *selfTester) test(ctx context.Context) statusResponse {
+	pluginConfig := t.reloadingConfig.get()
+	report := statusResponse{Status: selfTestStatusOK, CheckedAt: t.now()}
+
+	// the loaded config is valid, an invalid change of the config source
+	// keeps it loaded
+	config := selfTestCheck{Name: "config", Status: selfTestStatusOK}
+	datasources := pluginConfig.allDatasources()
+	if err := t.reloadingConfig.lastReloadError(); err != nil {
+		config = selfTestCheck{Name: "config", Status: selfTestStatusError, Step: selfTestStepConfig, Message: err.Error(), Hint: "fix the plugin config, the previous config is used until then. Candidate configs can be checked at /validate-config"}
+	} else if len(datasources) == 0 {
+		config = selfTestCheck{Name: "config", Status: selfTestStatusWarning, Step: selfTestStepConfig, Message: "no datasource is configured", Hint: "set lokiURL or the datasources section of the plugin config"}
+	} else if ds, ok := t.reloadingConfig.discoveredDatasource(); ok {
+		config.Message = fmt.Sprintf("no datasource is configured, the discovered Loki gateway %s is used", ds.URL)
+		config.Hint = "set lokiURL to choose the datasource"
+	}
+	report.Checks = append(report.Checks, config)
+
+	checks := make([]selfTestCheck, len(datasources))
+	var wg sync.WaitGroup
+	for i, ds := range datasources {
+		// the federated datasources query the other datasources, the
+		// kubernetes ones without URL the in-cluster API server
+		if ds.URL == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, ds DatasourceConfig) {
+			defer wg.Done()
+			checks[i] = t.testDatasource(ctx, ds, pluginConfig.ProxyURL)
+		}(i, ds)
+	}
+	wg.Wait()
+	for _, check := range checks {
+		if check.Name != "" {
+			report.Checks = append(report.Checks, check)
+		}
+	}
+
+	for _, check := range report.Checks {
+		if check.Status == selfTestStatusError || (check.Status == selfTestStatusWarning && report.Status == selfTestStatusOK) {
+			report.Status = check.Status
+		}
+	}
+	return report
+}
+
+// testDatasource resolves the host of the datasource, connects to it,
+// completes a TLS handshake with the TLS settings of the datasource and
+// compares its clock with the one of the plugin. Only the proxy is resolved
+// and reached when the datasource is reached through one
+func (t *selfTester) testDatasource(ctx context.Context, ds DatasourceConfig, proxyURL string) selfTestCheck {
+	ctx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	failed := func(step string, err error, hint string) selfTestCheck {
+		return selfTestCheck{Name: ds.Name, Status: selfTestStatusError, Step: step, Message: err.Error(), Hint: hint}
+	}
+
+	u, err := url.Parse(ds.URL)
+	if err != nil {
+		return failed(selfTestStepConfig, err, "fix the URL of the datasource")
+	}
+	roundTripper, err := datasourceTransport(ds, proxyURL, UpstreamConnectionsConfig{})
+	if err != nil {
+		return failed(selfTestStepConfig, err, "check the caFile, clientCertFile and clientKeyFile of the datasource")
+	}
+	// the transports of the datasources are built by newUpstreamTransport
+	transport := roundTripper.(*http.Transport)
+	defer transport.CloseIdleConnections()
+
+	target, tlsTarget := u, u.Scheme == "https"
+	if transport.Proxy != nil {
+		proxy, err := transport.Proxy(&http.Request{URL: u})
+		if err != nil {
+			return failed(selfTestStepConfig, err, "fix proxyURL or the HTTPS_PROXY variable")
+		}
+		if proxy != nil {
+			// the TLS session is tunneled through the proxy
+			target, tlsTarget = proxy, false
+		}
+	}
+
+	host, port := target.Hostname(), target.Port()
+	if port == "" {
+		port = "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	if _, err := t.lookupHost(ctx, host); err != nil {
+		return failed(selfTestStepResolve, err, fmt.Sprintf("check the host name %s and the cluster DNS", host))
+	}
+
+	conn, err := t.dial(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return failed(selfTestStepConnect, err, fmt.Sprintf("check that %s listens on port %s and that the network policies and the egress firewall allow the plugin pod to reach it", host, port))
+	}
+	defer conn.Close()
+
+	check := selfTestCheck{Name: ds.Name, Status: selfTestStatusOK}
+	if tlsTarget {
+		tlsConfig := transport.TLSClientConfig.Clone()
+		tlsConfig.ServerName = host
+		if err := tls.Client(conn, tlsConfig).HandshakeContext(ctx); err != nil {
+			return failed(selfTestStepTLS, err, tlsHint(err))
+		}
+		if ds.InsecureSkipVerify {
+			check.Status, check.Step = selfTestStatusWarning, selfTestStepTLS
+			check.Message = "the certificate of the datasource is not verified"
+			check.Hint = "set caFile to the CA signing the certificate and unset insecureSkipVerify"
+		}
+	}
+
+	skew, err := t.clockSkew(ctx, u, transport)
+	if err != nil {
+		slog.WithError(err).Debugf("cannot read the clock of datasource %s", ds.Name)
+		return check
+	}
+	metrics.DatasourceClockSkew.WithLabelValues(ds.Name).Set(skew.Seconds())
+	if (skew > maxClockSkew || skew < -maxClockSkew) && check.Status == selfTestStatusOK {
+		direction := "ahead of"
+		if skew < 0 {
+			direction, skew = "behind", -skew
+		}
+		check.Status, check.Step = selfTestStatusWarning, selfTestStepClock
+		check.Message = fmt.Sprintf("the clock of the datasource is %s %s the clock of the plugin", skew.Round(time.Second), direction)
+		check.Hint = "synchronize the clocks of the nodes of the plugin and of Loki with NTP, the relative time ranges and the live tail are shifted until then"
+	}
+	return check
+}
+
+// clockSkew returns the offset of the clock of the datasource from the clock
+// of the plugin, read from the Date header of a response of its URL, whatever
+// its status. The header has a second precision, half a second is added, and
+// is compared with the middle of the round trip
+func (t *selfTester) clockSkew(ctx context.Context, u *url.URL, transport http.RoundTripper) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	sent := t.now()
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return 0, err
+	}
+	received := t.now()
+	resp.Body.Close()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid Date header: %w", err)
+	}
+	return date.Add(500 * time.Millisecond).Sub(sent.Add(received.Sub(sent) / 2)), nil
+}
+
+// tlsHint suggests the fix of a failed TLS handshake
+func tlsHint(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return "set the caFile of the datasource to the CA signing its certificate, like the service CA"
+	case errors.As(err, &hostname):
+		return "use a host name of the certificate in the URL of the datasource"
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return "renew the certificate of the datasource"
+	default:
+		return "check the TLS settings of the datasource and that its URL uses https on a TLS port"
+	}
+}
+
+// statusHandler serves the report of the last self-test
+func statusHandler(tester *selfTester) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := tester.latest()
+		if report == nil {
+			writeError(w, r, http.StatusServiceUnavailable, errorCodeUnavailable, "the self-test has not completed yet", nil)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, r, http.StatusOK, report)
+	})
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTest(t *testing.T) {
+	loki := httptest.NewTLSServer(http.NotFoundHandler())
+	defer loki.Close()
+	caPath := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: loki.Certificate().Raw}), 0600))
+
+	// the Date header set by the handler is kept by the server
+	skewed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer skewed.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedURL := "http://" + closed.Addr().String()
+	closed.Close()
+
+	pluginConfig, err := parsePluginConfig([]byte(`
+datasources:
+  - name: verified
+    url: ` + loki.URL + `
+    caFile: ` + caPath + `
+    noProxy: true
+  - name: unknown-ca
+    url: ` + loki.URL + `
+    noProxy: true
+  - name: insecure
+    url: ` + loki.URL + `
+    insecureSkipVerify: true
+    noProxy: true
+  - name: skewed
+    url: ` + skewed.URL + `
+    noProxy: true
+  - name: closed
+    url: ` + closedURL + `
+    noProxy: true
+  - name: unresolved
+    url: https://loki.invalid:8080
+    noProxy: true
+  - name: all
+    type: federated
+    federation:
+      clusters: [verified, closed]
+`))
+	require.NoError(t, err)
+
+	tester := newSelfTester(&reloadingPluginConfig{config: pluginConfig})
+	lookupHost := tester.lookupHost
+	tester.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host == "loki.invalid" {
+			return nil, errors.New("no such host")
+		}
+		return lookupHost(ctx, host)
+	}
+
+	report := tester.test(context.Background())
+	require.Equal(t, selfTestStatusError, report.Status)
+
+	checks := map[string]selfTestCheck{}
+	for _, check := range report.Checks {
+		checks[check.Name] = check
+	}
+	require.Len(t, checks, 7)
+	require.Equal(t, selfTestStatusOK, checks["config"].Status)
+	require.Equal(t, selfTestStatusOK, checks["verified"].Status)
+	require.Equal(t, selfTestStatusError, checks["unknown-ca"].Status)
+	require.Equal(t, selfTestStepTLS, checks["unknown-ca"].Step)
+	require.Contains(t, checks["unknown-ca"].Hint, "caFile")
+	require.Equal(t, selfTestStatusWarning, checks["insecure"].Status)
+	require.Equal(t, selfTestStatusWarning, checks["skewed"].Status)
+	require.Equal(t, selfTestStepClock, checks["skewed"].Step)
+	require.Contains(t, checks["skewed"].Message, "1h0m0s behind")
+	require.Equal(t, selfTestStepConnect, checks["closed"].Step)
+	require.Equal(t, selfTestStepResolve, checks["unresolved"].Step)
+	require.NotContains(t, checks, "all")
+}
+
+func TestSelfTestWithoutDatasources(t *testing.T) {
+	tester := newSelfTester(&reloadingPluginConfig{config: &PluginConfig{}})
+	report := tester.test(context.Background())
+	require.Equal(t, selfTestStatusWarning, report.Status)
+	require.Equal(t, []selfTestCheck{{Name: "config", Status: selfTestStatusWarning, Step: selfTestStepConfig, Message: "no datasource is configured", Hint: "set lokiURL or the datasources section of the plugin config"}}, report.Checks)
+}
+
+func TestStatusHandler(t *testing.T) {
+	tester := newSelfTester(&reloadingPluginConfig{config: &PluginConfig{}})
+
+	w := httptest.NewRecorder()
+	statusHandler(tester).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tester.run(ctx, selfTestInterval)
+	require.Eventually(t, func() bool { return tester.latest() != nil }, time.Second, 10*time.Millisecond)
+
+	w = httptest.NewRecorder()
+	statusHandler(tester).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	report := statusResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Equal(t, selfTestStatusWarning, report.Status)
+}
+
+func TestSelfTestReloadError(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("lokiURL: https://loki:8080\n"), 0600))
+	reloadingConfig, err := newReloadingPluginConfig(configPath)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("logsLimit: -1\n"), 0600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(configPath, later, later))
+	_, err = reloadingConfig.reload()
+	require.Error(t, err)
+
+	tester := newSelfTester(reloadingConfig)
+	tester.lookupHost = func(context.Context, string) ([]string, error) { return nil, errors.New("no such host") }
+	report := tester.test(context.Background())
+	require.Equal(t, selfTestStatusError, report.Status)
+	require.Equal(t, selfTestStepConfig, report.Checks[0].Step)
+	require.Contains(t, report.Checks[0].Message, "logsLimit")
+}
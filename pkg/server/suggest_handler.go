@@ -0,0 +1,266 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+const (
+	defaultSuggestLimit = 20
+	maxSuggestLimit     = 100
+)
+
+// the kinds of the suggestions, by rank
+const (
+	suggestionLabel   = "label"
+	suggestionValue   = "value"
+	suggestionSaved   = "saved"
+	suggestionHistory = "history"
+)
+
+var suggestionKindRanks = map[string]int{
+	suggestionLabel:   0,
+	suggestionValue:   0,
+	suggestionSaved:   1,
+	suggestionHistory: 2,
+}
+
+// suggestion is a completion of the query editor
+type suggestion struct {
+	Text string `json:"text"`
+	Kind string `json:"kind"`
+	// Detail is the name of a saved query, or the runs of a recent query
+	Detail string `json:"detail,omitempty"`
+}
+
+// suggestResponse are the ranked suggestions, the warnings report the
+// sources that could not be read
+type suggestResponse struct {
+	Suggestions []suggestion `json:"suggestions"`
+	Warnings    []string     `json:"warnings,omitempty"`
+}
+
+// rankedSuggestion is a suggestion with its ranking: the prefix matches
+// first, then the kinds by rank, then the most run and the most recent
+type rankedSuggestion struct {
+	suggestion
+	match   int
+	runs    int
+	lastRun time.Time
+}
+
+// suggestionMatch returns 0 when text starts with prefix, 1 when it contains
+// it, ignoring the case, and -1 otherwise. Every text matches an empty prefix
+func suggestionMatch(prefix string, texts ...string) int {
+	prefix = strings.ToLower(prefix)
+	match := -1
+	for _, text := range texts {
+		text = strings.ToLower(text)
+		switch {
+		case strings.HasPrefix(text, prefix):
+			return 0
+		case strings.Contains(text, prefix):
+			match = 1
+		}
+	}
+	return match
+}
+
+// suggestHandler completes the queries of the editor for the tenant of the
+// `/<tenant>` path: with the label names, the saved queries and the recent
+// queries of the user matching the prefix parameter, or with the values of
+// the label parameter. The labels are read from the streams of the namespace
+// parameters, of every stream without them, over the range of the exports,
+// and cached for a few seconds. The saved and recent queries of the tenants
+// authorized by the plugin are suggested when the user can access all their
+// namespaces
+func suggestHandler(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps) http.Handler {
+	proxyConfig, err := lokiProxyConfig(ds, pluginConfig, deps)
+	if err != nil {
+		return unavailableDatasourceHandler(ds, err)
+	}
+	client := proxy.NewClient(proxyConfig)
+	cache := newResponseCache()
+
+	enforced := map[string]bool{}
+	if deps.authorizer != nil {
+		for _, tenant := range pluginConfig.Authorization.Tenants {
+			enforced[tenant] = true
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := strings.Trim(r.URL.Path, "/")
+		if !tenantRegexp.MatchString(tenant) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid tenant %q", tenant), nil)
+			return
+		}
+
+		params := r.URL.Query()
+		prefix := params.Get("prefix")
+		label := params.Get("label")
+		if label != "" && !labelNameRegexp.MatchString(label) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid label %q", label), nil)
+			return
+		}
+		limit := defaultSuggestLimit
+		if value := params.Get("limit"); value != "" {
+			// the limit is validated by queryParamsMiddleware
+			limit, _ = strconv.Atoi(value)
+			if limit > maxSuggestLimit {
+				limit = maxSuggestLimit
+			}
+		}
+		rng, err := exportRange(params.Get("start"), params.Get("end"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, err.Error(), nil)
+			return
+		}
+
+		// the selector set by namespacesSelectorMiddleware restricts the
+		// labels to the namespace parameters
+		labelsReq := proxy.LabelsRequest{Start: rng.Start, End: rng.End}
+		if len(params["namespace"]) > 0 {
+			labelsReq.Query = params.Get("query")
+		}
+
+		response := suggestResponse{Suggestions: []suggestion{}}
+		ranked := []rankedSuggestion{}
+		add := func(s rankedSuggestion, texts ...string) {
+			if s.match = suggestionMatch(prefix, texts...); s.match >= 0 {
+				ranked = append(ranked, s)
+			}
+		}
+
+		// the labels of a minute are shared by the users allowed to read the
+		// same streams
+		key := strings.Join([]string{queryCacheScope(r), tenant, label, labelsReq.Query, strconv.FormatInt(rng.Start.Unix()/60, 10), strconv.FormatInt(rng.End.Unix()/60, 10)}, "\x00")
+		labels := cache.get(0, key, func() ([]byte, error) {
+			var labels []string
+			var err error
+			if label != "" {
+				labels, err = client.LabelValues(r, tenant, label, labelsReq)
+			} else {
+				labels, err = client.Labels(r, tenant, labelsReq)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(labels)
+		})
+		names := []string{}
+		if labels.err == nil {
+			labels.err = json.Unmarshal(labels.body, &names)
+		}
+		if labels.err != nil {
+			requestLog(slog, r).WithError(labels.err).Warn("cannot list the labels to suggest")
+			response.Warnings = append(response.Warnings, fmt.Sprintf("cannot list the labels: %s", labels.err))
+		}
+		kind := suggestionLabel
+		if label != "" {
+			kind = suggestionValue
+		}
+		for _, name := range names {
+			add(rankedSuggestion{suggestion: suggestion{Text: name, Kind: kind}}, name)
+		}
+
+		user, authenticated := requestUser(r)
+		if label == "" && authenticated {
+			// the queries of the namespaces the user cannot access anymore
+			// are left out
+			allowed := func(query string) bool {
+				if !enforced[tenant] {
+					return true
+				}
+				return namespacesAllowed(r, deps, user, query)
+			}
+
+			if deps.savedQueries != nil {
+				saved, err := deps.savedQueries.List(r.Context(), user.Username, user.Groups)
+				if err != nil {
+					requestLog(slog, r).WithError(err).Warn("cannot list the saved queries to suggest")
+					response.Warnings = append(response.Warnings, "cannot list the saved queries")
+				}
+				for _, q := range saved {
+					if (q.Tenant == "" || q.Tenant == tenant) && allowed(q.Query) {
+						add(rankedSuggestion{suggestion: suggestion{Text: q.Query, Kind: suggestionSaved, Detail: q.Name}}, q.Query, q.Name)
+					}
+				}
+			}
+
+			if deps.queryHistory != nil {
+				recent := map[string]*rankedSuggestion{}
+				for _, entry := range deps.queryHistory.List(user.Username) {
+					if entry.Tenant != tenant || entry.Query == "" {
+						continue
+					}
+					if s, ok := recent[entry.Query]; ok {
+						s.runs++
+						continue
+					}
+					// the entries are listed from the newest
+					recent[entry.Query] = &rankedSuggestion{suggestion: suggestion{Text: entry.Query, Kind: suggestionHistory}, runs: 1, lastRun: entry.Time}
+				}
+				for query, s := range recent {
+					if allowed(query) {
+						s.Detail = fmt.Sprintf("run %d times", s.runs)
+						add(*s, query)
+					}
+				}
+			}
+		}
+
+		sort.Slice(ranked, func(i, j int) bool {
+			a, b := ranked[i], ranked[j]
+			switch {
+			case a.match != b.match:
+				return a.match < b.match
+			case suggestionKindRanks[a.Kind] != suggestionKindRanks[b.Kind]:
+				return suggestionKindRanks[a.Kind] < suggestionKindRanks[b.Kind]
+			case a.runs != b.runs:
+				return a.runs > b.runs
+			case !a.lastRun.Equal(b.lastRun):
+				return a.lastRun.After(b.lastRun)
+			default:
+				return a.Text < b.Text
+			}
+		})
+		seen := map[string]bool{}
+		for _, s := range ranked {
+			if len(response.Suggestions) == limit {
+				break
+			}
+			if !seen[s.Text] {
+				seen[s.Text] = true
+				response.Suggestions = append(response.Suggestions, s.suggestion)
+			}
+		}
+
+		w.Header().Set("Cache-Control", "private, no-store")
+		writeJSON(w, r, http.StatusOK, response)
+	})
+}
+
+// namespacesAllowed tells whether user can access every namespace selected
+// by query, the queries whose namespaces cannot be listed are not allowed
+func namespacesAllowed(r *http.Request, deps routeDeps, user *kube.UserInfo, query string) bool {
+	namespaces, err := logqlNamespaces([]string{query})
+	if err != nil {
+		return false
+	}
+	for _, namespace := range namespaces {
+		allowed, err := deps.authorizer.Authorize(r.Context(), user, namespace)
+		if err != nil || !allowed {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/authz"
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/openshift/logging-view-plugin/pkg/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestHandler(t *testing.T) {
+	var requests int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/loki/api/v1/labels"):
+			require.Equal(t, `{kubernetes_namespace_name=~"my-app"}`, r.URL.Query().Get("query"))
+			w.Write([]byte(`{"status":"success","data":["kubernetes_namespace_name","kubernetes_pod_name","level"]}`))
+		case strings.HasSuffix(r.URL.Path, "/loki/api/v1/label/level/values"):
+			w.Write([]byte(`{"status":"success","data":["error","info","warning"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	pluginConfig, err := parsePluginConfig([]byte("lokiURL: " + upstream.URL))
+	require.NoError(t, err)
+	ds, _ := pluginConfig.defaultDatasource()
+
+	savedQueries := store.New(store.NewMemoryBackend(), 10)
+	for _, q := range []store.Query{
+		{Name: "kube errors", Query: `{kubernetes_namespace_name="my-app"} |= "error"`, Tenant: "application", Owner: "developer"},
+		{Name: "other errors", Query: `{kubernetes_namespace_name="other"} |= "error"`, Tenant: "application", Owner: "developer"},
+		{Name: "audit", Query: `{kubernetes_namespace_name="my-app"}`, Tenant: "audit", Owner: "developer"},
+	} {
+		_, err := savedQueries.Add(context.Background(), q)
+		require.NoError(t, err)
+	}
+	history := store.NewHistory(10)
+	now := time.Now()
+	for i, query := range []string{`{kubernetes_namespace_name="my-app", level="info"}`, `{kubernetes_namespace_name="my-app", level="error"}`, `{kubernetes_namespace_name="my-app", level="error"}`} {
+		history.Record(store.HistoryEntry{User: "developer", Query: query, Tenant: "application", Time: now.Add(time.Duration(i) * time.Second)})
+	}
+
+	deps := routeDeps{
+		authorizer:   authz.New(&fakeAccessReviewer{allowedNamespaces: map[string]bool{"my-app": true}}, defaultAuthorizationConfig.resourceAttributes()),
+		savedQueries: savedQueries,
+		queryHistory: history,
+	}
+	handler := namespacesSelectorMiddleware(suggestHandler(ds, pluginConfig, deps))
+	suggest := func(params url.Values) (int, suggestResponse) {
+		r := httptest.NewRequest(http.MethodGet, "/application?"+params.Encode(), nil)
+		r = r.WithContext(context.WithValue(r.Context(), userKey{}, &kube.UserInfo{Username: "developer"}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		response := suggestResponse{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response
+	}
+
+	// the prefix matches first, the labels before the saved queries and the
+	// most run recent queries, the other namespaces are left out
+	status, response := suggest(url.Values{"namespace": {"my-app"}, "prefix": {"kube"}})
+	require.Equal(t, http.StatusOK, status)
+	require.Empty(t, response.Warnings)
+	require.Equal(t, []suggestion{
+		{Text: "kubernetes_namespace_name", Kind: suggestionLabel},
+		{Text: "kubernetes_pod_name", Kind: suggestionLabel},
+		{Text: `{kubernetes_namespace_name="my-app"} |= "error"`, Kind: suggestionSaved, Detail: "kube errors"},
+		{Text: `{kubernetes_namespace_name="my-app", level="error"}`, Kind: suggestionHistory, Detail: "run 2 times"},
+		{Text: `{kubernetes_namespace_name="my-app", level="info"}`, Kind: suggestionHistory, Detail: "run 1 times"},
+	}, response.Suggestions)
+
+	// the labels are cached
+	status, response = suggest(url.Values{"namespace": {"my-app"}, "prefix": {"LEVEL"}, "limit": {"1"}})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, []suggestion{{Text: "level", Kind: suggestionLabel}}, response.Suggestions)
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	status, response = suggest(url.Values{"label": {"level"}, "prefix": {"warn"}})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, []suggestion{{Text: "warning", Kind: suggestionValue}}, response.Suggestions)
+
+	// the failures of Loki are warnings
+	status, response = suggest(url.Values{"label": {"app"}})
+	require.Equal(t, http.StatusOK, status)
+	require.Empty(t, response.Suggestions)
+	require.Len(t, response.Warnings, 1)
+
+	status, _ = suggest(url.Values{"label": {"not-a-label"}})
+	require.Equal(t, http.StatusBadRequest, status)
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/version"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	supportBundlePath = "/api/debug/bundle"
+	// maxRecentErrorLogs is the number of error logs kept for the bundles
+	maxRecentErrorLogs = 200
+	// redactedValue replaces the secrets of the bundled config
+	redactedValue = "REDACTED"
+)
+
+// errorLogs keeps the last error logs of the backend, the hook is added to the
+// logger once the server is set up
+var (
+	errorLogs        = newRecentErrorLogs(maxRecentErrorLogs)
+	errorLogsHookSet sync.Once
+)
+
+// recentErrorLogs is a logrus hook keeping the last error logs in JSON
+type recentErrorLogs struct {
+	mu        sync.Mutex
+	entries   [][]byte
+	next      int
+	formatter logrus.Formatter
+}
+
+func newRecentErrorLogs(size int) *recentErrorLogs {
+	return &recentErrorLogs{entries: make([][]byte, 0, size), formatter: &logrus.JSONFormatter{}}
+}
+
+func (l *recentErrorLogs) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+func (l *recentErrorLogs) Fire(entry *logrus.Entry) error {
+	line, err := l.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	// the formatter may return the buffer of the entry
+	line = append([]byte(nil), line...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < cap(l.entries) {
+		l.entries = append(l.entries, line)
+		return nil
+	}
+	l.entries[l.next] = line
+	l.next = (l.next + 1) % len(l.entries)
+	return nil
+}
+
+// lines returns the kept error logs, the oldest first
+func (l *recentErrorLogs) lines() []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buf := bytes.Buffer{}
+	for i := range l.entries {
+		buf.Write(l.entries[(l.next+i)%len(l.entries)])
+	}
+	return buf.Bytes()
+}
+
+// secretKeyRegexp matches the config keys holding a secret, the keys of the
+// file paths like tokenFile are kept
+var secretKeyRegexp = regexp.MustCompile(`(?i)(password|passwd|secret|token|apikey|api_key|credential|authorization)`)
+
+// redactConfig returns the plugin config in YAML with the values of the
+// secret keys and the passwords of the URLs redacted
+func redactConfig(pluginConfig *PluginConfig) ([]byte, error) {
+	content, err := yaml.Marshal(pluginConfig)
+	if err != nil {
+		return nil, err
+	}
+	var config interface{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(redactValue("", config))
+}
+
+func redactValue(key string, value interface{}) interface{} {
+	secret := secretKeyRegexp.MatchString(key) && !strings.HasSuffix(strings.ToLower(key), "file")
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = redactValue(k, child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(key, child)
+		}
+		return v
+	case string:
+		if secret {
+			return redactedValue
+		}
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+		return v
+	default:
+		if secret && value != nil {
+			return redactedValue
+		}
+		return value
+	}
+}
+
+type bundleFile struct {
+	name    string
+	content func() ([]byte, error)
+}
+
+// supportBundleHandler serves a tar.gz of the diagnostics asked in the
+// support cases: the redacted plugin config, the features, the version, the
+// last self-test report, the goroutine and heap profiles and the last error
+// logs
+func supportBundleHandler(cfg *Config, reloadingConfig *reloadingPluginConfig, selfTest *selfTester) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UTC()
+		dir := "logging-view-plugin-bundle-" + now.Format("20060102T150405Z")
+		pluginConfig := reloadingConfig.get()
+
+		files := []bundleFile{
+			{"config.yaml", func() ([]byte, error) { return redactConfig(pluginConfig) }},
+			{"features.json", func() ([]byte, error) {
+				resolved := resolveFeatures(cfg.Features, pluginConfig)
+				return json.MarshalIndent(featuresResponse{Features: resolved.enabled, Disabled: resolved.disabled}, "", "  ")
+			}},
+			{"version.json", func() ([]byte, error) { return json.MarshalIndent(version.Get(), "", "  ") }},
+			{"status.json", func() ([]byte, error) {
+				var report *statusResponse
+				if selfTest != nil {
+					report = selfTest.latest()
+				}
+				return json.MarshalIndent(report, "", "  ")
+			}},
+			{"goroutines.txt", func() ([]byte, error) { return profile("goroutine", 2) }},
+			{"heap.pb.gz", func() ([]byte, error) { return profile("heap", 0) }},
+			{"errors.log", func() ([]byte, error) { return errorLogs.lines(), nil }},
+		}
+
+		// the bundle is built before it is sent, so that its errors are
+		// returned with an error status
+		buf := &bytes.Buffer{}
+		gz := gzip.NewWriter(buf)
+		archive := tar.NewWriter(gz)
+		for _, file := range files {
+			content, err := file.content()
+			if err != nil {
+				requestLog(slog, r).WithError(err).Errorf("cannot collect %s for the support bundle", file.name)
+				writeError(w, r, http.StatusInternalServerError, errorCodeInternal, fmt.Sprintf("cannot collect %s", file.name), err.Error())
+				return
+			}
+			header := &tar.Header{Name: dir + "/" + file.name, Mode: 0644, Size: int64(len(content)), ModTime: now}
+			if err := archive.WriteHeader(header); err != nil {
+				writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot write the support bundle", err.Error())
+				return
+			}
+			if _, err := archive.Write(content); err != nil {
+				writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot write the support bundle", err.Error())
+				return
+			}
+		}
+		if err := archive.Close(); err != nil {
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot write the support bundle", err.Error())
+			return
+		}
+		if err := gz.Close(); err != nil {
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot write the support bundle", err.Error())
+			return
+		}
+
+		requestLog(slog, r).Info("serving a support bundle")
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", dir+".tar.gz"))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(buf.Bytes())
+	})
+}
+
+// profile returns the runtime profile name in the debug format
+func profile(name string, debug int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := pprof.Lookup(name).WriteTo(buf, debug); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
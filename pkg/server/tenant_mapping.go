@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// autoTenant is the tenant of the query routes resolved from the namespaces
+// selected by the query
+const autoTenant = "auto"
+
+// tenantHeader is the response header carrying the tenant resolved for the
+// auto tenant
+const tenantHeader = "X-Logging-Tenant"
+
+// TenantMappingConfig maps the namespaces to the tenant holding their logs,
+// the first matching rule wins and the other namespaces belong to Default
+type TenantMappingConfig struct {
+	Rules   []TenantMappingRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+	Default string              `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// TenantMappingRule maps the listed Namespaces and the ones matching Pattern
+// to Tenant
+type TenantMappingRule struct {
+	Namespaces []string `yaml:"namespaces,omitempty" json:"namespaces,omitempty"`
+	Pattern    string   `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	Tenant     string   `yaml:"tenant" json:"tenant"`
+}
+
+// defaultTenantMappingConfig maps the namespaces of the platform to the
+// infrastructure tenant, like the console does
+var defaultTenantMappingConfig = TenantMappingConfig{
+	Rules: []TenantMappingRule{
+		{Namespaces: []string{"default", "openshift"}, Tenant: "infrastructure"},
+		{Pattern: "^(openshift|kube)-", Tenant: "infrastructure"},
+	},
+	Default: "application",
+}
+
+func (c TenantMappingConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	for i, rule := range c.Rules {
+		field := fmt.Sprintf("tenantMapping.rules[%d]", i)
+		if len(rule.Namespaces) == 0 && rule.Pattern == "" {
+			errs = append(errs, ConfigValidationError{Field: field, Message: "namespaces or pattern is required"})
+		}
+		for j, namespace := range rule.Namespaces {
+			if !namespaceRegexp.MatchString(namespace) {
+				errs = append(errs, ConfigValidationError{Field: fmt.Sprintf("%s.namespaces[%d]", field, j), Message: fmt.Sprintf("invalid namespace %q", namespace)})
+			}
+		}
+		if rule.Pattern != "" {
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				errs = append(errs, ConfigValidationError{Field: field + ".pattern", Message: fmt.Sprintf("invalid pattern %q: %s", rule.Pattern, err)})
+			}
+		}
+		if !tenantRegexp.MatchString(rule.Tenant) || rule.Tenant == autoTenant {
+			errs = append(errs, ConfigValidationError{Field: field + ".tenant", Message: fmt.Sprintf("invalid tenant %q", rule.Tenant)})
+		}
+	}
+	if c.Default != "" && (!tenantRegexp.MatchString(c.Default) || c.Default == autoTenant) {
+		errs = append(errs, ConfigValidationError{Field: "tenantMapping.default", Message: fmt.Sprintf("invalid tenant %q", c.Default)})
+	}
+	return errs
+}
+
+// tenantMapper resolves the tenants of the namespaces with the rules of a
+// validated tenant mapping
+type tenantMapper struct {
+	rules         []tenantMapperRule
+	defaultTenant string
+}
+
+type tenantMapperRule struct {
+	namespaces map[string]bool
+	pattern    *regexp.Regexp
+	tenant     string
+}
+
+func newTenantMapper(cfg TenantMappingConfig) *tenantMapper {
+	m := &tenantMapper{defaultTenant: cfg.Default}
+	for _, rule := range cfg.Rules {
+		mapperRule := tenantMapperRule{namespaces: map[string]bool{}, tenant: rule.Tenant}
+		for _, namespace := range rule.Namespaces {
+			mapperRule.namespaces[namespace] = true
+		}
+		if rule.Pattern != "" {
+			// the patterns are validated when the plugin config is parsed
+			mapperRule.pattern, _ = regexp.Compile(rule.Pattern)
+		}
+		m.rules = append(m.rules, mapperRule)
+	}
+	return m
+}
+
+// tenant returns the tenant holding the logs of namespace
+func (m *tenantMapper) tenant(namespace string) string {
+	for _, rule := range m.rules {
+		if rule.namespaces[namespace] || (rule.pattern != nil && rule.pattern.MatchString(namespace)) {
+			return rule.tenant
+		}
+	}
+	return m.defaultTenant
+}
+
+// tenantHandler serves the tenant of the namespace query parameter
+func tenantHandler(m *tenantMapper) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		namespace := r.URL.Query().Get("namespace")
+		if !namespaceRegexp.MatchString(namespace) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid namespace %q", namespace), nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"namespace": namespace, "tenant": m.tenant(namespace)})
+	})
+}
+
+// tenantMappingMiddleware replaces the auto tenant of the `/<tenant>` paths
+// with the tenant of the namespaces selected by the query, so that the
+// following middlewares and the datasource see the resolved tenant. The
+// resolved tenant is echoed in the X-Logging-Tenant response header
+func tenantMappingMiddleware(m *tenantMapper) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if tenant != autoTenant {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			namespaces, err := queryNamespaces(r)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "cannot resolve the tenant of the query", err.Error())
+				return
+			}
+
+			resolved := ""
+			for _, namespace := range namespaces {
+				namespaceTenant := m.tenant(namespace)
+				if resolved != "" && namespaceTenant != resolved {
+					writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("the query selects namespaces of the %s and %s tenants", resolved, namespaceTenant), nil)
+					return
+				}
+				resolved = namespaceTenant
+			}
+
+			r = r.Clone(r.Context())
+			r.URL.Path = "/" + resolved
+			if rest != "" {
+				r.URL.Path += "/" + rest
+			}
+			r.URL.RawPath = ""
+			w.Header().Set(tenantHeader, resolved)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
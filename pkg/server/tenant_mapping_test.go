@@ -0,0 +1,145 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantMapper(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(""))
+	require.NoError(t, err)
+	defaultMapper := newTenantMapper(pluginConfig.TenantMapping)
+
+	for namespace, expected := range map[string]string{
+		"default":           "infrastructure",
+		"openshift":         "infrastructure",
+		"openshift-logging": "infrastructure",
+		"kube-system":       "infrastructure",
+		"my-app":            "application",
+	} {
+		require.Equal(t, expected, defaultMapper.tenant(namespace), namespace)
+	}
+
+	pluginConfig, err = parsePluginConfig([]byte(`
+tenantMapping:
+  rules:
+    - namespaces: [payments]
+      tenant: finance
+    - pattern: ^team-a-
+      tenant: team-a
+  default: shared
+`))
+	require.NoError(t, err)
+	mapper := newTenantMapper(pluginConfig.TenantMapping)
+	require.Equal(t, "finance", mapper.tenant("payments"))
+	require.Equal(t, "team-a", mapper.tenant("team-a-api"))
+	require.Equal(t, "shared", mapper.tenant("openshift-logging"))
+}
+
+func TestTenantHandler(t *testing.T) {
+	handler := tenantHandler(newTenantMapper(defaultTenantMappingConfig))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/tenant?namespace=openshift-logging", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"namespace":"openshift-logging","tenant":"infrastructure"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/tenant?namespace=Invalid_Namespace", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTenantMappingMiddleware(t *testing.T) {
+	var forwardedPath string
+	handler := tenantMappingMiddleware(newTenantMapper(defaultTenantMappingConfig))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedPath = r.URL.Path
+	}))
+
+	tests := []struct {
+		name           string
+		path           string
+		query          string
+		expectedStatus int
+		expectedPath   string
+		expectedTenant string
+	}{
+		{
+			name:           "explicit tenant",
+			path:           "/audit/loki/api/v1/query_range",
+			query:          `{log_type="audit"}`,
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/audit/loki/api/v1/query_range",
+		},
+		{
+			name:           "application namespace",
+			path:           "/auto/loki/api/v1/query_range",
+			query:          `{kubernetes_namespace_name="my-app"}`,
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/application/loki/api/v1/query_range",
+			expectedTenant: "application",
+		},
+		{
+			name:           "infrastructure namespaces of the tail",
+			path:           "/auto",
+			query:          `{kubernetes_namespace_name=~"openshift-logging|kube-system"}`,
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/infrastructure",
+			expectedTenant: "infrastructure",
+		},
+		{
+			name:           "namespaces of several tenants",
+			path:           "/auto/loki/api/v1/query_range",
+			query:          `{kubernetes_namespace_name=~"my-app|openshift-logging"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "namespace of another tenant after a comment",
+			path:           "/auto/loki/api/v1/query",
+			query:          "count_over_time({kubernetes_namespace_name=\"my-app\"}[1m]) # \"\nor count_over_time({kubernetes_namespace_name=\"openshift-logging\"}[1m]) # \"",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "no namespace",
+			path:           "/auto/loki/api/v1/query_range",
+			query:          `{app="api"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwardedPath = ""
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path+"?"+url.Values{"query": {tt.query}}.Encode(), nil))
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			require.Equal(t, tt.expectedPath, forwardedPath)
+			require.Equal(t, tt.expectedTenant, w.Header().Get(tenantHeader))
+		})
+	}
+}
+
+func TestTenantMappingValidation(t *testing.T) {
+	errs := TenantMappingConfig{
+		Rules: []TenantMappingRule{
+			{Tenant: "application"},
+			{Namespaces: []string{"Not_A_Namespace"}, Pattern: "(", Tenant: "auto"},
+		},
+		Default: "in valid",
+	}.validate()
+
+	fields := []string{}
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	require.Equal(t, []string{
+		"tenantMapping.rules[0]",
+		"tenantMapping.rules[1].namespaces[0]",
+		"tenantMapping.rules[1].pattern",
+		"tenantMapping.rules[1].tenant",
+		"tenantMapping.default",
+	}, fields)
+}
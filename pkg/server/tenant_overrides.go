@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TenantConfig overrides the plugin config settings for the queries of one
+// tenant, the unset fields keep the global values
+type TenantConfig struct {
+	LogsLimit    int      `yaml:"logsLimit,omitempty" json:"logsLimit,omitempty"`
+	Timeout      Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	DefaultQuery string   `yaml:"defaultQuery,omitempty" json:"defaultQuery,omitempty"`
+}
+
+// forTenant returns a copy of the config with the overrides of tenant
+// applied, the tenant overrides are not part of the copy
+func (c *PluginConfig) forTenant(tenant string) *PluginConfig {
+	merged := *c
+	merged.Tenants = nil
+
+	overrides, ok := c.Tenants[tenant]
+	if !ok {
+		return &merged
+	}
+
+	if overrides.LogsLimit != 0 {
+		merged.LogsLimit = overrides.LogsLimit
+	}
+	if overrides.Timeout.Duration != 0 {
+		merged.Timeout = overrides.Timeout
+	}
+	if overrides.DefaultQuery != "" {
+		merged.DefaultQuery = overrides.DefaultQuery
+	}
+
+	return &merged
+}
+
+// tenantTimeouts returns the upstream timeouts overridden per tenant
+func (c *PluginConfig) tenantTimeouts() map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+	for tenant, overrides := range c.Tenants {
+		if overrides.Timeout.Duration != 0 {
+			timeouts[tenant] = overrides.Timeout.Duration
+		}
+	}
+	return timeouts
+}
+
+func (c *PluginConfig) validateTenants() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+
+	// sort the tenants to report the errors in a stable order
+	tenants := make([]string, 0, len(c.Tenants))
+	for tenant := range c.Tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+
+	for _, tenant := range tenants {
+		overrides := c.Tenants[tenant]
+		field := fmt.Sprintf("tenants.%s", tenant)
+		if !tenantRegexp.MatchString(tenant) {
+			errs = append(errs, ConfigValidationError{Field: field, Message: fmt.Sprintf("invalid tenant %q", tenant)})
+		}
+		if overrides.LogsLimit < 0 {
+			errs = append(errs, ConfigValidationError{Field: field + ".logsLimit", Message: "logsLimit must be greater than 0"})
+		}
+		if overrides.Timeout.Duration < 0 || overrides.Timeout.Duration > maxTimeout {
+			errs = append(errs, ConfigValidationError{Field: field + ".timeout", Message: fmt.Sprintf("timeout must be between 0 and %s", maxTimeout)})
+		}
+		errs = append(errs, validateDefaultQuery(field+".defaultQuery", overrides.DefaultQuery)...)
+	}
+
+	return errs
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigHandlerTenantOverrides(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+timeout: 30s
+logsLimit: 1000
+defaultQuery: '{log_type="application"}'
+tenants:
+  application:
+    logsLimit: 100
+    timeout: 10s
+  infrastructure:
+    defaultQuery: '{log_type="infrastructure"}'
+`), 0600))
+
+	reloadingConfig, err := newReloadingPluginConfig(configFile)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                 string
+		query                string
+		expectedStatus       int
+		expectedLogsLimit    int
+		expectedTimeout      time.Duration
+		expectedDefaultQuery string
+	}{
+		{
+			name:                 "without tenant",
+			expectedStatus:       http.StatusOK,
+			expectedLogsLimit:    1000,
+			expectedTimeout:      30 * time.Second,
+			expectedDefaultQuery: `{log_type="application"}`,
+		},
+		{
+			name:                 "application",
+			query:                "?tenant=application",
+			expectedStatus:       http.StatusOK,
+			expectedLogsLimit:    100,
+			expectedTimeout:      10 * time.Second,
+			expectedDefaultQuery: `{log_type="application"}`,
+		},
+		{
+			name:                 "infrastructure",
+			query:                "?tenant=infrastructure",
+			expectedStatus:       http.StatusOK,
+			expectedLogsLimit:    1000,
+			expectedTimeout:      30 * time.Second,
+			expectedDefaultQuery: `{log_type="infrastructure"}`,
+		},
+		{
+			name:                 "tenant without overrides",
+			query:                "?tenant=audit",
+			expectedStatus:       http.StatusOK,
+			expectedLogsLimit:    1000,
+			expectedTimeout:      30 * time.Second,
+			expectedDefaultQuery: `{log_type="application"}`,
+		},
+		{
+			name:           "invalid tenant",
+			query:          "?tenant=../audit",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			configHandler(reloadingConfig).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config"+tt.query, nil))
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			pluginConfig := PluginConfig{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pluginConfig))
+			require.Equal(t, tt.expectedLogsLimit, pluginConfig.LogsLimit)
+			require.Equal(t, tt.expectedTimeout, pluginConfig.Timeout.Duration)
+			require.Equal(t, tt.expectedDefaultQuery, pluginConfig.DefaultQuery)
+			if tt.query != "" {
+				require.Nil(t, pluginConfig.Tenants)
+			}
+		})
+	}
+
+	require.Equal(t, map[string]time.Duration{"application": 10 * time.Second}, reloadingConfig.get().tenantTimeouts())
+}
+
+func TestValidateTenants(t *testing.T) {
+	_, err := parsePluginConfig([]byte(`
+tenants:
+  infrastructure:
+    timeout: 1h
+  application:
+    logsLimit: -5
+`))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "tenants.application.logsLimit", Message: "logsLimit must be greater than 0"},
+		{Field: "tenants.infrastructure.timeout", Message: "timeout must be between 0 and 10m0s"},
+	}, err)
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+const textContentType = "text/plain; charset=utf-8"
+
+// acceptsText returns true when the first media type accepted by the client
+// is text/plain, like `curl -H 'Accept: text/plain'`. The browsers accepting
+// anything get JSON
+func acceptsText(r *http.Request) bool {
+	first, _, _ := strings.Cut(r.Header.Get("Accept"), ",")
+	mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(first))
+	return err == nil && mediaType == "text/plain"
+}
+
+// textLogLine formats entry as a flat timestamped log line
+func textLogLine(entry proxy.Entry) string {
+	return entry.Timestamp.UTC().Format(time.RFC3339Nano) + " " + strings.TrimRight(entry.Line, "\n") + "\n"
+}
+
+// textLogsMiddleware sends the log queries of the /<tenant>/loki/api/v1/query
+// and query_range paths as flat timestamped lines to the clients accepting
+// text/plain, in the direction of the query. The errors are sent as is
+func textLogsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsText(r) || !isQueryEndpoint(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// the response is decoded here, it must be uncompressed JSON
+		r.Header.Set("Accept", "application/json")
+		r.Header.Del("Accept-Encoding")
+		buffered := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(buffered, r)
+
+		if buffered.status != http.StatusOK {
+			buffered.writeTo(w)
+			return
+		}
+
+		entries, err := proxy.DecodeEntries(buffered.body.Bytes())
+		if err != nil {
+			var proxyErr *proxy.Error
+			errors.As(err, &proxyErr)
+			writeProxyError(w, r, proxyErr)
+			return
+		}
+
+		forward := r.URL.Query().Get("direction") == "forward"
+		var b strings.Builder
+		for i := range entries {
+			entry := entries[i]
+			if forward {
+				entry = entries[len(entries)-1-i]
+			}
+			b.WriteString(textLogLine(entry))
+		}
+
+		w.Header().Set("Content-Type", textContentType)
+		w.Write([]byte(b.String()))
+	})
+}
+
+// isQueryEndpoint returns true for the query and query_range endpoints of
+// the /<tenant>/loki/api/v1/<endpoint> paths
+func isQueryEndpoint(urlPath string) bool {
+	_, endpoint, _ := strings.Cut(strings.TrimPrefix(urlPath, "/"), "/")
+	return endpoint == "loki/api/v1/query" || endpoint == "loki/api/v1/query_range"
+}
+
+// bufferedResponse holds a response to send it later, or to transform it
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// writeTo sends the buffered response to w
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
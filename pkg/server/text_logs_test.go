@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextLogsMiddleware(t *testing.T) {
+	handler := textLogsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Accept"))
+		switch r.URL.Query().Get("query") {
+		case `{app="missing"}`:
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid query", nil)
+		case `count_over_time({app="api"}[5m])`:
+			w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		default:
+			w.Write([]byte(testStreams))
+		}
+	}))
+
+	tests := []struct {
+		name                string
+		target              string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "backward",
+			target:              "/application/loki/api/v1/query_range?query=%7Bapp%3D~%22api%7Cdb%22%7D",
+			accept:              "text/plain",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody: "2023-11-14T22:13:20.000000003Z GET /orders 500\n" +
+				"2023-11-14T22:13:20.000000002Z slow query, \"orders\"\n" +
+				"2023-11-14T22:13:20.000000001Z started\n",
+		},
+		{
+			name:                "forward",
+			target:              "/application/loki/api/v1/query_range?query=%7Bapp%3D~%22api%7Cdb%22%7D&direction=forward",
+			accept:              "text/plain, application/json;q=0.5",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody: "2023-11-14T22:13:20.000000001Z started\n" +
+				"2023-11-14T22:13:20.000000002Z slow query, \"orders\"\n" +
+				"2023-11-14T22:13:20.000000003Z GET /orders 500\n",
+		},
+		{
+			name:           "metric query",
+			target:         "/application/loki/api/v1/query_range?query=count_over_time%28%7Bapp%3D%22api%22%7D%5B5m%5D%29",
+			accept:         "text/plain",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:                "upstream error",
+			target:              "/application/loki/api/v1/query_range?query=%7Bapp%3D%22missing%22%7D",
+			accept:              "text/plain",
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: "application/json",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			r.Header.Set("Accept", tc.accept)
+			r.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedContentType != "" {
+				require.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+			}
+			if tc.expectedBody != "" {
+				require.Equal(t, tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAcceptsText(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"text/plain":                   true,
+		"text/plain; charset=utf-8":    true,
+		"application/json":             false,
+		"*/*":                          false,
+		"application/json, text/plain": false,
+		"":                             false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		require.Equal(t, expected, acceptsText(r), accept)
+	}
+}
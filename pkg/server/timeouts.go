@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// probeTimeout bounds the responses of the health probes and metrics
+	probeTimeout = 5 * time.Second
+	// apiDeadlineMargin lets the proxy reply to the upstream timeouts before
+	// the API route deadline
+	apiDeadlineMargin = 5 * time.Second
+)
+
+var (
+	// streamingPaths are the path prefixes of the long lived responses, they
+	// have no deadline
+	streamingPaths = []string{"/api/tail/", "/api/export/", "/debug/pprof/"}
+	// probePaths are the path prefixes of the routes bound by probeTimeout
+	probePaths = []string{"/health", "/readyz", "/metrics", "/version"}
+)
+
+// routeDeadlines are the deadlines of the responses of the routes, a zero
+// deadline disables it
+type routeDeadlines struct {
+	// api bounds the /api/ routes, defaults to the default deadline
+	api time.Duration
+	// defaultDeadline bounds the other routes
+	defaultDeadline time.Duration
+}
+
+// newRouteDeadlines returns the route deadlines, the API routes are bound by
+// the longest upstream timeout of the plugin config
+func newRouteDeadlines(cfg *Config, pluginConfig *PluginConfig) routeDeadlines {
+	longest := pluginConfig.Timeout.Duration
+	for _, timeout := range pluginConfig.tenantTimeouts() {
+		if timeout > longest {
+			longest = timeout
+		}
+	}
+
+	deadlines := routeDeadlines{api: cfg.WriteTimeout, defaultDeadline: cfg.WriteTimeout}
+	if longest > 0 {
+		deadlines.api = longest + apiDeadlineMargin
+	}
+	return deadlines
+}
+
+func (d routeDeadlines) forPath(urlPath string) time.Duration {
+	for _, prefix := range streamingPaths {
+		if strings.HasPrefix(urlPath, prefix) {
+			return 0
+		}
+	}
+	for _, prefix := range probePaths {
+		if strings.HasPrefix(urlPath, prefix) {
+			return probeTimeout
+		}
+	}
+	if strings.HasPrefix(urlPath, "/api/") {
+		return d.api
+	}
+	return d.defaultDeadline
+}
+
+// timeoutMiddleware replies with a 503 error to the requests exceeding the
+// deadline of their route, the streaming routes are not bound. The server
+// write timeout cannot be lifted per route, the deadlines are enforced with
+// http.TimeoutHandler instead
+func timeoutMiddleware(deadlines routeDeadlines) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline := deadlines.forPath(r.URL.Path)
+			if deadline <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// the handler writes the message as is, the error envelope is
+			// built here
+			message := "the request took longer than " + deadline.String()
+			if acceptsJSON(r) {
+				body, _ := json.Marshal(errorResponse{Error: apiError{
+					Code:      errorCodeTimeout,
+					Message:   message,
+					RequestID: r.Header.Get(requestIDHeader),
+				}})
+				message = string(body)
+			}
+			http.TimeoutHandler(next, deadline, message).ServeHTTP(w, r)
+		})
+	}
+}
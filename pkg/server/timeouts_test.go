@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteDeadlines(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte(`
+timeout: 30s
+tenants:
+  audit:
+    timeout: 2m
+`))
+	require.NoError(t, err)
+
+	deadlines := newRouteDeadlines(&Config{WriteTimeout: 10 * time.Second}, pluginConfig)
+
+	tests := []struct {
+		path             string
+		expectedDeadline time.Duration
+	}{
+		{path: "/api/proxy/audit/loki/api/v1/query_range", expectedDeadline: 2*time.Minute + apiDeadlineMargin},
+		{path: "/api/tail/application", expectedDeadline: 0},
+		{path: "/api/export/application", expectedDeadline: 0},
+		{path: "/debug/pprof/profile", expectedDeadline: 0},
+		{path: "/healthz", expectedDeadline: probeTimeout},
+		{path: "/metrics", expectedDeadline: probeTimeout},
+		{path: "/plugin-manifest.json", expectedDeadline: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			require.Equal(t, tt.expectedDeadline, deadlines.forPath(tt.path))
+		})
+	}
+
+	deadlines = newRouteDeadlines(&Config{WriteTimeout: 10 * time.Second}, &PluginConfig{})
+	require.Equal(t, 10*time.Second, deadlines.forPath("/api/rules"))
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+		w.Write([]byte("done"))
+	})
+	handler := timeoutMiddleware(routeDeadlines{api: 10 * time.Millisecond, defaultDeadline: 10 * time.Millisecond})(slow)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/rules", nil)
+	r.Header.Set(requestIDHeader, "abc-123")
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.JSONEq(t, `{"error":{"code":"Timeout","message":"the request took longer than 10ms","requestId":"abc-123"}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/api/rules", nil)
+	r.Header.Set("Accept", "*/*")
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "the request took longer than 10ms", w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/export/application", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "done", w.Body.String())
+}
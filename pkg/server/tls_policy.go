@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// tlsVersions are the accepted TLS versions by name, the older versions are
+// not supported
+var tlsVersions = map[string]uint16{
+	"1.2":          tls.VersionTLS12,
+	"1.3":          tls.VersionTLS13,
+	"VersionTLS12": tls.VersionTLS12,
+	"VersionTLS13": tls.VersionTLS13,
+}
+
+// ParseTLSVersion parses a TLS version like 1.2 or VersionTLS13, 0 when value
+// is empty
+func ParseTLSVersion(value string) (uint16, error) {
+	if value == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[strings.TrimSpace(value)]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version %q, expected 1.2 or 1.3", value)
+	}
+	return version, nil
+}
+
+// ParseCipherSuites parses a comma separated list of the TLS 1.2 cipher suite
+// names of crypto/tls, like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. The suites
+// known to be insecure are rejected
+func ParseCipherSuites(value string) ([]uint16, error) {
+	supported := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+	insecure := map[string]bool{}
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	suites := []uint16{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if insecure[name] {
+			return nil, fmt.Errorf("cipher suite %s is insecure", name)
+		}
+		id, ok := supported[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		suites = append(suites, id)
+	}
+
+	return suites, nil
+}
+
+// applyTLSPolicy sets the versions and cipher suites of cfg on tlsConfig
+func applyTLSPolicy(tlsConfig *tls.Config, cfg *Config) error {
+	if cfg.TLSMinVersion != 0 {
+		tlsConfig.MinVersion = cfg.TLSMinVersion
+	}
+	if cfg.TLSMaxVersion != 0 {
+		if cfg.TLSMaxVersion < tlsConfig.MinVersion {
+			return fmt.Errorf("the maximum TLS version %s is lower than the minimum %s", tlsVersionName(cfg.TLSMaxVersion), tlsVersionName(tlsConfig.MinVersion))
+		}
+		tlsConfig.MaxVersion = cfg.TLSMaxVersion
+	}
+	if len(cfg.TLSCipherSuites) > 0 {
+		if tlsConfig.MinVersion == tls.VersionTLS13 {
+			return fmt.Errorf("the cipher suites cannot be set with TLS 1.3 only, its suites are not configurable")
+		}
+		tlsConfig.CipherSuites = cfg.TLSCipherSuites
+	}
+	return nil
+}
+
+// describeTLSPolicy returns the effective versions and cipher suites of
+// tlsConfig, for the startup logs
+func describeTLSPolicy(tlsConfig *tls.Config) string {
+	maxVersion := "1.3"
+	if tlsConfig.MaxVersion != 0 {
+		maxVersion = tlsVersionName(tlsConfig.MaxVersion)
+	}
+
+	suites := "Go defaults"
+	if len(tlsConfig.CipherSuites) > 0 {
+		names := make([]string, 0, len(tlsConfig.CipherSuites))
+		for _, id := range tlsConfig.CipherSuites {
+			names = append(names, tls.CipherSuiteName(id))
+		}
+		suites = strings.Join(names, ", ")
+	}
+
+	return fmt.Sprintf("TLS %s to %s, TLS 1.2 cipher suites: %s", tlsVersionName(tlsConfig.MinVersion), maxVersion, suites)
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	default:
+		return fmt.Sprintf("0x%04x", version)
+	}
+}
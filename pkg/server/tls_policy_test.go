@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	for value, expected := range map[string]uint16{"": 0, "1.2": tls.VersionTLS12, "VersionTLS13": tls.VersionTLS13} {
+		version, err := ParseTLSVersion(value)
+		require.NoError(t, err, value)
+		require.Equal(t, expected, version, value)
+	}
+
+	for _, value := range []string{"1.1", "VersionTLS10", "tls13"} {
+		_, err := ParseTLSVersion(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	suites, err := ParseCipherSuites("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,")
+	require.NoError(t, err)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256}, suites)
+
+	_, err = ParseCipherSuites("TLS_RSA_WITH_RC4_128_SHA")
+	require.EqualError(t, err, "cipher suite TLS_RSA_WITH_RC4_128_SHA is insecure")
+
+	_, err = ParseCipherSuites("TLS_AES_256_CBC")
+	require.Error(t, err)
+}
+
+func TestApplyTLSPolicy(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	require.NoError(t, applyTLSPolicy(tlsConfig, &Config{
+		TLSMaxVersion:   tls.VersionTLS12,
+		TLSCipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+	}))
+	require.Equal(t, "TLS 1.2 to 1.2, TLS 1.2 cipher suites: TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", describeTLSPolicy(tlsConfig))
+
+	require.Error(t, applyTLSPolicy(&tls.Config{MinVersion: tls.VersionTLS12}, &Config{TLSMinVersion: tls.VersionTLS13, TLSMaxVersion: tls.VersionTLS12}))
+	require.Error(t, applyTLSPolicy(&tls.Config{MinVersion: tls.VersionTLS12}, &Config{TLSMinVersion: tls.VersionTLS13, TLSCipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}}))
+
+	require.Equal(t, "TLS 1.2 to 1.3, TLS 1.2 cipher suites: Go defaults", describeTLSPolicy(&tls.Config{MinVersion: tls.VersionTLS12}))
+}
+
+func TestTLSPolicyHandshake(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	require.NoError(t, applyTLSPolicy(server.TLS, &Config{TLSCipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}}))
+	server.StartTLS()
+	defer server.Close()
+
+	client := func(suite uint16) *http.Client {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.MaxVersion = tls.VersionTLS12
+		transport.TLSClientConfig.CipherSuites = []uint16{suite}
+		return &http.Client{Transport: transport}
+	}
+
+	resp, err := client(tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = client(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256).Get(server.URL)
+	require.Error(t, err)
+}
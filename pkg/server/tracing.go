@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/openshift/logging-view-plugin/pkg/tracing"
+)
+
+// tracingMiddleware records a server span per request named after the matched
+// route template, continuing the trace of the traceparent header if any
+func tracingMiddleware(tracer *tracing.Tracer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if tracer == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeTemplate(r)
+
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracer.Start(ctx, r.Method+" "+route, tracing.KindServer,
+				tracing.String("http.method", r.Method),
+				tracing.String("http.route", route),
+				tracing.String("http.target", r.URL.Path),
+				tracing.String("request_id", r.Header.Get(requestIDHeader)),
+			)
+			defer span.End()
+
+			m := httpsnoop.CaptureMetrics(next, w, r.WithContext(ctx))
+
+			span.SetAttributes(tracing.Int("http.status_code", int64(m.Code)))
+			if m.Code >= http.StatusInternalServerError {
+				span.SetError(httpStatusError(m.Code))
+			}
+		})
+	}
+}
+
+type httpStatusError int
+
+func (e httpStatusError) Error() string {
+	return http.StatusText(int(e))
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+// UpstreamConfig protects the Loki datasources from the bursts of queries, and
+// the plugin from piling up requests while a datasource is failing
+type UpstreamConfig struct {
+	// MaxInFlight bounds the queries proxied to each datasource at the same
+	// time
+	MaxInFlight int `yaml:"maxInFlight,omitempty" json:"maxInFlight,omitempty"`
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit breaker of a datasource
+	FailureThreshold int `yaml:"failureThreshold,omitempty" json:"failureThreshold,omitempty"`
+	// OpenDuration is the time the queries of an open circuit are rejected
+	// before the datasource is tried again
+	OpenDuration time.Duration `yaml:"openDuration,omitempty" json:"openDuration,omitempty"`
+	// MinTimeout is the timeout of the queries without a range, the longer
+	// ranges add TimeoutPerHour per hour of range, up to the plugin config
+	// timeout. The timeout is not scaled when unset
+	MinTimeout     time.Duration `yaml:"minTimeout,omitempty" json:"minTimeout,omitempty"`
+	TimeoutPerHour time.Duration `yaml:"timeoutPerHour,omitempty" json:"timeoutPerHour,omitempty"`
+	// Retry sends the failed queries again, to smooth over the restarts of
+	// the gateway pods
+	Retry UpstreamRetryConfig `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// Connections sizes the connection pools of the datasources
+	Connections UpstreamConnectionsConfig `yaml:"connections,omitempty" json:"connections,omitempty"`
+}
+
+// UpstreamRetryConfig retries the queries failing with a transient error when
+// MaxAttempts is greater than 1
+type UpstreamRetryConfig struct {
+	// MaxAttempts is the number of attempts of a query, including the first
+	// one
+	MaxAttempts int `yaml:"maxAttempts,omitempty" json:"maxAttempts,omitempty"`
+	// Backoff is the wait before the first retry, doubled on each retry up to
+	// MaxBackoff. A longer Retry-After is not waited for
+	Backoff    time.Duration `yaml:"backoff,omitempty" json:"backoff,omitempty"`
+	MaxBackoff time.Duration `yaml:"maxBackoff,omitempty" json:"maxBackoff,omitempty"`
+	// StatusCodes are the retried response statuses, a 429 is only retried
+	// with a Retry-After header
+	StatusCodes []int `yaml:"statusCodes,omitempty" json:"statusCodes,omitempty"`
+}
+
+// UpstreamConnectionsConfig sizes the connection pool of each transport of a
+// datasource and bounds its handshakes. The default transport keeps 2 idle
+// connections per host, which dials new connections on every burst of
+// dashboard queries
+type UpstreamConnectionsConfig struct {
+	// MaxIdleConns bounds the idle connections kept to all the hosts
+	MaxIdleConns int `yaml:"maxIdleConns,omitempty" json:"maxIdleConns,omitempty"`
+	// MaxIdleConnsPerHost bounds the idle connections kept to each host
+	MaxIdleConnsPerHost int `yaml:"maxIdleConnsPerHost,omitempty" json:"maxIdleConnsPerHost,omitempty"`
+	// MaxConnsPerHost bounds the connections to each host, the requests wait
+	// for a connection beyond. The connections are not bounded when unset
+	MaxConnsPerHost int `yaml:"maxConnsPerHost,omitempty" json:"maxConnsPerHost,omitempty"`
+	// IdleConnTimeout closes the connections idle for longer
+	IdleConnTimeout time.Duration `yaml:"idleConnTimeout,omitempty" json:"idleConnTimeout,omitempty"`
+	// TLSHandshakeTimeout bounds the TLS handshakes of the new connections,
+	// the one of the transport is kept when unset
+	TLSHandshakeTimeout time.Duration `yaml:"tlsHandshakeTimeout,omitempty" json:"tlsHandshakeTimeout,omitempty"`
+	// DisableKeepAlives closes the connections after each request, for the
+	// load balancers spreading the connections rather than the requests
+	DisableKeepAlives bool `yaml:"disableKeepAlives,omitempty" json:"disableKeepAlives,omitempty"`
+}
+
+// maxRetryAttempts bounds the attempts of a query
+const maxRetryAttempts = 10
+
+var defaultUpstreamConfig = UpstreamConfig{
+	MaxInFlight:      100,
+	FailureThreshold: 5,
+	OpenDuration:     30 * time.Second,
+	Retry: UpstreamRetryConfig{
+		MaxAttempts: 1,
+		Backoff:     200 * time.Millisecond,
+		MaxBackoff:  2 * time.Second,
+		StatusCodes: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	},
+	Connections: UpstreamConnectionsConfig{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+func (c UpstreamConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.MaxInFlight < 0 {
+		errs = append(errs, ConfigValidationError{Field: "upstream.maxInFlight", Message: "maxInFlight cannot be negative"})
+	}
+	if c.FailureThreshold < 0 {
+		errs = append(errs, ConfigValidationError{Field: "upstream.failureThreshold", Message: "failureThreshold cannot be negative"})
+	}
+	if c.OpenDuration < 0 {
+		errs = append(errs, ConfigValidationError{Field: "upstream.openDuration", Message: "openDuration cannot be negative"})
+	}
+	if c.MinTimeout < 0 || c.MinTimeout > maxTimeout {
+		errs = append(errs, ConfigValidationError{Field: "upstream.minTimeout", Message: fmt.Sprintf("minTimeout must be between 0 and %s", maxTimeout)})
+	}
+	if c.TimeoutPerHour < 0 {
+		errs = append(errs, ConfigValidationError{Field: "upstream.timeoutPerHour", Message: "timeoutPerHour cannot be negative"})
+	}
+	if c.TimeoutPerHour > 0 && c.MinTimeout == 0 {
+		errs = append(errs, ConfigValidationError{Field: "upstream.timeoutPerHour", Message: "timeoutPerHour requires minTimeout"})
+	}
+	errs = append(errs, c.Retry.validate()...)
+	errs = append(errs, c.Connections.validate()...)
+	return errs
+}
+
+func (c UpstreamRetryConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.MaxAttempts < 0 || c.MaxAttempts > maxRetryAttempts {
+		errs = append(errs, ConfigValidationError{Field: "upstream.retry.maxAttempts", Message: fmt.Sprintf("maxAttempts must be between 0 and %d", maxRetryAttempts)})
+	}
+	if c.Backoff < 0 {
+		errs = append(errs, ConfigValidationError{Field: "upstream.retry.backoff", Message: "backoff cannot be negative"})
+	}
+	if c.MaxBackoff < 0 || (c.MaxBackoff > 0 && c.MaxBackoff < c.Backoff) {
+		errs = append(errs, ConfigValidationError{Field: "upstream.retry.maxBackoff", Message: "maxBackoff cannot be lower than backoff"})
+	}
+	for i, code := range c.StatusCodes {
+		if code < 400 || code > 599 {
+			errs = append(errs, ConfigValidationError{Field: fmt.Sprintf("upstream.retry.statusCodes[%d]", i), Message: fmt.Sprintf("invalid status code %d, expected a 4xx or 5xx status", code)})
+		}
+	}
+	return errs
+}
+
+func (c UpstreamConnectionsConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.MaxIdleConns < 0 {
+		errs = append(errs, ConfigValidationError{Field: "upstream.connections.maxIdleConns", Message: "maxIdleConns cannot be negative"})
+	}
+	if c.MaxIdleConnsPerHost < 0 {
+		errs = append(errs, ConfigValidationError{Field: "upstream.connections.maxIdleConnsPerHost", Message: "maxIdleConnsPerHost cannot be negative"})
+	}
+	if c.MaxIdleConns > 0 && c.MaxIdleConnsPerHost > c.MaxIdleConns {
+		errs = append(errs, ConfigValidationError{Field: "upstream.connections.maxIdleConnsPerHost", Message: "maxIdleConnsPerHost cannot be greater than maxIdleConns"})
+	}
+	if c.MaxConnsPerHost < 0 {
+		errs = append(errs, ConfigValidationError{Field: "upstream.connections.maxConnsPerHost", Message: "maxConnsPerHost cannot be negative"})
+	}
+	if c.IdleConnTimeout < 0 {
+		errs = append(errs, ConfigValidationError{Field: "upstream.connections.idleConnTimeout", Message: "idleConnTimeout cannot be negative"})
+	}
+	if c.TLSHandshakeTimeout < 0 || c.TLSHandshakeTimeout > maxTimeout {
+		errs = append(errs, ConfigValidationError{Field: "upstream.connections.tlsHandshakeTimeout", Message: fmt.Sprintf("tlsHandshakeTimeout must be between 0 and %s", maxTimeout)})
+	}
+	return errs
+}
+
+// apply sets the pool settings of transport, the unset ones keep the
+// settings of the transport
+func (c UpstreamConnectionsConfig) apply(transport *http.Transport) {
+	if c.MaxIdleConns > 0 {
+		transport.MaxIdleConns = c.MaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	}
+	if c.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = c.MaxConnsPerHost
+	}
+	if c.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = c.IdleConnTimeout
+	}
+	if c.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = c.TLSHandshakeTimeout
+	}
+	if c.DisableKeepAlives {
+		transport.DisableKeepAlives = true
+	}
+}
+
+// proxyRetryConfig returns the retry policy of the datasource proxies
+func (c UpstreamRetryConfig) proxyRetryConfig() proxy.RetryConfig {
+	if c.MaxAttempts <= 1 {
+		return proxy.RetryConfig{}
+	}
+	return proxy.RetryConfig{
+		MaxAttempts: c.MaxAttempts,
+		Backoff:     c.Backoff,
+		MaxBackoff:  c.MaxBackoff,
+		StatusCodes: c.StatusCodes,
+	}
+}
+
+// newDatasourceBreakers returns the circuit breakers of the datasources by
+// name, shared by their proxy, tail and export routes
+func newDatasourceBreakers(pluginConfig *PluginConfig) map[string]*proxy.Breaker {
+	breakers := map[string]*proxy.Breaker{}
+	for _, ds := range pluginConfig.allDatasources() {
+		if !ds.isLoki() {
+			continue
+		}
+		breaker := proxy.NewBreaker(proxy.BreakerConfig{
+			Name:             ds.Name,
+			FailureThreshold: pluginConfig.Upstream.FailureThreshold,
+			OpenDuration:     pluginConfig.Upstream.OpenDuration,
+		})
+		if breaker != nil {
+			breakers[ds.Name] = breaker
+		}
+	}
+	return breakers
+}
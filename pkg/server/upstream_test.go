@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamConfig(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte("lokiURL: http://loki:3100"))
+	require.NoError(t, err)
+	require.Equal(t, defaultUpstreamConfig, pluginConfig.Upstream)
+	require.Equal(t, proxy.RetryConfig{}, pluginConfig.Upstream.Retry.proxyRetryConfig())
+
+	_, err = parsePluginConfig([]byte("upstream:\n  maxInFlight: -1"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "upstream.maxInFlight", Message: "maxInFlight cannot be negative"},
+	}, err)
+
+	pluginConfig, err = parsePluginConfig([]byte("upstream:\n  minTimeout: 30s\n  timeoutPerHour: 5s"))
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, pluginConfig.Upstream.MinTimeout)
+	require.Equal(t, 5*time.Second, pluginConfig.Upstream.TimeoutPerHour)
+
+	_, err = parsePluginConfig([]byte("upstream:\n  timeoutPerHour: 5s"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "upstream.timeoutPerHour", Message: "timeoutPerHour requires minTimeout"},
+	}, err)
+
+	pluginConfig, err = parsePluginConfig([]byte("upstream:\n  retry:\n    maxAttempts: 3\n    backoff: 5s"))
+	require.NoError(t, err)
+	require.Equal(t, proxy.RetryConfig{MaxAttempts: 3, Backoff: 5 * time.Second, MaxBackoff: 5 * time.Second, StatusCodes: []int{429, 502, 503, 504}}, pluginConfig.Upstream.Retry.proxyRetryConfig())
+
+	_, err = parsePluginConfig([]byte("upstream:\n  retry:\n    maxAttempts: 20\n    statusCodes: [503, 200]"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "upstream.retry.maxAttempts", Message: "maxAttempts must be between 0 and 10"},
+		{Field: "upstream.retry.statusCodes[1]", Message: "invalid status code 200, expected a 4xx or 5xx status"},
+	}, err)
+}
+
+func TestUpstreamConnectionsConfig(t *testing.T) {
+	pluginConfig, err := parsePluginConfig([]byte("upstream:\n  connections:\n    maxIdleConns: 20\n    maxConnsPerHost: 50\n    tlsHandshakeTimeout: 5s\n    disableKeepAlives: true"))
+	require.NoError(t, err)
+	// the default idle connections per host are bounded by maxIdleConns
+	require.Equal(t, UpstreamConnectionsConfig{MaxIdleConns: 20, MaxIdleConnsPerHost: 20, MaxConnsPerHost: 50, IdleConnTimeout: 90 * time.Second, TLSHandshakeTimeout: 5 * time.Second, DisableKeepAlives: true}, pluginConfig.Upstream.Connections)
+
+	transport, err := datasourceTransport(DatasourceConfig{Name: "infra"}, "", pluginConfig.Upstream.Connections)
+	require.NoError(t, err)
+	require.Equal(t, 20, transport.(*http.Transport).MaxIdleConns)
+	require.Equal(t, 20, transport.(*http.Transport).MaxIdleConnsPerHost)
+	require.Equal(t, 50, transport.(*http.Transport).MaxConnsPerHost)
+	require.Equal(t, 90*time.Second, transport.(*http.Transport).IdleConnTimeout)
+	require.Equal(t, 5*time.Second, transport.(*http.Transport).TLSHandshakeTimeout)
+	require.True(t, transport.(*http.Transport).DisableKeepAlives)
+
+	_, err = parsePluginConfig([]byte("upstream:\n  connections:\n    maxIdleConns: 10\n    maxIdleConnsPerHost: 20\n    maxConnsPerHost: -1\n    idleConnTimeout: -1s\n    tlsHandshakeTimeout: -1s"))
+	require.Equal(t, ConfigValidationErrors{
+		{Field: "upstream.connections.maxIdleConnsPerHost", Message: "maxIdleConnsPerHost cannot be greater than maxIdleConns"},
+		{Field: "upstream.connections.maxConnsPerHost", Message: "maxConnsPerHost cannot be negative"},
+		{Field: "upstream.connections.idleConnTimeout", Message: "idleConnTimeout cannot be negative"},
+		{Field: "upstream.connections.tlsHandshakeTimeout", Message: fmt.Sprintf("tlsHandshakeTimeout must be between 0 and %s", maxTimeout)},
+	}, err)
+}
+
+func TestNewDatasourceBreakers(t *testing.T) {
+	pluginConfig := &PluginConfig{
+		LokiURL:     "http://loki:3100",
+		Datasources: []DatasourceConfig{{Name: "infra", URL: "http://infra-loki:3100"}},
+		Upstream:    UpstreamConfig{FailureThreshold: 3, OpenDuration: time.Minute},
+	}
+	breakers := newDatasourceBreakers(pluginConfig)
+	require.Len(t, breakers, 2)
+	require.Contains(t, breakers, defaultDatasourceName)
+	require.Contains(t, breakers, "infra")
+
+	pluginConfig.Upstream.FailureThreshold = 0
+	require.Empty(t, newDatasourceBreakers(pluginConfig))
+}
+
+func TestReadinessHandlerCircuitBreakers(t *testing.T) {
+	breakers := map[string]*proxy.Breaker{
+		defaultDatasourceName: proxy.NewBreaker(proxy.BreakerConfig{Name: defaultDatasourceName, FailureThreshold: 1, OpenDuration: time.Minute}),
+	}
+	checker := newReadinessChecker(&Config{}, &PluginConfig{}, breakers, nil)
+
+	w := httptest.NewRecorder()
+	readinessHandler(checker).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	response := readinessResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, map[string]string{defaultDatasourceName: proxy.BreakerClosed}, response.CircuitBreakers)
+}
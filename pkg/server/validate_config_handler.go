@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// maxConfigSize is the maximum size of a candidate plugin config
+const maxConfigSize = 1 << 20
+
+type configValidationResult struct {
+	Valid  bool                   `json:"valid"`
+	Errors ConfigValidationErrors `json:"errors,omitempty"`
+}
+
+// validateConfigHandler validates a candidate plugin config YAML sent in the
+// request body without applying it
+func validateConfigHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigSize))
+		if err != nil {
+			writeError(w, r, http.StatusRequestEntityTooLarge, errorCodePayloadTooLarge, "cannot read config", err.Error())
+			return
+		}
+
+		result := configValidationResult{Valid: true}
+
+		if _, err := parsePluginConfig(content); err != nil {
+			result.Valid = false
+			if !errors.As(err, &result.Errors) {
+				result.Errors = ConfigValidationErrors{{Message: err.Error()}}
+			}
+		}
+
+		jsonResult, err := json.Marshal(result)
+		if err != nil {
+			requestLog(slog, r).WithError(err).Error("cannot marshal config validation result")
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal validation result", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonResult)
+	})
+}
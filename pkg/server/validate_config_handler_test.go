@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfigHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         string
+		expectedResult configValidationResult
+	}{
+		{
+			name:           "valid config",
+			config:         "cacheControl:\n  - pattern: /*.js\n    value: no-cache\n",
+			expectedResult: configValidationResult{Valid: true},
+		},
+		{
+			name:   "invalid rule",
+			config: "cacheControl:\n  - pattern: '[a'\n",
+			expectedResult: configValidationResult{Errors: ConfigValidationErrors{
+				{Field: "cacheControl[0].pattern", Message: `invalid pattern "[a"`},
+				{Field: "cacheControl[0].value", Message: "value is required"},
+			}},
+		},
+		{
+			name:   "credentials with wildcard origin",
+			config: "cors:\n  allowedOrigins: ['*']\n  allowCredentials: true\n",
+			expectedResult: configValidationResult{Errors: ConfigValidationErrors{
+				{Field: "cors.allowedOrigins[0]", Message: "the * origin cannot be used with allowCredentials"},
+			}},
+		},
+		{
+			name:   "malformed yaml",
+			config: "cacheControl: [",
+			expectedResult: configValidationResult{Errors: ConfigValidationErrors{
+				{Message: "yaml: line 1: did not find expected node content"},
+			}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			validateConfigHandler()(w, httptest.NewRequest(http.MethodPost, "/validate-config", strings.NewReader(tc.config)))
+
+			require.Equal(t, http.StatusOK, w.Code)
+
+			result := configValidationResult{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			require.Equal(t, tc.expectedResult, result)
+		})
+	}
+}
+
+func TestValidateConfigRoute(t *testing.T) {
+	requireAuthenticatedRoute(t, http.MethodPost, "/validate-config", "cacheControl: []\n")
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/openshift/logging-view-plugin/pkg/version"
+)
+
+// versionResponse is the build information of the backend and its enabled
+// features
+type versionResponse struct {
+	version.Info
+	Features []string `json:"features"`
+}
+
+// versionHandler serves the build information, it is not authenticated so
+// support can check the running version with curl
+func versionHandler(cfg *Config, reloadingConfig *reloadingPluginConfig) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		features := sortedFeatures(reloadingConfig.features(cfg))
+		body, err := json.Marshal(versionResponse{Info: version.Get(), Features: features})
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, errorCodeInternal, "cannot marshal version", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(body)
+	})
+}
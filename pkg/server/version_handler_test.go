@@ -0,0 +1,41 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/version"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionHandler(t *testing.T) {
+	defer func(v, commit, date string) {
+		version.Version, version.GitCommit, version.BuildDate = v, commit, date
+	}(version.Version, version.GitCommit, version.BuildDate)
+	version.Version, version.GitCommit, version.BuildDate = "v5.8.0", "0123abc", "2023-06-01T10:00:00Z"
+
+	pluginConfig, err := newReloadingPluginConfig("")
+	require.NoError(t, err)
+
+	handler := versionHandler(&Config{Features: map[string]bool{"dev-console": true, "alerts": true, "disabled": false}}, pluginConfig)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	response := versionResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, versionResponse{
+		Info: version.Info{
+			Version:   "v5.8.0",
+			GitCommit: "0123abc",
+			BuildDate: "2023-06-01T10:00:00Z",
+			GoVersion: runtime.Version(),
+		},
+		Features: []string{"alerts", "dev-console"},
+	}, response)
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/logql"
+	"github.com/openshift/logging-view-plugin/pkg/proxy"
+)
+
+const (
+	// volumeBuckets is the number of buckets the volume intervals aim at,
+	// like the histogram of the UI
+	volumeBuckets = 60
+	// defaultVolumeGroupBy is the label counted separately by default, the
+	// histogram colors the levels
+	defaultVolumeGroupBy = "level"
+)
+
+var (
+	labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// volumeIntervals are the bucket intervals of the volumes, the shortest
+	// one giving at most volumeBuckets buckets is used
+	volumeIntervals = []time.Duration{
+		time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+		time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+		time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+	}
+)
+
+// volumeResponse are the bucketed counts of a log query, the times are in
+// Unix milliseconds
+type volumeResponse struct {
+	Start    int64                `json:"start"`
+	End      int64                `json:"end"`
+	Interval int64                `json:"interval"`
+	Series   []proxy.VolumeSeries `json:"series"`
+}
+
+// volumeHandler counts the entries of the log query of the query parameter
+// against the tenant of the `/<tenant>` path, in buckets whose interval
+// depends on the length of the range. The entries are counted by level unless
+// the groupBy parameter names another label, or none
+func volumeHandler(ds DatasourceConfig, pluginConfig *PluginConfig, deps routeDeps) http.Handler {
+	proxyConfig, err := lokiProxyConfig(ds, pluginConfig, deps)
+	if err != nil {
+		return unavailableDatasourceHandler(ds, err)
+	}
+	client := proxy.NewClient(proxyConfig)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := strings.Trim(r.URL.Path, "/")
+		if !tenantRegexp.MatchString(tenant) {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid tenant %q", tenant), nil)
+			return
+		}
+
+		params := r.URL.Query()
+
+		query := params.Get("query")
+		parsed, err := logql.Parse(query)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "invalid query", err.Error())
+			return
+		}
+		if parsed.Metric {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, "the volume of a log query is expected", nil)
+			return
+		}
+
+		groupBy := defaultVolumeGroupBy
+		if values, ok := params["groupBy"]; ok {
+			groupBy = values[0]
+			if groupBy != "" && !labelNameRegexp.MatchString(groupBy) {
+				writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, fmt.Sprintf("invalid groupBy label %q", groupBy), nil)
+				return
+			}
+		}
+
+		// the volumes have the range of the exports, the last hour by
+		// default
+		rng, err := exportRange(params.Get("start"), params.Get("end"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errorCodeInvalidRequest, err.Error(), nil)
+			return
+		}
+
+		req := volumeRequest(rng.Start, rng.End)
+		req.Query = query
+		req.GroupBy = groupBy
+
+		series, err := client.Volume(r, tenant, req)
+		if err != nil {
+			var proxyErr *proxy.Error
+			if !errors.As(err, &proxyErr) {
+				proxyErr = &proxy.Error{Status: http.StatusBadGateway, Code: errorCodeUpstreamUnavailable, Message: "cannot count the logs", Err: err}
+			}
+			writeProxyError(w, r, proxyErr)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(volumeResponse{
+			Start:    req.Start.UnixMilli(),
+			End:      req.End.UnixMilli(),
+			Interval: req.Interval.Milliseconds(),
+			Series:   series,
+		})
+	})
+}
+
+// volumeRequest returns the buckets of the start to end range, the range is
+// extended to whole buckets
+func volumeRequest(start time.Time, end time.Time) proxy.VolumeRequest {
+	interval := volumeIntervals[len(volumeIntervals)-1]
+	for _, candidate := range volumeIntervals {
+		if end.Sub(start) <= candidate*volumeBuckets {
+			interval = candidate
+			break
+		}
+	}
+
+	req := proxy.VolumeRequest{Start: start.Truncate(interval), End: end.Truncate(interval), Interval: interval}
+	if req.End.Before(end) {
+		req.End = req.End.Add(interval)
+	}
+	return req
+}
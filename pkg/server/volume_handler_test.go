@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVolumeHandler(t *testing.T) {
+	queries := make(chan url.Values, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries <- r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[
+{"metric":{"level":"info"},"values":[[1700000040,"3"],[1700000160,"1"]]},
+{"metric":{"level":"error"},"values":[[1700000100,"2"]]}]}}`))
+	}))
+	defer upstream.Close()
+
+	pluginConfig, err := parsePluginConfig([]byte(fmt.Sprintf("lokiURL: %s", upstream.URL)))
+	require.NoError(t, err)
+	ds, _ := pluginConfig.defaultDatasource()
+	handler := volumeHandler(ds, pluginConfig, routeDeps{})
+
+	params := url.Values{"query": {`{app="api"} |= "GET"`}, "start": {"1699999980"}, "end": {"1700003580"}}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application?"+params.Encode(), nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	upstreamParams := <-queries
+	require.Equal(t, `sum by (level) (count_over_time({app="api"} |= "GET" [60s]))`, upstreamParams.Get("query"))
+	require.Equal(t, "60", upstreamParams.Get("step"))
+	require.Equal(t, "1700000040000000000", upstreamParams.Get("start"))
+
+	response := volumeResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, int64(1699999980000), response.Start)
+	require.Equal(t, int64(1700003580000), response.End)
+	require.Equal(t, int64(60000), response.Interval)
+	require.Len(t, response.Series, 2)
+	require.Equal(t, map[string]string{"level": "error"}, response.Series[0].Labels)
+	require.Equal(t, int64(2), response.Series[0].Total)
+	require.Equal(t, int64(2), response.Series[0].Counts[1])
+	require.Equal(t, map[string]string{"level": "info"}, response.Series[1].Labels)
+	require.Equal(t, int64(4), response.Series[1].Total)
+	require.Len(t, response.Series[1].Counts, 60)
+	require.Equal(t, []int64{3, 0, 1}, response.Series[1].Counts[:3])
+
+	for _, params := range []url.Values{
+		{"query": {`count_over_time({app="api"}[5m])`}},
+		{"query": {`{app="api"}`}, "groupBy": {"le-vel"}},
+		{"query": {`{app="api"}`}, "start": {"1700000001"}, "end": {"1699999999"}},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/application?"+params.Encode(), nil))
+		require.Equal(t, http.StatusBadRequest, w.Code, params.Encode())
+	}
+}
+
+func TestVolumeRequest(t *testing.T) {
+	end := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+
+	tests := []struct {
+		rng              time.Duration
+		expectedInterval time.Duration
+	}{
+		{rng: 30 * time.Second, expectedInterval: time.Second},
+		{rng: time.Hour, expectedInterval: time.Minute},
+		{rng: 6 * time.Hour, expectedInterval: 10 * time.Minute},
+		{rng: 7 * 24 * time.Hour, expectedInterval: 3 * time.Hour},
+		{rng: 365 * 24 * time.Hour, expectedInterval: 7 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rng.String(), func(t *testing.T) {
+			req := volumeRequest(end.Add(-tt.rng), end)
+			require.Equal(t, tt.expectedInterval, req.Interval)
+			require.False(t, req.Start.After(end.Add(-tt.rng)))
+			require.False(t, req.End.Before(end))
+			require.Zero(t, req.End.Sub(req.Start)%req.Interval)
+		})
+	}
+}
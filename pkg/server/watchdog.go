@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// watchdogCheckInterval is the time between two checks of the watchdog
+	watchdogCheckInterval = 5 * time.Second
+	// minWatchdogTimeout leaves the config watchers, which sync every
+	// pluginConfigCheckInterval or configMapWatchTimeout, time to sync
+	minWatchdogTimeout = time.Minute
+)
+
+// WatchdogConfig sets the watchdog failing the liveness probe when a
+// subsystem of the plugin is stuck, so that the pod is restarted instead of
+// serving stale data: the plugin config watcher not syncing, a lock of the
+// caches held too long or the goroutines not being scheduled
+type WatchdogConfig struct {
+	// Disabled turns the watchdog off
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	// Timeout is the time after which a silent config watcher or a held lock
+	// is stuck, 2m by default
+	Timeout Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// MaxSchedulingDelay is the delay of the checks of the watchdog after
+	// which the goroutines are starved, 10s by default
+	MaxSchedulingDelay Duration `yaml:"maxSchedulingDelay,omitempty" json:"maxSchedulingDelay,omitempty"`
+}
+
+var defaultWatchdogConfig = WatchdogConfig{
+	Timeout:            Duration{2 * time.Minute},
+	MaxSchedulingDelay: Duration{10 * time.Second},
+}
+
+func (c WatchdogConfig) validate() ConfigValidationErrors {
+	errs := ConfigValidationErrors{}
+	if c.Timeout.Duration != 0 && c.Timeout.Duration < minWatchdogTimeout {
+		errs = append(errs, ConfigValidationError{Field: "watchdog.timeout", Message: fmt.Sprintf("timeout must be at least %s", minWatchdogTimeout)})
+	}
+	if c.MaxSchedulingDelay.Duration < 0 {
+		errs = append(errs, ConfigValidationError{Field: "watchdog.maxSchedulingDelay", Message: "maxSchedulingDelay cannot be negative"})
+	}
+	return errs
+}
+
+// heartbeat is beaten by a loop of a subsystem on each iteration, a nil
+// heartbeat does nothing
+type heartbeat struct {
+	// last is the time of the last beat in Unix nanoseconds
+	last int64
+	now  func() time.Time
+}
+
+func (h *heartbeat) beat() {
+	if h != nil {
+		atomic.StoreInt64(&h.last, h.now().UnixNano())
+	}
+}
+
+// watchdog finds the stuck subsystems, a nil watchdog finds none
+type watchdog struct {
+	cfg WatchdogConfig
+	now func() time.Time
+
+	mu         sync.Mutex
+	heartbeats map[string]*heartbeat
+	locks      map[string]sync.Locker
+	// probes are the start times of the lock probes in flight
+	probes map[string]time.Time
+	// interval is the time between two checks, lastCheck and
+	// schedulingDelay are the time and the delay of the last check
+	interval        time.Duration
+	lastCheck       time.Time
+	schedulingDelay time.Duration
+	// reported are the problems of the last check, logged when they change
+	reported string
+}
+
+// newWatchdog returns the watchdog of cfg, nil when disabled
+func newWatchdog(cfg WatchdogConfig) *watchdog {
+	if cfg.Disabled {
+		return nil
+	}
+	return &watchdog{
+		cfg:        cfg,
+		now:        time.Now,
+		heartbeats: map[string]*heartbeat{},
+		locks:      map[string]sync.Locker{},
+		probes:     map[string]time.Time{},
+	}
+}
+
+// heartbeat returns the heartbeat of the subsystem name, it is stuck when it
+// is not beaten within the timeout
+func (w *watchdog) heartbeat(name string) *heartbeat {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	h := &heartbeat{last: w.now().UnixNano(), now: w.now}
+	w.heartbeats[name] = h
+	return h
+}
+
+// watchLock probes lock on each check, it is stuck when it cannot be
+// acquired within the timeout. The probes of the RWMutexes take their
+// RLocker, so that a waiting probe does not block the readers
+func (w *watchdog) watchLock(name string, lock sync.Locker) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.locks[name] = lock
+}
+
+// run checks the subsystems every interval until ctx is done
+func (w *watchdog) run(ctx context.Context, interval time.Duration) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	w.interval = interval
+	w.lastCheck = w.now()
+	w.mu.Unlock()
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		w.check()
+		problems := strings.Join(w.problems(), ", ")
+		if reported := w.swapReported(problems); problems != reported {
+			if problems == "" {
+				slog.Info("watchdog: the stuck subsystems recovered")
+			} else {
+				slog.Errorf("watchdog: %s, failing the liveness probe", problems)
+			}
+		}
+		timer.Reset(interval)
+	}
+}
+
+// swapReported returns the problems of the previous check and replaces them
+// with problems
+func (w *watchdog) swapReported(problems string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	reported := w.reported
+	w.reported = problems
+	return reported
+}
+
+// check measures the scheduling delay of the check expected an interval
+// after the previous one, and starts the probes of the locks without one in
+// flight
+func (w *watchdog) check() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	w.schedulingDelay = now.Sub(w.lastCheck) - w.interval
+	w.lastCheck = now
+
+	for name, lock := range w.locks {
+		if _, inFlight := w.probes[name]; inFlight {
+			continue
+		}
+		w.probes[name] = now
+		go func(name string, lock sync.Locker) {
+			lock.Lock()
+			lock.Unlock()
+			w.mu.Lock()
+			delete(w.probes, name)
+			w.mu.Unlock()
+		}(name, lock)
+	}
+}
+
+// problems describes the stuck subsystems, sorted, none when every
+// subsystem is alive
+func (w *watchdog) problems() []string {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	timeout := w.cfg.Timeout.Duration
+	problems := []string{}
+
+	for name, h := range w.heartbeats {
+		last := time.Unix(0, atomic.LoadInt64(&h.last))
+		if silence := now.Sub(last); silence > timeout {
+			problems = append(problems, fmt.Sprintf("%s has not synced for %s", name, silence.Round(time.Second)))
+		}
+	}
+	for name, started := range w.probes {
+		if held := now.Sub(started); held > timeout {
+			problems = append(problems, fmt.Sprintf("the %s lock is held for more than %s", name, held.Round(time.Second)))
+		}
+	}
+	if maxDelay := w.cfg.MaxSchedulingDelay.Duration; maxDelay > 0 && w.schedulingDelay > maxDelay {
+		problems = append(problems, fmt.Sprintf("the goroutines were not scheduled for %s", w.schedulingDelay.Round(time.Millisecond)))
+	}
+	if late := now.Sub(w.lastCheck) - w.interval; !w.lastCheck.IsZero() && late > timeout {
+		problems = append(problems, fmt.Sprintf("the watchdog has not run for %s", late.Round(time.Second)))
+	}
+
+	sort.Strings(problems)
+	return problems
+}
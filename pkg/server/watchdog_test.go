@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdog(t *testing.T) {
+	now := time.Now()
+	w := newWatchdog(defaultWatchdogConfig)
+	w.now = func() time.Time { return now }
+	w.interval = watchdogCheckInterval
+	w.lastCheck = now
+
+	configWatcher := w.heartbeat("the plugin config watcher")
+	var lock sync.Mutex
+	w.watchLock("token cache", &lock)
+	require.Empty(t, w.problems())
+
+	// the lock probe waits while the lock is held
+	lock.Lock()
+	now = now.Add(watchdogCheckInterval)
+	w.check()
+
+	now = now.Add(3 * time.Minute)
+	require.Equal(t, []string{
+		"the plugin config watcher has not synced for 3m5s",
+		"the token cache lock is held for more than 3m0s",
+		"the watchdog has not run for 2m55s",
+	}, w.problems())
+
+	lock.Unlock()
+	require.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return len(w.probes) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// the late check reports the starved goroutines until the next one
+	configWatcher.beat()
+	w.check()
+	require.Equal(t, []string{"the goroutines were not scheduled for 2m55s"}, w.problems())
+
+	now = now.Add(watchdogCheckInterval)
+	w.check()
+	require.Empty(t, w.problems())
+
+	require.Nil(t, newWatchdog(WatchdogConfig{Disabled: true}))
+	require.Empty(t, (*watchdog)(nil).problems())
+}
+
+func TestHealthHandlerWatchdog(t *testing.T) {
+	now := time.Now()
+	w := newWatchdog(defaultWatchdogConfig)
+	w.now = func() time.Time { return now }
+	w.heartbeat("the plugin config watcher")
+
+	rec := httptest.NewRecorder()
+	healthHandler(w).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "ok", rec.Body.String())
+
+	now = now.Add(3 * time.Minute)
+	rec = httptest.NewRecorder()
+	healthHandler(w).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "the plugin config watcher has not synced for 3m0s")
+}
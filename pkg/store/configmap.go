@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+)
+
+// configMapDocument is a JSON document stored in a key of a ConfigMap, the
+// resource version of the ConfigMap is the version of the document
+type configMapDocument struct {
+	client    *kube.Client
+	namespace string
+	name      string
+	key       string
+	component string
+}
+
+// load decodes the document in v and returns its version, v is left
+// unchanged and the version is empty when the ConfigMap does not exist
+func (d *configMapDocument) load(ctx context.Context, v interface{}) (string, error) {
+	configMap, err := d.client.GetConfigMap(ctx, d.namespace, d.name)
+	if kube.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot read ConfigMap %s/%s: %w", d.namespace, d.name, err)
+	}
+
+	if data, ok := configMap.Data[d.key]; ok {
+		if err := json.Unmarshal([]byte(data), v); err != nil {
+			return "", fmt.Errorf("cannot decode %s of ConfigMap %s/%s: %w", d.key, d.namespace, d.name, err)
+		}
+	}
+
+	return configMap.Metadata.ResourceVersion, nil
+}
+
+// save replaces the document when it is still at version, the ConfigMap is
+// created when version is empty. It returns ErrConflict otherwise
+func (d *configMapDocument) save(ctx context.Context, v interface{}, version string) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	configMap := &kube.ConfigMap{
+		Metadata: kube.ObjectMeta{
+			Name:            d.name,
+			Namespace:       d.namespace,
+			ResourceVersion: version,
+			Labels:          map[string]string{"app.kubernetes.io/component": d.component},
+		},
+		Data: map[string]string{d.key: string(data)},
+	}
+
+	if version == "" {
+		_, err = d.client.CreateConfigMap(ctx, configMap)
+	} else {
+		_, err = d.client.UpdateConfigMap(ctx, configMap)
+	}
+	if kube.IsConflict(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("cannot save ConfigMap %s/%s: %w", d.namespace, d.name, err)
+	}
+
+	return nil
+}
+
+// ConfigMapBackend stores the queries as JSON in a ConfigMap
+type ConfigMapBackend struct {
+	doc configMapDocument
+}
+
+// NewConfigMapBackend builds a backend storing the queries in the ConfigMap
+// name of namespace, it is created with the first saved query
+func NewConfigMapBackend(client *kube.Client, namespace string, name string) *ConfigMapBackend {
+	return &ConfigMapBackend{doc: configMapDocument{client: client, namespace: namespace, name: name, key: "queries.json", component: "saved-queries"}}
+}
+
+func (b *ConfigMapBackend) Load(ctx context.Context) ([]Query, string, error) {
+	queries := []Query{}
+	version, err := b.doc.load(ctx, &queries)
+	if err != nil {
+		return nil, "", err
+	}
+	return queries, version, nil
+}
+
+func (b *ConfigMapBackend) Save(ctx context.Context, queries []Query, version string) error {
+	return b.doc.save(ctx, queries, version)
+}
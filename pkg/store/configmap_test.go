@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConfigMapServer serves the ConfigMaps of the openshift-logging
+// namespace, the updates with an outdated resource version conflict
+type fakeConfigMapServer struct {
+	mu         sync.Mutex
+	configMaps map[string]*kube.ConfigMap
+	version    int
+}
+
+func newFakeConfigMapClient(t *testing.T) (*kube.Client, *fakeConfigMapServer) {
+	f := &fakeConfigMapServer{configMaps: map[string]*kube.ConfigMap{}}
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		const path = "/api/v1/namespaces/openshift-logging/configmaps"
+		name := r.URL.Path[len(path):]
+		if len(name) > 0 {
+			name = name[1:]
+		}
+
+		configMap := &kube.ConfigMap{}
+		if r.Method != http.MethodGet {
+			body, _ := io.ReadAll(r.Body)
+			require.NoError(t, json.Unmarshal(body, configMap))
+			name = configMap.Metadata.Name
+		}
+		existing, exists := f.configMaps[name]
+
+		switch {
+		case r.Method == http.MethodGet && exists:
+			json.NewEncoder(w).Encode(existing)
+			return
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","reason":"NotFound","message":"configmaps \"` + name + `\" not found"}`))
+			return
+		case r.Method == http.MethodPost && exists, r.Method == http.MethodPut && (!exists || existing.Metadata.ResourceVersion != configMap.Metadata.ResourceVersion):
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"kind":"Status","reason":"Conflict","message":"the object has been modified"}`))
+			return
+		}
+
+		f.version++
+		configMap.Metadata.ResourceVersion = strconv.Itoa(f.version)
+		f.configMaps[name] = configMap
+		json.NewEncoder(w).Encode(configMap)
+	}))
+	t.Cleanup(apiServer.Close)
+
+	return kube.NewClient(apiServer.URL, "", apiServer.Client()), f
+}
+
+func TestConfigMapBackend(t *testing.T) {
+	ctx := context.Background()
+	client, f := newFakeConfigMapClient(t)
+	backend := NewConfigMapBackend(client, "openshift-logging", "saved-queries")
+
+	queries, version, err := backend.Load(ctx)
+	require.NoError(t, err)
+	require.Empty(t, queries)
+	require.Equal(t, "", version)
+
+	require.NoError(t, backend.Save(ctx, []Query{{ID: "1", Name: "errors", Owner: "alice"}}, version))
+	require.Equal(t, "ConfigMap", f.configMaps["saved-queries"].Kind)
+
+	queries, version, err = backend.Load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Query{{ID: "1", Name: "errors", Owner: "alice"}}, queries)
+	require.Equal(t, "1", version)
+
+	require.NoError(t, backend.Save(ctx, []Query{}, version))
+	require.ErrorIs(t, backend.Save(ctx, []Query{}, version), ErrConflict)
+}
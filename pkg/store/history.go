@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openshift/logging-view-plugin/pkg/kube"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("module", "store")
+
+// HistoryEntry is a query run by a user
+type HistoryEntry struct {
+	User     string     `json:"user"`
+	Query    string     `json:"query"`
+	Tenant   string     `json:"tenant"`
+	Endpoint string     `json:"endpoint"`
+	Start    *time.Time `json:"start,omitempty"`
+	End      *time.Time `json:"end,omitempty"`
+	// Time is when the query was run
+	Time            time.Time `json:"time"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Status          int       `json:"status"`
+	// Results is the number of entries or series returned when known
+	Results        *int   `json:"results,omitempty"`
+	BytesProcessed *int64 `json:"bytesProcessed,omitempty"`
+}
+
+// History keeps the last queries of every user in memory, they are persisted
+// in a ConfigMap when the history is built with NewConfigMapHistory
+type History struct {
+	mu         sync.Mutex
+	maxPerUser int
+	// entries holds the entries of each user from the oldest to the newest
+	entries map[string][]HistoryEntry
+	dirty   bool
+	doc     *configMapDocument
+	version string
+}
+
+// NewHistory builds an in-memory history of up to maxPerUser queries per user
+func NewHistory(maxPerUser int) *History {
+	return &History{maxPerUser: maxPerUser, entries: map[string][]HistoryEntry{}}
+}
+
+// NewConfigMapHistory builds a history persisted in the ConfigMap name of
+// namespace by Sync
+func NewConfigMapHistory(maxPerUser int, client *kube.Client, namespace string, name string) *History {
+	h := NewHistory(maxPerUser)
+	h.doc = &configMapDocument{client: client, namespace: namespace, name: name, key: "history.json", component: "query-history"}
+	return h
+}
+
+// Record adds the query entry of a user, the oldest entries beyond the limit
+// are dropped
+func (h *History) Record(entry HistoryEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := append(h.entries[entry.User], entry)
+	if len(entries) > h.maxPerUser {
+		entries = entries[len(entries)-h.maxPerUser:]
+	}
+	h.entries[entry.User] = entries
+	h.dirty = true
+}
+
+// List returns the queries of user from the newest to the oldest
+func (h *History) List(user string) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := h.entries[user]
+	listed := make([]HistoryEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		listed = append(listed, entries[i])
+	}
+	return listed
+}
+
+// Slowest returns the limit slowest queries of all the users
+func (h *History) Slowest(limit int) []HistoryEntry {
+	h.mu.Lock()
+	all := []HistoryEntry{}
+	for _, entries := range h.entries {
+		all = append(all, entries...)
+	}
+	h.mu.Unlock()
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].DurationSeconds > all[j].DurationSeconds })
+	if len(all) > limit {
+		all = all[:limit]
+	}
+	return all
+}
+
+// Load reads the persisted history, the recorded entries are kept
+func (h *History) Load(ctx context.Context) error {
+	if h.doc == nil {
+		return nil
+	}
+
+	persisted := map[string][]HistoryEntry{}
+	version, err := h.doc.load(ctx, &persisted)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.merge(persisted)
+	h.version = version
+	return nil
+}
+
+// Sync persists the entries recorded since the last sync, the entries
+// persisted by other replicas in between are merged
+func (h *History) Sync(ctx context.Context) error {
+	if h.doc == nil {
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		h.mu.Lock()
+		if !h.dirty {
+			h.mu.Unlock()
+			return nil
+		}
+		snapshot := make(map[string][]HistoryEntry, len(h.entries))
+		for user, entries := range h.entries {
+			snapshot[user] = append([]HistoryEntry{}, entries...)
+		}
+		version := h.version
+		h.dirty = false
+		h.mu.Unlock()
+
+		err := h.doc.save(ctx, snapshot, version)
+		if err == nil {
+			// refresh the version of the saved ConfigMap
+			return h.Load(ctx)
+		}
+
+		h.mu.Lock()
+		h.dirty = true
+		h.mu.Unlock()
+
+		if !errors.Is(err, ErrConflict) || attempt == maxUpdateAttempts {
+			return err
+		}
+		if err := h.Load(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// Run syncs the history every interval until ctx is done, then syncs it a
+// last time
+func (h *History) Run(ctx context.Context, interval time.Duration) {
+	if h.doc == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := h.Sync(ctx); err != nil {
+				log.WithError(err).Warn("cannot persist query history")
+			}
+		case <-ctx.Done():
+			syncCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := h.Sync(syncCtx); err != nil {
+				log.WithError(err).Warn("cannot persist query history")
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+// merge adds the entries missing from the history, h.mu must be held
+func (h *History) merge(entries map[string][]HistoryEntry) {
+	for user, persisted := range entries {
+		seen := map[historyKey]bool{}
+		merged := []HistoryEntry{}
+		for _, entry := range append(append([]HistoryEntry{}, h.entries[user]...), persisted...) {
+			key := historyKey{time: entry.Time.UnixNano(), query: entry.Query}
+			if !seen[key] {
+				seen[key] = true
+				merged = append(merged, entry)
+			}
+		}
+
+		sort.SliceStable(merged, func(i, j int) bool { return merged[i].Time.Before(merged[j].Time) })
+		if len(merged) > h.maxPerUser {
+			merged = merged[len(merged)-h.maxPerUser:]
+		}
+		h.entries[user] = merged
+	}
+}
+
+type historyKey struct {
+	time  int64
+	query string
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func historyQueries(entries []HistoryEntry) []string {
+	queries := []string{}
+	for _, entry := range entries {
+		queries = append(queries, entry.Query)
+	}
+	return queries
+}
+
+func TestHistory(t *testing.T) {
+	h := NewHistory(2)
+	now := time.Now()
+
+	h.Record(HistoryEntry{User: "alice", Query: "a1", Time: now, DurationSeconds: 1})
+	h.Record(HistoryEntry{User: "alice", Query: "a2", Time: now.Add(time.Second), DurationSeconds: 5})
+	h.Record(HistoryEntry{User: "alice", Query: "a3", Time: now.Add(2 * time.Second), DurationSeconds: 2})
+	h.Record(HistoryEntry{User: "bob", Query: "b1", Time: now, DurationSeconds: 3})
+
+	require.Equal(t, []string{"a3", "a2"}, historyQueries(h.List("alice")))
+	require.Equal(t, []string{"b1"}, historyQueries(h.List("bob")))
+	require.Empty(t, h.List("carol"))
+
+	require.Equal(t, []string{"a2", "b1"}, historyQueries(h.Slowest(2)))
+}
+
+func TestConfigMapHistory(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeConfigMapClient(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	replica1 := NewConfigMapHistory(3, client, "openshift-logging", "query-history")
+	replica2 := NewConfigMapHistory(3, client, "openshift-logging", "query-history")
+	require.NoError(t, replica1.Load(ctx))
+	require.NoError(t, replica2.Load(ctx))
+
+	replica1.Record(HistoryEntry{User: "alice", Query: "a1", Time: now})
+	replica2.Record(HistoryEntry{User: "alice", Query: "a2", Time: now.Add(time.Second)})
+	replica2.Record(HistoryEntry{User: "bob", Query: "b1", Time: now})
+
+	require.NoError(t, replica1.Sync(ctx))
+	// the ConfigMap changed since replica2 loaded it
+	require.NoError(t, replica2.Sync(ctx))
+
+	restarted := NewConfigMapHistory(3, client, "openshift-logging", "query-history")
+	require.NoError(t, restarted.Load(ctx))
+	require.Equal(t, []string{"a2", "a1"}, historyQueries(restarted.List("alice")))
+	require.Equal(t, []string{"b1"}, historyQueries(restarted.List("bob")))
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// MemoryBackend keeps the queries in memory, they are lost on restart
+type MemoryBackend struct {
+	mu      sync.Mutex
+	queries []Query
+	version int
+}
+
+// NewMemoryBackend builds an empty in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{}
+}
+
+func (b *MemoryBackend) Load(_ context.Context) ([]Query, string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Query{}, b.queries...), strconv.Itoa(b.version), nil
+}
+
+func (b *MemoryBackend) Save(_ context.Context, queries []Query, version string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if version != strconv.Itoa(b.version) {
+		return ErrConflict
+	}
+	b.queries = append([]Query{}, queries...)
+	b.version++
+	return nil
+}
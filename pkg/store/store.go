@@ -0,0 +1,167 @@
+// Package store persists the LogQL queries saved by the users
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// maxUpdateAttempts bounds the retries of the updates conflicting with a
+// concurrent one
+const maxUpdateAttempts = 5
+
+var (
+	// ErrConflict is returned by Backend.Save when the queries were changed
+	// since they were loaded
+	ErrConflict = errors.New("the saved queries were updated concurrently")
+	// ErrNotFound is returned when deleting a query that does not exist
+	ErrNotFound = errors.New("saved query not found")
+	// ErrForbidden is returned when deleting the query of another user
+	ErrForbidden = errors.New("only the owner of a saved query can delete it")
+	// ErrLimitReached is returned when a user has too many saved queries
+	ErrLimitReached = errors.New("too many saved queries")
+)
+
+// Query is a LogQL query saved by a user, it is shared with the members of
+// Groups
+type Query struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Owner     string    `json:"owner"`
+	Groups    []string  `json:"groups,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// visibleTo returns true when the query is owned by user or shared with one
+// of groups
+func (q *Query) visibleTo(user string, groups []string) bool {
+	if q.Owner == user {
+		return true
+	}
+	for _, shared := range q.Groups {
+		for _, group := range groups {
+			if shared == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Backend loads and saves all the queries at once
+type Backend interface {
+	// Load returns the saved queries and their version
+	Load(ctx context.Context) ([]Query, string, error)
+	// Save replaces the queries when they are still at version, it returns
+	// ErrConflict otherwise
+	Save(ctx context.Context, queries []Query, version string) error
+}
+
+// Store manages the saved queries of the users
+type Store struct {
+	backend    Backend
+	maxPerUser int
+	now        func() time.Time
+}
+
+// New builds a store keeping up to maxPerUser queries per user, unlimited if
+// 0
+func New(backend Backend, maxPerUser int) *Store {
+	return &Store{backend: backend, maxPerUser: maxPerUser, now: time.Now}
+}
+
+// List returns the queries owned by user or shared with its groups sorted by
+// name
+func (s *Store) List(ctx context.Context, user string, groups []string) ([]Query, error) {
+	queries, _, err := s.backend.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := []Query{}
+	for _, q := range queries {
+		if q.visibleTo(user, groups) {
+			visible = append(visible, q)
+		}
+	}
+	sort.SliceStable(visible, func(i, j int) bool { return visible[i].Name < visible[j].Name })
+
+	return visible, nil
+}
+
+// Add saves q with a new ID and creation time
+func (s *Store) Add(ctx context.Context, q Query) (Query, error) {
+	id, err := newID()
+	if err != nil {
+		return Query{}, err
+	}
+	q.ID = id
+	q.CreatedAt = s.now().UTC()
+
+	err = s.update(ctx, func(queries []Query) ([]Query, error) {
+		owned := 0
+		for _, existing := range queries {
+			if existing.Owner == q.Owner {
+				owned++
+			}
+		}
+		if s.maxPerUser > 0 && owned >= s.maxPerUser {
+			return nil, fmt.Errorf("%w, up to %d queries can be saved", ErrLimitReached, s.maxPerUser)
+		}
+		return append(queries, q), nil
+	})
+
+	return q, err
+}
+
+// Delete removes the query id owned by user
+func (s *Store) Delete(ctx context.Context, user string, id string) error {
+	return s.update(ctx, func(queries []Query) ([]Query, error) {
+		for i, q := range queries {
+			if q.ID != id {
+				continue
+			}
+			if q.Owner != user {
+				return nil, ErrForbidden
+			}
+			return append(queries[:i:i], queries[i+1:]...), nil
+		}
+		return nil, ErrNotFound
+	})
+}
+
+// update applies fn to the saved queries, it is retried when another update
+// happened in between
+func (s *Store) update(ctx context.Context, fn func([]Query) ([]Query, error)) error {
+	for attempt := 1; ; attempt++ {
+		queries, version, err := s.backend.Load(ctx)
+		if err != nil {
+			return err
+		}
+
+		updated, err := fn(queries)
+		if err != nil {
+			return err
+		}
+
+		err = s.backend.Save(ctx, updated, version)
+		if !errors.Is(err, ErrConflict) || attempt == maxUpdateAttempts {
+			return err
+		}
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("cannot generate query ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
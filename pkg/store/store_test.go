@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s := New(NewMemoryBackend(), 2)
+
+	saved, err := s.Add(ctx, Query{Name: "errors", Query: `{app="api"} |= "error"`, Owner: "alice", Groups: []string{"team-a"}})
+	require.NoError(t, err)
+	require.NotEmpty(t, saved.ID)
+	require.False(t, saved.CreatedAt.IsZero())
+
+	_, err = s.Add(ctx, Query{Name: "all", Query: `{app="api"}`, Owner: "alice"})
+	require.NoError(t, err)
+	_, err = s.Add(ctx, Query{Name: "db", Query: `{app="db"}`, Owner: "bob"})
+	require.NoError(t, err)
+
+	_, err = s.Add(ctx, Query{Name: "third", Query: `{app="web"}`, Owner: "alice"})
+	require.ErrorIs(t, err, ErrLimitReached)
+
+	tests := []struct {
+		name          string
+		user          string
+		groups        []string
+		expectedNames []string
+	}{
+		{name: "owner", user: "alice", expectedNames: []string{"all", "errors"}},
+		{name: "group member", user: "bob", groups: []string{"team-a"}, expectedNames: []string{"db", "errors"}},
+		{name: "other user", user: "carol", groups: []string{"team-b"}, expectedNames: []string{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			queries, err := s.List(ctx, tc.user, tc.groups)
+			require.NoError(t, err)
+			names := []string{}
+			for _, q := range queries {
+				names = append(names, q.Name)
+			}
+			require.Equal(t, tc.expectedNames, names)
+		})
+	}
+
+	require.ErrorIs(t, s.Delete(ctx, "bob", saved.ID), ErrForbidden)
+	require.ErrorIs(t, s.Delete(ctx, "alice", "missing"), ErrNotFound)
+	require.NoError(t, s.Delete(ctx, "alice", saved.ID))
+
+	queries, err := s.List(ctx, "bob", []string{"team-a"})
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+}
+
+// conflictingBackend fails the first saves with a conflict
+type conflictingBackend struct {
+	*MemoryBackend
+	conflicts int
+}
+
+func (b *conflictingBackend) Save(ctx context.Context, queries []Query, version string) error {
+	if b.conflicts > 0 {
+		b.conflicts--
+		return ErrConflict
+	}
+	return b.MemoryBackend.Save(ctx, queries, version)
+}
+
+func TestStoreConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	s := New(&conflictingBackend{MemoryBackend: NewMemoryBackend(), conflicts: 2}, 0)
+	_, err := s.Add(ctx, Query{Name: "errors", Owner: "alice"})
+	require.NoError(t, err)
+
+	s = New(&conflictingBackend{MemoryBackend: NewMemoryBackend(), conflicts: maxUpdateAttempts}, 0)
+	_, err = s.Add(ctx, Query{Name: "errors", Owner: "alice"})
+	require.ErrorIs(t, err, ErrConflict)
+}
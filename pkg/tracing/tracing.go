@@ -0,0 +1,227 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("module", "tracing")
+
+// TraceParentHeader is the W3C trace context header propagated to and from
+// the other services
+const TraceParentHeader = "traceparent"
+
+var traceParentRegexp = regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// Config holds the OTLP exporter settings
+type Config struct {
+	// Endpoint is the OTLP/HTTP collector base URL, the spans are sent to
+	// <Endpoint>/v1/traces unless it already has a path
+	Endpoint string
+	// ServiceName is the service.name resource attribute of the spans
+	ServiceName string
+	// BatchInterval is the maximum time a span waits before being exported
+	BatchInterval time.Duration
+	// Client sends the export requests, a client with a 10s timeout if nil
+	Client *http.Client
+}
+
+// Tracer records spans and exports them in batches to an OTLP/HTTP
+// collector. A nil Tracer records nothing
+type Tracer struct {
+	exporter *exporter
+}
+
+// Attribute is a span attribute, the value is a string, an int64 or a bool
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is a timed operation of a trace. The methods of a nil Span do nothing
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes []Attribute
+	err        string
+	ended      bool
+}
+
+// span kinds of the OTLP protocol
+const (
+	KindServer = 2
+	KindClient = 3
+)
+
+type spanKey struct{}
+
+// New builds a tracer exporting to cfg.Endpoint, Shutdown flushes the
+// pending spans
+func New(cfg Config) (*Tracer, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, an absolute http or https URL is expected", cfg.Endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "logging-view-plugin"
+	}
+	if cfg.BatchInterval <= 0 {
+		cfg.BatchInterval = 5 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Tracer{exporter: newExporter(u.String(), cfg)}, nil
+}
+
+// Shutdown exports the pending spans and stops the tracer
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.shutdown(ctx)
+}
+
+// Start begins a span named name, child of the span of ctx if any
+func (t *Tracer) Start(ctx context.Context, name string, kind int, attributes ...Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now(), attributes: attributes}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(remoteParent); ok {
+		s.traceID = remote.traceID
+		s.parentID = remote.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SpanFromContext returns the current span of ctx, nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attributes = append(s.attributes, attributes...)
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed with the message of err
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+// End completes the span and queues it for export, only the first call has an
+// effect
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	s.tracer.exporter.enqueue(s)
+}
+
+// TraceID returns the hex encoded trace ID of the span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+type remoteKey struct{}
+
+type remoteParent struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+// Extract returns ctx with the remote parent span of the traceparent header
+// of headers, invalid headers are ignored
+func Extract(ctx context.Context, headers http.Header) context.Context {
+	match := traceParentRegexp.FindStringSubmatch(headers.Get(TraceParentHeader))
+	if match == nil {
+		return ctx
+	}
+
+	var parent remoteParent
+	hex.Decode(parent.traceID[:], []byte(match[1]))
+	hex.Decode(parent.spanID[:], []byte(match[2]))
+	if parent.traceID == [16]byte{} || parent.spanID == [8]byte{} {
+		return ctx
+	}
+
+	return context.WithValue(ctx, remoteKey{}, parent)
+}
+
+// Inject sets the traceparent header of the current span of ctx in headers
+func Inject(ctx context.Context, headers http.Header) {
+	s := SpanFromContext(ctx)
+	if s == nil {
+		return
+	}
+	headers.Set(TraceParentHeader, fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:])))
+}
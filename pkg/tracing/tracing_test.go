@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracerExportsSpans(t *testing.T) {
+	requests := make(chan otlpRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/traces", r.URL.Path)
+		var request otlpRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests <- request
+	}))
+	defer collector.Close()
+
+	tracer, err := New(Config{Endpoint: collector.URL, BatchInterval: time.Hour})
+	require.NoError(t, err)
+
+	headers := http.Header{}
+	headers.Set(TraceParentHeader, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	ctx := Extract(context.Background(), headers)
+
+	ctx, parent := tracer.Start(ctx, "GET /api/proxy/", KindServer)
+	childCtx, child := tracer.Start(ctx, "loki /loki/api/v1/query_range", KindClient, String("loki.tenant", "application"), Int("loki.query.range_seconds", 3600))
+
+	upstreamHeaders := http.Header{}
+	Inject(childCtx, upstreamHeaders)
+	require.Regexp(t, `^00-0af7651916cd43dd8448eb211c80319c-[0-9a-f]{16}-01$`, upstreamHeaders.Get(TraceParentHeader))
+
+	child.SetError(errors.New("upstream timeout"))
+	child.End()
+	parent.End()
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	request := <-requests
+	require.Len(t, request.ResourceSpans, 1)
+	require.Equal(t, "logging-view-plugin", *request.ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+	require.Equal(t, "loki /loki/api/v1/query_range", spans[0].Name)
+	require.Equal(t, "0af7651916cd43dd8448eb211c80319c", spans[0].TraceID)
+	require.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	require.Equal(t, "b7ad6b7169203331", spans[1].ParentSpanID)
+	require.Equal(t, 2, spans[0].Status.Code)
+	require.Equal(t, "3600", *spans[0].Attributes[1].Value.IntValue)
+	require.Nil(t, spans[1].Status)
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+
+	ctx, span := tracer.Start(context.Background(), "noop", KindServer)
+	span.SetAttributes(String("key", "value"))
+	span.End()
+
+	headers := http.Header{}
+	Inject(ctx, headers)
+	require.Empty(t, headers.Get(TraceParentHeader))
+	require.NoError(t, tracer.Shutdown(context.Background()))
+}
+
+func TestNewInvalidEndpoint(t *testing.T) {
+	_, err := New(Config{Endpoint: "collector:4318"})
+	require.Error(t, err)
+}
@@ -0,0 +1,59 @@
+// Package version holds the build information of the plugin backend, set at
+// build time with:
+//
+//	-ldflags "-X github.com/openshift/logging-view-plugin/pkg/version.Version=v5.8.0
+//	          -X github.com/openshift/logging-view-plugin/pkg/version.GitCommit=<sha>
+//	          -X github.com/openshift/logging-view-plugin/pkg/version.BuildDate=<RFC 3339 date>"
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+const unknown = "unknown"
+
+// set with -ldflags at build time
+var (
+	Version   = "dev"
+	GitCommit = ""
+	BuildDate = ""
+)
+
+// Info is the build information of the binary
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build information, the commit and date not set at build
+// time are read from the version control information embedded by go build
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.GitCommit == "":
+				info.GitCommit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+
+	if info.GitCommit == "" {
+		info.GitCommit = unknown
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = unknown
+	}
+	return info
+}
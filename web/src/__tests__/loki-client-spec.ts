@@ -1,4 +1,4 @@
-import { getFetchConfig } from '../loki-client';
+import { getFetchConfig, getTailURL } from '../loki-client';
 
 describe('Loki Client', () => {
   it('should generate a valid config', () => {
@@ -15,4 +15,38 @@ describe('Loki Client', () => {
       endpoint: '/api/proxy/plugin/logging-view-plugin/backend/api/logs/v1/infrastructure',
     });
   });
+
+  it('should follow the tenant routing mode', () => {
+    expect(
+      getFetchConfig({ config: { tenantRoutingMode: 'header' }, tenant: 'application' }),
+    ).toEqual({
+      endpoint: '/api/proxy/plugin/logging-view-plugin/backend',
+      requestInit: { headers: { 'X-Scope-OrgID': 'application' } },
+    });
+    expect(getFetchConfig({ config: { tenantRoutingMode: 'none' }, tenant: 'application' })).toEqual(
+      {
+        endpoint: '/api/proxy/plugin/logging-view-plugin/backend',
+      },
+    );
+    expect(
+      getFetchConfig({
+        config: { useTenantInHeader: true, tenantRoutingMode: 'path' },
+        tenant: 'application',
+      }),
+    ).toEqual({
+      endpoint: '/api/proxy/plugin/logging-view-plugin/backend/api/logs/v1/application',
+    });
+  });
+
+  it('should build the tail URLs', () => {
+    expect(getTailURL({ config: { tenantRoutingMode: 'path' }, tenant: 'application' })).toEqual(
+      '/api/proxy/plugin/logging-view-plugin/backend/api/logs/v1/application/loki/api/v1/tail',
+    );
+    expect(getTailURL({ config: { tenantRoutingMode: 'header' }, tenant: 'application' })).toEqual(
+      '/api/proxy/plugin/logging-view-plugin/backend/loki/api/v1/tail',
+    );
+    expect(
+      getTailURL({ config: { tenantRoutingMode: 'header' }, tenant: 'audit', eventSource: true }),
+    ).toEqual('/api/proxy/plugin/logging-view-plugin/plugin-backend/api/tail/sse/audit');
+  });
 });
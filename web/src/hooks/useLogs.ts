@@ -7,9 +7,16 @@ import {
   isMatrixResult,
   isStreamsResult,
   QueryRangeResponse,
+  StreamLogData,
   TimeRange,
 } from '../logs.types';
-import { connectToTailSocket, executeHistogramQuery, executeQueryRange } from '../loki-client';
+import {
+  connectToTailEventSource,
+  connectToTailSocket,
+  EventSourceTail,
+  executeHistogramQuery,
+  executeQueryRange,
+} from '../loki-client';
 import { intervalFromTimeRange, numericTimeRange, timeRangeFromDuration } from '../time-range';
 import { millisecondsFromDuration } from '../value-utils';
 
@@ -226,7 +233,9 @@ export const useLogs = (
   const currentDirection = React.useRef<Direction>(undefined);
   const logsAbort = React.useRef<() => void | undefined>();
   const histogramAbort = React.useRef<() => void | undefined>();
-  const ws = React.useRef<WSFactory | null>();
+  const ws = React.useRef<WSFactory | EventSourceTail | null>();
+  // switched to the server-sent events once a WebSocket cannot be opened
+  const tailWithEventSource = React.useRef(false);
   const [configData, configLoaded, configError] = useK8sWatchResource({
     namespace: 'logging-view',
     name: 'logging-view-config',
@@ -402,22 +411,15 @@ export const useLogs = (
 
     dispatch({ type: 'startStreaming' });
 
-    ws.current = connectToTailSocket({
+    const tailParams = {
       query,
       start,
       tenant: currentTenant.current,
       namespace,
-    });
-
-    ws.current.onerror((error) => {
-      const errorMessage = (error as ErrorEvent).message ?? 'WebSocket error';
-      dispatch({
-        type: 'logsError',
-        payload: { error: errorMessage },
-      });
-    });
+      config: currentConfig.current,
+    };
 
-    ws.current.onmessage((data) => {
+    const onMessage = (data: { streams: Array<StreamLogData> }) => {
       dispatch({
         type: 'streamingResponse',
         payload: {
@@ -431,7 +433,45 @@ export const useLogs = (
           },
         },
       });
+    };
+
+    const onError = (error: unknown) => {
+      const errorMessage = (error as ErrorEvent).message ?? 'WebSocket error';
+      dispatch({
+        type: 'logsError',
+        payload: { error: errorMessage },
+      });
+    };
+
+    const connectEventSource = () => {
+      ws.current = connectToTailEventSource(tailParams).onerror(onError).onmessage(onMessage);
+    };
+
+    if (tailWithEventSource.current) {
+      connectEventSource();
+      return;
+    }
+
+    let opened = false;
+    const socket = connectToTailSocket(tailParams);
+    ws.current = socket;
+
+    socket.onopen(() => {
+      opened = true;
     });
+
+    socket.onerror((error) => {
+      // the proxies stripping the WebSocket upgrades fail the handshake
+      if (!opened && ws.current === socket) {
+        tailWithEventSource.current = true;
+        socket.destroy();
+        connectEventSource();
+        return;
+      }
+      onError(error);
+    });
+
+    socket.onmessage(onMessage);
   };
 
   const toggleStreaming = ({
@@ -0,0 +1,40 @@
+import React from 'react';
+import { getNamespaceTenant } from '../loki-client';
+
+const DEFAULT_TENANT = 'application';
+
+/**
+ * useNamespaceTenant returns the tenant holding the logs of the namespace,
+ * resolved by the plugin backend so that the frontend and the backend agree.
+ * It is undefined until the tenant is resolved, the default tenant is used
+ * when the backend cannot resolve it
+ */
+export const useNamespaceTenant = (namespace?: string): string | undefined => {
+  const [tenant, setTenant] = React.useState<string | undefined>(
+    namespace ? undefined : DEFAULT_TENANT,
+  );
+
+  React.useEffect(() => {
+    if (!namespace) {
+      setTenant(DEFAULT_TENANT);
+      return;
+    }
+
+    setTenant(undefined);
+    const { request, abort } = getNamespaceTenant(namespace);
+    request()
+      .then((response) => setTenant(response.tenant))
+      .catch((error) => {
+        if (error instanceof DOMException && error.name === 'AbortError') {
+          return;
+        }
+        // eslint-disable-next-line no-console
+        console.warn('Cannot resolve the tenant of the namespace', namespace, error);
+        setTenant(DEFAULT_TENANT);
+      });
+
+    return abort;
+  }, [namespace]);
+
+  return tenant;
+};
@@ -1,7 +1,26 @@
+export type TenantRoutingMode = 'header' | 'path' | 'none';
+
+export type Datasource = {
+  name: string;
+  url: string;
+  useTenantInHeader?: boolean;
+  tenantRoutingMode?: TenantRoutingMode;
+  default?: boolean;
+};
+
 export type Config = {
   useTenantInHeader?: boolean;
+  tenantRoutingMode?: TenantRoutingMode;
+  datasources?: Array<Datasource>;
+  logsLimit?: number;
+  defaultQuery?: string;
+  defaultSeverityFilter?: Array<string>;
+  defaultTimeRange?: string;
+  defaultTenant?: string;
   isStreamingEnabledInDefaultPage?: boolean;
   lokiTenanLabelKey?: string;
+  alertingRuleTenantLabelKey?: string;
+  alertingRuleNamespaceLabelKey?: string;
 };
 
 export type MetricValue = Array<number | string>;
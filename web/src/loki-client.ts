@@ -1,10 +1,19 @@
 import { WSFactory } from '@openshift-console/dynamic-plugin-sdk/lib/utils/k8s/ws-factory';
 import { queryWithNamespace } from './attribute-filters';
 import { CancellableFetch, cancellableFetch, RequestInitWithTimeout } from './cancellable-fetch';
-import { Config, Direction, QueryRangeResponse, RulesResponse } from './logs.types';
+import {
+  Config,
+  Direction,
+  QueryRangeResponse,
+  RulesResponse,
+  StreamLogData,
+} from './logs.types';
 import { durationFromTimestamp } from './value-utils';
 
 const LOKI_ENDPOINT = '/api/proxy/plugin/logging-view-plugin/backend';
+// the console proxy alias of the plugin backend, for the routes served by the
+// plugin itself rather than by Loki
+const PLUGIN_BACKEND_ENDPOINT = '/api/proxy/plugin/logging-view-plugin/plugin-backend';
 
 type QueryRangeParams = {
   query: string;
@@ -35,7 +44,14 @@ export const getFetchConfig = ({
   tenant: string;
   endpoint?: string;
 }): { requestInit?: RequestInitWithTimeout; endpoint: string } => {
-  if (config && config.useTenantInHeader === true) {
+  const tenantRoutingMode =
+    config?.tenantRoutingMode ?? (config?.useTenantInHeader === true ? 'header' : 'path');
+
+  if (tenantRoutingMode === 'none') {
+    return { endpoint: LOKI_ENDPOINT };
+  }
+
+  if (tenantRoutingMode === 'header') {
     return {
       requestInit: {
         headers: { 'X-Scope-OrgID': tenant },
@@ -114,28 +130,49 @@ export const executeHistogramQuery = ({
   );
 };
 
-export const connectToTailSocket = ({
-  query,
-  start,
-  limit = 200,
-  config,
-  tenant,
-  namespace,
-}: Omit<QueryRangeParams, 'end'>) => {
+type TailParams = Omit<QueryRangeParams, 'end'>;
+
+const getTailSearchParams = ({ query, start, limit = 200, namespace }: TailParams) => {
   const extendedQuery = queryWithNamespace({
     query,
     namespace,
   });
 
-  const params = {
+  return new URLSearchParams({
     query: extendedQuery,
     start: String(start * 1000000),
     limit: String(limit),
-  };
+  });
+};
+
+/**
+ * getTailURL returns the URL of the live tail. The WebSocket stream is sent by
+ * Loki, routed with the tenantRoutingMode of the config. The server-sent
+ * events are sent by the plugin backend, whose route always has the tenant in
+ * its path: the backend routes it to Loki with the same tenantRoutingMode
+ */
+export const getTailURL = ({
+  config,
+  tenant,
+  eventSource = false,
+}: {
+  config?: Config;
+  tenant: string;
+  eventSource?: boolean;
+}): string => {
+  if (eventSource) {
+    return `${PLUGIN_BACKEND_ENDPOINT}/api/tail/sse/${tenant}`;
+  }
 
   const { endpoint } = getFetchConfig({ config, tenant });
+  return `${endpoint}/loki/api/v1/tail`;
+};
 
-  const url = `${endpoint}/loki/api/v1/tail?${new URLSearchParams(params)}`;
+export const connectToTailSocket = (params: TailParams) => {
+  const url = `${getTailURL({
+    config: params.config,
+    tenant: params.tenant,
+  })}?${getTailSearchParams(params)}`;
 
   return new WSFactory(url, {
     host: 'auto',
@@ -145,6 +182,56 @@ export const connectToTailSocket = ({
   });
 };
 
+type TailMessage = { streams: Array<StreamLogData> };
+
+/**
+ * EventSourceTail receives the tail stream as server-sent events, for the
+ * proxies stripping the WebSocket upgrades. It has the handlers of WSFactory
+ */
+export class EventSourceTail {
+  private source: EventSource;
+
+  constructor(url: string) {
+    this.source = new EventSource(url);
+    // the backend ends the stream with a close event, reconnecting would
+    // replay the stream
+    this.source.addEventListener('close', () => this.destroy());
+  }
+
+  onopen(fn: () => void) {
+    this.source.addEventListener('open', fn);
+    return this;
+  }
+
+  onmessage(fn: (data: TailMessage) => void) {
+    this.source.addEventListener('message', (event: MessageEvent) => fn(JSON.parse(event.data)));
+    return this;
+  }
+
+  onerror(fn: (error: unknown) => void) {
+    this.source.addEventListener('error', (event) => {
+      // the browser reconnects on the transient errors
+      if (this.source.readyState === EventSource.CLOSED) {
+        fn(event);
+      }
+    });
+    return this;
+  }
+
+  destroy() {
+    this.source.close();
+  }
+}
+
+export const connectToTailEventSource = (params: TailParams) =>
+  new EventSourceTail(
+    `${getTailURL({
+      config: params.config,
+      tenant: params.tenant,
+      eventSource: true,
+    })}?${getTailSearchParams(params)}`,
+  );
+
 export const getRules = ({ config, tenant }: { config?: Config; tenant: string }) => {
   const { endpoint, requestInit } = getFetchConfig({
     config,
@@ -153,3 +240,12 @@ export const getRules = ({ config, tenant }: { config?: Config; tenant: string }
 
   return cancellableFetch<RulesResponse>(`${endpoint}/prometheus/api/v1/rules`, requestInit);
 };
+
+/**
+ * getNamespaceTenant resolves the tenant holding the logs of a namespace with
+ * the tenantMapping of the plugin backend
+ */
+export const getNamespaceTenant = (namespace: string) =>
+  cancellableFetch<{ namespace: string; tenant: string }>(
+    `${PLUGIN_BACKEND_ENDPOINT}/api/tenant?${new URLSearchParams({ namespace })}`,
+  );
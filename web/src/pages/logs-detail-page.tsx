@@ -11,10 +11,10 @@ import { RefreshIntervalDropdown } from '../components/refresh-interval-dropdown
 import { TimeRangeDropdown } from '../components/time-range-dropdown';
 import { ToggleHistogramButton } from '../components/toggle-histogram-button';
 import { useLogs } from '../hooks/useLogs';
+import { useNamespaceTenant } from '../hooks/useNamespaceTenant';
 import { useURLState } from '../hooks/useURLState';
 import { Direction } from '../logs.types';
 import { TestIds } from '../test-ids';
-import { getInitialTenantFromNamespace } from '../value-utils';
 
 const LogsDetailPage: React.FunctionComponent = () => {
   const { name: podname, ns: namespace } = useParams<{ name: string; ns: string }>();
@@ -42,8 +42,7 @@ const LogsDetailPage: React.FunctionComponent = () => {
     defaultQuery,
     attributes: attributesForPod,
   });
-  const initialTenant = getInitialTenantFromNamespace(namespace);
-  const tenant = React.useRef(initialTenant);
+  const tenant = useNamespaceTenant(namespace);
 
   const {
     isLoadingLogsData,
@@ -76,10 +75,15 @@ const LogsDetailPage: React.FunctionComponent = () => {
   };
 
   const runQuery = () => {
-    getLogs({ query, tenant: tenant.current, namespace, timeRange, direction });
+    // the queries wait for the tenant of the namespace
+    if (!tenant) {
+      return;
+    }
+
+    getLogs({ query, tenant, namespace, timeRange, direction });
 
     if (isHistogramVisible) {
-      getHistogram({ query, tenant: tenant.current, namespace, timeRange });
+      getHistogram({ query, tenant, namespace, timeRange });
     }
   };
 
@@ -111,7 +115,7 @@ const LogsDetailPage: React.FunctionComponent = () => {
 
   React.useEffect(() => {
     runQuery();
-  }, [timeRange, isHistogramVisible, direction]);
+  }, [timeRange, isHistogramVisible, direction, tenant]);
 
   const isQueryEmpty = query === '';
 
@@ -16,10 +16,10 @@ import { RefreshIntervalDropdown } from '../components/refresh-interval-dropdown
 import { TimeRangeDropdown } from '../components/time-range-dropdown';
 import { ToggleHistogramButton } from '../components/toggle-histogram-button';
 import { useLogs } from '../hooks/useLogs';
+import { useNamespaceTenant } from '../hooks/useNamespaceTenant';
 import { useURLState } from '../hooks/useURLState';
 import { Direction } from '../logs.types';
 import { TestIds } from '../test-ids';
-import { getInitialTenantFromNamespace } from '../value-utils';
 
 const LogsDevPage: React.FunctionComponent = () => {
   const { ns: namespace } = useParams<{ ns: string }>();
@@ -39,7 +39,7 @@ const LogsDevPage: React.FunctionComponent = () => {
     setDirectionInURL,
   } = useURLState({ attributes: availableAttributes });
 
-  const tenant = getInitialTenantFromNamespace(namespace);
+  const tenant = useNamespaceTenant(namespace);
 
   const {
     histogramData,
@@ -73,6 +73,11 @@ const LogsDevPage: React.FunctionComponent = () => {
   };
 
   const runQuery = () => {
+    // the queries wait for the tenant of the namespace
+    if (!tenant) {
+      return;
+    }
+
     getLogs({ query, namespace, timeRange, direction, tenant });
 
     if (isHistogramVisible) {
@@ -107,7 +112,7 @@ const LogsDevPage: React.FunctionComponent = () => {
 
   React.useEffect(() => {
     runQuery();
-  }, [timeRange, isHistogramVisible, namespace, direction]);
+  }, [timeRange, isHistogramVisible, namespace, direction, tenant]);
 
   const isQueryEmpty = query === '';
 
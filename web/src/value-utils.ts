@@ -68,13 +68,3 @@ export const millisecondsFromDuration = (duration: string): number => {
 
 export const padLeadingZero = (value: number): string =>
   value >= 10 ? value.toString(10) : `0${value}`;
-
-const DEFAULT_TENANT = 'application';
-
-export const getInitialTenantFromNamespace = (namespace?: string): string => {
-  if (namespace && /^openshift-|^openshift$|^default$|^kube-/.test(namespace)) {
-    return 'infrastructure';
-  }
-
-  return DEFAULT_TENANT;
-};
@@ -0,0 +1,8 @@
+// Package web bundles the built frontend into the plugin backend binary
+package web
+
+import "io/fs"
+
+// Dist is the frontend built in web/dist, embedded when the backend is built
+// with the embed tag and nil otherwise
+var Dist fs.FS